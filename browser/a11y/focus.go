@@ -0,0 +1,72 @@
+package a11y
+
+import (
+	"sort"
+
+	"browser/dom"
+)
+
+// TabOrder returns the nodes Tab moves through, in order: those with a
+// positive tabindex by increasing tabindex, then the other focusable nodes
+// in document order. Disabled controls and nodes with a negative tabindex
+// are skipped, and of each group of radio buttons only the checked one,
+// or the first if none is, takes part.
+func TabOrder(tree *Node) []*Node {
+	var nodes []*Node
+	radios := make(map[radioKey]*Node) // the button Tab stops at in each group
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Focusable && n.tabIndex >= 0 && !n.Disabled {
+			if group, ok := radioGroup(n); ok {
+				if stop, ok := radios[group]; !ok {
+					radios[group] = n
+					nodes = append(nodes, n)
+				} else if n.Checked == "true" && stop.Checked != "true" {
+					radios[group] = n
+					for i := range nodes {
+						if nodes[i] == stop {
+							nodes[i] = n
+						}
+					}
+				}
+			} else {
+				nodes = append(nodes, n)
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(tree)
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i].tabIndex, nodes[j].tabIndex
+		return a > 0 && (b == 0 || a < b)
+	})
+	return nodes
+}
+
+// radioKey identifies a group of radio buttons: a name within a form
+type radioKey struct {
+	form *dom.Node
+	name string
+}
+
+// radioGroup returns the group of a named radio button
+func radioGroup(n *Node) (radioKey, bool) {
+	if n.DOM == nil || n.DOM.TagName != "input" || dom.InputType(n.DOM) != "radio" {
+		return radioKey{}, false
+	}
+	name := n.DOM.Attributes["name"]
+	if name == "" {
+		return radioKey{}, false
+	}
+	key := radioKey{name: name}
+	for p := n.DOM.Parent; p != nil; p = p.Parent {
+		if p.TagName == "form" {
+			key.form = p
+			break
+		}
+	}
+	return key, true
+}
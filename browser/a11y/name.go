@@ -0,0 +1,194 @@
+package a11y
+
+import (
+	"strings"
+
+	"browser/dom"
+)
+
+// nameFromContent are the roles whose name defaults to their text
+var nameFromContent = map[string]bool{
+	"button":       true,
+	"cell":         true,
+	"checkbox":     true,
+	"columnheader": true,
+	"heading":      true,
+	"link":         true,
+	"menuitem":     true,
+	"option":       true,
+	"radio":        true,
+	"rowheader":    true,
+	"switch":       true,
+	"tab":          true,
+	"tooltip":      true,
+	"treeitem":     true,
+}
+
+// name computes an element's accessible name, roughly following the
+// accessible name computation of the ARIA spec: aria-labelledby, then
+// aria-label, then what the element's tag provides, then its text for
+// roles named by their content, then its title
+func (b *builder) name(node *dom.Node, role string) string {
+	if ids := strings.Fields(node.Attributes["aria-labelledby"]); len(ids) > 0 {
+		var parts []string
+		for _, id := range ids {
+			if ref := b.ids[id]; ref != nil {
+				if text := b.text(ref); text != "" {
+					parts = append(parts, text)
+				}
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, " ")
+		}
+	}
+	if label := collapse(node.Attributes["aria-label"]); label != "" {
+		return label
+	}
+	if name := b.nativeName(node); name != "" {
+		return name
+	}
+	if nameFromContent[role] && node.TagName != "input" {
+		if text := b.text(node); text != "" {
+			return text
+		}
+	}
+	return collapse(node.Attributes["title"])
+}
+
+// nativeName is the name an element's markup gives it: the label of a
+// form field, the alt text of an image, the legend of a fieldset and so on
+func (b *builder) nativeName(node *dom.Node) string {
+	switch node.TagName {
+	case "input":
+		switch dom.InputType(node) {
+		case "submit", "reset", "button":
+			if value := collapse(node.Attributes["value"]); value != "" {
+				return value
+			}
+			switch dom.InputType(node) {
+			case "submit":
+				return "Submit"
+			case "reset":
+				return "Reset"
+			}
+			return ""
+		case "image":
+			if alt := collapse(node.Attributes["alt"]); alt != "" {
+				return alt
+			}
+			return "Submit"
+		}
+		return b.fieldName(node)
+	case "textarea", "select":
+		return b.fieldName(node)
+	case "img", "area":
+		return collapse(node.Attributes["alt"])
+	case "fieldset":
+		return b.childText(node, "legend")
+	case "table":
+		return b.childText(node, "caption")
+	case "figure":
+		return b.childText(node, "figcaption")
+	case "iframe":
+		return collapse(node.Attributes["title"])
+	}
+	return ""
+}
+
+// fieldName is the name of a form field: its <label>, then its title,
+// then its placeholder
+func (b *builder) fieldName(node *dom.Node) string {
+	label := b.labels[node]
+	if label == nil {
+		for n := node.Parent; n != nil; n = n.Parent {
+			if n.TagName == "label" {
+				label = n
+				break
+			}
+		}
+	}
+	if label != nil {
+		if text := b.textOf(label, node); text != "" {
+			return text
+		}
+	}
+	if title := collapse(node.Attributes["title"]); title != "" {
+		return title
+	}
+	return collapse(node.Attributes["placeholder"])
+}
+
+// childText returns the text of the first child element with the given tag
+func (b *builder) childText(node *dom.Node, tag string) string {
+	for _, child := range node.Children {
+		if child.TagName == tag {
+			return b.text(child)
+		}
+	}
+	return ""
+}
+
+// text is the name an element's content gives it: its text, with images
+// counted by their alt text and form fields by their value. Hidden
+// elements do not count.
+func (b *builder) text(node *dom.Node) string {
+	return b.textOf(node, nil)
+}
+
+// textOf is text leaving out skip, the field a label is naming
+func (b *builder) textOf(node, skip *dom.Node) string {
+	var parts []string
+	var walk func(n *dom.Node)
+	walk = func(n *dom.Node) {
+		switch n.Type {
+		case dom.Text:
+			parts = append(parts, n.Text)
+			return
+		case dom.Element:
+		default:
+			return
+		}
+		if hidden(n) {
+			return
+		}
+		if n == skip {
+			return
+		}
+		if label := collapse(n.Attributes["aria-label"]); label != "" && n != node {
+			parts = append(parts, " "+label+" ")
+			return
+		}
+		switch n.TagName {
+		case "img", "area":
+			parts = append(parts, " "+n.Attributes["alt"]+" ")
+			return
+		case "input", "textarea", "select":
+			// Fields inside the content count by their value
+			if isButton(n) {
+				parts = append(parts, " "+b.nativeName(n)+" ")
+			} else {
+				value, _ := b.state(n)
+				parts = append(parts, " "+value+" ")
+			}
+			return
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return collapse(strings.Join(parts, ""))
+}
+
+// isButton reports whether an <input> is a kind of button
+func isButton(node *dom.Node) bool {
+	if node.TagName != "input" {
+		return false
+	}
+	switch dom.InputType(node) {
+	case "submit", "reset", "button", "image":
+		return true
+	}
+	return false
+}
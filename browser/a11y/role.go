@@ -0,0 +1,195 @@
+package a11y
+
+import (
+	"strconv"
+	"strings"
+
+	"browser/dom"
+)
+
+// tagRoles are the implicit ARIA roles of elements whose role does not
+// depend on their attributes or context
+var tagRoles = map[string]string{
+	"article":    "article",
+	"aside":      "complementary",
+	"blockquote": "blockquote",
+	"button":     "button",
+	"caption":    "caption",
+	"code":       "code",
+	"datalist":   "listbox",
+	"dd":         "definition",
+	"details":    "group",
+	"dialog":     "dialog",
+	"dt":         "term",
+	"fieldset":   "group",
+	"figure":     "figure",
+	"form":       "form",
+	"hr":         "separator",
+	"iframe":     "iframe",
+	"li":         "listitem",
+	"main":       "main",
+	"menu":       "list",
+	"meter":      "meter",
+	"nav":        "navigation",
+	"ol":         "list",
+	"optgroup":   "group",
+	"option":     "option",
+	"output":     "status",
+	"p":          "paragraph",
+	"progress":   "progressbar",
+	"summary":    "button",
+	"table":      "table",
+	"tbody":      "rowgroup",
+	"td":         "cell",
+	"textarea":   "textbox",
+	"tfoot":      "rowgroup",
+	"thead":      "rowgroup",
+	"tr":         "row",
+	"ul":         "list",
+}
+
+// leafRoles are roles whose DOM children are not part of the tree: form
+// fields show their value instead, and images their name
+var leafRoles = map[string]bool{
+	"textbox":     true,
+	"searchbox":   true,
+	"spinbutton":  true,
+	"slider":      true,
+	"img":         true,
+	"separator":   true,
+	"progressbar": true,
+	"meter":       true,
+	"iframe":      true,
+}
+
+// roleOf returns an element's role, from its role attribute or else its
+// tag, and the level of a heading. "" means the element has no meaning
+// of its own.
+func roleOf(node *dom.Node) (role string, level int) {
+	if fields := strings.Fields(node.Attributes["role"]); len(fields) > 0 {
+		role = strings.ToLower(fields[0])
+		if role == "heading" {
+			level, _ = strconv.Atoi(node.Attributes["aria-level"])
+			if level == 0 {
+				level = 2
+			}
+		}
+		return role, level
+	}
+
+	tag := node.TagName
+	if len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6' {
+		level = int(tag[1] - '0')
+		if l, err := strconv.Atoi(node.Attributes["aria-level"]); err == nil && l > 0 {
+			level = l
+		}
+		return "heading", level
+	}
+	if role, ok := tagRoles[tag]; ok {
+		return role, 0
+	}
+
+	switch tag {
+	case "a", "area":
+		if _, ok := node.Attributes["href"]; ok {
+			return "link", 0
+		}
+	case "img":
+		if alt, ok := node.Attributes["alt"]; ok && alt == "" {
+			return "presentation", 0
+		}
+		return "img", 0
+	case "input":
+		return inputRole(node), 0
+	case "select":
+		size, _ := strconv.Atoi(node.Attributes["size"])
+		if _, multiple := node.Attributes["multiple"]; multiple || size > 1 {
+			return "listbox", 0
+		}
+		return "combobox", 0
+	case "section":
+		// Only sections with a name are landmarks
+		if node.Attributes["aria-label"] != "" || node.Attributes["aria-labelledby"] != "" {
+			return "region", 0
+		}
+	case "header", "footer":
+		if !inSectioningContent(node) {
+			if tag == "header" {
+				return "banner", 0
+			}
+			return "contentinfo", 0
+		}
+	case "th":
+		if strings.EqualFold(node.Attributes["scope"], "row") {
+			return "rowheader", 0
+		}
+		return "columnheader", 0
+	}
+	return "", 0
+}
+
+// inputRole is the role of an <input> of each type
+func inputRole(node *dom.Node) string {
+	switch dom.InputType(node) {
+	case "button", "submit", "reset", "image", "file":
+		return "button"
+	case "checkbox":
+		return "checkbox"
+	case "radio":
+		return "radio"
+	case "range":
+		return "slider"
+	case "number":
+		return "spinbutton"
+	case "search":
+		return "searchbox"
+	}
+	return "textbox"
+}
+
+// inSectioningContent reports whether a header or footer belongs to an
+// article, aside, main, nav or section rather than to the whole page
+func inSectioningContent(node *dom.Node) bool {
+	for n := node.Parent; n != nil; n = n.Parent {
+		switch n.TagName {
+		case "article", "aside", "main", "nav", "section":
+			return true
+		}
+	}
+	return false
+}
+
+// focusability reports whether an element can take keyboard focus and its
+// tab index: negative for elements only focusable by script or click
+func focusability(node *dom.Node) (focusable bool, tabIndex int) {
+	if isDisabled(node) {
+		return false, -1
+	}
+	if value, ok := node.Attributes["tabindex"]; ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return true, n
+		}
+	}
+	switch node.TagName {
+	case "a", "area":
+		_, ok := node.Attributes["href"]
+		return ok, 0
+	case "button", "select", "textarea", "iframe", "summary":
+		return true, 0
+	case "input":
+		return dom.InputType(node) != "hidden", 0
+	}
+	if ce, ok := node.Attributes["contenteditable"]; ok && !strings.EqualFold(ce, "false") {
+		return true, 0
+	}
+	return false, -1
+}
+
+// IsFocusable reports whether an element can take keyboard focus
+func IsFocusable(node *dom.Node) bool {
+	if node == nil || node.Type != dom.Element || hidden(node) {
+		return false
+	}
+	focusable, _ := focusability(node)
+	return focusable
+}
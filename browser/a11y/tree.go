@@ -0,0 +1,369 @@
+// Package a11y builds the accessibility tree of a page: the semantic view
+// assistive technology gets instead of the DOM.
+//
+// Each element becomes a node with a role, from its tag or ARIA role
+// attribute, an accessible name and its states. Elements without meaning
+// of their own, like <div> and <span>, are left out and their children
+// take their place; elements that are not rendered are left out entirely.
+// The same tree decides the keyboard focus order of the page.
+package a11y
+
+import (
+	"strings"
+
+	"browser/dom"
+	"browser/layout"
+)
+
+// Node is an element or run of text in the accessibility tree
+type Node struct {
+	Role  string `json:"role"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	Level int    `json:"level,omitempty"` // of headings
+
+	// States. Checked is "true", "false" or "mixed" for checkboxes, radio
+	// buttons and switches; Expanded is "true" or "false" when known.
+	Checked   string `json:"checked,omitempty"`
+	Expanded  string `json:"expanded,omitempty"`
+	Selected  bool   `json:"selected,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	Required  bool   `json:"required,omitempty"`
+	Readonly  bool   `json:"readonly,omitempty"`
+	Invalid   bool   `json:"invalid,omitempty"`
+	Focusable bool   `json:"focusable,omitempty"`
+
+	Bounds   *Rect   `json:"bounds,omitempty"` // on the page, when laid out
+	Children []*Node `json:"children,omitempty"`
+
+	DOM *dom.Node `json:"-"`
+
+	tabIndex int // position in the tab order, see TabOrder
+}
+
+// Rect is where a node is on the page
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// StateFunc returns the current value of a form control and whether a
+// checkbox or radio button is checked, as the user left them
+type StateFunc func(node *dom.Node) (value string, checked bool)
+
+// builder holds what Build looks up while walking the document
+type builder struct {
+	state  StateFunc
+	ids    map[string]*dom.Node
+	labels map[*dom.Node]*dom.Node // control -> <label for>
+	boxed  map[*dom.Node]bool      // nodes with boxes, nil without a layout tree
+	bounds map[*dom.Node]Rect
+}
+
+// Build returns the accessibility tree of doc. With the page's layout tree
+// root, elements that are not rendered are left out and nodes get their
+// bounds. state supplies the values of form controls; when nil they are
+// taken from the markup.
+func Build(doc *dom.Node, root *layout.LayoutBox, state StateFunc) *Node {
+	b := &builder{
+		state:  state,
+		ids:    make(map[string]*dom.Node),
+		labels: make(map[*dom.Node]*dom.Node),
+	}
+	if b.state == nil {
+		b.state = markupState
+	}
+	b.index(doc)
+	if root != nil {
+		b.boxed = make(map[*dom.Node]bool)
+		b.bounds = make(map[*dom.Node]Rect)
+		b.measure(root)
+	}
+
+	tree := &Node{Role: "document", Name: dom.FindTitle(doc), DOM: doc}
+	if r, ok := b.bounds[doc]; ok {
+		tree.Bounds = &r
+	}
+	for _, child := range doc.Children {
+		tree.Children = append(tree.Children, b.build(child, false)...)
+	}
+	return tree
+}
+
+// markupState reads form control values from the markup
+func markupState(node *dom.Node) (string, bool) {
+	switch node.TagName {
+	case "textarea":
+		return node.InnerText(), false
+	case "select":
+		// The first selected option, or the first option
+		var first *dom.Node
+		for _, option := range options(node) {
+			if _, selected := option.Attributes["selected"]; selected {
+				return optionValue(option), false
+			}
+			if first == nil {
+				first = option
+			}
+		}
+		if first != nil {
+			return optionValue(first), false
+		}
+		return "", false
+	}
+	_, checked := node.Attributes["checked"]
+	return node.Attributes["value"], checked
+}
+
+// options returns the <option> elements of a <select>, in optgroups too
+func options(sel *dom.Node) []*dom.Node {
+	var found []*dom.Node
+	for _, child := range sel.Children {
+		switch child.TagName {
+		case "option":
+			found = append(found, child)
+		case "optgroup":
+			found = append(found, options(child)...)
+		}
+	}
+	return found
+}
+
+// index records the elements with ids and the labels that point at them
+func (b *builder) index(doc *dom.Node) {
+	var labels []*dom.Node
+	var walk func(node *dom.Node)
+	walk = func(node *dom.Node) {
+		if node.Type == dom.Element {
+			if id := node.Attributes["id"]; id != "" {
+				if _, dup := b.ids[id]; !dup {
+					b.ids[id] = node
+				}
+			}
+			if node.TagName == "label" {
+				labels = append(labels, node)
+			}
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	for _, label := range labels {
+		if target := b.ids[label.Attributes["for"]]; target != nil {
+			if _, have := b.labels[target]; !have {
+				b.labels[target] = label
+			}
+		}
+	}
+}
+
+// measure records which nodes have boxes, and gives every element the
+// union of the boxes it and its descendants were laid out in
+func (b *builder) measure(box *layout.LayoutBox) {
+	for n := box.Node; n != nil && !b.boxed[n]; n = n.Parent {
+		b.boxed[n] = true
+	}
+	if box.Node != nil && box.Rect.Width > 0 && box.Rect.Height > 0 {
+		r := Rect{box.Rect.X, box.Rect.Y, box.Rect.Width, box.Rect.Height}
+		for n := box.Node; n != nil; n = n.Parent {
+			if have, ok := b.bounds[n]; ok {
+				r = union(have, r)
+			}
+			b.bounds[n] = r
+		}
+	}
+	for _, child := range box.Children {
+		b.measure(child)
+	}
+}
+
+// union is the smallest rect holding a and b
+func union(a, b Rect) Rect {
+	x, y := min(a.X, b.X), min(a.Y, b.Y)
+	return Rect{
+		X:      x,
+		Y:      y,
+		Width:  max(a.X+a.Width, b.X+b.Width) - x,
+		Height: max(a.Y+a.Height, b.Y+b.Height) - y,
+	}
+}
+
+// build returns the nodes node contributes to its parent: one node, the
+// nodes of its children when it is ignored, or none. Inside a <select> or
+// similar, children are kept even though they have no boxes of their own.
+func (b *builder) build(node *dom.Node, unboxed bool) []*Node {
+	switch node.Type {
+	case dom.Text:
+		text := collapse(node.Text)
+		if text == "" || !b.rendered(node, unboxed) {
+			return nil
+		}
+		return []*Node{{Role: "text", Name: text, DOM: node, Bounds: b.boundsOf(node)}}
+	case dom.Element:
+	default:
+		return nil
+	}
+	if hidden(node) || !b.rendered(node, unboxed) {
+		return nil
+	}
+
+	role, level := roleOf(node)
+	focusable, tabIndex := focusability(node)
+	if role == "" && focusable {
+		role = "generic"
+	}
+
+	var children []*Node
+	if !leafRoles[role] {
+		childUnboxed := unboxed || node.TagName == "select" || node.TagName == "datalist"
+		for _, child := range node.Children {
+			children = append(children, b.build(child, childUnboxed)...)
+		}
+	}
+	if role == "" || role == "none" || role == "presentation" {
+		return children
+	}
+
+	n := &Node{
+		Role:      role,
+		Name:      b.name(node, role),
+		Level:     level,
+		Focusable: focusable,
+		Bounds:    b.boundsOf(node),
+		Children:  children,
+		DOM:       node,
+		tabIndex:  tabIndex,
+	}
+	b.states(n)
+	return []*Node{n}
+}
+
+// rendered reports whether node was laid out. Without a layout tree every
+// node counts as rendered.
+func (b *builder) rendered(node *dom.Node, unboxed bool) bool {
+	return b.boxed == nil || unboxed || b.boxed[node]
+}
+
+func (b *builder) boundsOf(node *dom.Node) *Rect {
+	if r, ok := b.bounds[node]; ok {
+		return &r
+	}
+	return nil
+}
+
+// hidden reports whether an element is kept from assistive technology
+// whether or not it is rendered
+func hidden(node *dom.Node) bool {
+	switch node.TagName {
+	case "head", "script", "style", "template", "noscript", "title", "meta", "link":
+		return true
+	case "input":
+		if dom.InputType(node) == "hidden" {
+			return true
+		}
+	}
+	if _, ok := node.Attributes["hidden"]; ok {
+		return true
+	}
+	return strings.EqualFold(node.Attributes["aria-hidden"], "true")
+}
+
+// states fills in the states of an element node
+func (b *builder) states(n *Node) {
+	node := n.DOM
+
+	switch n.Role {
+	case "textbox", "searchbox", "spinbutton", "slider", "combobox", "listbox":
+		if node.TagName == "input" || node.TagName == "textarea" || node.TagName == "select" {
+			n.Value, _ = b.state(node)
+		}
+	case "checkbox", "radio", "switch":
+		if node.TagName == "input" {
+			_, checked := b.state(node)
+			n.Checked = boolString(checked)
+		}
+	case "option":
+		if sel := selectOf(node); sel != nil {
+			value, _ := b.state(sel)
+			n.Selected = value == optionValue(node)
+		} else {
+			_, n.Selected = node.Attributes["selected"]
+		}
+	case "progressbar", "meter":
+		n.Value = node.Attributes["value"]
+	}
+
+	if checked, ok := node.Attributes["aria-checked"]; ok {
+		n.Checked = strings.ToLower(checked)
+	}
+	if expanded, ok := node.Attributes["aria-expanded"]; ok {
+		n.Expanded = strings.ToLower(expanded)
+	} else if node.TagName == "details" {
+		_, open := node.Attributes["open"]
+		n.Expanded = boolString(open)
+	}
+	if strings.EqualFold(node.Attributes["aria-selected"], "true") {
+		n.Selected = true
+	}
+
+	n.Disabled = isDisabled(node)
+	_, required := node.Attributes["required"]
+	n.Required = required || strings.EqualFold(node.Attributes["aria-required"], "true")
+	_, readonly := node.Attributes["readonly"]
+	n.Readonly = readonly || strings.EqualFold(node.Attributes["aria-readonly"], "true")
+	n.Invalid = node.Attributes["aria-invalid"] != "" && !strings.EqualFold(node.Attributes["aria-invalid"], "false")
+}
+
+// isDisabled reports whether a control is disabled, itself or by a
+// disabled fieldset, or marked aria-disabled
+func isDisabled(node *dom.Node) bool {
+	if strings.EqualFold(node.Attributes["aria-disabled"], "true") {
+		return true
+	}
+	switch node.TagName {
+	case "input", "button", "select", "textarea", "option", "optgroup", "fieldset":
+	default:
+		return false
+	}
+	for n := node; n != nil; n = n.Parent {
+		if _, ok := n.Attributes["disabled"]; ok && (n == node || n.TagName == "fieldset" || n.TagName == "optgroup" || n.TagName == "select") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectOf returns the <select> an <option> belongs to
+func selectOf(option *dom.Node) *dom.Node {
+	for n := option.Parent; n != nil; n = n.Parent {
+		if n.TagName == "select" {
+			return n
+		}
+	}
+	return nil
+}
+
+// optionValue is the value an <option> submits: its value attribute or
+// its text
+func optionValue(option *dom.Node) string {
+	if value, ok := option.Attributes["value"]; ok {
+		return value
+	}
+	return collapse(option.InnerText())
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// collapse trims text and collapses its runs of white space
+func collapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
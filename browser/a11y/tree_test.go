@@ -0,0 +1,201 @@
+package a11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// build lays out html and returns its accessibility tree
+func build(t *testing.T, html string, state StateFunc) *Node {
+	t.Helper()
+	doc := dom.Parse(strings.NewReader(html))
+	root := layout.BuildLayoutTree(doc, css.Parse(dom.FindActiveStyleContent(doc)), layout.Viewport{Width: 800})
+	layout.ComputeLayout(root, 800)
+	return Build(doc, root, state)
+}
+
+// outline prints a tree one node per line, without text nodes that only
+// repeat their parent's name
+func outline(n *Node) string {
+	var sb strings.Builder
+	var walk func(n *Node, depth int, parentName string)
+	walk = func(n *Node, depth int, parentName string) {
+		if n.Role == "text" && n.Name == parentName {
+			return
+		}
+		line := n.Role
+		if n.Name != "" {
+			line += fmt.Sprintf(" %q", n.Name)
+		}
+		if n.Value != "" {
+			line += " value=" + n.Value
+		}
+		if n.Level != 0 {
+			line += fmt.Sprintf(" level=%d", n.Level)
+		}
+		if n.Checked != "" {
+			line += " checked=" + n.Checked
+		}
+		if n.Expanded != "" {
+			line += " expanded=" + n.Expanded
+		}
+		for _, state := range []struct {
+			on   bool
+			name string
+		}{{n.Selected, "selected"}, {n.Disabled, "disabled"}, {n.Required, "required"}, {n.Readonly, "readonly"}, {n.Invalid, "invalid"}, {n.Focusable, "focusable"}} {
+			if state.on {
+				line += " " + state.name
+			}
+		}
+		sb.WriteString(strings.Repeat("  ", depth) + line + "\n")
+		for _, child := range n.Children {
+			walk(child, depth+1, n.Name)
+		}
+	}
+	walk(n, 0, "")
+	return sb.String()
+}
+
+func TestBuild(t *testing.T) {
+	tree := build(t, `<html><head><title>Shop</title><style>.gone { display: none }</style></head><body>
+		<header><nav aria-label="Main"><a href="/">Home <img src="logo.png" alt="Logo"></a> <a>no href</a></nav></header>
+		<main>
+			<h1>Cart</h1>
+			<div class="gone"><a href="/secret">Hidden</a></div>
+			<p aria-hidden="true">Decoration</p>
+			<img src="spacer.gif" alt="">
+			<ul><li>One</li><li hidden>Two</li></ul>
+			<section>Unnamed</section>
+			<div role="button" tabindex="0" aria-pressed="false">Custom</div>
+		</main>
+		<footer>Fine print</footer>
+	</body></html>`, nil)
+
+	assert.Equal(t, `document "Shop"
+  banner
+    navigation "Main"
+      link "Home Logo" focusable
+        text "Home"
+        img "Logo"
+      text "no href"
+  main
+    heading "Cart" level=1
+    list
+      listitem
+        text "One"
+    text "Unnamed"
+    button "Custom" focusable
+  contentinfo
+    text "Fine print"
+`, outline(tree))
+	require.NotNil(t, tree.Children[0].Bounds)
+}
+
+func TestFormControls(t *testing.T) {
+	values := map[string]string{"email": "ann@example.com"}
+	state := func(node *dom.Node) (string, bool) {
+		if node.TagName == "select" {
+			return "b", false
+		}
+		return values[node.Attributes["id"]], node.Attributes["id"] == "terms"
+	}
+	tree := build(t, `<form>
+		<input id="email" type="email" required>
+		<label for="email">Email</label>
+		<label><input id="terms" type="checkbox"> I agree</label>
+		<input type="search" placeholder="Search" readonly>
+		<input type="number" title="Count" aria-invalid="true">
+		<input type="hidden" value="secret">
+		<select aria-labelledby="pick"><option value="a">A</option><option value="b">B</option></select>
+		<span id="pick">Pick one</span>
+		<fieldset disabled><legend>Extras</legend><input type="submit"></fieldset>
+		<details open><summary>More</summary>Text</details>
+	</form>`, state)
+
+	assert.Equal(t, `document
+  form
+    textbox "Email" value=ann@example.com required focusable
+    text "Email"
+    checkbox "I agree" checked=true focusable
+    text "I agree"
+    searchbox "Search" readonly focusable
+    spinbutton "Count" invalid focusable
+    combobox "Pick one" value=b focusable
+      option "A"
+      option "B" selected
+    text "Pick one"
+    group "Extras" disabled
+      button "Submit" disabled
+    group expanded=true
+      button "More" focusable
+      text "Text"
+`, outline(tree))
+}
+
+func TestBuildWithoutLayout(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<div style="display: none"><button>Go</button></div><select><option>X</option><option selected>Y</option></select>`))
+	tree := Build(doc, nil, nil)
+	assert.Equal(t, `document
+  button "Go" focusable
+  combobox value=Y focusable
+    option "X"
+    option "Y" selected
+`, outline(tree))
+	assert.Nil(t, tree.Children[0].Bounds)
+}
+
+func TestTabOrder(t *testing.T) {
+	tree := build(t, `<a href="/a">A</a>
+		<button tabindex="2">Second</button>
+		<input id="name" aria-label="Name">
+		<button disabled>Off</button>
+		<span tabindex="-1">Script only</span>
+		<button tabindex="1">First</button>
+		<form>
+			<input type="radio" name="size" value="s" aria-label="Small">
+			<input type="radio" name="size" value="m" aria-label="Medium" checked>
+			<input type="radio" name="color" value="red" aria-label="Red">
+			<input type="radio" name="color" value="blue" aria-label="Blue">
+		</form>`, nil)
+
+	var names []string
+	for _, n := range TabOrder(tree) {
+		names = append(names, n.Name)
+	}
+	assert.Equal(t, []string{"First", "Second", "A", "Name", "Medium", "Red"}, names)
+}
+
+func TestIsFocusable(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<a href="/">x</a><a>y</a><input type="hidden"><div contenteditable>z</div><p>w</p>`))
+	var got []bool
+	var walk func(n *dom.Node)
+	walk = func(n *dom.Node) {
+		if n.Type == dom.Element {
+			switch n.TagName {
+			case "a", "input", "div", "p":
+				got = append(got, IsFocusable(n))
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(doc)
+	assert.Equal(t, []bool{true, false, false, true, false}, got)
+}
+
+func TestJSON(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<h2>Title</h2>`))
+	out, err := json.Marshal(Build(doc, nil, nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"document","children":[{"role":"heading","name":"Title","level":2,"children":[{"role":"text","name":"Title"}]}]}`, string(out))
+}
@@ -0,0 +1,208 @@
+package animation
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTiming(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		at    float64
+		want  float64
+	}{
+		{"linear", "linear", 0.3, 0.3},
+		{"ease ends", "ease", 1, 1},
+		{"ease starts slow", "ease", 0.1, 0.0948},
+		{"ease-in", "ease-in", 0.5, 0.3153},
+		{"ease-out", "ease-out", 0.5, 0.6847},
+		{"cubic-bezier as linear", "cubic-bezier(0, 0, 1, 1)", 0.42, 0.42},
+		{"steps end", "steps(4)", 0.3, 0.25},
+		{"steps start", "steps(4, start)", 0.3, 0.5},
+		{"step-end", "step-end", 0.99, 0},
+		{"jump-none", "steps(3, jump-none)", 0.5, 0.5},
+		{"unknown is ease", "bouncy", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, ParseTiming(tt.value)(tt.at), 0.001)
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	assert.Equal(t, 15.0, interpolate(10.0, 20.0, 0.5))
+
+	mid := interpolate(color.RGBA{0, 0, 0, 255}, color.RGBA{200, 100, 50, 255}, 0.5).(color.Color)
+	r, g, b, a := mid.RGBA()
+	assert.Equal(t, []uint32{100, 50, 25, 255}, []uint32{r >> 8, g >> 8, b >> 8, a >> 8})
+
+	// Fading in from no background keeps the color, only alpha changes
+	faded := interpolate(nil, color.RGBA{255, 0, 0, 255}, 0.5).(color.Color)
+	n := color.NRGBAModel.Convert(faded).(color.NRGBA)
+	assert.Equal(t, uint8(255), n.R)
+	assert.InDelta(t, 128, int(n.A), 1)
+
+	none := []css.TransformFunc(nil)
+	spun := []css.TransformFunc{{Name: "translate", Args: []float64{100, 0}}, {Name: "rotate", Args: []float64{90}}}
+	assert.Equal(t, []css.TransformFunc{
+		{Name: "translate", Args: []float64{50, 0}},
+		{Name: "rotate", Args: []float64{45}},
+	}, interpolate(none, spun, 0.5))
+
+	// Mismatched lists jump halfway
+	scaled := []css.TransformFunc{{Name: "scale", Args: []float64{2, 2}}}
+	assert.Equal(t, scaled, interpolate(scaled, spun, 0.4))
+	assert.Equal(t, spun, interpolate(scaled, spun, 0.6))
+}
+
+// page parses html and sheet and lays them out
+func page(t *testing.T, html, sheet string) (*dom.Node, *layout.LayoutBox, css.Stylesheet) {
+	t.Helper()
+	doc := dom.Parse(strings.NewReader(html))
+	stylesheet := css.Parse(sheet)
+	tree := layout.BuildLayoutTree(doc, stylesheet, layout.Viewport{Width: 800, Height: 600})
+	layout.ComputeLayout(tree, 800)
+	return doc, tree, stylesheet
+}
+
+func findBox(box *layout.LayoutBox, id string) *layout.LayoutBox {
+	if box.Node != nil && box.Node.Attributes["id"] == id {
+		return box
+	}
+	for _, child := range box.Children {
+		if found := findBox(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestTransitionStartsWhenStyleChanges(t *testing.T) {
+	sheet := `#box { width: 100px; opacity: 1; transition: opacity 1s linear, width 2s linear; }
+		#box.faded { width: 300px; opacity: 0.5; }`
+	doc, tree, stylesheet := page(t, `<div id="box">x</div>`, sheet)
+	viewport := layout.Viewport{Width: 800, Height: 600}
+
+	engine := NewEngine()
+	start := time.Now()
+	assert.False(t, engine.Update(tree, stylesheet, viewport, start), "nothing runs on first style")
+
+	node := findBox(tree, "box").Node
+	node.Attributes["class"] = "faded"
+	node.MarkDirty()
+	tree = layout.UpdateLayoutTree(tree, doc, stylesheet, viewport)
+	assert.True(t, engine.Update(tree, stylesheet, viewport, start))
+
+	box := findBox(tree, "box")
+	assert.Equal(t, 1.0, box.Style.Opacity)
+	assert.Equal(t, 100.0, box.Style.Width)
+
+	layout.ComputeLayout(tree, 800)
+	assert.True(t, engine.Tick(start.Add(500*time.Millisecond)))
+	assert.InDelta(t, 0.75, box.Style.Opacity, 1e-9)
+	assert.InDelta(t, 150, box.Style.Width, 1e-9)
+	assert.Zero(t, box.Generation(), "animated boxes are laid out again")
+
+	assert.True(t, engine.Tick(start.Add(time.Second)))
+	assert.Equal(t, 0.5, box.Style.Opacity)
+	assert.False(t, engine.Tick(start.Add(2*time.Second)))
+	assert.Equal(t, 300.0, box.Style.Width)
+}
+
+func TestTransitionRetargetsFromCurrentValue(t *testing.T) {
+	sheet := `#box { opacity: 1; transition: opacity 1s linear; } #box.faded { opacity: 0; }`
+	doc, tree, stylesheet := page(t, `<div id="box">x</div>`, sheet)
+	viewport := layout.Viewport{Width: 800, Height: 600}
+	engine := NewEngine()
+	start := time.Now()
+	engine.Update(tree, stylesheet, viewport, start)
+
+	restyle := func(class string, at time.Time) *layout.LayoutBox {
+		node := findBox(tree, "box").Node
+		node.Attributes["class"] = class
+		node.MarkDirty()
+		tree = layout.UpdateLayoutTree(tree, doc, stylesheet, viewport)
+		engine.Update(tree, stylesheet, viewport, at)
+		return findBox(tree, "box")
+	}
+
+	restyle("faded", start)
+	// Halfway through, the class is removed again: it turns back from 0.5
+	box := restyle("", start.Add(500*time.Millisecond))
+	assert.InDelta(t, 0.5, box.Style.Opacity, 1e-9)
+	engine.Tick(start.Add(time.Second))
+	assert.InDelta(t, 0.75, box.Style.Opacity, 1e-9)
+}
+
+func TestKeyframeAnimation(t *testing.T) {
+	sheet := `@keyframes pulse {
+			from { background-color: #000000; }
+			50% { background-color: #ff0000; transform: scale(2); }
+			to { background-color: #000000; }
+		}
+		#box { animation: pulse 1s linear 2 alternate forwards; }`
+	_, tree, stylesheet := page(t, `<div id="box">x</div>`, sheet)
+	engine := NewEngine()
+	start := time.Now()
+	require.True(t, engine.Update(tree, stylesheet, layout.Viewport{Width: 800, Height: 600}, start))
+	box := findBox(tree, "box")
+
+	red := func() uint32 {
+		r, _, _, _ := box.Style.BackgroundColor.RGBA()
+		return r >> 8
+	}
+
+	engine.Tick(start.Add(250 * time.Millisecond))
+	assert.InDelta(t, 127, red(), 1)
+	// Transform has no from/to keyframes, so it eases from and back to none
+	require.Len(t, box.Style.Transform, 1)
+	assert.InDeltaSlice(t, []float64{1.5, 1.5}, box.Style.Transform[0].Args, 1e-9)
+
+	engine.Tick(start.Add(500 * time.Millisecond))
+	assert.Equal(t, uint32(255), red())
+
+	// The second iteration runs backwards
+	engine.Tick(start.Add(1250 * time.Millisecond))
+	assert.InDelta(t, 127, red(), 1)
+
+	// Filling forwards holds the end of the last iteration, which ran
+	// backwards to the first keyframe
+	assert.False(t, engine.Tick(start.Add(3*time.Second)))
+	assert.Equal(t, uint32(0), red())
+	require.Len(t, box.Style.Transform, 1)
+	assert.Equal(t, []float64{1, 1}, box.Style.Transform[0].Args)
+}
+
+func TestPausedAnimationHoldsItsValue(t *testing.T) {
+	sheet := `@keyframes grow { from { width: 0px; } to { width: 100px; } }
+		#box { animation: grow 1s linear; }
+		#box.paused { animation: grow 1s linear paused; }`
+	doc, tree, stylesheet := page(t, `<div id="box">x</div>`, sheet)
+	viewport := layout.Viewport{Width: 800, Height: 600}
+	engine := NewEngine()
+	start := time.Now()
+	engine.Update(tree, stylesheet, viewport, start)
+
+	node := findBox(tree, "box").Node
+	node.Attributes["class"] = "paused"
+	node.MarkDirty()
+	tree = layout.UpdateLayoutTree(tree, doc, stylesheet, viewport)
+	assert.False(t, engine.Update(tree, stylesheet, viewport, start.Add(300*time.Millisecond)))
+
+	box := findBox(tree, "box")
+	assert.InDelta(t, 30, box.Style.Width, 1e-9)
+	engine.Tick(start.Add(5 * time.Second))
+	assert.InDelta(t, 30, box.Style.Width, 1e-9)
+}
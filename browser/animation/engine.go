@@ -0,0 +1,382 @@
+// Package animation runs CSS transitions and @keyframes animations.
+//
+// Layout resolves styles as usual. The Engine remembers the cascaded style
+// of every element that transitions or animates, and on each frame writes
+// the current animated values over its box's Style, marking the box for
+// relayout and repaint when anything changed.
+package animation
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+)
+
+// FrameInterval is how often a page with running animations is redrawn
+const FrameInterval = time.Second / 60
+
+// Engine tracks the transitions and animations of one document
+type Engine struct {
+	mu       sync.Mutex
+	elements map[*dom.Node]*element
+	sheet    css.Stylesheet
+	viewport layout.Viewport
+}
+
+// element is the animation state of one element
+type element struct {
+	box         *layout.LayoutBox
+	base        css.Style // the cascaded style, before animated values
+	transitions map[string]*transition
+	animations  []*running
+}
+
+// transition moves one property from where it was to its new value
+type transition struct {
+	from, to any
+	start    time.Time // after the delay
+	duration time.Duration
+	timing   TimingFunc
+}
+
+// running is an animation-name bound to its @keyframes rule
+type running struct {
+	spec     css.Animation
+	start    time.Time
+	pausedAt time.Time
+	tracks   map[string][]stop // keyframe values per property
+}
+
+// stop is a property's value at one keyframe offset. timing eases the
+// interval that starts here; nil uses the animation's timing function.
+type stop struct {
+	offset float64
+	value  any
+	timing TimingFunc
+}
+
+func NewEngine() *Engine {
+	return &Engine{elements: make(map[*dom.Node]*element)}
+}
+
+// Update is called after the layout tree was built or updated. Elements
+// whose cascaded style changed start transitions, animation-name changes
+// start and stop animations, and the values at now are applied to the
+// boxes. It reports whether frames are needed to keep animating.
+func (e *Engine) Update(root *layout.LayoutBox, sheet css.Stylesheet, viewport layout.Viewport, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sheet = sheet
+	e.viewport = viewport
+
+	seen := make(map[*dom.Node]bool)
+	var walk func(box *layout.LayoutBox)
+	walk = func(box *layout.LayoutBox) {
+		for _, child := range box.Children {
+			walk(child)
+		}
+		node := box.Node
+		if node == nil || node.Type != dom.Element || seen[node] {
+			return
+		}
+		el := e.elements[node]
+		if el == nil {
+			if len(box.Style.Transitions) == 0 && len(box.Style.Animations) == 0 {
+				return
+			}
+			el = &element{box: box, base: box.Style, transitions: make(map[string]*transition)}
+			e.elements[node] = el
+		} else if el.box != box {
+			// The element was restyled into a new box
+			el.restyle(box, now)
+		}
+		seen[node] = true
+		e.updateAnimations(el, now)
+		el.apply(now)
+		if len(el.transitions) == 0 && len(el.animations) == 0 && len(el.base.Transitions) == 0 {
+			delete(e.elements, node)
+		}
+	}
+	if root != nil {
+		walk(root)
+	}
+
+	for node := range e.elements {
+		if !seen[node] {
+			delete(e.elements, node)
+		}
+	}
+	return e.active(now)
+}
+
+// Tick applies the values at now and reports whether more frames are needed
+func (e *Engine) Tick(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, el := range e.elements {
+		el.apply(now)
+	}
+	return e.active(now)
+}
+
+func (e *Engine) active(now time.Time) bool {
+	for _, el := range e.elements {
+		if len(el.transitions) > 0 {
+			return true
+		}
+		for _, r := range el.animations {
+			if !r.spec.Paused && !r.finished(now) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restyle starts a transition for every transitioning property whose
+// cascaded value changed. Transitions already running are retargeted from
+// their current value.
+func (el *element) restyle(box *layout.LayoutBox, now time.Time) {
+	old, next := el.base, box.Style
+
+	covered := make(map[string]css.Transition)
+	for _, tr := range next.Transitions {
+		for _, prop := range expand(tr.Property) {
+			covered[prop] = tr
+		}
+	}
+	for prop := range el.transitions {
+		if _, ok := covered[prop]; !ok {
+			delete(el.transitions, prop)
+		}
+	}
+
+	for prop, tr := range covered {
+		from, to := get(&old, prop), get(&next, prop)
+		if equal(from, to) {
+			continue
+		}
+		if tr.Duration <= 0 {
+			delete(el.transitions, prop)
+			continue
+		}
+		if current, ok := el.transitions[prop]; ok {
+			from, _ = current.value(now)
+		}
+		el.transitions[prop] = &transition{
+			from:     from,
+			to:       to,
+			start:    now.Add(tr.Delay),
+			duration: tr.Duration,
+			timing:   ParseTiming(tr.Timing),
+		}
+	}
+
+	el.base = next
+	el.box = box
+}
+
+// value returns the transitioned value at now and whether it has finished
+func (tr *transition) value(now time.Time) (any, bool) {
+	elapsed := now.Sub(tr.start)
+	if elapsed < 0 {
+		return tr.from, false
+	}
+	if elapsed >= tr.duration {
+		return tr.to, true
+	}
+	return interpolate(tr.from, tr.to, tr.timing(float64(elapsed)/float64(tr.duration))), false
+}
+
+// updateAnimations matches the element's animation-name list against the
+// animations it is running. Animations that stay keep their start time;
+// their keyframes are resolved again against the current base style.
+func (e *Engine) updateAnimations(el *element, now time.Time) {
+	previous := make(map[string]*running, len(el.animations))
+	for _, r := range el.animations {
+		previous[r.spec.Name] = r
+	}
+
+	var next []*running
+	for _, spec := range el.base.Animations {
+		keyframes := e.sheet.FindKeyframes(spec.Name)
+		if spec.Name == "" || keyframes == nil {
+			continue
+		}
+		r := previous[spec.Name]
+		delete(previous, spec.Name)
+		if r == nil {
+			r = &running{start: now}
+		}
+		switch {
+		case spec.Paused && r.pausedAt.IsZero():
+			r.pausedAt = now
+		case !spec.Paused && !r.pausedAt.IsZero():
+			r.start = r.start.Add(now.Sub(r.pausedAt))
+			r.pausedAt = time.Time{}
+		}
+		r.spec = spec
+		r.tracks = e.tracks(keyframes, el.base, ParseTiming(spec.Timing))
+		next = append(next, r)
+	}
+	el.animations = next
+}
+
+// tracks resolves each keyframe against base and collects the values of
+// every animatable property it sets. Offsets 0 and 1 default to the base
+// value when no keyframe gives one.
+func (e *Engine) tracks(keyframes *css.Keyframes, base css.Style, timing TimingFunc) map[string][]stop {
+	tracks := make(map[string][]stop)
+	for _, frame := range keyframes.Frames {
+		style := base
+		css.ApplyKeyframe(&style, frame, e.viewport.Width, e.viewport.Height)
+
+		frameTiming := timing
+		for _, decl := range frame.Declarations {
+			if strings.EqualFold(decl.Property, "animation-timing-function") {
+				frameTiming = ParseTiming(decl.Value)
+			}
+		}
+		for _, decl := range frame.Declarations {
+			for _, prop := range expand(strings.ToLower(decl.Property)) {
+				tracks[prop] = append(tracks[prop], stop{offset: frame.Offset, value: get(&style, prop), timing: frameTiming})
+			}
+		}
+	}
+
+	for prop, stops := range tracks {
+		if stops[0].offset > 0 {
+			stops = append([]stop{{offset: 0, value: get(&base, prop), timing: timing}}, stops...)
+		}
+		if stops[len(stops)-1].offset < 1 {
+			stops = append(stops, stop{offset: 1, value: get(&base, prop)})
+		}
+		tracks[prop] = stops
+	}
+	return tracks
+}
+
+// progress returns how far through its keyframes the animation is at now,
+// after iterations and direction. ok is false while the animation does
+// not affect the element: during its delay or after it ends, unless its
+// fill mode says otherwise.
+func (r *running) progress(now time.Time) (p float64, ok bool) {
+	at := now
+	if !r.pausedAt.IsZero() {
+		at = r.pausedAt
+	}
+	elapsed := at.Sub(r.start) - r.spec.Delay
+	fill := r.spec.FillMode
+
+	if elapsed < 0 {
+		if fill != "backwards" && fill != "both" {
+			return 0, false
+		}
+		return r.directed(0, 0), true
+	}
+	if r.finished(now) {
+		if fill != "forwards" && fill != "both" {
+			return 0, false
+		}
+		iterations := r.spec.Iterations
+		if iterations == 0 {
+			return r.directed(0, 0), true
+		}
+		// The end of the last, possibly partial, iteration
+		last := math.Ceil(iterations) - 1
+		return r.directed(last, iterations-last), true
+	}
+	pos := float64(elapsed) / float64(r.spec.Duration)
+	iteration := math.Floor(pos)
+	return r.directed(iteration, pos-iteration), true
+}
+
+// directed applies animation-direction to the progress p of an iteration
+func (r *running) directed(iteration, p float64) float64 {
+	odd := math.Mod(iteration, 2) == 1
+	switch r.spec.Direction {
+	case "reverse":
+		return 1 - p
+	case "alternate":
+		if odd {
+			return 1 - p
+		}
+	case "alternate-reverse":
+		if !odd {
+			return 1 - p
+		}
+	}
+	return p
+}
+
+// finished reports whether every iteration has played
+func (r *running) finished(now time.Time) bool {
+	if math.IsInf(r.spec.Iterations, 1) && r.spec.Duration > 0 {
+		return false
+	}
+	at := now
+	if !r.pausedAt.IsZero() {
+		at = r.pausedAt
+	}
+	elapsed := at.Sub(r.start) - r.spec.Delay
+	return r.spec.Duration <= 0 || float64(elapsed) >= float64(r.spec.Duration)*r.spec.Iterations
+}
+
+// apply writes the animated values at now into style
+func (r *running) apply(style *css.Style, now time.Time) {
+	p, ok := r.progress(now)
+	if !ok {
+		return
+	}
+	for prop, stops := range r.tracks {
+		i := 0
+		for i < len(stops)-2 && p >= stops[i+1].offset {
+			i++
+		}
+		from, to := stops[i], stops[i+1]
+		local := 1.0
+		if span := to.offset - from.offset; span > 0 {
+			local = math.Max(0, math.Min(1, (p-from.offset)/span))
+		}
+		timing := from.timing
+		if timing == nil {
+			timing = linear
+		}
+		set(style, prop, interpolate(from.value, to.value, timing(local)))
+	}
+}
+
+// apply computes the element's style at now and writes it to its box,
+// marking the box restyled if any animatable property changed
+func (el *element) apply(now time.Time) {
+	style := el.base
+	for prop, tr := range el.transitions {
+		v, done := tr.value(now)
+		set(&style, prop, v)
+		if done {
+			delete(el.transitions, prop)
+		}
+	}
+	// Animations override transitions
+	for _, r := range el.animations {
+		r.apply(&style, now)
+	}
+
+	box := el.box
+	changed := false
+	for _, prop := range allProperties {
+		if !equal(get(&box.Style, prop), get(&style, prop)) {
+			changed = true
+			break
+		}
+	}
+	box.Style = style
+	if changed {
+		box.Restyled()
+	}
+}
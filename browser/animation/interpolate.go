@@ -0,0 +1,235 @@
+package animation
+
+import (
+	"browser/css"
+	"image/color"
+	"math"
+	"slices"
+)
+
+// numbers are the animatable properties holding a length or a plain number
+var numbers = map[string]func(s *css.Style) *float64{
+	"opacity":             func(s *css.Style) *float64 { return &s.Opacity },
+	"font-size":           func(s *css.Style) *float64 { return &s.FontSize },
+	"line-height":         func(s *css.Style) *float64 { return &s.LineHeight },
+	"width":               func(s *css.Style) *float64 { return &s.Width },
+	"height":              func(s *css.Style) *float64 { return &s.Height },
+	"min-width":           func(s *css.Style) *float64 { return &s.MinWidth },
+	"max-width":           func(s *css.Style) *float64 { return &s.MaxWidth },
+	"min-height":          func(s *css.Style) *float64 { return &s.MinHeight },
+	"max-height":          func(s *css.Style) *float64 { return &s.MaxHeight },
+	"top":                 func(s *css.Style) *float64 { return &s.Top },
+	"left":                func(s *css.Style) *float64 { return &s.Left },
+	"right":               func(s *css.Style) *float64 { return &s.Right },
+	"bottom":              func(s *css.Style) *float64 { return &s.Bottom },
+	"margin-top":          func(s *css.Style) *float64 { return &s.MarginTop },
+	"margin-right":        func(s *css.Style) *float64 { return &s.MarginRight },
+	"margin-bottom":       func(s *css.Style) *float64 { return &s.MarginBottom },
+	"margin-left":         func(s *css.Style) *float64 { return &s.MarginLeft },
+	"padding-top":         func(s *css.Style) *float64 { return &s.PaddingTop },
+	"padding-right":       func(s *css.Style) *float64 { return &s.PaddingRight },
+	"padding-bottom":      func(s *css.Style) *float64 { return &s.PaddingBottom },
+	"padding-left":        func(s *css.Style) *float64 { return &s.PaddingLeft },
+	"border-top-width":    func(s *css.Style) *float64 { return &s.BorderTopWidth },
+	"border-right-width":  func(s *css.Style) *float64 { return &s.BorderRightWidth },
+	"border-bottom-width": func(s *css.Style) *float64 { return &s.BorderBottomWidth },
+	"border-left-width":   func(s *css.Style) *float64 { return &s.BorderLeftWidth },
+	"border-radius":       func(s *css.Style) *float64 { return &s.BorderRadius },
+}
+
+// colors are the animatable color properties
+var colors = map[string]func(s *css.Style) *color.Color{
+	"color":               func(s *css.Style) *color.Color { return &s.Color },
+	"background-color":    func(s *css.Style) *color.Color { return &s.BackgroundColor },
+	"border-top-color":    func(s *css.Style) *color.Color { return &s.BorderTopColor },
+	"border-right-color":  func(s *css.Style) *color.Color { return &s.BorderRightColor },
+	"border-bottom-color": func(s *css.Style) *color.Color { return &s.BorderBottomColor },
+	"border-left-color":   func(s *css.Style) *color.Color { return &s.BorderLeftColor },
+}
+
+// shorthands lists the animatable longhands a shorthand name stands for
+var shorthands = map[string][]string{
+	"margin":       {"margin-top", "margin-right", "margin-bottom", "margin-left"},
+	"padding":      {"padding-top", "padding-right", "padding-bottom", "padding-left"},
+	"inset":        {"top", "right", "bottom", "left"},
+	"border-width": {"border-top-width", "border-right-width", "border-bottom-width", "border-left-width"},
+	"border-color": {"border-top-color", "border-right-color", "border-bottom-color", "border-left-color"},
+	"border": {"border-top-width", "border-right-width", "border-bottom-width", "border-left-width",
+		"border-top-color", "border-right-color", "border-bottom-color", "border-left-color"},
+	"background": {"background-color"},
+}
+
+// allProperties is every property "transition: all" covers, sorted so
+// transitions start in a stable order
+var allProperties = func() []string {
+	props := []string{"transform"}
+	for name := range numbers {
+		props = append(props, name)
+	}
+	for name := range colors {
+		props = append(props, name)
+	}
+	slices.Sort(props)
+	return props
+}()
+
+// expand returns the animatable longhands for a property name
+func expand(name string) []string {
+	if name == "all" {
+		return allProperties
+	}
+	if longhands, ok := shorthands[name]; ok {
+		return longhands
+	}
+	if isAnimatable(name) {
+		return []string{name}
+	}
+	return nil
+}
+
+func isAnimatable(name string) bool {
+	_, isNumber := numbers[name]
+	_, isColor := colors[name]
+	return isNumber || isColor || name == "transform"
+}
+
+// get reads a property's value: a float64, a color.Color or a transform list
+func get(style *css.Style, name string) any {
+	if field, ok := numbers[name]; ok {
+		return *field(style)
+	}
+	if field, ok := colors[name]; ok {
+		return *field(style)
+	}
+	return style.Transform
+}
+
+func set(style *css.Style, name string, value any) {
+	if field, ok := numbers[name]; ok {
+		v := value.(float64)
+		if name == "opacity" {
+			// Easing curves may overshoot
+			v = math.Max(0, math.Min(1, v))
+		}
+		*field(style) = v
+		return
+	}
+	if field, ok := colors[name]; ok {
+		c, _ := value.(color.Color)
+		*field(style) = c
+		return
+	}
+	funcs, _ := value.([]css.TransformFunc)
+	style.Transform = funcs
+}
+
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case float64:
+		return a == b.(float64)
+	case []css.TransformFunc:
+		b := b.([]css.TransformFunc)
+		return slices.EqualFunc(a, b, func(x, y css.TransformFunc) bool {
+			return x.Name == y.Name && slices.Equal(x.Args, y.Args)
+		})
+	}
+	ca, _ := a.(color.Color)
+	cb, _ := b.(color.Color)
+	if ca == nil || cb == nil {
+		return ca == nil && cb == nil
+	}
+	r1, g1, b1, a1 := ca.RGBA()
+	r2, g2, b2, a2 := cb.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// interpolate returns the value t of the way from a to b. t may leave
+// [0, 1] for easing curves that overshoot.
+func interpolate(a, b any, t float64) any {
+	switch a := a.(type) {
+	case float64:
+		return a + (b.(float64)-a)*t
+	case []css.TransformFunc:
+		return interpolateTransforms(a, b.([]css.TransformFunc), t)
+	}
+	ca, _ := a.(color.Color)
+	cb, _ := b.(color.Color)
+	return interpolateColors(ca, cb, t)
+}
+
+// interpolateColors mixes premultiplied RGBA, so fading from transparent
+// does not pass through dark fringes. A missing color is the other one
+// made fully transparent.
+func interpolateColors(a, b color.Color, t float64) color.Color {
+	if a == nil && b == nil {
+		return nil
+	}
+	if a == nil {
+		a = transparent(b)
+	}
+	if b == nil {
+		b = transparent(a)
+	}
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	mix := func(x, y uint32) uint16 {
+		v := float64(x) + (float64(y)-float64(x))*t
+		return uint16(math.Round(math.Max(0, math.Min(0xffff, v))))
+	}
+	alpha := mix(a1, a2)
+	clamp := func(v uint16) uint16 { return min(v, alpha) }
+	return color.RGBA64{R: clamp(mix(r1, r2)), G: clamp(mix(g1, g2)), B: clamp(mix(b1, b2)), A: alpha}
+}
+
+func transparent(c color.Color) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = 0
+	return n
+}
+
+// interpolateTransforms interpolates two transform lists function by
+// function. "none" stands for the identity of the other list's functions;
+// lists of different shapes switch halfway instead.
+func interpolateTransforms(a, b []css.TransformFunc, t float64) []css.TransformFunc {
+	if len(a) == 0 {
+		a = identities(b)
+	}
+	if len(b) == 0 {
+		b = identities(a)
+	}
+	if len(a) != len(b) {
+		if t < 0.5 {
+			return a
+		}
+		return b
+	}
+	out := make([]css.TransformFunc, len(a))
+	for i := range a {
+		if a[i].Name != b[i].Name || len(a[i].Args) != len(b[i].Args) {
+			if t < 0.5 {
+				return a
+			}
+			return b
+		}
+		args := make([]float64, len(a[i].Args))
+		for j := range args {
+			args[j] = a[i].Args[j] + (b[i].Args[j]-a[i].Args[j])*t
+		}
+		out[i] = css.TransformFunc{Name: a[i].Name, Args: args}
+	}
+	return out
+}
+
+// identities returns a transform list shaped like funcs that does nothing
+func identities(funcs []css.TransformFunc) []css.TransformFunc {
+	out := make([]css.TransformFunc, len(funcs))
+	for i, f := range funcs {
+		out[i] = css.TransformFunc{Name: f.Name, Args: make([]float64, len(f.Args))}
+		if f.Name == "scale" {
+			for j := range out[i].Args {
+				out[i].Args[j] = 1
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package animation
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TimingFunc maps the linear progress of an animation (0 to 1) to the
+// eased progress used for interpolation
+type TimingFunc func(t float64) float64
+
+func linear(t float64) float64 { return t }
+
+// ParseTiming returns the easing function for a CSS timing function value.
+// Unknown values fall back to ease.
+func ParseTiming(value string) TimingFunc {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "linear":
+		return linear
+	case "ease-in":
+		return cubicBezier(0.42, 0, 1, 1)
+	case "ease-out":
+		return cubicBezier(0, 0, 0.58, 1)
+	case "ease-in-out":
+		return cubicBezier(0.42, 0, 0.58, 1)
+	case "step-start":
+		return steps(1, "start")
+	case "step-end":
+		return steps(1, "end")
+	}
+
+	if args, ok := functionArgs(value, "cubic-bezier"); ok && len(args) == 4 {
+		var p [4]float64
+		for i, arg := range args {
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return cubicBezier(0.25, 0.1, 0.25, 1)
+			}
+			p[i] = n
+		}
+		// The x coordinates must stay in [0, 1] for the curve to be a function
+		if p[0] >= 0 && p[0] <= 1 && p[2] >= 0 && p[2] <= 1 {
+			return cubicBezier(p[0], p[1], p[2], p[3])
+		}
+	}
+	if args, ok := functionArgs(value, "steps"); ok && len(args) >= 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+			position := "end"
+			if len(args) > 1 {
+				position = args[1]
+			}
+			return steps(n, position)
+		}
+	}
+	return cubicBezier(0.25, 0.1, 0.25, 1) // ease
+}
+
+// functionArgs returns the comma-separated arguments of name(...)
+func functionArgs(value, name string) ([]string, bool) {
+	if !strings.HasPrefix(value, name+"(") || !strings.HasSuffix(value, ")") {
+		return nil, false
+	}
+	args := strings.Split(value[len(name)+1:len(value)-1], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args, true
+}
+
+// cubicBezier returns the easing curve through (0, 0), (x1, y1), (x2, y2)
+// and (1, 1), solving for the curve parameter at each x
+func cubicBezier(x1, y1, x2, y2 float64) TimingFunc {
+	// Polynomial coefficients of x(s) and y(s)
+	cx := 3 * x1
+	bx := 3*(x2-x1) - cx
+	ax := 1 - cx - bx
+	cy := 3 * y1
+	by := 3*(y2-y1) - cy
+	ay := 1 - cy - by
+
+	sampleX := func(s float64) float64 { return ((ax*s+bx)*s + cx) * s }
+	sampleY := func(s float64) float64 { return ((ay*s+by)*s + cy) * s }
+	slopeX := func(s float64) float64 { return (3*ax*s+2*bx)*s + cx }
+
+	return func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return t
+		}
+		// Newton's method converges quickly on smooth curves
+		s := t
+		for range 8 {
+			dx := sampleX(s) - t
+			if math.Abs(dx) < 1e-7 {
+				return sampleY(s)
+			}
+			slope := slopeX(s)
+			if math.Abs(slope) < 1e-6 {
+				break
+			}
+			s -= dx / slope
+		}
+		// Fall back to bisection where the slope is flat
+		lo, hi := 0.0, 1.0
+		s = t
+		for range 50 {
+			x := sampleX(s)
+			if math.Abs(x-t) < 1e-7 {
+				break
+			}
+			if x < t {
+				lo = s
+			} else {
+				hi = s
+			}
+			s = (lo + hi) / 2
+		}
+		return sampleY(s)
+	}
+}
+
+// steps returns a staircase of n steps. position says where the jumps are:
+// at the start of each interval, at the end, at both ends or neither.
+func steps(n int, position string) TimingFunc {
+	return func(t float64) float64 {
+		if t < 0 || t > 1 {
+			return t
+		}
+		switch position {
+		case "start", "jump-start":
+			return math.Min(1, math.Floor(t*float64(n)+1)/float64(n))
+		case "jump-both":
+			return math.Min(1, math.Floor(t*float64(n)+1)/float64(n+1))
+		case "jump-none":
+			if n == 1 {
+				return math.Floor(t)
+			}
+			return math.Min(1, math.Floor(t*float64(n))/float64(n-1))
+		default: // end, jump-end
+			return math.Floor(t*float64(n)) / float64(n)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package css
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transition is one entry of a transition list
+type Transition struct {
+	Property string // property name, or "all"
+	Duration time.Duration
+	Delay    time.Duration
+	Timing   string // timing function as written, e.g. "ease" or "cubic-bezier(0, 0, 1, 1)"
+}
+
+// Animation is one entry of an animation list
+type Animation struct {
+	Name       string // @keyframes name, empty for none
+	Duration   time.Duration
+	Delay      time.Duration
+	Timing     string
+	Iterations float64 // math.Inf(1) for infinite
+	Direction  string  // normal, reverse, alternate, alternate-reverse
+	FillMode   string  // none, forwards, backwards, both
+	Paused     bool
+}
+
+// Keyframes is a @keyframes rule, its frames sorted by offset
+type Keyframes struct {
+	Name   string
+	Frames []Keyframe
+}
+
+// Keyframe is one selector block of a @keyframes rule
+type Keyframe struct {
+	Offset       float64 // 0 for from, 1 for to
+	Declarations []Declaration
+}
+
+// TransformFunc is one function of a transform list, normalized so lists
+// can be interpolated pairwise: translate has x and y in pixels, scale has
+// x and y factors and rotate has an angle in degrees
+type TransformFunc struct {
+	Name string // translate, scale or rotate
+	Args []float64
+}
+
+func defaultTransition() Transition {
+	return Transition{Property: "all", Timing: "ease"}
+}
+
+func defaultAnimation() Animation {
+	return Animation{Timing: "ease", Iterations: 1, Direction: "normal", FillMode: "none"}
+}
+
+// FindKeyframes returns the last @keyframes rule called name
+func (s Stylesheet) FindKeyframes(name string) *Keyframes {
+	for i := len(s.Keyframes) - 1; i >= 0; i-- {
+		if s.Keyframes[i].Name == name {
+			return &s.Keyframes[i]
+		}
+	}
+	return nil
+}
+
+// parseKeyframeSelector converts "from", "to" and "50%" to offsets
+func parseKeyframeSelector(selector string) []float64 {
+	var offsets []float64
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch part {
+		case "from":
+			offsets = append(offsets, 0)
+		case "to":
+			offsets = append(offsets, 1)
+		default:
+			if !strings.HasSuffix(part, "%") {
+				continue
+			}
+			if n, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64); err == nil && n >= 0 && n <= 100 {
+				offsets = append(offsets, n/100)
+			}
+		}
+	}
+	return offsets
+}
+
+func sortKeyframes(frames []Keyframe) {
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].Offset < frames[j].Offset })
+}
+
+// ParseTime parses a CSS time such as "0.3s" or "150ms"
+func ParseTime(value string) (time.Duration, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	unit := time.Second
+	switch {
+	case strings.HasSuffix(value, "ms"):
+		value = strings.TrimSuffix(value, "ms")
+		unit = time.Millisecond
+	case strings.HasSuffix(value, "s"):
+		value = strings.TrimSuffix(value, "s")
+	default:
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n * float64(unit)), true
+}
+
+// isTimingFunction reports whether a token names an easing function
+func isTimingFunction(token string) bool {
+	switch token {
+	case "ease", "linear", "ease-in", "ease-out", "ease-in-out", "step-start", "step-end":
+		return true
+	}
+	return strings.HasPrefix(token, "cubic-bezier(") || strings.HasPrefix(token, "steps(")
+}
+
+// fieldsOutsideParens splits s at whitespace that is not inside parentheses
+func fieldsOutsideParens(s string) []string {
+	var fields []string
+	depth, start := 0, -1
+	for i, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n'):
+			if start >= 0 {
+				fields = append(fields, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, s[start:])
+	}
+	return fields
+}
+
+// listValues splits a comma-separated list value into its trimmed entries
+func listValues(value string) []string {
+	parts := splitOutsideParens(value, ',')
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// ParseTransitions parses the transition shorthand.
+// Example: "opacity 0.3s, transform 1s ease-in 0.5s"
+func ParseTransitions(value string) []Transition {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil
+	}
+	var transitions []Transition
+	for _, item := range listValues(strings.ToLower(value)) {
+		t := defaultTransition()
+		times := 0
+		for _, token := range fieldsOutsideParens(item) {
+			if d, ok := ParseTime(token); ok {
+				if times == 0 {
+					t.Duration = d
+				} else {
+					t.Delay = d
+				}
+				times++
+			} else if isTimingFunction(token) {
+				t.Timing = token
+			} else {
+				t.Property = token
+			}
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions
+}
+
+// ParseAnimations parses the animation shorthand.
+// Example: "spin 2s linear infinite, fade 1s ease-out 0.5s both"
+func ParseAnimations(value string) []Animation {
+	var animations []Animation
+	for _, item := range listValues(value) {
+		a := defaultAnimation()
+		times := 0
+		for _, token := range fieldsOutsideParens(item) {
+			lower := strings.ToLower(token)
+			if d, ok := ParseTime(lower); ok {
+				if times == 0 {
+					a.Duration = d
+				} else {
+					a.Delay = d
+				}
+				times++
+				continue
+			}
+			if isTimingFunction(lower) {
+				a.Timing = lower
+				continue
+			}
+			if n, ok := parseIterations(lower); ok {
+				a.Iterations = n
+				continue
+			}
+			switch lower {
+			case "normal", "reverse", "alternate", "alternate-reverse":
+				a.Direction = lower
+			case "forwards", "backwards", "both":
+				a.FillMode = lower
+			case "running", "paused":
+				a.Paused = lower == "paused"
+			case "none":
+				a.Name = ""
+			default:
+				a.Name = strings.Trim(token, `"'`)
+			}
+		}
+		animations = append(animations, a)
+	}
+	return animations
+}
+
+func parseIterations(value string) (float64, bool) {
+	if value == "infinite" {
+		return math.Inf(1), true
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// setTransitionLonghand applies one transition-* property. transition-property
+// decides how many transitions there are; the other lists repeat to fit.
+func setTransitionLonghand(list []Transition, property, value string) []Transition {
+	values := listValues(strings.ToLower(value))
+	if property == "transition-property" {
+		if value == "none" {
+			return nil
+		}
+		next := make([]Transition, len(values))
+		for i, v := range values {
+			next[i] = defaultTransition()
+			if len(list) > 0 {
+				next[i] = list[i%len(list)]
+			}
+			next[i].Property = v
+		}
+		return next
+	}
+	if len(list) == 0 {
+		list = []Transition{defaultTransition()}
+	} else {
+		list = append([]Transition(nil), list...)
+	}
+	for i := range list {
+		v := values[i%len(values)]
+		switch property {
+		case "transition-duration":
+			if d, ok := ParseTime(v); ok {
+				list[i].Duration = d
+			}
+		case "transition-delay":
+			if d, ok := ParseTime(v); ok {
+				list[i].Delay = d
+			}
+		case "transition-timing-function":
+			if isTimingFunction(v) {
+				list[i].Timing = v
+			}
+		}
+	}
+	return list
+}
+
+// setAnimationLonghand applies one animation-* property, in the same way
+// animation-name sets the number of animations
+func setAnimationLonghand(list []Animation, property, value string) []Animation {
+	values := listValues(value)
+	if property == "animation-name" {
+		next := make([]Animation, len(values))
+		for i, v := range values {
+			next[i] = defaultAnimation()
+			if len(list) > 0 {
+				next[i] = list[i%len(list)]
+			}
+			next[i].Name = strings.Trim(v, `"'`)
+			if v == "none" {
+				next[i].Name = ""
+			}
+		}
+		return next
+	}
+	if len(list) == 0 {
+		list = []Animation{defaultAnimation()}
+	} else {
+		list = append([]Animation(nil), list...)
+	}
+	for i := range list {
+		v := strings.ToLower(values[i%len(values)])
+		switch property {
+		case "animation-duration":
+			if d, ok := ParseTime(v); ok {
+				list[i].Duration = d
+			}
+		case "animation-delay":
+			if d, ok := ParseTime(v); ok {
+				list[i].Delay = d
+			}
+		case "animation-timing-function":
+			if isTimingFunction(v) {
+				list[i].Timing = v
+			}
+		case "animation-iteration-count":
+			if n, ok := parseIterations(v); ok {
+				list[i].Iterations = n
+			}
+		case "animation-direction":
+			list[i].Direction = v
+		case "animation-fill-mode":
+			list[i].FillMode = v
+		case "animation-play-state":
+			list[i].Paused = v == "paused"
+		}
+	}
+	return list
+}
+
+// ParseTransform parses a transform list. It reports false for values it
+// cannot parse, which leaves the declaration unapplied.
+// Example: "translateX(10px) rotate(45deg)" → [translate(10, 0) rotate(45)]
+func ParseTransform(value string, fontSize float64) ([]TransformFunc, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "none" {
+		return nil, true
+	}
+	var funcs []TransformFunc
+	for _, token := range fieldsOutsideParens(value) {
+		open := strings.Index(token, "(")
+		if open < 0 || !strings.HasSuffix(token, ")") {
+			return nil, false
+		}
+		name := token[:open]
+		args := listValues(token[open+1 : len(token)-1])
+		length := func(i int) float64 {
+			if i >= len(args) {
+				return 0
+			}
+			return ParseSizeWithContext(args[i], fontSize, DefaultViewportWidth, DefaultViewportHeight)
+		}
+		number := func(i int, fallback float64) float64 {
+			if i >= len(args) {
+				return fallback
+			}
+			n, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				return fallback
+			}
+			return n
+		}
+
+		switch name {
+		case "translate":
+			funcs = append(funcs, TransformFunc{Name: "translate", Args: []float64{length(0), length(1)}})
+		case "translatex":
+			funcs = append(funcs, TransformFunc{Name: "translate", Args: []float64{length(0), 0}})
+		case "translatey":
+			funcs = append(funcs, TransformFunc{Name: "translate", Args: []float64{0, length(0)}})
+		case "scale":
+			x := number(0, 1)
+			funcs = append(funcs, TransformFunc{Name: "scale", Args: []float64{x, number(1, x)}})
+		case "scalex":
+			funcs = append(funcs, TransformFunc{Name: "scale", Args: []float64{number(0, 1), 1}})
+		case "scaley":
+			funcs = append(funcs, TransformFunc{Name: "scale", Args: []float64{1, number(0, 1)}})
+		case "rotate", "rotatez":
+			deg, ok := parseAngle(args[0])
+			if !ok {
+				return nil, false
+			}
+			funcs = append(funcs, TransformFunc{Name: "rotate", Args: []float64{deg}})
+		default:
+			return nil, false
+		}
+	}
+	return funcs, true
+}
+
+// parseAngle converts an angle to degrees
+func parseAngle(value string) (float64, bool) {
+	units := []struct {
+		suffix string
+		deg    float64
+	}{
+		{"deg", 1},
+		{"grad", 0.9},
+		{"rad", 180 / math.Pi},
+		{"turn", 360},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(value, u.suffix), 64)
+			return n * u.deg, err == nil
+		}
+	}
+	return 0, value == "0"
+}
+
+// ApplyKeyframe applies the declarations of a keyframe on top of style
+func ApplyKeyframe(style *Style, frame Keyframe, viewportWidth, viewportHeight float64) {
+	for _, decl := range frame.Declarations {
+		applyDeclarationWithContext(style, strings.ToLower(decl.Property), decl.Value, style.FontSize, viewportWidth, viewportHeight)
+	}
+}
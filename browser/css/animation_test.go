@@ -0,0 +1,105 @@
+package css
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"1s", time.Second, true},
+		{"0.25s", 250 * time.Millisecond, true},
+		{"150ms", 150 * time.Millisecond, true},
+		{"-1s", -time.Second, true},
+		{"10px", 0, false},
+		{"fast", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, ok := ParseTime(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, d)
+		})
+	}
+}
+
+func TestParseTransitions(t *testing.T) {
+	assert.Equal(t, []Transition{
+		{Property: "opacity", Duration: 300 * time.Millisecond, Timing: "ease"},
+		{Property: "transform", Duration: time.Second, Delay: 500 * time.Millisecond, Timing: "cubic-bezier(0.1, 0.7, 1, 0.1)"},
+	}, ParseTransitions("opacity 0.3s, transform 1s cubic-bezier(0.1, 0.7, 1, 0.1) 0.5s"))
+
+	// A bare duration transitions every property
+	assert.Equal(t, []Transition{{Property: "all", Duration: time.Second, Timing: "ease"}}, ParseTransitions("1s"))
+	assert.Nil(t, ParseTransitions("none"))
+}
+
+func TestParseAnimations(t *testing.T) {
+	got := ParseAnimations("spin 2s linear infinite, fade 1s ease-out 0.5s 3 alternate both paused")
+	assert.Equal(t, []Animation{
+		{Name: "spin", Duration: 2 * time.Second, Timing: "linear", Iterations: math.Inf(1), Direction: "normal", FillMode: "none"},
+		{Name: "fade", Duration: time.Second, Delay: 500 * time.Millisecond, Timing: "ease-out", Iterations: 3, Direction: "alternate", FillMode: "both", Paused: true},
+	}, got)
+}
+
+func TestTransitionAndAnimationLonghands(t *testing.T) {
+	style := ParseInlineStyle("transition-property: opacity, color, width; transition-duration: 1s, 2s; transition-timing-function: linear")
+	assert.Equal(t, []Transition{
+		{Property: "opacity", Duration: time.Second, Timing: "linear"},
+		{Property: "color", Duration: 2 * time.Second, Timing: "linear"},
+		{Property: "width", Duration: time.Second, Timing: "linear"},
+	}, style.Transitions)
+
+	style = ParseInlineStyle("animation-name: slide; animation-duration: 3s; animation-iteration-count: infinite; animation-fill-mode: forwards")
+	assert.Equal(t, []Animation{
+		{Name: "slide", Duration: 3 * time.Second, Timing: "ease", Iterations: math.Inf(1), Direction: "normal", FillMode: "forwards"},
+	}, style.Animations)
+}
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TransformFunc
+		ok       bool
+	}{
+		{"none", nil, true},
+		{"translate(10px, 2em)", []TransformFunc{{Name: "translate", Args: []float64{10, 32}}}, true},
+		{"translateX(5px) translateY(6px)", []TransformFunc{
+			{Name: "translate", Args: []float64{5, 0}},
+			{Name: "translate", Args: []float64{0, 6}},
+		}, true},
+		{"scale(2)", []TransformFunc{{Name: "scale", Args: []float64{2, 2}}}, true},
+		{"scaleY(0.5)", []TransformFunc{{Name: "scale", Args: []float64{1, 0.5}}}, true},
+		{"rotate(0.25turn)", []TransformFunc{{Name: "rotate", Args: []float64{90}}}, true},
+		{"rotate(100grad)", []TransformFunc{{Name: "rotate", Args: []float64{90}}}, true},
+		{"skew(10deg)", nil, false},
+		{"rotate(fast)", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseTransform(tt.input, 16)
+			assert.Equal(t, tt.ok, ok)
+			if !tt.ok {
+				return
+			}
+			assert.Equal(t, len(tt.expected), len(got))
+			for i := range got {
+				assert.Equal(t, tt.expected[i].Name, got[i].Name)
+				assert.InDeltaSlice(t, tt.expected[i].Args, got[i].Args, 1e-9)
+			}
+		})
+	}
+
+	// An invalid transform leaves the previous value alone
+	style := ParseInlineStyle("transform: scale(2); transform: skew(5deg)")
+	assert.Equal(t, []TransformFunc{{Name: "scale", Args: []float64{2, 2}}}, style.Transform)
+}
@@ -0,0 +1,393 @@
+package css
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Length is a length that may be a percentage of a size only known when
+// painting, such as a background position
+type Length struct {
+	Value   float64 // pixels, or percent when Percent is set
+	Percent bool
+	Auto    bool
+}
+
+// Percent returns a percentage length
+func Percent(p float64) Length {
+	return Length{Value: p, Percent: true}
+}
+
+// Resolve returns the length in pixels, taking percentages of ref
+func (l Length) Resolve(ref float64) float64 {
+	if l.Percent {
+		return l.Value / 100 * ref
+	}
+	return l.Value
+}
+
+// parseLength parses a length or percentage; "auto" is accepted as Auto
+func parseLength(value string, fontSize float64) (Length, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "auto" {
+		return Length{Auto: true}, true
+	}
+	if strings.HasSuffix(value, "%") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return Percent(n), err == nil
+	}
+	if _, err := strconv.ParseFloat(strings.TrimRight(value, "pxemvwh"), 64); err != nil {
+		return Length{}, false
+	}
+	return Length{Value: ParseSizeWithContext(value, fontSize, DefaultViewportWidth, DefaultViewportHeight)}, true
+}
+
+// ColorStop is one color of a gradient, at Position (0 to 1) along it when
+// HasPosition is set; stops without one are spread evenly
+type ColorStop struct {
+	Color       color.Color
+	Position    float64
+	HasPosition bool
+}
+
+// Gradient is a linear-gradient() or radial-gradient() background
+type Gradient struct {
+	Radial bool
+	Stops  []ColorStop
+
+	// Linear gradients
+	Angle  float64 // degrees clockwise from pointing up ("to top")
+	Corner string  // "top right" etc. for "to <corner>", which overrides Angle
+
+	// Radial gradients, sized to reach the farthest corner
+	Circle           bool
+	CenterX, CenterY Length
+}
+
+// ParseGradient parses a linear-gradient() or radial-gradient() value.
+// Example: "linear-gradient(to right, red, blue 80%)"
+func ParseGradient(value string) (*Gradient, bool) {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	var g Gradient
+	var body string
+	switch {
+	case strings.HasPrefix(lower, "linear-gradient(") && strings.HasSuffix(lower, ")"):
+		body = value[len("linear-gradient(") : len(value)-1]
+		g.Angle = 180
+	case strings.HasPrefix(lower, "radial-gradient(") && strings.HasSuffix(lower, ")"):
+		body = value[len("radial-gradient(") : len(value)-1]
+		g.Radial = true
+		g.CenterX, g.CenterY = Percent(50), Percent(50)
+	default:
+		return nil, false
+	}
+
+	args := listValues(body)
+	if len(args) == 0 {
+		return nil, false
+	}
+	// The first argument is the direction or shape unless it is a color
+	if g.Radial {
+		if parseGradientShape(&g, args[0]) {
+			args = args[1:]
+		}
+	} else if parseGradientDirection(&g, args[0]) {
+		args = args[1:]
+	}
+
+	for _, arg := range args {
+		fields := fieldsOutsideParens(arg)
+		if len(fields) == 0 {
+			return nil, false
+		}
+		c := ParseColor(fields[0])
+		if c == nil {
+			return nil, false
+		}
+		stop := ColorStop{Color: c}
+		if len(fields) > 1 && strings.HasSuffix(fields[1], "%") {
+			if n, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64); err == nil {
+				stop.Position, stop.HasPosition = n/100, true
+			}
+		}
+		g.Stops = append(g.Stops, stop)
+	}
+	if len(g.Stops) < 2 {
+		return nil, false
+	}
+	return &g, true
+}
+
+// parseGradientDirection reads "to right", "to top left" or an angle
+func parseGradientDirection(g *Gradient, arg string) bool {
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	if deg, ok := parseAngle(arg); ok && arg != "0" {
+		g.Angle = deg
+		return true
+	}
+	if !strings.HasPrefix(arg, "to ") {
+		return false
+	}
+	var vertical, horizontal string
+	for _, side := range strings.Fields(arg[3:]) {
+		switch side {
+		case "top", "bottom":
+			vertical = side
+		case "left", "right":
+			horizontal = side
+		default:
+			return false
+		}
+	}
+	switch {
+	case vertical != "" && horizontal != "":
+		g.Corner = vertical + " " + horizontal
+	case vertical == "top":
+		g.Angle = 0
+	case vertical == "bottom":
+		g.Angle = 180
+	case horizontal == "right":
+		g.Angle = 90
+	case horizontal == "left":
+		g.Angle = 270
+	}
+	return true
+}
+
+// parseGradientShape reads "circle", "ellipse" and "at <position>"
+func parseGradientShape(g *Gradient, arg string) bool {
+	fields := strings.Fields(strings.ToLower(arg))
+	matched := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "circle":
+			g.Circle, matched = true, true
+		case "ellipse", "farthest-corner":
+			matched = true
+		case "at":
+			if pos, ok := parseBackgroundPosition(fields[i+1:], DefaultFontSize); ok {
+				g.CenterX, g.CenterY = pos.X, pos.Y
+			}
+			return true
+		}
+	}
+	return matched
+}
+
+// BackgroundSize is background-size: cover, contain, or a width and height
+// where Auto keeps the image's aspect ratio
+type BackgroundSize struct {
+	Keyword       string // "cover", "contain" or ""
+	Width, Height Length
+}
+
+// BackgroundPosition places the first background tile. Percentages align
+// that point of the tile with the same point of the box.
+type BackgroundPosition struct {
+	X, Y Length
+}
+
+// positionKeyword converts a position keyword to a percentage
+func positionKeyword(word string) (Length, bool) {
+	switch word {
+	case "left", "top":
+		return Percent(0), true
+	case "center":
+		return Percent(50), true
+	case "right", "bottom":
+		return Percent(100), true
+	}
+	return Length{}, false
+}
+
+// parseBackgroundPosition parses one or two position values.
+// Example: "right 20px" → {100%, 20px}, "bottom" → {50%, 100%}
+func parseBackgroundPosition(tokens []string, fontSize float64) (BackgroundPosition, bool) {
+	pos := BackgroundPosition{X: Percent(50), Y: Percent(50)}
+	parse := func(token string) (Length, bool) {
+		if l, ok := positionKeyword(token); ok {
+			return l, true
+		}
+		l, ok := parseLength(token, fontSize)
+		return l, ok && !l.Auto
+	}
+	switch len(tokens) {
+	case 1:
+		l, ok := parse(tokens[0])
+		if !ok {
+			return pos, false
+		}
+		if tokens[0] == "top" || tokens[0] == "bottom" {
+			pos.Y = l
+		} else {
+			pos.X = l
+		}
+	case 2:
+		first, second := tokens[0], tokens[1]
+		if first == "top" || first == "bottom" || second == "left" || second == "right" {
+			first, second = second, first
+		}
+		x, okX := parse(first)
+		y, okY := parse(second)
+		if !okX || !okY {
+			return pos, false
+		}
+		pos.X, pos.Y = x, y
+	default:
+		return pos, false
+	}
+	return pos, true
+}
+
+// parseBackgroundSize parses "cover", "contain" or one or two lengths
+func parseBackgroundSize(tokens []string, fontSize float64) (BackgroundSize, bool) {
+	if len(tokens) == 1 && (tokens[0] == "cover" || tokens[0] == "contain") {
+		return BackgroundSize{Keyword: tokens[0]}, true
+	}
+	size := BackgroundSize{Width: Length{Auto: true}, Height: Length{Auto: true}}
+	if len(tokens) == 0 || len(tokens) > 2 {
+		return size, false
+	}
+	var ok bool
+	if size.Width, ok = parseLength(tokens[0], fontSize); !ok {
+		return size, false
+	}
+	if len(tokens) == 2 {
+		if size.Height, ok = parseLength(tokens[1], fontSize); !ok {
+			return size, false
+		}
+	}
+	return size, true
+}
+
+func isBackgroundRepeat(token string) bool {
+	switch token {
+	case "repeat", "repeat-x", "repeat-y", "no-repeat", "space", "round":
+		return true
+	}
+	return false
+}
+
+func isPositionToken(token string) bool {
+	if _, ok := positionKeyword(token); ok {
+		return true
+	}
+	l, ok := parseLength(token, DefaultFontSize)
+	return ok && !l.Auto
+}
+
+// applyBackgroundLayer sets the gradient, repeat, position and size parts
+// of the background shorthand; color and url() are parseBackgroundShorthand's.
+// Example: "url(a.png) no-repeat center / cover"
+func applyBackgroundLayer(style *Style, value string) {
+	// Make "center/cover" two tokens around the slash
+	var spaced strings.Builder
+	depth := 0
+	for _, c := range value {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == '/' && depth == 0:
+			spaced.WriteString(" / ")
+			continue
+		}
+		spaced.WriteRune(c)
+	}
+
+	style.BackgroundGradient = nil
+	var position, size []string
+	afterSlash := false
+	for _, token := range fieldsOutsideParens(spaced.String()) {
+		lower := strings.ToLower(token)
+		switch {
+		case lower == "/":
+			afterSlash = true
+		case strings.Contains(lower, "-gradient("):
+			if g, ok := ParseGradient(token); ok {
+				style.BackgroundGradient = g
+				style.BackgroundImage = ""
+			}
+		case isBackgroundRepeat(lower):
+			style.BackgroundRepeat = lower
+		case afterSlash && (lower == "cover" || lower == "contain" || isPositionToken(lower) || lower == "auto"):
+			size = append(size, lower)
+		case !afterSlash && isPositionToken(lower):
+			position = append(position, lower)
+		}
+	}
+	if pos, ok := parseBackgroundPosition(position, style.FontSize); ok {
+		style.BackgroundPosition = pos
+	}
+	if s, ok := parseBackgroundSize(size, style.FontSize); ok {
+		style.BackgroundSize = s
+	}
+}
+
+// BoxShadow is one shadow of a box-shadow list. A nil Color means the
+// element's text color.
+type BoxShadow struct {
+	OffsetX, OffsetY float64
+	Blur             float64
+	Spread           float64
+	Color            color.Color
+	Inset            bool
+}
+
+// ParseBoxShadows parses a box-shadow list.
+// Example: "0 2px 4px rgba(0,0,0,0.5), inset 0 0 0 1px red"
+func ParseBoxShadows(value string, fontSize float64) []BoxShadow {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil
+	}
+	var shadows []BoxShadow
+	for _, item := range listValues(value) {
+		var shadow BoxShadow
+		var lengths []float64
+		for _, token := range fieldsOutsideParens(item) {
+			lower := strings.ToLower(token)
+			if lower == "inset" {
+				shadow.Inset = true
+			} else if l, ok := parseLength(lower, fontSize); ok && !l.Auto && !l.Percent {
+				lengths = append(lengths, l.Value)
+			} else if c := ParseColor(lower); c != nil {
+				shadow.Color = c
+			}
+		}
+		if len(lengths) < 2 {
+			continue
+		}
+		shadow.OffsetX, shadow.OffsetY = lengths[0], lengths[1]
+		if len(lengths) > 2 {
+			shadow.Blur = math.Max(0, lengths[2])
+		}
+		if len(lengths) > 3 {
+			shadow.Spread = lengths[3]
+		}
+		shadows = append(shadows, shadow)
+	}
+	return shadows
+}
+
+// parseBorderRadius reads the first radius of a border-radius value, in
+// pixels or as a percentage of the box
+func parseBorderRadius(style *Style, value string) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return
+	}
+	l, ok := parseLength(fields[0], style.FontSize)
+	if !ok || l.Auto {
+		return
+	}
+	style.BorderRadius, style.BorderRadiusPercent = 0, 0
+	if l.Percent {
+		style.BorderRadiusPercent = l.Value
+	} else {
+		style.BorderRadius = l.Value
+	}
+}
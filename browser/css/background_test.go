@@ -0,0 +1,93 @@
+package css
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGradient(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	g, ok := ParseGradient("linear-gradient(red, blue)")
+	require.True(t, ok)
+	assert.False(t, g.Radial)
+	assert.Equal(t, 180.0, g.Angle, "the default runs to the bottom")
+	require.Len(t, g.Stops, 2)
+	assert.True(t, colorsEqual(red, g.Stops[0].Color))
+	assert.True(t, colorsEqual(blue, g.Stops[1].Color))
+
+	g, ok = ParseGradient("linear-gradient(to right, red, rgba(0, 0, 255, 0.5) 80%)")
+	require.True(t, ok)
+	assert.Equal(t, 90.0, g.Angle)
+	assert.True(t, g.Stops[1].HasPosition)
+	assert.InDelta(t, 0.8, g.Stops[1].Position, 1e-9)
+
+	g, ok = ParseGradient("linear-gradient(45deg, red, blue)")
+	require.True(t, ok)
+	assert.Equal(t, 45.0, g.Angle)
+
+	g, ok = ParseGradient("linear-gradient(to top left, red, blue)")
+	require.True(t, ok)
+	assert.Equal(t, "top left", g.Corner)
+
+	g, ok = ParseGradient("radial-gradient(circle at top left, red, blue)")
+	require.True(t, ok)
+	assert.True(t, g.Radial)
+	assert.True(t, g.Circle)
+	assert.Equal(t, Percent(0), g.CenterX)
+	assert.Equal(t, Percent(0), g.CenterY)
+
+	g, ok = ParseGradient("radial-gradient(red, blue)")
+	require.True(t, ok)
+	assert.Equal(t, Percent(50), g.CenterX)
+
+	_, ok = ParseGradient("linear-gradient(red)")
+	assert.False(t, ok, "a gradient needs two stops")
+	_, ok = ParseGradient("url(a.png)")
+	assert.False(t, ok)
+}
+
+func TestParseBoxShadows(t *testing.T) {
+	shadows := ParseBoxShadows("2px 4px 6px rgba(0, 0, 0, 0.5), inset 0 0 0 1px red", DefaultFontSize)
+	require.Len(t, shadows, 2)
+	assert.Equal(t, 2.0, shadows[0].OffsetX)
+	assert.Equal(t, 4.0, shadows[0].OffsetY)
+	assert.Equal(t, 6.0, shadows[0].Blur)
+	assert.False(t, shadows[0].Inset)
+	assert.NotNil(t, shadows[0].Color)
+
+	assert.True(t, shadows[1].Inset)
+	assert.Equal(t, 1.0, shadows[1].Spread)
+	assert.True(t, colorsEqual(color.RGBA{255, 0, 0, 255}, shadows[1].Color))
+
+	assert.Nil(t, ParseBoxShadows("none", DefaultFontSize))
+	assert.Empty(t, ParseBoxShadows("1em", DefaultFontSize), "needs two offsets")
+}
+
+func TestBackgroundProperties(t *testing.T) {
+	style := ParseInlineStyle("background-image: linear-gradient(red, blue); background-repeat: no-repeat; background-size: 50% auto; background-position: right 10px")
+	require.NotNil(t, style.BackgroundGradient)
+	assert.Equal(t, "no-repeat", style.BackgroundRepeat)
+	assert.Equal(t, BackgroundSize{Width: Percent(50), Height: Length{Auto: true}}, style.BackgroundSize)
+	assert.Equal(t, BackgroundPosition{X: Percent(100), Y: Length{Value: 10}}, style.BackgroundPosition)
+
+	style = ParseInlineStyle("background: url(a.png) repeat-x bottom / cover")
+	assert.Equal(t, "a.png", style.BackgroundImage)
+	assert.Equal(t, "repeat-x", style.BackgroundRepeat)
+	assert.Equal(t, BackgroundPosition{X: Percent(50), Y: Percent(100)}, style.BackgroundPosition)
+	assert.Equal(t, "cover", style.BackgroundSize.Keyword)
+
+	style = ParseInlineStyle("background: #fff radial-gradient(circle, red, blue)")
+	assert.True(t, colorsEqual(color.RGBA{255, 255, 255, 255}, style.BackgroundColor))
+	require.NotNil(t, style.BackgroundGradient)
+	assert.True(t, style.BackgroundGradient.Circle)
+
+	style = ParseInlineStyle("border-radius: 50%")
+	assert.Equal(t, 50.0, style.BorderRadiusPercent)
+	assert.Zero(t, style.BorderRadius)
+	style = ParseInlineStyle("border-radius: 8px 4px")
+	assert.Equal(t, 8.0, style.BorderRadius)
+}
@@ -2,6 +2,7 @@ package css
 
 import (
 	"image/color"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -65,6 +66,21 @@ type Style struct {
 	BorderBottomStyle string
 	BorderLeftStyle   string
 	BorderRadius      float64
+	// BorderRadiusPercent is a radius as a percentage of the box, used
+	// instead of BorderRadius when set
+	BorderRadiusPercent float64
+
+	// Backgrounds beyond a color and an image URL
+	BackgroundGradient *Gradient
+	BackgroundRepeat   string // "repeat" when empty
+	BackgroundSize     BackgroundSize
+	BackgroundPosition BackgroundPosition
+	BoxShadows         []BoxShadow
+
+	// Transforms, transitions and animations
+	Transform   []TransformFunc
+	Transitions []Transition
+	Animations  []Animation
 }
 
 func DefaultStyle() Style {
@@ -274,6 +290,15 @@ func ParseColor(value string) color.Color {
 		return c
 	}
 
+	if value == "transparent" {
+		return color.NRGBA{0, 0, 0, 0}
+	}
+
+	// Functional notation: rgb(r, g, b) or rgba(r, g, b, a)
+	if strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba(") {
+		return parseRGBFunction(value)
+	}
+
 	// Hex color: #RGB or #RRGGBB
 	if strings.HasPrefix(value, "#") {
 		hex := value[1:]
@@ -292,6 +317,55 @@ func ParseColor(value string) color.Color {
 	return nil
 }
 
+// parseRGBFunction parses rgb()/rgba() with 0-255 or percentage channels
+// and an optional 0-1 alpha
+func parseRGBFunction(value string) color.Color {
+	open := strings.Index(value, "(")
+	if open < 0 || !strings.HasSuffix(value, ")") {
+		return nil
+	}
+	args := strings.FieldsFunc(value[open+1:len(value)-1], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '/'
+	})
+	if len(args) != 3 && len(args) != 4 {
+		return nil
+	}
+
+	var channels [3]uint8
+	for i := 0; i < 3; i++ {
+		arg := args[i]
+		percent := strings.HasSuffix(arg, "%")
+		v, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil {
+			return nil
+		}
+		if percent {
+			v = v / 100 * 255
+		}
+		channels[i] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+	}
+
+	alpha := 1.0
+	if len(args) == 4 {
+		arg := args[3]
+		scale := 1.0
+		if strings.HasSuffix(arg, "%") {
+			arg = strings.TrimSuffix(arg, "%")
+			scale = 0.01
+		}
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil
+		}
+		alpha = math.Max(0, math.Min(1, v*scale))
+	}
+
+	if alpha == 1 {
+		return color.RGBA{channels[0], channels[1], channels[2], 255}
+	}
+	return color.NRGBA{channels[0], channels[1], channels[2], uint8(math.Round(alpha * 255))}
+}
+
 type Selector struct {
 	TagName string
 	ID      string
@@ -310,7 +384,9 @@ type Rule struct {
 }
 
 type Stylesheet struct {
-	Rules []Rule
+	Rules     []Rule
+	FontFaces []FontFace
+	Keyframes []Keyframes
 }
 
 // MatchSelector checks if a selector matches a DOM node
@@ -342,6 +418,21 @@ func MatchSelector(sel Selector, tagName string, id string, classes []string) bo
 	return true
 }
 
+// MatchingRules returns the rules of sheet with a selector matching the
+// element, in the order they are applied
+func MatchingRules(sheet Stylesheet, tagName string, id string, classes []string) []Rule {
+	var matched []Rule
+	for _, rule := range sheet.Rules {
+		for _, sel := range rule.Selectors {
+			if MatchSelector(sel, tagName, id, classes) {
+				matched = append(matched, rule)
+				break
+			}
+		}
+	}
+	return matched
+}
+
 // ApplyStylesheet applies matching rules from stylesheet to a base style
 func ApplyStylesheet(sheet Stylesheet, tagName string, id string, classes []string) Style {
 	style := DefaultStyle()
@@ -396,9 +487,28 @@ func applyDeclaration(style *Style, property, value string) {
 			url = strings.Trim(url, `"'`)
 			url = strings.TrimSpace(url)
 			style.BackgroundImage = url
+			style.BackgroundGradient = nil
+		} else if g, ok := ParseGradient(value); ok {
+			style.BackgroundGradient = g
+			style.BackgroundImage = ""
 		} else if value == "none" {
 			style.BackgroundImage = ""
+			style.BackgroundGradient = nil
 		}
+	case "background-repeat":
+		if isBackgroundRepeat(strings.ToLower(value)) {
+			style.BackgroundRepeat = strings.ToLower(value)
+		}
+	case "background-size":
+		if size, ok := parseBackgroundSize(strings.Fields(strings.ToLower(value)), style.FontSize); ok {
+			style.BackgroundSize = size
+		}
+	case "background-position":
+		if pos, ok := parseBackgroundPosition(strings.Fields(strings.ToLower(value)), style.FontSize); ok {
+			style.BackgroundPosition = pos
+		}
+	case "box-shadow":
+		style.BoxShadows = ParseBoxShadows(value, style.FontSize)
 	case "background":
 		bgColor, bgImage := parseBackgroundShorthand(value)
 		if bgColor != nil {
@@ -408,6 +518,7 @@ func applyDeclaration(style *Style, property, value string) {
 		if bgImage != "" {
 			style.BackgroundImage = bgImage
 		}
+		applyBackgroundLayer(style, value)
 
 	case "font-size":
 		if size := ParseSize(value); size > 0 {
@@ -416,7 +527,7 @@ func applyDeclaration(style *Style, property, value string) {
 	case "line-height":
 		style.LineHeight = parseLineHeight(value, style.FontSize)
 	case "font-weight":
-		style.Bold = (value == "bold")
+		style.Bold = ParseFontWeight(value) >= 600
 	case "font-style":
 		style.Italic = (value == "italic")
 	case "font-family":
@@ -562,7 +673,20 @@ func applyDeclaration(style *Style, property, value string) {
 			style.MaxHeight = h
 		}
 	case "border-radius":
-		style.BorderRadius = ParseSize(value)
+		parseBorderRadius(style, value)
+	case "transform":
+		if funcs, ok := ParseTransform(value, style.FontSize); ok {
+			style.Transform = funcs
+		}
+	case "transition":
+		style.Transitions = ParseTransitions(value)
+	case "transition-property", "transition-duration", "transition-delay", "transition-timing-function":
+		style.Transitions = setTransitionLonghand(style.Transitions, property, value)
+	case "animation":
+		style.Animations = ParseAnimations(value)
+	case "animation-name", "animation-duration", "animation-delay", "animation-timing-function",
+		"animation-iteration-count", "animation-direction", "animation-fill-mode", "animation-play-state":
+		style.Animations = setAnimationLonghand(style.Animations, property, value)
 	}
 }
 
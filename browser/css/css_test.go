@@ -50,6 +50,17 @@ func TestParseColor(t *testing.T) {
 		{"named uppercase", "RED", color.RGBA{255, 0, 0, 255}},
 		{"named mixed case", "Blue", color.RGBA{0, 0, 255, 255}},
 
+		// Functional notation
+		{"rgb", "rgb(10, 20, 30)", color.RGBA{10, 20, 30, 255}},
+		{"rgb spaces", "rgb(10 20 30)", color.RGBA{10, 20, 30, 255}},
+		{"rgb percent", "rgb(100%, 0%, 50%)", color.RGBA{255, 0, 128, 255}},
+		{"rgba", "rgba(255, 0, 0, 0.5)", color.NRGBA{255, 0, 0, 128}},
+		{"rgba opaque", "rgba(1, 2, 3, 1)", color.RGBA{1, 2, 3, 255}},
+		{"rgb clamps", "rgb(300, -5, 0)", color.RGBA{255, 0, 0, 255}},
+		{"transparent", "transparent", color.NRGBA{0, 0, 0, 0}},
+		{"rgb missing channel", "rgb(1, 2)", nil},
+		{"rgb bad number", "rgb(a, 2, 3)", nil},
+
 		// Invalid colors
 		{"invalid color name", "notacolor", nil},
 		{"empty string", "", nil},
@@ -205,6 +216,20 @@ func TestMatchSelector(t *testing.T) {
 	}
 }
 
+func TestMatchingRules(t *testing.T) {
+	sheet := Parse(`p { color: red } .note, #main { margin: 4px !important } div p { color: blue }`)
+	rules := MatchingRules(sheet, "p", "main", []string{"intro"})
+	var printed []string
+	for _, rule := range rules {
+		printed = append(printed, rule.String())
+	}
+	assert.Equal(t, []string{
+		"p { color: red; }",
+		".note, #main { margin: 4px !important; }",
+	}, printed)
+	assert.Empty(t, MatchingRules(sheet, "span", "", nil))
+}
+
 func TestParseInlineStyle(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,116 @@
+package css
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FontFace is an @font-face rule
+type FontFace struct {
+	Family  string
+	Sources []FontSource
+	Weight  int // 100-900
+	Italic  bool
+}
+
+// FontSource is one url() of an @font-face src list
+type FontSource struct {
+	URL    string
+	Format string // from format(), empty if not given
+}
+
+// parseFontFace builds a FontFace from the declarations of an @font-face block
+func parseFontFace(decls []Declaration) FontFace {
+	face := FontFace{Weight: 400}
+	for _, d := range decls {
+		switch strings.ToLower(d.Property) {
+		case "font-family":
+			if families := ParseFontFamily(d.Value); len(families) > 0 {
+				face.Family = families[0]
+			}
+		case "src":
+			face.Sources = ParseFontSources(d.Value)
+		case "font-weight":
+			// A range such as "100 900" covers every weight, keep its start
+			if fields := strings.Fields(d.Value); len(fields) > 0 {
+				face.Weight = ParseFontWeight(fields[0])
+			}
+		case "font-style":
+			face.Italic = strings.HasPrefix(strings.ToLower(d.Value), "italic") ||
+				strings.HasPrefix(strings.ToLower(d.Value), "oblique")
+		}
+	}
+	return face
+}
+
+// ParseFontWeight converts a font-weight value to its numeric weight
+func ParseFontWeight(value string) int {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "normal", "":
+		return 400
+	case "bold", "bolder":
+		return 700
+	case "lighter":
+		return 300
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 1 && n <= 1000 {
+		return n
+	}
+	return 400
+}
+
+// ParseFontSources splits an @font-face src value into its url() entries.
+// local() entries are skipped, the browser has no installed-font lookup.
+// Example: url(a.woff2) format("woff2"), url('a.ttf') → [{a.woff2 woff2} {a.ttf }]
+func ParseFontSources(value string) []FontSource {
+	var sources []FontSource
+	for _, part := range splitOutsideParens(value, ',') {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(strings.ToLower(part), "url(") {
+			continue
+		}
+		end := strings.Index(part, ")")
+		if end < 0 {
+			continue
+		}
+		src := FontSource{URL: strings.Trim(strings.TrimSpace(part[4:end]), `"'`)}
+		rest := strings.ToLower(part[end+1:])
+		if i := strings.Index(rest, "format("); i >= 0 {
+			format := rest[i+len("format("):]
+			if j := strings.Index(format, ")"); j >= 0 {
+				src.Format = strings.Trim(strings.TrimSpace(format[:j]), `"'`)
+			}
+		}
+		if src.URL != "" {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// splitOutsideParens splits s at sep, ignoring separators inside
+// parentheses or quotes (as in data: URLs)
+func splitOutsideParens(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -17,15 +17,74 @@ func Parse(input string) Stylesheet {
 
 func (p *Parser) parseStylesheet() Stylesheet {
 	var rules []Rule
+	var fontFaces []FontFace
+	var keyframes []Keyframes
 	for p.pos < len(p.input) {
 		p.skipWhitespace()
 		if p.pos >= len(p.input) {
 			break
 		}
+		if strings.HasPrefix(strings.ToLower(p.input[p.pos:]), "@font-face") {
+			p.pos += len("@font-face")
+			p.skipWhitespace()
+			fontFaces = append(fontFaces, parseFontFace(p.parseDeclarations()))
+			continue
+		}
+		if kf, ok := p.parseKeyframes(); ok {
+			keyframes = append(keyframes, kf)
+			continue
+		}
 		rule := p.parseRule()
 		rules = append(rules, rule)
 	}
-	return Stylesheet{Rules: rules}
+	return Stylesheet{Rules: rules, FontFaces: fontFaces, Keyframes: keyframes}
+}
+
+// parseKeyframes parses a @keyframes rule if one starts at the current
+// position. Each selector block may list several offsets ("0%, 100%").
+func (p *Parser) parseKeyframes() (Keyframes, bool) {
+	rest := strings.ToLower(p.input[p.pos:])
+	var keyword string
+	for _, k := range []string{"@keyframes", "@-webkit-keyframes"} {
+		if strings.HasPrefix(rest, k) {
+			keyword = k
+		}
+	}
+	if keyword == "" {
+		return Keyframes{}, false
+	}
+	p.pos += len(keyword)
+	p.skipWhitespace()
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] != '{' {
+		p.pos++
+	}
+	kf := Keyframes{Name: strings.Trim(strings.TrimSpace(p.input[start:p.pos]), `"'`)}
+	if p.pos < len(p.input) {
+		p.pos++ // skip {
+	}
+
+	for p.pos < len(p.input) {
+		p.skipWhitespace()
+		if p.pos >= len(p.input) {
+			break
+		}
+		if p.input[p.pos] == '}' {
+			p.pos++
+			break
+		}
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] != '{' && p.input[p.pos] != '}' {
+			p.pos++
+		}
+		offsets := parseKeyframeSelector(p.input[start:p.pos])
+		decls := p.parseDeclarations()
+		for _, offset := range offsets {
+			kf.Frames = append(kf.Frames, Keyframe{Offset: offset, Declarations: decls})
+		}
+	}
+	sortKeyframes(kf.Frames)
+	return kf, true
 }
 
 func (p *Parser) parseRule() Rule {
@@ -132,9 +191,22 @@ func (p *Parser) parseIdentifier() string {
 
 func (p *Parser) parseValue() string {
 	start := p.pos
+	depth := 0
+	quote := byte(0)
 	for p.pos < len(p.input) {
 		c := p.input[p.pos]
-		if c == ';' || c == '}' {
+		// Semicolons inside url(...) or quotes (data: URLs) are part of the value
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+		} else if c == '"' || c == '\'' {
+			quote = c
+		} else if c == '(' {
+			depth++
+		} else if c == ')' && depth > 0 {
+			depth--
+		} else if depth == 0 && (c == ';' || c == '}') {
 			break
 		}
 		p.pos++
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) {
@@ -244,3 +245,88 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFontFace(t *testing.T) {
+	input := `@font-face {
+		font-family: "Open Sans";
+		src: url(/fonts/open.woff2) format("woff2"), local("Open Sans"), url('/fonts/open.ttf') format('truetype');
+		font-weight: 700;
+		font-style: italic;
+	}
+	p { font-family: "Open Sans", sans-serif; }
+	@font-face { font-family: Inline; src: url(data:font/ttf;base64,AAEAAA==); }`
+
+	sheet := Parse(input)
+	assert.Equal(t, []FontFace{
+		{
+			Family: "Open Sans",
+			Sources: []FontSource{
+				{URL: "/fonts/open.woff2", Format: "woff2"},
+				{URL: "/fonts/open.ttf", Format: "truetype"},
+			},
+			Weight: 700,
+			Italic: true,
+		},
+		{
+			Family:  "Inline",
+			Sources: []FontSource{{URL: "data:font/ttf;base64,AAEAAA=="}},
+			Weight:  400,
+		},
+	}, sheet.FontFaces)
+
+	// The ordinary rule between them is unaffected
+	assert.Len(t, sheet.Rules, 1)
+	assert.Equal(t, []Selector{{TagName: "p"}}, sheet.Rules[0].Selectors)
+}
+
+func TestParseFontWeight(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"normal", 400},
+		{"bold", 700},
+		{"600", 600},
+		{"lighter", 300},
+		{"huge", 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseFontWeight(tt.input))
+		})
+	}
+}
+
+func TestParseKeyframes(t *testing.T) {
+	input := `@keyframes fade {
+		from { opacity: 0; }
+		50%, 75% { opacity: 0.8; }
+		to { opacity: 1; transform: scale(1.5); }
+	}
+	div { animation: fade 1s; }
+	@-webkit-keyframes "spin" { to { transform: rotate(1turn); } 0% { transform: rotate(0); } }`
+
+	sheet := Parse(input)
+	require.Len(t, sheet.Keyframes, 2)
+
+	fade := sheet.FindKeyframes("fade")
+	require.NotNil(t, fade)
+	offsets := make([]float64, len(fade.Frames))
+	for i, frame := range fade.Frames {
+		offsets[i] = frame.Offset
+	}
+	assert.Equal(t, []float64{0, 0.5, 0.75, 1}, offsets)
+	assert.Equal(t, []Declaration{{Property: "opacity", Value: "0.8"}}, fade.Frames[1].Declarations)
+	assert.Len(t, fade.Frames[3].Declarations, 2)
+
+	// Frames are sorted by offset whatever order they were written in
+	spin := sheet.FindKeyframes("spin")
+	require.NotNil(t, spin)
+	require.Len(t, spin.Frames, 2)
+	assert.Equal(t, 0.0, spin.Frames[0].Offset)
+	assert.Nil(t, sheet.FindKeyframes("missing"))
+
+	assert.Len(t, sheet.Rules, 1)
+	assert.Equal(t, []Selector{{TagName: "div"}}, sheet.Rules[0].Selectors)
+}
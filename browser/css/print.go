@@ -1,31 +1,46 @@
 package css
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func (s Stylesheet) Print() {
 	for _, rule := range s.Rules {
-		for i, sel := range rule.Selectors {
-			if i > 0 {
-				fmt.Print(", ")
-			}
-
-			if sel.TagName != "" {
-				fmt.Print(sel.TagName)
-			}
+		fmt.Println(rule)
+	}
+}
 
-			if sel.ID != "" {
-				fmt.Print("#" + sel.ID)
-			}
+// String writes the selector as it appears in a stylesheet
+func (sel Selector) String() string {
+	var b strings.Builder
+	b.WriteString(sel.TagName)
+	if sel.ID != "" {
+		b.WriteString("#" + sel.ID)
+	}
+	for _, class := range sel.Classes {
+		b.WriteString("." + class)
+	}
+	return b.String()
+}
 
-			for _, class := range sel.Classes {
-				fmt.Print("." + class)
-			}
+// String writes the rule on one line: its selectors and declarations
+func (r Rule) String() string {
+	var b strings.Builder
+	for i, sel := range r.Selectors {
+		if i > 0 {
+			b.WriteString(", ")
 		}
-		fmt.Print(" {")
-
-		for _, decl := range rule.Declarations {
-			fmt.Printf(" %s: %s;", decl.Property, decl.Value)
+		b.WriteString(sel.String())
+	}
+	b.WriteString(" {")
+	for _, decl := range r.Declarations {
+		fmt.Fprintf(&b, " %s: %s", decl.Property, decl.Value)
+		if decl.Important {
+			b.WriteString(" !important")
 		}
-		fmt.Println(" }")
+		b.WriteString(";")
 	}
+	b.WriteString(" }")
+	return b.String()
 }
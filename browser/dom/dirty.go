@@ -0,0 +1,54 @@
+package dom
+
+// Dirty flags tell the layout engine which parts of the tree changed since
+// the layout tree was last built, so it can restyle only those subtrees.
+//
+// A dirty node needs its own style recomputed, which also restyles its
+// subtree through inheritance. A node with dirty children has kept its style
+// but something below it changed, including its list of children. Every
+// ancestor of a flagged node has dirty children.
+
+// MarkDirty flags a node whose attributes or content changed
+func (n *Node) MarkDirty() {
+	n.dirty = true
+	n.markAncestors()
+}
+
+// MarkChildrenDirty flags a node whose children were added, removed or replaced
+func (n *Node) MarkChildrenDirty() {
+	n.childrenDirty = true
+	n.markAncestors()
+}
+
+func (n *Node) markAncestors() {
+	// Ancestors of a flagged node are already flagged, so stop at the first one
+	for p := n.Parent; p != nil && !p.childrenDirty; p = p.Parent {
+		p.childrenDirty = true
+	}
+}
+
+// IsDirty reports whether the node itself needs restyling
+func (n *Node) IsDirty() bool {
+	return n.dirty
+}
+
+// HasDirtyChildren reports whether anything below the node changed
+func (n *Node) HasDirtyChildren() bool {
+	return n.childrenDirty
+}
+
+// ClearDirty resets the flags on n and everything below it, once the layout
+// tree reflects the changes
+func ClearDirty(n *Node) {
+	if n == nil {
+		return
+	}
+	n.dirty = false
+	if !n.childrenDirty {
+		return
+	}
+	n.childrenDirty = false
+	for _, child := range n.Children {
+		ClearDirty(child)
+	}
+}
@@ -6,6 +6,8 @@ const (
 	Document NodeType = iota
 	Element
 	Text
+	Comment
+	Doctype
 )
 
 type Node struct {
@@ -17,6 +19,18 @@ type Node struct {
 	Parent     *Node
 	Text       string
 	Disabled   bool
+
+	// CustomValidity is the message a script set with setCustomValidity;
+	// the form control is invalid while it is not empty
+	CustomValidity string
+
+	// Set on Document nodes only
+	Mode    DocumentMode // quirks mode chosen from the doctype
+	Charset string       // encoding the source bytes were decoded from
+
+	// Incremental relayout, see dirty.go
+	dirty         bool
+	childrenDirty bool
 }
 
 func NewElement(tagName string, tags map[string]string) *Node {
@@ -35,9 +49,44 @@ func NewText(text string) *Node {
 	}
 }
 
+func NewComment(text string) *Node {
+	return &Node{
+		Type: Comment,
+		Text: text,
+	}
+}
+
+// NewDoctype creates a doctype node; the public and system identifiers are
+// kept as "public" and "system" attributes when present
+func NewDoctype(name string, attrs map[string]string) *Node {
+	return &Node{
+		Type:       Doctype,
+		TagName:    name,
+		Attributes: attrs,
+	}
+}
+
+// OwnerDocument returns the Document node at the root of n's tree, or nil
+// for detached nodes
+func (n *Node) OwnerDocument() *Node {
+	for node := n; node != nil; node = node.Parent {
+		if node.Type == Document {
+			return node
+		}
+	}
+	return nil
+}
+
+// InQuirksMode reports whether n belongs to a document rendered in quirks mode
+func (n *Node) InQuirksMode() bool {
+	doc := n.OwnerDocument()
+	return doc != nil && doc.Mode == QuirksMode
+}
+
 func (n *Node) AppendChild(child *Node) {
 	child.Parent = n
 	n.Children = append(n.Children, child)
+	n.MarkChildrenDirty()
 }
 
 func (n *Node) RemoveChild(child *Node) {
@@ -45,6 +94,7 @@ func (n *Node) RemoveChild(child *Node) {
 		if c == child {
 			n.Children = append(n.Children[:i], n.Children[i+1:]...)
 			child.Parent = nil
+			n.MarkChildrenDirty()
 			return
 		}
 	}
@@ -0,0 +1,47 @@
+package dom
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// sniffSize is how many bytes the encoding prescan looks at
+const sniffSize = 1024
+
+var byteOrderMarks = [][]byte{
+	{0xEF, 0xBB, 0xBF}, // UTF-8
+	{0xFE, 0xFF},       // UTF-16BE
+	{0xFF, 0xFE},       // UTF-16LE
+}
+
+// DecodeReader wraps r so it yields UTF-8, following the HTML encoding
+// sniffing algorithm: a byte order mark wins, then the charset parameter of
+// contentType, then a <meta charset> in the first 1024 bytes. Without any of
+// those, valid UTF-8 is kept and anything else is read as windows-1252.
+// It returns the canonical name of the detected encoding.
+func DecodeReader(r io.Reader, contentType string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	preview, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	enc, name, _ := charset.DetermineEncoding(preview, contentType)
+
+	// The decoders leave a byte order mark in place; the parser would see it as text
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(preview, bom) {
+			br.Discard(len(bom))
+			break
+		}
+	}
+
+	if name == "utf-8" {
+		return br, name, nil
+	}
+	return transform.NewReader(br, enc.NewDecoder()), name, nil
+}
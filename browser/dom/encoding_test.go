@@ -0,0 +1,114 @@
+package dom
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func shiftJIS(t *testing.T, s string) []byte {
+	encoded, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(s))
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestDecodeReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		contentType string
+		wantText    string
+		wantCharset string
+	}{
+		{
+			name:        "content-type latin-1",
+			input:       []byte("<p>caf\xe9</p>"),
+			contentType: "text/html; charset=ISO-8859-1",
+			wantText:    "<p>café</p>",
+			wantCharset: "windows-1252",
+		},
+		{
+			name:        "meta charset windows-1252",
+			input:       []byte("<meta charset=\"windows-1252\"><p>\x93quoted\x94</p>"),
+			wantText:    "<meta charset=\"windows-1252\"><p>“quoted”</p>",
+			wantCharset: "windows-1252",
+		},
+		{
+			name:        "meta http-equiv shift_jis",
+			input:       append([]byte(`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"><p>`), shiftJIS(t, "日本語")...),
+			wantText:    `<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"><p>日本語`,
+			wantCharset: "shift_jis",
+		},
+		{
+			name:        "header wins over meta",
+			input:       []byte("<meta charset=\"shift_jis\"><p>caf\xe9</p>"),
+			contentType: "text/html; charset=latin1",
+			wantText:    "<meta charset=\"shift_jis\"><p>café</p>",
+			wantCharset: "windows-1252",
+		},
+		{
+			name:        "utf-8 bom wins and is stripped",
+			input:       []byte("\xef\xbb\xbf<p>héllo</p>"),
+			contentType: "text/html; charset=iso-8859-1",
+			wantText:    "<p>héllo</p>",
+			wantCharset: "utf-8",
+		},
+		{
+			name:        "utf-16le bom",
+			input:       []byte("\xff\xfe<\x00p\x00>\x00\xe9\x00"),
+			wantText:    "<p>é",
+			wantCharset: "utf-16le",
+		},
+		{
+			name:        "undeclared utf-8",
+			input:       []byte("<p>naïve</p>"),
+			wantText:    "<p>naïve</p>",
+			wantCharset: "utf-8",
+		},
+		{
+			name:        "undeclared legacy bytes",
+			input:       []byte("<p>caf\xe9</p>"),
+			wantText:    "<p>café</p>",
+			wantCharset: "windows-1252",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, charset, err := DecodeReader(bytes.NewReader(tt.input), tt.contentType)
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantText, string(decoded))
+			assert.Equal(t, tt.wantCharset, charset)
+		})
+	}
+}
+
+func TestParseWithContentType(t *testing.T) {
+	input := append([]byte(`<html><head><meta charset="shift_jis"><title>`), shiftJIS(t, "こんにちは")...)
+	input = append(input, []byte("</title></head><body></body></html>")...)
+
+	doc := ParseWithContentType(bytes.NewReader(input), "text/html")
+	require.NotNil(t, doc)
+
+	assert.Equal(t, "こんにちは", FindTitle(doc))
+	assert.Equal(t, "shift_jis", doc.Charset)
+}
+
+func TestParseLongDocumentWithoutDeclaration(t *testing.T) {
+	// The prescan only sees the first 1024 bytes; the rest must still be read
+	body := strings.Repeat("a", 3000) + "end"
+	doc := ParseWithContentType(strings.NewReader("<p>"+body+"</p>"), "text/html; charset=utf-8")
+	require.NotNil(t, doc)
+
+	p := FindElementsByTagName(doc, TagP)
+	require.NotNil(t, p)
+	assert.Equal(t, body, p.Children[0].Text)
+}
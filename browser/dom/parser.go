@@ -8,13 +8,49 @@ import (
 	"golang.org/x/net/html/atom"
 )
 
+// Namespace URIs for elements the HTML parser places outside the HTML namespace
+const (
+	NamespaceHTML   = "http://www.w3.org/1999/xhtml"
+	NamespaceSVG    = "http://www.w3.org/2000/svg"
+	NamespaceMathML = "http://www.w3.org/1998/Math/MathML"
+)
+
+// Parse parses an HTML document that is already UTF-8
 func Parse(r io.Reader) *Node {
 	doc, err := html.Parse(r)
 	if err != nil {
 		return nil
 	}
 
-	return convertNode(doc)
+	document := convertNode(doc)
+	document.Charset = "utf-8"
+	document.Mode = documentMode(document)
+	return document
+}
+
+// ParseWithContentType parses raw document bytes, detecting their encoding
+// from a byte order mark, the Content-Type header charset or a <meta> tag
+func ParseWithContentType(r io.Reader, contentType string) *Node {
+	decoded, charsetName, err := DecodeReader(r, contentType)
+	if err != nil {
+		return nil
+	}
+
+	document := Parse(decoded)
+	if document != nil {
+		document.Charset = charsetName
+	}
+	return document
+}
+
+// documentMode finds the doctype among the document's children
+func documentMode(document *Node) DocumentMode {
+	for _, child := range document.Children {
+		if child.Type == Doctype {
+			return DetermineMode(child)
+		}
+	}
+	return DetermineMode(nil)
 }
 
 func convertNode(n *html.Node) *Node {
@@ -36,10 +72,15 @@ func convertNodeWithContext(n *html.Node, preserveWhitespace bool) *Node {
 	case html.ElementNode:
 		attrs := make(map[string]string)
 		for _, attr := range n.Attr {
-			attrs[attr.Key] = attr.Val
+			key := attr.Key
+			if attr.Namespace != "" {
+				// Foreign attributes such as xlink:href keep their prefix
+				key = attr.Namespace + ":" + attr.Key
+			}
+			attrs[key] = attr.Val
 		}
 		node = NewElement(n.Data, attrs)
-		node.Namespace = "http://www.w3.org/1999/xhtml"
+		node.Namespace = namespaceURI(n.Namespace)
 	case html.TextNode:
 		var text string
 		if preserveWhitespace {
@@ -52,6 +93,14 @@ func convertNodeWithContext(n *html.Node, preserveWhitespace bool) *Node {
 			return nil
 		}
 		node = NewText(text)
+	case html.CommentNode:
+		node = NewComment(n.Data)
+	case html.DoctypeNode:
+		attrs := make(map[string]string)
+		for _, attr := range n.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+		node = NewDoctype(n.Data, attrs)
 	default:
 		return nil
 	}
@@ -66,6 +115,18 @@ func convertNodeWithContext(n *html.Node, preserveWhitespace bool) *Node {
 	return node
 }
 
+// namespaceURI maps the parser's short namespace names to URIs
+func namespaceURI(namespace string) string {
+	switch namespace {
+	case "svg":
+		return NamespaceSVG
+	case "math":
+		return NamespaceMathML
+	default:
+		return NamespaceHTML
+	}
+}
+
 // normalizeWhitespace collapses whitespace sequences to single spaces
 // while preserving space boundaries for inline element separation
 func normalizeWhitespace(s string) string {
@@ -125,4 +186,3 @@ func ParseFragment(htmlContent string) []*Node {
 	}
 	return result
 }
-
@@ -472,3 +472,31 @@ func TestParseFragment(t *testing.T) {
 		})
 	}
 }
+
+func TestParseKeepsCommentsAndDoctype(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "strict.dtd"><!-- top --><html><body><!-- inner --><p>x</p></body></html>`))
+
+	doctype := doc.Children[0]
+	assert.Equal(t, Doctype, doctype.Type)
+	assert.Equal(t, "html", doctype.TagName)
+	assert.Equal(t, "-//W3C//DTD XHTML 1.0 Strict//EN", doctype.Attributes["public"])
+	assert.Equal(t, "strict.dtd", doctype.Attributes["system"])
+
+	comment := doc.Children[1]
+	assert.Equal(t, Comment, comment.Type)
+	assert.Equal(t, " top ", comment.Text)
+
+	body := FindElementsByTagName(doc, TagBody)
+	assert.Equal(t, Comment, body.Children[0].Type)
+	assert.Equal(t, " inner ", body.Children[0].Text)
+	assert.Equal(t, "x", body.InnerText())
+}
+
+func TestParseNamespaces(t *testing.T) {
+	doc := Parse(strings.NewReader(`<body><svg><a xlink:href="#t"></a></svg><math><mi>x</mi></math></body>`))
+
+	assert.Equal(t, NamespaceHTML, FindElementsByTagName(doc, TagBody).Namespace)
+	assert.Equal(t, NamespaceSVG, FindElementsByTagName(doc, "svg").Namespace)
+	assert.Equal(t, "#t", FindElementsByTagName(doc, "a").Attributes["xlink:href"])
+	assert.Equal(t, NamespaceMathML, FindElementsByTagName(doc, "mi").Namespace)
+}
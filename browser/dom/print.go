@@ -15,6 +15,10 @@ func (n *Node) Print(indent int) {
 		fmt.Printf("%s<%s>\n", prefix, n.TagName)
 	case Text:
 		fmt.Printf("%s\"%s\"\n", prefix, n.Text)
+	case Comment:
+		fmt.Printf("%s<!-- %s -->\n", prefix, n.Text)
+	case Doctype:
+		fmt.Printf("%s<!DOCTYPE %s>\n", prefix, n.TagName)
 	}
 
 	for _, child := range n.Children {
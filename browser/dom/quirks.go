@@ -0,0 +1,148 @@
+package dom
+
+import "strings"
+
+// DocumentMode is the rendering mode a document is in, decided by its doctype
+// (https://html.spec.whatwg.org/multipage/parsing.html#the-initial-insertion-mode)
+type DocumentMode int
+
+const (
+	NoQuirksMode DocumentMode = iota
+	LimitedQuirksMode
+	QuirksMode
+)
+
+func (m DocumentMode) String() string {
+	switch m {
+	case QuirksMode:
+		return "quirks"
+	case LimitedQuirksMode:
+		return "limited-quirks"
+	default:
+		return "no-quirks"
+	}
+}
+
+// Public identifiers that put the document in quirks mode when matched exactly
+var quirksPublicIDs = []string{
+	"-//w3o//dtd w3 html strict 3.0//en//",
+	"-/w3c/dtd html 4.0 transitional/en",
+	"html",
+}
+
+// Public identifier prefixes that put the document in quirks mode
+var quirksPublicIDPrefixes = []string{
+	"+//silmaril//dtd html pro v0r11 19970101//",
+	"-//as//dtd html 3.0 aswedit + extensions//",
+	"-//advasoft ltd//dtd html 3.0 aswedit + extensions//",
+	"-//ietf//dtd html 2.0 level 1//",
+	"-//ietf//dtd html 2.0 level 2//",
+	"-//ietf//dtd html 2.0 strict level 1//",
+	"-//ietf//dtd html 2.0 strict level 2//",
+	"-//ietf//dtd html 2.0 strict//",
+	"-//ietf//dtd html 2.0//",
+	"-//ietf//dtd html 2.1e//",
+	"-//ietf//dtd html 3.0//",
+	"-//ietf//dtd html 3.2 final//",
+	"-//ietf//dtd html 3.2//",
+	"-//ietf//dtd html 3//",
+	"-//ietf//dtd html level 0//",
+	"-//ietf//dtd html level 1//",
+	"-//ietf//dtd html level 2//",
+	"-//ietf//dtd html level 3//",
+	"-//ietf//dtd html strict level 0//",
+	"-//ietf//dtd html strict level 1//",
+	"-//ietf//dtd html strict level 2//",
+	"-//ietf//dtd html strict level 3//",
+	"-//ietf//dtd html strict//",
+	"-//ietf//dtd html//",
+	"-//metrius//dtd metrius presentational//",
+	"-//microsoft//dtd internet explorer 2.0 html strict//",
+	"-//microsoft//dtd internet explorer 2.0 html//",
+	"-//microsoft//dtd internet explorer 2.0 tables//",
+	"-//microsoft//dtd internet explorer 3.0 html strict//",
+	"-//microsoft//dtd internet explorer 3.0 html//",
+	"-//microsoft//dtd internet explorer 3.0 tables//",
+	"-//netscape comm. corp.//dtd html//",
+	"-//netscape comm. corp.//dtd strict html//",
+	"-//o'reilly and associates//dtd html 2.0//",
+	"-//o'reilly and associates//dtd html extended 1.0//",
+	"-//o'reilly and associates//dtd html extended relaxed 1.0//",
+	"-//sq//dtd html 2.0 hotmetal + extensions//",
+	"-//softquad software//dtd hotmetal pro 6.0::19990601::extensions to html 4.0//",
+	"-//softquad//dtd hotmetal pro 4.0::19971010::extensions to html 4.0//",
+	"-//spyglass//dtd html 2.0 extended//",
+	"-//sun microsystems corp.//dtd hotjava html//",
+	"-//sun microsystems corp.//dtd hotjava strict html//",
+	"-//w3c//dtd html 3 1995-03-24//",
+	"-//w3c//dtd html 3.2 draft//",
+	"-//w3c//dtd html 3.2 final//",
+	"-//w3c//dtd html 3.2//",
+	"-//w3c//dtd html 3.2s draft//",
+	"-//w3c//dtd html 4.0 frameset//",
+	"-//w3c//dtd html 4.0 transitional//",
+	"-//w3c//dtd html experimental 19960712//",
+	"-//w3c//dtd html experimental 970421//",
+	"-//w3c//dtd w3 html//",
+	"-//w3o//dtd w3 html 3.0//",
+	"-//webtechs//dtd mozilla html 2.0//",
+	"-//webtechs//dtd mozilla html//",
+}
+
+// HTML 4.01 frameset/transitional: quirks without a system id, limited quirks with one
+var html401PublicIDPrefixes = []string{
+	"-//w3c//dtd html 4.01 frameset//",
+	"-//w3c//dtd html 4.01 transitional//",
+}
+
+var limitedQuirksPublicIDPrefixes = []string{
+	"-//w3c//dtd xhtml 1.0 frameset//",
+	"-//w3c//dtd xhtml 1.0 transitional//",
+}
+
+// DetermineMode returns the document mode for a doctype node. A document
+// without a doctype is in quirks mode.
+func DetermineMode(doctype *Node) DocumentMode {
+	if doctype == nil || doctype.Type != Doctype || doctype.TagName != "html" {
+		return QuirksMode
+	}
+
+	publicID, hasPublic := doctype.Attributes["public"]
+	systemID, hasSystem := doctype.Attributes["system"]
+	publicID = strings.ToLower(publicID)
+
+	if strings.EqualFold(systemID, "http://www.ibm.com/data/dtd/v11/ibmxhtml1-transitional.dtd") {
+		return QuirksMode
+	}
+	if !hasPublic {
+		return NoQuirksMode
+	}
+
+	for _, id := range quirksPublicIDs {
+		if publicID == id {
+			return QuirksMode
+		}
+	}
+	if hasPrefixAny(publicID, quirksPublicIDPrefixes) {
+		return QuirksMode
+	}
+	if hasPrefixAny(publicID, html401PublicIDPrefixes) {
+		if hasSystem {
+			return LimitedQuirksMode
+		}
+		return QuirksMode
+	}
+	if hasPrefixAny(publicID, limitedQuirksPublicIDPrefixes) {
+		return LimitedQuirksMode
+	}
+	return NoQuirksMode
+}
+
+func hasPrefixAny(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
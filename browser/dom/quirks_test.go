@@ -0,0 +1,44 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected DocumentMode
+	}{
+		{"html5 doctype", "<!DOCTYPE html><p>x", NoQuirksMode},
+		{"uppercase html5 doctype", "<!doctype HTML><p>x", NoQuirksMode},
+		{"no doctype", "<p>x", QuirksMode},
+		{"html 4.01 strict", `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01//EN" "http://www.w3.org/TR/html4/strict.dtd"><p>x`, NoQuirksMode},
+		{"html 4.01 transitional without system id", `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN"><p>x`, QuirksMode},
+		{"html 4.01 transitional with system id", `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN" "http://www.w3.org/TR/html4/loose.dtd"><p>x`, LimitedQuirksMode},
+		{"xhtml 1.0 transitional", `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd"><p>x`, LimitedQuirksMode},
+		{"xhtml 1.0 strict", `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><p>x`, NoQuirksMode},
+		{"html 3.2", `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN"><p>x`, QuirksMode},
+		{"ibm system id", `<!DOCTYPE html SYSTEM "http://www.ibm.com/data/dtd/v11/ibmxhtml1-transitional.dtd"><p>x`, QuirksMode},
+		{"other doctype name", "<!DOCTYPE svg><p>x", QuirksMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(strings.NewReader(tt.html))
+			assert.Equal(t, tt.expected, doc.Mode)
+		})
+	}
+}
+
+func TestInQuirksMode(t *testing.T) {
+	quirks := Parse(strings.NewReader("<p>x</p>"))
+	standards := Parse(strings.NewReader("<!DOCTYPE html><p>x</p>"))
+
+	assert.True(t, FindElementsByTagName(quirks, TagP).InQuirksMode())
+	assert.False(t, FindElementsByTagName(standards, TagP).InQuirksMode())
+	assert.False(t, NewElement("p", nil).InQuirksMode())
+}
@@ -38,7 +38,10 @@ const (
 	TagLI = "li"
 
 	// Media
-	TagImg = "img"
+	TagImg    = "img"
+	TagSVG    = "svg"
+	TagCanvas = "canvas"
+	TagIFrame = "iframe"
 
 	// Structure
 	TagHeader     = "header"
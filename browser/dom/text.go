@@ -26,6 +26,7 @@ func (n *Node) InnerText() string {
 
 func (n *Node) SetInnerText(text string) {
 	n.Children = []*Node{}
+	n.MarkChildrenDirty()
 
 	if text != "" {
 		texNode := NewText(text)
@@ -0,0 +1,276 @@
+package dom
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validity holds the constraints a form control's value fails, like the
+// ValidityState flags of the HTML spec
+type Validity struct {
+	ValueMissing    bool
+	TypeMismatch    bool
+	PatternMismatch bool
+	TooLong         bool
+	TooShort        bool
+	RangeUnderflow  bool
+	RangeOverflow   bool
+	StepMismatch    bool
+	BadInput        bool
+	CustomError     bool
+}
+
+// Valid reports whether no constraint failed
+func (v Validity) Valid() bool {
+	return v == Validity{}
+}
+
+// emailPattern is the valid e-mail address production of the HTML spec
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// dateLayout is the format of <input type="date"> values
+const dateLayout = "2006-01-02"
+
+// InputType returns the lowercased type of an <input>, "text" by default
+func InputType(node *Node) string {
+	if t := strings.ToLower(node.Attributes["type"]); t != "" {
+		return t
+	}
+	return "text"
+}
+
+// WillValidate reports whether node is a form control taking part in
+// constraint validation: not disabled, read-only, hidden or a button
+func WillValidate(node *Node) bool {
+	if node == nil || node.Type != Element {
+		return false
+	}
+	switch node.TagName {
+	case "input":
+		switch InputType(node) {
+		case "hidden", "submit", "reset", "button", "image":
+			return false
+		}
+	case "textarea", "select":
+	default:
+		return false
+	}
+	if _, ok := node.Attributes["readonly"]; ok && node.TagName != "select" {
+		return false
+	}
+	for n := node; n != nil; n = n.Parent {
+		if _, ok := n.Attributes["disabled"]; ok && (n == node || n.TagName == "fieldset") {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckValidity checks a form control against its constraints. value is
+// its current value; checked tells for a checkbox whether it is checked
+// and for a radio button whether any button of its group is.
+func CheckValidity(node *Node, value string, checked bool) Validity {
+	var v Validity
+	if !WillValidate(node) {
+		return v
+	}
+	v.CustomError = node.CustomValidity != ""
+	_, required := node.Attributes["required"]
+
+	if node.TagName == "input" {
+		switch InputType(node) {
+		case "checkbox", "radio":
+			v.ValueMissing = required && !checked
+			return v
+		}
+	}
+	if value == "" {
+		v.ValueMissing = required
+		return v
+	}
+
+	length := utf8.RuneCountInString(value)
+	if max, err := strconv.Atoi(node.Attributes["maxlength"]); err == nil && max >= 0 && length > max {
+		v.TooLong = true
+	}
+	if min, err := strconv.Atoi(node.Attributes["minlength"]); err == nil && length < min {
+		v.TooShort = true
+	}
+	if node.TagName != "input" {
+		return v
+	}
+
+	inputType := InputType(node)
+	switch inputType {
+	case "email":
+		v.TypeMismatch = !emailPattern.MatchString(value)
+	case "url":
+		u, err := url.Parse(value)
+		v.TypeMismatch = err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "")
+	case "number", "range", "date":
+		checkRange(node, inputType, value, &v)
+	}
+
+	if pattern, ok := node.Attributes["pattern"]; ok {
+		// The pattern must match the whole value; patterns that do not
+		// compile are ignored, as browsers do
+		if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil {
+			v.PatternMismatch = !re.MatchString(value)
+		}
+	}
+	return v
+}
+
+// checkRange applies min, max and step to numeric and date values
+func checkRange(node *Node, inputType, value string, v *Validity) {
+	n, ok := parseRangeValue(inputType, value)
+	if !ok {
+		v.BadInput = true
+		return
+	}
+	min, hasMin := parseRangeValue(inputType, node.Attributes["min"])
+	max, hasMax := parseRangeValue(inputType, node.Attributes["max"])
+	if hasMin && n < min {
+		v.RangeUnderflow = true
+	}
+	if hasMax && n > max {
+		v.RangeOverflow = true
+	}
+	if step, base, ok := rangeStep(node, inputType); ok {
+		steps := (n - base) / step
+		v.StepMismatch = math.Abs(steps-math.Round(steps)) > 1e-9
+	}
+}
+
+// rangeStep returns the step of a numeric or date control and the value
+// steps count from, or false for step="any"
+func rangeStep(node *Node, inputType string) (step, base float64, ok bool) {
+	step = 1
+	if s, has := node.Attributes["step"]; has {
+		if strings.EqualFold(s, "any") {
+			return 0, 0, false
+		}
+		if parsed, err := strconv.ParseFloat(s, 64); err == nil && parsed > 0 {
+			step = parsed
+		}
+	}
+	if inputType == "date" {
+		step *= 24 * 60 * 60 // date steps are days, values seconds
+	}
+	if min, ok := parseRangeValue(inputType, node.Attributes["min"]); ok {
+		base = min
+	}
+	return step, base, true
+}
+
+// parseRangeValue reads a number, or a date as seconds since the epoch
+func parseRangeValue(inputType, value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if inputType == "date" {
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return 0, false
+		}
+		return float64(t.Unix()), true
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, false
+	}
+	return n, true
+}
+
+// ValidationMessage is what the browser tells the user about the first
+// constraint v fails, or "" when it is valid
+func ValidationMessage(node *Node, value string, v Validity) string {
+	switch {
+	case v.CustomError:
+		return node.CustomValidity
+	case v.ValueMissing:
+		switch {
+		case node.TagName == "select":
+			return "Please select an item in the list."
+		case InputType(node) == "checkbox":
+			return "Please check this box if you want to proceed."
+		case InputType(node) == "radio":
+			return "Please select one of these options."
+		case InputType(node) == "file":
+			return "Please select a file."
+		}
+		return "Please fill out this field."
+	case v.TypeMismatch:
+		if InputType(node) == "email" {
+			return "Please enter an email address."
+		}
+		return "Please enter a URL."
+	case v.BadInput:
+		if InputType(node) == "date" {
+			return "Please enter a valid date."
+		}
+		return "Please enter a number."
+	case v.TooLong:
+		return fmt.Sprintf("Please shorten this text to %s characters or less (you are currently using %d characters).",
+			node.Attributes["maxlength"], utf8.RuneCountInString(value))
+	case v.TooShort:
+		return fmt.Sprintf("Please lengthen this text to %s characters or more (you are currently using %d characters).",
+			node.Attributes["minlength"], utf8.RuneCountInString(value))
+	case v.RangeUnderflow:
+		if InputType(node) == "date" {
+			return "Value must be " + node.Attributes["min"] + " or later."
+		}
+		return "Value must be greater than or equal to " + node.Attributes["min"] + "."
+	case v.RangeOverflow:
+		if InputType(node) == "date" {
+			return "Value must be " + node.Attributes["max"] + " or earlier."
+		}
+		return "Value must be less than or equal to " + node.Attributes["max"] + "."
+	case v.StepMismatch:
+		return stepMessage(node, value)
+	case v.PatternMismatch:
+		if title := node.Attributes["title"]; title != "" {
+			return "Please match the requested format: " + title
+		}
+		return "Please match the requested format."
+	}
+	return ""
+}
+
+// stepMessage names the two valid values nearest to a number off its step
+func stepMessage(node *Node, value string) string {
+	inputType := InputType(node)
+	step, base, ok := rangeStep(node, inputType)
+	n, valid := parseRangeValue(inputType, value)
+	if !ok || !valid || inputType == "date" {
+		return "Please enter a valid value."
+	}
+	below := base + math.Floor((n-base)/step)*step
+	format := func(f float64) string {
+		return strconv.FormatFloat(math.Round(f*1e9)/1e9, 'f', -1, 64)
+	}
+	return fmt.Sprintf("Please enter a valid value. The two nearest valid values are %s and %s.", format(below), format(below+step))
+}
+
+// FormControls returns the inputs, textareas and selects inside form, in
+// document order
+func FormControls(form *Node) []*Node {
+	var controls []*Node
+	for _, child := range form.Children {
+		if child.Type != Element {
+			continue
+		}
+		switch child.TagName {
+		case "input", "textarea", "select":
+			controls = append(controls, child)
+		}
+		controls = append(controls, FormControls(child)...)
+	}
+	return controls
+}
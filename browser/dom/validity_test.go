@@ -0,0 +1,80 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// control parses markup holding one form control and returns it
+func control(t *testing.T, html string) *Node {
+	t.Helper()
+	controls := FormControls(Parse(strings.NewReader("<form>" + html + "</form>")))
+	require.Len(t, controls, 1)
+	return controls[0]
+}
+
+func TestCheckValidity(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		value   string
+		checked bool
+		want    Validity
+		message string
+	}{
+		{"required empty", `<input required>`, "", false, Validity{ValueMissing: true}, "Please fill out this field."},
+		{"required filled", `<input required>`, "x", false, Validity{}, ""},
+		{"optional empty skips other checks", `<input type="email" minlength="5">`, "", false, Validity{}, ""},
+		{"required checkbox", `<input type="checkbox" required>`, "", false, Validity{ValueMissing: true}, "Please check this box if you want to proceed."},
+		{"checked checkbox", `<input type="checkbox" required>`, "", true, Validity{}, ""},
+		{"required select", `<select required></select>`, "", false, Validity{ValueMissing: true}, "Please select an item in the list."},
+		{"email", `<input type="email">`, "a@example.com", false, Validity{}, ""},
+		{"bad email", `<input type="email">`, "not an address", false, Validity{TypeMismatch: true}, "Please enter an email address."},
+		{"url", `<input type="url">`, "https://example.com/x", false, Validity{}, ""},
+		{"relative url", `<input type="url">`, "/x", false, Validity{TypeMismatch: true}, "Please enter a URL."},
+		{"pattern", `<input pattern="[0-9]{3}">`, "123", false, Validity{}, ""},
+		{"pattern matches whole value", `<input pattern="[0-9]{3}" title="Three digits">`, "1234", false, Validity{PatternMismatch: true}, "Please match the requested format: Three digits"},
+		{"broken pattern is ignored", `<input pattern="(">`, "x", false, Validity{}, ""},
+		{"too short", `<input minlength="3">`, "ab", false, Validity{TooShort: true}, "Please lengthen this text to 3 characters or more (you are currently using 2 characters)."},
+		{"too long", `<textarea maxlength="2"></textarea>`, "héé", false, Validity{TooLong: true}, "Please shorten this text to 2 characters or less (you are currently using 3 characters)."},
+		{"number", `<input type="number" min="1" max="10">`, "5", false, Validity{}, ""},
+		{"not a number", `<input type="number">`, "abc", false, Validity{BadInput: true}, "Please enter a number."},
+		{"underflow", `<input type="number" min="1">`, "0", false, Validity{RangeUnderflow: true}, "Value must be greater than or equal to 1."},
+		{"overflow", `<input type="number" max="10">`, "11", false, Validity{RangeOverflow: true}, "Value must be less than or equal to 10."},
+		{"step from min", `<input type="number" min="1" step="2">`, "4", false, Validity{StepMismatch: true}, "Please enter a valid value. The two nearest valid values are 3 and 5."},
+		{"decimal step", `<input type="number" step="0.1">`, "0.3", false, Validity{}, ""},
+		{"step any", `<input type="number" step="any">`, "0.123", false, Validity{}, ""},
+		{"integer step by default", `<input type="number">`, "1.5", false, Validity{StepMismatch: true}, "Please enter a valid value. The two nearest valid values are 1 and 2."},
+		{"date", `<input type="date" min="2024-01-01">`, "2024-02-29", false, Validity{}, ""},
+		{"early date", `<input type="date" min="2024-01-01">`, "2023-12-31", false, Validity{RangeUnderflow: true}, "Value must be 2024-01-01 or later."},
+		{"bad date", `<input type="date">`, "2024-13-01", false, Validity{BadInput: true}, "Please enter a valid date."},
+		{"disabled is not validated", `<input required disabled>`, "", false, Validity{}, ""},
+		{"readonly is not validated", `<input required readonly>`, "", false, Validity{}, ""},
+		{"disabled fieldset", `<fieldset disabled><input required></fieldset>`, "", false, Validity{}, ""},
+		{"hidden is not validated", `<input type="hidden" required>`, "", false, Validity{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := control(t, tt.html)
+			v := CheckValidity(node, tt.value, tt.checked)
+			assert.Equal(t, tt.want, v)
+			assert.Equal(t, tt.want == Validity{}, v.Valid())
+			assert.Equal(t, tt.message, ValidationMessage(node, tt.value, v))
+		})
+	}
+}
+
+func TestCustomValidity(t *testing.T) {
+	node := control(t, `<input type="email">`)
+	node.CustomValidity = "Taken"
+	v := CheckValidity(node, "a@example.com", false)
+	assert.Equal(t, Validity{CustomError: true}, v)
+	assert.Equal(t, "Taken", ValidationMessage(node, "a@example.com", v))
+
+	node.CustomValidity = ""
+	assert.True(t, CheckValidity(node, "a@example.com", false).Valid())
+}
@@ -0,0 +1,525 @@
+package fonts
+
+import "golang.org/x/text/unicode/bidi"
+
+// Direction is the base direction of a paragraph
+type Direction int
+
+const (
+	// DirAuto takes the direction from the first strong character (rules P2-P3)
+	DirAuto Direction = iota
+	DirLTR
+	DirRTL
+)
+
+// maxDepth is the deepest explicit embedding level (BD2)
+const maxDepth = 125
+
+// Levels resolves the embedding level of every rune of a paragraph following
+// the Unicode bidirectional algorithm (UAX #9): explicit embeddings,
+// overrides and isolates (X1-X10), weak types (W1-W7), neutrals (N1-N2),
+// implicit levels (I1-I2) and trailing whitespace (L1). Bracket pairs (N0)
+// are resolved like any other neutral. Odd levels are right-to-left.
+func Levels(text []rune, dir Direction) (levels []uint8, paragraph uint8) {
+	classes := make([]bidi.Class, len(text))
+	for i, r := range text {
+		p, _ := bidi.LookupRune(r)
+		classes[i] = p.Class()
+	}
+	matching := matchIsolates(classes)
+
+	switch dir {
+	case DirRTL:
+		paragraph = 1
+	case DirLTR:
+		paragraph = 0
+	default:
+		paragraph = firstStrongLevel(classes, matching, 0, len(classes), 0)
+	}
+
+	levels = make([]uint8, len(text))
+	types := append([]bidi.Class(nil), classes...)
+	removed := resolveExplicit(classes, types, levels, matching, paragraph)
+
+	for _, seq := range isolatingRunSequences(classes, types, levels, removed, matching, paragraph) {
+		seq.resolveWeak()
+		seq.resolveNeutral()
+		seq.resolveImplicit()
+	}
+
+	// Characters removed by X9 take the level of the character before them
+	prev := paragraph
+	for i := range levels {
+		if removed[i] {
+			levels[i] = prev
+		}
+		prev = levels[i]
+	}
+
+	resetWhitespace(classes, levels, paragraph)
+	return levels, paragraph
+}
+
+// IsRTL reports whether text contains characters that need bidi reordering
+func IsRTL(text string) bool {
+	for _, r := range text {
+		if r < 0x0590 {
+			continue
+		}
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.R, bidi.AL, bidi.AN, bidi.RLE, bidi.RLO, bidi.RLI:
+			return true
+		}
+	}
+	return false
+}
+
+// VisualOrder returns the indices of items at the given levels in display
+// order, reversing every run at or above each odd level (rule L2)
+func VisualOrder(levels []uint8) []int {
+	order := make([]int, len(levels))
+	var highest, lowestOdd uint8 = 0, maxDepth + 2
+	for i, l := range levels {
+		order[i] = i
+		highest = max(highest, l)
+		if l%2 == 1 {
+			lowestOdd = min(lowestOdd, l)
+		}
+	}
+	for level := highest; level >= lowestOdd && level > 0; level-- {
+		for i := 0; i < len(levels); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(levels) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+func isIsolateInitiator(c bidi.Class) bool {
+	return c == bidi.LRI || c == bidi.RLI || c == bidi.FSI
+}
+
+// isRemoved reports the classes dropped by rule X9
+func isRemoved(c bidi.Class) bool {
+	switch c {
+	case bidi.LRE, bidi.RLE, bidi.LRO, bidi.RLO, bidi.PDF, bidi.BN:
+		return true
+	}
+	return false
+}
+
+// matchIsolates maps every isolate initiator to its matching PDI (BD9), or
+// to -1 when the paragraph ends first
+func matchIsolates(classes []bidi.Class) map[int]int {
+	matching := make(map[int]int)
+	var open []int
+	for i, c := range classes {
+		switch {
+		case isIsolateInitiator(c):
+			open = append(open, i)
+			matching[i] = -1
+		case c == bidi.PDI && len(open) > 0:
+			matching[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		case c == bidi.B:
+			open = open[:0]
+		}
+	}
+	return matching
+}
+
+// firstStrongLevel finds the first L, R or AL in [start, end), skipping
+// isolates, and returns its level. Returns fallback if there is none.
+func firstStrongLevel(classes []bidi.Class, matching map[int]int, start, end int, fallback uint8) uint8 {
+	for i := start; i < end; i++ {
+		switch classes[i] {
+		case bidi.L:
+			return 0
+		case bidi.R, bidi.AL:
+			return 1
+		case bidi.LRI, bidi.RLI, bidi.FSI:
+			m := matching[i]
+			if m < 0 {
+				return fallback
+			}
+			i = m
+		case bidi.B:
+			return fallback
+		}
+	}
+	return fallback
+}
+
+type embedding struct {
+	level    uint8
+	override bidi.Class // L, R or ON for none
+	isolate  bool
+}
+
+// resolveExplicit applies rules X1-X8, filling levels and overriding types.
+// It returns which characters X9 removes.
+func resolveExplicit(classes, types []bidi.Class, levels []uint8, matching map[int]int, paragraph uint8) []bool {
+	removed := make([]bool, len(classes))
+	stack := []embedding{{level: paragraph, override: bidi.ON}}
+	overflowIsolates, overflowEmbeddings, validIsolates := 0, 0, 0
+
+	nextLevel := func(rtl bool) uint8 {
+		l := stack[len(stack)-1].level + 1
+		if rtl != (l%2 == 1) {
+			l++
+		}
+		return l
+	}
+	applyOverride := func(i int) {
+		top := stack[len(stack)-1]
+		levels[i] = top.level
+		if top.override != bidi.ON {
+			types[i] = top.override
+		}
+	}
+
+	for i, c := range classes {
+		switch c {
+		case bidi.RLE, bidi.LRE, bidi.RLO, bidi.LRO:
+			removed[i] = true
+			levels[i] = stack[len(stack)-1].level
+			l := nextLevel(c == bidi.RLE || c == bidi.RLO)
+			if l <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				e := embedding{level: l, override: bidi.ON}
+				if c == bidi.RLO {
+					e.override = bidi.R
+				} else if c == bidi.LRO {
+					e.override = bidi.L
+				}
+				stack = append(stack, e)
+			} else if overflowIsolates == 0 {
+				overflowEmbeddings++
+			}
+
+		case bidi.RLI, bidi.LRI, bidi.FSI:
+			applyOverride(i)
+			rtl := c == bidi.RLI
+			if c == bidi.FSI {
+				end := matching[i]
+				if end < 0 {
+					end = len(classes)
+				}
+				rtl = firstStrongLevel(classes, matching, i+1, end, 0) == 1
+			}
+			l := nextLevel(rtl)
+			if l <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				validIsolates++
+				stack = append(stack, embedding{level: l, override: bidi.ON, isolate: true})
+			} else {
+				overflowIsolates++
+			}
+
+		case bidi.PDI:
+			if overflowIsolates > 0 {
+				overflowIsolates--
+			} else if validIsolates > 0 {
+				overflowEmbeddings = 0
+				for !stack[len(stack)-1].isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolates--
+			}
+			applyOverride(i)
+
+		case bidi.PDF:
+			removed[i] = true
+			levels[i] = stack[len(stack)-1].level
+			switch {
+			case overflowIsolates > 0:
+			case overflowEmbeddings > 0:
+				overflowEmbeddings--
+			case !stack[len(stack)-1].isolate && len(stack) >= 2:
+				stack = stack[:len(stack)-1]
+			}
+
+		case bidi.B:
+			levels[i] = paragraph
+
+		case bidi.BN:
+			removed[i] = true
+			levels[i] = stack[len(stack)-1].level
+
+		default:
+			applyOverride(i)
+		}
+	}
+	return removed
+}
+
+// runSequence is one isolating run sequence (BD13): the indices it covers,
+// their working types (after overrides), and the types at its edges
+type runSequence struct {
+	indices  []int
+	types    []bidi.Class
+	levels   []uint8
+	level    uint8
+	sos, eos bidi.Class
+}
+
+func isolatingRunSequences(classes, types []bidi.Class, levels []uint8, removed []bool, matching map[int]int, paragraph uint8) []*runSequence {
+	// Level runs over the characters that survive X9
+	var runs [][]int
+	var current []int
+	for i := range classes {
+		if removed[i] {
+			continue
+		}
+		if len(current) > 0 && levels[current[len(current)-1]] != levels[i] {
+			runs = append(runs, current)
+			current = nil
+		}
+		current = append(current, i)
+	}
+	if len(current) > 0 {
+		runs = append(runs, current)
+	}
+
+	runStartingAt := make(map[int]int, len(runs))
+	for r, run := range runs {
+		runStartingAt[run[0]] = r
+	}
+	isMatchedPDI := make(map[int]bool)
+	for _, pdi := range matching {
+		if pdi >= 0 {
+			isMatchedPDI[pdi] = true
+		}
+	}
+
+	// levelAround returns the level of the nearest kept character in direction step
+	levelAround := func(i, step int) uint8 {
+		for j := i + step; j >= 0 && j < len(classes); j += step {
+			if !removed[j] {
+				return levels[j]
+			}
+		}
+		return paragraph
+	}
+	edge := func(a, b uint8) bidi.Class {
+		if max(a, b)%2 == 1 {
+			return bidi.R
+		}
+		return bidi.L
+	}
+
+	var sequences []*runSequence
+	for _, run := range runs {
+		if isMatchedPDI[run[0]] {
+			continue // continues the sequence of its isolate initiator
+		}
+		var indices []int
+		for {
+			indices = append(indices, run...)
+			last := run[len(run)-1]
+			pdi, ok := matching[last]
+			if !ok || pdi < 0 {
+				break
+			}
+			next, ok := runStartingAt[pdi]
+			if !ok {
+				break
+			}
+			run = runs[next]
+		}
+
+		seq := &runSequence{indices: indices, levels: levels, level: levels[indices[0]]}
+		seq.types = make([]bidi.Class, len(indices))
+		for k, i := range indices {
+			seq.types[k] = types[i]
+		}
+		first, last := indices[0], indices[len(indices)-1]
+		seq.sos = edge(seq.level, levelAround(first, -1))
+		if isIsolateInitiator(classes[last]) {
+			seq.eos = edge(seq.level, paragraph)
+		} else {
+			seq.eos = edge(seq.level, levelAround(last, 1))
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences
+}
+
+// resolveWeak applies rules W1-W7
+func (s *runSequence) resolveWeak() {
+	t := s.types
+
+	// W1: non-spacing marks take the type of what precedes them
+	prev := s.sos
+	for k, c := range t {
+		if c == bidi.NSM {
+			if isIsolateInitiator(prev) || prev == bidi.PDI {
+				t[k] = bidi.ON
+			} else {
+				t[k] = prev
+			}
+		}
+		prev = t[k]
+	}
+
+	// W2: European numbers after Arabic letters are Arabic numbers
+	lastStrong := s.sos
+	for k, c := range t {
+		switch c {
+		case bidi.L, bidi.R, bidi.AL:
+			lastStrong = c
+		case bidi.EN:
+			if lastStrong == bidi.AL {
+				t[k] = bidi.AN
+			}
+		}
+	}
+
+	// W3
+	for k, c := range t {
+		if c == bidi.AL {
+			t[k] = bidi.R
+		}
+	}
+
+	// W4: a single separator between two numbers of the same kind
+	for k := 1; k+1 < len(t); k++ {
+		switch {
+		case t[k] == bidi.ES && t[k-1] == bidi.EN && t[k+1] == bidi.EN:
+			t[k] = bidi.EN
+		case t[k] == bidi.CS && t[k-1] == bidi.EN && t[k+1] == bidi.EN:
+			t[k] = bidi.EN
+		case t[k] == bidi.CS && t[k-1] == bidi.AN && t[k+1] == bidi.AN:
+			t[k] = bidi.AN
+		}
+	}
+
+	// W5: terminators next to European numbers
+	for k := 0; k < len(t); k++ {
+		if t[k] != bidi.ET {
+			continue
+		}
+		end := k
+		for end < len(t) && t[end] == bidi.ET {
+			end++
+		}
+		if (k > 0 && t[k-1] == bidi.EN) || (end < len(t) && t[end] == bidi.EN) {
+			for j := k; j < end; j++ {
+				t[j] = bidi.EN
+			}
+		}
+		k = end
+	}
+
+	// W6
+	for k, c := range t {
+		if c == bidi.ES || c == bidi.ET || c == bidi.CS {
+			t[k] = bidi.ON
+		}
+	}
+
+	// W7: European numbers in left-to-right context
+	lastStrong = s.sos
+	for k, c := range t {
+		switch c {
+		case bidi.L, bidi.R:
+			lastStrong = c
+		case bidi.EN:
+			if lastStrong == bidi.L {
+				t[k] = bidi.L
+			}
+		}
+	}
+}
+
+func isNeutral(c bidi.Class) bool {
+	switch c {
+	case bidi.B, bidi.S, bidi.WS, bidi.ON, bidi.LRI, bidi.RLI, bidi.FSI, bidi.PDI:
+		return true
+	}
+	return false
+}
+
+// strongDirection treats numbers as right-to-left for rule N1
+func strongDirection(c bidi.Class) bidi.Class {
+	if c == bidi.EN || c == bidi.AN {
+		return bidi.R
+	}
+	return c
+}
+
+// resolveNeutral applies rules N1-N2
+func (s *runSequence) resolveNeutral() {
+	t := s.types
+	embeddingDir := bidi.L
+	if s.level%2 == 1 {
+		embeddingDir = bidi.R
+	}
+	for k := 0; k < len(t); k++ {
+		if !isNeutral(t[k]) {
+			continue
+		}
+		end := k
+		for end < len(t) && isNeutral(t[end]) {
+			end++
+		}
+		before, after := s.sos, s.eos
+		if k > 0 {
+			before = strongDirection(t[k-1])
+		}
+		if end < len(t) {
+			after = strongDirection(t[end])
+		}
+		dir := embeddingDir
+		if before == after {
+			dir = before
+		}
+		for j := k; j < end; j++ {
+			t[j] = dir
+		}
+		k = end
+	}
+}
+
+// resolveImplicit applies rules I1-I2
+func (s *runSequence) resolveImplicit() {
+	for k, i := range s.indices {
+		l := s.levels[i]
+		switch c := s.types[k]; {
+		case l%2 == 0 && c == bidi.R:
+			l++
+		case l%2 == 0 && (c == bidi.AN || c == bidi.EN):
+			l += 2
+		case l%2 == 1 && (c == bidi.L || c == bidi.EN || c == bidi.AN):
+			l++
+		}
+		s.levels[i] = l
+	}
+}
+
+// resetWhitespace applies rule L1: separators, and whitespace before them or
+// at the end of the line, go back to the paragraph level
+func resetWhitespace(classes []bidi.Class, levels []uint8, paragraph uint8) {
+	trailing := true
+	for i := len(classes) - 1; i >= 0; i-- {
+		switch c := classes[i]; {
+		case c == bidi.S || c == bidi.B:
+			levels[i] = paragraph
+			trailing = true
+		case trailing && (c == bidi.WS || isIsolateInitiator(c) || c == bidi.PDI || isRemoved(c)):
+			levels[i] = paragraph
+		default:
+			trailing = false
+		}
+	}
+}
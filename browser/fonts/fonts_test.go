@@ -0,0 +1,350 @@
+package fonts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/theme"
+	"github.com/go-text/typesetting/font"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		dir       Direction
+		paragraph uint8
+		expected  []uint8
+	}{
+		{"latin", "abc", DirAuto, 0, []uint8{0, 0, 0}},
+		{"hebrew", "אבג", DirAuto, 1, []uint8{1, 1, 1}},
+		{"hebrew in latin", "ab אב cd", DirAuto, 0, []uint8{0, 0, 0, 1, 1, 0, 0, 0}},
+		{"numbers in hebrew", "א 12", DirAuto, 1, []uint8{1, 1, 2, 2}},
+		{"arabic numbers stay right to left", "ب ١٢", DirAuto, 1, []uint8{1, 1, 2, 2}},
+		{"latin forced rtl", "ab", DirRTL, 1, []uint8{2, 2}},
+		{"trailing space goes to paragraph level", "אב ", DirLTR, 0, []uint8{1, 1, 0}},
+		{"right-to-left override", "a‮bc‬d", DirAuto, 0, []uint8{0, 0, 1, 1, 1, 0}},
+		{"isolate", "אב ⁦ab⁩ גד", DirAuto, 1, []uint8{1, 1, 1, 1, 2, 2, 1, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, paragraph := Levels([]rune(tt.text), tt.dir)
+			assert.Equal(t, tt.paragraph, paragraph)
+			assert.Equal(t, tt.expected, levels)
+		})
+	}
+}
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		levels   []uint8
+		expected []int
+	}{
+		{"all ltr", []uint8{0, 0, 0}, []int{0, 1, 2}},
+		{"all rtl", []uint8{1, 1, 1}, []int{2, 1, 0}},
+		{"rtl inside ltr", []uint8{0, 1, 1, 0}, []int{0, 2, 1, 3}},
+		{"numbers inside rtl", []uint8{1, 1, 2, 2}, []int{2, 3, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, VisualOrder(tt.levels))
+		})
+	}
+}
+
+func TestIsRTL(t *testing.T) {
+	assert.False(t, IsRTL("hello, world 123"))
+	assert.True(t, IsRTL("hello שלום"))
+	assert.True(t, IsRTL("مرحبا"))
+}
+
+func TestLoad(t *testing.T) {
+	face, err := Load(goregular.TTF)
+	require.NoError(t, err)
+	assert.NotNil(t, face)
+
+	// A truncated WOFF2 header
+	_, err = Load([]byte("wOF2\x00\x01\x00\x00"))
+	assert.Error(t, err)
+
+	_, err = Load([]byte("not a font"))
+	assert.Error(t, err)
+}
+
+func TestLoadWOFF2(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "open-sans-regular.woff2"))
+	require.NoError(t, err)
+	face, err := Load(data)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		ch      rune
+		advance float32
+		extents font.GlyphExtents
+	}{
+		{"simple glyph", 'A', 1296, font.GlyphExtents{XBearing: 0, YBearing: 1468, Width: 1296, Height: -1468}},
+		{"descender", 'g', 1122, font.GlyphExtents{XBearing: 39, YBearing: 1116, Width: 1034, Height: -1608}},
+		{"composite glyph", 'É', 1139, font.GlyphExtents{XBearing: 201, YBearing: 1907, Width: 815, Height: -1907}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gid, ok := face.NominalGlyph(tt.ch)
+			require.True(t, ok)
+			assert.Equal(t, tt.advance, face.HorizontalAdvance(gid))
+			extents, ok := face.GlyphExtents(gid)
+			require.True(t, ok)
+			assert.Equal(t, tt.extents, extents)
+
+			// The rebuilt outline spans the glyph's bounding box
+			outline, ok := face.GlyphData(gid).(font.GlyphOutline)
+			require.True(t, ok)
+			require.NotEmpty(t, outline.Segments)
+			minX, minY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+			maxX, maxY := -minX, -minY
+			for _, segment := range outline.Segments {
+				for _, p := range segment.ArgsSlice() {
+					minX, minY = min(minX, p.X), min(minY, p.Y)
+					maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+				}
+			}
+			assert.Equal(t, extents.XBearing, minX)
+			assert.Equal(t, extents.YBearing, maxY)
+			assert.Equal(t, extents.XBearing+extents.Width, maxX)
+			assert.Equal(t, extents.YBearing+extents.Height, minY)
+		})
+	}
+}
+
+func TestDecodeWOFF2(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "open-sans-regular.woff2"))
+	require.NoError(t, err)
+
+	sfnt, err := decodeWOFF2(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x00010000), binary.BigEndian.Uint32(sfnt))
+	// head's checksum adjustment makes the whole font sum to the magic number
+	assert.Equal(t, uint32(0xB1B0AFBA), checksum(sfnt))
+
+	_, err = decodeWOFF2(data[:len(data)/2])
+	assert.ErrorIs(t, err, errWOFF2Truncated)
+
+	corrupt := bytes.Clone(data)
+	for i := len(corrupt) - 200; i < len(corrupt); i++ {
+		corrupt[i] ^= 0xFF
+	}
+	_, err = decodeWOFF2(corrupt)
+	assert.Error(t, err)
+}
+
+func TestReconstructHmtx(t *testing.T) {
+	xMins := []int16{10, 20, 30}
+	u16s := func(values ...int) []byte {
+		var b []byte
+		for _, v := range values {
+			b = binary.BigEndian.AppendUint16(b, uint16(v))
+		}
+		return b
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		numHMetrics int
+		expected    []byte
+		expectError bool
+	}{
+		{
+			name:        "bearings kept",
+			data:        append([]byte{0}, u16s(500, 600, 1, 2, 3)...),
+			numHMetrics: 2,
+			expected:    u16s(500, 1, 600, 2, 3),
+		},
+		{
+			name:        "proportional bearings from xMin",
+			data:        append([]byte{1}, u16s(500, 600, 3)...),
+			numHMetrics: 2,
+			expected:    u16s(500, 10, 600, 20, 3),
+		},
+		{
+			name:        "monospaced bearings from xMin",
+			data:        append([]byte{2}, u16s(500, 600, 1, 2)...),
+			numHMetrics: 2,
+			expected:    u16s(500, 1, 600, 2, 30),
+		},
+		{
+			name:        "all bearings from xMin",
+			data:        append([]byte{3}, u16s(500)...),
+			numHMetrics: 1,
+			expected:    u16s(500, 10, 20, 30),
+		},
+		{
+			name:        "numberOfHMetrics out of range",
+			data:        []byte{3},
+			numHMetrics: 0,
+			expectError: true,
+		},
+		{
+			name:        "truncated",
+			data:        append([]byte{0}, u16s(500, 600)...),
+			numHMetrics: 2,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hmtx, err := reconstructHmtx(tt.data, tt.numHMetrics, xMins)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, hmtx)
+		})
+	}
+}
+
+func TestShapeWidth(t *testing.T) {
+	style := Style{Size: 16}
+	empty := Shape("", style)
+	assert.Zero(t, empty.Width)
+
+	one := Shape("a", style)
+	two := Shape("aa", style)
+	assert.Greater(t, one.Width, 0.0)
+	assert.InDelta(t, 2*one.Width, two.Width, 0.01)
+	assert.Greater(t, one.Ascent, 0.0)
+	assert.Greater(t, one.Descent, 0.0)
+
+	// Bigger text is wider
+	assert.Greater(t, Shape("a", Style{Size: 32}).Width, one.Width)
+}
+
+func TestShapeKerns(t *testing.T) {
+	// Noto Sans, bundled with the GUI toolkit, has a kerning table
+	noto, err := Load(theme.DefaultTextFont().Content())
+	require.NoError(t, err)
+	r := NewRegistry()
+	r.Register("Noto Sans", Descriptor{}, noto, "")
+
+	style := Style{Family: []string{"Noto Sans"}, Size: 32}
+	separate := r.Shape("A", style).Width + r.Shape("V", style).Width
+	assert.Less(t, r.Shape("AV", style).Width, separate)
+}
+
+func TestShapeOrdersRunsVisually(t *testing.T) {
+	line := Shape("abc אבג def", Style{Size: 16})
+	require.Len(t, line.Runs, 3)
+
+	// The Hebrew word sits between the Latin ones and is shaped right to left
+	assert.Equal(t, 0, line.Runs[0].Runes.Offset)
+	assert.Equal(t, 4, line.Runs[1].Runes.Offset)
+	assert.Equal(t, 7, line.Runs[2].Runes.Offset)
+	assert.Equal(t, uint8(1), line.Runs[1].Level)
+	assert.Less(t, line.Runs[0].X, line.Runs[1].X)
+	assert.Less(t, line.Runs[1].X, line.Runs[2].X)
+
+	// A right-to-left paragraph puts its first word on the right
+	rtl := Shape("אבג def", Style{Size: 16})
+	require.Len(t, rtl.Runs, 2)
+	assert.Equal(t, 4, rtl.Runs[0].Runes.Offset)
+	assert.Equal(t, 0, rtl.Runs[1].Runes.Offset)
+}
+
+func TestRegistryFamilies(t *testing.T) {
+	r := NewRegistry()
+	mono, err := Load(gomono.TTF)
+	require.NoError(t, err)
+
+	assert.False(t, r.HasWebFont([]string{"Fancy", "sans-serif"}))
+	r.Register(`"Fancy"`, Descriptor{Weight: 400}, mono, "https://example.com/fancy.ttf")
+	assert.True(t, r.Loaded("https://example.com/fancy.ttf"))
+	assert.True(t, r.HasWebFont([]string{"fancy", "sans-serif"}))
+	assert.False(t, r.HasWebFont([]string{"serif", "Fancy"}))
+
+	// The web font is monospaced, so both strings are as wide
+	style := Style{Family: []string{"Fancy"}, Size: 16}
+	assert.InDelta(t, r.Shape("iii", style).Width, r.Shape("mmm", style).Width, 0.01)
+
+	// Without it the default proportional face is used
+	plain := Style{Family: []string{"Unknown"}, Size: 16}
+	assert.Less(t, r.Shape("iii", plain).Width, r.Shape("mmm", plain).Width)
+
+	// Registering the same source again is ignored
+	r.Register("Other", Descriptor{}, mono, "https://example.com/fancy.ttf")
+	assert.False(t, r.HasWebFont([]string{"Other"}))
+}
+
+func TestRegistryFallsBackPerGlyph(t *testing.T) {
+	r := NewRegistry()
+	mono, err := Load(gomono.TTF)
+	require.NoError(t, err)
+	r.Register("Digits", Descriptor{}, mono, "")
+
+	faces := r.faceStack([]string{"Digits", "serif"}, Descriptor{})
+	assert.Same(t, mono, faces.ResolveFace('1'))
+	// Go Mono has no Hebrew, neither does any bundled face: the first wins
+	assert.Same(t, mono, faces.ResolveFace('א'))
+}
+
+func TestRegistryForDocument(t *testing.T) {
+	base := NewRegistry()
+	page := base.ForDocument()
+	other := base.ForDocument()
+	mono, err := Load(gomono.TTF)
+	require.NoError(t, err)
+	page.Register("Fancy", Descriptor{}, mono, "https://example.com/fancy.ttf")
+
+	assert.True(t, page.HasWebFont([]string{"Fancy"}))
+	assert.False(t, base.HasWebFont([]string{"Fancy"}))
+	assert.False(t, other.HasWebFont([]string{"Fancy"}))
+	assert.False(t, other.Loaded("https://example.com/fancy.ttf"))
+
+	// The bundled faces are shared
+	style := Style{Family: []string{"serif"}, Size: 16}
+	assert.Equal(t, base.Shape("hello", style).Width, other.Shape("hello", style).Width)
+	assert.Same(t, base.system, page.system)
+}
+
+func TestMatchPrefersStyleThenWeight(t *testing.T) {
+	regular, err := Load(goregular.TTF)
+	require.NoError(t, err)
+	mono, err := Load(gomono.TTF)
+	require.NoError(t, err)
+	entries := []entry{
+		{Descriptor{Weight: 400}, regular},
+		{Descriptor{Weight: 700, Italic: true}, mono},
+	}
+
+	assert.Same(t, regular, match(entries, Descriptor{Weight: 700}))
+	assert.Same(t, mono, match(entries, Descriptor{Weight: 400, Italic: true}))
+}
+
+func TestRasterize(t *testing.T) {
+	line := Shape("Hi", Style{Size: 20})
+	img := Rasterize(line, color.Black)
+	require.NotNil(t, img)
+
+	inked := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 {
+			inked++
+		}
+	}
+	assert.Greater(t, inked, 20)
+	assert.Nil(t, Rasterize(Line{}, color.Black))
+}
@@ -0,0 +1,284 @@
+package fonts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/fontscan"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Load parses a TrueType, OpenType, WOFF or WOFF2 font file
+func Load(data []byte) (*font.Face, error) {
+	if bytes.HasPrefix(data, []byte("wOF2")) {
+		sfnt, err := decodeWOFF2(data)
+		if err != nil {
+			return nil, err
+		}
+		data = sfnt
+	}
+	face, err := font.ParseTTF(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("fonts: %w", err)
+	}
+	return face, nil
+}
+
+// Descriptor is the part of an @font-face rule used to pick between faces
+// of one family
+type Descriptor struct {
+	Weight int // 100-900, 400 is normal
+	Italic bool
+}
+
+type entry struct {
+	Descriptor
+	face *font.Face
+}
+
+// Registry maps font-family names to faces. Generic families (serif,
+// sans-serif, monospace...) resolve to the bundled Go fonts, and runes no
+// family covers fall back to the system fonts when enabled.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string][]entry
+	sources  map[string]bool // font URLs already registered
+
+	defaults  map[Descriptor]*font.Face
+	monospace *font.Face
+	system    *systemFonts
+}
+
+// systemFonts is the installed font fallback, shared by a registry and the
+// document registries made from it
+type systemFonts struct {
+	mu   sync.Mutex
+	dir  string
+	once sync.Once
+	fm   *fontscan.FontMap
+}
+
+// Default has the bundled and system fonts but no web fonts. Each document
+// registers its @font-face rules in a registry of its own, see ForDocument.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		families: make(map[string][]entry),
+		sources:  make(map[string]bool),
+		system:   &systemFonts{},
+	}
+	must := func(data []byte) *font.Face {
+		face, err := Load(data)
+		if err != nil {
+			panic(err)
+		}
+		return face
+	}
+	r.defaults = map[Descriptor]*font.Face{
+		{Weight: 400}:               must(goregular.TTF),
+		{Weight: 700}:               must(gobold.TTF),
+		{Weight: 400, Italic: true}: must(goitalic.TTF),
+		{Weight: 700, Italic: true}: must(gobolditalic.TTF),
+	}
+	r.monospace = must(gomono.TTF)
+	return r
+}
+
+// ForDocument returns an empty registry for one document's web fonts that
+// shares r's bundled faces and system fonts, so a page's faces go away with
+// the page
+func (r *Registry) ForDocument() *Registry {
+	return &Registry{
+		families:  make(map[string][]entry),
+		sources:   make(map[string]bool),
+		defaults:  r.defaults,
+		monospace: r.monospace,
+		system:    r.system,
+	}
+}
+
+// normalizeFamily lower-cases a family name and drops its quotes
+func normalizeFamily(name string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(name), `"'`))
+}
+
+func isGeneric(family string) bool {
+	switch family {
+	case "serif", "sans-serif", "monospace", "cursive", "fantasy", "system-ui", "ui-sans-serif", "ui-serif", "ui-monospace":
+		return true
+	}
+	return false
+}
+
+// Register adds a face to a family. source identifies where it came from
+// (usually its URL) so loading the same @font-face twice is a no-op.
+func (r *Registry) Register(family string, desc Descriptor, face *font.Face, source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if source != "" {
+		if r.sources[source] {
+			return
+		}
+		r.sources[source] = true
+	}
+	if desc.Weight == 0 {
+		desc.Weight = 400
+	}
+	family = normalizeFamily(family)
+	r.families[family] = append(r.families[family], entry{Descriptor: desc, face: face})
+}
+
+// Add loads a font file and registers it under family
+func (r *Registry) Add(family string, desc Descriptor, data []byte, source string) error {
+	face, err := Load(data)
+	if err != nil {
+		return err
+	}
+	r.Register(family, desc, face, source)
+	return nil
+}
+
+// Loaded reports whether source has already been registered
+func (r *Registry) Loaded(source string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sources[source]
+}
+
+// HasWebFont reports whether the first family of the stack that can be
+// resolved is a registered web font rather than a generic or unknown one
+func (r *Registry) HasWebFont(stack []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range stack {
+		family := normalizeFamily(name)
+		if len(r.families[family]) > 0 {
+			return true
+		}
+		if isGeneric(family) {
+			return false
+		}
+	}
+	return false
+}
+
+// UseSystemFonts enables falling back to installed fonts for runes that no
+// family in the stack covers. The font index is built on first use and
+// cached in dir.
+func (r *Registry) UseSystemFonts(dir string) {
+	r.system.mu.Lock()
+	defer r.system.mu.Unlock()
+	r.system.dir = dir
+}
+
+// face finds an installed face covering ch
+func (s *systemFonts) face(ch rune) *font.Face {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		return nil
+	}
+	s.once.Do(func() {
+		fm := fontscan.NewFontMap(nil)
+		if err := fm.UseSystemFonts(s.dir); err != nil {
+			return
+		}
+		fm.SetQuery(fontscan.Query{Families: []string{fontscan.SansSerif}})
+		s.fm = fm
+	})
+	if s.fm == nil {
+		return nil
+	}
+	face := s.fm.ResolveFace(ch)
+	if face == nil {
+		return nil
+	}
+	if _, ok := face.NominalGlyph(ch); !ok {
+		return nil
+	}
+	return face
+}
+
+// match picks the face of a family closest to desc: the right style first,
+// then the nearest weight (CSS Fonts 4 §5.2, simplified)
+func match(entries []entry, desc Descriptor) *font.Face {
+	var best *font.Face
+	bestScore := -1
+	for _, e := range entries {
+		score := abs(e.Weight - desc.Weight)
+		if e.Italic != desc.Italic {
+			score += 1000
+		}
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = e.face, score
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// genericFace returns the bundled face for a generic family
+func (r *Registry) genericFace(family string, desc Descriptor) *font.Face {
+	if family == "monospace" || family == "ui-monospace" {
+		return r.monospace
+	}
+	key := Descriptor{Weight: 400, Italic: desc.Italic}
+	if desc.Weight >= 600 {
+		key.Weight = 700
+	}
+	return r.defaults[key]
+}
+
+// fontmap resolves runes against one family stack, falling back per glyph
+type fontmap struct {
+	r     *Registry
+	faces []*font.Face // the stack's faces, then the default
+}
+
+// faceStack returns the faces for a family stack in priority order. Runes
+// are resolved to the first face that has a glyph for them.
+func (r *Registry) faceStack(stack []string, desc Descriptor) *fontmap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if desc.Weight == 0 {
+		desc.Weight = 400
+	}
+	fm := &fontmap{r: r}
+	for _, name := range stack {
+		family := normalizeFamily(name)
+		if entries := r.families[family]; len(entries) > 0 {
+			fm.faces = append(fm.faces, match(entries, desc))
+		} else if isGeneric(family) {
+			fm.faces = append(fm.faces, r.genericFace(family, desc))
+		}
+	}
+	fm.faces = append(fm.faces, r.genericFace("sans-serif", desc))
+	return fm
+}
+
+// ResolveFace implements shaping.Fontmap
+func (fm *fontmap) ResolveFace(ch rune) *font.Face {
+	for _, face := range fm.faces {
+		if _, ok := face.NominalGlyph(ch); ok {
+			return face
+		}
+	}
+	if face := fm.r.system.face(ch); face != nil {
+		return face
+	}
+	return fm.faces[0]
+}
@@ -0,0 +1,231 @@
+package fonts
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"browser/graphics"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+)
+
+// Style selects the faces and size text is shaped with
+type Style struct {
+	Family    []string // CSS font-family stack, highest priority first
+	Size      float64  // in pixels
+	Bold      bool
+	Italic    bool
+	Direction Direction
+}
+
+func (s Style) descriptor() Descriptor {
+	d := Descriptor{Weight: 400, Italic: s.Italic}
+	if s.Bold {
+		d.Weight = 700
+	}
+	return d
+}
+
+// Run is a shaped stretch of text in one face, direction and script,
+// positioned X pixels from the start of its line
+type Run struct {
+	shaping.Output
+	X     float64
+	Level uint8
+}
+
+// Line is shaped text with its runs in visual (left to right) order
+type Line struct {
+	Runs    []Run
+	Width   float64
+	Ascent  float64
+	Descent float64
+}
+
+// NeedsShaping reports whether text in style has to go through Shape rather
+// than the GUI toolkit's own text: it uses a web font, or it has
+// right-to-left characters that need reordering
+func NeedsShaping(text string, family []string) bool {
+	return Default.NeedsShaping(text, family)
+}
+
+// NeedsShaping is the package NeedsShaping for r's web fonts
+func (r *Registry) NeedsShaping(text string, family []string) bool {
+	return r.HasWebFont(family) || IsRTL(text)
+}
+
+// shapers are pooled because a HarfbuzzShaper caches per-face state and is
+// not safe for concurrent use
+var shapers = sync.Pool{New: func() any { return &shaping.HarfbuzzShaper{} }}
+
+// Shape shapes text with kerning and ligatures using the Default registry
+func Shape(text string, style Style) Line {
+	return Default.Shape(text, style)
+}
+
+// Measure returns the advance width of text
+func Measure(text string, style Style) float64 {
+	return Default.Measure(text, style)
+}
+
+// Measure returns the advance width of text set in r's fonts
+func (r *Registry) Measure(text string, style Style) float64 {
+	return r.Shape(text, style).Width
+}
+
+// Shape splits text into runs of one embedding level, script and face,
+// shapes each, and orders them for display
+func (r *Registry) Shape(text string, style Style) Line {
+	runes := []rune(text)
+	if len(runes) == 0 || style.Size <= 0 {
+		return Line{}
+	}
+	levels, _ := Levels(runes, style.Direction)
+	faces := r.faceStack(style.Family, style.descriptor())
+	size := fixed.Int26_6(math.Round(style.Size * 64))
+
+	shaper := shapers.Get().(*shaping.HarfbuzzShaper)
+	defer shapers.Put(shaper)
+
+	var runs []Run
+	for _, item := range itemize(runes, levels) {
+		dir := di.DirectionLTR
+		if item.level%2 == 1 {
+			dir = di.DirectionRTL
+		}
+		input := shaping.Input{
+			Text:     runes,
+			RunStart: item.start,
+			RunEnd:   item.end,
+			Size:     size,
+			Script:   item.script,
+			Language: language.DefaultLanguage(),
+		}
+		for _, part := range shaping.SplitByFace(input, faces) {
+			part.Direction = dir
+			runs = append(runs, Run{Output: shaper.Shape(part), Level: item.level})
+		}
+	}
+
+	runLevels := make([]uint8, len(runs))
+	for i, run := range runs {
+		runLevels[i] = run.Level
+	}
+	line := Line{Runs: make([]Run, 0, len(runs))}
+	for _, i := range VisualOrder(runLevels) {
+		run := runs[i]
+		run.X = line.Width
+		line.Width += fixedToFloat(run.Advance)
+		line.Ascent = max(line.Ascent, fixedToFloat(run.LineBounds.Ascent))
+		line.Descent = max(line.Descent, -fixedToFloat(run.LineBounds.Descent))
+		line.Runs = append(line.Runs, run)
+	}
+	return line
+}
+
+type item struct {
+	start, end int
+	level      uint8
+	script     language.Script
+}
+
+// itemize splits runes where the embedding level or the script changes.
+// Common and inherited characters (spaces, punctuation, marks) join the
+// script of the text around them.
+func itemize(runes []rune, levels []uint8) []item {
+	var items []item
+	current := item{level: levels[0], script: language.Common}
+	for i, r := range runes {
+		script := language.LookupScript(r)
+		shared := script == language.Common || script == language.Inherited
+		switch {
+		case levels[i] != current.level:
+		case shared || current.script == language.Common || script == current.script:
+			if !shared && current.script == language.Common {
+				current.script = script
+			}
+			continue
+		}
+		current.end = i
+		items = append(items, current)
+		current = item{start: i, level: levels[i], script: script}
+		if shared {
+			current.script = language.Common
+		}
+	}
+	current.end = len(runes)
+	items = append(items, current)
+	for i := range items {
+		if items[i].script == language.Common {
+			items[i].script = language.Latin
+		}
+	}
+	return items
+}
+
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+// Draw paints a shaped line onto dst with its left edge at x and its
+// baseline at y
+func Draw(dst *image.RGBA, line Line, x, y float64, c color.Color) {
+	for _, run := range line.Runs {
+		scale := fixedToFloat(run.Size) / float64(run.Face.Upem())
+		pen := x + run.X
+		for _, g := range run.Glyphs {
+			gx := pen + fixedToFloat(g.XOffset)
+			gy := y - fixedToFloat(g.YOffset)
+			if outline, ok := run.Face.GlyphData(g.GlyphID).(font.GlyphOutline); ok {
+				graphics.Fill(dst, glyphPath(outline, gx, gy, scale), c)
+			}
+			pen += fixedToFloat(g.XAdvance)
+		}
+	}
+}
+
+// Rasterize draws a line into a new transparent image just large enough to
+// hold it, with the baseline at Ascent
+func Rasterize(line Line, c color.Color) *image.RGBA {
+	w := int(math.Ceil(line.Width))
+	h := int(math.Ceil(line.Ascent + line.Descent))
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	Draw(img, line, 0, line.Ascent, c)
+	return img
+}
+
+// glyphPath converts an outline in font units (y up) to device pixels
+func glyphPath(outline font.GlyphOutline, x, y, scale float64) *graphics.Path {
+	p := graphics.NewPath()
+	pt := func(sp font.SegmentPoint) (float64, float64) {
+		return x + float64(sp.X)*scale, y - float64(sp.Y)*scale
+	}
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			p.MoveTo(pt(seg.Args[0]))
+		case ot.SegmentOpLineTo:
+			p.LineTo(pt(seg.Args[0]))
+		case ot.SegmentOpQuadTo:
+			cx, cy := pt(seg.Args[0])
+			ex, ey := pt(seg.Args[1])
+			p.QuadTo(cx, cy, ex, ey)
+		case ot.SegmentOpCubeTo:
+			c1x, c1y := pt(seg.Args[0])
+			c2x, c2y := pt(seg.Args[1])
+			ex, ey := pt(seg.Args[2])
+			p.CubicTo(c1x, c1y, c2x, c2y, ex, ey)
+		}
+	}
+	return p
+}
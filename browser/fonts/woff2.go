@@ -0,0 +1,586 @@
+package fonts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/andybalholm/brotli"
+)
+
+// WOFF2 (https://www.w3.org/TR/WOFF2/) packs an sfnt's tables into one
+// Brotli stream. glyf and loca are usually transformed into separate
+// streams of contours, points and instructions, and hmtx may drop the left
+// side bearings that equal the glyphs' xMin. decodeWOFF2 undoes both and
+// rebuilds the sfnt the font parser reads.
+
+var errWOFF2Truncated = errors.New("fonts: truncated WOFF2 data")
+
+// woff2KnownTags are the tags a table directory entry can name by index
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2Table is one table directory entry and, once decompressed, its data
+type woff2Table struct {
+	tag       string
+	transform uint8
+	length    uint32 // bytes the table takes in the decompressed stream
+	data      []byte
+}
+
+// transformed reports whether the table's data needs reconstructing.
+// Version 0 is the glyf/loca transform but the null transform elsewhere.
+func (t *woff2Table) transformed() bool {
+	if t.tag == "glyf" || t.tag == "loca" {
+		return t.transform == 0
+	}
+	return t.transform != 0
+}
+
+// woff2Reader reads big-endian values, remembering the first overrun
+type woff2Reader struct {
+	buf []byte
+	err error
+}
+
+func (r *woff2Reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errWOFF2Truncated
+		return nil
+	}
+	b := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *woff2Reader) u8() uint8 {
+	if b := r.bytes(1); len(b) == 1 {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *woff2Reader) u16() uint16 {
+	if b := r.bytes(2); len(b) == 2 {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *woff2Reader) u32() uint32 {
+	if b := r.bytes(4); len(b) == 4 {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// base128 reads a UIntBase128: up to five bytes of seven bits each
+func (r *woff2Reader) base128() uint32 {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		if (i == 0 && b == 0x80) || v&0xFE000000 != 0 {
+			r.err = errors.New("fonts: malformed WOFF2 UIntBase128")
+			return 0
+		}
+		v = v<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = errors.New("fonts: malformed WOFF2 UIntBase128")
+	return 0
+}
+
+// u255 reads a 255UInt16, the variable length encoding of point counts
+// and instruction lengths
+func (r *woff2Reader) u255() int {
+	switch code := r.u8(); code {
+	case 253:
+		return int(r.u16())
+	case 254:
+		return int(r.u8()) + 506
+	case 255:
+		return int(r.u8()) + 253
+	default:
+		return int(code)
+	}
+}
+
+// decodeWOFF2 turns a WOFF2 file back into the sfnt it was made from
+func decodeWOFF2(data []byte) ([]byte, error) {
+	r := &woff2Reader{buf: data}
+	r.u32() // signature
+	flavor := r.u32()
+	r.u32() // length
+	numTables := int(r.u16())
+	r.u16() // reserved
+	r.u32() // totalSfntSize
+	compressedSize := int(r.u32())
+	r.bytes(24) // version, metadata and private data blocks
+
+	const collection = 0x74746366 // 'ttcf'
+	if flavor == collection {
+		return nil, errors.New("fonts: WOFF2 font collections are not supported")
+	}
+
+	tables := make([]woff2Table, numTables)
+	var total uint64
+	for i := range tables {
+		t := &tables[i]
+		flags := r.u8()
+		if index := flags & 0x3F; index == 63 {
+			t.tag = string(r.bytes(4))
+		} else {
+			t.tag = woff2KnownTags[index]
+		}
+		t.transform = flags >> 6
+		t.length = r.base128()
+		if t.transformed() {
+			t.length = r.base128()
+		}
+		total += uint64(t.length)
+	}
+	compressed := r.bytes(compressedSize)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	stream, err := io.ReadAll(io.LimitReader(brotli.NewReader(bytes.NewReader(compressed)), int64(total)+1))
+	if err != nil {
+		return nil, fmt.Errorf("fonts: WOFF2: %w", err)
+	}
+	if uint64(len(stream)) != total {
+		return nil, errors.New("fonts: WOFF2 tables do not match their decompressed size")
+	}
+	byTag := make(map[string]*woff2Table, len(tables))
+	for i := range tables {
+		t := &tables[i]
+		t.data, stream = stream[:t.length:t.length], stream[t.length:]
+		byTag[t.tag] = t
+	}
+
+	if err := reconstructTables(byTag); err != nil {
+		return nil, err
+	}
+	return buildSFNT(flavor, tables), nil
+}
+
+// reconstructTables undoes the glyf/loca and hmtx transforms in place
+func reconstructTables(byTag map[string]*woff2Table) error {
+	glyf, loca := byTag["glyf"], byTag["loca"]
+	if glyf != nil && loca != nil && glyf.transformed() != loca.transformed() {
+		return errors.New("fonts: WOFF2 glyf and loca must be transformed together")
+	}
+	var xMins []int16
+	if glyf != nil && glyf.transformed() {
+		if loca == nil {
+			return errors.New("fonts: WOFF2 transformed glyf without loca")
+		}
+		var err error
+		glyf.data, loca.data, xMins, err = reconstructGlyf(glyf.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	hmtx := byTag["hmtx"]
+	if hmtx == nil || !hmtx.transformed() {
+		return nil
+	}
+	hhea := byTag["hhea"]
+	if xMins == nil || hhea == nil || len(hhea.data) < 36 {
+		return errors.New("fonts: WOFF2 transformed hmtx needs transformed glyf and hhea")
+	}
+	numHMetrics := int(binary.BigEndian.Uint16(hhea.data[34:]))
+	var err error
+	hmtx.data, err = reconstructHmtx(hmtx.data, numHMetrics, xMins)
+	return err
+}
+
+// woff2Point is a decoded glyph outline point in absolute coordinates
+type woff2Point struct {
+	x, y    int
+	onCurve bool
+}
+
+// reconstructGlyf rebuilds glyf and loca from the transformed glyf table,
+// returning each glyph's xMin for the hmtx transform
+func reconstructGlyf(data []byte) (glyf, loca []byte, xMins []int16, err error) {
+	r := &woff2Reader{buf: data}
+	r.u16() // reserved
+	optionFlags := r.u16()
+	numGlyphs := int(r.u16())
+	indexFormat := r.u16()
+	var sizes [7]uint32
+	for i := range sizes {
+		sizes[i] = r.u32()
+	}
+	stream := func(i int) *woff2Reader {
+		return &woff2Reader{buf: r.bytes(int(sizes[i]))}
+	}
+	nContours, nPoints, flags, glyphs, composites := stream(0), stream(1), stream(2), stream(3), stream(4)
+	bboxes, instructions := stream(5), stream(6)
+	bboxBitmap := bboxes.bytes(4 * ((numGlyphs + 31) / 32))
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		overlapBitmap = r.bytes((numGlyphs + 7) / 8)
+	}
+	if r.err != nil || bboxes.err != nil {
+		return nil, nil, nil, errWOFF2Truncated
+	}
+	bitSet := func(bitmap []byte, i int) bool {
+		return bitmap != nil && bitmap[i>>3]&(0x80>>(i&7)) != 0
+	}
+
+	offsets := make([]int, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	for i := 0; i < numGlyphs; i++ {
+		offsets[i] = len(glyf)
+		contours := int16(nContours.u16())
+		hasBBox := bitSet(bboxBitmap, i)
+		switch {
+		case contours == 0:
+			if hasBBox {
+				return nil, nil, nil, errors.New("fonts: WOFF2 empty glyph with a bounding box")
+			}
+		case contours < 0:
+			// Composites always store their bounding box
+			if !hasBBox {
+				return nil, nil, nil, errors.New("fonts: WOFF2 composite glyph without a bounding box")
+			}
+			bbox := bboxes.bytes(8)
+			components, hasInstructions := readComposite(composites)
+			glyf = binary.BigEndian.AppendUint16(glyf, uint16(contours))
+			glyf = append(glyf, bbox...)
+			glyf = append(glyf, components...)
+			if hasInstructions {
+				n := glyphs.u255()
+				glyf = binary.BigEndian.AppendUint16(glyf, uint16(n))
+				glyf = append(glyf, instructions.bytes(n)...)
+			}
+			if len(bbox) == 8 {
+				xMins[i] = int16(binary.BigEndian.Uint16(bbox))
+			}
+		default:
+			endPoints := make([]uint16, contours)
+			total := 0
+			for c := range endPoints {
+				total += nPoints.u255()
+				if total > 0xFFFF {
+					return nil, nil, nil, errors.New("fonts: WOFF2 glyph has too many points")
+				}
+				endPoints[c] = uint16(total - 1)
+			}
+			points := decodeTriplets(flags.bytes(total), glyphs)
+			instructionLength := glyphs.u255()
+			instructionData := instructions.bytes(instructionLength)
+
+			var bbox [4]int16
+			if hasBBox {
+				for k := range bbox {
+					bbox[k] = int16(bboxes.u16())
+				}
+			} else {
+				bbox = boundingBox(points)
+			}
+			xMins[i] = bbox[0]
+
+			glyf = binary.BigEndian.AppendUint16(glyf, uint16(contours))
+			for _, v := range bbox {
+				glyf = binary.BigEndian.AppendUint16(glyf, uint16(v))
+			}
+			for _, end := range endPoints {
+				glyf = binary.BigEndian.AppendUint16(glyf, end)
+			}
+			glyf = binary.BigEndian.AppendUint16(glyf, uint16(instructionLength))
+			glyf = append(glyf, instructionData...)
+			glyf = appendSimpleOutline(glyf, points, bitSet(overlapBitmap, i))
+		}
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+	offsets[numGlyphs] = len(glyf)
+	for _, s := range []*woff2Reader{nContours, nPoints, flags, glyphs, composites, bboxes, instructions} {
+		if s.err != nil {
+			return nil, nil, nil, s.err
+		}
+	}
+
+	for _, offset := range offsets {
+		if indexFormat == 0 {
+			if offset/2 > 0xFFFF {
+				return nil, nil, nil, errors.New("fonts: WOFF2 glyf too large for short loca offsets")
+			}
+			loca = binary.BigEndian.AppendUint16(loca, uint16(offset/2))
+		} else {
+			loca = binary.BigEndian.AppendUint32(loca, uint32(offset))
+		}
+	}
+	return glyf, loca, xMins, nil
+}
+
+// decodeTriplets reads the points of a simple glyph: one flag per point
+// says whether it is on the curve and how its x and y deltas are packed
+// into the glyph stream
+func decodeTriplets(flags []byte, glyphs *woff2Reader) []woff2Point {
+	points := make([]woff2Point, len(flags))
+	withSign := func(flag byte, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	x, y := 0, 0
+	for i, flag := range flags {
+		onCurve := flag>>7 == 0
+		flag &= 0x7F
+		size := 4
+		switch {
+		case flag < 84:
+			size = 1
+		case flag < 120:
+			size = 2
+		case flag < 124:
+			size = 3
+		}
+		in := glyphs.bytes(size)
+		if len(in) != size {
+			return points
+		}
+
+		var dx, dy int
+		switch {
+		case flag < 10:
+			dy = withSign(flag, int(flag&14)<<7+int(in[0]))
+		case flag < 20:
+			dx = withSign(flag, int((flag-10)&14)<<7+int(in[0]))
+		case flag < 84:
+			b0, b1 := int(flag-20), int(in[0])
+			dx = withSign(flag, 1+(b0&0x30)+(b1>>4))
+			dy = withSign(flag>>1, 1+(b0&0x0C)<<2+(b1&0x0F))
+		case flag < 120:
+			b0 := int(flag - 84)
+			dx = withSign(flag, 1+(b0/12)<<8+int(in[0]))
+			dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(in[1]))
+		case flag < 124:
+			b2 := int(in[1])
+			dx = withSign(flag, int(in[0])<<4+b2>>4)
+			dy = withSign(flag>>1, (b2&0x0F)<<8+int(in[2]))
+		default:
+			dx = withSign(flag, int(in[0])<<8+int(in[1]))
+			dy = withSign(flag>>1, int(in[2])<<8+int(in[3]))
+		}
+		x += dx
+		y += dy
+		points[i] = woff2Point{x: x, y: y, onCurve: onCurve}
+	}
+	return points
+}
+
+// boundingBox returns xMin, yMin, xMax, yMax of points
+func boundingBox(points []woff2Point) [4]int16 {
+	if len(points) == 0 {
+		return [4]int16{}
+	}
+	box := [4]int{points[0].x, points[0].y, points[0].x, points[0].y}
+	for _, p := range points[1:] {
+		box[0], box[1] = min(box[0], p.x), min(box[1], p.y)
+		box[2], box[3] = max(box[2], p.x), max(box[3], p.y)
+	}
+	return [4]int16{int16(box[0]), int16(box[1]), int16(box[2]), int16(box[3])}
+}
+
+// appendSimpleOutline writes the flags and delta coordinates of a simple
+// glyph in the TrueType encoding, using a byte per delta where it fits
+func appendSimpleOutline(dst []byte, points []woff2Point, overlap bool) []byte {
+	const (
+		onCurve   = 0x01
+		xShort    = 0x02
+		yShort    = 0x04
+		xSame     = 0x10 // or positive, for a short x
+		ySame     = 0x20 // or positive, for a short y
+		overlapFl = 0x40
+	)
+	flags := make([]byte, len(points))
+	var xs, ys []byte
+	coordinate := func(delta int, short, same byte, out []byte) (byte, []byte) {
+		switch {
+		case delta == 0:
+			return same, out
+		case delta > -256 && delta < 256:
+			if delta > 0 {
+				return short | same, append(out, byte(delta))
+			}
+			return short, append(out, byte(-delta))
+		default:
+			return 0, binary.BigEndian.AppendUint16(out, uint16(int16(delta)))
+		}
+	}
+	prevX, prevY := 0, 0
+	for i, p := range points {
+		var fx, fy byte
+		fx, xs = coordinate(p.x-prevX, xShort, xSame, xs)
+		fy, ys = coordinate(p.y-prevY, yShort, ySame, ys)
+		flags[i] = fx | fy
+		if p.onCurve {
+			flags[i] |= onCurve
+		}
+		if i == 0 && overlap {
+			flags[i] |= overlapFl
+		}
+		prevX, prevY = p.x, p.y
+	}
+	dst = append(dst, flags...)
+	dst = append(dst, xs...)
+	return append(dst, ys...)
+}
+
+// readComposite returns the component records of a composite glyph and
+// whether instructions follow them
+func readComposite(r *woff2Reader) ([]byte, bool) {
+	const (
+		argsAreWords    = 0x0001
+		haveScale       = 0x0008
+		moreComponents  = 0x0020
+		haveXYScale     = 0x0040
+		haveTwoByTwo    = 0x0080
+		haveInstruction = 0x0100
+	)
+	start := r.buf
+	hasInstructions := false
+	for r.err == nil {
+		flags := r.u16()
+		r.u16() // glyph index
+		if flags&argsAreWords != 0 {
+			r.bytes(4)
+		} else {
+			r.bytes(2)
+		}
+		switch {
+		case flags&haveScale != 0:
+			r.bytes(2)
+		case flags&haveXYScale != 0:
+			r.bytes(4)
+		case flags&haveTwoByTwo != 0:
+			r.bytes(8)
+		}
+		hasInstructions = hasInstructions || flags&haveInstruction != 0
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	if r.err != nil {
+		return nil, false
+	}
+	return start[:len(start)-len(r.buf)], hasInstructions
+}
+
+// reconstructHmtx rebuilds hmtx, taking the left side bearings the
+// transform dropped from the glyphs' xMin
+func reconstructHmtx(data []byte, numHMetrics int, xMins []int16) ([]byte, error) {
+	numGlyphs := len(xMins)
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, errors.New("fonts: WOFF2 hhea has a bad numberOfHMetrics")
+	}
+	r := &woff2Reader{buf: data}
+	flags := r.u8()
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	bearings := make([]int16, numGlyphs)
+	for i := range bearings {
+		// Bit 0 drops the proportional glyphs' bearings, bit 1 the rest
+		dropped := flags&1 != 0
+		if i >= numHMetrics {
+			dropped = flags&2 != 0
+		}
+		if dropped {
+			bearings[i] = xMins[i]
+		} else {
+			bearings[i] = int16(r.u16())
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	hmtx := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i, bearing := range bearings {
+		if i < numHMetrics {
+			hmtx = binary.BigEndian.AppendUint16(hmtx, advances[i])
+		}
+		hmtx = binary.BigEndian.AppendUint16(hmtx, uint16(bearing))
+	}
+	return hmtx, nil
+}
+
+// buildSFNT lays the tables out as an sfnt with its table directory and
+// checksums
+func buildSFNT(flavor uint32, tables []woff2Table) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+
+	numTables := len(tables)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	font := binary.BigEndian.AppendUint32(nil, flavor)
+	font = binary.BigEndian.AppendUint16(font, uint16(numTables))
+	font = binary.BigEndian.AppendUint16(font, uint16(searchRange))
+	font = binary.BigEndian.AppendUint16(font, uint16(entrySelector))
+	font = binary.BigEndian.AppendUint16(font, uint16(numTables*16-searchRange))
+
+	directory := len(font)
+	font = append(font, make([]byte, 16*numTables)...)
+	headAt := -1
+	for i, t := range tables {
+		offset := len(font)
+		font = append(font, t.data...)
+		for len(font)%4 != 0 {
+			font = append(font, 0)
+		}
+		if t.tag == "head" && len(t.data) >= 12 {
+			headAt = offset
+			// The checksum adjustment is left out of the table's checksum
+			binary.BigEndian.PutUint32(font[offset+8:], 0)
+		}
+		record := font[directory+16*i:]
+		copy(record, t.tag)
+		binary.BigEndian.PutUint32(record[4:], checksum(font[offset:]))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(t.data)))
+	}
+	if headAt >= 0 {
+		binary.BigEndian.PutUint32(font[headAt+8:], 0xB1B0AFBA-checksum(font))
+	}
+	return font
+}
+
+// checksum sums data as big-endian uint32s; len(data) is a multiple of 4
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+4 <= len(data); i += 4 {
+		sum += binary.BigEndian.Uint32(data[i:])
+	}
+	return sum
+}
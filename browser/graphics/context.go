@@ -0,0 +1,427 @@
+package graphics
+
+import (
+	"browser/css"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/math/f64"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Canvas defaults from the HTML spec
+const (
+	DefaultCanvasWidth  = 300
+	DefaultCanvasHeight = 150
+)
+
+// contextState is what save() pushes and restore() pops
+type contextState struct {
+	transform    Matrix
+	fillStyle    string
+	strokeStyle  string
+	fill         color.Color
+	stroke       color.Color
+	lineWidth    float64
+	globalAlpha  float64
+	font         Font
+	textAlign    string
+	textBaseline string
+}
+
+// Context is a canvas bitmap with a CanvasRenderingContext2D-style API.
+// It is safe to draw from the JS goroutine while the renderer snapshots it.
+type Context struct {
+	mu    sync.Mutex
+	img   *image.RGBA
+	path  *Path
+	state contextState
+	stack []contextState
+}
+
+func NewContext(width, height int) *Context {
+	c := &Context{}
+	c.reset(width, height)
+	return c
+}
+
+// reset allocates a cleared bitmap and default state, as setting a
+// canvas's width or height does
+func (c *Context) reset(width, height int) {
+	c.img = image.NewRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+	c.path = NewPath()
+	c.stack = nil
+	c.state = contextState{
+		transform:    Identity(),
+		fillStyle:    "#000000",
+		strokeStyle:  "#000000",
+		fill:         color.Black,
+		stroke:       color.Black,
+		lineWidth:    1,
+		globalAlpha:  1,
+		font:         DefaultFont,
+		textAlign:    "start",
+		textBaseline: "alphabetic",
+	}
+}
+
+// Resize clears the canvas to a new size
+func (c *Context) Resize(width, height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset(width, height)
+}
+
+func (c *Context) Size() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// Snapshot returns a copy of the bitmap that later drawing won't change
+func (c *Context) Snapshot() *image.RGBA {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img := image.NewRGBA(c.img.Bounds())
+	copy(img.Pix, c.img.Pix)
+	return img
+}
+
+func (c *Context) Save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stack = append(c.stack, c.state)
+}
+
+func (c *Context) Restore() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stack) == 0 {
+		return
+	}
+	c.state = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	c.path.M = c.state.transform
+}
+
+func (c *Context) setTransform(m Matrix) {
+	c.state.transform = m
+	c.path.M = m
+}
+
+func (c *Context) Translate(x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTransform(c.state.transform.Translate(x, y))
+}
+
+func (c *Context) Scale(x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTransform(c.state.transform.Scale(x, y))
+}
+
+func (c *Context) Rotate(angle float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTransform(c.state.transform.Rotate(angle))
+}
+
+// Transform multiplies the current transform by m
+func (c *Context) Transform(m Matrix) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTransform(c.state.transform.Multiply(m))
+}
+
+// SetTransform replaces the current transform
+func (c *Context) SetTransform(m Matrix) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTransform(m)
+}
+
+// SetFillStyle sets the fill color; unparseable values are ignored
+func (c *Context) SetFillStyle(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if col := css.ParseColor(strings.TrimSpace(value)); col != nil {
+		c.state.fillStyle = value
+		c.state.fill = col
+	}
+}
+
+func (c *Context) FillStyle() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.fillStyle
+}
+
+// SetStrokeStyle sets the stroke color; unparseable values are ignored
+func (c *Context) SetStrokeStyle(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if col := css.ParseColor(strings.TrimSpace(value)); col != nil {
+		c.state.strokeStyle = value
+		c.state.stroke = col
+	}
+}
+
+func (c *Context) StrokeStyle() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.strokeStyle
+}
+
+func (c *Context) SetLineWidth(width float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if width > 0 {
+		c.state.lineWidth = width
+	}
+}
+
+func (c *Context) LineWidth() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.lineWidth
+}
+
+func (c *Context) SetGlobalAlpha(alpha float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if alpha >= 0 && alpha <= 1 {
+		c.state.globalAlpha = alpha
+	}
+}
+
+func (c *Context) GlobalAlpha() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.globalAlpha
+}
+
+// SetFont sets the font from a CSS font shorthand; invalid values are ignored
+func (c *Context) SetFont(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := ParseFont(value); ok {
+		c.state.font = f
+	}
+}
+
+func (c *Context) Font() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.font.String()
+}
+
+func (c *Context) SetTextAlign(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch value {
+	case "start", "end", "left", "right", "center":
+		c.state.textAlign = value
+	}
+}
+
+func (c *Context) TextAlign() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.textAlign
+}
+
+func (c *Context) SetTextBaseline(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch value {
+	case "top", "hanging", "middle", "alphabetic", "ideographic", "bottom":
+		c.state.textBaseline = value
+	}
+}
+
+func (c *Context) TextBaseline() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.textBaseline
+}
+
+func (c *Context) FillRect(x, y, w, h float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &Path{M: c.state.transform}
+	p.Rect(x, y, w, h)
+	Fill(c.img, p, WithAlpha(c.state.fill, c.state.globalAlpha))
+}
+
+func (c *Context) StrokeRect(x, y, w, h float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &Path{M: c.state.transform}
+	p.Rect(x, y, w, h)
+	c.strokePath(p)
+}
+
+// ClearRect makes the rectangle fully transparent
+func (c *Context) ClearRect(x, y, w, h float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &Path{M: c.state.transform}
+	p.Rect(x, y, w, h)
+
+	// Rasterize the rectangle as a mask, then cut it out of the bitmap
+	mask := image.NewRGBA(c.img.Bounds())
+	Fill(mask, p, color.White)
+	for i := 3; i < len(mask.Pix); i += 4 {
+		keep := 255 - uint32(mask.Pix[i])
+		for j := i - 3; j <= i; j++ {
+			c.img.Pix[j] = uint8(uint32(c.img.Pix[j]) * keep / 255)
+		}
+	}
+}
+
+func (c *Context) BeginPath() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.Clear()
+}
+
+func (c *Context) ClosePath() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.Close()
+}
+
+func (c *Context) MoveTo(x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.MoveTo(x, y)
+}
+
+func (c *Context) LineTo(x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.LineTo(x, y)
+}
+
+func (c *Context) QuadraticCurveTo(cx, cy, x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.QuadTo(cx, cy, x, y)
+}
+
+func (c *Context) BezierCurveTo(c1x, c1y, c2x, c2y, x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.CubicTo(c1x, c1y, c2x, c2y, x, y)
+}
+
+func (c *Context) Arc(x, y, r, startAngle, endAngle float64, counterclockwise bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r < 0 {
+		return
+	}
+	c.path.Arc(x, y, r, startAngle, endAngle, counterclockwise)
+}
+
+func (c *Context) Ellipse(x, y, rx, ry, rotation, startAngle, endAngle float64, counterclockwise bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rx < 0 || ry < 0 {
+		return
+	}
+	c.path.Ellipse(x, y, rx, ry, rotation, startAngle, endAngle, counterclockwise)
+}
+
+func (c *Context) Rect(x, y, w, h float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path.Rect(x, y, w, h)
+}
+
+// Fill fills the current path with the fill style
+func (c *Context) Fill() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	Fill(c.img, c.path, WithAlpha(c.state.fill, c.state.globalAlpha))
+}
+
+// Stroke outlines the current path with the stroke style
+func (c *Context) Stroke() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strokePath(c.path)
+}
+
+func (c *Context) strokePath(p *Path) {
+	width := c.state.lineWidth * c.state.transform.ScaleFactor()
+	Stroke(c.img, p, width, WithAlpha(c.state.stroke, c.state.globalAlpha))
+}
+
+func (c *Context) FillText(text string, x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drawText(text, x, y, WithAlpha(c.state.fill, c.state.globalAlpha))
+}
+
+func (c *Context) StrokeText(text string, x, y float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Outlined glyphs aren't supported; draw them solid in the stroke color
+	c.drawText(text, x, y, WithAlpha(c.state.stroke, c.state.globalAlpha))
+}
+
+// drawText positions text by textAlign in user space, then draws it at the
+// transformed anchor with the font scaled by the transform
+func (c *Context) drawText(text string, x, y float64, col color.Color) {
+	width := MeasureText(text, c.state.font)
+	switch c.state.textAlign {
+	case "center":
+		x -= width / 2
+	case "right", "end":
+		x -= width
+	}
+
+	f := c.state.font
+	f.Size *= c.state.transform.ScaleFactor()
+	dx, dy := c.state.transform.Apply(x, y)
+	DrawText(c.img, text, dx, dy, f, c.state.textBaseline, col)
+}
+
+// MeasureText returns the width of text in the current font
+func (c *Context) MeasureText(text string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return MeasureText(text, c.state.font)
+}
+
+// DrawImage draws the source rectangle (sx, sy, sw, sh) of src into the
+// destination rectangle (dx, dy, dw, dh) under the current transform
+func (c *Context) DrawImage(src image.Image, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if src == nil || sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+		return
+	}
+
+	b := src.Bounds()
+	m := c.state.transform.
+		Translate(dx, dy).
+		Scale(dw/sw, dh/sh).
+		Translate(-sx-float64(b.Min.X), -sy-float64(b.Min.Y))
+	aff := f64.Aff3{m.A, m.C, m.E, m.B, m.D, m.F}
+
+	srcRect := image.Rect(b.Min.X+int(sx), b.Min.Y+int(sy), b.Min.X+int(sx+sw), b.Min.Y+int(sy+sh)).Intersect(b)
+
+	var opts *xdraw.Options
+	if c.state.globalAlpha < 1 {
+		opts = &xdraw.Options{SrcMask: image.NewUniform(color.Alpha{A: uint8(c.state.globalAlpha * 255)})}
+	}
+	xdraw.BiLinear.Transform(c.img, aff, src, srcRect, draw.Over, opts)
+}
@@ -0,0 +1,321 @@
+package graphics
+
+import (
+	"browser/dom"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	clear = color.RGBA{}
+)
+
+func pixel(img *image.RGBA, x, y int) color.RGBA {
+	return img.RGBAAt(x, y)
+}
+
+func TestMatrix(t *testing.T) {
+	m := Identity().Translate(10, 20).Scale(2, 3)
+	x, y := m.Apply(1, 1)
+	assert.InDelta(t, 12, x, 1e-9)
+	assert.InDelta(t, 23, y, 1e-9)
+
+	r := Identity().Rotate(math.Pi / 2)
+	x, y = r.Apply(1, 0)
+	assert.InDelta(t, 0, x, 1e-9)
+	assert.InDelta(t, 1, y, 1e-9)
+
+	inv, ok := m.Invert()
+	require.True(t, ok)
+	x, y = inv.Apply(12, 23)
+	assert.InDelta(t, 1, x, 1e-9)
+	assert.InDelta(t, 1, y, 1e-9)
+
+	assert.InDelta(t, math.Sqrt(6), m.ScaleFactor(), 1e-9)
+}
+
+func TestFillRect(t *testing.T) {
+	ctx := NewContext(20, 20)
+	ctx.SetFillStyle("red")
+	ctx.FillRect(5, 5, 10, 10)
+	img := ctx.Snapshot()
+
+	assert.Equal(t, red, pixel(img, 10, 10))
+	assert.Equal(t, red, pixel(img, 5, 5))
+	assert.Equal(t, clear, pixel(img, 4, 10))
+	assert.Equal(t, clear, pixel(img, 15, 10))
+}
+
+func TestClearRect(t *testing.T) {
+	ctx := NewContext(20, 20)
+	ctx.SetFillStyle("blue")
+	ctx.FillRect(0, 0, 20, 20)
+	ctx.ClearRect(0, 0, 10, 20)
+	img := ctx.Snapshot()
+
+	assert.Equal(t, clear, pixel(img, 5, 5))
+	assert.Equal(t, blue, pixel(img, 15, 5))
+}
+
+func TestTransformAndRestore(t *testing.T) {
+	ctx := NewContext(40, 40)
+	ctx.SetFillStyle("red")
+	ctx.Save()
+	ctx.Translate(20, 20)
+	ctx.Scale(2, 2)
+	ctx.FillRect(0, 0, 5, 5) // covers 20..30
+	ctx.Restore()
+	ctx.SetFillStyle("blue")
+	ctx.FillRect(0, 0, 5, 5)
+	img := ctx.Snapshot()
+
+	assert.Equal(t, red, pixel(img, 25, 25))
+	assert.Equal(t, clear, pixel(img, 31, 31))
+	assert.Equal(t, blue, pixel(img, 2, 2))
+}
+
+func TestArcFill(t *testing.T) {
+	ctx := NewContext(40, 40)
+	ctx.SetFillStyle("red")
+	ctx.BeginPath()
+	ctx.Arc(20, 20, 10, 0, 2*math.Pi, false)
+	ctx.Fill()
+	img := ctx.Snapshot()
+
+	assert.Equal(t, red, pixel(img, 20, 20))
+	assert.Equal(t, red, pixel(img, 27, 20))
+	assert.Equal(t, clear, pixel(img, 28, 28)) // outside the circle, inside its box
+}
+
+func TestStrokePath(t *testing.T) {
+	ctx := NewContext(40, 40)
+	ctx.SetStrokeStyle("blue")
+	ctx.SetLineWidth(4)
+	ctx.BeginPath()
+	ctx.MoveTo(5, 20)
+	ctx.LineTo(35, 20)
+	ctx.Stroke()
+	img := ctx.Snapshot()
+
+	assert.Equal(t, blue, pixel(img, 20, 19))
+	assert.Equal(t, blue, pixel(img, 20, 20))
+	assert.Equal(t, clear, pixel(img, 20, 24))
+	assert.Equal(t, clear, pixel(img, 20, 15))
+}
+
+func TestGlobalAlpha(t *testing.T) {
+	ctx := NewContext(10, 10)
+	ctx.SetGlobalAlpha(0.5)
+	ctx.SetFillStyle("rgb(255, 0, 0)")
+	ctx.FillRect(0, 0, 10, 10)
+	c := pixel(ctx.Snapshot(), 5, 5)
+	assert.InDelta(t, 128, int(c.A), 1)
+}
+
+func TestInvalidStyleIgnored(t *testing.T) {
+	ctx := NewContext(10, 10)
+	ctx.SetFillStyle("red")
+	ctx.SetFillStyle("not-a-color")
+	assert.Equal(t, "red", ctx.FillStyle())
+}
+
+func TestFillText(t *testing.T) {
+	ctx := NewContext(100, 40)
+	ctx.SetFont("20px sans-serif")
+	assert.Equal(t, "20px sans-serif", ctx.Font())
+	ctx.FillText("Hello", 5, 30)
+
+	img := ctx.Snapshot()
+	inked := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 {
+			inked++
+		}
+	}
+	assert.Greater(t, inked, 50)
+	assert.Greater(t, ctx.MeasureText("Hello"), 30.0)
+}
+
+func TestDrawImage(t *testing.T) {
+	src := NewContext(2, 2)
+	src.SetFillStyle("red")
+	src.FillRect(0, 0, 2, 2)
+
+	ctx := NewContext(20, 20)
+	ctx.DrawImage(src.Snapshot(), 0, 0, 2, 2, 5, 5, 10, 10)
+	img := ctx.Snapshot()
+	assert.Equal(t, red, pixel(img, 10, 10))
+	assert.Equal(t, clear, pixel(img, 2, 2))
+}
+
+func TestParseFont(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Font
+		ok    bool
+	}{
+		{"10px sans-serif", Font{Size: 10}, true},
+		{"bold 16px Arial", Font{Size: 16, Bold: true}, true},
+		{"italic 700 12pt serif", Font{Size: 16, Bold: true, Italic: true}, true},
+		{"14px/20px monospace", Font{Size: 14, Monospace: true}, true},
+		{"serif", Font{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseFont(tt.input)
+		assert.Equal(t, tt.ok, ok, tt.input)
+		if tt.ok {
+			assert.Equal(t, tt.want, got, tt.input)
+		}
+	}
+}
+
+func TestParsePathData(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      string
+		inside [2]float64
+		out    [2]float64
+	}{
+		{"absolute lines", "M2 2 L18 2 L18 18 L2 18 Z", [2]float64{10, 10}, [2]float64{19, 19}},
+		{"relative lines", "m2 2 h16 v16 h-16 z", [2]float64{10, 10}, [2]float64{1, 1}},
+		{"implicit lineto", "M2,2 18,2 18,18 2,18z", [2]float64{10, 10}, [2]float64{1, 10}},
+		{"packed numbers", "M2-0.5.5 18L18 18z", [2]float64{8, 12}, [2]float64{17, 3}},
+		{"arc", "M2 10 A8 8 0 1 1 18 10 A8 8 0 1 1 2 10Z", [2]float64{10, 10}, [2]float64{17, 17}},
+		{"cubic", "M2 18 C2 0 18 0 18 18Z", [2]float64{10, 10}, [2]float64{2, 2}},
+		{"quadratic", "M2 18 Q10 -10 18 18Z", [2]float64{10, 10}, [2]float64{2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+			p := NewPath()
+			ParsePathData(p, tt.d)
+			Fill(img, p, red)
+			assert.Equal(t, red, pixel(img, int(tt.inside[0]), int(tt.inside[1])))
+			assert.Equal(t, clear, pixel(img, int(tt.out[0]), int(tt.out[1])))
+		})
+	}
+}
+
+func TestParsePathDataStopsAtError(t *testing.T) {
+	p := NewPath()
+	ParsePathData(p, "M0 0 L10 0 L10 x L0 10")
+	require.Len(t, p.subpaths, 1)
+	assert.Len(t, p.subpaths[0].points, 2)
+}
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		value string
+		x, y  float64
+	}{
+		{"translate(10, 20)", 11, 21},
+		{"scale(2)", 2, 2},
+		{"scale(2 3)", 2, 3},
+		{"translate(10) scale(2)", 12, 2},
+		{"rotate(90)", -1, 1},
+		{"rotate(90 1 1)", 1, 1},
+		{"matrix(1 0 0 1 5 5)", 6, 6},
+	}
+	for _, tt := range tests {
+		x, y := ParseTransform(tt.value).Apply(1, 1)
+		assert.InDelta(t, tt.x, x, 1e-9, tt.value)
+		assert.InDelta(t, tt.y, y, 1e-9, tt.value)
+	}
+}
+
+func parseSVG(t *testing.T, html string) *dom.Node {
+	t.Helper()
+	doc := dom.Parse(strings.NewReader(html))
+	svg := dom.FindElementsByTagName(doc, "svg")
+	require.NotNil(t, svg)
+	return svg
+}
+
+func TestRenderSVGShapes(t *testing.T) {
+	svg := parseSVG(t, `<svg width="100" height="100">
+		<rect x="0" y="0" width="50" height="50" fill="red"/>
+		<circle cx="75" cy="75" r="20" fill="#0000ff"/>
+		<line x1="0" y1="90" x2="40" y2="90" stroke="red" stroke-width="4"/>
+		<polygon points="60,5 95,5 95,40" style="fill: blue"/>
+		<rect x="10" y="60" width="20" height="20" fill="none" stroke="blue"/>
+	</svg>`)
+	img := RenderSVG(svg, 100, 100)
+
+	assert.Equal(t, red, pixel(img, 25, 25))
+	assert.Equal(t, blue, pixel(img, 75, 75))
+	assert.Equal(t, red, pixel(img, 20, 90))
+	assert.Equal(t, blue, pixel(img, 90, 10))
+	assert.Equal(t, clear, pixel(img, 65, 35)) // below the polygon's diagonal
+	assert.Equal(t, clear, pixel(img, 20, 70)) // unfilled rect interior
+	assert.Equal(t, clear, pixel(img, 99, 0))
+}
+
+func TestRenderSVGViewBox(t *testing.T) {
+	// The 10x10 viewBox is scaled up to fill 100x100
+	svg := parseSVG(t, `<svg width="100" height="100" viewBox="0 0 10 10">
+		<rect x="5" y="5" width="5" height="5" fill="red"/>
+	</svg>`)
+	img := RenderSVG(svg, 100, 100)
+	assert.Equal(t, red, pixel(img, 75, 75))
+	assert.Equal(t, clear, pixel(img, 45, 45))
+
+	// A wide viewport centers the square viewBox horizontally
+	svg = parseSVG(t, `<svg viewBox="0 0 10 10"><rect width="10" height="10" fill="red"/></svg>`)
+	img = RenderSVG(svg, 200, 100)
+	assert.Equal(t, clear, pixel(img, 40, 50))
+	assert.Equal(t, red, pixel(img, 100, 50))
+}
+
+func TestRenderSVGGroupsInherit(t *testing.T) {
+	svg := parseSVG(t, `<svg width="40" height="40">
+		<g fill="blue" transform="translate(20 20)">
+			<rect width="10" height="10"/>
+			<g opacity="0"><rect x="-20" y="-20" width="10" height="10"/></g>
+		</g>
+		<text x="0" y="15" font-size="14" fill="none">hidden</text>
+	</svg>`)
+	img := RenderSVG(svg, 40, 40)
+	assert.Equal(t, blue, pixel(img, 25, 25))
+	assert.Equal(t, clear, pixel(img, 5, 5))
+}
+
+func TestRenderSVGText(t *testing.T) {
+	svg := parseSVG(t, `<svg width="100" height="30"><text x="2" y="20" font-size="16">SVG</text></svg>`)
+	img := RenderSVG(svg, 100, 30)
+	inked := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 {
+			inked++
+		}
+	}
+	assert.Greater(t, inked, 20)
+}
+
+func TestCanvasRegistry(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<canvas width="40" height="30"></canvas><canvas></canvas>`))
+	canvas := dom.FindElementsByTagName(doc, "canvas")
+	require.NotNil(t, canvas)
+
+	assert.Nil(t, LookupCanvas(canvas))
+	ctx := CanvasFor(canvas)
+	assert.Same(t, ctx, LookupCanvas(canvas))
+	w, h := ctx.Size()
+	assert.Equal(t, 40, w)
+	assert.Equal(t, 30, h)
+
+	canvas.Attributes["width"] = "80"
+	assert.Same(t, ctx, CanvasFor(canvas))
+	w, _ = ctx.Size()
+	assert.Equal(t, 80, w)
+
+	ReleaseCanvases(doc)
+	assert.Nil(t, LookupCanvas(canvas))
+}
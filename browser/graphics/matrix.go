@@ -0,0 +1,64 @@
+package graphics
+
+import "math"
+
+// Matrix is a 2D affine transform mapping (x, y) to
+// (A*x + C*y + E, B*x + D*y + F), the same layout as canvas setTransform
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+func Identity() Matrix {
+	return Matrix{A: 1, D: 1}
+}
+
+// Multiply returns m * n: n is applied first, then m
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+func (m Matrix) Translate(tx, ty float64) Matrix {
+	return m.Multiply(Matrix{A: 1, D: 1, E: tx, F: ty})
+}
+
+func (m Matrix) Scale(sx, sy float64) Matrix {
+	return m.Multiply(Matrix{A: sx, D: sy})
+}
+
+// Rotate rotates clockwise on screen by angle radians
+func (m Matrix) Rotate(angle float64) Matrix {
+	sin, cos := math.Sincos(angle)
+	return m.Multiply(Matrix{A: cos, B: sin, C: -sin, D: cos})
+}
+
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+// ScaleFactor is the average linear scale, used for line widths and font sizes
+func (m Matrix) ScaleFactor() float64 {
+	return math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+}
+
+// Invert returns the inverse transform, or false if m is singular
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if det == 0 {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
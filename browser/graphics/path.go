@@ -0,0 +1,207 @@
+package graphics
+
+import "math"
+
+type Point struct {
+	X, Y float64
+}
+
+type subpath struct {
+	points []Point
+	closed bool
+}
+
+// Path is a list of flattened subpaths in device space. Points are mapped
+// through M as they are added, so changing M between calls behaves like the
+// canvas current transform.
+type Path struct {
+	M        Matrix
+	subpaths []subpath
+}
+
+func NewPath() *Path {
+	return &Path{M: Identity()}
+}
+
+// Clear removes all subpaths but keeps the transform
+func (p *Path) Clear() {
+	p.subpaths = nil
+}
+
+func (p *Path) Empty() bool {
+	return len(p.subpaths) == 0
+}
+
+func (p *Path) MoveTo(x, y float64) {
+	dx, dy := p.M.Apply(x, y)
+	p.subpaths = append(p.subpaths, subpath{points: []Point{{dx, dy}}})
+}
+
+func (p *Path) LineTo(x, y float64) {
+	dx, dy := p.M.Apply(x, y)
+	p.lineToDevice(Point{dx, dy})
+}
+
+func (p *Path) lineToDevice(pt Point) {
+	if len(p.subpaths) == 0 {
+		p.subpaths = append(p.subpaths, subpath{points: []Point{pt}})
+		return
+	}
+	last := &p.subpaths[len(p.subpaths)-1]
+	if last.closed {
+		// Drawing after closePath starts a new subpath at the closed one's start
+		p.subpaths = append(p.subpaths, subpath{points: []Point{last.points[0]}})
+		last = &p.subpaths[len(p.subpaths)-1]
+	}
+	last.points = append(last.points, pt)
+}
+
+// current returns the last device point, or false for an empty path
+func (p *Path) current() (Point, bool) {
+	if len(p.subpaths) == 0 {
+		return Point{}, false
+	}
+	last := p.subpaths[len(p.subpaths)-1]
+	if last.closed {
+		return last.points[0], true
+	}
+	return last.points[len(last.points)-1], true
+}
+
+// CurrentPoint returns the last point in user space. Returns false for an empty path.
+func (p *Path) CurrentPoint() (float64, float64, bool) {
+	pt, ok := p.current()
+	if !ok {
+		return 0, 0, false
+	}
+	inv, ok := p.M.Invert()
+	if !ok {
+		return 0, 0, false
+	}
+	x, y := inv.Apply(pt.X, pt.Y)
+	return x, y, true
+}
+
+func (p *Path) QuadTo(cx, cy, x, y float64) {
+	start, ok := p.current()
+	if !ok {
+		p.MoveTo(cx, cy)
+		start, _ = p.current()
+	}
+	c := p.device(cx, cy)
+	end := p.device(x, y)
+
+	n := segmentsFor(start, c, end)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		p.lineToDevice(Point{
+			X: mt*mt*start.X + 2*mt*t*c.X + t*t*end.X,
+			Y: mt*mt*start.Y + 2*mt*t*c.Y + t*t*end.Y,
+		})
+	}
+}
+
+func (p *Path) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	start, ok := p.current()
+	if !ok {
+		p.MoveTo(c1x, c1y)
+		start, _ = p.current()
+	}
+	c1 := p.device(c1x, c1y)
+	c2 := p.device(c2x, c2y)
+	end := p.device(x, y)
+
+	n := segmentsFor(start, c1, c2, end)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		p.lineToDevice(Point{
+			X: a*start.X + b*c1.X + c*c2.X + d*end.X,
+			Y: a*start.Y + b*c1.Y + c*c2.Y + d*end.Y,
+		})
+	}
+}
+
+// Arc adds a circular arc, connected to the current point by a straight line
+func (p *Path) Arc(cx, cy, r, startAngle, endAngle float64, counterclockwise bool) {
+	p.Ellipse(cx, cy, r, r, 0, startAngle, endAngle, counterclockwise)
+}
+
+// Ellipse adds an elliptical arc with the canvas ellipse() angle rules
+func (p *Path) Ellipse(cx, cy, rx, ry, rotation, startAngle, endAngle float64, counterclockwise bool) {
+	sweep := endAngle - startAngle
+	if counterclockwise {
+		if sweep <= -2*math.Pi {
+			sweep = -2 * math.Pi
+		} else {
+			sweep = math.Mod(sweep, 2*math.Pi)
+			if sweep > 0 {
+				sweep -= 2 * math.Pi
+			}
+		}
+	} else {
+		if sweep >= 2*math.Pi {
+			sweep = 2 * math.Pi
+		} else {
+			sweep = math.Mod(sweep, 2*math.Pi)
+			if sweep < 0 {
+				sweep += 2 * math.Pi
+			}
+		}
+	}
+
+	sinRot, cosRot := math.Sincos(rotation)
+	pointAt := func(angle float64) (float64, float64) {
+		sin, cos := math.Sincos(angle)
+		x, y := rx*cos, ry*sin
+		return cx + x*cosRot - y*sinRot, cy + x*sinRot + y*cosRot
+	}
+
+	// Enough segments that each covers a few device pixels
+	radius := max(rx, ry) * p.M.ScaleFactor()
+	n := int(math.Ceil(math.Abs(sweep) * max(radius, 1) / 4))
+	n = max(4, min(n, 256))
+
+	x, y := pointAt(startAngle)
+	if _, ok := p.current(); ok {
+		p.LineTo(x, y)
+	} else {
+		p.MoveTo(x, y)
+	}
+	for i := 1; i <= n; i++ {
+		x, y := pointAt(startAngle + sweep*float64(i)/float64(n))
+		p.LineTo(x, y)
+	}
+}
+
+func (p *Path) Rect(x, y, w, h float64) {
+	p.MoveTo(x, y)
+	p.LineTo(x+w, y)
+	p.LineTo(x+w, y+h)
+	p.LineTo(x, y+h)
+	p.Close()
+}
+
+func (p *Path) Close() {
+	if len(p.subpaths) == 0 {
+		return
+	}
+	p.subpaths[len(p.subpaths)-1].closed = true
+}
+
+func (p *Path) device(x, y float64) Point {
+	dx, dy := p.M.Apply(x, y)
+	return Point{dx, dy}
+}
+
+// segmentsFor picks how many line segments approximate a curve, based on
+// the length of its control polygon
+func segmentsFor(points ...Point) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	return max(4, min(int(length/3), 128))
+}
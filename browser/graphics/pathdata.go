@@ -0,0 +1,299 @@
+package graphics
+
+import (
+	"math"
+	"strconv"
+)
+
+// pathScanner tokenizes SVG path data and number lists
+type pathScanner struct {
+	s   string
+	pos int
+}
+
+func (s *pathScanner) skipSeparators() {
+	for s.pos < len(s.s) {
+		switch s.s[s.pos] {
+		case ' ', '\t', '\n', '\r', ',':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// command returns the next command letter, if the next token is one
+func (s *pathScanner) command() (byte, bool) {
+	s.skipSeparators()
+	if s.pos >= len(s.s) {
+		return 0, false
+	}
+	c := s.s[s.pos]
+	if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+		s.pos++
+		return c, true
+	}
+	return 0, false
+}
+
+// number reads one number. Numbers may run together, as in "1.5.5" or "1-2".
+func (s *pathScanner) number() (float64, bool) {
+	s.skipSeparators()
+	start := s.pos
+	i := s.pos
+	if i < len(s.s) && (s.s[i] == '+' || s.s[i] == '-') {
+		i++
+	}
+	digits := false
+	for i < len(s.s) && isDigit(s.s[i]) {
+		i++
+		digits = true
+	}
+	if i < len(s.s) && s.s[i] == '.' {
+		i++
+		for i < len(s.s) && isDigit(s.s[i]) {
+			i++
+			digits = true
+		}
+	}
+	if !digits {
+		return 0, false
+	}
+	if i < len(s.s) && (s.s[i] == 'e' || s.s[i] == 'E') {
+		j := i + 1
+		if j < len(s.s) && (s.s[j] == '+' || s.s[j] == '-') {
+			j++
+		}
+		if j < len(s.s) && isDigit(s.s[j]) {
+			for j < len(s.s) && isDigit(s.s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	v, err := strconv.ParseFloat(s.s[start:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	s.pos = i
+	return v, true
+}
+
+// flag reads an arc flag, which may be written without a separator
+func (s *pathScanner) flag() (bool, bool) {
+	s.skipSeparators()
+	if s.pos >= len(s.s) || (s.s[s.pos] != '0' && s.s[s.pos] != '1') {
+		return false, false
+	}
+	s.pos++
+	return s.s[s.pos-1] == '1', true
+}
+
+// numbers reads n numbers, failing if any is missing
+func (s *pathScanner) numbers(n int) ([]float64, bool) {
+	nums := make([]float64, n)
+	for i := range nums {
+		v, ok := s.number()
+		if !ok {
+			return nil, false
+		}
+		nums[i] = v
+	}
+	return nums, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ParsePathData adds the commands of an SVG path "d" attribute to p.
+// Parsing stops at the first error, keeping what was drawn so far, as
+// browsers do.
+func ParsePathData(p *Path, d string) {
+	s := &pathScanner{s: d}
+	var cx, cy float64         // current point
+	var startX, startY float64 // start of the current subpath
+	var ctrlX, ctrlY float64   // last control point, for S and T
+	var prev byte
+
+	cmd, ok := s.command()
+	if !ok || (cmd != 'M' && cmd != 'm') {
+		return
+	}
+
+	for {
+		rel := cmd >= 'a'
+		ox, oy := 0.0, 0.0
+		if rel {
+			ox, oy = cx, cy
+		}
+
+		switch cmd {
+		case 'M', 'm':
+			n, ok := s.numbers(2)
+			if !ok {
+				return
+			}
+			cx, cy = ox+n[0], oy+n[1]
+			startX, startY = cx, cy
+			p.MoveTo(cx, cy)
+			// Further coordinate pairs are implicit lineto commands
+			cmd = 'L' + (cmd - 'M')
+			prev = 'M'
+			if next, ok := s.continuation(cmd); ok {
+				cmd = next
+				continue
+			}
+			return
+		case 'L', 'l':
+			n, ok := s.numbers(2)
+			if !ok {
+				return
+			}
+			cx, cy = ox+n[0], oy+n[1]
+			p.LineTo(cx, cy)
+		case 'H', 'h':
+			n, ok := s.numbers(1)
+			if !ok {
+				return
+			}
+			cx = ox + n[0]
+			p.LineTo(cx, cy)
+		case 'V', 'v':
+			n, ok := s.numbers(1)
+			if !ok {
+				return
+			}
+			cy = oy + n[0]
+			p.LineTo(cx, cy)
+		case 'C', 'c':
+			n, ok := s.numbers(6)
+			if !ok {
+				return
+			}
+			p.CubicTo(ox+n[0], oy+n[1], ox+n[2], oy+n[3], ox+n[4], oy+n[5])
+			ctrlX, ctrlY = ox+n[2], oy+n[3]
+			cx, cy = ox+n[4], oy+n[5]
+		case 'S', 's':
+			n, ok := s.numbers(4)
+			if !ok {
+				return
+			}
+			// The first control point reflects the previous curve's second one
+			c1x, c1y := cx, cy
+			if prev == 'C' || prev == 'S' {
+				c1x, c1y = 2*cx-ctrlX, 2*cy-ctrlY
+			}
+			p.CubicTo(c1x, c1y, ox+n[0], oy+n[1], ox+n[2], oy+n[3])
+			ctrlX, ctrlY = ox+n[0], oy+n[1]
+			cx, cy = ox+n[2], oy+n[3]
+		case 'Q', 'q':
+			n, ok := s.numbers(4)
+			if !ok {
+				return
+			}
+			p.QuadTo(ox+n[0], oy+n[1], ox+n[2], oy+n[3])
+			ctrlX, ctrlY = ox+n[0], oy+n[1]
+			cx, cy = ox+n[2], oy+n[3]
+		case 'T', 't':
+			n, ok := s.numbers(2)
+			if !ok {
+				return
+			}
+			qx, qy := cx, cy
+			if prev == 'Q' || prev == 'T' {
+				qx, qy = 2*cx-ctrlX, 2*cy-ctrlY
+			}
+			p.QuadTo(qx, qy, ox+n[0], oy+n[1])
+			ctrlX, ctrlY = qx, qy
+			cx, cy = ox+n[0], oy+n[1]
+		case 'A', 'a':
+			radii, ok := s.numbers(3)
+			if !ok {
+				return
+			}
+			large, ok1 := s.flag()
+			sweep, ok2 := s.flag()
+			end, ok3 := s.numbers(2)
+			if !ok1 || !ok2 || !ok3 {
+				return
+			}
+			x, y := ox+end[0], oy+end[1]
+			arcTo(p, cx, cy, radii[0], radii[1], radii[2], large, sweep, x, y)
+			cx, cy = x, y
+		case 'Z', 'z':
+			p.Close()
+			cx, cy = startX, startY
+		default:
+			return
+		}
+		prev = cmd &^ 0x20 // upper case
+
+		next, ok := s.continuation(cmd)
+		if !ok {
+			return
+		}
+		cmd = next
+	}
+}
+
+// continuation returns the next command: an explicit letter, or a repeat
+// of cmd when more numbers follow
+func (s *pathScanner) continuation(cmd byte) (byte, bool) {
+	if next, ok := s.command(); ok {
+		return next, true
+	}
+	s.skipSeparators()
+	if s.pos >= len(s.s) || cmd == 'Z' || cmd == 'z' {
+		return 0, false
+	}
+	return cmd, true
+}
+
+// arcTo converts an SVG endpoint arc to center form (SVG spec F.6.5) and
+// adds it to p
+func arcTo(p *Path, x1, y1, rx, ry, angle float64, large, sweep bool, x2, y2 float64) {
+	if x1 == x2 && y1 == y2 {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.LineTo(x2, y2)
+		return
+	}
+
+	phi := angle * math.Pi / 180
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p := cosPhi*dx + sinPhi*dy
+	y1p := -sinPhi*dx + cosPhi*dy
+
+	// Scale up radii that are too small to reach the end point
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		scale := math.Sqrt(lambda)
+		rx, ry = rx*scale, ry*scale
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+
+	cx := cosPhi*cxp - sinPhi*cyp + (x1+x2)/2
+	cy := sinPhi*cxp + cosPhi*cyp + (y1+y2)/2
+
+	theta1 := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	theta2 := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx)
+	delta := theta2 - theta1
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	p.Ellipse(cx, cy, rx, ry, phi, theta1, theta1+delta, !sweep)
+}
@@ -0,0 +1,124 @@
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Fill paints the inside of every subpath of p onto dst. Open subpaths are
+// closed implicitly. Overlapping subpaths use the nonzero rule.
+func Fill(dst *image.RGBA, p *Path, c color.Color) {
+	if p.Empty() {
+		return
+	}
+	r := newRasterizer(dst)
+	for _, sp := range p.subpaths {
+		if len(sp.points) < 3 {
+			continue
+		}
+		addPolygon(r, sp.points)
+	}
+	r.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+// Stroke paints the outline of p with the given device-space line width,
+// using round joins and butt caps
+func Stroke(dst *image.RGBA, p *Path, width float64, c color.Color) {
+	if p.Empty() || width <= 0 {
+		return
+	}
+	half := width / 2
+	r := newRasterizer(dst)
+	for _, sp := range p.subpaths {
+		points := sp.points
+		if sp.closed && len(points) > 1 {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+		for i := 1; i < len(points); i++ {
+			addSegment(r, points[i-1], points[i], half)
+		}
+		// Round joins cover the gaps between consecutive segments
+		if width > 1 {
+			last := len(points) - 1
+			for i := 1; i < last; i++ {
+				addCircle(r, points[i], half)
+			}
+			if sp.closed && last > 0 {
+				addCircle(r, points[0], half)
+			}
+		}
+	}
+	r.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+func newRasterizer(dst *image.RGBA) *vector.Rasterizer {
+	b := dst.Bounds()
+	r := vector.NewRasterizer(b.Dx(), b.Dy())
+	r.DrawOp = draw.Over
+	return r
+}
+
+// addPolygon adds a closed polygon in its given orientation
+func addPolygon(r *vector.Rasterizer, points []Point) {
+	r.MoveTo(float32(points[0].X), float32(points[0].Y))
+	for _, pt := range points[1:] {
+		r.LineTo(float32(pt.X), float32(pt.Y))
+	}
+	r.ClosePath()
+}
+
+// addOriented adds a polygon wound clockwise, so the stroke pieces add up
+// instead of cancelling where they overlap
+func addOriented(r *vector.Rasterizer, points []Point) {
+	area := 0.0
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i].X*points[j].Y - points[j].X*points[i].Y
+	}
+	if area < 0 {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	addPolygon(r, points)
+}
+
+// addSegment adds the rectangle covering a line segment of half-width half
+func addSegment(r *vector.Rasterizer, a, b Point, half float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	nx, ny := -dy/length*half, dx/length*half
+	addOriented(r, []Point{
+		{a.X + nx, a.Y + ny},
+		{b.X + nx, b.Y + ny},
+		{b.X - nx, b.Y - ny},
+		{a.X - nx, a.Y - ny},
+	})
+}
+
+func addCircle(r *vector.Rasterizer, center Point, radius float64) {
+	n := max(8, min(int(radius*2), 64))
+	points := make([]Point, n)
+	for i := range points {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		points[i] = Point{center.X + radius*cos, center.Y + radius*sin}
+	}
+	addOriented(r, points)
+}
+
+// WithAlpha scales the alpha of c by a, for opacity and globalAlpha
+func WithAlpha(c color.Color, a float64) color.Color {
+	if a >= 1 {
+		return c
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(math.Round(float64(n.A) * max(a, 0)))
+	return n
+}
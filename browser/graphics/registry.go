@@ -0,0 +1,72 @@
+package graphics
+
+import (
+	"browser/dom"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Each <canvas> element owns one bitmap, shared by the script that draws
+// into it and the renderer that paints it
+var (
+	canvasesMu sync.Mutex
+	canvases   = map[*dom.Node]*Context{}
+)
+
+// CanvasSize returns the bitmap size given by a canvas element's width and
+// height attributes, falling back to 300x150
+func CanvasSize(node *dom.Node) (int, int) {
+	return sizeAttribute(node, "width", DefaultCanvasWidth), sizeAttribute(node, "height", DefaultCanvasHeight)
+}
+
+func sizeAttribute(node *dom.Node, name string, fallback int) int {
+	value, ok := node.Attributes[name]
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return fallback
+	}
+	return n
+}
+
+// CanvasFor returns the context backing a canvas element, creating it on
+// first use. A changed width or height attribute resizes and clears it.
+func CanvasFor(node *dom.Node) *Context {
+	canvasesMu.Lock()
+	defer canvasesMu.Unlock()
+
+	w, h := CanvasSize(node)
+	ctx, ok := canvases[node]
+	if !ok {
+		ctx = NewContext(w, h)
+		canvases[node] = ctx
+		return ctx
+	}
+	if cw, ch := ctx.Size(); cw != w || ch != h {
+		ctx.Resize(w, h)
+	}
+	return ctx
+}
+
+// LookupCanvas returns the context for a canvas element, or nil if no
+// script has drawn into it
+func LookupCanvas(node *dom.Node) *Context {
+	canvasesMu.Lock()
+	defer canvasesMu.Unlock()
+	return canvases[node]
+}
+
+// ReleaseCanvases drops the bitmaps of canvases inside document, called
+// when a page is replaced
+func ReleaseCanvases(document *dom.Node) {
+	canvasesMu.Lock()
+	defer canvasesMu.Unlock()
+	for node := range canvases {
+		if node.OwnerDocument() == document {
+			delete(canvases, node)
+		}
+	}
+}
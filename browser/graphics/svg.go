@@ -0,0 +1,429 @@
+package graphics
+
+import (
+	"browser/css"
+	"browser/dom"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgStyle is the inherited presentation state while walking an SVG tree
+type svgStyle struct {
+	fill          color.Color // nil for "none"
+	stroke        color.Color
+	currentColor  color.Color
+	strokeWidth   float64
+	fillOpacity   float64
+	strokeOpacity float64
+	opacity       float64 // product of the opacity of this element and its ancestors
+	font          Font
+	textAnchor    string
+}
+
+// svgRenderer draws one <svg> element into a bitmap
+type svgRenderer struct {
+	img            *image.RGBA
+	viewportWidth  float64 // user-space viewport size, for percentage lengths
+	viewportHeight float64
+}
+
+// RenderSVG rasterizes an inline <svg> element at width x height pixels,
+// mapping its viewBox onto that area
+func RenderSVG(svg *dom.Node, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+	if width <= 0 || height <= 0 {
+		return img
+	}
+
+	r := &svgRenderer{img: img, viewportWidth: float64(width), viewportHeight: float64(height)}
+	m := Identity()
+	if vb, ok := parseViewBox(svg.Attributes["viewBox"]); ok {
+		m = viewBoxTransform(vb, float64(width), float64(height), svg.Attributes["preserveAspectRatio"])
+		r.viewportWidth, r.viewportHeight = vb[2], vb[3]
+	}
+
+	style := svgStyle{
+		fill:          color.Black,
+		currentColor:  color.Black,
+		strokeWidth:   1,
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		font:          Font{Size: 16},
+		textAnchor:    "start",
+	}
+	style = r.applyPresentation(svg, style)
+	r.renderChildren(svg, m, style)
+	return img
+}
+
+// parseViewBox reads "min-x min-y width height"
+func parseViewBox(value string) ([4]float64, bool) {
+	var vb [4]float64
+	nums := parseNumberList(value)
+	if len(nums) != 4 || nums[2] <= 0 || nums[3] <= 0 {
+		return vb, false
+	}
+	copy(vb[:], nums)
+	return vb, true
+}
+
+// ViewBoxSize returns the width and height of an svg element's viewBox
+func ViewBoxSize(svg *dom.Node) (float64, float64, bool) {
+	vb, ok := parseViewBox(svg.Attributes["viewBox"])
+	return vb[2], vb[3], ok
+}
+
+// viewBoxTransform maps the viewBox onto the viewport. Only the default
+// xMidYMid meet and "none" are supported.
+func viewBoxTransform(vb [4]float64, width, height float64, preserve string) Matrix {
+	sx, sy := width/vb[2], height/vb[3]
+	if strings.TrimSpace(preserve) == "none" {
+		return Identity().Scale(sx, sy).Translate(-vb[0], -vb[1])
+	}
+	s := min(sx, sy)
+	tx := (width - vb[2]*s) / 2
+	ty := (height - vb[3]*s) / 2
+	return Identity().Translate(tx, ty).Scale(s, s).Translate(-vb[0], -vb[1])
+}
+
+func (r *svgRenderer) renderChildren(node *dom.Node, m Matrix, style svgStyle) {
+	for _, child := range node.Children {
+		if child.Type == dom.Element {
+			r.renderElement(child, m, style)
+		}
+	}
+}
+
+func (r *svgRenderer) renderElement(node *dom.Node, m Matrix, style svgStyle) {
+	if strings.TrimSpace(styleProperty(node, "display")) == "none" {
+		return
+	}
+	if t, ok := node.Attributes["transform"]; ok {
+		m = m.Multiply(ParseTransform(t))
+	}
+	style = r.applyPresentation(node, style)
+
+	p := &Path{M: m}
+	switch node.TagName {
+	case "g", "a", "svg":
+		r.renderChildren(node, m, style)
+		return
+	case "rect":
+		x, y := r.lengthX(node, "x"), r.lengthY(node, "y")
+		w, h := r.lengthX(node, "width"), r.lengthY(node, "height")
+		if w <= 0 || h <= 0 {
+			return
+		}
+		rx, rxOK := r.length(node, "rx", r.viewportWidth)
+		ry, ryOK := r.length(node, "ry", r.viewportHeight)
+		if !rxOK {
+			rx = ry
+		}
+		if !ryOK {
+			ry = rx
+		}
+		roundedRect(p, x, y, w, h, min(rx, w/2), min(ry, h/2))
+	case "circle":
+		radius, _ := r.length(node, "r", math.Hypot(r.viewportWidth, r.viewportHeight)/math.Sqrt2)
+		if radius <= 0 {
+			return
+		}
+		p.Arc(r.lengthX(node, "cx"), r.lengthY(node, "cy"), radius, 0, 2*math.Pi, false)
+		p.Close()
+	case "ellipse":
+		rx, ry := r.lengthX(node, "rx"), r.lengthY(node, "ry")
+		if rx <= 0 || ry <= 0 {
+			return
+		}
+		p.Ellipse(r.lengthX(node, "cx"), r.lengthY(node, "cy"), rx, ry, 0, 0, 2*math.Pi, false)
+		p.Close()
+	case "line":
+		p.MoveTo(r.lengthX(node, "x1"), r.lengthY(node, "y1"))
+		p.LineTo(r.lengthX(node, "x2"), r.lengthY(node, "y2"))
+		// Lines have no interior
+		style.fill = nil
+	case "polyline", "polygon":
+		nums := parseNumberList(node.Attributes["points"])
+		for i := 0; i+1 < len(nums); i += 2 {
+			if i == 0 {
+				p.MoveTo(nums[i], nums[i+1])
+			} else {
+				p.LineTo(nums[i], nums[i+1])
+			}
+		}
+		if node.TagName == "polygon" {
+			p.Close()
+		}
+	case "path":
+		ParsePathData(p, node.Attributes["d"])
+	case "text":
+		r.renderText(node, m, style)
+		return
+	default:
+		// defs, gradients, clipPath and unknown elements draw nothing
+		return
+	}
+
+	r.paint(p, m, style)
+}
+
+// paint fills then strokes a shape, as SVG's default paint-order does
+func (r *svgRenderer) paint(p *Path, m Matrix, style svgStyle) {
+	if style.fill != nil {
+		Fill(r.img, p, WithAlpha(style.fill, style.fillOpacity*style.opacity))
+	}
+	if style.stroke != nil && style.strokeWidth > 0 {
+		Stroke(r.img, p, style.strokeWidth*m.ScaleFactor(), WithAlpha(style.stroke, style.strokeOpacity*style.opacity))
+	}
+}
+
+func (r *svgRenderer) renderText(node *dom.Node, m Matrix, style svgStyle) {
+	text := strings.Join(strings.Fields(textContent(node)), " ")
+	if text == "" || style.fill == nil {
+		return
+	}
+	x, y := r.lengthX(node, "x"), r.lengthY(node, "y")
+
+	width := MeasureText(text, style.font)
+	switch style.textAnchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+
+	f := style.font
+	f.Size *= m.ScaleFactor()
+	dx, dy := m.Apply(x, y)
+	DrawText(r.img, text, dx, dy, f, "alphabetic", WithAlpha(style.fill, style.fillOpacity*style.opacity))
+}
+
+func textContent(node *dom.Node) string {
+	if node.Type == dom.Text {
+		return node.Text
+	}
+	var sb strings.Builder
+	for _, child := range node.Children {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+// roundedRect adds a rectangle whose corners are quarter ellipses
+func roundedRect(p *Path, x, y, w, h, rx, ry float64) {
+	if rx <= 0 || ry <= 0 {
+		p.Rect(x, y, w, h)
+		return
+	}
+	p.MoveTo(x+rx, y)
+	p.LineTo(x+w-rx, y)
+	p.Ellipse(x+w-rx, y+ry, rx, ry, 0, -math.Pi/2, 0, false)
+	p.LineTo(x+w, y+h-ry)
+	p.Ellipse(x+w-rx, y+h-ry, rx, ry, 0, 0, math.Pi/2, false)
+	p.LineTo(x+rx, y+h)
+	p.Ellipse(x+rx, y+h-ry, rx, ry, 0, math.Pi/2, math.Pi, false)
+	p.LineTo(x, y+ry)
+	p.Ellipse(x+rx, y+ry, rx, ry, 0, math.Pi, 3*math.Pi/2, false)
+	p.Close()
+}
+
+// applyPresentation reads the fill, stroke, opacity and font properties an
+// element sets, from attributes or its style attribute (which wins)
+func (r *svgRenderer) applyPresentation(node *dom.Node, style svgStyle) svgStyle {
+	if v := styleProperty(node, "color"); v != "" {
+		if c := css.ParseColor(v); c != nil {
+			style.currentColor = c
+		}
+	}
+	if v := styleProperty(node, "fill"); v != "" {
+		style.fill = parsePaint(v, style.fill, style.currentColor)
+	}
+	if v := styleProperty(node, "stroke"); v != "" {
+		style.stroke = parsePaint(v, style.stroke, style.currentColor)
+	}
+	if v := styleProperty(node, "stroke-width"); v != "" {
+		if w, ok := parseLength(v, r.viewportWidth); ok && w >= 0 {
+			style.strokeWidth = w
+		}
+	}
+	if v, ok := parseOpacity(styleProperty(node, "fill-opacity")); ok {
+		style.fillOpacity = v
+	}
+	if v, ok := parseOpacity(styleProperty(node, "stroke-opacity")); ok {
+		style.strokeOpacity = v
+	}
+	if v, ok := parseOpacity(styleProperty(node, "opacity")); ok {
+		style.opacity *= v
+	}
+	if v := styleProperty(node, "font-size"); v != "" {
+		if size, ok := parseLength(v, style.font.Size); ok && size > 0 {
+			style.font.Size = size
+		}
+	}
+	if v := styleProperty(node, "font-weight"); v != "" {
+		weight, err := strconv.Atoi(v)
+		style.font.Bold = v == "bold" || v == "bolder" || err == nil && weight >= 600
+	}
+	if v := styleProperty(node, "font-style"); v != "" {
+		style.font.Italic = v == "italic" || v == "oblique"
+	}
+	if v := styleProperty(node, "font-family"); v != "" {
+		v = strings.ToLower(v)
+		style.font.Monospace = strings.Contains(v, "monospace") || strings.Contains(v, "courier")
+	}
+	if v := styleProperty(node, "text-anchor"); v != "" {
+		style.textAnchor = v
+	}
+	return style
+}
+
+// styleProperty returns a presentation property from the style attribute,
+// falling back to the attribute of the same name
+func styleProperty(node *dom.Node, name string) string {
+	if style, ok := node.Attributes["style"]; ok {
+		for decl := range strings.SplitSeq(style, ";") {
+			key, value, found := strings.Cut(decl, ":")
+			if found && strings.TrimSpace(strings.ToLower(key)) == name {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return strings.TrimSpace(node.Attributes[name])
+}
+
+// parsePaint resolves a fill or stroke value. Gradients and patterns are
+// not supported and paint nothing.
+func parsePaint(value string, inherited, currentColor color.Color) color.Color {
+	switch strings.ToLower(value) {
+	case "none":
+		return nil
+	case "inherit":
+		return inherited
+	case "currentcolor":
+		return currentColor
+	}
+	if strings.HasPrefix(value, "url(") {
+		return nil
+	}
+	if c := css.ParseColor(value); c != nil {
+		return c
+	}
+	return inherited
+}
+
+func parseOpacity(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value = strings.TrimSuffix(value, "%")
+		scale = 0.01
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return math.Max(0, math.Min(1, v*scale)), true
+}
+
+func (r *svgRenderer) lengthX(node *dom.Node, name string) float64 {
+	v, _ := r.length(node, name, r.viewportWidth)
+	return v
+}
+
+func (r *svgRenderer) lengthY(node *dom.Node, name string) float64 {
+	v, _ := r.length(node, name, r.viewportHeight)
+	return v
+}
+
+// length reads a length attribute; percentages are relative to ref
+func (r *svgRenderer) length(node *dom.Node, name string, ref float64) (float64, bool) {
+	value, ok := node.Attributes[name]
+	if !ok {
+		return 0, false
+	}
+	return parseLength(value, ref)
+}
+
+// parseLength accepts user units, px, pt, em and percentages of ref
+func parseLength(value string, ref float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(value, "%"):
+		value, scale = strings.TrimSuffix(value, "%"), ref/100
+	case strings.HasSuffix(value, "px"):
+		value = strings.TrimSuffix(value, "px")
+	case strings.HasSuffix(value, "pt"):
+		value, scale = strings.TrimSuffix(value, "pt"), 4.0/3
+	case strings.HasSuffix(value, "em"):
+		value, scale = strings.TrimSuffix(value, "em"), 16
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// parseNumberList splits a list of numbers separated by commas and/or
+// whitespace, as used by points and viewBox
+func parseNumberList(value string) []float64 {
+	s := &pathScanner{s: value}
+	var nums []float64
+	for {
+		n, ok := s.number()
+		if !ok {
+			return nums
+		}
+		nums = append(nums, n)
+	}
+}
+
+// ParseTransform reads an SVG transform list: matrix, translate, scale,
+// rotate, skewX and skewY
+func ParseTransform(value string) Matrix {
+	m := Identity()
+	rest := value
+	for {
+		open := strings.Index(rest, "(")
+		closing := strings.Index(rest, ")")
+		if open < 0 || closing < open {
+			return m
+		}
+		name := strings.TrimSpace(strings.Trim(rest[:open], ", \t\n"))
+		args := parseNumberList(rest[open+1 : closing])
+		rest = rest[closing+1:]
+
+		arg := func(i int, fallback float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return fallback
+		}
+		switch name {
+		case "matrix":
+			if len(args) == 6 {
+				m = m.Multiply(Matrix{A: args[0], B: args[1], C: args[2], D: args[3], E: args[4], F: args[5]})
+			}
+		case "translate":
+			m = m.Translate(arg(0, 0), arg(1, 0))
+		case "scale":
+			sx := arg(0, 1)
+			m = m.Scale(sx, arg(1, sx))
+		case "rotate":
+			angle := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			m = m.Translate(cx, cy).Rotate(angle).Translate(-cx, -cy)
+		case "skewX":
+			m = m.Multiply(Matrix{A: 1, C: math.Tan(arg(0, 0) * math.Pi / 180), D: 1})
+		case "skewY":
+			m = m.Multiply(Matrix{A: 1, B: math.Tan(arg(0, 0) * math.Pi / 180), D: 1})
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Font describes the face used by fillText and SVG <text>
+type Font struct {
+	Size      float64
+	Bold      bool
+	Italic    bool
+	Monospace bool
+}
+
+// DefaultFont matches the canvas default "10px sans-serif"
+var DefaultFont = Font{Size: 10}
+
+// ParseFont reads a CSS font shorthand such as "italic bold 16px serif".
+// Returns false if there is no size.
+func ParseFont(s string) (Font, bool) {
+	var f Font
+	found := false
+	fields := strings.Fields(s)
+	for i, field := range fields {
+		lower := strings.ToLower(field)
+		switch lower {
+		case "italic", "oblique":
+			f.Italic = true
+			continue
+		case "bold", "bolder", "600", "700", "800", "900":
+			f.Bold = true
+			continue
+		}
+		if size, ok := parseFontSize(strings.SplitN(lower, "/", 2)[0]); ok {
+			f.Size = size
+			found = true
+			family := strings.ToLower(strings.Join(fields[i+1:], " "))
+			f.Monospace = strings.Contains(family, "monospace") || strings.Contains(family, "courier")
+			break
+		}
+	}
+	return f, found
+}
+
+func parseFontSize(s string) (float64, bool) {
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "px"):
+		s = strings.TrimSuffix(s, "px")
+	case strings.HasSuffix(s, "pt"):
+		s, scale = strings.TrimSuffix(s, "pt"), 4.0/3
+	case strings.HasSuffix(s, "em"):
+		s, scale = strings.TrimSuffix(s, "em"), 16
+	default:
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// String formats f the way the canvas font property reports it
+func (f Font) String() string {
+	var parts []string
+	if f.Italic {
+		parts = append(parts, "italic")
+	}
+	if f.Bold {
+		parts = append(parts, "bold")
+	}
+	parts = append(parts, strconv.FormatFloat(f.Size, 'f', -1, 64)+"px")
+	if f.Monospace {
+		parts = append(parts, "monospace")
+	} else {
+		parts = append(parts, "sans-serif")
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	fontsOnce sync.Once
+	fonts     map[[3]bool]*opentype.Font
+	faceMu    sync.Mutex
+	faces     = map[Font]font.Face{}
+)
+
+func loadFonts() {
+	parse := func(data []byte) *opentype.Font {
+		f, err := opentype.Parse(data)
+		if err != nil {
+			panic(err)
+		}
+		return f
+	}
+	fonts = map[[3]bool]*opentype.Font{
+		{false, false, false}: parse(goregular.TTF),
+		{true, false, false}:  parse(gobold.TTF),
+		{false, true, false}:  parse(goitalic.TTF),
+		{true, true, false}:   parse(gobolditalic.TTF),
+		{false, false, true}:  parse(gomono.TTF),
+	}
+}
+
+// face returns a cached face for f. Faces are not safe for concurrent use,
+// so callers hold faceMu while using it.
+func face(f Font) font.Face {
+	fontsOnce.Do(loadFonts)
+	if fc, ok := faces[f]; ok {
+		return fc
+	}
+	otf := fonts[[3]bool{f.Bold, f.Italic, false}]
+	if f.Monospace {
+		otf = fonts[[3]bool{false, false, true}]
+	}
+	fc, err := opentype.NewFace(otf, &opentype.FaceOptions{Size: f.Size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil
+	}
+	faces[f] = fc
+	return fc
+}
+
+// MeasureText returns the advance width of text in f
+func MeasureText(text string, f Font) float64 {
+	if f.Size <= 0 {
+		return 0
+	}
+	faceMu.Lock()
+	defer faceMu.Unlock()
+	fc := face(f)
+	if fc == nil {
+		return 0
+	}
+	return float64(font.MeasureString(fc, text)) / 64
+}
+
+// DrawText draws text with its left edge at x and the given baseline
+// ("alphabetic", "top", "middle", "bottom") at y, all in device pixels
+func DrawText(dst *image.RGBA, text string, x, y float64, f Font, baseline string, c color.Color) {
+	if f.Size <= 0 || text == "" {
+		return
+	}
+	faceMu.Lock()
+	defer faceMu.Unlock()
+	fc := face(f)
+	if fc == nil {
+		return
+	}
+
+	metrics := fc.Metrics()
+	ascent := float64(metrics.Ascent) / 64
+	descent := float64(metrics.Descent) / 64
+	switch baseline {
+	case "top", "hanging":
+		y += ascent
+	case "middle":
+		y += (ascent - descent) / 2
+	case "bottom", "ideographic":
+		y -= descent
+	}
+
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: fc,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)},
+	}
+	d.DrawString(text)
+}
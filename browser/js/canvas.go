@@ -0,0 +1,246 @@
+package js
+
+import (
+	"browser/dom"
+	"browser/graphics"
+	"image"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// setupCanvasElement adds the HTMLCanvasElement API: width, height and
+// getContext('2d')
+func (rt *JSRuntime) setupCanvasElement(obj *goja.Object, node *dom.Node) {
+	sizeProperty := func(name string, index int) {
+		obj.DefineAccessorProperty(name,
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				w, h := graphics.CanvasSize(node)
+				return rt.vm.ToValue([2]int{w, h}[index])
+			}),
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				if len(call.Arguments) > 0 {
+					if node.Attributes == nil {
+						node.Attributes = make(map[string]string)
+					}
+					node.Attributes[name] = strconv.FormatInt(call.Arguments[0].ToInteger(), 10)
+					node.MarkDirty()
+					rt.resizeCanvas(node)
+				}
+				return goja.Undefined()
+			}),
+			goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+	sizeProperty("width", 0)
+	sizeProperty("height", 1)
+
+	obj.Set("getContext", func(call goja.FunctionCall) goja.Value {
+		if call.Argument(0).String() != "2d" {
+			return goja.Null()
+		}
+		return rt.canvasContext(obj, node)
+	})
+}
+
+// resizeCanvas applies a changed width or height attribute, which clears
+// the bitmap, and relays out the page for the new size
+func (rt *JSRuntime) resizeCanvas(node *dom.Node) {
+	if graphics.LookupCanvas(node) != nil {
+		graphics.CanvasFor(node)
+	}
+	if rt.onReflow != nil {
+		rt.onReflow()
+	}
+}
+
+// canvasContext returns the CanvasRenderingContext2D for a canvas element.
+// Repeated getContext calls return the same object.
+func (rt *JSRuntime) canvasContext(canvasObj *goja.Object, node *dom.Node) goja.Value {
+	if cached, ok := rt.canvasContexts[node]; ok {
+		return cached
+	}
+
+	ctx := graphics.CanvasFor(node)
+	obj := rt.vm.NewObject()
+	obj.Set("canvas", canvasObj)
+
+	// draw wraps a drawing call so the page repaints once the script returns
+	draw := func(fn func(args []float64)) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			args := make([]float64, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				args[i] = arg.ToFloat()
+			}
+			fn(args)
+			rt.canvasDirty = true
+			return goja.Undefined()
+		}
+	}
+	// arg returns the i-th number, or 0 when the script passed fewer
+	arg := func(args []float64, i int) float64 {
+		if i < len(args) {
+			return args[i]
+		}
+		return 0
+	}
+
+	obj.Set("fillRect", draw(func(a []float64) { ctx.FillRect(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3)) }))
+	obj.Set("strokeRect", draw(func(a []float64) { ctx.StrokeRect(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3)) }))
+	obj.Set("clearRect", draw(func(a []float64) { ctx.ClearRect(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3)) }))
+
+	obj.Set("beginPath", draw(func(a []float64) { ctx.BeginPath() }))
+	obj.Set("closePath", draw(func(a []float64) { ctx.ClosePath() }))
+	obj.Set("moveTo", draw(func(a []float64) { ctx.MoveTo(arg(a, 0), arg(a, 1)) }))
+	obj.Set("lineTo", draw(func(a []float64) { ctx.LineTo(arg(a, 0), arg(a, 1)) }))
+	obj.Set("quadraticCurveTo", draw(func(a []float64) {
+		ctx.QuadraticCurveTo(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3))
+	}))
+	obj.Set("bezierCurveTo", draw(func(a []float64) {
+		ctx.BezierCurveTo(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3), arg(a, 4), arg(a, 5))
+	}))
+	obj.Set("rect", draw(func(a []float64) { ctx.Rect(arg(a, 0), arg(a, 1), arg(a, 2), arg(a, 3)) }))
+	obj.Set("arc", func(call goja.FunctionCall) goja.Value {
+		ctx.Arc(call.Argument(0).ToFloat(), call.Argument(1).ToFloat(), call.Argument(2).ToFloat(),
+			call.Argument(3).ToFloat(), call.Argument(4).ToFloat(), call.Argument(5).ToBoolean())
+		return goja.Undefined()
+	})
+	obj.Set("ellipse", func(call goja.FunctionCall) goja.Value {
+		ctx.Ellipse(call.Argument(0).ToFloat(), call.Argument(1).ToFloat(), call.Argument(2).ToFloat(),
+			call.Argument(3).ToFloat(), call.Argument(4).ToFloat(), call.Argument(5).ToFloat(),
+			call.Argument(6).ToFloat(), call.Argument(7).ToBoolean())
+		return goja.Undefined()
+	})
+	obj.Set("fill", draw(func(a []float64) { ctx.Fill() }))
+	obj.Set("stroke", draw(func(a []float64) { ctx.Stroke() }))
+
+	obj.Set("save", draw(func(a []float64) { ctx.Save() }))
+	obj.Set("restore", draw(func(a []float64) { ctx.Restore() }))
+	obj.Set("translate", draw(func(a []float64) { ctx.Translate(arg(a, 0), arg(a, 1)) }))
+	obj.Set("scale", draw(func(a []float64) { ctx.Scale(arg(a, 0), arg(a, 1)) }))
+	obj.Set("rotate", draw(func(a []float64) { ctx.Rotate(arg(a, 0)) }))
+	obj.Set("transform", draw(func(a []float64) {
+		ctx.Transform(graphics.Matrix{A: arg(a, 0), B: arg(a, 1), C: arg(a, 2), D: arg(a, 3), E: arg(a, 4), F: arg(a, 5)})
+	}))
+	obj.Set("setTransform", draw(func(a []float64) {
+		ctx.SetTransform(graphics.Matrix{A: arg(a, 0), B: arg(a, 1), C: arg(a, 2), D: arg(a, 3), E: arg(a, 4), F: arg(a, 5)})
+	}))
+	obj.Set("resetTransform", draw(func(a []float64) { ctx.SetTransform(graphics.Identity()) }))
+
+	obj.Set("fillText", func(call goja.FunctionCall) goja.Value {
+		ctx.FillText(call.Argument(0).String(), call.Argument(1).ToFloat(), call.Argument(2).ToFloat())
+		rt.canvasDirty = true
+		return goja.Undefined()
+	})
+	obj.Set("strokeText", func(call goja.FunctionCall) goja.Value {
+		ctx.StrokeText(call.Argument(0).String(), call.Argument(1).ToFloat(), call.Argument(2).ToFloat())
+		rt.canvasDirty = true
+		return goja.Undefined()
+	})
+	obj.Set("measureText", func(call goja.FunctionCall) goja.Value {
+		metrics := rt.vm.NewObject()
+		metrics.Set("width", ctx.MeasureText(call.Argument(0).String()))
+		return metrics
+	})
+
+	obj.Set("drawImage", func(call goja.FunctionCall) goja.Value {
+		src := rt.imageSource(call.Argument(0))
+		if src == nil {
+			return goja.Undefined()
+		}
+		b := src.Bounds()
+		sw, sh := float64(b.Dx()), float64(b.Dy())
+
+		n := make([]float64, len(call.Arguments)-1)
+		for i := range n {
+			n[i] = call.Arguments[i+1].ToFloat()
+		}
+		switch len(n) {
+		case 2: // drawImage(img, dx, dy)
+			ctx.DrawImage(src, 0, 0, sw, sh, n[0], n[1], sw, sh)
+		case 4: // drawImage(img, dx, dy, dw, dh)
+			ctx.DrawImage(src, 0, 0, sw, sh, n[0], n[1], n[2], n[3])
+		case 8: // drawImage(img, sx, sy, sw, sh, dx, dy, dw, dh)
+			ctx.DrawImage(src, n[0], n[1], n[2], n[3], n[4], n[5], n[6], n[7])
+		default:
+			return goja.Undefined()
+		}
+		rt.canvasDirty = true
+		return goja.Undefined()
+	})
+
+	stringProperty := func(name string, get func() string, set func(string)) {
+		obj.DefineAccessorProperty(name,
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				return rt.vm.ToValue(get())
+			}),
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				if len(call.Arguments) > 0 {
+					set(call.Arguments[0].String())
+				}
+				return goja.Undefined()
+			}),
+			goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+	numberProperty := func(name string, get func() float64, set func(float64)) {
+		obj.DefineAccessorProperty(name,
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				return rt.vm.ToValue(get())
+			}),
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				if len(call.Arguments) > 0 {
+					set(call.Arguments[0].ToFloat())
+				}
+				return goja.Undefined()
+			}),
+			goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+
+	stringProperty("fillStyle", ctx.FillStyle, ctx.SetFillStyle)
+	stringProperty("strokeStyle", ctx.StrokeStyle, ctx.SetStrokeStyle)
+	stringProperty("font", ctx.Font, ctx.SetFont)
+	stringProperty("textAlign", ctx.TextAlign, ctx.SetTextAlign)
+	stringProperty("textBaseline", ctx.TextBaseline, ctx.SetTextBaseline)
+	numberProperty("lineWidth", ctx.LineWidth, ctx.SetLineWidth)
+	numberProperty("globalAlpha", ctx.GlobalAlpha, ctx.SetGlobalAlpha)
+
+	rt.canvasContexts[node] = obj
+	return obj
+}
+
+// imageSource returns the bitmap behind a drawImage argument: an <img>
+// element loaded through the image loader, or another <canvas>
+func (rt *JSRuntime) imageSource(val goja.Value) image.Image {
+	node := unwrapNode(rt, val)
+	if node == nil {
+		return nil
+	}
+	switch node.TagName {
+	case dom.TagCanvas:
+		if ctx := graphics.LookupCanvas(node); ctx != nil {
+			return ctx.Snapshot()
+		}
+	case dom.TagImg:
+		src := node.Attributes["src"]
+		if src != "" && rt.imageLoader != nil {
+			return rt.imageLoader(src)
+		}
+	}
+	return nil
+}
+
+// flushCanvas repaints the page once after a script has drawn on a canvas
+func (rt *JSRuntime) flushCanvas() {
+	if !rt.canvasDirty {
+		return
+	}
+	rt.canvasDirty = false
+	if rt.onReflow != nil {
+		rt.onReflow()
+	}
+}
+
+// SetImageLoader sets how drawImage loads <img> sources. The loader gets
+// the src attribute as written and may block.
+func (rt *JSRuntime) SetImageLoader(loader func(src string) image.Image) {
+	rt.imageLoader = loader
+}
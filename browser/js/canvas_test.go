@@ -0,0 +1,100 @@
+package js
+
+import (
+	"browser/dom"
+	"browser/graphics"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func canvasPage(t *testing.T, html string) (*JSRuntime, *dom.Node, *int) {
+	t.Helper()
+	doc := dom.Parse(strings.NewReader(html))
+	canvas := dom.FindElementsByTagName(doc, dom.TagCanvas)
+	require.NotNil(t, canvas)
+	t.Cleanup(func() { graphics.ReleaseCanvases(doc) })
+
+	reflows := 0
+	rt := NewJSRuntime(doc, func() { reflows++ })
+	return rt, canvas, &reflows
+}
+
+func TestCanvasDrawing(t *testing.T) {
+	rt, canvas, reflows := canvasPage(t, `<canvas id="c" width="50" height="40"></canvas>`)
+
+	require.NoError(t, rt.Execute(`
+		var c = document.getElementById("c");
+		var ctx = c.getContext("2d");
+		ctx.fillStyle = "red";
+		ctx.fillRect(0, 0, 10, 10);
+		ctx.beginPath();
+		ctx.arc(30, 20, 5, 0, Math.PI * 2);
+		ctx.fillStyle = "#0000ff";
+		ctx.fill();
+	`))
+	assert.Equal(t, 1, *reflows, "drawing repaints once per script")
+
+	ctx := graphics.LookupCanvas(canvas)
+	require.NotNil(t, ctx)
+	img := ctx.Snapshot()
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(5, 5))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, img.RGBAAt(30, 20))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(45, 35))
+}
+
+func TestCanvasProperties(t *testing.T) {
+	rt, _, _ := canvasPage(t, `<canvas id="c"></canvas>`)
+
+	val, err := rt.vm.RunString(`
+		var c = document.getElementById("c");
+		var ctx = c.getContext("2d");
+		ctx.lineWidth = 3;
+		ctx.fillStyle = "bogus";
+		[c.width, c.height, ctx.lineWidth, ctx.fillStyle, ctx.canvas === c,
+		 c.getContext("2d") === ctx, c.getContext("webgl") === null].join(",")
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, "300,150,3,#000000,true,true,true", val.String())
+}
+
+func TestCanvasResizeClears(t *testing.T) {
+	rt, canvas, _ := canvasPage(t, `<canvas id="c" width="20" height="20"></canvas>`)
+
+	require.NoError(t, rt.Execute(`
+		var c = document.getElementById("c");
+		var ctx = c.getContext("2d");
+		ctx.fillRect(0, 0, 20, 20);
+		c.width = 30;
+	`))
+	img := graphics.LookupCanvas(canvas).Snapshot()
+	assert.Equal(t, 30, img.Bounds().Dx())
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(5, 5))
+}
+
+func TestCanvasDrawImage(t *testing.T) {
+	rt, canvas, _ := canvasPage(t, `<img id="i" src="dot.png"><canvas id="c" width="20" height="20"></canvas>`)
+
+	var loaded string
+	rt.SetImageLoader(func(src string) image.Image {
+		loaded = src
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		return img
+	})
+
+	require.NoError(t, rt.Execute(`
+		var ctx = document.getElementById("c").getContext("2d");
+		ctx.drawImage(document.getElementById("i"), 5, 5, 10, 10);
+	`))
+	assert.Equal(t, "dot.png", loaded)
+	img := graphics.LookupCanvas(canvas).Snapshot()
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(10, 10))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(2, 2))
+}
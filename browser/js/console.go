@@ -0,0 +1,101 @@
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// consoleLevels are the console methods scripts can call. debug and info
+// are shown like log.
+var consoleLevels = []string{"log", "info", "debug", "warn", "error"}
+
+// setupConsole defines console.log and friends. Messages go to stdout and
+// to the console handler, if one is set.
+func (rt *JSRuntime) setupConsole() {
+	console := rt.vm.NewObject()
+	for _, level := range consoleLevels {
+		console.Set(level, func(call goja.FunctionCall) goja.Value {
+			parts := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				// Strings are shown without quotes when logged
+				if s, ok := arg.Export().(string); ok {
+					parts[i] = s
+				} else {
+					parts[i] = rt.describe(arg)
+				}
+			}
+			rt.consoleMessage(level, strings.Join(parts, " "))
+			return goja.Undefined()
+		})
+	}
+	rt.vm.Set("console", console)
+}
+
+// SetConsoleHandler sets where console messages and uncaught script errors
+// are reported, as level ("log", "info", "debug", "warn" or "error") and
+// text
+func (rt *JSRuntime) SetConsoleHandler(handler func(level, message string)) {
+	rt.onConsole = handler
+}
+
+func (rt *JSRuntime) consoleMessage(level, message string) {
+	fmt.Println(message)
+	if rt.onConsole != nil {
+		rt.onConsole(level, message)
+	}
+}
+
+// reportError shows an error a script threw and nobody caught
+func (rt *JSRuntime) reportError(err error) {
+	fmt.Println("JS error: ", err)
+	if rt.onConsole != nil {
+		rt.onConsole("error", "Uncaught "+err.Error())
+	}
+}
+
+// Evaluate runs an expression typed into the console and describes its
+// value
+func (rt *JSRuntime) Evaluate(expression string) (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	value, err := rt.vm.RunString(expression)
+	rt.flushCanvas()
+	if err != nil {
+		return "", err
+	}
+	return rt.describe(value), nil
+}
+
+// describe formats a value the way the console shows it: strings quoted,
+// elements as their tag and plain objects and arrays as JSON
+func (rt *JSRuntime) describe(value goja.Value) string {
+	if value == nil || goja.IsUndefined(value) {
+		return "undefined"
+	}
+	if goja.IsNull(value) {
+		return "null"
+	}
+	if _, ok := goja.AssertFunction(value); ok {
+		return "ƒ " + value.String()
+	}
+	switch v := value.Export().(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case bool, int64, float64:
+		return value.String()
+	}
+	if obj, ok := value.(*goja.Object); ok {
+		for node, wrapper := range rt.elementCache {
+			if wrapper == obj {
+				return "<" + node.TagName + ">"
+			}
+		}
+	}
+	if data, err := json.Marshal(value.Export()); err == nil {
+		return string(data)
+	}
+	return value.String()
+}
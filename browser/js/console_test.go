@@ -0,0 +1,55 @@
+package js
+
+import (
+	"browser/dom"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleMessages(t *testing.T) {
+	rt := NewJSRuntime(dom.Parse(strings.NewReader(`<p id="p">hi</p>`)), nil)
+	t.Cleanup(func() { ReleaseRuntimes(rt.document) })
+
+	var got []string
+	rt.SetConsoleHandler(func(level, message string) {
+		got = append(got, level+": "+message)
+	})
+	rt.Execute(`
+		console.log("count", 3, {a: [1, 2]});
+		console.warn("careful");
+		undefinedFunction();
+	`)
+	require.Len(t, got, 3)
+	assert.Equal(t, `log: count 3 {"a":[1,2]}`, got[0])
+	assert.Equal(t, "warn: careful", got[1])
+	assert.True(t, strings.HasPrefix(got[2], "error: Uncaught ReferenceError"), got[2])
+}
+
+func TestEvaluate(t *testing.T) {
+	rt := NewJSRuntime(dom.Parse(strings.NewReader(`<p id="p">hi</p>`)), nil)
+	t.Cleanup(func() { ReleaseRuntimes(rt.document) })
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{`1 + 2`, "3"},
+		{`"a" + "b"`, `"ab"`},
+		{`var x = 1`, "undefined"},
+		{`x`, "1"},
+		{`null`, "null"},
+		{`[1, "two"]`, `[1,"two"]`},
+		{`document.getElementById("p")`, "<p>"},
+	}
+	for _, tt := range tests {
+		result, err := rt.Evaluate(tt.expression)
+		require.NoError(t, err, tt.expression)
+		assert.Equal(t, tt.expected, result, tt.expression)
+	}
+
+	_, err := rt.Evaluate(`throw new Error("boom")`)
+	assert.ErrorContains(t, err, "boom")
+}
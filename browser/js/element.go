@@ -32,6 +32,11 @@ func (e *Element) SetAttribute(name, value string) {
 		e.node.Attributes = make(map[string]string)
 	}
 	e.node.Attributes[name] = value
+	e.node.MarkDirty()
+
+	if e.node.TagName == dom.TagCanvas && (name == "width" || name == "height") {
+		e.rt.resizeCanvas(e.node)
+	}
 }
 
 // GetTextContent returns all text content
@@ -41,6 +46,7 @@ func (e *Element) GetTextContent() string {
 
 func (e *Element) SetTextContent(text string) {
 	e.node.Children = []*dom.Node{}
+	e.node.MarkChildrenDirty()
 	if text != "" {
 		textNode := dom.NewText(text)
 		e.node.AppendChild(textNode)
@@ -61,6 +67,7 @@ func (e *Element) GetInnerHTML() string {
 
 func (e *Element) SetInnerHTML(htmlContent string) {
 	e.node.Children = []*dom.Node{}
+	e.node.MarkChildrenDirty()
 
 	parsed := dom.ParseFragment(htmlContent)
 
@@ -86,6 +93,7 @@ func (e *Element) setClasses(classes []string) {
 		e.node.Attributes = make(map[string]string)
 	}
 	e.node.Attributes["class"] = strings.Join(classes, " ")
+	e.node.MarkDirty()
 }
 
 func (e *Element) ClassListAdd(className string) {
@@ -122,6 +130,20 @@ func serializeNode(sb *strings.Builder, node *dom.Node) {
 		return
 	}
 
+	if node.Type == dom.Comment {
+		sb.WriteString("<!--")
+		sb.WriteString(node.Text)
+		sb.WriteString("-->")
+		return
+	}
+
+	if node.Type == dom.Doctype {
+		sb.WriteString("<!DOCTYPE ")
+		sb.WriteString(node.TagName)
+		sb.WriteString(">")
+		return
+	}
+
 	// Handle element nodes - write opening tag
 	sb.WriteString("<")
 	sb.WriteString(node.TagName)
@@ -98,6 +98,23 @@ func TestSerializeNode(t *testing.T) {
 			},
 			expected: "<div>Start <span>middle</span> end</div>",
 		},
+		{
+			name: "comment child",
+			build: func() *dom.Node {
+				div := dom.NewElement("div", nil)
+				div.AppendChild(dom.NewComment(" note "))
+				div.AppendChild(dom.NewText("text"))
+				return div
+			},
+			expected: "<div><!-- note -->text</div>",
+		},
+		{
+			name: "doctype",
+			build: func() *dom.Node {
+				return dom.NewDoctype("html", nil)
+			},
+			expected: "<!DOCTYPE html>",
+		},
 	}
 
 	for _, tt := range tests {
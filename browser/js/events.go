@@ -56,9 +56,25 @@ func (em *EventManager) Dispatch(rt *JSRuntime, node *dom.Node, eventType string
 				event.Set("type", eventType)
 				event.Set("target", rt.wrapElement(node)) // original target
 				event.Set("currentTarget", rt.wrapElement(current))
-				l.callback(goja.Undefined(), event)
+				if _, err := l.callback(goja.Undefined(), event); err != nil {
+					rt.reportError(err)
+				}
 			}
 		}
 		current = current.Parent
 	}
 }
+
+// dispatchAt fires the listeners of node alone, for events that do not
+// bubble
+func (em *EventManager) dispatchAt(rt *JSRuntime, node *dom.Node, eventType string) {
+	for _, l := range em.listeners[node][eventType] {
+		event := rt.vm.NewObject()
+		event.Set("type", eventType)
+		event.Set("target", rt.wrapElement(node))
+		event.Set("currentTarget", rt.wrapElement(node))
+		if _, err := l.callback(goja.Undefined(), event); err != nil {
+			rt.reportError(err)
+		}
+	}
+}
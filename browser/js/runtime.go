@@ -3,7 +3,9 @@ package js
 import (
 	"browser/dom"
 	"fmt"
+	"image"
 	"strings"
+	"sync"
 
 	"github.com/dop251/goja"
 )
@@ -21,30 +23,50 @@ type JSRuntime struct {
 	elementCache        map[*dom.Node]*goja.Object
 	onTitleChange       func(string)
 	beforeUnloadHandler goja.Callable
+	imageLoader         func(src string) image.Image
+	canvasContexts      map[*dom.Node]*goja.Object
+	canvasDirty         bool // a canvas was drawn on since the last repaint
+	onConsole           func(level, message string)
+	onFormState         func(node *dom.Node) (value string, checked bool)
+
+	// mu runs page scripts, event handlers and messages from other frames
+	// one at a time
+	mu sync.Mutex
+
+	// Windows of nested browsing contexts, see window.go
+	window          *goja.Object
+	parent          *JSRuntime
+	framesMu        sync.Mutex
+	frames          map[*dom.Node]*JSRuntime // by <iframe> element
+	proxies         map[*JSRuntime]*goja.Object
+	windowListeners map[string][]goja.Callable
+
+	// Messages posted to this window, delivered in order, see postMessage
+	messagesMu sync.Mutex
+	messages   []postedMessage
+	draining   bool
 }
 
 func NewJSRuntime(document *dom.Node, onReflow func()) *JSRuntime {
 	rt := &JSRuntime{
-		vm:           goja.New(),
-		document:     document,
-		onReflow:     onReflow,
-		Events:       NewEventManager(),
-		elementCache: make(map[*dom.Node]*goja.Object),
+		vm:             goja.New(),
+		document:       document,
+		onReflow:       onReflow,
+		Events:         NewEventManager(),
+		elementCache:   make(map[*dom.Node]*goja.Object),
+		canvasContexts: make(map[*dom.Node]*goja.Object),
+
+		frames:          make(map[*dom.Node]*JSRuntime),
+		proxies:         make(map[*JSRuntime]*goja.Object),
+		windowListeners: make(map[string][]goja.Callable),
 	}
 	rt.setupGlobals()
+	registerRuntime(rt)
 	return rt
 }
 
 func (rt *JSRuntime) setupGlobals() {
-	console := rt.vm.NewObject()
-	console.Set("log", func(call goja.FunctionCall) goja.Value {
-		for _, arg := range call.Arguments {
-			fmt.Print(arg.String(), " ")
-		}
-		fmt.Println()
-		return goja.Undefined()
-	})
-	rt.vm.Set("console", console)
+	rt.setupConsole()
 
 	doc := newDocument(rt, rt.document)
 	docObj := rt.vm.NewObject()
@@ -114,6 +136,27 @@ func (rt *JSRuntime) setupGlobals() {
 		nil,
 		goja.FLAG_FALSE, goja.FLAG_TRUE)
 
+	// document.compatMode is "BackCompat" only in full quirks mode
+	docObj.DefineAccessorProperty("compatMode",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			if rt.document.Mode == dom.QuirksMode {
+				return rt.vm.ToValue("BackCompat")
+			}
+			return rt.vm.ToValue("CSS1Compat")
+		}),
+		nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	docObj.DefineAccessorProperty("characterSet",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			if rt.document.Charset == "" || rt.document.Charset == "utf-8" {
+				return rt.vm.ToValue("UTF-8")
+			}
+			return rt.vm.ToValue(rt.document.Charset)
+		}),
+		nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+
 	rt.vm.Set("document", docObj)
 
 	rt.vm.Set("alert", func(call goja.FunctionCall) goja.Value {
@@ -218,15 +261,23 @@ func (rt *JSRuntime) setupGlobals() {
 		}),
 		goja.FLAG_FALSE, goja.FLAG_TRUE)
 
+	rt.setupWindow(window)
 	rt.vm.Set("window", window)
 
 }
 
 func (rt *JSRuntime) Execute(code string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.execute(code)
+}
+
+func (rt *JSRuntime) execute(code string) error {
 	_, err := rt.vm.RunString(code)
 	if err != nil {
-		fmt.Println("JS error: ", err)
+		rt.reportError(err)
 	}
+	rt.flushCanvas()
 	return err
 }
 
@@ -429,6 +480,24 @@ func (rt *JSRuntime) wrapElement(node *dom.Node) goja.Value {
 			goja.FLAG_FALSE, goja.FLAG_TRUE)
 	}
 
+	if node.TagName == dom.TagCanvas {
+		rt.setupCanvasElement(obj, node)
+	}
+
+	switch node.TagName {
+	case "form", "input", "textarea", "select":
+		rt.setupValidation(obj, node)
+	}
+
+	if node.TagName == dom.TagIFrame {
+		obj.DefineAccessorProperty("contentWindow",
+			rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				return rt.contentWindow(node)
+			}),
+			nil,
+			goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+
 	// Cache before returning
 	rt.elementCache[node] = obj
 
@@ -436,12 +505,16 @@ func (rt *JSRuntime) wrapElement(node *dom.Node) goja.Value {
 }
 
 func (rt *JSRuntime) DispatchClick(node *dom.Node) {
-	inlineExecuted := rt.ExecuteInlineEvent(node, "click")
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	inlineExecuted := rt.executeInlineEvent(node, "click")
 	if inlineExecuted {
 		fmt.Println("inline executed")
 	}
 
 	rt.Events.Dispatch(rt, node, "click")
+	rt.flushCanvas()
 }
 
 func (rt *JSRuntime) SetAlertHandler(handler func(message string)) {
@@ -469,6 +542,12 @@ func (rt *JSRuntime) SetTitleChangeHandler(handler func(string)) {
 }
 
 func (rt *JSRuntime) ExecuteInlineEvent(node *dom.Node, eventType string) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.executeInlineEvent(node, eventType)
+}
+
+func (rt *JSRuntime) executeInlineEvent(node *dom.Node, eventType string) bool {
 	if node == nil || node.Type != dom.Element {
 		return false
 	}
@@ -480,7 +559,7 @@ func (rt *JSRuntime) ExecuteInlineEvent(node *dom.Node, eventType string) bool {
 		return false
 	}
 
-	err := rt.Execute(code)
+	err := rt.execute(code)
 	if err != nil {
 		fmt.Printf("Error executing inline %s: %v\n", eventType, err)
 		return false
@@ -490,6 +569,8 @@ func (rt *JSRuntime) ExecuteInlineEvent(node *dom.Node, eventType string) bool {
 }
 
 func (rt *JSRuntime) CheckBeforeUnload() bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
 	fmt.Println("CheckBeforeUnload called")
 
 	// Check window.onbeforeunload (set via JavaScript)
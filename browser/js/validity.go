@@ -0,0 +1,130 @@
+package js
+
+import (
+	"browser/dom"
+
+	"github.com/dop251/goja"
+)
+
+// SetFormStateHandler sets where scripts learn the value the user gave a
+// form control and whether a checkbox or radio group is checked. Without
+// one, controls are validated as the markup left them.
+func (rt *JSRuntime) SetFormStateHandler(handler func(node *dom.Node) (value string, checked bool)) {
+	rt.onFormState = handler
+}
+
+// formState returns a control's value and checkedness for validation
+func (rt *JSRuntime) formState(node *dom.Node) (string, bool) {
+	if rt.onFormState != nil {
+		return rt.onFormState(node)
+	}
+	if node.TagName == "textarea" {
+		return node.InnerText(), false
+	}
+	_, checked := node.Attributes["checked"]
+	return node.Attributes["value"], checked
+}
+
+// validity checks a control against its constraints
+func (rt *JSRuntime) validity(node *dom.Node) dom.Validity {
+	value, checked := rt.formState(node)
+	return dom.CheckValidity(node, value, checked)
+}
+
+// setupValidation adds the constraint validation API to a form or a form
+// control. reportValidity behaves like checkValidity: the browser only
+// shows validation messages when a submission is blocked.
+func (rt *JSRuntime) setupValidation(obj *goja.Object, node *dom.Node) {
+	check := rt.checkControl
+	if node.TagName == "form" {
+		check = rt.checkForm
+	}
+	checkValidity := func(call goja.FunctionCall) goja.Value {
+		return rt.vm.ToValue(check(node))
+	}
+	obj.Set("checkValidity", checkValidity)
+	obj.Set("reportValidity", checkValidity)
+	if node.TagName == "form" {
+		return
+	}
+
+	obj.Set("setCustomValidity", func(call goja.FunctionCall) goja.Value {
+		node.CustomValidity = ""
+		if len(call.Arguments) > 0 {
+			node.CustomValidity = call.Arguments[0].String()
+		}
+		return goja.Undefined()
+	})
+	obj.DefineAccessorProperty("willValidate",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			return rt.vm.ToValue(dom.WillValidate(node))
+		}),
+		nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+	obj.DefineAccessorProperty("validity",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			return rt.validityState(rt.validity(node))
+		}),
+		nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+	obj.DefineAccessorProperty("validationMessage",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			value, _ := rt.formState(node)
+			return rt.vm.ToValue(dom.ValidationMessage(node, value, rt.validity(node)))
+		}),
+		nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
+
+// validityState builds the ValidityState object scripts see
+func (rt *JSRuntime) validityState(v dom.Validity) goja.Value {
+	state := rt.vm.NewObject()
+	state.Set("valueMissing", v.ValueMissing)
+	state.Set("typeMismatch", v.TypeMismatch)
+	state.Set("patternMismatch", v.PatternMismatch)
+	state.Set("tooLong", v.TooLong)
+	state.Set("tooShort", v.TooShort)
+	state.Set("rangeUnderflow", v.RangeUnderflow)
+	state.Set("rangeOverflow", v.RangeOverflow)
+	state.Set("stepMismatch", v.StepMismatch)
+	state.Set("badInput", v.BadInput)
+	state.Set("customError", v.CustomError)
+	state.Set("valid", v.Valid())
+	return state
+}
+
+// checkControl validates one control, firing invalid at it if it fails
+func (rt *JSRuntime) checkControl(node *dom.Node) bool {
+	if rt.validity(node).Valid() {
+		return true
+	}
+	rt.fireInvalid(node)
+	return false
+}
+
+// checkForm validates every control of a form, firing invalid at each one
+// that fails
+func (rt *JSRuntime) checkForm(form *dom.Node) bool {
+	valid := true
+	for _, control := range dom.FormControls(form) {
+		if !rt.checkControl(control) {
+			valid = false
+		}
+	}
+	return valid
+}
+
+// fireInvalid runs the oninvalid attribute and invalid listeners of a
+// control. The event does not bubble.
+func (rt *JSRuntime) fireInvalid(node *dom.Node) {
+	rt.executeInlineEvent(node, "invalid")
+	rt.Events.dispatchAt(rt, node, "invalid")
+}
+
+// DispatchInvalid fires invalid at a control that blocked a form submission
+func (rt *JSRuntime) DispatchInvalid(node *dom.Node) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.fireInvalid(node)
+	rt.flushCanvas()
+}
@@ -0,0 +1,47 @@
+package js
+
+import (
+	"browser/dom"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraintValidationAPI(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<form id="f">
+		<input id="name" required oninvalid="log.push('inline')">
+		<input id="code" pattern="[A-Z]{3}" value="ABC">
+	</form>`))
+	rt := NewJSRuntime(doc, nil)
+	t.Cleanup(func() { ReleaseRuntimes(rt.document) })
+
+	values := map[string]string{}
+	rt.SetFormStateHandler(func(node *dom.Node) (string, bool) {
+		return values[node.Attributes["id"]], false
+	})
+
+	require.NoError(t, rt.Execute(`
+		var log = [];
+		var name = document.getElementById("name");
+		var code = document.getElementById("code");
+		var form = document.getElementById("f");
+		name.addEventListener("invalid", function(e) { log.push("listener " + e.target.id); });
+		form.addEventListener("invalid", function() { log.push("bubbled"); });
+	`))
+
+	result, err := rt.Evaluate(`[form.checkValidity(), name.validity.valueMissing, name.validationMessage, name.willValidate, log.join(",")]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[false,true,"Please fill out this field.",true,"inline,listener name"]`, result)
+
+	values["name"] = "Ann"
+	result, err = rt.Evaluate(`code.setCustomValidity("Code taken"); [code.checkValidity(), code.validity.customError, code.validationMessage, name.checkValidity()]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[false,true,"Code taken",true]`, result)
+
+	result, err = rt.Evaluate(`code.setCustomValidity(""); [form.checkValidity(), code.validity.valid]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[true,true]`, result)
+	assert.Empty(t, dom.FormControls(doc)[1].CustomValidity)
+}
@@ -0,0 +1,243 @@
+package js
+
+import (
+	"browser/dom"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Every document with scripts has one runtime. Frames find the runtime of
+// the document holding their <iframe> through this registry.
+var (
+	runtimesMu sync.Mutex
+	runtimes   = map[*dom.Node]*JSRuntime{}
+)
+
+// RuntimeFor returns the runtime running the scripts of document, or nil
+func RuntimeFor(document *dom.Node) *JSRuntime {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	return runtimes[document]
+}
+
+// ReleaseRuntimes forgets the runtime of document and of every frame
+// nested in it, called when a page is replaced
+func ReleaseRuntimes(document *dom.Node) {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	for doc, rt := range runtimes {
+		for r := rt; r != nil; r = r.parent {
+			if r.document == document {
+				delete(runtimes, doc)
+				break
+			}
+		}
+	}
+}
+
+func registerRuntime(rt *JSRuntime) {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	runtimes[rt.document] = rt
+}
+
+// SetParent makes rt the window of a document shown in frameElement, an
+// <iframe> of parent's document. Scripts in rt see parent as
+// window.parent, and parent's scripts reach rt through the iframe's
+// contentWindow.
+func (rt *JSRuntime) SetParent(parent *JSRuntime, frameElement *dom.Node) {
+	rt.parent = parent
+	parent.framesMu.Lock()
+	parent.frames[frameElement] = rt
+	parent.framesMu.Unlock()
+}
+
+// top returns the runtime of the outermost document
+func (rt *JSRuntime) top() *JSRuntime {
+	r := rt
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// origin is the scheme, host and port of the document, or "null" when it
+// has no URL
+func (rt *JSRuntime) origin() string {
+	u, err := url.Parse(rt.currentURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "null"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// setupWindow adds what windows use to talk to each other: parent, top,
+// postMessage and message listeners
+func (rt *JSRuntime) setupWindow(window *goja.Object) {
+	rt.window = window
+
+	parent := rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		if rt.parent == nil {
+			return rt.window
+		}
+		return rt.windowProxy(rt.parent)
+	})
+	top := rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		return rt.windowProxy(rt.top())
+	})
+	self := rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		return rt.window
+	})
+	postMessage := func(call goja.FunctionCall) goja.Value {
+		rt.postMessage(rt, call.Argument(0), call.Argument(1).String())
+		return goja.Undefined()
+	}
+	addEventListener := func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 2 {
+			return goja.Undefined()
+		}
+		if callback, ok := goja.AssertFunction(call.Arguments[1]); ok {
+			eventType := call.Arguments[0].String()
+			rt.windowListeners[eventType] = append(rt.windowListeners[eventType], callback)
+		}
+		return goja.Undefined()
+	}
+
+	// Scripts use these both as window.parent and as bare globals
+	global := rt.vm.GlobalObject()
+	for _, obj := range []*goja.Object{window, global} {
+		obj.DefineAccessorProperty("parent", parent, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		obj.DefineAccessorProperty("top", top, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		obj.Set("postMessage", postMessage)
+		obj.Set("addEventListener", addEventListener)
+	}
+	window.DefineAccessorProperty("self", self, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	window.DefineAccessorProperty("origin",
+		rt.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			return rt.vm.ToValue(rt.origin())
+		}),
+		nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
+
+// windowProxy returns the object rt's scripts use for another window. The
+// windows run in separate VMs, so it only carries postMessage.
+func (rt *JSRuntime) windowProxy(target *JSRuntime) goja.Value {
+	if target == rt {
+		return rt.window
+	}
+	if proxy, ok := rt.proxies[target]; ok {
+		return proxy
+	}
+	proxy := rt.vm.NewObject()
+	proxy.Set("postMessage", func(call goja.FunctionCall) goja.Value {
+		rt.postMessage(target, call.Argument(0), call.Argument(1).String())
+		return goja.Undefined()
+	})
+	rt.proxies[target] = proxy
+	return proxy
+}
+
+// contentWindow returns the window of the document in an iframe element,
+// or null before it has loaded
+func (rt *JSRuntime) contentWindow(frameElement *dom.Node) goja.Value {
+	rt.framesMu.Lock()
+	child := rt.frames[frameElement]
+	rt.framesMu.Unlock()
+	if child == nil {
+		return goja.Null()
+	}
+	return rt.windowProxy(child)
+}
+
+// postMessage sends a copy of message from rt to target's message
+// listeners. It is dropped unless targetOrigin is "*" or target's origin;
+// "/" stands for rt's own origin. Delivery happens after the sending
+// script has returned.
+func (rt *JSRuntime) postMessage(target *JSRuntime, message goja.Value, targetOrigin string) {
+	if targetOrigin == "/" {
+		targetOrigin = rt.origin()
+	}
+	if targetOrigin != "*" && targetOrigin != target.origin() {
+		return
+	}
+	// Messages are cloned through JSON, as the windows share no objects
+	var exported any
+	if message != nil && !goja.IsUndefined(message) {
+		exported = message.Export()
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		fmt.Println("postMessage: could not clone message:", err)
+		return
+	}
+	target.queueMessage(postedMessage{data: data, origin: rt.origin(), source: rt})
+}
+
+// postedMessage is a message waiting in its target window's queue
+type postedMessage struct {
+	data   []byte
+	origin string
+	source *JSRuntime
+}
+
+// queueMessage appends m to rt's message queue, starting a goroutine to
+// drain it unless one is already running. One goroutine at a time delivers
+// a window's messages, so they arrive in the order they were posted.
+func (rt *JSRuntime) queueMessage(m postedMessage) {
+	rt.messagesMu.Lock()
+	rt.messages = append(rt.messages, m)
+	start := !rt.draining
+	rt.draining = true
+	rt.messagesMu.Unlock()
+	if start {
+		go rt.drainMessages()
+	}
+}
+
+// drainMessages dispatches queued messages until the queue is empty
+func (rt *JSRuntime) drainMessages() {
+	for {
+		rt.messagesMu.Lock()
+		if len(rt.messages) == 0 {
+			rt.draining = false
+			rt.messagesMu.Unlock()
+			return
+		}
+		m := rt.messages[0]
+		rt.messages = rt.messages[1:]
+		rt.messagesMu.Unlock()
+		rt.dispatchMessage(m.data, m.origin, m.source)
+	}
+}
+
+// dispatchMessage runs window.onmessage and the window's message listeners
+func (rt *JSRuntime) dispatchMessage(data []byte, origin string, source *JSRuntime) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return
+	}
+	event := rt.vm.NewObject()
+	event.Set("type", "message")
+	event.Set("data", rt.vm.ToValue(value))
+	event.Set("origin", origin)
+	event.Set("source", rt.windowProxy(source))
+
+	var callbacks []goja.Callable
+	if handler, ok := goja.AssertFunction(rt.window.Get("onmessage")); ok {
+		callbacks = append(callbacks, handler)
+	}
+	callbacks = append(callbacks, rt.windowListeners["message"]...)
+	for _, callback := range callbacks {
+		if _, err := callback(rt.window, event); err != nil {
+			rt.reportError(err)
+		}
+	}
+	rt.flushCanvas()
+}
@@ -0,0 +1,123 @@
+package js
+
+import (
+	"browser/dom"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// framePair returns the runtimes of a page and of the document in its iframe
+func framePair(t *testing.T, parentURL, childURL string) (*JSRuntime, *JSRuntime) {
+	t.Helper()
+	parentDoc := dom.Parse(strings.NewReader(`<iframe id="f" src="child.html"></iframe>`))
+	childDoc := dom.Parse(strings.NewReader(`<p>child</p>`))
+	t.Cleanup(func() { ReleaseRuntimes(parentDoc) })
+
+	parent := NewJSRuntime(parentDoc, nil)
+	parent.SetCurrentURL(parentURL)
+	child := NewJSRuntime(childDoc, nil)
+	child.SetCurrentURL(childURL)
+	child.SetParent(parent, dom.FindElementsByTagName(parentDoc, dom.TagIFrame))
+	return parent, child
+}
+
+// global reads a global variable under the runtime's lock, as messages
+// arrive on another goroutine
+func global(rt *JSRuntime, name string) any {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	v := rt.vm.Get(name)
+	if v == nil {
+		return nil
+	}
+	return v.Export()
+}
+
+func TestPostMessageBetweenFrames(t *testing.T) {
+	parent, child := framePair(t, "http://example.com/", "http://example.com/child.html")
+
+	require.NoError(t, parent.Execute(`
+		var got = null;
+		window.addEventListener("message", function (e) {
+			got = e.data.n + ":" + e.origin;
+			e.source.postMessage("ack", "*");
+		});
+	`))
+	require.NoError(t, child.Execute(`
+		var reply = null;
+		window.onmessage = function (e) { reply = e.data; };
+		var isTop = window.parent === window;
+		parent.postMessage({n: 7}, "http://example.com");
+	`))
+	assert.Equal(t, false, global(child, "isTop"))
+
+	assert.Eventually(t, func() bool {
+		return global(parent, "got") == "7:http://example.com"
+	}, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return global(child, "reply") == "ack"
+	}, time.Second, 5*time.Millisecond)
+
+	// The parent reaches the child through the iframe element
+	require.NoError(t, parent.Execute(`
+		document.getElementById("f").contentWindow.postMessage("down", "/");
+	`))
+	assert.Eventually(t, func() bool {
+		return global(child, "reply") == "down"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPostMessageKeepsOrder(t *testing.T) {
+	parent, child := framePair(t, "http://example.com/", "http://example.com/child.html")
+	require.NoError(t, parent.Execute(`
+		var got = [];
+		window.onmessage = function (e) { got.push(e.data); };
+	`))
+	// The messages wait while a parent script runs
+	parent.mu.Lock()
+	require.NoError(t, child.Execute(`
+		for (var i = 0; i < 100; i++) {
+			parent.postMessage(i, "*");
+		}
+	`))
+	time.Sleep(20 * time.Millisecond)
+	parent.mu.Unlock()
+
+	expected := make([]any, 100)
+	for i := range expected {
+		expected[i] = int64(i)
+	}
+	assert.Eventually(t, func() bool {
+		got, _ := global(parent, "got").([]any)
+		return len(got) == len(expected)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, expected, global(parent, "got"))
+}
+
+func TestPostMessageChecksTargetOrigin(t *testing.T) {
+	parent, child := framePair(t, "http://example.com/", "http://example.com/child.html")
+	require.NoError(t, parent.Execute(`
+		var count = 0;
+		window.onmessage = function () { count++; };
+	`))
+	require.NoError(t, child.Execute(`
+		parent.postMessage("wrong", "http://other.example");
+		parent.postMessage("right", "*");
+	`))
+	assert.Eventually(t, func() bool {
+		return global(parent, "count") == int64(1)
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(1), global(parent, "count"))
+
+	top := NewJSRuntime(dom.Parse(strings.NewReader(`<p>top</p>`)), nil)
+	require.NoError(t, top.Execute(`var alone = window.parent === window && top === window`))
+	assert.Equal(t, true, global(top, "alone"))
+	assert.Same(t, top, RuntimeFor(top.document))
+	ReleaseRuntimes(top.document)
+	assert.Nil(t, RuntimeFor(top.document))
+}
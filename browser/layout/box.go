@@ -3,6 +3,8 @@ package layout
 import (
 	"browser/css"
 	"browser/dom"
+	"browser/fonts"
+	"sync/atomic"
 )
 
 type Rect struct {
@@ -35,6 +37,9 @@ const (
 	FileInputBox
 	FieldsetBox
 	LegendBox
+	SVGBox    // inline <svg>, rasterized as a whole
+	CanvasBox // <canvas>, painted from its script-drawn bitmap
+	IFrameBox // <iframe>, showing a nested document
 )
 
 type LayoutBox struct {
@@ -54,12 +59,69 @@ type LayoutBox struct {
 	Right        float64
 	Bottom       float64
 	Float        string
+
+	cache layoutCache
+	fonts *fonts.Registry // set on the root box
+}
+
+// Fonts returns the registry of the web fonts of the box's document
+func (box *LayoutBox) Fonts() *fonts.Registry {
+	for box.Parent != nil {
+		box = box.Parent
+	}
+	if box.fonts == nil {
+		return fonts.Default
+	}
+	return box.fonts
+}
+
+// layoutCache remembers the inputs a block box was last laid out with.
+// While they match and the box's subtree hasn't changed, relayout only
+// moves the box instead of recomputing it.
+type layoutCache struct {
+	valid          bool
+	containerWidth float64
+	parentTag      string
+	x, y           float64 // start position, kept current by offsetBox
+	generation     uint64
+}
+
+// layoutGeneration numbers each block layout pass, so painters can tell
+// whether a box was recomputed since they last saw it
+var layoutGeneration atomic.Uint64
+
+// Generation identifies the last time this box was laid out. It changes
+// whenever the box or anything inside it is recomputed, and is 0 for boxes
+// that are positioned by their parent rather than laid out as a block.
+func (box *LayoutBox) Generation() uint64 {
+	if !box.cache.valid {
+		return 0
+	}
+	return box.cache.generation
+}
+
+// invalidate forces the next layout pass to recompute the box
+func (box *LayoutBox) invalidate() {
+	box.cache.valid = false
+}
+
+// Restyled marks a box whose Style was changed in place, such as by an
+// animation frame, so it and its ancestors are laid out and painted again
+func (box *LayoutBox) Restyled() {
+	box.Position = box.Style.Position
+	box.Top = box.Style.Top
+	box.Left = box.Style.Left
+	box.Right = box.Style.Right
+	box.Bottom = box.Style.Bottom
+	for b := box; b != nil; b = b.Parent {
+		b.invalidate()
+	}
 }
 
 // IsInline returns true if the box should flow horizontally (inline)
 func (box *LayoutBox) IsInline() bool {
 	switch box.Type {
-	case TextBox, InlineBox, ImageBox:
+	case TextBox, InlineBox, ImageBox, SVGBox, CanvasBox, IFrameBox:
 		return true
 	default:
 		return false
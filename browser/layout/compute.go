@@ -3,6 +3,7 @@ package layout
 import (
 	"browser/css"
 	"browser/dom"
+	"browser/graphics"
 	"fmt"
 	"strconv"
 	"strings"
@@ -63,6 +64,12 @@ func ComputeLayout(root *LayoutBox, containerWidth float64) {
 }
 
 func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY float64, parentTag string) {
+	// Nothing inside changed and the box gets the same width: just move it
+	if box.cache.valid && box.cache.containerWidth == containerWidth && box.cache.parentTag == parentTag {
+		offsetBox(box, startX-box.cache.x, startY-box.cache.y)
+		return
+	}
+
 	// Separate positioned children from normal flow
 	var positionedChildren []*LayoutBox
 	var floatedChildren []*LayoutBox
@@ -274,6 +281,7 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 		switch child.Type {
 		case TextBox:
 			fontSize := getFontSize(parentTag)
+			textFont := textFontOf(child)
 			// Check if inside a <pre> element
 			if isInsidePre(child) {
 				// Handle multi-line preformatted text
@@ -283,7 +291,7 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 				// Find the widest line
 				maxWidth := 0.0
 				for _, line := range lines {
-					w := MeasureText(line, fontSize)
+					w := MeasureStyledText(line, fontSize, textFont)
 					if w > maxWidth {
 						maxWidth = w
 					}
@@ -293,7 +301,7 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 				childHeight = float64(len(lines)) * lineHeight
 			} else {
 				// Wrap text to fit container width
-				child.WrappedLines = WrapText(child.Text, fontSize, innerWidth)
+				child.WrappedLines = WrapStyledText(child.Text, fontSize, innerWidth, textFont)
 
 				lineHeight := getLineHeightFromStyle(box.Style, parentTag)
 				numLines := len(child.WrappedLines)
@@ -304,7 +312,7 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 				// Width is the widest wrapped line
 				maxLineWidth := 0.0
 				for _, line := range child.WrappedLines {
-					w := MeasureText(line, fontSize)
+					w := MeasureStyledText(line, fontSize, textFont)
 					if w > maxLineWidth {
 						maxLineWidth = w
 					}
@@ -317,7 +325,7 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 			// Compute inline box size from its content
 			childWidth, childHeight = computeInlineSize(child, parentTag)
 
-		case ImageBox:
+		case ImageBox, SVGBox, CanvasBox, IFrameBox:
 			childWidth, childHeight = getImageSize(child.Node)
 		case InputBox:
 			childWidth = 200.0
@@ -521,12 +529,25 @@ func computeBlockLayout(box *LayoutBox, containerWidth float64, startX, startY f
 		box.Children = append(box.Children, child)
 	}
 
+	box.cache = layoutCache{
+		valid:          true,
+		containerWidth: containerWidth,
+		parentTag:      parentTag,
+		x:              startX,
+		y:              startY,
+		generation:     layoutGeneration.Add(1),
+	}
 }
 
 // offsetBox moves a box and all its children by (dx, dy)
 func offsetBox(box *LayoutBox, dx, dy float64) {
+	if dx == 0 && dy == 0 {
+		return
+	}
 	box.Rect.X += dx
 	box.Rect.Y += dy
+	box.cache.x += dx
+	box.cache.y += dy
 	for _, child := range box.Children {
 		offsetBox(child, dx, dy)
 	}
@@ -576,11 +597,11 @@ func computeInlineSize(box *LayoutBox, parentTag string) (float64, float64) {
 		case TextBox:
 			fontSize := getFontSize(tagForSize)
 			text := css.ApplyTextTransform(child.Text, box.Style.TextTransform)
-			w = MeasureText(text, fontSize)
+			w = MeasureStyledText(text, fontSize, textFontOf(child))
 			h = getLineHeightFromStyle(box.Style, tagForSize)
 		case InlineBox:
 			w, h = computeInlineSize(child, parentTag)
-		case ImageBox:
+		case ImageBox, SVGBox, CanvasBox, IFrameBox:
 			w, h = getImageSize(child.Node)
 		case CheckboxBox, RadioBox:
 			w = 20.0
@@ -614,7 +635,7 @@ func layoutInlineChildren(box *LayoutBox, parentTag string) {
 		case TextBox:
 			fontSize := getFontSize(tagForSize)
 			text := css.ApplyTextTransform(child.Text, box.Style.TextTransform)
-			w := MeasureText(text, fontSize)
+			w := MeasureStyledText(text, fontSize, textFontOf(child))
 			h := getLineHeightFromStyle(box.Style, tagForSize)
 			child.Rect.X = box.Rect.X + offsetX
 			child.Rect.Y = box.Rect.Y + baselineOffset
@@ -629,7 +650,7 @@ func layoutInlineChildren(box *LayoutBox, parentTag string) {
 			child.Rect.Height = h
 			layoutInlineChildren(child, parentTag)
 			offsetX += w
-		case ImageBox:
+		case ImageBox, SVGBox, CanvasBox, IFrameBox:
 			w, h := getImageSize(child.Node)
 			child.Rect.X = box.Rect.X + offsetX
 			child.Rect.Y = box.Rect.Y
@@ -709,7 +730,7 @@ func computeTableLayout(table *LayoutBox, containerWidth float64, startX, startY
 			for _, textChild := range child.Children {
 				if textChild.Type == TextBox {
 					fontSize := 16.0
-					textWidth := MeasureText(textChild.Text, fontSize)
+					textWidth := MeasureStyledText(textChild.Text, fontSize, textFontOf(textChild))
 					textChild.Rect.X = startX + (containerWidth-textWidth)/2 // centered
 					textChild.Rect.Y = yOffset
 					textChild.Rect.Width = textWidth
@@ -785,7 +806,7 @@ func computeCellContent(cell *LayoutBox, width float64, startX, startY float64)
 		switch box.Type {
 		case TextBox:
 			fontSize := 16.0
-			textWidth := MeasureText(box.Text, fontSize)
+			textWidth := MeasureStyledText(box.Text, fontSize, textFontOf(box))
 			box.Rect.X = currentX
 			box.Rect.Y = currentY
 			box.Rect.Width = textWidth
@@ -851,7 +872,9 @@ func computeCellContent(cell *LayoutBox, width float64, startX, startY float64)
 	return maxY - startY
 }
 
-// getImageSize reads width/height attributes or returns defaults
+// getImageSize reads width/height attributes or returns defaults. Canvas,
+// SVG and iframes default to 300x150; an SVG missing one dimension takes it
+// from its viewBox aspect ratio.
 func getImageSize(node *dom.Node) (float64, float64) {
 	if node == nil {
 		return DefaultImageWidth, DefaultImageHeight
@@ -859,16 +882,36 @@ func getImageSize(node *dom.Node) (float64, float64) {
 
 	width := DefaultImageWidth
 	height := DefaultImageHeight
+	if node.TagName == dom.TagSVG || node.TagName == dom.TagCanvas || node.TagName == dom.TagIFrame {
+		width = graphics.DefaultCanvasWidth
+		height = graphics.DefaultCanvasHeight
+	}
 
-	if w, ok := node.Attributes["width"]; ok {
+	w, hasWidth := node.Attributes["width"]
+	if hasWidth {
 		if parsed, err := strconv.ParseFloat(strings.TrimSuffix(w, "px"), 64); err == nil {
 			width = parsed
+		} else {
+			hasWidth = false
 		}
 	}
 
-	if h, ok := node.Attributes["height"]; ok {
+	h, hasHeight := node.Attributes["height"]
+	if hasHeight {
 		if parsed, err := strconv.ParseFloat(strings.TrimSuffix(h, "px"), 64); err == nil {
 			height = parsed
+		} else {
+			hasHeight = false
+		}
+	}
+
+	if node.TagName == dom.TagSVG && hasWidth != hasHeight {
+		if vbWidth, vbHeight, ok := graphics.ViewBoxSize(node); ok {
+			if hasWidth {
+				height = width * vbHeight / vbWidth
+			} else {
+				width = height * vbWidth / vbHeight
+			}
 		}
 	}
 
@@ -886,6 +929,12 @@ func (box *LayoutBox) Print(indent int) {
 		typeName = "Text"
 	case ImageBox:
 		typeName = "Image"
+	case SVGBox:
+		typeName = "SVG"
+	case CanvasBox:
+		typeName = "Canvas"
+	case IFrameBox:
+		typeName = "IFrame"
 	}
 
 	if box.Type == TextBox {
@@ -116,6 +116,31 @@ func TestGetImageSize(t *testing.T) {
 	}
 }
 
+func TestGetImageSizeSVGAndCanvas(t *testing.T) {
+	tests := []struct {
+		name           string
+		tag            string
+		attrs          map[string]string
+		expectedWidth  float64
+		expectedHeight float64
+	}{
+		{"canvas default", "canvas", map[string]string{}, 300.0, 150.0},
+		{"canvas attributes", "canvas", map[string]string{"width": "64", "height": "32"}, 64.0, 32.0},
+		{"svg default", "svg", map[string]string{}, 300.0, 150.0},
+		{"svg width from viewBox", "svg", map[string]string{"height": "50", "viewBox": "0 0 20 10"}, 100.0, 50.0},
+		{"svg height from viewBox", "svg", map[string]string{"width": "40", "viewBox": "0 0 20 10"}, 40.0, 20.0},
+		{"svg both attributes", "svg", map[string]string{"width": "40", "height": "40", "viewBox": "0 0 20 10"}, 40.0, 40.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := getImageSize(dom.NewElement(tt.tag, tt.attrs))
+			assert.Equal(t, tt.expectedWidth, w)
+			assert.Equal(t, tt.expectedHeight, h)
+		})
+	}
+}
+
 func TestIsInsidePre(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,91 @@
+package layout
+
+import (
+	"browser/dom"
+	"sync"
+)
+
+// Frame is the laid-out document shown by an <iframe>, scrolled within the
+// iframe's box. Coordinates in Root are relative to the top left of that
+// box, before scrolling. The loader that builds the document's layout
+// registers it against the iframe element.
+type Frame struct {
+	mu               sync.Mutex
+	root             *LayoutBox
+	url              string
+	scrollX, scrollY float64
+}
+
+// Each <iframe> element owns at most one frame
+var (
+	framesMu sync.Mutex
+	frames   = map[*dom.Node]*Frame{}
+)
+
+// FrameFor returns the frame of an iframe element, creating it on first use
+func FrameFor(node *dom.Node) *Frame {
+	framesMu.Lock()
+	defer framesMu.Unlock()
+	frame, ok := frames[node]
+	if !ok {
+		frame = &Frame{}
+		frames[node] = frame
+	}
+	return frame
+}
+
+// LookupFrame returns the frame of an iframe element, or nil if no document
+// was loaded into it
+func LookupFrame(node *dom.Node) *Frame {
+	framesMu.Lock()
+	defer framesMu.Unlock()
+	return frames[node]
+}
+
+// ReleaseFrame forgets the frame of an iframe element, called when the
+// page holding it is replaced
+func ReleaseFrame(node *dom.Node) {
+	framesMu.Lock()
+	defer framesMu.Unlock()
+	delete(frames, node)
+}
+
+// SetRoot replaces the frame's layout tree and the URL of its document
+func (f *Frame) SetRoot(root *LayoutBox, url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.url != url {
+		f.scrollX, f.scrollY = 0, 0
+	}
+	f.root = root
+	f.url = url
+}
+
+// Root returns the frame's layout tree, or nil while its document loads
+func (f *Frame) Root() *LayoutBox {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.root
+}
+
+// URL is the address of the frame's document, which its links and images
+// are resolved against
+func (f *Frame) URL() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.url
+}
+
+// Scroll returns how far the frame's document is scrolled
+func (f *Frame) Scroll() (x, y float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.scrollX, f.scrollY
+}
+
+// ScrollTo records the frame's scroll position
+func (f *Frame) ScrollTo(x, y float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scrollX, f.scrollY = x, y
+}
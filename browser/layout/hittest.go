@@ -1,18 +1,40 @@
 package layout
 
+// HitTest returns the innermost box at (x, y). Points inside an iframe are
+// forwarded to the document it shows, so the box may belong to that one.
 func (box *LayoutBox) HitTest(x, y float64) *LayoutBox {
+	hit, _, _ := box.HitTestPoint(x, y)
+	return hit
+}
+
+// HitTestPoint is HitTest that also returns the point in the coordinates of
+// the hit box's own document, which differ from (x, y) inside iframes
+func (box *LayoutBox) HitTestPoint(x, y float64) (*LayoutBox, float64, float64) {
 	if !box.Contains(x, y) {
-		return nil
+		return nil, x, y
+	}
+
+	if box.Type == IFrameBox && box.Node != nil {
+		if frame := LookupFrame(box.Node); frame != nil {
+			if root := frame.Root(); root != nil {
+				sx, sy := frame.Scroll()
+				fx, fy := x-box.Rect.X+sx, y-box.Rect.Y+sy
+				if hit, hx, hy := root.HitTestPoint(fx, fy); hit != nil {
+					return hit, hx, hy
+				}
+			}
+		}
+		return box, x, y
 	}
 
 	for i := len(box.Children) - 1; i >= 0; i-- {
 		child := box.Children[i]
-		if hit := child.HitTest(x, y); hit != nil {
-			return hit
+		if hit, hx, hy := child.HitTestPoint(x, y); hit != nil {
+			return hit, hx, hy
 		}
 	}
 
-	return box
+	return box, x, y
 }
 
 // Contains checks if point (x, y) is inside this box
@@ -269,3 +269,45 @@ func TestFindLink(t *testing.T) {
 func createElementNode(tagName string, attrs map[string]string) *dom.Node {
 	return dom.NewElement(tagName, attrs)
 }
+
+func TestHitTestForwardsIntoFrames(t *testing.T) {
+	iframe := &dom.Node{Type: dom.Element, TagName: dom.TagIFrame}
+	page := createBoxWithRect(0, 0, 400, 400)
+	frameBox := createBoxWithRect(100, 50, 200, 100)
+	frameBox.Type = IFrameBox
+	frameBox.Node = iframe
+	addChild(page, frameBox)
+
+	t.Run("without a document the iframe itself is hit", func(t *testing.T) {
+		assert.Same(t, frameBox, page.HitTest(150, 80))
+	})
+
+	frameRoot := createBoxWithRect(0, 0, 200, 300)
+	link := createBoxWithRect(10, 120, 50, 20)
+	addChild(frameRoot, link)
+	frame := FrameFor(iframe)
+	frame.SetRoot(frameRoot, "http://example.com/frame.html")
+	t.Cleanup(func() { ReleaseFrame(iframe) })
+
+	t.Run("points are translated into the frame's document", func(t *testing.T) {
+		hit, x, y := page.HitTestPoint(120, 100)
+		assert.Same(t, frameRoot, hit)
+		assert.Equal(t, []float64{20, 50}, []float64{x, y})
+	})
+
+	t.Run("scrolling moves the document under the point", func(t *testing.T) {
+		frame.ScrollTo(0, 80)
+		defer frame.ScrollTo(0, 0)
+		hit, _, y := page.HitTestPoint(120, 100)
+		assert.Same(t, link, hit)
+		assert.Equal(t, 130.0, y)
+	})
+
+	t.Run("a new document starts at the top", func(t *testing.T) {
+		frame.ScrollTo(0, 80)
+		frame.SetRoot(frameRoot, "http://example.com/other.html")
+		x, y := frame.Scroll()
+		assert.Zero(t, x)
+		assert.Zero(t, y)
+	})
+}
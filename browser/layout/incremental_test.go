@@ -0,0 +1,210 @@
+package layout
+
+import (
+	"browser/dom"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const incrementalPage = `<html><body>
+<h1>Title</h1>
+<p id="first">First paragraph with <b>bold</b> text.</p>
+<div id="box"><p id="inner">Inner text</p><span>inline</span></div>
+<ul id="list"><li>one</li><li>two</li></ul>
+<div style="position: absolute; top: 10px; left: 10px; width: 50px">abs</div>
+<div style="float: right; width: 40px">float</div>
+<table><tr><td id="cell">cell</td></tr></table>
+<p id="last">Last paragraph</p>
+</body></html>`
+
+// dumpLayout lists every box's type and rect in tree order
+func dumpLayout(box *LayoutBox) []string {
+	var out []string
+	var walk func(b *LayoutBox, depth int)
+	walk = func(b *LayoutBox, depth int) {
+		label := b.Text
+		if b.Node != nil && b.Node.Type == dom.Element {
+			label = "<" + b.Node.TagName + ">"
+		}
+		out = append(out, fmt.Sprintf("%s%d %q %.1f,%.1f %.1fx%.1f",
+			strings.Repeat(" ", depth), b.Type, label, b.Rect.X, b.Rect.Y, b.Rect.Width, b.Rect.Height))
+		for _, child := range b.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(box, 0)
+	return out
+}
+
+func findNodeByID(node *dom.Node, id string) *dom.Node {
+	if node.Attributes["id"] == id {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := findNodeByID(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestUpdateLayoutTreeMatchesFullBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(doc *dom.Node)
+	}{
+		{"no change", func(doc *dom.Node) {}},
+		{"replace text", func(doc *dom.Node) {
+			findNodeByID(doc, "first").SetInnerText("A much longer first paragraph that wraps onto more than one line at this width")
+		}},
+		{"append child", func(doc *dom.Node) {
+			p := dom.NewElement("p", map[string]string{})
+			p.AppendChild(dom.NewText("appended"))
+			findNodeByID(doc, "box").AppendChild(p)
+		}},
+		{"remove child", func(doc *dom.Node) {
+			findNodeByID(doc, "inner").Remove()
+		}},
+		{"list item added", func(doc *dom.Node) {
+			li := dom.NewElement("li", map[string]string{})
+			li.AppendChild(dom.NewText("zero"))
+			list := findNodeByID(doc, "list")
+			list.Children = append([]*dom.Node{li}, list.Children...)
+			li.Parent = list
+			list.MarkChildrenDirty()
+		}},
+		{"style attribute", func(doc *dom.Node) {
+			box := findNodeByID(doc, "box")
+			box.Attributes["style"] = "padding-left: 30px; font-size: 24px"
+			box.MarkDirty()
+		}},
+		{"table cell text", func(doc *dom.Node) {
+			findNodeByID(doc, "cell").SetInnerText("a longer cell")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseHTML(incrementalPage)
+			viewport := Viewport{Width: 400, Height: 300}
+			tree := BuildLayoutTree(doc, emptyStylesheet(), viewport)
+			ComputeLayout(tree, 400)
+
+			tt.mutate(doc)
+			tree = UpdateLayoutTree(tree, doc, emptyStylesheet(), viewport)
+			ComputeLayout(tree, 400)
+
+			fresh := BuildLayoutTree(doc, emptyStylesheet(), viewport)
+			ComputeLayout(fresh, 400)
+
+			assert.Equal(t, dumpLayout(fresh), dumpLayout(tree))
+			assert.False(t, doc.HasDirtyChildren(), "flags are cleared")
+		})
+	}
+}
+
+func TestUpdateLayoutTreeReusesCleanBoxes(t *testing.T) {
+	doc := parseHTML(incrementalPage)
+	viewport := Viewport{Width: 400, Height: 300}
+	tree := BuildLayoutTree(doc, emptyStylesheet(), viewport)
+	ComputeLayout(tree, 400)
+
+	first := findBoxByTag(tree, "h1")
+	require.NotNil(t, first)
+	firstGeneration := first.Generation()
+	require.NotZero(t, firstGeneration)
+	box := findBoxByTag(tree, "div")
+	boxGeneration := box.Generation()
+
+	// Text after the heading changes; the heading keeps its box and layout
+	findNodeByID(doc, "last").SetInnerText("changed")
+	tree = UpdateLayoutTree(tree, doc, emptyStylesheet(), viewport)
+	ComputeLayout(tree, 400)
+
+	assert.Same(t, first, findBoxByTag(tree, "h1"))
+	assert.Equal(t, firstGeneration, first.Generation())
+	assert.Same(t, box, findBoxByTag(tree, "div"))
+	assert.Equal(t, boxGeneration, box.Generation())
+
+	// A different width invalidates everything
+	ComputeLayout(tree, 300)
+	assert.NotEqual(t, firstGeneration, first.Generation())
+}
+
+func TestUpdateLayoutTreeRestylesDirtySubtree(t *testing.T) {
+	doc := parseHTML(`<div id="outer"><p id="p">text</p></div>`)
+	tree := BuildLayoutTree(doc, createStylesheet(".big { font-size: 30px; }"), Viewport{})
+	p := findBoxByTag(tree, "p")
+
+	outer := findNodeByID(doc, "outer")
+	outer.Attributes["class"] = "big"
+	outer.MarkDirty()
+	tree = UpdateLayoutTree(tree, doc, createStylesheet(".big { font-size: 30px; }"), Viewport{})
+
+	assert.Equal(t, 30.0, findBoxByTag(tree, "div").Style.FontSize)
+	assert.NotSame(t, p, findBoxByTag(tree, "p"), "descendants are restyled with the node")
+}
+
+func TestRestyledRelaysOutAncestors(t *testing.T) {
+	doc := parseHTML(`<div id="outer"><p>before</p><div id="inner" style="height: 20px"></div></div><p id="after">after</p>`)
+	tree := BuildLayoutTree(doc, emptyStylesheet(), Viewport{Width: 400})
+	ComputeLayout(tree, 400)
+
+	outer := findBoxByTag(tree, "div")
+	require.NotNil(t, outer)
+	inner := outer.Children[1]
+	outerHeight := outer.Rect.Height
+
+	// An animation frame grows the inner box in place
+	inner.Style.Height = 50
+	inner.Restyled()
+	assert.Zero(t, outer.Generation())
+	ComputeLayout(tree, 400)
+
+	assert.Equal(t, 50.0, inner.Rect.Height)
+	assert.InDelta(t, outerHeight+30, outer.Rect.Height, 0.01)
+}
+
+// largePage builds a page with n sections of mixed block and inline content
+func largePage(n int) string {
+	var sb strings.Builder
+	sb.WriteString("<html><body><p id=\"ticker\">0</p>")
+	for i := range n {
+		fmt.Fprintf(&sb, `<div class="section"><h2>Section %d</h2><p>Some <b>bold</b> and <i>italic</i> text in paragraph %d, long enough to wrap across a couple of lines at the default width.</p><ul><li>first</li><li>second</li></ul></div>`, i, i)
+	}
+	sb.WriteString("</body></html>")
+	return sb.String()
+}
+
+// BenchmarkRelayout compares rebuilding the whole layout tree after a
+// script changes one text node with updating only the changed subtree
+func BenchmarkRelayout(b *testing.B) {
+	viewport := Viewport{Width: 900, Height: 600}
+	stylesheet := createStylesheet(".section { margin-top: 8px; } h2 { color: navy; }")
+
+	b.Run("full", func(b *testing.B) {
+		doc := parseHTML(largePage(1000))
+		ticker := findNodeByID(doc, "ticker")
+		for i := 0; b.Loop(); i++ {
+			ticker.SetInnerText(fmt.Sprint(i))
+			tree := BuildLayoutTree(doc, stylesheet, viewport)
+			ComputeLayout(tree, 900)
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		doc := parseHTML(largePage(1000))
+		ticker := findNodeByID(doc, "ticker")
+		tree := BuildLayoutTree(doc, stylesheet, viewport)
+		ComputeLayout(tree, 900)
+		for i := 0; b.Loop(); i++ {
+			ticker.SetInnerText(fmt.Sprint(i))
+			tree = UpdateLayoutTree(tree, doc, stylesheet, viewport)
+			ComputeLayout(tree, 900)
+		}
+	})
+}
@@ -3,12 +3,14 @@ package layout
 import (
 	"browser/css"
 	"browser/dom"
+	"browser/fonts"
 	"strings"
 )
 
 type Viewport struct {
 	Width  float64
 	Height float64
+	Fonts  *fonts.Registry // the document's web fonts, nil for none
 }
 
 var blockElements = map[string]bool{
@@ -48,13 +50,75 @@ var imageElements = map[string]bool{
 }
 
 func BuildLayoutTree(root *dom.Node, stylesheet css.Stylesheet, viewport Viewport) *LayoutBox {
-	return BuildBox(root, nil, stylesheet, viewport)
+	box := BuildBox(root, nil, stylesheet, viewport)
+	if box != nil {
+		box.fonts = viewport.Fonts
+	}
+	dom.ClearDirty(root)
+	return box
+}
+
+// UpdateLayoutTree brings a tree built by BuildLayoutTree up to date with
+// the DOM changes flagged since. Dirty nodes are restyled with their
+// subtrees; everything else keeps its box, and boxes whose subtree did not
+// change keep their layout. The stylesheet and viewport must be the ones
+// prev was built with, otherwise use BuildLayoutTree.
+func UpdateLayoutTree(prev *LayoutBox, root *dom.Node, stylesheet css.Stylesheet, viewport Viewport) *LayoutBox {
+	if prev == nil || prev.Node != root || root.IsDirty() {
+		return BuildLayoutTree(root, stylesheet, viewport)
+	}
+	updateBox(prev, stylesheet, viewport)
+	dom.ClearDirty(root)
+	return prev
+}
+
+// updateBox rebuilds the children of a box whose node has dirty children,
+// reusing the boxes of children that did not change
+func updateBox(box *LayoutBox, stylesheet css.Stylesheet, viewport Viewport) {
+	node := box.Node
+	if !node.HasDirtyChildren() {
+		return
+	}
+	box.invalidate()
+
+	// SVG, canvas and iframes have no child boxes
+	if box.Type == SVGBox || box.Type == CanvasBox || box.Type == IFrameBox {
+		return
+	}
+
+	previous := make(map[*dom.Node]*LayoutBox, len(box.Children))
+	for _, child := range box.Children {
+		previous[child.Node] = child
+	}
+
+	// List markers are numbered by position, so items must repaint
+	isList := node.TagName == dom.TagUL || node.TagName == dom.TagOL
+
+	children := make([]*LayoutBox, 0, len(node.Children))
+	for _, child := range node.Children {
+		if old, ok := previous[child]; ok && !child.IsDirty() {
+			updateBox(old, stylesheet, viewport)
+			if isList {
+				old.invalidate()
+			}
+			children = append(children, old)
+			continue
+		}
+		if childBox := BuildBox(child, box, stylesheet, viewport); childBox != nil {
+			children = append(children, childBox)
+		}
+	}
+	box.Children = children
 }
 
 func BuildBox(node *dom.Node, parent *LayoutBox, stylesheet css.Stylesheet, viewport Viewport) *LayoutBox {
 	if node.Type == dom.Element && skipElements[node.TagName] {
 		return nil
 	}
+	// Comments and the doctype are kept in the DOM but never rendered
+	if node.Type == dom.Comment || node.Type == dom.Doctype {
+		return nil
+	}
 
 	box := &LayoutBox{Node: node, Parent: parent}
 
@@ -72,6 +136,13 @@ func BuildBox(node *dom.Node, parent *LayoutBox, stylesheet css.Stylesheet, view
 			parentFontSize = parent.Style.FontSize
 		}
 
+		quirks := node.InQuirksMode()
+
+		// Quirk: tables do not inherit font size from their ancestors
+		if quirks && node.TagName == dom.TagTable {
+			parentFontSize = 16.0
+		}
+
 		box.Style = css.ApplyStylesheetWithContext(stylesheet, node.TagName, id, classes, parentFontSize, viewport.Width, viewport.Height)
 
 		if align, ok := node.Attributes["align"]; ok {
@@ -97,6 +168,12 @@ func BuildBox(node *dom.Node, parent *LayoutBox, stylesheet css.Stylesheet, view
 			return nil
 		}
 
+		// Quirk: the body fills the viewport unless the page sizes it
+		if quirks && node.TagName == dom.TagBody && viewport.Height > 0 &&
+			box.Style.Height == 0 && box.Style.MinHeight == 0 {
+			box.Style.MinHeight = viewport.Height
+		}
+
 		box.Position = box.Style.Position
 		box.Top = box.Style.Top
 		box.Left = box.Style.Left
@@ -115,6 +192,12 @@ func BuildBox(node *dom.Node, parent *LayoutBox, stylesheet css.Stylesheet, view
 			box.Type = BRBox
 		} else if imageElements[node.TagName] {
 			box.Type = ImageBox
+		} else if node.TagName == dom.TagSVG {
+			box.Type = SVGBox
+		} else if node.TagName == dom.TagCanvas {
+			box.Type = CanvasBox
+		} else if node.TagName == dom.TagIFrame {
+			box.Type = IFrameBox
 		} else if node.TagName == dom.TagInput {
 			inputType := node.Attributes["type"]
 			switch strings.ToLower(inputType) {
@@ -157,6 +240,12 @@ func BuildBox(node *dom.Node, parent *LayoutBox, stylesheet css.Stylesheet, view
 		box.Text = wrapInlineQuotes(node)
 	}
 
+	// SVG content is drawn by the SVG renderer, canvas fallback content is
+	// only for browsers without scripting, and an iframe shows its own document
+	if box.Type == SVGBox || box.Type == CanvasBox || box.Type == IFrameBox {
+		return box
+	}
+
 	for _, child := range node.Children {
 		childBox := BuildBox(child, box, stylesheet, viewport)
 		if childBox != nil {
@@ -321,12 +410,40 @@ func mergeStyles(base *css.Style, inline *css.Style) {
 		base.FontFamily = inline.FontFamily
 	}
 
-	if inline.BorderRadius > 0 {
+	if inline.BorderRadius > 0 || inline.BorderRadiusPercent > 0 {
 		base.BorderRadius = inline.BorderRadius
+		base.BorderRadiusPercent = inline.BorderRadiusPercent
 	}
 
 	if inline.BackgroundImage != "" {
 		base.BackgroundImage = inline.BackgroundImage
+		base.BackgroundGradient = nil
+	}
+	if inline.BackgroundGradient != nil {
+		base.BackgroundGradient = inline.BackgroundGradient
+		base.BackgroundImage = ""
+	}
+	if inline.BackgroundRepeat != "" {
+		base.BackgroundRepeat = inline.BackgroundRepeat
+	}
+	if inline.BackgroundSize != (css.BackgroundSize{}) {
+		base.BackgroundSize = inline.BackgroundSize
+	}
+	if inline.BackgroundPosition != (css.BackgroundPosition{}) {
+		base.BackgroundPosition = inline.BackgroundPosition
+	}
+	if inline.BoxShadows != nil {
+		base.BoxShadows = inline.BoxShadows
+	}
+
+	if inline.Transform != nil {
+		base.Transform = inline.Transform
+	}
+	if inline.Transitions != nil {
+		base.Transitions = inline.Transitions
+	}
+	if inline.Animations != nil {
+		base.Animations = inline.Animations
 	}
 }
 
@@ -2,10 +2,13 @@ package layout
 
 import (
 	"browser/css"
+	"browser/fonts"
 	"image/color"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/gomono"
 )
 
 func TestBuildLayoutTreeBoxTypes(t *testing.T) {
@@ -308,3 +311,113 @@ func TestMergeStyles(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildLayoutTreeQuirks(t *testing.T) {
+	const cssText = "div { font-size: 32px; } table { font-size: 2em; }"
+	const body = "<body><div><table><tr><td>x</td></tr></table></div></body>"
+
+	tests := []struct {
+		name          string
+		doctype       string
+		tableFontSize float64
+		bodyMinHeight float64
+	}{
+		{"standards mode", "<!DOCTYPE html>", 64, 0},
+		{"quirks mode", "", 32, 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := parseHTML(tt.doctype + body)
+			tree := BuildLayoutTree(root, createStylesheet(cssText), Viewport{Width: 800, Height: 600})
+
+			assert.Equal(t, tt.tableFontSize, findBoxByTag(tree, "table").Style.FontSize)
+			assert.Equal(t, tt.bodyMinHeight, findBoxByTag(tree, "body").Style.MinHeight)
+		})
+	}
+}
+
+func TestBuildLayoutTreeSkipsComments(t *testing.T) {
+	tree := buildTree("<!DOCTYPE html><div><!-- note --><p>A</p></div>")
+	div := findBoxByTag(tree, "div")
+	assert.NotNil(t, div)
+	assert.Len(t, div.Children, 1)
+	assert.Equal(t, 1, len(tree.Children))
+}
+
+func TestBuildLayoutTreeSVGAndCanvas(t *testing.T) {
+	tree := buildTree(`<div><svg width="20" height="10"><rect width="5" height="5"/></svg><canvas><p>fallback</p></canvas></div>`)
+
+	svg := findBoxByTag(tree, "svg")
+	require.NotNil(t, svg)
+	assert.Equal(t, SVGBox, svg.Type)
+	assert.True(t, svg.IsInline())
+	assert.Empty(t, svg.Children)
+
+	canvas := findBoxByTag(tree, "canvas")
+	require.NotNil(t, canvas)
+	assert.Equal(t, CanvasBox, canvas.Type)
+	assert.Empty(t, canvas.Children, "fallback content is not rendered")
+}
+
+func TestBuildLayoutTreeIFrame(t *testing.T) {
+	tree := buildTree(`<div><iframe src="a.html"></iframe><iframe src="b.html" width="400" height="80"></iframe></div>`)
+	ComputeLayout(tree, 800)
+
+	var frames []*LayoutBox
+	for _, child := range findBoxByTag(tree, "div").Children {
+		if child.Type == IFrameBox {
+			frames = append(frames, child)
+		}
+	}
+	require.Len(t, frames, 2)
+	assert.True(t, frames[0].IsInline())
+	assert.Empty(t, frames[0].Children)
+	assert.Equal(t, []float64{300, 150}, []float64{frames[0].Rect.Width, frames[0].Rect.Height})
+	assert.Equal(t, []float64{400, 80}, []float64{frames[1].Rect.Width, frames[1].Rect.Height})
+}
+
+func TestMeasureStyledTextUsesShaperForRTL(t *testing.T) {
+	// Without a measurer, plain text is estimated per byte; Hebrew is
+	// shaped, so its width does not depend on the UTF-8 length
+	hebrew := "שלום"
+	assert.Equal(t, MeasureText("abcd", 16), MeasureStyledText("abcd", 16, TextFont{}))
+	assert.NotEqual(t, MeasureText(hebrew, 16), MeasureStyledText(hebrew, 16, TextFont{}))
+	assert.Greater(t, MeasureStyledText(hebrew, 16, TextFont{}), 0.0)
+}
+
+func TestDocumentFonts(t *testing.T) {
+	html := `<html><body><p style="font-family: Fancy">iii</p></body></html>`
+	mono, err := fonts.Load(gomono.TTF)
+	require.NoError(t, err)
+	registry := fonts.Default.ForDocument()
+	registry.Register("Fancy", fonts.Descriptor{}, mono, "")
+
+	root := BuildLayoutTree(parseHTML(html), emptyStylesheet(), Viewport{Fonts: registry})
+	text := findBoxByType(root, TextBox)
+	require.NotNil(t, text)
+	f := textFontOf(text)
+	assert.Same(t, registry, f.Fonts)
+	assert.Same(t, registry, text.Fonts())
+	// Measured by the shaper with the document's web font
+	expected := registry.Measure("iii", fonts.Style{Family: f.Family, Size: 16})
+	assert.InDelta(t, expected, MeasureStyledText("iii", 16, f), 0.01)
+	assert.NotEqual(t, MeasureText("iii", 16), expected)
+
+	// Another document does not see the face
+	other := findBoxByType(buildTree(html), TextBox)
+	require.NotNil(t, other)
+	assert.Same(t, fonts.Default, other.Fonts())
+	assert.Equal(t, MeasureText("iii", 16), MeasureStyledText("iii", 16, textFontOf(other)))
+}
+
+func TestTextFontOfInheritsFromAncestors(t *testing.T) {
+	root := buildTree(`<html><body><div style="font-family: Fancy, serif"><strong><em>hi</em></strong></div></body></html>`)
+	text := findBoxByType(root, TextBox)
+	require.NotNil(t, text)
+
+	f := textFontOf(text)
+	assert.Equal(t, []string{"Fancy", "serif"}, f.Family)
+	assert.True(t, f.Bold)
+	assert.True(t, f.Italic)
+}
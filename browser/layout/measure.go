@@ -1,6 +1,10 @@
 package layout
 
-import "strings"
+import (
+	"browser/dom"
+	"browser/fonts"
+	"strings"
+)
 
 // MeasureTextFunc is a function that measures text width given text, fontSize, bold, italic
 type MeasureTextFunc func(text string, fontSize float64, bold bool, italic bool) float64
@@ -24,9 +28,70 @@ func MeasureText(text string, fontSize float64) float64 {
 	return float64(len(text)) * avgCharWidth
 }
 
+// TextFont is the font a text box inherits from its ancestors
+type TextFont struct {
+	Family []string
+	Bold   bool
+	Italic bool
+	Fonts  *fonts.Registry // the document's web fonts, nil for none
+}
+
+// textFontOf walks up from box collecting the nearest font-family and the
+// weight and style the painter will use for it
+func textFontOf(box *LayoutBox) TextFont {
+	var f TextFont
+	for p := box; p != nil; p = p.Parent {
+		if len(f.Family) == 0 && len(p.Style.FontFamily) > 0 {
+			f.Family = p.Style.FontFamily
+		}
+		f.Bold = f.Bold || p.Style.Bold
+		f.Italic = f.Italic || p.Style.Italic
+		if p.Parent == nil {
+			f.Fonts = p.fonts
+		}
+		if p.Node == nil {
+			continue
+		}
+		switch p.Node.TagName {
+		case dom.TagStrong, dom.TagB, dom.TagTH, dom.TagH1, dom.TagH2, dom.TagH3, dom.TagH4, dom.TagH5, dom.TagH6:
+			f.Bold = true
+		case dom.TagEm, dom.TagI:
+			f.Italic = true
+		}
+	}
+	return f
+}
+
+// MeasureStyledText returns the width of text set in f. Text using a web
+// font or needing bidi reordering is measured by the shaper that paints it;
+// anything else goes through MeasureText.
+func MeasureStyledText(text string, fontSize float64, f TextFont) float64 {
+	registry := f.Fonts
+	if registry == nil {
+		registry = fonts.Default
+	}
+	if registry.NeedsShaping(text, f.Family) {
+		return registry.Measure(text, fonts.Style{Family: f.Family, Size: fontSize, Bold: f.Bold, Italic: f.Italic})
+	}
+	return MeasureText(text, fontSize)
+}
+
 // WrapText breaks text into lines that fit within maxWidth.
 // Returns slice of lines. Words are not broken mid-word.
 func WrapText(text string, fontSize float64, maxWidth float64) []string {
+	return wrapText(text, maxWidth, func(line string) float64 {
+		return MeasureText(line, fontSize)
+	})
+}
+
+// WrapStyledText is WrapText for text set in f
+func WrapStyledText(text string, fontSize float64, maxWidth float64, f TextFont) []string {
+	return wrapText(text, maxWidth, func(line string) float64 {
+		return MeasureStyledText(line, fontSize, f)
+	})
+}
+
+func wrapText(text string, maxWidth float64, measure func(string) float64) []string {
 	if maxWidth <= 0 {
 		return []string{text}
 	}
@@ -47,7 +112,7 @@ func WrapText(text string, fontSize float64, maxWidth float64) []string {
 		}
 		testLine += word
 
-		lineWidth := MeasureText(testLine, fontSize)
+		lineWidth := measure(testLine)
 
 		if lineWidth <= maxWidth || currentLine.Len() == 0 {
 			// Word fits, or it's the first word (must include even if too long)
@@ -2,41 +2,81 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"image"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
+	"browser/a11y"
 	"browser/css"
 	"browser/dom"
+	"browser/fonts"
 	"browser/js"
 	"browser/layout"
+	"browser/network"
 	"browser/render"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	// --a11y-tree <url> prints the page's accessibility tree and exits
+	if len(os.Args) == 3 && os.Args[1] == "--a11y-tree" {
+		if err := dumpAccessibilityTree(os.Args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	sessionPath := render.DefaultSessionPath()
+	session, sessionErr := render.LoadSession(sessionPath)
+	hasSession := sessionErr == nil && len(session.Tabs) > 0
+
+	if len(os.Args) < 2 && !hasSession {
 		fmt.Println("Usage: go run . <url>")
+		fmt.Println("       go run . --a11y-tree <url>")
 		os.Exit(1)
 	}
 
-	startURL := os.Args[1]
+	// Pages, stylesheets and images share one disk-backed HTTP cache
+	if err := network.EnableCache(network.DefaultCacheDir(), 100<<20); err != nil {
+		fmt.Println("HTTP cache disabled:", err)
+	}
+	// The devtools network panel lists every request
+	network.EnableLogging(network.DefaultLog)
+	// Runes no page font covers fall back to the installed fonts
+	fonts.Default.UseSystemFonts(filepath.Join(filepath.Dir(network.DefaultCacheDir()), "fonts"))
 
 	// Create browser window
 	browser := render.NewBrowser(900, 600)
+	browser.SessionPath = sessionPath
 
 	// When link is clicked or Go pressed, load the page
 	browser.OnNavigate = func(req render.NavigationRequest) {
 		loadPage(browser, req)
 	}
-	// Load initial page
-	loadPage(browser, render.NavigationRequest{
-		URL:    startURL,
-		Method: "GET",
-	})
+	browser.OnLoadFrame = func(req render.FrameRequest) {
+		loadFrame(browser, req)
+	}
+
+	// Reopen the tabs from last time, then the URL given on the command line
+	if hasSession {
+		browser.RestoreSession(session)
+		if len(os.Args) >= 2 {
+			browser.OpenTab(os.Args[1])
+		}
+	} else {
+		loadPage(browser, render.NavigationRequest{
+			URL:    os.Args[1],
+			Method: "GET",
+		})
+	}
 
 	// Run the GUI
 	browser.Run()
@@ -48,9 +88,15 @@ func loadPage(browser *render.Browser, req render.NavigationRequest) {
 	if method == "" {
 		method = "GET"
 	}
+	// Results go to the tab that asked for the page, even if it is in the background
+	tab := req.Tab
+	if tab == nil {
+		tab = browser.ActiveTab()
+	}
+
 	fmt.Printf("Fetching (%s): %s\n", method, pageURL)
-	browser.ShowLoading()
-	browser.UpdateURLBar(pageURL)
+	tab.ShowLoading()
+	tab.UpdateURLBar(pageURL)
 
 	// Run fetch in background so UI stays responsive
 	go func() {
@@ -63,98 +109,82 @@ func loadPage(browser *render.Browser, req render.NavigationRequest) {
 				httpReq, err := http.NewRequest("POST", pageURL, bytes.NewReader(req.Body))
 				if err != nil {
 					fmt.Println("Error creating request:", err)
-					browser.ShowError("Error creating request")
+					tab.ShowError("Error creating request")
 					return
 				}
 				httpReq.Header.Set("Content-Type", req.ContentType)
-				resp, err = http.DefaultClient.Do(httpReq)
+				resp, err = network.DefaultClient.Do(httpReq)
 			} else {
 				// URL-encoded form data (default)
-				resp, err = http.PostForm(pageURL, req.Data)
+				resp, err = network.DefaultClient.PostForm(pageURL, req.Data)
 			}
 		} else {
-			resp, err = http.Get(pageURL)
+			resp, err = network.DefaultClient.Get(pageURL)
 		}
 
 		if err != nil {
 			fmt.Println("Error:", err)
-			browser.ShowError("Error 404")
+			tab.ShowError("Error 404")
 			return
 		}
 		defer resp.Body.Close()
 
 		fmt.Println("Parsing HTML...")
-		document := dom.Parse(resp.Body)
+		// Decode using the Content-Type charset or <meta charset> before parsing
+		document := dom.ParseWithContentType(resp.Body, resp.Header.Get("Content-Type"))
 		if document == nil {
-			browser.ShowError("Error 404")
+			tab.ShowError("Error 404")
 			fmt.Println("Error: failed to parse HTML")
 			return
 		}
 
 		title := dom.FindTitle(document)
-		browser.SetTitle(title)
-		browser.SetDocument(document)
-
-		fmt.Println("Fetching CSS...")
-
-		// 1. Fetch external stylesheets in parallel
-		links := dom.FindStylesheetLinks(document)
-		cssResults := make([]string, len(links))
-		var wg sync.WaitGroup
-
-		for i, link := range links {
-			wg.Add(1)
-			go func(idx int, href string) {
-				defer wg.Done()
-				absURL := resolveURL(pageURL, href)
-				fmt.Println("Fetching CSS:", absURL)
-				cssResp, err := http.Get(absURL)
-				if err == nil {
-					data, _ := io.ReadAll(cssResp.Body)
-					cssResults[idx] = string(data)
-					cssResp.Body.Close()
-				} else {
-					fmt.Println("Failed to fetch CSS:", err)
-				}
-			}(i, link)
+		tab.SetTitle(title)
+		// Scripts of the old page and its frames stop receiving events
+		if old := tab.Document(); old != nil && old != document {
+			js.ReleaseRuntimes(old)
 		}
+		tab.SetDocument(document)
 
-		wg.Wait()
-
-		// Combine external CSS in order
-		var externalCSS strings.Builder
-		for _, cssContent := range cssResults {
-			externalCSS.WriteString(cssContent + "\n")
-		}
+		fmt.Println("Fetching CSS...")
+		externalCSS := fetchStylesheets(document, pageURL, tab.Fonts())
 
 		// Store external CSS for reflow (when styles are disabled/enabled)
-		browser.SetExternalCSS(externalCSS.String())
+		tab.SetExternalCSS(externalCSS)
 
 		// Combine external + internal <style> content
-		fullCSS := externalCSS.String() + dom.FindActiveStyleContent(document)
+		fullCSS := externalCSS + dom.FindActiveStyleContent(document)
 
 		fmt.Println("Building layout...")
 		stylesheet := css.Parse(fullCSS)
-		browser.SetDocument(document)
+		tab.SetDocument(document)
 		layoutTree := layout.BuildLayoutTree(document, stylesheet, layout.Viewport{
 			Width:  float64(browser.Width),
 			Height: float64(browser.Height),
+			Fonts:  tab.Fonts(),
 		})
 		layout.ComputeLayout(layoutTree, float64(browser.Width))
 
 		// Execute JavaScript
 		fmt.Println("Executing JavaScript...")
 		jsRuntime := js.NewJSRuntime(document, func() {
-			browser.Reflow(browser.Width)
+			tab.Reflow(browser.Width)
 		})
 
 		jsRuntime.SetAlertHandler(browser.ShowAlert)
 		jsRuntime.SetConfirmHandler(browser.ShowConfirm)
 		jsRuntime.SetPromptHandler(browser.ShowPrompt)
-		browser.SetJSClickHandler(jsRuntime.DispatchClick)
-		browser.SetBeforeNavigateHandler(jsRuntime.CheckBeforeUnload)
+		jsRuntime.SetConsoleHandler(tab.ConsoleMessage)
+		tab.SetEvaluateHandler(jsRuntime.Evaluate)
+		tab.SetJSClickHandler(jsRuntime.DispatchClick)
+		tab.SetInvalidHandler(jsRuntime.DispatchInvalid)
+		jsRuntime.SetFormStateHandler(tab.FormState)
+		tab.SetBeforeNavigateHandler(jsRuntime.CheckBeforeUnload)
 
 		jsRuntime.SetCurrentURL(pageURL)
+		jsRuntime.SetImageLoader(func(src string) image.Image {
+			return render.LoadImage(resolveURL(pageURL, src))
+		})
 
 		scripts := js.FindScripts(document)
 		for i, script := range scripts {
@@ -162,24 +192,25 @@ func loadPage(browser *render.Browser, req render.NavigationRequest) {
 			jsRuntime.Execute(script)
 		}
 
-		browser.SetCurrentURL(pageURL)
+		tab.SetCurrentURL(pageURL)
 		jsRuntime.SetReloadHandler(func() {
-			browser.Refresh()
+			tab.Refresh()
 		})
 
-		jsRuntime.SetTitleChangeHandler(browser.SetTitle)
+		jsRuntime.SetTitleChangeHandler(tab.SetTitle)
 
 		// Re-parse CSS after JavaScript (respects disabled styles)
-		fullCSS = externalCSS.String() + dom.FindActiveStyleContent(document)
+		fullCSS = externalCSS + dom.FindActiveStyleContent(document)
 		stylesheet = css.Parse(fullCSS)
 
 		// Rebuild layout tree AFTER JavaScript has modified the DOM
 		layoutTree = layout.BuildLayoutTree(document, stylesheet, layout.Viewport{
 			Width:  float64(browser.Width),
 			Height: float64(browser.Height),
+			Fonts:  tab.Fonts(),
 		})
 		layout.ComputeLayout(layoutTree, float64(browser.Width))
-		browser.SetContent(layoutTree)
+		tab.SetContent(layoutTree)
 
 		bodyNode := dom.FindElementsByTagName(document, dom.TagBody)
 		if bodyNode != nil {
@@ -189,12 +220,201 @@ func loadPage(browser *render.Browser, req render.NavigationRequest) {
 			}
 		}
 
-		browser.AddToHistory(pageURL)
+		if !req.SkipHistory {
+			tab.AddToHistory(pageURL)
+		}
 
 		fmt.Println("Page loaded!")
 	}()
 }
 
+// dumpAccessibilityTree loads a page without a window, runs its scripts,
+// lays it out at the default window size and writes its accessibility tree
+// to stdout as JSON
+func dumpAccessibilityTree(pageURL string) error {
+	// The pipeline logs its progress to stdout; keep that for the JSON
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	resp, err := network.DefaultClient.Get(pageURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	document := dom.ParseWithContentType(resp.Body, resp.Header.Get("Content-Type"))
+	if document == nil {
+		return fmt.Errorf("failed to parse %s", pageURL)
+	}
+	registry := fonts.Default.ForDocument()
+	externalCSS := fetchStylesheets(document, pageURL, registry)
+
+	jsRuntime := js.NewJSRuntime(document, nil)
+	jsRuntime.SetCurrentURL(pageURL)
+	for _, script := range js.FindScripts(document) {
+		jsRuntime.Execute(script)
+	}
+	js.ReleaseRuntimes(document)
+
+	stylesheet := css.Parse(externalCSS + dom.FindActiveStyleContent(document))
+	layoutTree := layout.BuildLayoutTree(document, stylesheet, layout.Viewport{Width: 900, Height: 600, Fonts: registry})
+	layout.ComputeLayout(layoutTree, 900)
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(a11y.Build(document, layoutTree, nil))
+}
+
+// loadFrame fetches the document of an iframe, runs its scripts with the
+// page's runtime as window.parent and hands it to the tab
+func loadFrame(browser *render.Browser, req render.FrameRequest) {
+	tab := req.Tab
+	pageURL := req.URL
+
+	var document *dom.Node
+	if req.SrcDoc != "" {
+		document = dom.Parse(strings.NewReader(req.SrcDoc))
+	} else {
+		fmt.Println("Fetching frame:", pageURL)
+		resp, err := network.DefaultClient.Get(pageURL)
+		if err != nil {
+			fmt.Println("Failed to fetch frame:", err)
+			return
+		}
+		defer resp.Body.Close()
+		document = dom.ParseWithContentType(resp.Body, resp.Header.Get("Content-Type"))
+	}
+	if document == nil {
+		fmt.Println("Error: failed to parse frame HTML")
+		return
+	}
+
+	registry := fonts.Default.ForDocument()
+	externalCSS := fetchStylesheets(document, pageURL, registry)
+
+	jsRuntime := js.NewJSRuntime(document, func() {
+		tab.Reflow(browser.Width)
+	})
+	jsRuntime.SetAlertHandler(browser.ShowAlert)
+	jsRuntime.SetConfirmHandler(browser.ShowConfirm)
+	jsRuntime.SetPromptHandler(browser.ShowPrompt)
+	jsRuntime.SetConsoleHandler(tab.ConsoleMessage)
+	jsRuntime.SetCurrentURL(pageURL)
+	jsRuntime.SetImageLoader(func(src string) image.Image {
+		return render.LoadImage(resolveURL(pageURL, src))
+	})
+	if parent := js.RuntimeFor(req.Element.OwnerDocument()); parent != nil {
+		jsRuntime.SetParent(parent, req.Element)
+	}
+	for _, script := range js.FindScripts(document) {
+		jsRuntime.Execute(script)
+	}
+
+	tab.SetFrameDocument(req, render.FrameContent{
+		Document:    document,
+		URL:         pageURL,
+		ExternalCSS: externalCSS,
+		OnClick:     jsRuntime.DispatchClick,
+		Fonts:       registry,
+	})
+
+	if body := dom.FindElementsByTagName(document, dom.TagBody); body != nil {
+		if onload, ok := body.Attributes["onload"]; ok {
+			jsRuntime.Execute(onload)
+		}
+	}
+}
+
+// fetchStylesheets fetches the <link> stylesheets of a document in
+// parallel and returns them joined in document order. Web fonts of those
+// and of the document's <style> elements are loaded into registry on the way.
+func fetchStylesheets(document *dom.Node, pageURL string, registry *fonts.Registry) string {
+	links := dom.FindStylesheetLinks(document)
+	cssResults := make([]string, len(links))
+	var wg sync.WaitGroup
+
+	for i, link := range links {
+		wg.Add(1)
+		go func(idx int, href string) {
+			defer wg.Done()
+			absURL := resolveURL(pageURL, href)
+			fmt.Println("Fetching CSS:", absURL)
+			cssResp, err := network.DefaultClient.Get(absURL)
+			if err == nil {
+				data, _ := io.ReadAll(cssResp.Body)
+				cssResults[idx] = string(data)
+				cssResp.Body.Close()
+				// font URLs are relative to the stylesheet
+				loadWebFonts(css.Parse(cssResults[idx]).FontFaces, absURL, registry)
+			} else {
+				fmt.Println("Failed to fetch CSS:", err)
+			}
+		}(i, link)
+	}
+
+	wg.Wait()
+
+	// Combine external CSS in order
+	var externalCSS strings.Builder
+	for _, cssContent := range cssResults {
+		externalCSS.WriteString(cssContent + "\n")
+	}
+	loadWebFonts(css.Parse(dom.FindActiveStyleContent(document)).FontFaces, pageURL, registry)
+	return externalCSS.String()
+}
+
+// loadWebFonts fetches the @font-face rules of a stylesheet and registers
+// them in registry. Each face uses the first of its sources that loads.
+func loadWebFonts(faces []css.FontFace, baseURL string, registry *fonts.Registry) {
+	var wg sync.WaitGroup
+	for _, face := range faces {
+		wg.Add(1)
+		go func(face css.FontFace) {
+			defer wg.Done()
+			desc := fonts.Descriptor{Weight: face.Weight, Italic: face.Italic}
+			for _, src := range face.Sources {
+				fontURL := resolveURL(baseURL, src.URL)
+				if registry.Loaded(fontURL) {
+					return
+				}
+				data, err := fetchFont(fontURL)
+				if err == nil {
+					err = registry.Add(face.Family, desc, data, fontURL)
+				}
+				if err == nil {
+					return
+				}
+				fmt.Println("Failed to load font:", fontURL, err)
+			}
+		}(face)
+	}
+	wg.Wait()
+}
+
+// fetchFont returns the bytes of a font from an http(s) or data: URL
+func fetchFont(fontURL string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(fontURL, "data:"); ok {
+		meta, payload, found := strings.Cut(rest, ",")
+		if !found {
+			return nil, fmt.Errorf("malformed data URL")
+		}
+		if strings.HasSuffix(meta, ";base64") {
+			return base64.StdEncoding.DecodeString(payload)
+		}
+		decoded, err := url.PathUnescape(payload)
+		return []byte(decoded), err
+	}
+	resp, err := network.DefaultClient.Get(fontURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
 func resolveURL(baseURL, href string) string {
 	base, err := url.Parse(baseURL)
 	if err != nil {
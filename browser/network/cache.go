@@ -0,0 +1,270 @@
+package network
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaExt = ".meta"
+	bodyExt = ".body"
+)
+
+// Entry is a stored HTTP response
+type Entry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	StoredAt   time.Time   `json:"stored_at"` // when the response (or its last revalidation) arrived
+	Size       int64       `json:"size"`
+	Body       []byte      `json:"-"`
+}
+
+// Cache is a disk-backed response store with LRU eviction.
+// Each entry is kept as two files named after the SHA-256 of its URL:
+// <key>.meta (JSON metadata and headers) and <key>.body (raw bytes).
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	lru     *list.List               // front = most recently used, values are *lruItem
+	entries map[string]*list.Element // key -> element in lru
+	size    int64
+}
+
+type lruItem struct {
+	key  string
+	size int64
+}
+
+// OpenCache opens (or creates) a cache directory, restoring the LRU order
+// from the access times recorded on disk.
+func OpenCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		key      string
+		size     int64
+		accessed time.Time
+	}
+	var existing []found
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), metaExt) {
+			continue
+		}
+		key := strings.TrimSuffix(f.Name(), metaExt)
+		meta, err := c.readMeta(key)
+		if err != nil {
+			c.removeFiles(key)
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		existing = append(existing, found{key: key, size: meta.Size, accessed: info.ModTime()})
+	}
+
+	// Oldest first so that PushFront leaves the newest at the front
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].accessed.Before(existing[j].accessed)
+	})
+	for _, e := range existing {
+		c.entries[e.key] = c.lru.PushFront(&lruItem{key: e.key, size: e.size})
+		c.size += e.size
+	}
+	c.evict()
+
+	return c, nil
+}
+
+// DefaultCacheDir returns the per-user directory used for the HTTP cache
+func DefaultCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "go-browser", "http")
+}
+
+// Get returns the stored entry for url, or nil if none exists
+func (c *Cache) Get(url string) *Entry {
+	key := cacheKey(url)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry, err := c.readMeta(key)
+	if err != nil {
+		c.removeLocked(key)
+		return nil
+	}
+	body, err := os.ReadFile(c.path(key, bodyExt))
+	if err != nil {
+		c.removeLocked(key)
+		return nil
+	}
+	entry.Body = body
+
+	c.lru.MoveToFront(elem)
+	now := time.Now()
+	os.Chtimes(c.path(key, metaExt), now, now)
+
+	return entry
+}
+
+// Put stores an entry, evicting least recently used entries if the cache
+// grows beyond its size limit. Entries larger than the whole cache are skipped.
+func (c *Cache) Put(entry *Entry) error {
+	entry.Size = int64(len(entry.Body))
+	if c.maxBytes > 0 && entry.Size > c.maxBytes {
+		return nil
+	}
+
+	key := cacheKey(entry.URL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.WriteFile(c.path(key, bodyExt), entry.Body, 0644); err != nil {
+		return err
+	}
+	if err := c.writeMeta(key, entry); err != nil {
+		return err
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		item := elem.Value.(*lruItem)
+		c.size -= item.size
+		item.size = entry.Size
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.lru.PushFront(&lruItem{key: key, size: entry.Size})
+	}
+	c.size += entry.Size
+	c.evict()
+
+	return nil
+}
+
+// UpdateMeta rewrites headers and storage time of an existing entry
+// (used after a 304 Not Modified revalidation)
+func (c *Cache) UpdateMeta(entry *Entry) error {
+	key := cacheKey(entry.URL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		return nil
+	}
+	return c.writeMeta(key, entry)
+}
+
+// Remove deletes the entry for url
+func (c *Cache) Remove(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(cacheKey(url))
+}
+
+// Size returns the total body bytes currently stored
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of stored entries
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict drops least recently used entries until the cache fits. Caller holds mu.
+func (c *Cache) evict() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for c.size > c.maxBytes {
+		back := c.lru.Back()
+		if back == nil {
+			return
+		}
+		c.removeLocked(back.Value.(*lruItem).key)
+	}
+}
+
+func (c *Cache) removeLocked(key string) {
+	if elem, ok := c.entries[key]; ok {
+		c.size -= elem.Value.(*lruItem).size
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.removeFiles(key)
+}
+
+func (c *Cache) removeFiles(key string) {
+	os.Remove(c.path(key, metaExt))
+	os.Remove(c.path(key, bodyExt))
+}
+
+func (c *Cache) readMeta(key string) (*Entry, error) {
+	data, err := os.ReadFile(c.path(key, metaExt))
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *Cache) writeMeta(key string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key, metaExt), data, 0644)
+}
+
+func (c *Cache) path(key, ext string) string {
+	return filepath.Join(c.dir, key+ext)
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
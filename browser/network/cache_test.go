@@ -0,0 +1,105 @@
+package network
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntry(url string, size int) *Entry {
+	return &Entry{
+		URL:        url,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		StoredAt:   time.Now(),
+		Body:       make([]byte, size),
+	}
+}
+
+func TestCachePutGet(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(newEntry("http://example.com/a", 10)))
+
+	got := cache.Get("http://example.com/a")
+	require.NotNil(t, got)
+	assert.Equal(t, "http://example.com/a", got.URL)
+	assert.Equal(t, "text/plain", got.Header.Get("Content-Type"))
+	assert.Len(t, got.Body, 10)
+	assert.Nil(t, cache.Get("http://example.com/missing"))
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 100)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(newEntry("a", 40)))
+	require.NoError(t, cache.Put(newEntry("b", 40)))
+
+	// Touch "a" so "b" becomes least recently used
+	require.NotNil(t, cache.Get("a"))
+
+	require.NoError(t, cache.Put(newEntry("c", 40)))
+
+	assert.NotNil(t, cache.Get("a"))
+	assert.Nil(t, cache.Get("b"))
+	assert.NotNil(t, cache.Get("c"))
+	assert.Equal(t, int64(80), cache.Size())
+}
+
+func TestCacheSkipsOversizedEntry(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(newEntry("big", 11)))
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCacheReplaceEntry(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 100)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(newEntry("a", 30)))
+	require.NoError(t, cache.Put(newEntry("a", 50)))
+
+	assert.Equal(t, 1, cache.Len())
+	assert.Equal(t, int64(50), cache.Size())
+}
+
+func TestCachePersistsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenCache(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, cache.Put(newEntry("old", 40)))
+	require.NoError(t, cache.Put(newEntry("new", 40)))
+
+	// Make "old" clearly older on disk so it is evicted first after reopening
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, cacheKey("old")+metaExt), past, past))
+
+	reopened, err := OpenCache(dir, 60)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, reopened.Len())
+	assert.Nil(t, reopened.Get("old"))
+	assert.NotNil(t, reopened.Get("new"))
+}
+
+func TestCacheDropsCorruptMeta(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken"+metaExt), []byte("{"), 0644))
+
+	cache, err := OpenCache(dir, 100)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, cache.Len())
+	_, err = os.Stat(filepath.Join(dir, "broken"+metaExt))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is one entry of a RequestLog
+type Request struct {
+	Method   string
+	URL      string
+	Kind     string // "document", "stylesheet", "image", "font", "script" or "other"
+	Status   int    // 0 when the request failed
+	Cache    string // the CacheStatusHeader value, empty when not cached
+	Size     int64  // Content-Length, -1 when unknown
+	Start    time.Time
+	Duration time.Duration // until the response headers arrived
+	Err      error
+}
+
+// RequestLog keeps the most recent requests made through a LoggingTransport
+type RequestLog struct {
+	mu       sync.Mutex
+	entries  []Request
+	max      int
+	onChange func()
+}
+
+// DefaultLog records the requests of DefaultClient once EnableLogging is called
+var DefaultLog = NewRequestLog(500)
+
+// NewRequestLog returns a log keeping the last max requests
+func NewRequestLog(max int) *RequestLog {
+	return &RequestLog{max: max}
+}
+
+// EnableLogging routes DefaultClient through a LoggingTransport writing to
+// log. Call it after EnableCache so cache hits are logged too.
+func EnableLogging(log *RequestLog) {
+	DefaultClient = &http.Client{Transport: &LoggingTransport{Log: log, Base: DefaultClient.Transport}}
+}
+
+// Entries returns a copy of the logged requests, oldest first
+func (l *RequestLog) Entries() []Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Request(nil), l.entries...)
+}
+
+// Clear forgets all logged requests
+func (l *RequestLog) Clear() {
+	l.mu.Lock()
+	l.entries = nil
+	onChange := l.onChange
+	l.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// SetChangeHandler sets a function called after each change to the log
+func (l *RequestLog) SetChangeHandler(handler func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = handler
+}
+
+func (l *RequestLog) add(req Request) {
+	l.mu.Lock()
+	l.entries = append(l.entries, req)
+	if len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+	onChange := l.onChange
+	l.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// LoggingTransport is an http.RoundTripper that records every request in Log
+type LoggingTransport struct {
+	Log  *RequestLog
+	Base http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	entry := Request{Method: req.Method, URL: req.URL.String(), Size: -1, Start: time.Now()}
+	resp, err := base.RoundTrip(req)
+	entry.Duration = time.Since(entry.Start)
+	if err != nil {
+		entry.Err = err
+		entry.Kind = requestKind("", req.URL.Path)
+	} else {
+		entry.Status = resp.StatusCode
+		entry.Cache = resp.Header.Get(CacheStatusHeader)
+		entry.Size = resp.ContentLength
+		entry.Kind = requestKind(resp.Header.Get("Content-Type"), req.URL.Path)
+	}
+	t.Log.add(entry)
+	return resp, err
+}
+
+// requestKind classifies a request by its response type, or by the file
+// extension when there is no response
+func requestKind(contentType, urlPath string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType = mime.TypeByExtension(path.Ext(urlPath))
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return "document"
+	case mediaType == "text/css":
+		return "stylesheet"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "font/") || strings.Contains(mediaType, "font"):
+		return "font"
+	case strings.Contains(mediaType, "javascript"):
+		return "script"
+	}
+	return "other"
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/missing.png":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("x"))
+	}))
+	defer server.Close()
+
+	cached, _ := newTestClient(t)
+	log := NewRequestLog(2)
+	changes := 0
+	log.SetChangeHandler(func() { changes++ })
+	client := &http.Client{Transport: &LoggingTransport{Log: log, Base: cached.Transport}}
+
+	fetch(t, client, server.URL+"/style.css")
+	fetch(t, client, server.URL+"/style.css")
+	entries := log.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "stylesheet", entries[0].Kind)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, "MISS", entries[0].Cache)
+	assert.Equal(t, "HIT", entries[1].Cache)
+	assert.Equal(t, int64(1), entries[1].Size)
+
+	fetch(t, client, server.URL+"/missing.png")
+	entries = log.Entries()
+	require.Len(t, entries, 2, "only the last two are kept")
+	assert.Equal(t, http.StatusNotFound, entries[1].Status)
+	assert.Equal(t, "image", entries[1].Kind, "classified by extension without a type")
+	assert.Equal(t, 3, changes)
+
+	log.Clear()
+	assert.Empty(t, log.Entries())
+}
+
+func TestRequestKind(t *testing.T) {
+	assert.Equal(t, "document", requestKind("text/html; charset=utf-8", "/"))
+	assert.Equal(t, "image", requestKind("image/png", "/a"))
+	assert.Equal(t, "font", requestKind("font/woff2", "/a"))
+	assert.Equal(t, "script", requestKind("", "/app.js"))
+	assert.Equal(t, "other", requestKind("", "/data"))
+}
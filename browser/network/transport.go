@@ -0,0 +1,238 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultClient is used for page, stylesheet and image requests.
+// It talks to the network directly until EnableCache is called.
+var DefaultClient = http.DefaultClient
+
+// CacheStatusHeader is added to responses served by Transport: "HIT" when
+// the body came from disk without contacting the server, "REVALIDATED" after
+// a 304, and "MISS" otherwise.
+const CacheStatusHeader = "X-Cache"
+
+// EnableCache opens a disk cache and routes DefaultClient through it
+func EnableCache(dir string, maxBytes int64) error {
+	cache, err := OpenCache(dir, maxBytes)
+	if err != nil {
+		return err
+	}
+	DefaultClient = &http.Client{Transport: NewTransport(cache, nil)}
+	return nil
+}
+
+// Transport is an http.RoundTripper that serves GET requests from a Cache,
+// honoring Cache-Control, Expires, ETag and Last-Modified.
+type Transport struct {
+	Cache *Cache
+	Base  http.RoundTripper
+
+	now func() time.Time
+}
+
+// NewTransport wraps base (http.DefaultTransport if nil) with cache
+func NewTransport(cache *Cache, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Cache: cache, Base: base, now: time.Now}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Cache == nil || req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.Base.RoundTrip(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.Base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	cached := t.Cache.Get(url)
+
+	if cached != nil {
+		_, reqNoCache := reqCC["no-cache"]
+		if !reqNoCache && t.isFresh(cached) {
+			return cachedResponse(req, cached, "HIT"), nil
+		}
+
+		condReq := req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			condReq.Header.Set("If-None-Match", etag)
+		}
+		if lastMod := cached.Header.Get("Last-Modified"); lastMod != "" {
+			condReq.Header.Set("If-Modified-Since", lastMod)
+		}
+
+		resp, err := t.Base.RoundTrip(condReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			for name, values := range resp.Header {
+				cached.Header[name] = values
+			}
+			cached.StoredAt = t.now()
+			t.Cache.UpdateMeta(cached)
+			return cachedResponse(req, cached, "REVALIDATED"), nil
+		}
+
+		return t.store(req, resp)
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.store(req, resp)
+}
+
+// store saves a cacheable response and returns a copy whose body can still be read
+func (t *Transport) store(req *http.Request, resp *http.Response) (*http.Response, error) {
+	if !isCacheable(resp) {
+		if resp.StatusCode == http.StatusOK {
+			t.Cache.Remove(req.URL.String())
+		}
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		StoredAt:   t.now(),
+		Body:       body,
+	}
+	if err := t.Cache.Put(entry); err != nil {
+		fmt.Println("Cache write failed:", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.Header.Set(CacheStatusHeader, "MISS")
+	return resp, nil
+}
+
+// isFresh reports whether entry can be served without revalidation
+func (t *Transport) isFresh(entry *Entry) bool {
+	cc := parseCacheControl(entry.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+
+	lifetime := freshnessLifetime(entry.Header, entry.StoredAt)
+	return currentAge(entry.Header, entry.StoredAt, t.now()) < lifetime
+}
+
+// freshnessLifetime follows RFC 9111 4.2.1: max-age, then Expires - Date,
+// then a heuristic of 10% of the time since Last-Modified
+func freshnessLifetime(h http.Header, storedAt time.Time) time.Duration {
+	cc := parseCacheControl(h)
+	if v, ok := cc["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		return 0
+	}
+
+	date := storedAt
+	if d, err := http.ParseTime(h.Get("Date")); err == nil {
+		date = d
+	}
+
+	if exp := h.Get("Expires"); exp != "" {
+		expires, err := http.ParseTime(exp)
+		if err != nil {
+			return 0 // invalid Expires means already expired
+		}
+		return expires.Sub(date)
+	}
+
+	if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil && date.After(lm) {
+		return date.Sub(lm) / 10
+	}
+
+	return 0
+}
+
+// currentAge is the Age header plus time spent in the cache
+func currentAge(h http.Header, storedAt, now time.Time) time.Duration {
+	age := now.Sub(storedAt)
+	if v := h.Get("Age"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			age += time.Duration(secs) * time.Second
+		}
+	}
+	return age
+}
+
+// isCacheable reports whether a response may be stored
+func isCacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	// Without any freshness or validator information the entry could never be reused
+	_, hasMaxAge := cc["max-age"]
+	_, hasNoCache := cc["no-cache"]
+	return hasMaxAge || hasNoCache ||
+		resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+func cachedResponse(req *http.Request, entry *Entry, status string) *http.Response {
+	header := entry.Header.Clone()
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// parseCacheControl splits a Cache-Control header into directives.
+// Directives without a value map to "".
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
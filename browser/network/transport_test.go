@@ -0,0 +1,225 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client whose cache lives in a temp dir and whose
+// clock can be moved forward through the returned pointer
+func newTestClient(t *testing.T) (*http.Client, *time.Time) {
+	cache, err := OpenCache(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+
+	now := time.Now()
+	transport := NewTransport(cache, nil)
+	transport.now = func() time.Time { return now }
+	return &http.Client{Transport: transport}, &now
+}
+
+func fetch(t *testing.T, client *http.Client, url string) (string, string) {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body), resp.Header.Get(CacheStatusHeader)
+}
+
+func TestTransportMaxAge(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client, now := newTestClient(t)
+
+	body, status := fetch(t, client, server.URL)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, "MISS", status)
+
+	body, status = fetch(t, client, server.URL)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, "HIT", status)
+	assert.Equal(t, 1, hits)
+
+	*now = now.Add(2 * time.Minute)
+	_, status = fetch(t, client, server.URL)
+	assert.Equal(t, "MISS", status)
+	assert.Equal(t, 2, hits)
+}
+
+func TestTransportETagRevalidation(t *testing.T) {
+	hits, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("etag body"))
+	}))
+	defer server.Close()
+
+	client, _ := newTestClient(t)
+
+	fetch(t, client, server.URL)
+	body, status := fetch(t, client, server.URL)
+
+	assert.Equal(t, "etag body", body)
+	assert.Equal(t, "REVALIDATED", status)
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 1, notModified)
+}
+
+func TestTransportLastModifiedRevalidation(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	var gotIfModifiedSince string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Cache-Control", "max-age=0")
+		if gotIfModifiedSince == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("lm body"))
+	}))
+	defer server.Close()
+
+	client, now := newTestClient(t)
+
+	fetch(t, client, server.URL)
+	*now = now.Add(time.Second)
+	body, status := fetch(t, client, server.URL)
+
+	assert.Equal(t, lastModified, gotIfModifiedSince)
+	assert.Equal(t, "lm body", body)
+	assert.Equal(t, "REVALIDATED", status)
+}
+
+func TestTransportExpires(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		now := time.Now().UTC()
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+		w.Write([]byte("expires body"))
+	}))
+	defer server.Close()
+
+	client, now := newTestClient(t)
+
+	fetch(t, client, server.URL)
+	_, status := fetch(t, client, server.URL)
+	assert.Equal(t, "HIT", status)
+
+	*now = now.Add(2 * time.Hour)
+	_, status = fetch(t, client, server.URL)
+	assert.Equal(t, "MISS", status)
+	assert.Equal(t, 2, hits)
+}
+
+func TestTransportNotStored(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"no-store", map[string]string{"Cache-Control": "no-store, max-age=60"}, http.StatusOK},
+		{"vary star", map[string]string{"Cache-Control": "max-age=60", "Vary": "*"}, http.StatusOK},
+		{"no validators", map[string]string{}, http.StatusOK},
+		{"not found", map[string]string{"Cache-Control": "max-age=60"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte("x"))
+			}))
+			defer server.Close()
+
+			client, _ := newTestClient(t)
+			fetch(t, client, server.URL)
+			_, status := fetch(t, client, server.URL)
+
+			assert.Equal(t, "", status)
+			assert.Equal(t, 2, hits)
+		})
+	}
+}
+
+func TestTransportSkipsPost(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("posted"))
+	}))
+	defer server.Close()
+
+	client, _ := newTestClient(t)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(server.URL, "text/plain", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, 2, hits)
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	date := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=300"}}, 5 * time.Minute},
+		{"max-age wins over expires", http.Header{
+			"Cache-Control": {"max-age=10"},
+			"Expires":       {date.Add(time.Hour).Format(http.TimeFormat)},
+		}, 10 * time.Second},
+		{"expires minus date", http.Header{
+			"Date":    {date.Format(http.TimeFormat)},
+			"Expires": {date.Add(time.Hour).Format(http.TimeFormat)},
+		}, time.Hour},
+		{"invalid expires", http.Header{"Expires": {"0"}}, 0},
+		{"last-modified heuristic", http.Header{
+			"Date":          {date.Format(http.TimeFormat)},
+			"Last-Modified": {date.Add(-10 * time.Hour).Format(http.TimeFormat)},
+		}, time.Hour},
+		{"nothing", http.Header{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, freshnessLifetime(tt.header, date))
+		})
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	h := http.Header{"Cache-Control": {`no-cache, max-age="30"`, "Private"}}
+	cc := parseCacheControl(h)
+
+	assert.Equal(t, map[string]string{"no-cache": "", "max-age": "30", "private": ""}, cc)
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"browser/animation"
+	"browser/layout"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// usesAnimations reports whether any box in the tree declares an animation
+func usesAnimations(box *layout.LayoutBox) bool {
+	if len(box.Style.Animations) > 0 {
+		return true
+	}
+	for _, child := range box.Children {
+		if usesAnimations(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetAnimations drops every transition and animation, stopping the frame
+// clock. Called with reflowMu held when the page goes away.
+func (t *Tab) resetAnimations() {
+	t.animations = animation.NewEngine()
+	t.animating = false
+}
+
+// startAnimationClock starts drawing animation frames unless it already
+// runs. Called with reflowMu held.
+func (t *Tab) startAnimationClock() {
+	if t.animating {
+		return
+	}
+	t.animating = true
+	go t.runAnimationClock(t.animations)
+}
+
+func (t *Tab) runAnimationClock(engine *animation.Engine) {
+	ticker := time.NewTicker(animation.FrameInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if !t.animationFrame(engine, now) {
+			return
+		}
+	}
+}
+
+// animationFrame advances the animations to now, lays out what they
+// changed and repaints. It returns false when no more frames are needed
+// or engine no longer belongs to the page.
+func (t *Tab) animationFrame(engine *animation.Engine, now time.Time) bool {
+	t.reflowMu.Lock()
+	if engine != t.animations || t.layoutTree == nil {
+		t.reflowMu.Unlock()
+		return false
+	}
+	active := engine.Tick(now)
+	if !active {
+		t.animating = false
+	}
+	layout.ComputeLayout(t.layoutTree, t.layoutViewport.Width)
+	t.layoutFrames(t.layoutTree)
+	commands := BuildDisplayListCached(t.layoutTree, t.inputState(), t.displayCache)
+	t.reflowMu.Unlock()
+
+	// Background tabs keep time but are not drawn
+	if t.isActive() {
+		objects := RenderToCanvas(commands, t.baseURL(), true, nil)
+		fyne.Do(func() {
+			t.display(objects, t.scrollPosition())
+		})
+	}
+	return active
+}
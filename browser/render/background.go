@@ -0,0 +1,546 @@
+package render
+
+import (
+	"browser/css"
+	"browser/layout"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// borderRadius resolves the box's border-radius, which can be no more than
+// half its shorter side
+func borderRadius(box *layout.LayoutBox) float64 {
+	radius := box.Style.BorderRadius
+	shorter := math.Min(box.Rect.Width, box.Rect.Height)
+	if box.Style.BorderRadiusPercent > 0 {
+		radius = box.Style.BorderRadiusPercent / 100 * shorter
+	}
+	return math.Max(0, math.Min(radius, shorter/2))
+}
+
+// paintBackground emits a box's shadows, background color, gradient and
+// image, and its borders, from back to front
+func paintBackground(box *layout.LayoutBox, opacity float64, commands *[]DisplayCommand) {
+	style := box.Style
+	radius := borderRadius(box)
+
+	shadow := func(inset bool) {
+		// The first shadow in the list is painted on top
+		for i := len(style.BoxShadows) - 1; i >= 0; i-- {
+			s := style.BoxShadows[i]
+			if s.Inset != inset {
+				continue
+			}
+			if s.Color == nil {
+				s.Color = style.Color
+				if s.Color == nil {
+					s.Color = color.Black
+				}
+			}
+			s.Color = applyOpacity(s.Color, opacity)
+			*commands = append(*commands, DrawShadow{Rect: box.Rect, CornerRadius: radius, Shadow: s})
+		}
+	}
+	shadow(false)
+
+	if style.BackgroundColor != nil {
+		*commands = append(*commands, DrawRect{
+			Rect:         box.Rect,
+			Color:        applyOpacity(style.BackgroundColor, opacity),
+			CornerRadius: radius,
+		})
+	}
+
+	if g := style.BackgroundGradient; g != nil {
+		faded := *g
+		faded.Stops = make([]css.ColorStop, len(g.Stops))
+		for i, stop := range g.Stops {
+			stop.Color = applyOpacity(stop.Color, opacity)
+			faded.Stops[i] = stop
+		}
+		*commands = append(*commands, DrawGradient{Rect: box.Rect, Gradient: &faded, CornerRadius: radius})
+	}
+
+	if style.BackgroundImage != "" {
+		*commands = append(*commands, DrawBackground{
+			Rect:         box.Rect,
+			URL:          style.BackgroundImage,
+			Repeat:       style.BackgroundRepeat,
+			Size:         style.BackgroundSize,
+			Position:     style.BackgroundPosition,
+			CornerRadius: radius,
+		})
+	}
+
+	shadow(true)
+	paintBorders(box, radius, opacity, commands)
+}
+
+// borderSide is one side of a box's border
+type borderSide struct {
+	rect     layout.Rect
+	width    float64
+	color    color.Color
+	style    string
+	vertical bool
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// paintBorders draws the four borders of a box. A rounded box whose sides
+// all match gets one stroked rounded rectangle; otherwise each side is a
+// strip, split into dashes or dots for those styles.
+func paintBorders(box *layout.LayoutBox, radius, opacity float64, commands *[]DisplayCommand) {
+	st, r := box.Style, box.Rect
+	sides := []borderSide{
+		{layout.Rect{X: r.X, Y: r.Y, Width: r.Width, Height: st.BorderTopWidth}, st.BorderTopWidth, st.BorderTopColor, st.BorderTopStyle, false},
+		{layout.Rect{X: r.X, Y: r.Y + r.Height - st.BorderBottomWidth, Width: r.Width, Height: st.BorderBottomWidth}, st.BorderBottomWidth, st.BorderBottomColor, st.BorderBottomStyle, false},
+		{layout.Rect{X: r.X, Y: r.Y, Width: st.BorderLeftWidth, Height: r.Height}, st.BorderLeftWidth, st.BorderLeftColor, st.BorderLeftStyle, true},
+		{layout.Rect{X: r.X + r.Width - st.BorderRightWidth, Y: r.Y, Width: st.BorderRightWidth, Height: r.Height}, st.BorderRightWidth, st.BorderRightColor, st.BorderRightStyle, true},
+	}
+	visible := func(side borderSide) bool {
+		return side.width > 0 && side.color != nil && side.style != "none" && side.style != "hidden"
+	}
+
+	uniform := radius > 0
+	for _, side := range sides {
+		uniform = uniform && visible(side) && side.width == sides[0].width &&
+			side.style == sides[0].style && sameColor(side.color, sides[0].color)
+	}
+	if uniform && sides[0].style != "dashed" && sides[0].style != "dotted" {
+		w := sides[0].width
+		*commands = append(*commands, DrawRect{
+			Rect:         layout.Rect{X: r.X + w/2, Y: r.Y + w/2, Width: r.Width - w, Height: r.Height - w},
+			Color:        color.Transparent,
+			CornerRadius: math.Max(0, radius-w/2),
+			StrokeColor:  applyOpacity(sides[0].color, opacity),
+			StrokeWidth:  w,
+		})
+		return
+	}
+
+	for _, side := range sides {
+		if !visible(side) {
+			continue
+		}
+		c := applyOpacity(side.color, opacity)
+		switch side.style {
+		case "dashed":
+			borderSegments(side, c, 3*side.width, 2*side.width, 0, commands)
+		case "dotted":
+			borderSegments(side, c, side.width, side.width, side.width/2, commands)
+		default:
+			*commands = append(*commands, DrawRect{Rect: side.rect, Color: c})
+		}
+	}
+}
+
+// borderSegments splits a border side into dashes of length dash separated
+// by gap, stretching the gaps so the side starts and ends with a dash
+func borderSegments(side borderSide, c color.Color, dash, gap, cornerRadius float64, commands *[]DisplayCommand) {
+	length := side.rect.Width
+	if side.vertical {
+		length = side.rect.Height
+	}
+	if dash <= 0 || length <= 0 {
+		return
+	}
+	n := math.Max(1, math.Floor((length+gap)/(dash+gap)))
+	if n > 1 {
+		gap = (length - n*dash) / (n - 1)
+	} else {
+		dash = length
+	}
+	for i := 0; i < int(n); i++ {
+		offset := float64(i) * (dash + gap)
+		seg := side.rect
+		if side.vertical {
+			seg.Y += offset
+			seg.Height = dash
+		} else {
+			seg.X += offset
+			seg.Width = dash
+		}
+		*commands = append(*commands, DrawRect{Rect: seg, Color: c, CornerRadius: cornerRadius})
+	}
+}
+
+// roundedCoverage is how much of the pixel centered on (x, y) lies inside
+// the rounded rectangle, estimated from its distance to the edge
+func roundedCoverage(x, y float64, r layout.Rect, radius float64) float64 {
+	dx := math.Min(x-r.X, r.X+r.Width-x)
+	dy := math.Min(y-r.Y, r.Y+r.Height-y)
+	d := math.Min(dx, dy)
+	if radius > 0 && dx < radius && dy < radius {
+		d = radius - math.Hypot(radius-dx, radius-dy)
+	}
+	return math.Max(0, math.Min(1, d+0.5))
+}
+
+// shadowImage rasterizes a box shadow: the offset and spread shape is
+// blurred, then cut away where the box itself is (outer shadows) or
+// outside it (inset shadows)
+func shadowImage(c DrawShadow) *canvas.Image {
+	s := c.Shadow
+	spread := s.Spread
+	if s.Inset {
+		spread = -spread
+	}
+	shape := layout.Rect{
+		X:      c.X + s.OffsetX - spread,
+		Y:      c.Y + s.OffsetY - spread,
+		Width:  c.Width + 2*spread,
+		Height: c.Height + 2*spread,
+	}
+	shapeRadius := 0.0
+	if c.CornerRadius > 0 {
+		shapeRadius = math.Max(0, c.CornerRadius+spread)
+	}
+
+	area := shape
+	if s.Inset {
+		area = c.Rect
+	}
+	pad := math.Ceil(s.Blur) + 1
+	area = layout.Rect{X: math.Floor(area.X - pad), Y: math.Floor(area.Y - pad), Width: math.Ceil(area.Width + 2*pad), Height: math.Ceil(area.Height + 2*pad)}
+	w, h := int(area.Width)+1, int(area.Height)+1
+	if w <= 0 || h <= 0 || w*h > maxBackgroundPixels {
+		return nil
+	}
+
+	alpha := make([]float64, w*h)
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			cov := roundedCoverage(area.X+float64(px)+0.5, area.Y+float64(py)+0.5, shape, shapeRadius)
+			if s.Inset {
+				cov = 1 - cov
+			}
+			alpha[py*w+px] = cov
+		}
+	}
+	blurAlpha(alpha, w, h, s.Blur/2)
+
+	cr, cg, cb, ca := s.Color.RGBA()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			box := roundedCoverage(area.X+float64(px)+0.5, area.Y+float64(py)+0.5, c.Rect, c.CornerRadius)
+			if !s.Inset {
+				box = 1 - box
+			}
+			a := alpha[py*w+px] * box
+			if a <= 0 {
+				continue
+			}
+			img.SetRGBA(px, py, color.RGBA{
+				R: uint8(float64(cr>>8) * a),
+				G: uint8(float64(cg>>8) * a),
+				B: uint8(float64(cb>>8) * a),
+				A: uint8(float64(ca>>8) * a),
+			})
+		}
+	}
+
+	obj := canvas.NewImageFromImage(img)
+	obj.FillMode = canvas.ImageFillStretch
+	obj.Resize(fyne.NewSize(float32(w), float32(h)))
+	obj.Move(fyne.NewPos(float32(area.X), float32(area.Y)))
+	return obj
+}
+
+// blurAlpha approximates a gaussian blur of standard deviation sigma with
+// three box blurs in each direction
+func blurAlpha(alpha []float64, w, h int, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+	// Three passes of radius r have variance ((2r+1)²-1)/4
+	r := int(math.Round((math.Sqrt(4*sigma*sigma+1) - 1) / 2))
+	if r < 1 {
+		r = 1
+	}
+	tmp := make([]float64, max(w, h))
+	for pass := 0; pass < 3; pass++ {
+		for y := 0; y < h; y++ {
+			boxBlurLine(alpha[y*w:(y+1)*w], 1, tmp[:w], r)
+		}
+		for x := 0; x < w; x++ {
+			boxBlurLine(alpha[x:], w, tmp[:h], r)
+		}
+	}
+}
+
+// boxBlurLine averages each of len(tmp) values, stride apart in line, with
+// its r neighbours on either side
+func boxBlurLine(line []float64, stride int, tmp []float64, r int) {
+	n := len(tmp)
+	for i := range tmp {
+		tmp[i] = line[i*stride]
+	}
+	sum := 0.0
+	for i := -r; i <= r; i++ {
+		if i >= 0 && i < n {
+			sum += tmp[i]
+		}
+	}
+	for i := 0; i < n; i++ {
+		line[i*stride] = sum / float64(2*r+1)
+		if out := i - r; out >= 0 {
+			sum -= tmp[out]
+		}
+		if in := i + r + 1; in < n {
+			sum += tmp[in]
+		}
+	}
+}
+
+// maxBackgroundPixels bounds the bitmaps drawn for shadows and tiled
+// backgrounds
+const maxBackgroundPixels = 4096 * 4096
+
+// resolvedStops returns the gradient's stops with every position filled in:
+// the first and last default to the ends, others are spread evenly between
+// their neighbours, and none comes before the one preceding it
+func resolvedStops(stops []css.ColorStop) []css.ColorStop {
+	out := make([]css.ColorStop, len(stops))
+	copy(out, stops)
+	if !out[0].HasPosition {
+		out[0].Position, out[0].HasPosition = 0, true
+	}
+	if last := len(out) - 1; !out[last].HasPosition {
+		out[last].Position, out[last].HasPosition = 1, true
+	}
+	for i := 1; i < len(out); i++ {
+		if out[i].HasPosition {
+			out[i].Position = math.Max(out[i].Position, out[i-1].Position)
+			continue
+		}
+		j := i
+		for !out[j].HasPosition {
+			j++
+		}
+		start, end := out[i-1].Position, math.Max(out[j].Position, out[i-1].Position)
+		for k := i; k < j; k++ {
+			out[k].Position = start + (end-start)*float64(k-i+1)/float64(j-i+1)
+			out[k].HasPosition = true
+		}
+	}
+	return out
+}
+
+// gradientColor is the color at t along resolved stops, mixed with
+// premultiplied alpha
+func gradientColor(stops []css.ColorStop, t float64) color.Color {
+	if t <= stops[0].Position {
+		return stops[0].Color
+	}
+	for i := 1; i < len(stops); i++ {
+		if t > stops[i].Position {
+			continue
+		}
+		a, b := stops[i-1], stops[i]
+		f := 1.0
+		if span := b.Position - a.Position; span > 0 {
+			f = (t - a.Position) / span
+		}
+		r1, g1, b1, a1 := a.Color.RGBA()
+		r2, g2, b2, a2 := b.Color.RGBA()
+		mix := func(x, y uint32) uint8 {
+			return uint8((float64(x)*(1-f) + float64(y)*f) / 257)
+		}
+		return color.RGBA{mix(r1, r2), mix(g1, g2), mix(b1, b2), mix(a1, a2)}
+	}
+	return stops[len(stops)-1].Color
+}
+
+// gradientPosition returns the function giving how far along the gradient
+// a point of a w×h box lies
+func gradientPosition(g *css.Gradient, w, h float64) func(x, y float64) float64 {
+	if g.Radial {
+		cx, cy := g.CenterX.Resolve(w), g.CenterY.Resolve(h)
+		dx, dy := math.Max(cx, w-cx), math.Max(cy, h-cy)
+		if g.Circle {
+			radius := math.Max(math.Hypot(dx, dy), 1e-9)
+			return func(x, y float64) float64 {
+				return math.Hypot(x-cx, y-cy) / radius
+			}
+		}
+		// The ellipse through the farthest corner with the box's proportions
+		rx, ry := math.Max(dx*math.Sqrt2, 1e-9), math.Max(dy*math.Sqrt2, 1e-9)
+		return func(x, y float64) float64 {
+			return math.Hypot((x-cx)/rx, (y-cy)/ry)
+		}
+	}
+
+	angle := g.Angle * math.Pi / 180
+	corner := math.Atan2(h, w)
+	switch g.Corner {
+	case "top right":
+		angle = corner
+	case "bottom right":
+		angle = math.Pi - corner
+	case "bottom left":
+		angle = math.Pi + corner
+	case "top left":
+		angle = 2*math.Pi - corner
+	}
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	// The gradient line is just long enough for its ends to touch the corners
+	length := math.Max(math.Abs(w*sin)+math.Abs(h*cos), 1e-9)
+	return func(x, y float64) float64 {
+		return ((x-w/2)*sin-(y-h/2)*cos)/length + 0.5
+	}
+}
+
+// gradientRaster draws a gradient at the canvas's pixel density
+func gradientRaster(c DrawGradient) fyne.CanvasObject {
+	stops := resolvedStops(c.Gradient.Stops)
+	at := gradientPosition(c.Gradient, c.Width, c.Height)
+
+	raster := canvas.NewRasterWithPixels(func(px, py, w, h int) color.Color {
+		// Raster pixels → box coordinates
+		x := (float64(px) + 0.5) * c.Width / float64(w)
+		y := (float64(py) + 0.5) * c.Height / float64(h)
+		col := gradientColor(stops, at(x, y))
+		if c.CornerRadius > 0 {
+			scale := float64(w) / c.Width
+			cov := roundedCoverage(x*scale, y*scale, layout.Rect{Width: c.Width * scale, Height: c.Height * scale}, c.CornerRadius*scale)
+			if cov < 1 {
+				r, g, b, a := col.RGBA()
+				return color.RGBA64{uint16(float64(r) * cov), uint16(float64(g) * cov), uint16(float64(b) * cov), uint16(float64(a) * cov)}
+			}
+		}
+		return col
+	})
+	raster.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
+	raster.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+	return raster
+}
+
+// backgroundTileSize is the size one background image tile is drawn at
+func backgroundTileSize(c DrawBackground, iw, ih float64) (float64, float64) {
+	size := c.Size
+	switch {
+	case size.Keyword == "cover":
+		scale := math.Max(c.Width/iw, c.Height/ih)
+		return iw * scale, ih * scale
+	case size.Keyword == "contain":
+		scale := math.Min(c.Width/iw, c.Height/ih)
+		return iw * scale, ih * scale
+	case size == css.BackgroundSize{} || size.Width.Auto && size.Height.Auto:
+		return iw, ih
+	case size.Width.Auto:
+		h := size.Height.Resolve(c.Height)
+		return iw * h / ih, h
+	case size.Height.Auto:
+		w := size.Width.Resolve(c.Width)
+		return w, ih * w / iw
+	}
+	return size.Width.Resolve(c.Width), size.Height.Resolve(c.Height)
+}
+
+// backgroundOffset places the first tile along one axis: percentages line
+// up the same point of the tile and the box
+func backgroundOffset(l css.Length, box, tile float64) float64 {
+	if l.Percent {
+		return (box - tile) * l.Value / 100
+	}
+	return l.Value
+}
+
+// backgroundObject draws a background image sized, positioned and tiled
+// over the box. Nothing is drawn until the image has loaded.
+func backgroundObject(c DrawBackground, baseURL string, onLoad func()) fyne.CanvasObject {
+	src := cachedImage(c.URL, baseURL, onLoad)
+	if src == nil || c.Width <= 0 || c.Height <= 0 {
+		return nil
+	}
+	b := src.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+	tw, th := backgroundTileSize(c, float64(b.Dx()), float64(b.Dy()))
+	if tw < 0.5 || th < 0.5 {
+		return nil
+	}
+	ox := backgroundOffset(c.Position.X, c.Width, tw)
+	oy := backgroundOffset(c.Position.Y, c.Height, th)
+
+	repeatX := c.Repeat != "no-repeat" && c.Repeat != "repeat-y"
+	repeatY := c.Repeat != "no-repeat" && c.Repeat != "repeat-x"
+
+	// A single tile inside a square box needs no bitmap of its own
+	if !repeatX && !repeatY && c.CornerRadius == 0 &&
+		ox >= 0 && oy >= 0 && ox+tw <= c.Width && oy+th <= c.Height {
+		img := canvas.NewImageFromImage(src)
+		img.FillMode = canvas.ImageFillStretch
+		img.Resize(fyne.NewSize(float32(tw), float32(th)))
+		img.Move(fyne.NewPos(float32(c.X+ox), float32(c.Y+oy)))
+		return img
+	}
+
+	w, h := int(math.Ceil(c.Width)), int(math.Ceil(c.Height))
+	if w*h > maxBackgroundPixels {
+		return nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	tile := image.NewRGBA(image.Rect(0, 0, int(math.Round(tw)), int(math.Round(th))))
+	xdraw.ApproxBiLinear.Scale(tile, tile.Bounds(), src, b, draw.Src, nil)
+
+	startX, startY := ox, oy
+	if repeatX {
+		startX = math.Mod(ox, tw)
+		if startX > 0 {
+			startX -= tw
+		}
+	}
+	if repeatY {
+		startY = math.Mod(oy, th)
+		if startY > 0 {
+			startY -= th
+		}
+	}
+	for y := startY; y < c.Height; y += th {
+		for x := startX; x < c.Width; x += tw {
+			at := image.Pt(int(math.Round(x)), int(math.Round(y)))
+			draw.Draw(dst, tile.Bounds().Add(at), tile, image.Point{}, draw.Over)
+			if !repeatX {
+				break
+			}
+		}
+		if !repeatY {
+			break
+		}
+	}
+
+	if c.CornerRadius > 0 {
+		box := layout.Rect{Width: float64(w), Height: float64(h)}
+		for py := 0; py < h; py++ {
+			for px := 0; px < w; px++ {
+				cov := roundedCoverage(float64(px)+0.5, float64(py)+0.5, box, c.CornerRadius)
+				if cov >= 1 {
+					continue
+				}
+				p := dst.RGBAAt(px, py)
+				dst.SetRGBA(px, py, color.RGBA{uint8(float64(p.R) * cov), uint8(float64(p.G) * cov), uint8(float64(p.B) * cov), uint8(float64(p.A) * cov)})
+			}
+		}
+	}
+
+	img := canvas.NewImageFromImage(dst)
+	img.FillMode = canvas.ImageFillStretch
+	img.Resize(fyne.NewSize(float32(w), float32(h)))
+	img.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+	return img
+}
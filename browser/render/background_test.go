@@ -0,0 +1,150 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func paintStyled(t *testing.T, style string) []DisplayCommand {
+	t.Helper()
+	doc := dom.Parse(strings.NewReader(`<div style="width: 100px; height: 40px; ` + style + `"></div>`))
+	root := layout.BuildLayoutTree(doc, css.Stylesheet{}, layout.Viewport{Width: 800})
+	layout.ComputeLayout(root, 800)
+	// Leave out the page background
+	var commands []DisplayCommand
+	for _, cmd := range BuildDisplayList(root) {
+		if r, ok := cmd.(DrawRect); ok && r.Width > 200 {
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+func TestPaintBackgroundLayers(t *testing.T) {
+	commands := paintStyled(t, "background: red linear-gradient(red, blue); border-radius: 50%; box-shadow: inset 0 0 2px blue, 0 2px 4px black")
+
+	var order []string
+	for _, cmd := range commands {
+		switch c := cmd.(type) {
+		case DrawShadow:
+			if c.Shadow.Inset {
+				order = append(order, "inset")
+			} else {
+				order = append(order, "shadow")
+			}
+			assert.Equal(t, 20.0, c.CornerRadius, "50% of the shorter side")
+		case DrawRect:
+			order = append(order, "color")
+			assert.Equal(t, 20.0, c.CornerRadius)
+		case DrawGradient:
+			order = append(order, "gradient")
+		}
+	}
+	assert.Equal(t, []string{"shadow", "color", "gradient", "inset"}, order)
+}
+
+func TestPaintBorderStyles(t *testing.T) {
+	var rects []DrawRect
+	for _, cmd := range paintStyled(t, "border: 2px solid black; border-radius: 6px") {
+		if r, ok := cmd.(DrawRect); ok {
+			rects = append(rects, r)
+		}
+	}
+	require.Len(t, rects, 1, "a uniform rounded border is one stroked rect")
+	assert.Equal(t, 2.0, rects[0].StrokeWidth)
+	assert.Equal(t, 5.0, rects[0].CornerRadius)
+
+	rects = nil
+	for _, cmd := range paintStyled(t, "border-top: 4px dotted black") {
+		if r, ok := cmd.(DrawRect); ok {
+			rects = append(rects, r)
+		}
+	}
+	require.Greater(t, len(rects), 1)
+	for _, r := range rects {
+		assert.Equal(t, 4.0, r.Width)
+		assert.Equal(t, 2.0, r.CornerRadius)
+	}
+	// Gaps are stretched evenly so the dots span the whole side
+	gap := rects[1].X - rects[0].X
+	assert.GreaterOrEqual(t, gap, 8.0)
+	for i := 2; i < len(rects); i++ {
+		assert.InDelta(t, gap, rects[i].X-rects[i-1].X, 1e-9)
+	}
+}
+
+func TestGradientColors(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	stops := resolvedStops([]css.ColorStop{{Color: red}, {Color: red}, {Color: blue, Position: 0.8, HasPosition: true}})
+	assert.InDelta(t, 0.4, stops[1].Position, 1e-9)
+
+	mid := gradientColor(stops, 0.6).(color.RGBA)
+	assert.Equal(t, color.RGBA{127, 0, 127, 255}, mid)
+	assert.Equal(t, blue, gradientColor(stops, 0.9))
+
+	// "to right" runs from the left edge to the right edge
+	at := gradientPosition(&css.Gradient{Angle: 90}, 100, 40)
+	assert.InDelta(t, 0, at(0, 20), 1e-9)
+	assert.InDelta(t, 1, at(100, 0), 1e-9)
+
+	// Corner gradients reach exactly into the corner
+	at = gradientPosition(&css.Gradient{Corner: "bottom right"}, 100, 40)
+	assert.InDelta(t, 1, at(100, 40), 1e-9)
+	assert.InDelta(t, 0, at(0, 0), 1e-9)
+
+	at = gradientPosition(&css.Gradient{Radial: true, Circle: true, CenterX: css.Percent(50), CenterY: css.Percent(50)}, 60, 80)
+	assert.InDelta(t, 0, at(30, 40), 1e-9)
+	assert.InDelta(t, 1, at(60, 80), 1e-9)
+}
+
+func TestShadowImage(t *testing.T) {
+	box := layout.Rect{X: 10, Y: 10, Width: 20, Height: 20}
+	img := shadowImage(DrawShadow{Rect: box, Shadow: css.BoxShadow{OffsetX: 5, Color: color.Black}})
+	require.NotNil(t, img)
+
+	alphaAt := func(x, y float64) uint32 {
+		px := int(x - float64(img.Position().X))
+		py := int(y - float64(img.Position().Y))
+		_, _, _, a := img.Image.At(px, py).RGBA()
+		return a >> 8
+	}
+	assert.Equal(t, uint32(255), alphaAt(33, 20), "shadow shows to the right")
+	assert.Zero(t, alphaAt(20, 20), "but not under the box")
+	assert.Zero(t, alphaAt(12, 35))
+
+	inset := shadowImage(DrawShadow{Rect: box, Shadow: css.BoxShadow{Spread: 3, Color: color.Black, Inset: true}})
+	require.NotNil(t, inset)
+	img = inset
+	assert.Equal(t, uint32(255), alphaAt(11, 20), "inset shadow lines the inside edge")
+	assert.Zero(t, alphaAt(20, 20))
+	assert.Zero(t, alphaAt(5, 20))
+}
+
+func TestBackgroundTileSize(t *testing.T) {
+	c := DrawBackground{Rect: layout.Rect{Width: 200, Height: 100}}
+	w, h := backgroundTileSize(c, 50, 25)
+	assert.Equal(t, []float64{50, 25}, []float64{w, h})
+
+	c.Size = css.BackgroundSize{Keyword: "cover"}
+	w, h = backgroundTileSize(c, 50, 50)
+	assert.Equal(t, []float64{200, 200}, []float64{w, h})
+
+	c.Size = css.BackgroundSize{Keyword: "contain"}
+	w, h = backgroundTileSize(c, 50, 50)
+	assert.Equal(t, []float64{100, 100}, []float64{w, h})
+
+	c.Size = css.BackgroundSize{Width: css.Percent(50), Height: css.Length{Auto: true}}
+	w, h = backgroundTileSize(c, 50, 25)
+	assert.Equal(t, []float64{100, 50}, []float64{w, h})
+
+	assert.Equal(t, 150.0, backgroundOffset(css.Percent(100), 200, 50))
+	assert.Equal(t, 10.0, backgroundOffset(css.Length{Value: 10}, 200, 50))
+}
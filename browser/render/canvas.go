@@ -1,13 +1,14 @@
 package render
 
 import (
+	"browser/fonts"
+	"browser/network"
 	"fmt"
 	"image"
 	"image/color"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -225,18 +226,45 @@ func RenderToCanvas(commands []DisplayCommand, baseURL string, useCache bool, on
 			rect.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
 			rect.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
 			rect.CornerRadius = float32(c.CornerRadius)
+			if c.StrokeWidth > 0 {
+				rect.StrokeColor = c.StrokeColor
+				rect.StrokeWidth = float32(c.StrokeWidth)
+			}
+			objects = append(objects, rect)
+
+		case DrawFocusRing:
+			objects = append(objects, focusRingObject(c))
+
+		case DrawValidationMessage:
+			dropdownOverlays = append(dropdownOverlays, validationMessageObjects(c)...)
+
+		case DrawHighlight:
+			highlightColor := ColorFindMatch
+			if c.Current {
+				highlightColor = ColorFindCurrent
+			}
+			rect := canvas.NewRectangle(highlightColor)
+			rect.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
+			rect.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
 			objects = append(objects, rect)
 
 		case DrawText:
-			text := canvas.NewText(c.Text, c.Color)
-			text.TextSize = c.Size
-			text.TextStyle = fyne.TextStyle{
-				Bold:      c.Bold,
-				Italic:    c.Italic,
-				Monospace: c.Monospace,
-			}
-			text.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
-			objects = append(objects, text)
+			if c.registry().NeedsShaping(c.Text, c.Family) {
+				// Web fonts and right-to-left text are shaped and drawn here
+				if img := shapedText(c); img != nil {
+					objects = append(objects, img)
+				}
+			} else {
+				text := canvas.NewText(c.Text, c.Color)
+				text.TextSize = c.Size
+				text.TextStyle = fyne.TextStyle{
+					Bold:      c.Bold,
+					Italic:    c.Italic,
+					Monospace: c.Monospace,
+				}
+				text.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+				objects = append(objects, text)
+			}
 
 			// Draw text decoration lines
 			if c.Underline || c.Strikethrough {
@@ -267,6 +295,32 @@ func RenderToCanvas(commands []DisplayCommand, baseURL string, useCache bool, on
 				objects = append(objects, placeholder)
 			}
 
+		case DrawShadow:
+			if img := shadowImage(c); img != nil {
+				objects = append(objects, img)
+			}
+
+		case DrawGradient:
+			objects = append(objects, gradientRaster(c))
+
+		case DrawBackground:
+			if obj := backgroundObject(c, baseURL, onImageLoad); obj != nil {
+				objects = append(objects, obj)
+			}
+
+		case DrawRaster:
+			img := canvas.NewImageFromImage(c.Image)
+			img.FillMode = canvas.ImageFillStretch
+			img.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
+			img.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+			objects = append(objects, img)
+
+		case DrawTransformed:
+			objects = append(objects, transformedObjects(c, baseURL, useCache, onImageLoad)...)
+
+		case DrawFrame:
+			objects = append(objects, frameObject(c, useCache, onImageLoad))
+
 		case DrawHR:
 			hr := canvas.NewRectangle(ColorHR)
 			hr.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
@@ -328,7 +382,7 @@ func RenderToCanvas(commands []DisplayCommand, baseURL string, useCache bool, on
 			objects = append(objects, text)
 
 		case DrawTextarea:
-			objects = append(objects, renderTextFieldObjects(c.X, c.Y, c.Width, c.Height, c.Value, c.Placeholder, c.IsFocused, c.IsDisabled, true)...)
+			objects = append(objects, renderTextFieldObjects(c.X, c.Y, c.Width, c.Height, c.Value, c.Placeholder, c.IsFocused, c.IsDisabled, c.IsValid)...)
 
 		case DrawSelect:
 			// Border - blue when open
@@ -572,7 +626,7 @@ func fetchAndCreateImage(src, baseURL string, width, height float64) *canvas.Ima
 	fullURL := resolveImageURL(src, baseURL)
 	fmt.Println("Fetching image:", fullURL)
 
-	resp, err := http.Get(fullURL)
+	resp, err := network.DefaultClient.Get(fullURL)
 	if err != nil {
 		fmt.Println("Error fetching image:", err)
 		return nil
@@ -735,7 +789,7 @@ func fetchimageToCache(fullURL string) {
 		}
 	} else {
 		// Remote URL - fetch via HTTP
-		resp, err := http.Get(fullURL)
+		resp, err := network.DefaultClient.Get(fullURL)
 		if err != nil {
 			fmt.Println("Error fetching image:", err)
 			return
@@ -753,7 +807,58 @@ func fetchimageToCache(fullURL string) {
 	imageCache[fullURL] = img
 	imageCacheMu.Unlock()
 }
+
+// registry returns the fonts the text's document registered
+func (c DrawText) registry() *fonts.Registry {
+	if c.Fonts == nil {
+		return fonts.Default
+	}
+	return c.Fonts
+}
+
+// shapedTextScale oversamples shaped text so it stays sharp on HiDPI screens
+const shapedTextScale = 2
+
+// shapedText draws text that needs the shaper (web fonts, bidi) into an image
+// placed where a canvas.Text would be: top-left at (X, Y), baseline at ascent
+func shapedText(c DrawText) *canvas.Image {
+	line := c.registry().Shape(c.Text, fonts.Style{
+		Family: c.Family,
+		Size:   float64(c.Size) * shapedTextScale,
+		Bold:   c.Bold,
+		Italic: c.Italic,
+	})
+	col := c.Color
+	if col == nil {
+		col = color.Black
+	}
+	raster := fonts.Rasterize(line, col)
+	if raster == nil {
+		return nil
+	}
+	bounds := raster.Bounds()
+	img := canvas.NewImageFromImage(raster)
+	img.FillMode = canvas.ImageFillStretch
+	img.Resize(fyne.NewSize(float32(bounds.Dx())/shapedTextScale, float32(bounds.Dy())/shapedTextScale))
+	img.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+	return img
+}
+
 func getImageOrPlaceholder(src, baseURL string, width, height float64, onLoad func()) *canvas.Image {
+	cached := cachedImage(src, baseURL, onLoad)
+	if cached == nil {
+		return nil
+	}
+	fyneImg := canvas.NewImageFromImage(cached)
+	fyneImg.Resize(fyne.NewSize(float32(width), float32(height)))
+	fyneImg.FillMode = canvas.ImageFillContain
+	return fyneImg
+}
+
+// cachedImage returns the decoded image for src if it is in the image
+// cache. Otherwise it starts fetching it, calls onLoad once it arrives, and
+// returns nil.
+func cachedImage(src, baseURL string, onLoad func()) image.Image {
 	fullURL := resolveImageURL(src, baseURL)
 
 	imageCacheMu.Lock()
@@ -761,10 +866,7 @@ func getImageOrPlaceholder(src, baseURL string, width, height float64, onLoad fu
 	imageCacheMu.Unlock()
 
 	if found {
-		fyneImg := canvas.NewImageFromImage(cached)
-		fyneImg.Resize(fyne.NewSize(float32(width), float32(height)))
-		fyneImg.FillMode = canvas.ImageFillContain
-		return fyneImg
+		return cached
 	}
 
 	pendingMu.Lock()
@@ -790,3 +892,20 @@ func getImageOrPlaceholder(src, baseURL string, width, height float64, onLoad fu
 
 	return nil
 }
+
+// LoadImage returns the decoded image at fullURL, fetching it into the
+// image cache first if needed. It blocks, so callers run off the main thread.
+func LoadImage(fullURL string) image.Image {
+	imageCacheMu.Lock()
+	cached, found := imageCache[fullURL]
+	imageCacheMu.Unlock()
+	if found {
+		return cached
+	}
+
+	fetchimageToCache(fullURL)
+
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+	return imageCache[fullURL]
+}
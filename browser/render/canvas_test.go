@@ -1,9 +1,16 @@
 package render
 
 import (
+	"browser/css"
+	"browser/dom"
+	"browser/fonts"
+	"browser/layout"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/gomono"
 )
 
 func TestIsLocalFile(t *testing.T) {
@@ -93,3 +100,50 @@ func TestResolveImageURL(t *testing.T) {
 		})
 	}
 }
+
+func TestShapedText(t *testing.T) {
+	img := shapedText(DrawText{Text: "abc שלום", X: 10, Y: 20, Size: 16})
+	if assert.NotNil(t, img) {
+		assert.Equal(t, float32(10), img.Position().X)
+		assert.Equal(t, float32(20), img.Position().Y)
+		assert.Greater(t, img.Size().Width, float32(0))
+		// Sized in layout pixels, not the oversampled raster
+		assert.Less(t, img.Size().Height, float32(32))
+	}
+
+	assert.Nil(t, shapedText(DrawText{Text: "", Size: 16}))
+}
+
+func TestDocumentFonts(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	page := dom.Parse(strings.NewReader(`<p style="font-family: Fancy">hi</p>`))
+	tab.SetDocument(page)
+	registry := tab.Fonts()
+	require.NotNil(t, registry)
+	mono, err := fonts.Load(gomono.TTF)
+	require.NoError(t, err)
+	registry.Register("Fancy", fonts.Descriptor{}, mono, "https://example.com/fancy.ttf")
+
+	// The page's text is shaped with its web font
+	root := layout.BuildLayoutTree(page, css.Stylesheet{}, layout.Viewport{Width: 800, Fonts: registry})
+	layout.ComputeLayout(root, 800)
+	var text *DrawText
+	for _, cmd := range BuildDisplayList(root) {
+		if d, ok := cmd.(DrawText); ok {
+			text = &d
+		}
+	}
+	require.NotNil(t, text)
+	assert.Same(t, registry, text.Fonts)
+	assert.True(t, text.registry().NeedsShaping(text.Text, text.Family))
+
+	tab.SetDocument(page)
+	assert.Same(t, registry, tab.Fonts(), "the same document keeps its fonts")
+
+	// Navigating drops the page's web fonts; no other page ever saw them
+	tab.SetDocument(dom.Parse(strings.NewReader(`<p>next</p>`)))
+	assert.NotSame(t, registry, tab.Fonts())
+	assert.False(t, tab.Fonts().Loaded("https://example.com/fancy.ttf"))
+	assert.False(t, fonts.Default.HasWebFont([]string{"Fancy"}))
+}
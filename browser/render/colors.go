@@ -59,6 +59,19 @@ var (
 	ColorSelectHighlight = color.RGBA{0, 120, 215, 40} // Translucent blue
 )
 
+// Find-in-page highlight colors
+var (
+	ColorFindMatch   = color.RGBA{255, 235, 0, 110}  // Translucent yellow
+	ColorFindCurrent = color.RGBA{255, 150, 50, 150} // Translucent orange
+)
+
+// Form validation bubble colors
+var (
+	ColorValidationBg     = color.RGBA{255, 255, 255, 255} // White
+	ColorValidationBorder = color.RGBA{160, 160, 160, 255} // Gray
+	ColorValidationIcon   = color.RGBA{240, 140, 0, 255}   // Orange
+)
+
 // Background colors
 var (
 	ColorPageBackground = color.RGBA{240, 240, 240, 255} // Light gray
@@ -0,0 +1,601 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"browser/network"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxConsoleEntries is how many console lines a tab keeps
+const maxConsoleEntries = 1000
+
+// ConsoleEntry is one line of a tab's console. Level is the console method
+// that wrote it, or "input" and "result" for expressions typed into the
+// devtools console.
+type ConsoleEntry struct {
+	Level string
+	Text  string
+}
+
+// tabConsole holds what a page logged, guarded by its own lock as scripts
+// log from their own goroutines
+type tabConsole struct {
+	mu         sync.Mutex
+	entries    []ConsoleEntry
+	onEvaluate func(expression string) (string, error)
+}
+
+// ConsoleMessage adds a message a script logged to the tab's console
+func (t *Tab) ConsoleMessage(level, message string) {
+	t.addConsoleEntry(ConsoleEntry{Level: level, Text: message})
+}
+
+// SetEvaluateHandler sets what runs expressions typed into the console
+func (t *Tab) SetEvaluateHandler(handler func(expression string) (string, error)) {
+	t.console.mu.Lock()
+	defer t.console.mu.Unlock()
+	t.console.onEvaluate = handler
+}
+
+func (t *Tab) addConsoleEntry(entry ConsoleEntry) {
+	t.console.mu.Lock()
+	t.console.entries = append(t.console.entries, entry)
+	if len(t.console.entries) > maxConsoleEntries {
+		t.console.entries = t.console.entries[len(t.console.entries)-maxConsoleEntries:]
+	}
+	t.console.mu.Unlock()
+	if t.isActive() && t.browser.devtools != nil {
+		t.browser.devtools.consoleChanged()
+	}
+}
+
+// consoleEntries returns a copy of the tab's console
+func (t *Tab) consoleEntries() []ConsoleEntry {
+	t.console.mu.Lock()
+	defer t.console.mu.Unlock()
+	return append([]ConsoleEntry(nil), t.console.entries...)
+}
+
+// clearConsole empties the tab's console
+func (t *Tab) clearConsole() {
+	t.console.mu.Lock()
+	t.console.entries = nil
+	t.console.mu.Unlock()
+}
+
+// evaluate runs an expression in the page's scripts and logs it with its
+// result
+func (t *Tab) evaluate(expression string) {
+	t.console.mu.Lock()
+	evaluate := t.console.onEvaluate
+	t.console.mu.Unlock()
+
+	t.addConsoleEntry(ConsoleEntry{Level: "input", Text: expression})
+	if evaluate == nil {
+		t.addConsoleEntry(ConsoleEntry{Level: "error", Text: "The page has no scripts running"})
+		return
+	}
+	result, err := evaluate(expression)
+	if err != nil {
+		t.addConsoleEntry(ConsoleEntry{Level: "error", Text: err.Error()})
+		return
+	}
+	t.addConsoleEntry(ConsoleEntry{Level: "result", Text: result})
+}
+
+// devTools is the developer tools pane under the page: the DOM tree with
+// the selected element's rules, computed style and box, the console and
+// the network log. Its widgets are only touched on the main thread.
+type devTools struct {
+	browser *Browser
+	panel   *container.AppTabs
+
+	tree     *widget.Tree
+	details  *widget.Label
+	document *dom.Node
+	nodes    map[widget.TreeNodeID]*dom.Node
+	children map[widget.TreeNodeID][]widget.TreeNodeID
+	selected *dom.Node
+
+	console      *widget.List
+	consoleInput *widget.Entry
+	entries      []ConsoleEntry
+
+	network  *widget.List
+	requests []network.Request
+}
+
+func (b *Browser) newDevTools() *devTools {
+	d := &devTools{browser: b}
+
+	d.tree = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID { return d.children[id] },
+		func(id widget.TreeNodeID) bool { return len(d.children[id]) > 0 },
+		func(bool) fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TreeNodeID, _ bool, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(nodeLabel(d.nodes[id]))
+		},
+	)
+	d.tree.OnSelected = func(id widget.TreeNodeID) {
+		d.selected = d.nodes[id]
+		d.showDetails()
+	}
+	d.details = widget.NewLabel("Select an element")
+	d.details.TextStyle = fyne.TextStyle{Monospace: true}
+	d.details.Wrapping = fyne.TextWrapWord
+	refreshBtn := widget.NewButton("↻", func() { d.refreshElements() })
+	elements := container.NewHSplit(
+		container.NewBorder(container.NewHBox(refreshBtn), nil, nil, nil, d.tree),
+		container.NewScroll(d.details),
+	)
+
+	d.console = widget.NewList(
+		func() int { return len(d.entries) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			label.Wrapping = fyne.TextWrapWord
+			return label
+		},
+		func(i widget.ListItemID, obj fyne.CanvasObject) {
+			label := obj.(*widget.Label)
+			entry := d.entries[i]
+			label.Importance = consoleImportance(entry.Level)
+			label.SetText(consoleLine(entry))
+		},
+	)
+	d.consoleInput = widget.NewEntry()
+	d.consoleInput.SetPlaceHolder("Evaluate an expression...")
+	d.consoleInput.OnSubmitted = func(expression string) {
+		if strings.TrimSpace(expression) == "" {
+			return
+		}
+		d.consoleInput.SetText("")
+		// Scripts may show dialogs, which wait for the main thread
+		go b.Tab.evaluate(expression)
+	}
+	clearConsoleBtn := widget.NewButton("Clear", func() {
+		b.Tab.clearConsole()
+		d.refreshConsole()
+	})
+	consoleTab := container.NewBorder(nil, container.NewBorder(nil, nil, nil, clearConsoleBtn, d.consoleInput), nil, nil, d.console)
+
+	d.network = widget.NewList(
+		func() int { return len(d.requests) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(i widget.ListItemID, obj fyne.CanvasObject) {
+			label := obj.(*widget.Label)
+			req := d.requests[i]
+			label.Importance = widget.MediumImportance
+			if req.Err != nil || req.Status >= 400 {
+				label.Importance = widget.DangerImportance
+			}
+			label.SetText(requestLine(req))
+		},
+	)
+	clearNetworkBtn := widget.NewButton("Clear", func() { network.DefaultLog.Clear() })
+	networkTab := container.NewBorder(nil, container.NewHBox(clearNetworkBtn), nil, nil, d.network)
+	network.DefaultLog.SetChangeHandler(func() {
+		fyne.Do(func() {
+			if d.visible() {
+				d.refreshNetwork()
+			}
+		})
+	})
+
+	d.panel = container.NewAppTabs(
+		container.NewTabItem("Elements", elements),
+		container.NewTabItem("Console", consoleTab),
+		container.NewTabItem("Network", networkTab),
+	)
+	d.panel.Hide()
+
+	// Ctrl+Shift+I, and F12 in handleTypedKey
+	b.Window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyI, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}, func(_ fyne.Shortcut) {
+		b.ToggleDevTools()
+	})
+	return d
+}
+
+// ToggleDevTools shows or hides the developer tools. Must run on the main
+// thread.
+func (b *Browser) ToggleDevTools() {
+	d := b.devtools
+	if d.panel.Visible() {
+		d.panel.Hide()
+		return
+	}
+	d.panel.Show()
+	d.refreshElements()
+	d.refreshConsole()
+	d.refreshNetwork()
+}
+
+func (d *devTools) visible() bool {
+	return d.panel.Visible()
+}
+
+// pageChanged updates the pane for a new page or another tab
+func (d *devTools) pageChanged() {
+	fyne.Do(func() {
+		if !d.visible() {
+			return
+		}
+		d.refreshElements()
+		d.refreshConsole()
+	})
+}
+
+func (d *devTools) consoleChanged() {
+	fyne.Do(func() {
+		if d.visible() {
+			d.refreshConsole()
+		}
+	})
+}
+
+// refreshElements rebuilds the DOM tree of the active tab's page, keeping
+// the selection while it is still in the document
+func (d *devTools) refreshElements() {
+	document := d.browser.Tab.document
+	d.nodes = map[widget.TreeNodeID]*dom.Node{}
+	d.children = map[widget.TreeNodeID][]widget.TreeNodeID{}
+	if document != nil {
+		d.addTreeNodes("", document)
+	}
+	if document != d.document {
+		d.tree.UnselectAll()
+		d.selected = nil
+		d.document = document
+	}
+	d.tree.Refresh()
+	d.showDetails()
+}
+
+func (d *devTools) addTreeNodes(parentID widget.TreeNodeID, node *dom.Node) {
+	for _, child := range node.Children {
+		if !inspectable(child) {
+			continue
+		}
+		id := fmt.Sprintf("%s/%d", parentID, len(d.children[parentID]))
+		d.nodes[id] = child
+		d.children[parentID] = append(d.children[parentID], id)
+		d.addTreeNodes(id, child)
+	}
+}
+
+func (d *devTools) showDetails() {
+	if d.selected == nil {
+		d.details.SetText("Select an element")
+		return
+	}
+	d.details.SetText(d.browser.Tab.inspect(d.selected))
+}
+
+func (d *devTools) refreshConsole() {
+	d.entries = d.browser.Tab.consoleEntries()
+	d.console.Refresh()
+	if len(d.entries) > 0 {
+		d.console.ScrollToBottom()
+	}
+}
+
+func (d *devTools) refreshNetwork() {
+	d.requests = network.DefaultLog.Entries()
+	d.network.Refresh()
+	if len(d.requests) > 0 {
+		d.network.ScrollToBottom()
+	}
+}
+
+// inspectable reports whether node is shown in the DOM tree: elements and
+// text that is not just whitespace
+func inspectable(node *dom.Node) bool {
+	switch node.Type {
+	case dom.Element:
+		return true
+	case dom.Text:
+		return strings.TrimSpace(node.Text) != ""
+	}
+	return false
+}
+
+// nodeLabel is how a node appears in the DOM tree
+func nodeLabel(node *dom.Node) string {
+	if node == nil {
+		return ""
+	}
+	if node.Type == dom.Text {
+		text := strings.Join(strings.Fields(node.Text), " ")
+		if len(text) > 60 {
+			text = text[:57] + "..."
+		}
+		return fmt.Sprintf("%q", text)
+	}
+	var b strings.Builder
+	b.WriteString("<" + node.TagName)
+	for _, name := range []string{"id", "class", "name", "type", "href", "src"} {
+		if value, ok := node.Attributes[name]; ok {
+			fmt.Fprintf(&b, " %s=%q", name, value)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// inspect describes a node for the details pane: the CSS rules matching
+// it, its computed style and its box
+func (t *Tab) inspect(node *dom.Node) string {
+	if node.Type != dom.Element {
+		return nodeLabel(node)
+	}
+	var b strings.Builder
+
+	b.WriteString("Matched rules\n")
+	classes := strings.Fields(node.Attributes["class"])
+	rules := css.MatchingRules(css.Parse(t.stylesheetOf(node)), node.TagName, node.Attributes["id"], classes)
+	for _, rule := range rules {
+		b.WriteString("  " + rule.String() + "\n")
+	}
+	if style, ok := node.Attributes["style"]; ok {
+		fmt.Fprintf(&b, "  element.style { %s }\n", strings.TrimSpace(style))
+	}
+	if len(rules) == 0 && node.Attributes["style"] == "" {
+		b.WriteString("  (none)\n")
+	}
+
+	box := t.boxOf(node)
+	if box == nil {
+		b.WriteString("\nNot rendered\n")
+		return b.String()
+	}
+	b.WriteString("\nComputed style\n")
+	for _, line := range computedStyle(box.Style) {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("\nBox model\n")
+	b.WriteString(boxModel(box))
+	return b.String()
+}
+
+// stylesheetOf returns the CSS of the page or frame document node is in
+func (t *Tab) stylesheetOf(node *dom.Node) string {
+	document := node.OwnerDocument()
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
+	if document != t.document {
+		for _, ctx := range t.frames {
+			if ctx.document == document {
+				return ctx.css + "\n" + dom.FindActiveStyleContent(document)
+			}
+		}
+	}
+	return t.externalCSS + "\n" + dom.FindActiveStyleContent(document)
+}
+
+// boxOf finds the first layout box of node in the page or its frames
+func (t *Tab) boxOf(node *dom.Node) *layout.LayoutBox {
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
+	if box := findBoxOfNode(t.layoutTree, node); box != nil {
+		return box
+	}
+	for _, ctx := range t.frames {
+		if box := findBoxOfNode(ctx.tree, node); box != nil {
+			return box
+		}
+	}
+	return nil
+}
+
+func findBoxOfNode(box *layout.LayoutBox, node *dom.Node) *layout.LayoutBox {
+	if box == nil {
+		return nil
+	}
+	if box.Node == node {
+		return box
+	}
+	for _, child := range box.Children {
+		if found := findBoxOfNode(child, node); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// computedStyle lists the properties of a style that differ from their
+// initial values, plus the font and color every element has
+func computedStyle(s css.Style) []string {
+	var lines []string
+	add := func(property, value string) {
+		lines = append(lines, property+": "+value)
+	}
+	addPx := func(property string, v float64) {
+		if v != 0 {
+			add(property, px(v))
+		}
+	}
+
+	if s.Display != "" {
+		add("display", s.Display)
+	}
+	if s.Position != "" {
+		add("position", s.Position)
+		addPx("top", s.Top)
+		addPx("right", s.Right)
+		addPx("bottom", s.Bottom)
+		addPx("left", s.Left)
+	}
+	if s.Float != "" {
+		add("float", s.Float)
+	}
+	addPx("width", s.Width)
+	addPx("height", s.Height)
+	addPx("min-width", s.MinWidth)
+	addPx("max-width", s.MaxWidth)
+	addPx("min-height", s.MinHeight)
+	addPx("max-height", s.MaxHeight)
+	if edges := edgeValues(s.MarginTop, s.MarginRight, s.MarginBottom, s.MarginLeft); edges != "" {
+		add("margin", edges)
+	}
+	if edges := edgeValues(s.PaddingTop, s.PaddingRight, s.PaddingBottom, s.PaddingLeft); edges != "" {
+		add("padding", edges)
+	}
+	if edges := edgeValues(s.BorderTopWidth, s.BorderRightWidth, s.BorderBottomWidth, s.BorderLeftWidth); edges != "" {
+		add("border-width", edges)
+	}
+	addPx("border-radius", s.BorderRadius)
+
+	if c := colorString(s.Color); c != "" {
+		add("color", c)
+	}
+	if c := colorString(s.BackgroundColor); c != "" {
+		add("background-color", c)
+	}
+	if s.BackgroundImage != "" {
+		add("background-image", "url("+s.BackgroundImage+")")
+	}
+	if len(s.FontFamily) > 0 {
+		add("font-family", strings.Join(s.FontFamily, ", "))
+	}
+	add("font-size", px(s.FontSize))
+	if s.Bold {
+		add("font-weight", "bold")
+	}
+	if s.Italic {
+		add("font-style", "italic")
+	}
+	addPx("line-height", s.LineHeight)
+	if s.TextAlign != "" {
+		add("text-align", s.TextAlign)
+	}
+	if s.TextDecoration != "" {
+		add("text-decoration", s.TextDecoration)
+	}
+	if s.TextTransform != "" {
+		add("text-transform", s.TextTransform)
+	}
+	if s.Opacity != 1 {
+		add("opacity", fmt.Sprintf("%g", s.Opacity))
+	}
+	if s.Visibility != "" {
+		add("visibility", s.Visibility)
+	}
+	if s.Cursor != "" {
+		add("cursor", s.Cursor)
+	}
+	return lines
+}
+
+// boxModel describes where a box was laid out and its edges
+func boxModel(box *layout.LayoutBox) string {
+	s := box.Style
+	return fmt.Sprintf("  position  %s, %s\n  size      %s × %s\n  padding   %s\n  border    %s\n  margin    %s\n",
+		px(box.Rect.X), px(box.Rect.Y), px(box.Rect.Width), px(box.Rect.Height),
+		edgesOrZero(s.PaddingTop, s.PaddingRight, s.PaddingBottom, s.PaddingLeft),
+		edgesOrZero(s.BorderTopWidth, s.BorderRightWidth, s.BorderBottomWidth, s.BorderLeftWidth),
+		edgesOrZero(s.MarginTop, s.MarginRight, s.MarginBottom, s.MarginLeft))
+}
+
+// edgeValues writes four sides in CSS shorthand order, or "" if all are 0
+func edgeValues(top, right, bottom, left float64) string {
+	if top == 0 && right == 0 && bottom == 0 && left == 0 {
+		return ""
+	}
+	return edgesOrZero(top, right, bottom, left)
+}
+
+func edgesOrZero(top, right, bottom, left float64) string {
+	if top == right && right == bottom && bottom == left {
+		return px(top)
+	}
+	return strings.Join([]string{px(top), px(right), px(bottom), px(left)}, " ")
+}
+
+func px(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%gpx", math.Round(v*100)/100)
+}
+
+// colorString writes a color as CSS rgb() or rgba(), or "" for none
+func colorString(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 255 {
+		return fmt.Sprintf("rgb(%d, %d, %d)", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("rgba(%d, %d, %d, %.2g)", n.R, n.G, n.B, float64(n.A)/255)
+}
+
+// consoleLine is how an entry appears in the console
+func consoleLine(entry ConsoleEntry) string {
+	switch entry.Level {
+	case "input":
+		return "> " + entry.Text
+	case "result":
+		return "< " + entry.Text
+	case "warn":
+		return "⚠ " + entry.Text
+	case "error":
+		return "✖ " + entry.Text
+	}
+	return entry.Text
+}
+
+func consoleImportance(level string) widget.Importance {
+	switch level {
+	case "warn":
+		return widget.WarningImportance
+	case "error":
+		return widget.DangerImportance
+	case "input", "result", "debug":
+		return widget.LowImportance
+	}
+	return widget.MediumImportance
+}
+
+// requestLine is how a request appears in the network log
+func requestLine(req network.Request) string {
+	if req.Err != nil {
+		return fmt.Sprintf("%-4s  failed  %-10s  %s  (%v)", req.Method, req.Kind, req.URL, req.Err)
+	}
+	size := "?"
+	if req.Size >= 0 {
+		size = byteSize(req.Size)
+	}
+	line := fmt.Sprintf("%-4s  %d  %-10s  %6d ms  %8s  %s", req.Method, req.Status, req.Kind, req.Duration.Milliseconds(), size, req.URL)
+	if req.Cache != "" {
+		line += "  (cache " + strings.ToLower(req.Cache) + ")"
+	}
+	return line
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
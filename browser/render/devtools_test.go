@@ -0,0 +1,99 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"browser/network"
+	"errors"
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeLabel(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<a id="home" class="nav big" href="/" data-x="1">  Go   home </a>`))
+	link := dom.FindElementsByTagName(doc, "a")
+	assert.Equal(t, `<a id="home" class="nav big" href="/">`, nodeLabel(link))
+	assert.Equal(t, `"Go home"`, nodeLabel(link.Children[0]))
+	assert.True(t, inspectable(link.Children[0]))
+	assert.False(t, inspectable(&dom.Node{Type: dom.Text, Text: " \n "}))
+}
+
+func TestInspectNode(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	tab.document = dom.Parse(strings.NewReader(`<style>p { color: red } .x { padding: 4px }</style><p class="x" style="margin: 2px 0">hi</p><span>unused</span>`))
+	tab.externalCSS = "#none { color: blue }"
+	sheet := css.Parse(tab.externalCSS + dom.FindActiveStyleContent(tab.document))
+	tab.layoutTree = layout.BuildLayoutTree(tab.document, sheet, layout.Viewport{Width: 800})
+	layout.ComputeLayout(tab.layoutTree, 800)
+
+	report := tab.inspect(dom.FindElementsByTagName(tab.document, "p"))
+	assert.Contains(t, report, "  p { color: red; }\n  .x { padding: 4px; }\n  element.style { margin: 2px 0 }\n")
+	assert.NotContains(t, report, "#none")
+	assert.Contains(t, report, "color: rgb(255, 0, 0)")
+	assert.Contains(t, report, "padding: 4px")
+	assert.Contains(t, report, "margin    2px 0 2px 0")
+
+	detached := &dom.Node{Type: dom.Element, TagName: "div", Parent: tab.document}
+	assert.Contains(t, tab.inspect(detached), "Not rendered")
+}
+
+func TestComputedStyleFormatting(t *testing.T) {
+	style := css.DefaultStyle()
+	style.Bold = true
+	style.MarginTop, style.MarginBottom = 8, 8
+	style.BackgroundColor = color.NRGBA{0, 0, 255, 128}
+	assert.Equal(t, []string{
+		"margin: 8px 0 8px 0",
+		"background-color: rgba(0, 0, 255, 0.5)",
+		"font-size: 16px",
+		"font-weight: bold",
+	}, computedStyle(style))
+	assert.Equal(t, "1.33px", px(1.3333))
+}
+
+func TestTabConsole(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	tab.ConsoleMessage("log", "hello")
+	tab.evaluate("1 + 1")
+	tab.SetEvaluateHandler(func(expression string) (string, error) {
+		if expression == "bad" {
+			return "", errors.New("ReferenceError: bad is not defined")
+		}
+		return "2", nil
+	})
+	tab.evaluate("1 + 1")
+	tab.evaluate("bad")
+
+	var lines []string
+	for _, entry := range tab.consoleEntries() {
+		lines = append(lines, consoleLine(entry))
+	}
+	assert.Equal(t, []string{
+		"hello",
+		"> 1 + 1",
+		"✖ The page has no scripts running",
+		"> 1 + 1",
+		"< 2",
+		"> bad",
+		"✖ ReferenceError: bad is not defined",
+	}, lines)
+
+	tab.clearConsole()
+	assert.Empty(t, tab.consoleEntries())
+}
+
+func TestRequestLine(t *testing.T) {
+	req := network.Request{Method: "GET", URL: "http://example.com/a.css", Kind: "stylesheet", Status: http.StatusOK, Size: 2048, Duration: 12 * time.Millisecond, Cache: "HIT"}
+	assert.Equal(t, "GET   200  stylesheet      12 ms    2.0 kB  http://example.com/a.css  (cache hit)", requestLine(req))
+
+	req = network.Request{Method: "GET", URL: "http://example.com/x.png", Kind: "image", Size: -1, Err: errors.New("refused")}
+	assert.Equal(t, "GET   failed  image       http://example.com/x.png  (refused)", requestLine(req))
+}
@@ -0,0 +1,66 @@
+package render
+
+import "browser/layout"
+
+// DisplayCache keeps the display commands painted for each laid-out block,
+// so a repaint can copy the commands of blocks that were neither relaid
+// out nor moved instead of walking their subtrees again
+type DisplayCache struct {
+	segments map[*layout.LayoutBox]displaySegment
+}
+
+type displaySegment struct {
+	generation uint64
+	x, y       float64
+	commands   []DisplayCommand
+}
+
+func NewDisplayCache() *DisplayCache {
+	return &DisplayCache{segments: make(map[*layout.LayoutBox]displaySegment)}
+}
+
+// lookup returns the segment painted for box if its layout is unchanged
+func (c *DisplayCache) lookup(box *layout.LayoutBox) (displaySegment, bool) {
+	seg, ok := c.segments[box]
+	if !ok || seg.generation != box.Generation() || seg.x != box.Rect.X || seg.y != box.Rect.Y {
+		return displaySegment{}, false
+	}
+	return seg, true
+}
+
+// store copies the commands just painted for box
+func (c *DisplayCache) store(box *layout.LayoutBox, commands []DisplayCommand) {
+	c.segments[box] = displaySegment{
+		generation: box.Generation(),
+		x:          box.Rect.X,
+		y:          box.Rect.Y,
+		commands:   append([]DisplayCommand(nil), commands...),
+	}
+}
+
+// Len returns how many block segments the cache holds
+func (c *DisplayCache) Len() int {
+	return len(c.segments)
+}
+
+// BuildDisplayListCached is BuildDisplayListWithInputs reusing the segments
+// in cache. The cache is refilled with the segments of this paint, dropping
+// boxes that are no longer in the tree.
+func BuildDisplayListCached(root *layout.LayoutBox, state InputState, cache *DisplayCache) []DisplayCommand {
+	// Selection highlights are painted into text runs anywhere in the page
+	if cache == nil || state.SelectionStart != nil {
+		return BuildDisplayListWithInputs(root, state)
+	}
+
+	next := NewDisplayCache()
+	commands := buildDisplayList(root, state, &displayPass{previous: cache, next: next})
+	cache.segments = next.segments
+	return commands
+}
+
+// displayPass carries the caches through one paint: segments are read from
+// previous and the ones still in use are written to next
+type displayPass struct {
+	previous *DisplayCache
+	next     *DisplayCache
+}
@@ -0,0 +1,39 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDisplayListCachedMatchesUncached(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	for i := 0; i < 20; i++ {
+		b.WriteString("<div><p>paragraph</p><p>more text here</p></div>")
+	}
+	b.WriteString("<h1>last</h1></body></html>")
+
+	doc := dom.Parse(strings.NewReader(b.String()))
+	viewport := layout.Viewport{Width: 800}
+	root := layout.BuildLayoutTree(doc, css.Stylesheet{}, viewport)
+	layout.ComputeLayout(root, 800)
+
+	cache := NewDisplayCache()
+	first := BuildDisplayListCached(root, InputState{}, cache)
+	assert.Equal(t, BuildDisplayListWithInputs(root, InputState{}), first)
+	assert.NotZero(t, cache.Len())
+
+	// Grow the first paragraph so everything below it moves down
+	dom.FindElementsByTagName(doc, "p").SetInnerText("a much longer paragraph " + strings.Repeat("that wraps ", 40))
+	root = layout.UpdateLayoutTree(root, doc, css.Stylesheet{}, viewport)
+	layout.ComputeLayout(root, 800)
+
+	assert.Equal(t, BuildDisplayListWithInputs(root, InputState{}), BuildDisplayListCached(root, InputState{}, cache))
+	// And once more with nothing changed, served entirely from the cache
+	assert.Equal(t, BuildDisplayListWithInputs(root, InputState{}), BuildDisplayListCached(root, InputState{}, cache))
+}
@@ -0,0 +1,374 @@
+package render
+
+import (
+	"browser/layout"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// findMeasureSize is the font size prefixes are measured at before scaling
+// to the painted box width
+const findMeasureSize = 16
+
+// FindMatch is one occurrence of the search text. A match that spans
+// several text boxes or wrapped lines has one rect per fragment.
+type FindMatch struct {
+	Rects []layout.Rect
+}
+
+// Top returns the y coordinate of the match's first fragment
+func (m FindMatch) Top() float64 {
+	if len(m.Rects) == 0 {
+		return 0
+	}
+	return m.Rects[0].Y
+}
+
+// DrawHighlight marks a find-in-page match; Current is the match the
+// user is on, drawn in a stronger color
+type DrawHighlight struct {
+	layout.Rect
+	Current bool
+}
+
+// findSegment is one painted line of a text box, positioned inside the
+// flattened page text
+type findSegment struct {
+	start, end int // rune offsets into the flattened text
+	runes      []rune
+	x, y       float64
+	height     float64
+	scale      float64 // painted width per measured unit, so prefixes can be measured
+}
+
+// FindText searches the text of all text boxes under root. Adjacent inline
+// text is searched as one run, so matches may cross element boundaries;
+// separate blocks are never joined.
+func FindText(root *layout.LayoutBox, query string, caseSensitive bool) []FindMatch {
+	if root == nil || query == "" {
+		return nil
+	}
+
+	var text []rune
+	var segments []findSegment
+	var lastBlock *layout.LayoutBox
+	collectFindSegments(root, &text, &segments, &lastBlock)
+
+	needle := []rune(query)
+	if !caseSensitive {
+		text = foldRunes(text)
+		needle = foldRunes(needle)
+	}
+
+	var matches []FindMatch
+	for i := 0; i+len(needle) <= len(text); {
+		if !slices.Equal(text[i:i+len(needle)], needle) {
+			i++
+			continue
+		}
+		if match := matchRects(segments, i, i+len(needle)); len(match.Rects) > 0 {
+			matches = append(matches, match)
+		}
+		i += len(needle)
+	}
+	return matches
+}
+
+func collectFindSegments(box *layout.LayoutBox, text *[]rune, segments *[]findSegment, lastBlock **layout.LayoutBox) {
+	if box.Type == layout.TextBox && box.Text != "" {
+		// Text from different blocks is separated by a newline, which a
+		// query typed into the find bar can never match
+		block := containingBlock(box)
+		if *lastBlock != nil && block != *lastBlock {
+			*text = append(*text, '\n')
+		}
+		*lastBlock = block
+
+		base := len(*text)
+		boxRunes := []rune(box.Text)
+		*text = append(*text, boxRunes...)
+		*segments = append(*segments, textBoxSegments(box, boxRunes, base)...)
+	}
+
+	for _, child := range box.Children {
+		collectFindSegments(child, text, segments, lastBlock)
+	}
+}
+
+// containingBlock returns the nearest ancestor that is not laid out inline
+func containingBlock(box *layout.LayoutBox) *layout.LayoutBox {
+	parent := box.Parent
+	for parent != nil && parent.IsInline() {
+		parent = parent.Parent
+	}
+	return parent
+}
+
+// textBoxSegments splits a text box into the lines it is painted as
+func textBoxSegments(box *layout.LayoutBox, boxRunes []rune, base int) []findSegment {
+	lines := box.WrappedLines
+	if strings.Contains(box.Text, "\n") {
+		lines = strings.Split(box.Text, "\n")
+	}
+	if len(lines) == 0 {
+		lines = []string{box.Text}
+	}
+
+	// Box width is the widest painted line; use it to convert measured
+	// widths into page coordinates whatever font size the box was laid out with
+	widest := 0.0
+	for _, line := range lines {
+		widest = max(widest, layout.MeasureText(line, findMeasureSize))
+	}
+	scale := 0.0
+	if widest > 0 {
+		scale = box.Rect.Width / widest
+	}
+	lineHeight := box.Rect.Height / float64(len(lines))
+
+	var segments []findSegment
+	cursor := 0
+	for i, line := range lines {
+		lineRunes := []rune(line)
+		offset := indexRunes(boxRunes[cursor:], lineRunes)
+		if offset < 0 {
+			continue
+		}
+		start := cursor + offset
+		cursor = start + len(lineRunes)
+
+		segments = append(segments, findSegment{
+			start:  base + start,
+			end:    base + cursor,
+			runes:  lineRunes,
+			x:      box.Rect.X,
+			y:      box.Rect.Y + float64(i)*lineHeight,
+			height: lineHeight,
+			scale:  scale,
+		})
+	}
+	return segments
+}
+
+// matchRects returns the painted fragments covering runes [start, end)
+func matchRects(segments []findSegment, start, end int) FindMatch {
+	var match FindMatch
+	for _, seg := range segments {
+		if seg.end <= start || seg.start >= end {
+			continue
+		}
+		from := max(start, seg.start) - seg.start
+		to := min(end, seg.end) - seg.start
+
+		x1 := seg.x + layout.MeasureText(string(seg.runes[:from]), findMeasureSize)*seg.scale
+		x2 := seg.x + layout.MeasureText(string(seg.runes[:to]), findMeasureSize)*seg.scale
+		match.Rects = append(match.Rects, layout.Rect{X: x1, Y: seg.y, Width: x2 - x1, Height: seg.height})
+	}
+	return match
+}
+
+// foldRunes lowercases rune by rune so offsets stay aligned with the original
+func foldRunes(runes []rune) []rune {
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+	}
+	return folded
+}
+
+func indexRunes(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if slices.Equal(haystack[i:i+len(needle)], needle) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findBarEntry is the find bar's text entry. The entry keeps Escape while
+// it has focus, so it closes the bar itself.
+type findBarEntry struct {
+	widget.Entry
+	onEscape func()
+}
+
+func newFindBarEntry(onEscape func()) *findBarEntry {
+	e := &findBarEntry{onEscape: onEscape}
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+func (e *findBarEntry) TypedKey(key *fyne.KeyEvent) {
+	if key.Name == fyne.KeyEscape {
+		e.onEscape()
+		return
+	}
+	e.Entry.TypedKey(key)
+}
+
+// newFindBar builds the hidden find-in-page bar and registers Ctrl+F
+func (b *Browser) newFindBar() *fyne.Container {
+	b.findEntry = newFindBarEntry(func() {
+		b.HideFindBar()
+	})
+	b.findEntry.SetPlaceHolder("Find in page")
+	b.findEntry.OnChanged = func(text string) {
+		// Switching tabs restores the tab's query; don't search again for it
+		if text != b.findQuery {
+			b.Find(text, b.findCase.Checked)
+		}
+	}
+	b.findEntry.OnSubmitted = func(_ string) {
+		b.FindNext()
+	}
+
+	b.findCase = widget.NewCheck("Match case", func(checked bool) {
+		if checked != b.findCaseSensitive {
+			b.Find(b.findEntry.Text, checked)
+		}
+	})
+	b.findStatus = widget.NewLabel("")
+
+	prevBtn := widget.NewButton("▲", func() {
+		b.FindPrevious()
+	})
+	nextBtn := widget.NewButton("▼", func() {
+		b.FindNext()
+	})
+	closeBtn := widget.NewButton("×", func() {
+		b.HideFindBar()
+	})
+	closeBtn.Importance = widget.LowImportance
+
+	// Find bar: [Entry] [▲] [▼] [Match case] [status] [×]
+	bar := container.NewBorder(
+		nil, nil, nil, // top, bottom, left
+		container.NewHBox(prevBtn, nextBtn, b.findCase, b.findStatus, closeBtn), // right
+		b.findEntry, // center
+	)
+	bar.Hide()
+
+	b.Window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyF, Modifier: fyne.KeyModifierShortcutDefault}, func(_ fyne.Shortcut) {
+		b.ShowFindBar()
+	})
+
+	return bar
+}
+
+// ShowFindBar opens the find bar and focuses its entry
+func (b *Browser) ShowFindBar() {
+	b.findBar.Show()
+	b.Window.Canvas().Focus(b.findEntry)
+	b.updateFindStatus()
+}
+
+// HideFindBar closes the find bar, removes the highlights and gives the
+// keyboard back to the page
+func (b *Browser) HideFindBar() {
+	b.findBar.Hide()
+	b.ClearFind()
+	b.focusPage()
+}
+
+// updateFindStatus shows "current of total" for the active tab
+func (b *Browser) updateFindStatus() {
+	if b.findStatus == nil || b.Tab == nil {
+		return
+	}
+	t := b.Tab
+	status := ""
+	switch {
+	case t.findQuery == "":
+	case len(t.findMatches) == 0:
+		status = "No results"
+	default:
+		status = fmt.Sprintf("%d of %d", t.findCurrent+1, len(t.findMatches))
+	}
+	fyne.Do(func() {
+		b.findStatus.SetText(status)
+	})
+}
+
+// Find highlights every match of query in the page and scrolls to the first
+func (t *Tab) Find(query string, caseSensitive bool) int {
+	t.findQuery = query
+	t.findCaseSensitive = caseSensitive
+	t.findCurrent = 0
+	t.updateFindMatches()
+	t.showFindResult()
+	return len(t.findMatches)
+}
+
+// FindNext moves to the next match, wrapping around at the end
+func (t *Tab) FindNext() {
+	if len(t.findMatches) == 0 {
+		return
+	}
+	t.findCurrent = (t.findCurrent + 1) % len(t.findMatches)
+	t.showFindResult()
+}
+
+// FindPrevious moves to the previous match, wrapping around at the start
+func (t *Tab) FindPrevious() {
+	if len(t.findMatches) == 0 {
+		return
+	}
+	t.findCurrent = (t.findCurrent + len(t.findMatches) - 1) % len(t.findMatches)
+	t.showFindResult()
+}
+
+// ClearFind drops the query and its highlights
+func (t *Tab) ClearFind() {
+	hadMatches := len(t.findMatches) > 0
+	t.findQuery = ""
+	t.findMatches = nil
+	t.findCurrent = 0
+	t.browser.updateFindStatus()
+	if hadMatches {
+		t.repaint()
+	}
+}
+
+// resetFind drops the query and matches of the page navigated away from
+func (t *Tab) resetFind() {
+	t.findQuery = ""
+	t.findMatches = nil
+	t.findCurrent = 0
+	b := t.browser
+	b.updateFindStatus()
+	if t.isActive() && b.findEntry != nil {
+		fyne.Do(func() {
+			b.findEntry.SetText("")
+		})
+	}
+}
+
+// updateFindMatches re-runs the search against the current layout, keeping
+// the current match index when it is still valid
+func (t *Tab) updateFindMatches() {
+	t.findMatches = FindText(t.layoutTree, t.findQuery, t.findCaseSensitive)
+	if t.findCurrent >= len(t.findMatches) {
+		t.findCurrent = 0
+	}
+}
+
+func (t *Tab) showFindResult() {
+	t.browser.updateFindStatus()
+	t.repaint()
+	t.scrollToFindMatch()
+}
+
+// scrollToFindMatch brings the current match into view if it is off screen
+func (t *Tab) scrollToFindMatch() {
+	if t.findCurrent >= len(t.findMatches) {
+		return
+	}
+	t.scrollIntoView(t.findMatches[t.findCurrent].Rects[0])
+}
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func layoutHTML(html string, width float64) *layout.LayoutBox {
+	doc := dom.Parse(strings.NewReader(html))
+	root := layout.BuildLayoutTree(doc, css.Stylesheet{}, layout.Viewport{Width: width})
+	layout.ComputeLayout(root, width)
+	return root
+}
+
+func TestFindTextCounts(t *testing.T) {
+	tests := []struct {
+		name          string
+		html          string
+		query         string
+		caseSensitive bool
+		expected      int
+	}{
+		{"single match", "<p>hello world</p>", "world", false, 1},
+		{"several matches", "<p>one two one two one</p>", "one", false, 3},
+		{"case insensitive", "<p>Go go GO</p>", "go", false, 3},
+		{"case sensitive", "<p>Go go GO</p>", "go", true, 1},
+		{"across inline elements", "<p>foo<b>bar</b>baz</p>", "foobarbaz", false, 1},
+		{"not across blocks", "<p>foo</p><p>bar</p>", "foobar", false, 0},
+		{"no match", "<p>hello</p>", "bye", false, 0},
+		{"empty query", "<p>hello</p>", "", false, 0},
+		{"matches do not overlap", "<p>aaaa</p>", "aa", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := layoutHTML(tt.html, 800)
+			matches := FindText(root, tt.query, tt.caseSensitive)
+			assert.Len(t, matches, tt.expected)
+		})
+	}
+}
+
+func TestFindTextRectsAcrossBoxes(t *testing.T) {
+	root := layoutHTML("<p>foo<b>bar</b></p>", 800)
+
+	matches := FindText(root, "obar", false)
+	require.Len(t, matches, 1)
+
+	// One fragment in "foo", one covering all of "bar"
+	rects := matches[0].Rects
+	require.Len(t, rects, 2)
+	assert.Less(t, rects[0].X, rects[1].X)
+	assert.Greater(t, rects[0].Width, 0.0)
+	assert.Greater(t, rects[1].Width, rects[0].Width)
+}
+
+func TestFindTextWrappedLines(t *testing.T) {
+	root := layoutHTML("<p>alpha beta gamma delta epsilon</p>", 60)
+
+	matches := FindText(root, "delta", false)
+	require.Len(t, matches, 1)
+	first := FindText(root, "alpha", false)
+	require.Len(t, first, 1)
+
+	// The later word is painted on a lower line, starting back at the left edge
+	assert.Greater(t, matches[0].Top(), first[0].Top())
+	assert.Equal(t, first[0].Rects[0].X, matches[0].Rects[0].X)
+}
+
+func TestBuildDisplayListFindHighlights(t *testing.T) {
+	root := layoutHTML("<p>one two one</p>", 800)
+	matches := FindText(root, "one", false)
+	require.Len(t, matches, 2)
+
+	commands := BuildDisplayListWithInputs(root, InputState{FindMatches: matches, FindCurrent: 1})
+
+	var highlights []DrawHighlight
+	for _, cmd := range commands {
+		if h, ok := cmd.(DrawHighlight); ok {
+			highlights = append(highlights, h)
+		}
+	}
+	require.Len(t, highlights, 2)
+	assert.False(t, highlights[0].Current)
+	assert.True(t, highlights[1].Current)
+}
+
+// findBarBrowser is focusBrowser with a find bar, open and showing query's
+// result
+func findBarBrowser(t *testing.T, html, query string) *Browser {
+	t.Helper()
+	b := focusBrowser(t, html)
+	b.findEntry = newFindBarEntry(func() {
+		b.HideFindBar()
+	})
+	b.findStatus = widget.NewLabel("")
+	b.findBar = container.NewVBox(b.findEntry, b.findStatus)
+	b.findEntry.SetText(query)
+	b.Find(query, false)
+	return b
+}
+
+func TestFindBarEscape(t *testing.T) {
+	b := findBarBrowser(t, "<p>hello</p>", "hello")
+	require.Len(t, b.findMatches, 1)
+
+	b.findEntry.TypedKey(&fyne.KeyEvent{Name: fyne.KeyEscape})
+	assert.False(t, b.findBar.Visible())
+	assert.Empty(t, b.findQuery)
+	assert.Empty(t, b.findMatches)
+
+	// Other keys still edit the entry
+	b.findEntry.TypedKey(&fyne.KeyEvent{Name: fyne.KeyEnd})
+	b.findEntry.TypedKey(&fyne.KeyEvent{Name: fyne.KeyBackspace})
+	assert.Equal(t, "hell", b.findEntry.Text)
+}
+
+func TestFindResetOnNavigation(t *testing.T) {
+	b := findBarBrowser(t, "<p>hello</p>", "missing")
+	require.Equal(t, "No results", b.findStatus.Text)
+
+	// SetContent resets the find state for the new page
+	b.resetFind()
+	assert.Empty(t, b.findQuery)
+	assert.Empty(t, b.findMatches)
+	assert.Empty(t, b.findStatus.Text)
+	assert.Empty(t, b.findEntry.Text)
+}
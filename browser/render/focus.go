@@ -0,0 +1,228 @@
+package render
+
+import (
+	"browser/a11y"
+	"browser/dom"
+	"browser/layout"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// DrawFocusRing outlines the element that has keyboard focus
+type DrawFocusRing struct {
+	layout.Rect
+}
+
+// pageKeys takes keyboard input for the page while the page has focus.
+// Fyne moves focus between its own widgets on Tab unless the focused one
+// accepts Tab, so the page needs a focusable widget of its own for Tab to
+// move between the page's elements instead.
+type pageKeys struct {
+	widget.BaseWidget
+	browser *Browser
+}
+
+func newPageKeys(b *Browser) *pageKeys {
+	keys := &pageKeys{browser: b}
+	keys.ExtendBaseWidget(keys)
+	return keys
+}
+
+func (k *pageKeys) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(canvas.NewRectangle(color.Transparent))
+}
+
+func (k *pageKeys) FocusGained() {}
+func (k *pageKeys) FocusLost()   {}
+
+func (k *pageKeys) AcceptsTab() bool { return true }
+
+func (k *pageKeys) TypedRune(r rune) {
+	k.browser.handleTypedRune(r)
+}
+
+func (k *pageKeys) TypedKey(key *fyne.KeyEvent) {
+	k.browser.handleTypedKey(key)
+}
+
+// focusPage sends keyboard input to the page, as a click on it does
+func (b *Browser) focusPage() {
+	if b.keys != nil && b.Window.Canvas().Focused() != b.keys {
+		b.Window.Canvas().Focus(b.keys)
+	}
+}
+
+// shiftHeld reports whether Shift is down, to tell Shift+Tab from Tab
+func shiftHeld() bool {
+	if app := fyne.CurrentApp(); app != nil {
+		if driver, ok := app.Driver().(desktop.Driver); ok {
+			return driver.CurrentKeyModifiers()&fyne.KeyModifierShift != 0
+		}
+	}
+	return false
+}
+
+// AccessibilityTree returns the accessibility tree of the tab's page, with
+// form controls as the user left them
+func (t *Tab) AccessibilityTree() *a11y.Node {
+	if t.document == nil {
+		return nil
+	}
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
+	return a11y.Build(t.document, t.layoutTree, t.controlState)
+}
+
+// controlState is the value of a form control and whether that checkbox or
+// radio button itself is checked
+func (t *Tab) controlState(node *dom.Node) (string, bool) {
+	value, checked := t.FormState(node)
+	if node.TagName == "input" && dom.InputType(node) == "radio" {
+		if selected := t.radioValues[node.Attributes["name"]]; selected != nil {
+			return value, selected == node
+		}
+		_, checked = node.Attributes["checked"]
+	}
+	return value, checked
+}
+
+// focusNext moves keyboard focus to the next element in tab order, or the
+// previous one, wrapping around at either end
+func (t *Tab) focusNext(backward bool) {
+	tree := t.AccessibilityTree()
+	if tree == nil {
+		return
+	}
+	order := a11y.TabOrder(tree)
+	if len(order) == 0 {
+		return
+	}
+
+	current := -1
+	for i, n := range order {
+		if n.DOM == t.keyboardFocus {
+			current = i
+			break
+		}
+	}
+	next := 0
+	switch {
+	case current < 0 && backward:
+		next = len(order) - 1
+	case backward:
+		next = (current - 1 + len(order)) % len(order)
+	case current >= 0:
+		next = (current + 1) % len(order)
+	}
+	t.setKeyboardFocus(order[next].DOM)
+
+	if bounds := order[next].Bounds; bounds != nil {
+		t.scrollIntoView(layout.Rect{X: bounds.X, Y: bounds.Y, Width: bounds.Width, Height: bounds.Height})
+	}
+}
+
+// setKeyboardFocus focuses an element; text fields also take typing
+func (t *Tab) setKeyboardFocus(node *dom.Node) {
+	t.keyboardFocus = node
+	t.openSelectNode = nil
+	t.focusedInputNode = nil
+	if node != nil && isTextControl(node) {
+		t.focusedInputNode = node
+	}
+	t.repaint()
+}
+
+// activateFocus does what a click on the focused element would: follow a
+// link, press a button, toggle a checkbox
+func (b *Browser) activateFocus() bool {
+	if b.keyboardFocus == nil || b.layoutTree == nil {
+		return false
+	}
+	rects := focusRects(b.layoutTree, b.keyboardFocus)
+	if len(rects) == 0 {
+		return false
+	}
+	r := rects[0]
+	b.handleClick(r.X+r.Width/2, r.Y+r.Height/2)
+	return true
+}
+
+// focusableAncestor returns node or the closest ancestor that can take
+// keyboard focus, the element a click on node focuses
+func focusableAncestor(node *dom.Node) *dom.Node {
+	for n := node; n != nil; n = n.Parent {
+		if a11y.IsFocusable(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// focusRects returns the boxes an element was laid out in: its own, or for
+// an inline element without one, those of its content
+func focusRects(box *layout.LayoutBox, node *dom.Node) []layout.Rect {
+	if box.Node != nil && (box.Node == node || (box.Node.Type == dom.Text && isDescendant(box.Node, node))) {
+		if box.Rect.Width > 0 && box.Rect.Height > 0 {
+			return []layout.Rect{box.Rect}
+		}
+		return nil
+	}
+	var rects []layout.Rect
+	for _, child := range box.Children {
+		rects = append(rects, focusRects(child, node)...)
+	}
+	return rects
+}
+
+// isDescendant reports whether node is inside ancestor
+func isDescendant(node, ancestor *dom.Node) bool {
+	for n := node.Parent; n != nil; n = n.Parent {
+		if n == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// focusRingObject draws a focus ring just outside its rect
+func focusRingObject(c DrawFocusRing) fyne.CanvasObject {
+	const gap = 2
+	ring := canvas.NewRectangle(color.Transparent)
+	ring.StrokeColor = ColorBorderFocused
+	ring.StrokeWidth = 2
+	ring.CornerRadius = 3
+	ring.Resize(fyne.NewSize(float32(c.Width+2*gap), float32(c.Height+2*gap)))
+	ring.Move(fyne.NewPos(float32(c.X-gap), float32(c.Y-gap)))
+	return ring
+}
+
+// scrollIntoView scrolls the page so rect is visible if it is off screen.
+// repaint queues its display first, so this runs against the new scroll.
+func (t *Tab) scrollIntoView(rect layout.Rect) {
+	b := t.browser
+	fyne.Do(func() {
+		if b.shownTab != t || len(b.content.Objects) == 0 {
+			return
+		}
+		scroll, ok := b.content.Objects[0].(*container.Scroll)
+		if !ok {
+			return
+		}
+
+		view := scroll.Size()
+		offset := scroll.Offset
+		if float32(rect.Y) < offset.Y || float32(rect.Y+rect.Height) > offset.Y+view.Height {
+			// Leave some context above the rect
+			offset.Y = max(0, float32(rect.Y)-view.Height/3)
+		}
+		if float32(rect.X) < offset.X || float32(rect.X+rect.Width) > offset.X+view.Width {
+			offset.X = max(0, float32(rect.X)-view.Width/3)
+		}
+		scroll.ScrollToOffset(offset)
+	})
+}
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// focusBrowser shows html in the active tab of a browser without a window
+func focusBrowser(t *testing.T, html string) *Browser {
+	t.Helper()
+	test.NewTempApp(t)
+	b := &Browser{content: container.NewMax()}
+	b.Tab = b.newTab()
+	b.document = dom.Parse(strings.NewReader(html))
+	b.layoutTree = layout.BuildLayoutTree(b.document, css.Stylesheet{}, layout.Viewport{Width: 800})
+	layout.ComputeLayout(b.layoutTree, 800)
+	return b
+}
+
+func TestFocusNext(t *testing.T) {
+	b := focusBrowser(t, `<p><a href="/a">A</a> <input id="name"> <button tabindex="-1">Skipped</button> <button>B</button> <input type="checkbox"></p>`)
+
+	var got []string
+	for range 5 {
+		b.focusNext(false)
+		require.NotNil(t, b.keyboardFocus)
+		got = append(got, b.keyboardFocus.TagName)
+	}
+	assert.Equal(t, []string{"a", "input", "button", "input", "a"}, got)
+
+	b.focusNext(true)
+	assert.Equal(t, "checkbox", b.keyboardFocus.Attributes["type"])
+	b.focusNext(true)
+	assert.Equal(t, "B", b.keyboardFocus.InnerText())
+
+	// Text fields take typing when focused, and show focus with their border
+	b.focusNext(true)
+	assert.Equal(t, b.keyboardFocus, b.focusedInputNode)
+	assert.Empty(t, focusRings(b))
+	b.focusNext(false)
+	assert.Nil(t, b.focusedInputNode)
+	assert.Len(t, focusRings(b), 1)
+}
+
+func TestActivateFocus(t *testing.T) {
+	b := focusBrowser(t, `<p><input type="checkbox"> <a href="/next">Next</a></p>`)
+	var navigated []string
+	b.OnNavigate = func(req NavigationRequest) { navigated = append(navigated, req.URL) }
+
+	b.focusNext(false)
+	checkbox := b.keyboardFocus
+	require.True(t, b.activateFocus())
+	assert.True(t, b.checkboxValue[checkbox])
+	assert.Equal(t, checkbox, b.keyboardFocus, "activating keeps focus")
+
+	b.keyboardFocus = nil
+	assert.False(t, b.activateFocus())
+}
+
+func TestControlState(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	doc := dom.Parse(strings.NewReader(`<form><input type="radio" name="r" checked><input type="radio" name="r"></form>`))
+	radios := dom.FormControls(dom.FindElementsByTagName(doc, "form"))
+
+	_, checked := tab.controlState(radios[1])
+	assert.False(t, checked)
+	tab.radioValues["r"] = radios[1]
+	_, checked = tab.controlState(radios[0])
+	assert.False(t, checked)
+	_, checked = tab.controlState(radios[1])
+	assert.True(t, checked)
+}
+
+// focusRings returns the focus rings painted for the page
+func focusRings(b *Browser) []DrawFocusRing {
+	var rings []DrawFocusRing
+	for _, cmd := range BuildDisplayListWithInputs(b.layoutTree, b.inputState()) {
+		if ring, ok := cmd.(DrawFocusRing); ok {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
@@ -0,0 +1,290 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/fonts"
+	"browser/graphics"
+	"browser/layout"
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+)
+
+// FrameRequest asks the loader for the document of an <iframe>. URL is the
+// absolute address to fetch; with SrcDoc set, the markup is used instead
+// and URL is the address of the document holding the iframe.
+type FrameRequest struct {
+	Tab     *Tab
+	Element *dom.Node
+	URL     string
+	SrcDoc  string
+
+	seq int // which request of the frame this is; older answers are dropped
+}
+
+// FrameContent is a loaded frame document, handed back to the tab
+type FrameContent struct {
+	Document    *dom.Node
+	URL         string
+	ExternalCSS string               // CSS from the document's <link> tags
+	OnClick     func(node *dom.Node) // the document's script click handler
+	Fonts       *fonts.Registry      // web fonts of the document's @font-face rules
+}
+
+// frameContext is the browsing context of one <iframe>: its document and
+// the layout state kept between reflows, like the tab keeps for the page
+type frameContext struct {
+	src string // the src or srcdoc the document was requested for
+	seq int
+
+	document  *dom.Node
+	url       *url.URL
+	css       string
+	onJSClick func(node *dom.Node)
+	fonts     *fonts.Registry
+
+	tree      *layout.LayoutBox
+	layoutCSS string
+	sheet     css.Stylesheet
+	viewport  layout.Viewport
+}
+
+// frameSource is what an iframe asks to show; srcdoc wins over src
+func frameSource(element *dom.Node) string {
+	if srcdoc, ok := element.Attributes["srcdoc"]; ok {
+		return "srcdoc:" + srcdoc
+	}
+	return element.Attributes["src"]
+}
+
+// loadFrames requests documents for the iframes of the page, and of the
+// frames already loaded, that have none or whose src changed. Must be
+// called with reflowMu held.
+func (t *Tab) loadFrames() {
+	if t.document == nil {
+		return
+	}
+	documents := []*dom.Node{t.document}
+	for _, ctx := range t.frames {
+		if ctx.document != nil {
+			documents = append(documents, ctx.document)
+		}
+	}
+	for _, document := range documents {
+		for _, element := range findIFrames(document) {
+			source := frameSource(element)
+			if ctx, ok := t.frames[element]; ok && ctx.src == source {
+				continue
+			}
+			ctx := &frameContext{src: source}
+			t.frames[element] = ctx
+
+			req := FrameRequest{Element: element}
+			if srcdoc, ok := element.Attributes["srcdoc"]; ok {
+				req.SrcDoc = srcdoc
+				req.URL = t.documentURL(document)
+			} else if src := element.Attributes["src"]; src != "" && src != "about:blank" {
+				req.URL = t.resolveFrom(document, src)
+			} else {
+				continue
+			}
+			t.requestFrame(ctx, req)
+		}
+	}
+}
+
+// requestFrame hands req to the loader. Must be called with reflowMu held.
+func (t *Tab) requestFrame(ctx *frameContext, req FrameRequest) {
+	if t.browser.OnLoadFrame == nil {
+		return
+	}
+	ctx.seq++
+	req.Tab = t
+	req.seq = ctx.seq
+	go t.browser.OnLoadFrame(req)
+}
+
+// SetFrameDocument shows a loaded document in the iframe it was requested
+// for. Answers to requests that were superseded, or that belong to a page
+// the tab has left, are ignored.
+func (t *Tab) SetFrameDocument(req FrameRequest, content FrameContent) {
+	t.reflowMu.Lock()
+	ctx, ok := t.frames[req.Element]
+	if !ok || ctx.seq != req.seq {
+		t.reflowMu.Unlock()
+		graphics.ReleaseCanvases(content.Document)
+		return
+	}
+	if ctx.document != nil && ctx.document != content.Document {
+		graphics.ReleaseCanvases(ctx.document)
+	}
+	ctx.document = content.Document
+	ctx.url, _ = url.Parse(content.URL)
+	ctx.css = content.ExternalCSS
+	ctx.onJSClick = content.OnClick
+	ctx.fonts = content.Fonts
+	ctx.tree = nil
+	t.reflowMu.Unlock()
+
+	go t.Reflow(t.browser.Width)
+}
+
+// navigateFrame loads rawURL into the frame of an iframe element, as a
+// link without a target inside the frame does
+func (t *Tab) navigateFrame(element *dom.Node, rawURL string) {
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
+	if ctx, ok := t.frames[element]; ok {
+		t.requestFrame(ctx, FrameRequest{Element: element, URL: rawURL})
+	}
+}
+
+// layoutFrames lays out the documents of the iframes in root at the size
+// of their boxes and registers them for painting and hit testing. Frames
+// nested in those documents are laid out in turn. Must be called with
+// reflowMu held.
+func (t *Tab) layoutFrames(root *layout.LayoutBox) {
+	for _, box := range findIFrameBoxes(root) {
+		ctx, ok := t.frames[box.Node]
+		if !ok || ctx.document == nil {
+			continue
+		}
+		fullCSS := ctx.css + "\n" + dom.FindActiveStyleContent(ctx.document)
+		viewport := layout.Viewport{Width: box.Rect.Width, Height: box.Rect.Height, Fonts: ctx.fonts}
+		if ctx.tree != nil && ctx.layoutCSS == fullCSS && ctx.viewport == viewport {
+			ctx.tree = layout.UpdateLayoutTree(ctx.tree, ctx.document, ctx.sheet, viewport)
+		} else {
+			ctx.sheet = css.Parse(fullCSS)
+			ctx.layoutCSS = fullCSS
+			ctx.viewport = viewport
+			ctx.tree = layout.BuildLayoutTree(ctx.document, ctx.sheet, viewport)
+		}
+		layout.ComputeLayout(ctx.tree, box.Rect.Width)
+		frameURL := ""
+		if ctx.url != nil {
+			frameURL = ctx.url.String()
+		}
+		layout.FrameFor(box.Node).SetRoot(ctx.tree, frameURL)
+		t.layoutFrames(ctx.tree)
+	}
+}
+
+// releaseFrames forgets the frames of the page being replaced. Must be
+// called with reflowMu held.
+func (t *Tab) releaseFrames() {
+	for element, ctx := range t.frames {
+		layout.ReleaseFrame(element)
+		if ctx.document != nil {
+			graphics.ReleaseCanvases(ctx.document)
+		}
+	}
+	t.frames = make(map[*dom.Node]*frameContext)
+}
+
+// frameOf returns the iframe element showing the document node belongs
+// to, or nil for nodes of the page itself
+func (t *Tab) frameOf(node *dom.Node) (*dom.Node, *frameContext) {
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
+	document := node.OwnerDocument()
+	if document == t.document {
+		return nil, nil
+	}
+	for element, ctx := range t.frames {
+		if ctx.document == document {
+			return element, ctx
+		}
+	}
+	return nil, nil
+}
+
+// documentURL is the address of the page or of a loaded frame document
+func (t *Tab) documentURL(document *dom.Node) string {
+	if document != t.document {
+		for _, ctx := range t.frames {
+			if ctx.document == document && ctx.url != nil {
+				return ctx.url.String()
+			}
+		}
+	}
+	if t.currentURL == nil {
+		return ""
+	}
+	return t.currentURL.String()
+}
+
+// resolveFrom resolves href against the document it appears in
+func (t *Tab) resolveFrom(document *dom.Node, href string) string {
+	if document == t.document {
+		return t.resolveURL(href)
+	}
+	base, err := url.Parse(t.documentURL(document))
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func findIFrames(node *dom.Node) []*dom.Node {
+	var found []*dom.Node
+	if node.Type == dom.Element && node.TagName == dom.TagIFrame {
+		found = append(found, node)
+	}
+	for _, child := range node.Children {
+		found = append(found, findIFrames(child)...)
+	}
+	return found
+}
+
+func findIFrameBoxes(box *layout.LayoutBox) []*layout.LayoutBox {
+	var found []*layout.LayoutBox
+	if box.Type == layout.IFrameBox && box.Node != nil {
+		found = append(found, box)
+	}
+	for _, child := range box.Children {
+		found = append(found, findIFrameBoxes(child)...)
+	}
+	return found
+}
+
+// frameObject shows a frame's document in a scroll container at the
+// iframe's rect. Scrolling is written back to the frame so hit testing and
+// the next repaint see it.
+func frameObject(c DrawFrame, useCache bool, onImageLoad func()) fyne.CanvasObject {
+	baseURL := ""
+	if u, err := url.Parse(c.URL); err == nil && u.Host != "" {
+		baseURL = u.Scheme + "://" + u.Host
+	}
+	objects := RenderToCanvas(c.Commands, baseURL, useCache, onImageLoad)
+
+	size := fyne.NewSize(float32(max(c.ContentWidth, c.Width)), float32(max(c.ContentHeight, c.Height)))
+	content := container.New(&fixedLayout{size: size}, objects...)
+	scroll := container.NewScroll(content)
+	scroll.Resize(fyne.NewSize(float32(c.Width), float32(c.Height)))
+	scroll.Move(fyne.NewPos(float32(c.X), float32(c.Y)))
+	scroll.Offset = fyne.NewPos(float32(c.ScrollX), float32(c.ScrollY))
+	if c.Frame != nil {
+		frame := c.Frame
+		scroll.OnScrolled = func(p fyne.Position) {
+			frame.ScrollTo(float64(p.X), float64(p.Y))
+		}
+	}
+	return scroll
+}
+
+// fixedLayout keeps objects where they were moved and reports a fixed size
+type fixedLayout struct {
+	size fyne.Size
+}
+
+func (l *fixedLayout) Layout([]fyne.CanvasObject, fyne.Size) {}
+
+func (l *fixedLayout) MinSize([]fyne.CanvasObject) fyne.Size {
+	return l.size
+}
@@ -0,0 +1,95 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/layout"
+	"net/url"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaintFrameDocument(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<iframe src="child.html" width="200" height="100"></iframe>`))
+	root := layout.BuildLayoutTree(doc, css.Stylesheet{}, layout.Viewport{Width: 800})
+	layout.ComputeLayout(root, 800)
+
+	iframe := dom.FindElementsByTagName(doc, dom.TagIFrame)
+	assert.Empty(t, findFrames(BuildDisplayList(root)), "nothing is drawn before the document loads")
+
+	childDoc := dom.Parse(strings.NewReader(`<p>inside</p>`))
+	childRoot := layout.BuildLayoutTree(childDoc, css.Stylesheet{}, layout.Viewport{Width: 200, Height: 100})
+	layout.ComputeLayout(childRoot, 200)
+	frame := layout.FrameFor(iframe)
+	frame.SetRoot(childRoot, "http://example.com/child.html")
+	frame.ScrollTo(0, 12)
+	t.Cleanup(func() { layout.ReleaseFrame(iframe) })
+
+	frames := findFrames(BuildDisplayList(root))
+	require.Len(t, frames, 1)
+	c := frames[0]
+	assert.Equal(t, 200.0, c.Width)
+	assert.Equal(t, 12.0, c.ScrollY)
+	assert.Equal(t, "http://example.com/child.html", c.URL)
+
+	var text []string
+	for _, cmd := range c.Commands {
+		if d, ok := cmd.(DrawText); ok {
+			text = append(text, d.Text)
+		}
+	}
+	assert.Equal(t, []string{"inside"}, text)
+
+	scroll, ok := frameObject(c, true, nil).(*container.Scroll)
+	require.True(t, ok)
+	assert.Equal(t, float32(12), scroll.Offset.Y)
+	scroll.OnScrolled(scroll.Offset.AddXY(0, 30))
+	_, y := frame.Scroll()
+	assert.Equal(t, 42.0, y, "scrolling the frame is remembered")
+}
+
+func TestLoadFramesRequestsDocuments(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	tab.currentURL, _ = url.Parse("http://example.com/dir/page.html")
+	tab.document = dom.Parse(strings.NewReader(`<iframe src="child.html"></iframe><iframe srcdoc="<p>hi</p>"></iframe><iframe></iframe>`))
+
+	requests := make(chan FrameRequest, 4)
+	b.OnLoadFrame = func(req FrameRequest) { requests <- req }
+
+	tab.reflowMu.Lock()
+	tab.loadFrames()
+	tab.loadFrames() // unchanged frames are not requested again
+	tab.reflowMu.Unlock()
+
+	byURL := map[string]FrameRequest{}
+	for range 2 {
+		req := <-requests
+		byURL[req.URL+"|"+req.SrcDoc] = req
+	}
+	assert.Empty(t, requests)
+	require.Contains(t, byURL, "http://example.com/dir/child.html|")
+	require.Contains(t, byURL, "http://example.com/dir/page.html|<p>hi</p>")
+
+	// A document arriving for a request that was superseded is dropped
+	stale := byURL["http://example.com/dir/child.html|"]
+	tab.navigateFrame(stale.Element, "http://example.com/next.html")
+	next := <-requests
+	assert.Equal(t, "http://example.com/next.html", next.URL)
+	tab.SetFrameDocument(stale, FrameContent{Document: dom.Parse(strings.NewReader(`<p>old</p>`))})
+	assert.Nil(t, tab.frames[stale.Element].document)
+}
+
+func findFrames(commands []DisplayCommand) []DrawFrame {
+	var frames []DrawFrame
+	for _, cmd := range commands {
+		if c, ok := cmd.(DrawFrame); ok {
+			frames = append(frames, c)
+		}
+	}
+	return frames
+}
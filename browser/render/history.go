@@ -0,0 +1,55 @@
+package render
+
+// History is a tab's back/forward list. Pos points at the current entry.
+type History struct {
+	Entries []string `json:"entries"`
+	Pos     int      `json:"pos"`
+}
+
+func NewHistory() *History {
+	return &History{Entries: []string{}, Pos: -1}
+}
+
+// Push records a newly visited URL, dropping any forward entries
+func (h *History) Push(url string) {
+	if h.Pos < len(h.Entries)-1 {
+		h.Entries = h.Entries[:h.Pos+1]
+	}
+
+	h.Entries = append(h.Entries, url)
+	h.Pos = len(h.Entries) - 1
+}
+
+// Back moves the cursor one entry back and returns that URL
+func (h *History) Back() (string, bool) {
+	if !h.CanGoBack() {
+		return "", false
+	}
+	h.Pos--
+	return h.Entries[h.Pos], true
+}
+
+// Forward moves the cursor one entry forward and returns that URL
+func (h *History) Forward() (string, bool) {
+	if !h.CanGoForward() {
+		return "", false
+	}
+	h.Pos++
+	return h.Entries[h.Pos], true
+}
+
+func (h *History) CanGoBack() bool {
+	return h.Pos > 0
+}
+
+func (h *History) CanGoForward() bool {
+	return h.Pos >= 0 && h.Pos < len(h.Entries)-1
+}
+
+// Current returns the URL at the cursor, or "" for an empty history
+func (h *History) Current() string {
+	if h.Pos < 0 || h.Pos >= len(h.Entries) {
+		return ""
+	}
+	return h.Entries[h.Pos]
+}
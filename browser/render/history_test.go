@@ -0,0 +1,50 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryBackForward(t *testing.T) {
+	h := NewHistory()
+	assert.False(t, h.CanGoBack())
+	assert.False(t, h.CanGoForward())
+	assert.Equal(t, "", h.Current())
+
+	h.Push("a")
+	h.Push("b")
+	h.Push("c")
+
+	url, ok := h.Back()
+	assert.True(t, ok)
+	assert.Equal(t, "b", url)
+
+	url, ok = h.Back()
+	assert.True(t, ok)
+	assert.Equal(t, "a", url)
+
+	_, ok = h.Back()
+	assert.False(t, ok)
+	assert.Equal(t, "a", h.Current())
+
+	url, ok = h.Forward()
+	assert.True(t, ok)
+	assert.Equal(t, "b", url)
+	assert.True(t, h.CanGoForward())
+}
+
+func TestHistoryPushDropsForwardEntries(t *testing.T) {
+	h := NewHistory()
+	h.Push("a")
+	h.Push("b")
+	h.Push("c")
+	h.Back()
+	h.Back()
+
+	h.Push("d")
+
+	assert.Equal(t, []string{"a", "d"}, h.Entries)
+	assert.Equal(t, "d", h.Current())
+	assert.False(t, h.CanGoForward())
+}
@@ -3,9 +3,13 @@ package render
 import (
 	"browser/css"
 	"browser/dom"
+	"browser/fonts"
+	"browser/graphics"
 	"browser/layout"
 	"fmt"
+	"image"
 	"image/color"
+	"math"
 	"strings"
 )
 
@@ -61,6 +65,7 @@ type DrawTextarea struct {
 	IsFocused   bool
 	IsDisabled  bool
 	IsReadonly  bool
+	IsValid     bool
 }
 
 type DrawSelect struct {
@@ -94,9 +99,14 @@ type InputState struct {
 	CheckboxValues  map[*dom.Node]bool   // Checked state per check
 	FileInputValues map[*dom.Node]string // Selected filename per file input
 	InvalidNodes    map[*dom.Node]bool   // Nodes with invalid input
+	Validation      *ValidationBubble    // Message of a blocked submission
+	KeyboardFocus   *dom.Node            // Element with keyboard focus
 
 	SelectionStart *SelectionPoint
 	SelectionEnd   *SelectionPoint
+
+	FindMatches []FindMatch // Find-in-page matches to highlight
+	FindCurrent int         // Index of the active match in FindMatches
 }
 
 // isTextSelected checks if a text box is within the current selection range
@@ -165,6 +175,8 @@ type DrawRect struct {
 	layout.Rect
 	Color        color.Color
 	CornerRadius float64
+	StrokeColor  color.Color // outline drawn inside the rect when StrokeWidth > 0
+	StrokeWidth  float64
 }
 
 type DrawText struct {
@@ -176,6 +188,8 @@ type DrawText struct {
 	Bold          bool
 	Italic        bool
 	Monospace     bool
+	Family        []string        // CSS font-family stack, for web fonts
+	Fonts         *fonts.Registry // the document's web fonts, nil for none
 	Underline     bool
 	Strikethrough bool
 	TextTransform string
@@ -186,10 +200,60 @@ type DrawImage struct {
 	URL string
 }
 
+// DrawRaster shows a bitmap produced by the page itself: a rasterized
+// <svg> or a <canvas>
+type DrawRaster struct {
+	layout.Rect
+	Image image.Image
+}
+
+// DrawShadow is one box-shadow of the border box Rect. Outer shadows are
+// clipped to outside the box and inset shadows to inside it.
+type DrawShadow struct {
+	layout.Rect
+	CornerRadius float64
+	Shadow       css.BoxShadow
+}
+
+// DrawGradient fills Rect with a CSS gradient
+type DrawGradient struct {
+	layout.Rect
+	Gradient     *css.Gradient
+	CornerRadius float64
+}
+
+// DrawBackground tiles a background image over Rect
+type DrawBackground struct {
+	layout.Rect
+	URL          string
+	Repeat       string
+	Size         css.BackgroundSize
+	Position     css.BackgroundPosition
+	CornerRadius float64
+}
+
+// DrawFrame shows the display list of an iframe's document, whose
+// coordinates start at the iframe's top left, scrolled within Rect
+type DrawFrame struct {
+	layout.Rect
+	URL                         string
+	Commands                    []DisplayCommand
+	ContentWidth, ContentHeight float64
+	ScrollX, ScrollY            float64
+	Frame                       *layout.Frame
+}
+
 type DrawHR struct {
 	layout.Rect
 }
 
+// DrawTransformed draws the commands of a box with a CSS transform under
+// Matrix, which maps page coordinates to where they appear on screen
+type DrawTransformed struct {
+	Matrix   graphics.Matrix
+	Commands []DisplayCommand
+}
+
 type DrawFileInput struct {
 	layout.Rect
 	Filename   string
@@ -226,6 +290,10 @@ func BuildDisplayList(root *layout.LayoutBox) []DisplayCommand {
 }
 
 func BuildDisplayListWithInputs(root *layout.LayoutBox, state InputState) []DisplayCommand {
+	return buildDisplayList(root, state, nil)
+}
+
+func buildDisplayList(root *layout.LayoutBox, state InputState, pass *displayPass) []DisplayCommand {
 	var commands []DisplayCommand
 
 	contentHeight := root.Rect.Y + root.Rect.Height
@@ -238,12 +306,52 @@ func BuildDisplayListWithInputs(root *layout.LayoutBox, state InputState) []Disp
 		Color: color.White,
 	})
 
-	paintLayoutBoxWithInputs(root, &commands, DefaultStyle(), state)
+	paintLayoutBoxWithInputs(root, &commands, DefaultStyle(), state, pass)
+
+	// Find highlights are translucent and go on top of the page
+	for i, match := range state.FindMatches {
+		for _, rect := range match.Rects {
+			commands = append(commands, DrawHighlight{Rect: rect, Current: i == state.FindCurrent})
+		}
+	}
+
+	// Text fields show focus with their border, other elements with a ring
+	if focus := state.KeyboardFocus; focus != nil && focus != state.FocusedNode {
+		for _, rect := range focusRects(root, focus) {
+			commands = append(commands, DrawFocusRing{Rect: rect})
+		}
+	}
+
+	// The validation bubble hangs below its field, over whatever follows
+	if bubble := state.Validation; bubble != nil {
+		if box := findBoxByNode(root, bubble.Node); box != nil {
+			commands = append(commands, DrawValidationMessage{
+				X:    box.Rect.X,
+				Y:    box.Rect.Y + box.Rect.Height,
+				Text: bubble.Text,
+			})
+		}
+	}
 
 	return commands
 }
 
-func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand, style TextStyle, state InputState) {
+// paintLayoutBoxWithInputs appends the commands for box and its subtree. It
+// returns false if they depend on form or canvas state, which changes
+// without a relayout, so they must not be reused from a display cache.
+func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand, style TextStyle, state InputState, pass *displayPass) bool {
+	// Blocks that were not relaid out since the last paint reuse its commands
+	cacheable := pass != nil && box.Generation() != 0
+	if cacheable {
+		if segment, ok := pass.previous.lookup(box); ok {
+			*commands = append(*commands, segment.commands...)
+			pass.next.segments[box] = segment
+			return true
+		}
+	}
+	start := len(*commands)
+	reusable := true
+
 	currentStyle := style
 
 	// Apply inline styles from CSS
@@ -270,7 +378,8 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 	if box.Style.TextTransform != "" {
 		currentStyle.TextTransform = box.Style.TextTransform
 	}
-	if box.Style.Opacity > 0 {
+	// Elements always have an opacity; other boxes leave it at zero
+	if box.Style.Opacity > 0 || box.Node != nil && box.Node.Type == dom.Element {
 		currentStyle.Opacity = box.Style.Opacity
 	}
 	if box.Style.Visibility != "" {
@@ -279,48 +388,8 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 
 	isHidden := currentStyle.Visibility == "hidden"
 
-	// Draw background if set
-	if box.Style.BackgroundColor != nil && !isHidden {
-		*commands = append(*commands, DrawRect{
-			Rect:         box.Rect,
-			Color:        applyOpacity(box.Style.BackgroundColor, currentStyle.Opacity),
-			CornerRadius: box.Style.BorderRadius,
-		})
-	}
-
-	if box.Style.BackgroundImage != "" && !isHidden {
-		*commands = append(*commands, DrawImage{
-			Rect: box.Rect,
-			URL:  box.Style.BackgroundImage,
-		})
-	}
-
-	// Draw borders if set
 	if !isHidden {
-		if box.Style.BorderTopWidth > 0 && box.Style.BorderTopStyle != "none" && box.Style.BorderTopColor != nil {
-			*commands = append(*commands, DrawRect{
-				Rect:  layout.Rect{X: box.Rect.X, Y: box.Rect.Y, Width: box.Rect.Width, Height: box.Style.BorderTopWidth},
-				Color: applyOpacity(box.Style.BorderTopColor, currentStyle.Opacity),
-			})
-		}
-		if box.Style.BorderBottomWidth > 0 && box.Style.BorderBottomStyle != "none" && box.Style.BorderBottomColor != nil {
-			*commands = append(*commands, DrawRect{
-				Rect:  layout.Rect{X: box.Rect.X, Y: box.Rect.Y + box.Rect.Height - box.Style.BorderBottomWidth, Width: box.Rect.Width, Height: box.Style.BorderBottomWidth},
-				Color: applyOpacity(box.Style.BorderBottomColor, currentStyle.Opacity),
-			})
-		}
-		if box.Style.BorderLeftWidth > 0 && box.Style.BorderLeftStyle != "none" && box.Style.BorderLeftColor != nil {
-			*commands = append(*commands, DrawRect{
-				Rect:  layout.Rect{X: box.Rect.X, Y: box.Rect.Y, Width: box.Style.BorderLeftWidth, Height: box.Rect.Height},
-				Color: applyOpacity(box.Style.BorderLeftColor, currentStyle.Opacity),
-			})
-		}
-		if box.Style.BorderRightWidth > 0 && box.Style.BorderRightStyle != "none" && box.Style.BorderRightColor != nil {
-			*commands = append(*commands, DrawRect{
-				Rect:  layout.Rect{X: box.Rect.X + box.Rect.Width - box.Style.BorderRightWidth, Y: box.Rect.Y, Width: box.Style.BorderRightWidth, Height: box.Rect.Height},
-				Color: applyOpacity(box.Style.BorderRightColor, currentStyle.Opacity),
-			})
-		}
+		paintBackground(box, currentStyle.Opacity, commands)
 	}
 
 	// Apply tag-based styles
@@ -432,6 +501,8 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 					Bold:          currentStyle.Bold,
 					Italic:        currentStyle.Italic,
 					Monospace:     currentStyle.Monospace || fontStackHasMonospace(currentStyle.FontFamily),
+					Family:        currentStyle.FontFamily,
+					Fonts:         box.Fonts(),
 					Underline:     currentStyle.TextDecoration == "underline",
 					Strikethrough: currentStyle.TextDecoration == "line-through",
 				})
@@ -450,6 +521,8 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 					Bold:          currentStyle.Bold,
 					Italic:        currentStyle.Italic,
 					Monospace:     currentStyle.Monospace || fontStackHasMonospace(currentStyle.FontFamily),
+					Family:        currentStyle.FontFamily,
+					Fonts:         box.Fonts(),
 					Underline:     currentStyle.TextDecoration == "underline",
 					Strikethrough: currentStyle.TextDecoration == "line-through",
 				})
@@ -463,6 +536,8 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 				Bold:          currentStyle.Bold,
 				Italic:        currentStyle.Italic,
 				Monospace:     currentStyle.Monospace || fontStackHasMonospace(currentStyle.FontFamily),
+				Family:        currentStyle.FontFamily,
+				Fonts:         box.Fonts(),
 				Underline:     currentStyle.TextDecoration == "underline",
 				Strikethrough: currentStyle.TextDecoration == "line-through",
 			})
@@ -479,12 +554,57 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 		}
 	}
 
+	if box.Type == layout.SVGBox && box.Node != nil && !isHidden {
+		*commands = append(*commands, DrawRaster{
+			Rect:  box.Rect,
+			Image: graphics.RenderSVG(box.Node, int(math.Ceil(box.Rect.Width)), int(math.Ceil(box.Rect.Height))),
+		})
+	}
+
+	// A canvas no script has drawn into is transparent
+	if box.Type == layout.CanvasBox && box.Node != nil && !isHidden {
+		reusable = false
+		if ctx := graphics.LookupCanvas(box.Node); ctx != nil {
+			*commands = append(*commands, DrawRaster{
+				Rect:  box.Rect,
+				Image: ctx.Snapshot(),
+			})
+		}
+	}
+
+	// An iframe shows its document once the loader has laid it out
+	if box.Type == layout.IFrameBox && box.Node != nil && !isHidden {
+		reusable = false
+		if frame := layout.LookupFrame(box.Node); frame != nil {
+			if root := frame.Root(); root != nil {
+				var frameCommands []DisplayCommand
+				paintLayoutBoxWithInputs(root, &frameCommands, DefaultStyle(), state, nil)
+				scrollX, scrollY := frame.Scroll()
+				*commands = append(*commands, DrawFrame{
+					Rect:          box.Rect,
+					URL:           frame.URL(),
+					Commands:      frameCommands,
+					ContentWidth:  root.Rect.Width,
+					ContentHeight: root.Rect.Height,
+					ScrollX:       scrollX,
+					ScrollY:       scrollY,
+					Frame:         frame,
+				})
+			}
+		}
+	}
+
 	if box.Type == layout.HRBox && !isHidden {
 		*commands = append(*commands, DrawHR{
 			Rect: box.Rect,
 		})
 	}
 
+	switch box.Type {
+	case layout.InputBox, layout.TextareaBox, layout.SelectBox, layout.RadioBox, layout.CheckboxBox, layout.FileInputBox:
+		reusable = false
+	}
+
 	// Input with state - use DOM node for lookup (stable across reflow)
 	if box.Type == layout.InputBox && box.Node != nil && !isHidden {
 		value := state.InputValues[box.Node]
@@ -507,13 +627,10 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 			isFocused = false
 		}
 
-		// Validate based on input type
-		isValid := true
-		if inputType == "email" && value != "" {
-			isValid = isValidEmail(value)
-		}
-
-		if state.InvalidNodes != nil && state.InvalidNodes[box.Node] {
+		// Malformed emails and URLs show as soon as they are typed; other
+		// constraints only once a submission failed on them
+		isValid := !dom.CheckValidity(box.Node, value, false).TypeMismatch
+		if state.InvalidNodes[box.Node] {
 			isValid = false
 		}
 
@@ -554,6 +671,7 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 			IsFocused:   isFocused,
 			IsDisabled:  isDisabled,
 			IsReadonly:  isReadonly,
+			IsValid:     !state.InvalidNodes[box.Node],
 		})
 	}
 
@@ -681,14 +799,48 @@ func paintLayoutBoxWithInputs(box *layout.LayoutBox, commands *[]DisplayCommand,
 			if child.Type == layout.LegendBox {
 				continue
 			}
-			paintLayoutBoxWithInputs(child, commands, currentStyle, state)
+			if !paintLayoutBoxWithInputs(child, commands, currentStyle, state, pass) {
+				reusable = false
+			}
 		}
 	}
+
+	if len(box.Style.Transform) > 0 {
+		transformed := DrawTransformed{
+			Matrix:   transformMatrix(box),
+			Commands: append([]DisplayCommand(nil), (*commands)[start:]...),
+		}
+		*commands = append((*commands)[:start], transformed)
+	}
+
+	if cacheable && reusable {
+		pass.next.store(box, (*commands)[start:])
+	}
+	return reusable
+}
+
+// transformMatrix converts a box's transform list to a matrix about the
+// center of its border box, the default transform-origin
+func transformMatrix(box *layout.LayoutBox) graphics.Matrix {
+	cx := box.Rect.X + box.Rect.Width/2
+	cy := box.Rect.Y + box.Rect.Height/2
+	m := graphics.Identity().Translate(cx, cy)
+	for _, f := range box.Style.Transform {
+		switch f.Name {
+		case "translate":
+			m = m.Translate(f.Args[0], f.Args[1])
+		case "scale":
+			m = m.Scale(f.Args[0], f.Args[1])
+		case "rotate":
+			m = m.Rotate(f.Args[0] * math.Pi / 180)
+		}
+	}
+	return m.Translate(-cx, -cy)
 }
 
 func paintLayoutBox(box *layout.LayoutBox, commands *[]DisplayCommand, style TextStyle) {
 	// Delegate to the stateful version with empty state
-	paintLayoutBoxWithInputs(box, commands, style, InputState{})
+	paintLayoutBoxWithInputs(box, commands, style, InputState{}, nil)
 }
 
 // getListInfo returns (isListItem, isOrdered, itemIndex)
@@ -743,26 +895,6 @@ func getButtonTextFromBox(box *layout.LayoutBox) string {
 	return "Button"
 }
 
-// isValidEmail checks if the value is a valid email format
-func isValidEmail(value string) bool {
-	// Simple validation: must have @ with text before and after
-	atIndex := strings.Index(value, "@")
-	if atIndex < 1 {
-		return false
-	}
-	// Must have something after @
-	afterAt := value[atIndex+1:]
-	if len(afterAt) < 1 {
-		return false
-	}
-	// Must have a dot after @ (for domain)
-	dotIndex := strings.Index(afterAt, ".")
-	if dotIndex < 1 || dotIndex >= len(afterAt)-1 {
-		return false
-	}
-	return true
-}
-
 // fontStackHasMonospace checks if any font in the stack is a monospace font
 func fontStackHasMonospace(fonts []string) bool {
 	for _, font := range fonts {
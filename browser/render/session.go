@@ -0,0 +1,79 @@
+package render
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Session is the set of open tabs saved on exit and restored on start
+type Session struct {
+	Tabs   []TabState `json:"tabs"`
+	Active int        `json:"active"`
+}
+
+// TabState is the persisted part of a tab
+type TabState struct {
+	History History `json:"history"`
+	ScrollX float32 `json:"scroll_x"`
+	ScrollY float32 `json:"scroll_y"`
+}
+
+// DefaultSessionPath returns the per-user session file location
+func DefaultSessionPath() string {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "go-browser", "session.json")
+}
+
+// SaveSession writes s to path, creating parent directories as needed
+func SaveSession(path string, s Session) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadSession reads a session, dropping tabs without history and keeping
+// the active index on the same tab
+func LoadSession(path string) (Session, error) {
+	var s Session
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
+	}
+
+	var tabs []TabState
+	active := s.Active
+	for i, tab := range s.Tabs {
+		if len(tab.History.Entries) == 0 {
+			if i == s.Active {
+				active = len(tabs) - 1
+			}
+			continue
+		}
+		if i == s.Active {
+			active = len(tabs)
+		}
+		if tab.History.Pos < 0 || tab.History.Pos >= len(tab.History.Entries) {
+			tab.History.Pos = len(tab.History.Entries) - 1
+		}
+		tabs = append(tabs, tab)
+	}
+	s.Tabs = tabs
+	s.Active = active
+
+	if s.Active < 0 || s.Active >= len(s.Tabs) {
+		s.Active = 0
+	}
+	return s, nil
+}
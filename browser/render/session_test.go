@@ -0,0 +1,94 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "session.json")
+	saved := Session{
+		Tabs: []TabState{
+			{History: History{Entries: []string{"http://a.test/", "http://a.test/2"}, Pos: 0}, ScrollY: 120},
+			{History: History{Entries: []string{"http://b.test/"}, Pos: 0}},
+		},
+		Active: 1,
+	}
+
+	require.NoError(t, SaveSession(path, saved))
+	loaded, err := LoadSession(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, saved, loaded)
+}
+
+func TestBrowserSessionSkipsEmptyTabs(t *testing.T) {
+	b := &Browser{}
+	for _, url := range []string{"http://a.test/", "", "http://b.test/"} {
+		tab := b.newTab()
+		if url != "" {
+			tab.history.Push(url)
+		}
+		b.tabs = append(b.tabs, tab)
+	}
+	b.Tab = b.tabs[2]
+
+	s := b.Session()
+	require.Len(t, s.Tabs, 2)
+	assert.Equal(t, 1, s.Active)
+	assert.Equal(t, "http://b.test/", s.Tabs[s.Active].History.Entries[0])
+}
+
+func TestLoadSessionSanitizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected Session
+	}{
+		{
+			name:     "drops empty tabs",
+			json:     `{"tabs":[{"history":{"entries":[],"pos":-1}},{"history":{"entries":["x"],"pos":0}}],"active":0}`,
+			expected: Session{Tabs: []TabState{{History: History{Entries: []string{"x"}, Pos: 0}}}},
+		},
+		{
+			name:     "clamps position",
+			json:     `{"tabs":[{"history":{"entries":["x","y"],"pos":7}}]}`,
+			expected: Session{Tabs: []TabState{{History: History{Entries: []string{"x", "y"}, Pos: 1}}}},
+		},
+		{
+			name:     "keeps the active tab after an empty tab",
+			json:     `{"tabs":[{"history":{"entries":["x"],"pos":0}},{"history":{"entries":[],"pos":-1}},{"history":{"entries":["y"],"pos":0}}],"active":2}`,
+			expected: Session{Tabs: []TabState{{History: History{Entries: []string{"x"}, Pos: 0}}, {History: History{Entries: []string{"y"}, Pos: 0}}}, Active: 1},
+		},
+		{
+			name:     "an empty active tab falls back to the tab before it",
+			json:     `{"tabs":[{"history":{"entries":["x"],"pos":0}},{"history":{"entries":["y"],"pos":0}},{"history":{"entries":[],"pos":-1}}],"active":2}`,
+			expected: Session{Tabs: []TabState{{History: History{Entries: []string{"x"}, Pos: 0}}, {History: History{Entries: []string{"y"}, Pos: 0}}}, Active: 1},
+		},
+		{
+			name:     "clamps active tab",
+			json:     `{"tabs":[{"history":{"entries":["x"],"pos":0}}],"active":3}`,
+			expected: Session{Tabs: []TabState{{History: History{Entries: []string{"x"}, Pos: 0}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.json), 0644))
+
+			s, err := LoadSession(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestLoadSessionMissingFile(t *testing.T) {
+	_, err := LoadSession(filepath.Join(t.TempDir(), "none.json"))
+	assert.True(t, os.IsNotExist(err))
+}
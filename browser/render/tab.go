@@ -0,0 +1,442 @@
+package render
+
+import (
+	"browser/animation"
+	"browser/css"
+	"browser/dom"
+	"browser/fonts"
+	"browser/graphics"
+	"browser/js"
+	"browser/layout"
+	"maps"
+	"net/url"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxTabTitle is how many characters of a page title fit on a tab button
+const maxTabTitle = 20
+
+// Tab holds everything that belongs to one page: its document, layout,
+// history, form state and selection. The Browser owns the window chrome.
+type Tab struct {
+	browser *Browser
+	id      int
+	title   string
+	urlText string // what the URL bar shows while this tab is active
+	loading bool
+
+	layoutTree  *layout.LayoutBox
+	currentURL  *url.URL
+	externalCSS string // CSS from <link> tags, stored for reflow
+	history     *History
+
+	document *dom.Node
+	fonts    *fonts.Registry // web fonts of the document's @font-face rules
+
+	// Incremental relayout: the tree is updated in place while the CSS and
+	// viewport it was built for stay the same
+	reflowMu         sync.Mutex
+	layoutCSS        string
+	layoutStylesheet css.Stylesheet
+	layoutViewport   layout.Viewport
+	displayCache     *DisplayCache
+
+	// CSS transitions and animations, advanced by a frame clock while any
+	// are running. Both are guarded by reflowMu.
+	animations *animation.Engine
+	animating  bool
+
+	// Browsing contexts of the page's iframes, keyed by <iframe> element
+	// and guarded by reflowMu
+	frames map[*dom.Node]*frameContext
+
+	console tabConsole // what the page's scripts logged, for the devtools
+
+	// Input state - keyed by DOM node (stable across reflow). formMu guards
+	// the value maps and what a blocked submission marked, which scripts,
+	// reflow and the animation clock read off the UI goroutine.
+	formMu           sync.Mutex
+	focusedInputNode *dom.Node
+	keyboardFocus    *dom.Node // element Tab, Enter and Space act on
+	inputValues      map[*dom.Node]string
+	openSelectNode   *dom.Node // Which select dropdown is open
+	radioValues      map[string]*dom.Node
+	checkboxValue    map[*dom.Node]bool
+	fileInputValues  map[*dom.Node]string
+	invalidNodes     map[*dom.Node]bool
+	validationBubble *ValidationBubble // why the last submission was blocked
+
+	onJSClick        func(node *dom.Node)
+	onInvalid        func(node *dom.Node)
+	onBeforeNavigate func() bool // Returns true if navigation should proceed
+
+	selectionStart *SelectionPoint
+	selectionEnd   *SelectionPoint
+	selectedText   string
+
+	// Find-in-page state
+	findQuery         string
+	findCaseSensitive bool
+	findMatches       []FindMatch
+	findCurrent       int
+
+	scrollOffset  fyne.Position // saved scroll position while in the background
+	pendingScroll fyne.Position // applied to the next page shown (session restore)
+}
+
+func (b *Browser) newTab() *Tab {
+	b.nextTabID++
+	return &Tab{
+		browser:         b,
+		id:              b.nextTabID,
+		title:           "New Tab",
+		history:         NewHistory(),
+		inputValues:     make(map[*dom.Node]string),
+		radioValues:     make(map[string]*dom.Node),
+		checkboxValue:   make(map[*dom.Node]bool),
+		fileInputValues: make(map[*dom.Node]string),
+		invalidNodes:    make(map[*dom.Node]bool),
+		displayCache:    NewDisplayCache(),
+		animations:      animation.NewEngine(),
+		frames:          make(map[*dom.Node]*frameContext),
+	}
+}
+
+// checkboxChecked reports whether a checkbox is checked. A checkbox takes
+// its checked attribute the first time it is seen, after which only the
+// user changes it. The caller holds formMu.
+func (t *Tab) checkboxChecked(node *dom.Node) bool {
+	checked, seen := t.checkboxValue[node]
+	if !seen {
+		_, checked = node.Attributes["checked"]
+		t.checkboxValue[node] = checked
+	}
+	return checked
+}
+
+func (t *Tab) isActive() bool {
+	return t.browser.Tab == t
+}
+
+// baseURL returns scheme://host of the current page, used to resolve images
+func (t *Tab) baseURL() string {
+	if t.currentURL == nil {
+		return ""
+	}
+	return t.currentURL.Scheme + "://" + t.currentURL.Host
+}
+
+func (t *Tab) inputState() InputState {
+	t.formMu.Lock()
+	defer t.formMu.Unlock()
+	if t.document != nil {
+		for _, control := range dom.FormControls(t.document) {
+			if dom.InputType(control) == "checkbox" {
+				t.checkboxChecked(control)
+			}
+		}
+	}
+	return InputState{
+		InputValues:     maps.Clone(t.inputValues),
+		FocusedNode:     t.focusedInputNode,
+		OpenSelectNode:  t.openSelectNode,
+		RadioValues:     maps.Clone(t.radioValues),
+		CheckboxValues:  maps.Clone(t.checkboxValue),
+		FileInputValues: maps.Clone(t.fileInputValues),
+		InvalidNodes:    maps.Clone(t.invalidNodes),
+		Validation:      t.validationBubble,
+		KeyboardFocus:   t.keyboardFocus,
+		SelectionStart:  t.selectionStart,
+		SelectionEnd:    t.selectionEnd,
+		FindMatches:     t.findMatches,
+		FindCurrent:     t.findCurrent,
+	}
+}
+
+// display shows rendered page objects if the tab is active; background tabs
+// only remember the offset. Must run on the main thread.
+func (t *Tab) display(objects []fyne.CanvasObject, offset fyne.Position) {
+	b := t.browser
+	if !t.isActive() {
+		t.scrollOffset = offset
+		return
+	}
+
+	scroll := b.createContentScroll(objects)
+	scroll.Offset = offset
+	b.content.Objects = []fyne.CanvasObject{scroll}
+	b.content.Refresh()
+	b.shownTab = t
+}
+
+// scrollPosition returns the live offset when the tab's page is on screen,
+// otherwise the one saved when it was last hidden
+func (t *Tab) scrollPosition() fyne.Position {
+	b := t.browser
+	if b.shownTab == t && len(b.content.Objects) > 0 {
+		if scroll, ok := b.content.Objects[0].(*container.Scroll); ok {
+			return scroll.Offset
+		}
+	}
+	return t.scrollOffset
+}
+
+// Tabs returns the open tabs in strip order
+func (b *Browser) Tabs() []*Tab {
+	return b.tabs
+}
+
+// ActiveTab returns the tab currently shown in the window
+func (b *Browser) ActiveTab() *Tab {
+	return b.Tab
+}
+
+// NewTab opens an empty tab and focuses the URL bar
+func (b *Browser) NewTab() *Tab {
+	t := b.newTab()
+	b.tabs = append(b.tabs, t)
+	b.SwitchTab(len(b.tabs) - 1)
+	b.Window.Canvas().Focus(b.urlEntry)
+	return t
+}
+
+// OpenTab opens rawURL in a new foreground tab
+func (b *Browser) OpenTab(rawURL string) *Tab {
+	t := b.newTab()
+	b.tabs = append(b.tabs, t)
+	b.SwitchTab(len(b.tabs) - 1)
+	t.UpdateURLBar(rawURL)
+	t.navigate(NavigationRequest{URL: rawURL, Method: "GET"})
+	return t
+}
+
+// CloseTab removes a tab. Closing the last tab leaves a fresh empty one.
+func (b *Browser) CloseTab(t *Tab) {
+	index := b.tabIndex(t)
+	if index < 0 {
+		return
+	}
+	b.tabs = append(b.tabs[:index], b.tabs[index+1:]...)
+	t.reflowMu.Lock()
+	t.resetAnimations()
+	t.releaseFrames()
+	t.reflowMu.Unlock()
+	if t.document != nil {
+		graphics.ReleaseCanvases(t.document)
+		// The page's scripts and those of its frames stop receiving events
+		js.ReleaseRuntimes(t.document)
+	}
+	if len(b.tabs) == 0 {
+		b.tabs = []*Tab{b.newTab()}
+	}
+
+	if t.isActive() {
+		if index >= len(b.tabs) {
+			index = len(b.tabs) - 1
+		}
+		b.Tab = nil
+		b.SwitchTab(index)
+		return
+	}
+	b.refreshTabBar()
+}
+
+// SwitchTab makes the tab at index active and shows its page
+func (b *Browser) SwitchTab(index int) {
+	if index < 0 || index >= len(b.tabs) {
+		return
+	}
+	t := b.tabs[index]
+	if t == b.Tab {
+		return
+	}
+
+	if b.Tab != nil {
+		b.Tab.scrollOffset = b.Tab.scrollPosition()
+	}
+	b.Tab = t
+
+	b.urlEntry.SetText(t.urlText)
+	b.findEntry.SetText(t.findQuery)
+	b.findCase.SetChecked(t.findCaseSensitive)
+	b.updateFindStatus()
+	if t.layoutTree != nil {
+		b.Window.SetTitle(t.title)
+	} else {
+		b.Window.SetTitle("Go Browser")
+	}
+	b.refreshTabBar()
+	b.refreshNavButtons()
+	if b.devtools != nil {
+		b.devtools.pageChanged()
+	}
+
+	switch {
+	case t.loading:
+		t.ShowLoading()
+	case t.document != nil:
+		go t.Reflow(b.Width)
+	default:
+		b.showBlank()
+	}
+}
+
+func (b *Browser) tabIndex(t *Tab) int {
+	for i, tab := range b.tabs {
+		if tab == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// showBlank clears the content area for a tab that has no page yet
+func (b *Browser) showBlank() {
+	bg := canvas.NewRectangle(ColorWhite)
+	b.content.Objects = []fyne.CanvasObject{bg}
+	b.content.Refresh()
+	b.shownTab = nil
+}
+
+// refreshTabBar rebuilds the tab strip: one button per tab plus a "+" button
+func (b *Browser) refreshTabBar() {
+	fyne.Do(func() {
+		var objects []fyne.CanvasObject
+		for i, t := range b.tabs {
+			index := i
+			tab := t
+
+			label := []rune(tab.title)
+			if len(label) > maxTabTitle {
+				label = append(label[:maxTabTitle-1], '…')
+			}
+			text := string(label)
+			if tab.loading {
+				text = "⟳ " + text
+			}
+
+			selectBtn := widget.NewButton(text, func() {
+				b.SwitchTab(index)
+			})
+			if tab.isActive() {
+				selectBtn.Importance = widget.HighImportance
+			}
+			closeBtn := widget.NewButton("×", func() {
+				b.CloseTab(tab)
+			})
+			closeBtn.Importance = widget.LowImportance
+
+			objects = append(objects, container.NewHBox(selectBtn, closeBtn))
+		}
+		objects = append(objects, widget.NewButton("+", func() {
+			b.NewTab()
+		}))
+
+		b.tabBar.Objects = objects
+		b.tabBar.Refresh()
+	})
+}
+
+// refreshNavButtons enables Back/Forward according to the active tab's history
+func (b *Browser) refreshNavButtons() {
+	fyne.Do(func() {
+		if b.backBtn == nil || b.Tab == nil {
+			return
+		}
+		if b.Tab.history.CanGoBack() {
+			b.backBtn.Enable()
+		} else {
+			b.backBtn.Disable()
+		}
+		if b.Tab.history.CanGoForward() {
+			b.fwdBtn.Enable()
+		} else {
+			b.fwdBtn.Disable()
+		}
+	})
+}
+
+// addTabShortcuts registers Ctrl+T (new tab), Ctrl+W (close tab) and
+// Ctrl+Tab / Ctrl+Shift+Tab (next / previous tab)
+func (b *Browser) addTabShortcuts() {
+	c := b.Window.Canvas()
+
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: fyne.KeyModifierShortcutDefault}, func(_ fyne.Shortcut) {
+		b.NewTab()
+	})
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyW, Modifier: fyne.KeyModifierShortcutDefault}, func(_ fyne.Shortcut) {
+		b.CloseTab(b.Tab)
+	})
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyTab, Modifier: fyne.KeyModifierControl}, func(_ fyne.Shortcut) {
+		b.SwitchTab((b.tabIndex(b.Tab) + 1) % len(b.tabs))
+	})
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyTab, Modifier: fyne.KeyModifierControl | fyne.KeyModifierShift}, func(_ fyne.Shortcut) {
+		b.SwitchTab((b.tabIndex(b.Tab) + len(b.tabs) - 1) % len(b.tabs))
+	})
+}
+
+// Session snapshots the open tabs, their history and scroll positions.
+// Must run on the main thread.
+func (b *Browser) Session() Session {
+	var s Session
+	for _, t := range b.tabs {
+		if len(t.history.Entries) == 0 {
+			// An empty active tab restores as the saved tab before it
+			if t == b.Tab {
+				s.Active = len(s.Tabs) - 1
+			}
+			continue
+		}
+		if t == b.Tab {
+			s.Active = len(s.Tabs)
+		}
+		offset := t.scrollPosition()
+		s.Tabs = append(s.Tabs, TabState{
+			History: History{
+				Entries: append([]string(nil), t.history.Entries...),
+				Pos:     t.history.Pos,
+			},
+			ScrollX: offset.X,
+			ScrollY: offset.Y,
+		})
+	}
+	if s.Active < 0 || s.Active >= len(s.Tabs) {
+		s.Active = 0
+	}
+	return s
+}
+
+// RestoreSession replaces the open tabs with the saved ones and reloads
+// each tab's current history entry
+func (b *Browser) RestoreSession(s Session) {
+	if len(s.Tabs) == 0 {
+		return
+	}
+
+	var tabs []*Tab
+	for _, state := range s.Tabs {
+		t := b.newTab()
+		t.history.Entries = append([]string(nil), state.History.Entries...)
+		t.history.Pos = state.History.Pos
+		t.urlText = t.history.Current()
+		t.pendingScroll = fyne.NewPos(state.ScrollX, state.ScrollY)
+		tabs = append(tabs, t)
+	}
+
+	b.tabs = tabs
+	b.Tab = nil
+	b.SwitchTab(s.Active)
+
+	if b.OnNavigate == nil {
+		return
+	}
+	for _, t := range tabs {
+		b.OnNavigate(NavigationRequest{URL: t.history.Current(), Method: "GET", Tab: t, SkipHistory: true})
+	}
+}
@@ -0,0 +1,29 @@
+package render
+
+import (
+	"browser/dom"
+	"browser/js"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseTabReleasesRuntimes(t *testing.T) {
+	test.NewTempApp(t)
+	b := &Browser{tabBar: container.NewHBox()}
+	kept, closed := b.newTab(), b.newTab()
+	b.tabs = []*Tab{kept, closed}
+	b.Tab = kept
+
+	closed.document = dom.Parse(strings.NewReader(`<p>closing</p>`))
+	js.NewJSRuntime(closed.document, nil)
+	require.NotNil(t, js.RuntimeFor(closed.document))
+
+	b.CloseTab(closed)
+	assert.Equal(t, []*Tab{kept}, b.tabs)
+	assert.Nil(t, js.RuntimeFor(closed.document))
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	"browser/graphics"
+	"image"
+	"image/draw"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/software"
+	"golang.org/x/image/math/f64"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// transformedObjects renders the commands of a transformed box. Moves and
+// scales are applied to the objects themselves, so text stays sharp and
+// images load as usual; rotated or flipped content is drawn into a bitmap
+// and resampled.
+func transformedObjects(c DrawTransformed, baseURL string, useCache bool, onImageLoad func()) []fyne.CanvasObject {
+	objects := RenderToCanvas(c.Commands, baseURL, useCache, onImageLoad)
+	m := c.Matrix
+	if m.B == 0 && m.C == 0 && m.A > 0 && m.D > 0 {
+		for _, obj := range objects {
+			scaleObject(obj, m)
+		}
+		return objects
+	}
+	if img := rasterizeObjects(objects, m); img != nil {
+		return []fyne.CanvasObject{img}
+	}
+	return nil
+}
+
+// scaleObject maps an object through a matrix without rotation
+func scaleObject(obj fyne.CanvasObject, m graphics.Matrix) {
+	pos := obj.Position()
+	x, y := m.Apply(float64(pos.X), float64(pos.Y))
+	obj.Move(fyne.NewPos(float32(x), float32(y)))
+	size := obj.Size()
+	obj.Resize(fyne.NewSize(size.Width*float32(m.A), size.Height*float32(m.D)))
+	if text, ok := obj.(*canvas.Text); ok {
+		text.TextSize *= float32(m.ScaleFactor())
+	}
+}
+
+// objectSize is the area an object covers; text is not resized when it is
+// created, so it covers its minimum size
+func objectSize(obj fyne.CanvasObject) fyne.Size {
+	if _, ok := obj.(*canvas.Text); ok {
+		return obj.MinSize()
+	}
+	return obj.Size()
+}
+
+// rasterizeObjects draws objects with the software painter, then resamples
+// the bitmap through m into an image placed over the transformed bounds
+func rasterizeObjects(objects []fyne.CanvasObject, m graphics.Matrix) *canvas.Image {
+	if len(objects) == 0 {
+		return nil
+	}
+	minX, minY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+	maxX, maxY := float32(-math.MaxFloat32), float32(-math.MaxFloat32)
+	for _, obj := range objects {
+		pos, size := obj.Position(), objectSize(obj)
+		minX, minY = min(minX, pos.X), min(minY, pos.Y)
+		maxX, maxY = max(maxX, pos.X+size.Width), max(maxY, pos.Y+size.Height)
+	}
+	if maxX <= minX || maxY <= minY {
+		return nil
+	}
+
+	// Draw the untransformed content at the origin
+	for _, obj := range objects {
+		obj.Resize(objectSize(obj))
+		obj.Move(obj.Position().SubtractXY(minX, minY))
+	}
+	c := software.NewTransparentCanvas()
+	c.SetPadded(false)
+	c.SetScale(shapedTextScale)
+	c.SetContent(container.NewWithoutLayout(objects...))
+	c.Resize(fyne.NewSize(maxX-minX, maxY-minY))
+	src := c.Capture()
+
+	// Bounds of the content's corners once transformed
+	dx0, dy0 := math.Inf(1), math.Inf(1)
+	dx1, dy1 := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float32{{minX, minY}, {maxX, minY}, {minX, maxY}, {maxX, maxY}} {
+		x, y := m.Apply(float64(corner[0]), float64(corner[1]))
+		dx0, dy0 = math.Min(dx0, x), math.Min(dy0, y)
+		dx1, dy1 = math.Max(dx1, x), math.Max(dy1, y)
+	}
+	w := int(math.Ceil((dx1 - dx0) * shapedTextScale))
+	h := int(math.Ceil((dy1 - dy0) * shapedTextScale))
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+
+	// Source pixels → page → transformed page → destination pixels
+	s := float64(shapedTextScale)
+	toDst := graphics.Identity().
+		Scale(s, s).
+		Translate(-dx0, -dy0).
+		Multiply(m).
+		Translate(float64(minX), float64(minY)).
+		Scale(1/s, 1/s)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	aff := f64.Aff3{toDst.A, toDst.C, toDst.E, toDst.B, toDst.D, toDst.F}
+	xdraw.BiLinear.Transform(dst, aff, src, src.Bounds(), draw.Over, nil)
+
+	img := canvas.NewImageFromImage(dst)
+	img.FillMode = canvas.ImageFillStretch
+	img.Resize(fyne.NewSize(float32(w)/shapedTextScale, float32(h)/shapedTextScale))
+	img.Move(fyne.NewPos(float32(dx0), float32(dy0)))
+	return img
+}
@@ -0,0 +1,98 @@
+package render
+
+import (
+	"browser/css"
+	"browser/dom"
+	"browser/graphics"
+	"browser/layout"
+	"image/color"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findTransformed(commands []DisplayCommand) (DrawTransformed, bool) {
+	for _, cmd := range commands {
+		if c, ok := cmd.(DrawTransformed); ok {
+			return c, true
+		}
+	}
+	return DrawTransformed{}, false
+}
+
+func TestPaintWrapsTransformedBoxes(t *testing.T) {
+	doc := dom.Parse(strings.NewReader(`<div id="a" style="width: 100px; height: 40px; background-color: red; transform: translate(10px, 5px) scale(2)">x</div>`))
+	root := layout.BuildLayoutTree(doc, css.Stylesheet{}, layout.Viewport{Width: 800})
+	layout.ComputeLayout(root, 800)
+
+	transformed, ok := findTransformed(BuildDisplayList(root))
+	require.True(t, ok)
+	assert.NotEmpty(t, transformed.Commands)
+
+	// Scaling is about the center of the box, after the translation
+	var box *layout.LayoutBox
+	var find func(*layout.LayoutBox)
+	find = func(b *layout.LayoutBox) {
+		if b.Node != nil && b.Node.Attributes["id"] == "a" {
+			box = b
+		}
+		for _, child := range b.Children {
+			find(child)
+		}
+	}
+	find(root)
+	require.NotNil(t, box)
+	cx, cy := box.Rect.X+box.Rect.Width/2, box.Rect.Y+box.Rect.Height/2
+	x, y := transformed.Matrix.Apply(cx, cy)
+	assert.InDelta(t, cx+10, x, 1e-9)
+	assert.InDelta(t, cy+5, y, 1e-9)
+	x, _ = transformed.Matrix.Apply(box.Rect.X, cy)
+	assert.InDelta(t, cx+10-box.Rect.Width, x, 1e-9)
+}
+
+func TestTransformedObjectsScale(t *testing.T) {
+	m := graphics.Identity().Translate(5, 0).Scale(2, 2)
+	objects := transformedObjects(DrawTransformed{
+		Matrix: m,
+		Commands: []DisplayCommand{
+			DrawRect{Rect: layout.Rect{X: 10, Y: 10, Width: 20, Height: 5}, Color: color.Black},
+			DrawText{Text: "hi", X: 10, Y: 20, Size: 10, Color: color.Black},
+		},
+	}, "", true, nil)
+	require.Len(t, objects, 2)
+
+	assert.Equal(t, fyne.NewPos(25, 20), objects[0].Position())
+	assert.Equal(t, fyne.NewSize(40, 10), objects[0].Size())
+	text, ok := objects[1].(*canvas.Text)
+	require.True(t, ok)
+	assert.Equal(t, float32(20), text.TextSize)
+}
+
+func TestTransformedObjectsRotate(t *testing.T) {
+	test.NewTempApp(t)
+
+	// A 40x10 bar turned a quarter about its center stands 10x40
+	m := graphics.Identity().Translate(30, 15).Rotate(1.5707963267948966).Translate(-30, -15)
+	objects := transformedObjects(DrawTransformed{
+		Matrix:   m,
+		Commands: []DisplayCommand{DrawRect{Rect: layout.Rect{X: 10, Y: 10, Width: 40, Height: 10}, Color: color.Black}},
+	}, "", true, nil)
+	require.Len(t, objects, 1)
+	img, ok := objects[0].(*canvas.Image)
+	require.True(t, ok)
+
+	assert.InDelta(t, 25, img.Position().X, 0.5)
+	assert.InDelta(t, -5, img.Position().Y, 0.5)
+	assert.InDelta(t, 10, img.Size().Width, 0.5)
+	assert.InDelta(t, 40, img.Size().Height, 0.5)
+
+	// The bar fills the middle of the bitmap
+	bounds := img.Image.Bounds()
+	_, _, _, a := img.Image.At(bounds.Dx()/2, bounds.Dy()/2).RGBA()
+	assert.Greater(t, a, uint32(0xf000))
+}
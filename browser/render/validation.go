@@ -0,0 +1,243 @@
+package render
+
+import (
+	"browser/dom"
+	"strconv"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+// ValidationBubble is the message shown under the first field that blocked
+// a form submission
+type ValidationBubble struct {
+	Node *dom.Node
+	Text string
+}
+
+// DrawValidationMessage paints a validation bubble whose arrow points up at
+// (X, Y), the bottom left corner of the field
+type DrawValidationMessage struct {
+	X, Y float64
+	Text string
+}
+
+// SetInvalidHandler sets the callback that fires the invalid event at each
+// field that blocked a form submission
+func (t *Tab) SetInvalidHandler(handler func(node *dom.Node)) {
+	t.onInvalid = handler
+}
+
+// FormState returns the current value of a form control as the user left
+// it, and for checkboxes and radio buttons whether they are checked. For a
+// radio button checked tells whether any button of its group is, which is
+// what the required constraint looks at.
+func (t *Tab) FormState(node *dom.Node) (value string, checked bool) {
+	t.formMu.Lock()
+	defer t.formMu.Unlock()
+	switch node.TagName {
+	case "select":
+		return t.getSelectedValue(node), false
+	case "textarea":
+		return t.inputValues[node], false
+	}
+
+	switch dom.InputType(node) {
+	case "checkbox":
+		return node.Attributes["value"], t.checkboxChecked(node)
+	case "radio":
+		name := node.Attributes["name"]
+		if t.radioValues[name] != nil {
+			return node.Attributes["value"], true
+		}
+		return node.Attributes["value"], radioGroupChecked(node, name)
+	case "file":
+		return t.fileInputValues[node], false
+	}
+	if value, ok := t.inputValues[node]; ok {
+		return value, false
+	}
+	return node.Attributes["value"], false
+}
+
+// radioGroupChecked reports whether a button of a radio group is checked in
+// the markup
+func radioGroupChecked(radio *dom.Node, name string) bool {
+	if name == "" {
+		_, checked := radio.Attributes["checked"]
+		return checked
+	}
+	scope := findParentForm(radio)
+	if scope == nil {
+		scope = radio.OwnerDocument()
+	}
+	for _, control := range dom.FormControls(scope) {
+		if dom.InputType(control) == "radio" && control.Attributes["name"] == name {
+			if _, checked := control.Attributes["checked"]; checked {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validity checks a control's current value against its constraints
+func (t *Tab) validity(node *dom.Node) dom.Validity {
+	value, checked := t.FormState(node)
+	return dom.CheckValidity(node, value, checked)
+}
+
+// validateForm returns the controls of a form whose values fail their
+// constraints, in document order
+func (t *Tab) validateForm(formNode *dom.Node) []*dom.Node {
+	var invalid []*dom.Node
+	for _, node := range dom.FormControls(formNode) {
+		if !t.validity(node).Valid() {
+			invalid = append(invalid, node)
+		}
+	}
+	return invalid
+}
+
+// reportInvalid marks the fields that blocked a submission, fires their
+// invalid events and explains what is wrong with the first one
+func (t *Tab) reportInvalid(invalid []*dom.Node) {
+	marked := make(map[*dom.Node]bool)
+	for _, node := range invalid {
+		marked[node] = true
+	}
+	if handler := t.onInvalid; handler != nil {
+		go func() {
+			for _, node := range invalid {
+				handler(node)
+			}
+		}()
+	}
+
+	first := invalid[0]
+	value, _ := t.FormState(first)
+	bubble := &ValidationBubble{
+		Node: first,
+		Text: dom.ValidationMessage(first, value, t.validity(first)),
+	}
+
+	// The animation clock paints the marks and the bubble
+	t.formMu.Lock()
+	t.invalidNodes = marked
+	t.validationBubble = bubble
+	if isTextControl(first) {
+		t.focusedInputNode = first
+	}
+	t.openSelectNode = nil
+	t.formMu.Unlock()
+	t.repaint()
+}
+
+// formChanged is called when the user edits a control: the bubble goes
+// away, and a field marked invalid is unmarked once its value is fixed
+func (t *Tab) formChanged(node *dom.Node) {
+	if t.validationBubble != nil && t.validationBubble.Node == node {
+		t.formMu.Lock()
+		t.validationBubble = nil
+		t.formMu.Unlock()
+	}
+	if node.TagName == "input" && dom.InputType(node) == "radio" {
+		// Checking one button fixes the whole group
+		var fixed []*dom.Node
+		for other := range t.invalidNodes {
+			if dom.InputType(other) == "radio" && other.Attributes["name"] == node.Attributes["name"] && t.validity(other).Valid() {
+				fixed = append(fixed, other)
+			}
+		}
+		t.formMu.Lock()
+		for _, other := range fixed {
+			delete(t.invalidNodes, other)
+		}
+		t.formMu.Unlock()
+		return
+	}
+	if t.invalidNodes[node] && t.validity(node).Valid() {
+		t.formMu.Lock()
+		delete(t.invalidNodes, node)
+		t.formMu.Unlock()
+	}
+}
+
+// clearValidation forgets the marks and message of a previous submission
+func (t *Tab) clearValidation() {
+	t.formMu.Lock()
+	t.invalidNodes = make(map[*dom.Node]bool)
+	t.validationBubble = nil
+	t.formMu.Unlock()
+}
+
+// isTextControl reports whether node takes typed input
+func isTextControl(node *dom.Node) bool {
+	if node.TagName == "textarea" {
+		return true
+	}
+	if node.TagName != "input" {
+		return false
+	}
+	switch dom.InputType(node) {
+	case "checkbox", "radio", "file", "color", "range":
+		return false
+	}
+	return true
+}
+
+// atMaxLength reports whether a text control holds as many characters as
+// its maxlength allows, so typing more is ignored
+func atMaxLength(node *dom.Node, value string) bool {
+	max, err := strconv.Atoi(node.Attributes["maxlength"])
+	return err == nil && max >= 0 && utf8.RuneCountInString(value) >= max
+}
+
+// validationMessageObjects draws a validation bubble: a warning icon and
+// the message in a bordered box with an arrow pointing up at the field
+func validationMessageObjects(c DrawValidationMessage) []fyne.CanvasObject {
+	const (
+		textSize = 13
+		padding  = 8
+		iconSize = 18
+		arrow    = 6
+	)
+	textWidth := fyne.MeasureText(c.Text, textSize, fyne.TextStyle{}).Width
+	width := float32(padding*3+iconSize) + textWidth
+	height := float32(iconSize + padding*2)
+	x, y := float32(c.X), float32(c.Y+arrow)
+
+	box := canvas.NewRectangle(ColorValidationBg)
+	box.StrokeColor = ColorValidationBorder
+	box.StrokeWidth = 1
+	box.CornerRadius = 4
+	box.Resize(fyne.NewSize(width, height))
+	box.Move(fyne.NewPos(x, y))
+
+	// Rectangles cannot be rotated, so the arrow is stacked one pixel bars
+	objects := []fyne.CanvasObject{box}
+	for i := 0; i < arrow; i++ {
+		bar := canvas.NewRectangle(ColorValidationBorder)
+		w := float32(2*i + 1)
+		bar.Resize(fyne.NewSize(w, 1))
+		bar.Move(fyne.NewPos(x+padding+iconSize/2-w/2, float32(c.Y)+float32(i)+1))
+		objects = append(objects, bar)
+	}
+
+	icon := canvas.NewRectangle(ColorValidationIcon)
+	icon.CornerRadius = 3
+	icon.Resize(fyne.NewSize(iconSize, iconSize))
+	icon.Move(fyne.NewPos(x+padding, y+padding))
+	mark := canvas.NewText("!", ColorValidationBg)
+	mark.TextSize = textSize
+	mark.TextStyle = fyne.TextStyle{Bold: true}
+	markWidth := fyne.MeasureText("!", textSize, mark.TextStyle).Width
+	mark.Move(fyne.NewPos(x+padding+(iconSize-markWidth)/2, y+padding+1))
+
+	text := canvas.NewText(c.Text, ColorText)
+	text.TextSize = textSize
+	text.Move(fyne.NewPos(x+padding*2+iconSize, y+padding+1))
+
+	return append(objects, icon, mark, text)
+}
@@ -0,0 +1,196 @@
+package render
+
+import (
+	"browser/dom"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitFormValidation(t *testing.T) {
+	b := &Browser{}
+	b.Tab = b.newTab()
+	b.document = dom.Parse(strings.NewReader(`<form action="/signup">
+		<input name="email" type="email" required>
+		<input name="age" type="number" min="18">
+		<input type="radio" name="plan" value="free">
+		<input type="radio" name="plan" value="pro" required>
+		<button id="go">Go</button>
+		<button id="draft" formnovalidate>Save</button>
+	</form>`))
+	form := dom.FindElementsByTagName(b.document, "form")
+	controls := dom.FormControls(form)
+	email, age, free := controls[0], controls[1], controls[2]
+	var buttons []*dom.Node
+	for _, child := range form.Children {
+		if child.TagName == "button" {
+			buttons = append(buttons, child)
+		}
+	}
+	submit, draft := buttons[0], buttons[1]
+
+	navigated := make(chan string, 1)
+	b.OnNavigate = func(req NavigationRequest) { navigated <- req.URL }
+	invalidEvents := make(chan *dom.Node, 4)
+	b.SetInvalidHandler(func(node *dom.Node) { invalidEvents <- node })
+
+	b.inputValues[email] = "someone"
+	b.inputValues[age] = "12"
+	b.submitForm(form, submit)
+	assert.Equal(t, map[*dom.Node]bool{email: true, age: true, controls[3]: true}, b.invalidNodes)
+	require.NotNil(t, b.validationBubble)
+	assert.Equal(t, email, b.validationBubble.Node)
+	assert.Equal(t, "Please enter an email address.", b.validationBubble.Text)
+	assert.Equal(t, email, b.focusedInputNode)
+	for _, want := range []*dom.Node{email, age, controls[3]} {
+		assert.Equal(t, want, <-invalidEvents)
+	}
+
+	// Fixing a field clears its mark, and the bubble once it is edited
+	b.inputValues[email] = "someone@example.com"
+	b.formChanged(email)
+	assert.Nil(t, b.validationBubble)
+	assert.False(t, b.invalidNodes[email])
+	b.radioValues["plan"] = free
+	b.formChanged(free)
+	assert.False(t, b.invalidNodes[controls[3]])
+
+	// formnovalidate submits as is
+	b.submitForm(form, draft)
+	assert.Empty(t, b.invalidNodes)
+	select {
+	case url := <-navigated:
+		assert.Contains(t, url, "age=12")
+	case <-time.After(time.Second):
+		t.Fatal("form was not submitted")
+	}
+}
+
+func TestFormState(t *testing.T) {
+	b := &Browser{}
+	tab := b.newTab()
+	doc := dom.Parse(strings.NewReader(`<form>
+		<input value="preset"><input type="checkbox" checked>
+		<input type="radio" name="r" checked><input type="radio" name="r">
+		<select><option>one</option><option selected value="2">two</option></select>
+	</form>`))
+	controls := dom.FormControls(dom.FindElementsByTagName(doc, "form"))
+
+	value, _ := tab.FormState(controls[0])
+	assert.Equal(t, "preset", value)
+	tab.inputValues[controls[0]] = "typed"
+	value, _ = tab.FormState(controls[0])
+	assert.Equal(t, "typed", value)
+
+	_, checked := tab.FormState(controls[1])
+	assert.True(t, checked)
+	_, checked = tab.FormState(controls[3])
+	assert.True(t, checked, "another button of the group is checked")
+
+	value, _ = tab.FormState(controls[4])
+	assert.Equal(t, "2", value)
+}
+
+func TestAtMaxLength(t *testing.T) {
+	node := &dom.Node{Type: dom.Element, TagName: "input", Attributes: map[string]string{"maxlength": "3"}}
+	assert.False(t, atMaxLength(node, "ab"))
+	assert.True(t, atMaxLength(node, "héé"))
+	assert.False(t, atMaxLength(&dom.Node{Type: dom.Element, TagName: "input", Attributes: map[string]string{}}, "long value"))
+}
+
+func TestCheckboxState(t *testing.T) {
+	b := focusBrowser(t, `<p><input type="checkbox" checked> <input type="checkbox"></p>`)
+	controls := dom.FormControls(b.document)
+	checkedBox, plainBox := controls[0], controls[1]
+
+	painted := func() []bool {
+		var got []bool
+		for _, cmd := range BuildDisplayListWithInputs(b.layoutTree, b.inputState()) {
+			if box, ok := cmd.(DrawCheckbox); ok {
+				got = append(got, box.IsChecked)
+			}
+		}
+		return got
+	}
+	assert.Equal(t, []bool{true, false}, painted())
+
+	// Unchecking a box with the checked attribute sticks
+	b.focusNext(false)
+	require.Equal(t, checkedBox, b.keyboardFocus)
+	require.True(t, b.activateFocus())
+	_, checked := b.FormState(checkedBox)
+	assert.False(t, checked)
+	assert.False(t, b.isChecked(checkedBox))
+	assert.Equal(t, []bool{false, false}, painted())
+
+	_, checked = b.FormState(plainBox)
+	assert.False(t, checked)
+}
+
+func TestFormStateFromScripts(t *testing.T) {
+	b := focusBrowser(t, `<p><input type="checkbox"> <input id="name"></p>`)
+	controls := dom.FormControls(b.document)
+	b.focusNext(false)
+
+	// Scripts read the form from their own goroutine while the user edits it
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				b.FormState(controls[0])
+				b.FormState(controls[1])
+			}
+		}
+	}()
+	for range 100 {
+		b.activateFocus()
+		b.focusedInputNode = controls[1]
+		b.handleTypedRune('x')
+		b.focusedInputNode = nil
+	}
+	close(stop)
+	<-done
+
+	value, _ := b.FormState(controls[1])
+	assert.Len(t, value, 100)
+}
+
+func TestFormEditsWhileAnimating(t *testing.T) {
+	b := focusBrowser(t, `<form><input id="name" required></form>`)
+	field := dom.FormControls(b.document)[0]
+
+	// The animation clock builds the display list, marks included, from
+	// its own goroutine; the test driver would show it there too
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				b.reflowMu.Lock()
+				BuildDisplayListCached(b.layoutTree, b.inputState(), b.displayCache)
+				b.reflowMu.Unlock()
+			}
+		}
+	}()
+	for range 50 {
+		b.reportInvalid([]*dom.Node{field})
+		b.handleTypedRune('x')
+		b.clearValidation()
+	}
+	close(stop)
+	<-done
+
+	value, _ := b.FormState(field)
+	assert.Len(t, value, 50)
+	assert.Empty(t, b.invalidNodes)
+}
@@ -3,6 +3,8 @@ package render
 import (
 	"browser/css"
 	"browser/dom"
+	"browser/fonts"
+	"browser/graphics"
 	"browser/layout"
 	"bytes"
 	"fmt"
@@ -29,40 +31,46 @@ type NavigationRequest struct {
 	Data        url.Values
 	Body        []byte
 	ContentType string
+
+	// Tab is the tab that should display the result
+	Tab *Tab
+	// SkipHistory is set for back/forward and reload, which move within
+	// the existing history instead of pushing a new entry
+	SkipHistory bool
 }
 
 type Browser struct {
-	App         fyne.App
-	Window      fyne.Window
-	Width       float32
-	Height      float32
-	layoutTree  *layout.LayoutBox
-	currentURL  *url.URL
-	externalCSS string // CSS from <link> tags, stored for reflow
-	OnNavigate  func(req NavigationRequest)
-
-	urlEntry   *widget.Entry
-	content    *fyne.Container
-	history    []string
-	historyPos int
-
-	document *dom.Node
-
-	// Input state - keyed by DOM node (stable across reflow)
-	focusedInputNode *dom.Node
-	inputValues      map[*dom.Node]string
-	openSelectNode   *dom.Node // Which select dropdown is open
-	radioValues      map[string]*dom.Node
-	checkboxValue    map[*dom.Node]bool
-	fileInputValues  map[*dom.Node]string
-	invalidNodes     map[*dom.Node]bool
-
-	onJSClick        func(node *dom.Node)
-	onBeforeNavigate func() bool // Returns true if navigation should proceed
-
-	selectionStart *SelectionPoint
-	selectionEnd   *SelectionPoint
-	selectedText   string
+	App        fyne.App
+	Window     fyne.Window
+	Width      float32
+	Height     float32
+	OnNavigate func(req NavigationRequest)
+	// OnLoadFrame fetches the document of an <iframe> and hands it to
+	// req.Tab.SetFrameDocument
+	OnLoadFrame func(req FrameRequest)
+
+	// SessionPath is where open tabs are saved when the window closes; empty disables saving
+	SessionPath string
+
+	urlEntry  *widget.Entry
+	content   *fyne.Container
+	keys      *pageKeys // has focus while the page takes keyboard input
+	tabBar    *fyne.Container
+	backBtn   *widget.Button
+	fwdBtn    *widget.Button
+	tabs      []*Tab
+	shownTab  *Tab // tab whose page is currently in content
+	nextTabID int
+
+	findBar    *fyne.Container
+	findEntry  *findBarEntry
+	findCase   *widget.Check
+	findStatus *widget.Label
+
+	devtools *devTools
+
+	// *Tab is the active tab; page state and page-level methods are promoted from it
+	*Tab
 }
 
 type SelectionPoint struct {
@@ -84,32 +92,21 @@ func NewBrowser(width, height float32) *Browser {
 	}
 
 	b := &Browser{
-		App:             a,
-		Window:          w,
-		Width:           width,
-		Height:          height,
-		history:         []string{},
-		historyPos:      -1,
-		inputValues:     make(map[*dom.Node]string),
-		radioValues:     make(map[string]*dom.Node),
-		checkboxValue:   make(map[*dom.Node]bool),
-		fileInputValues: make(map[*dom.Node]string),
-		invalidNodes:    make(map[*dom.Node]bool),
+		App:    a,
+		Window: w,
+		Width:  width,
+		Height: height,
 	}
+	b.Tab = b.newTab()
+	b.tabs = []*Tab{b.Tab}
+
 	// Create URL entry
 	b.urlEntry = widget.NewEntry()
 	b.urlEntry.SetPlaceHolder("Enter URL...")
 
 	b.urlEntry.OnSubmitted = func(text string) {
 		if text != "" {
-			if b.OnNavigate != nil {
-				go func() {
-					if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-						return
-					}
-					b.OnNavigate(NavigationRequest{URL: text, Method: "GET"})
-				}()
-			}
+			b.navigate(NavigationRequest{URL: text, Method: "GET"})
 		}
 	}
 
@@ -124,41 +121,54 @@ func NewBrowser(width, height float32) *Browser {
 	goBtn := widget.NewButton("Go", func() {
 		url := b.urlEntry.Text
 		if url != "" {
-			if b.OnNavigate != nil {
-				go func() {
-					if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-						return
-					}
-					b.OnNavigate(NavigationRequest{URL: url, Method: "GET"})
-				}()
-			}
+			b.navigate(NavigationRequest{URL: url, Method: "GET"})
 		}
 	})
 
-	backBtn := widget.NewButton("←", func() {
+	b.backBtn = widget.NewButton("←", func() {
 		b.GoBack()
 	})
 
+	b.fwdBtn = widget.NewButton("→", func() {
+		b.GoForward()
+	})
+
 	refreshBtn := widget.NewButton("↻", func() {
 		b.Refresh()
 	})
 
-	// Toolbar: [Back] [URL Entry] [Go]
+	// Toolbar: [Back] [Forward] [Refresh] [URL Entry] [Go]
 	toolbar := container.NewBorder(
 		nil, nil, // top, bottom
-		container.NewHBox(backBtn, refreshBtn), goBtn, // left, right
+		container.NewHBox(b.backBtn, b.fwdBtn, refreshBtn), goBtn, // left, right
 		b.urlEntry, // center (fills remaining space)
 	)
 
-	// Content area (NewMax makes children fill available space)
+	// Tab strip above the toolbar
+	b.tabBar = container.NewHBox()
+	b.refreshTabBar()
+
+	// Content area (NewMax makes children fill available space), over the
+	// widget that takes the page's keyboard input
 	b.content = container.NewMax()
+	b.keys = newPageKeys(b)
+
+	// Find bar, hidden until Ctrl+F
+	b.findBar = b.newFindBar()
 
-	// Main layout: toolbar on top, content below
+	// Developer tools under the page, hidden until F12
+	b.devtools = b.newDevTools()
+	page := container.NewVSplit(container.NewStack(b.keys, b.content), b.devtools.panel)
+	page.Offset = 0.6
+
+	// Main layout: tabs and toolbar on top, find bar at the bottom, content in between
 	main := container.NewBorder(
-		toolbar, nil, nil, nil, // top, bottom, left, right
-		b.content, // center
+		container.NewVBox(b.tabBar, toolbar), b.findBar, nil, nil, // top, bottom, left, right
+		page, // center
 	)
 
+	b.addTabShortcuts()
+
 	w.Canvas().SetOnTypedRune(func(r rune) {
 		b.handleTypedRune(r)
 	})
@@ -179,59 +189,107 @@ func NewBrowser(width, height float32) *Browser {
 		}
 	}()
 
+	w.SetCloseIntercept(func() {
+		if b.SessionPath != "" {
+			if err := SaveSession(b.SessionPath, b.Session()); err != nil {
+				fmt.Println("Failed to save session:", err)
+			}
+		}
+		w.Close()
+	})
+
 	w.SetContent(main)
 
 	return b
 }
 
-func (b *Browser) SetContent(layoutTree *layout.LayoutBox) {
-	b.layoutTree = layoutTree // Save it so handleClick can use it
+func (t *Tab) SetContent(layoutTree *layout.LayoutBox) {
+	t.reflowMu.Lock()
+	t.layoutTree = layoutTree // Save it so handleClick can use it
+	t.layoutCSS = ""          // the next Reflow rebuilds from scratch
+	t.displayCache = NewDisplayCache()
+	t.resetAnimations()
+	t.loadFrames()
+	t.reflowMu.Unlock()
+	t.loading = false
+	t.resetFind()
+	t.browser.refreshTabBar()
+	if t.isActive() && t.browser.devtools != nil {
+		t.browser.devtools.pageChanged()
+	}
 
 	commands := BuildDisplayList(layoutTree)
 
-	// Get base URL for resolving relative image URLs
-	baseURL := ""
-	if b.currentURL != nil {
-		baseURL = b.currentURL.Scheme + "://" + b.currentURL.Host
-	}
+	objects := RenderToCanvas(commands, t.baseURL(), false, t.triggerRepaint)
+
+	// A fresh page starts at the top unless a restored session remembered a position
+	offset := t.pendingScroll
+	t.pendingScroll = fyne.Position{}
+	fyne.Do(func() {
+		t.display(objects, offset)
+	})
 
-	objects := RenderToCanvas(commands, baseURL, false, b.triggerRepaint)
+	// The tree was built without the engine; a reflow starts its animations
+	if usesAnimations(layoutTree) {
+		go t.Reflow(t.browser.Width)
+	}
+}
 
-	scroll := b.createContentScroll(objects)
-	b.content.Objects = []fyne.CanvasObject{scroll}
-	b.content.Refresh()
+func (t *Tab) AddToHistory(url string) {
+	t.history.Push(url)
+	t.browser.refreshNavButtons()
 }
 
-func (b *Browser) AddToHistory(url string) {
-	if b.historyPos < len(b.history)-1 {
-		b.history = b.history[:b.historyPos+1]
+func (t *Tab) GoBack() {
+	if prevURL, ok := t.history.Back(); ok {
+		t.goToHistoryEntry(prevURL, func() { t.history.Forward() })
 	}
+}
 
-	b.history = append(b.history, url)
-	b.historyPos = len(b.history) - 1
+func (t *Tab) GoForward() {
+	if nextURL, ok := t.history.Forward(); ok {
+		t.goToHistoryEntry(nextURL, func() { t.history.Back() })
+	}
 }
 
-func (b *Browser) GoBack() {
-	if b.historyPos > 0 {
-		go func() {
-			if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-				return
-			}
-			b.historyPos--
-			prevURL := b.history[b.historyPos]
-			b.urlEntry.SetText(prevURL)
+// goToHistoryEntry loads an entry the history cursor already moved to,
+// calling undo if the page refuses to unload
+func (t *Tab) goToHistoryEntry(entryURL string, undo func()) {
+	if t.browser.OnNavigate == nil {
+		undo()
+		return
+	}
+	t.UpdateURLBar(entryURL)
+	t.browser.refreshNavButtons()
 
-			if b.OnNavigate != nil {
-				b.OnNavigate(NavigationRequest{URL: prevURL, Method: "GET"})
-			}
-		}()
+	go func() {
+		if t.onBeforeNavigate != nil && !t.onBeforeNavigate() {
+			undo()
+			t.browser.refreshNavButtons()
+			return
+		}
+		t.browser.OnNavigate(NavigationRequest{URL: entryURL, Method: "GET", Tab: t, SkipHistory: true})
+	}()
+}
+
+// navigate runs the page's beforeunload check and then asks the loader for req
+func (t *Tab) navigate(req NavigationRequest) {
+	if t.browser.OnNavigate == nil {
+		return
 	}
+	req.Tab = t
+	go func() {
+		if t.onBeforeNavigate != nil && !t.onBeforeNavigate() {
+			return
+		}
+		t.browser.OnNavigate(req)
+	}()
 }
 
-func (b *Browser) SetCurrentURL(rawURL string) {
+func (t *Tab) SetCurrentURL(rawURL string) {
 	parsed, err := url.Parse(rawURL)
 	if err == nil {
-		b.currentURL = parsed
+		t.currentURL = parsed
 	}
 }
 
@@ -243,21 +301,35 @@ func (b *Browser) handleClick(x, y float64) {
 		return
 	}
 
-	// Hit test: find what was clicked
-	hit := b.layoutTree.HitTest(x, y)
+	// Hit test: find what was clicked. Inside an iframe, x and y become
+	// coordinates in the frame's document.
+	hit, x, y := b.layoutTree.HitTestPoint(x, y)
 	if hit == nil {
 		fmt.Println("  No hit found")
-		if b.focusedInputNode != nil {
+		if b.focusedInputNode != nil || b.keyboardFocus != nil {
 			b.focusedInputNode = nil
+			b.keyboardFocus = nil
 			b.repaint()
 		}
 		return
 	}
 	fmt.Printf("  Hit: %+v\n", hit.Text)
 
-	if b.onJSClick != nil && hit.Node != nil {
+	// Clicks move keyboard focus to the element clicked
+	b.keyboardFocus = focusableAncestor(hit.Node)
+
+	// Clicks inside a frame go to the scripts of the frame's document
+	var frameElement *dom.Node
+	onJSClick := b.onJSClick
+	if hit.Node != nil {
+		var frame *frameContext
+		if frameElement, frame = b.frameOf(hit.Node); frame != nil {
+			onJSClick = frame.onJSClick
+		}
+	}
+	if onJSClick != nil && hit.Node != nil {
 		// Run JS in goroutine so dialogs (confirm/prompt) don't block UI
-		go b.onJSClick(hit.Node)
+		go onJSClick(hit.Node)
 	}
 
 	if hit.Type == layout.InputBox && hit.Node != nil {
@@ -286,7 +358,9 @@ func (b *Browser) handleClick(x, y float64) {
 					num--
 				}
 
+				b.formMu.Lock()
 				b.inputValues[hit.Node] = formatNumber(num)
+				b.formMu.Unlock()
 				b.focusedInputNode = hit.Node
 				b.repaint()
 				return
@@ -306,8 +380,11 @@ func (b *Browser) handleClick(x, y float64) {
 		fmt.Println("click radio button")
 		name := hit.Node.Attributes["name"]
 		if name != "" {
+			b.formMu.Lock()
 			b.radioValues[name] = hit.Node
+			b.formMu.Unlock()
 		}
+		b.formChanged(hit.Node)
 		b.repaint()
 		return
 	}
@@ -317,7 +394,10 @@ func (b *Browser) handleClick(x, y float64) {
 			return
 		}
 		fmt.Println("click checkbox")
-		b.checkboxValue[hit.Node] = !b.checkboxValue[hit.Node]
+		b.formMu.Lock()
+		b.checkboxValue[hit.Node] = !b.checkboxChecked(hit.Node)
+		b.formMu.Unlock()
+		b.formChanged(hit.Node)
 		b.repaint()
 		return
 	}
@@ -335,8 +415,11 @@ func (b *Browser) handleClick(x, y float64) {
 			if reader == nil {
 				return // User cancelled
 			}
+			b.formMu.Lock()
 			b.fileInputValues[hit.Node] = reader.URI().Path()
+			b.formMu.Unlock()
 			reader.Close()
+			b.formChanged(hit.Node)
 			b.repaint()
 		}, b.Window)
 		return
@@ -387,7 +470,10 @@ func (b *Browser) handleClick(x, y float64) {
 				optionValue := b.getSelectOptionByIndex(b.openSelectNode, optionIndex)
 				if optionValue != "" {
 					fmt.Println("  Selected option:", optionValue)
+					b.formMu.Lock()
 					b.inputValues[b.openSelectNode] = optionValue
+					b.formMu.Unlock()
+					b.formChanged(b.openSelectNode)
 					b.openSelectNode = nil // Close dropdown
 					b.repaint()
 					return
@@ -406,7 +492,7 @@ func (b *Browser) handleClick(x, y float64) {
 		// Default button type inside form is "submit"
 		if buttonType == "" || buttonType == "submit" {
 			if formNode := findParentForm(hit.Node); formNode != nil {
-				b.submitForm(formNode)
+				b.submitForm(formNode, hit.Node)
 				return
 			}
 		}
@@ -425,43 +511,28 @@ func (b *Browser) handleClick(x, y float64) {
 	fmt.Println("  Found link:", linkInfo.Href, "target:", linkInfo.Target, "rel:", linkInfo.Rel)
 
 	fullURL := b.resolveURL(linkInfo.Href)
+	if frameElement != nil {
+		fullURL = b.resolveFrom(hit.Node.OwnerDocument(), linkInfo.Href)
+	}
 
 	fmt.Println("Link clicked:", fullURL)
 
 	if linkInfo.Target == "_blank" {
-		b.openNewWindow(fullURL)
+		b.OpenTab(fullURL)
 		return
 	}
 
-	// Call navigation callback
-	if b.OnNavigate != nil {
-		go func() {
-			if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-				return
-			}
-			b.OnNavigate(NavigationRequest{URL: fullURL, Method: "GET"})
-		}()
+	// Links in a frame load in the frame unless they target the page
+	if frameElement != nil && (linkInfo.Target == "" || linkInfo.Target == "_self") {
+		b.navigateFrame(frameElement, fullURL)
+		return
 	}
-}
 
-func (b *Browser) openNewWindow(targetURL string) {
-	fmt.Printf("Opening new window: %s\n", targetURL)
-
-	// Create a new Fyne window
-	newWindow := b.App.NewWindow("Go Browser")
-	newWindow.Resize(fyne.NewSize(800, 600))
-
-	// For now, just show the URL - user can copy/paste
-	// Full implementation would create another Browser instance
-	label := canvas.NewText("New window: "+targetURL, ColorBlack)
-	label.TextSize = 16
-	newWindow.SetContent(container.NewCenter(label))
-
-	newWindow.Show()
+	b.navigate(NavigationRequest{URL: fullURL, Method: "GET"})
 }
 
-func (b *Browser) resolveURL(href string) string {
-	if b.currentURL == nil {
+func (t *Tab) resolveURL(href string) string {
+	if t.currentURL == nil {
 		return href
 	}
 
@@ -477,8 +548,8 @@ func (b *Browser) resolveURL(href string) string {
 	}
 
 	// Check for <base> element first
-	if b.document != nil {
-		baseHref := dom.FindBaseHref(b.document)
+	if t.document != nil {
+		baseHref := dom.FindBaseHref(t.document)
 		if baseHref != "" {
 			baseURL, err := url.Parse(baseHref)
 			if err == nil && baseURL.IsAbs() {
@@ -489,53 +560,101 @@ func (b *Browser) resolveURL(href string) string {
 	}
 
 	// Fall back to current URL
-	resolved := b.currentURL.ResolveReference(parsed)
+	resolved := t.currentURL.ResolveReference(parsed)
 	return resolved.String()
 }
 
 // UpdateURLBar sets the text in the URL entry field
-func (b *Browser) UpdateURLBar(url string) {
-	b.urlEntry.SetText(url)
+func (t *Tab) UpdateURLBar(url string) {
+	t.urlText = url
+	if t.isActive() {
+		fyne.Do(func() {
+			t.browser.urlEntry.SetText(url)
+		})
+	}
 }
 
-func (b *Browser) ShowLoading() {
-	// White background
-	bg := canvas.NewRectangle(ColorWhite)
-	bg.Resize(fyne.NewSize(b.Width, b.Height))
-	bg.Move(fyne.NewPos(0, 0))
+func (t *Tab) ShowLoading() {
+	t.loading = true
+	t.browser.refreshTabBar()
+	if !t.isActive() {
+		return
+	}
+	b := t.browser
+
+	fyne.Do(func() {
+		// White background
+		bg := canvas.NewRectangle(ColorWhite)
+		bg.Resize(fyne.NewSize(b.Width, b.Height))
+		bg.Move(fyne.NewPos(0, 0))
 
-	// Loading text - centered
-	loading := canvas.NewText("Loading...", ColorBlack)
-	loading.TextSize = 18
-	loading.Alignment = fyne.TextAlignCenter
+		// Loading text - centered
+		loading := canvas.NewText("Loading...", ColorBlack)
+		loading.TextSize = 18
+		loading.Alignment = fyne.TextAlignCenter
 
-	// Use a center container to position the text
-	centered := container.NewCenter(loading)
+		// Use a center container to position the text
+		centered := container.NewCenter(loading)
 
-	// Stack background and centered text
-	stack := container.NewStack(bg, centered)
+		// Stack background and centered text
+		stack := container.NewStack(bg, centered)
 
-	b.content.Objects = []fyne.CanvasObject{stack}
-	b.content.Refresh()
+		b.content.Objects = []fyne.CanvasObject{stack}
+		b.content.Refresh()
+		b.shownTab = nil
+	})
 }
 
 func (b *Browser) Run() {
 	b.Window.ShowAndRun()
 }
 
-func (b *Browser) SetTitle(title string) {
+func (t *Tab) SetTitle(title string) {
 	if title == "" {
 		title = "Go Browser"
 	}
-	b.Window.SetTitle(title)
+	t.title = title
+	t.browser.refreshTabBar()
+	if t.isActive() {
+		fyne.Do(func() {
+			t.browser.Window.SetTitle(title)
+		})
+	}
 }
 
-func (b *Browser) SetDocument(doc *dom.Node) {
-	b.document = doc
+func (t *Tab) SetDocument(doc *dom.Node) {
+	// Canvas bitmaps and frames belong to the page being replaced
+	// Web fonts belong to the document too
+	if t.document != doc {
+		t.fonts = fonts.Default.ForDocument()
+	}
+	if t.document != nil && t.document != doc {
+		graphics.ReleaseCanvases(t.document)
+		t.reflowMu.Lock()
+		t.releaseFrames()
+		t.reflowMu.Unlock()
+		// The console starts over with each page
+		t.clearConsole()
+		t.SetEvaluateHandler(nil)
+		t.clearValidation()
+		t.keyboardFocus = nil
+	}
+	t.document = doc
 }
 
-func (b *Browser) SetExternalCSS(cssContent string) {
-	b.externalCSS = cssContent
+// Document returns the page's document, nil before the first load
+func (t *Tab) Document() *dom.Node {
+	return t.document
+}
+
+// Fonts returns the registry for the web fonts of the tab's document. Each
+// document set with SetDocument gets a new one.
+func (t *Tab) Fonts() *fonts.Registry {
+	return t.fonts
+}
+
+func (t *Tab) SetExternalCSS(cssContent string) {
+	t.externalCSS = cssContent
 }
 
 func (b *Browser) handleMouseDown(x, y float64) {
@@ -629,6 +748,7 @@ func (b *Browser) isBoxInSelection(box *layout.LayoutBox) bool {
 // instead of manually creating ClickableContainer to avoid missing handler bugs.
 func (b *Browser) createContentScroll(objects []fyne.CanvasObject) *container.Scroll {
 	clickable := NewClickableContainer(objects, func(x, y float32) {
+		b.focusPage()
 		b.handleClick(float64(x), float64(y))
 	}, b.layoutTree)
 
@@ -644,64 +764,67 @@ func (b *Browser) createContentScroll(objects []fyne.CanvasObject) *container.Sc
 }
 
 // Reflow re-computes layout with new width and repaints
-func (b *Browser) Reflow(width float32) {
-	if b.document == nil {
+func (t *Tab) Reflow(width float32) {
+	if t.document == nil {
 		return
 	}
+	b := t.browser
+	t.reflowMu.Lock()
+	defer t.reflowMu.Unlock()
 
 	// Re-collect CSS: external + active internal styles (respects disabled)
-	fullCSS := b.externalCSS + "\n" + dom.FindActiveStyleContent(b.document)
-	stylesheet := css.Parse(fullCSS)
-
-	// Re-build layout tree with updated stylesheet
-	layoutTree := layout.BuildLayoutTree(b.document, stylesheet, layout.Viewport{
+	fullCSS := t.externalCSS + "\n" + dom.FindActiveStyleContent(t.document)
+	viewport := layout.Viewport{
 		Width:  float64(width),
 		Height: float64(b.Window.Canvas().Size().Height),
-	})
+		Fonts:  t.fonts,
+	}
+
+	// Same stylesheet and viewport: only restyle and relayout what the DOM
+	// marked dirty. Anything else re-builds the layout tree.
+	var layoutTree *layout.LayoutBox
+	if t.layoutTree != nil && t.layoutCSS == fullCSS && t.layoutViewport == viewport {
+		layoutTree = layout.UpdateLayoutTree(t.layoutTree, t.document, t.layoutStylesheet, viewport)
+	} else {
+		t.layoutStylesheet = css.Parse(fullCSS)
+		t.layoutCSS = fullCSS
+		t.layoutViewport = viewport
+		layoutTree = layout.BuildLayoutTree(t.document, t.layoutStylesheet, viewport)
+	}
+	// Restyled elements start transitions; running animations are applied
+	if t.animations.Update(layoutTree, t.layoutStylesheet, viewport, time.Now()) {
+		t.startAnimationClock()
+	}
 	layout.ComputeLayout(layoutTree, float64(width))
+	t.layoutFrames(layoutTree)
+	// Scripts may have added iframes or changed their src
+	t.loadFrames()
 
 	// Update stored values
 	b.Width = width
-	b.layoutTree = layoutTree
+	t.layoutTree = layoutTree
+	t.updateFindMatches()
 
 	// Repaint with input state preserved (uses DOM node keys, stable across reflow)
-	commands := BuildDisplayListWithInputs(layoutTree, InputState{
-		InputValues:     b.inputValues,
-		FocusedNode:     b.focusedInputNode,
-		OpenSelectNode:  b.openSelectNode,
-		RadioValues:     b.radioValues,
-		CheckboxValues:  b.checkboxValue,
-		FileInputValues: b.fileInputValues,
-		InvalidNodes:    b.invalidNodes,
-	})
-
-	baseURL := ""
-	if b.currentURL != nil {
-		baseURL = b.currentURL.Scheme + "://" + b.currentURL.Host
-	}
+	commands := BuildDisplayListCached(layoutTree, t.inputState(), t.displayCache)
 
 	// Use cached images on reflow (don't re-fetch)
-	objects := RenderToCanvas(commands, baseURL, true, b.triggerRepaint) // true = use cache
+	objects := RenderToCanvas(commands, t.baseURL(), true, t.triggerRepaint) // true = use cache
 
 	// UI updates must be on main thread
 	fyne.Do(func() {
-		// Preserve scroll position
-		var scrollOffset fyne.Position
-		if len(b.content.Objects) > 0 {
-			if oldScroll, ok := b.content.Objects[0].(*container.Scroll); ok {
-				scrollOffset = oldScroll.Offset
-			}
-		}
-
-		scroll := b.createContentScroll(objects)
-		scroll.Offset = scrollOffset // Restore scroll position
-
-		b.content.Objects = []fyne.CanvasObject{scroll}
-		b.content.Refresh()
+		t.display(objects, t.scrollPosition())
 	})
 }
 
-func (b *Browser) ShowError(message string) {
+func (t *Tab) ShowError(message string) {
+	t.loading = false
+	t.browser.refreshTabBar()
+	if !t.isActive() {
+		return
+	}
+	b := t.browser
+
 	fyne.Do(func() {
 		bg := canvas.NewRectangle(ColorWhite)
 		bg.Resize(fyne.NewSize(b.Width, b.Height))
@@ -722,6 +845,7 @@ func (b *Browser) ShowError(message string) {
 
 		b.content.Objects = []fyne.CanvasObject{stack}
 		b.content.Refresh()
+		b.shownTab = nil
 	})
 }
 
@@ -731,9 +855,9 @@ func (b *Browser) ShowAlert(message string) {
 	})
 }
 
-func (b *Browser) Refresh() {
-	if b.currentURL != nil && b.OnNavigate != nil {
-		b.OnNavigate(NavigationRequest{URL: b.currentURL.String(), Method: "GET"})
+func (t *Tab) Refresh() {
+	if t.currentURL != nil && t.browser.OnNavigate != nil {
+		t.browser.OnNavigate(NavigationRequest{URL: t.currentURL.String(), Method: "GET", Tab: t, SkipHistory: true})
 	}
 }
 
@@ -752,16 +876,52 @@ func (b *Browser) handleTypedRune(r rune) {
 		return // Ignore non-numeric input
 	}
 
-	// Add character to input value
+	// Add character to input value, up to its maxlength
 	current := b.inputValues[b.focusedInputNode]
+	if atMaxLength(b.focusedInputNode, current) {
+		return
+	}
+	b.formMu.Lock()
 	b.inputValues[b.focusedInputNode] = current + string(r)
+	b.formMu.Unlock()
+	b.formChanged(b.focusedInputNode)
 
 	// Re-render to show new text
 	b.refreshContent()
 }
 
 func (b *Browser) handleTypedKey(key *fyne.KeyEvent) {
+	if key.Name == fyne.KeyF12 {
+		b.ToggleDevTools()
+		return
+	}
+
+	if key.Name == fyne.KeyEscape && b.findBar.Visible() {
+		b.HideFindBar()
+		return
+	}
+
+	if key.Name == fyne.KeyTab {
+		b.focusNext(shiftHeld())
+		return
+	}
+
 	if b.focusedInputNode == nil {
+		switch key.Name {
+		case fyne.KeyReturn, fyne.KeyEnter:
+			b.activateFocus()
+		case fyne.KeySpace:
+			// Space presses buttons and toggles checkboxes, but does not
+			// follow links
+			if b.keyboardFocus != nil && b.keyboardFocus.TagName != "a" {
+				b.activateFocus()
+			}
+		case fyne.KeyEscape:
+			if b.keyboardFocus != nil {
+				b.keyboardFocus = nil
+				b.repaint()
+			}
+		}
 		return
 	}
 
@@ -774,7 +934,10 @@ func (b *Browser) handleTypedKey(key *fyne.KeyEvent) {
 		if len(current) > 0 {
 			// Remove last character (handle UTF-8)
 			runes := []rune(current)
+			b.formMu.Lock()
 			b.inputValues[b.focusedInputNode] = string(runes[:len(runes)-1])
+			b.formMu.Unlock()
+			b.formChanged(b.focusedInputNode)
 			b.repaint()
 		}
 	case fyne.KeyReturn, fyne.KeyEnter:
@@ -783,12 +946,19 @@ func (b *Browser) handleTypedKey(key *fyne.KeyEvent) {
 		}
 		if b.focusedInputNode.TagName == "textarea" {
 			current := b.inputValues[b.focusedInputNode]
+			if atMaxLength(b.focusedInputNode, current) {
+				return
+			}
+			b.formMu.Lock()
 			b.inputValues[b.focusedInputNode] = current + "\n"
+			b.formMu.Unlock()
+			b.formChanged(b.focusedInputNode)
 			b.repaint()
 		}
 	case fyne.KeyEscape:
 		// Unfocus on escape
 		b.focusedInputNode = nil
+		b.keyboardFocus = nil
 		b.openSelectNode = nil
 		b.repaint()
 	}
@@ -846,49 +1016,25 @@ func (b *Browser) getSelectOptionByIndex(selectNode *dom.Node, index int) string
 }
 
 // repaint re-renders the current layout tree without recalculating layout
-func (b *Browser) repaint() {
-	if b.layoutTree == nil {
+func (t *Tab) repaint() {
+	if t.layoutTree == nil {
 		return
 	}
 
-	commands := BuildDisplayListWithInputs(b.layoutTree, InputState{
-		InputValues:     b.inputValues,
-		FocusedNode:     b.focusedInputNode,
-		OpenSelectNode:  b.openSelectNode,
-		RadioValues:     b.radioValues,
-		CheckboxValues:  b.checkboxValue,
-		FileInputValues: b.fileInputValues,
-		InvalidNodes:    b.invalidNodes,
-		SelectionStart:  b.selectionStart,
-		SelectionEnd:    b.selectionEnd,
-	})
-
-	baseURL := ""
-	if b.currentURL != nil {
-		baseURL = b.currentURL.Scheme + "://" + b.currentURL.Host
-	}
+	t.reflowMu.Lock()
+	commands := BuildDisplayListCached(t.layoutTree, t.inputState(), t.displayCache)
+	t.reflowMu.Unlock()
 
-	objects := RenderToCanvas(commands, baseURL, true, nil)
+	objects := RenderToCanvas(commands, t.baseURL(), true, nil)
 
 	fyne.Do(func() {
-		// Preserve scroll position
-		var scrollOffset fyne.Position
-		if len(b.content.Objects) > 0 {
-			if oldScroll, ok := b.content.Objects[0].(*container.Scroll); ok {
-				scrollOffset = oldScroll.Offset
-			}
-		}
-
-		scroll := b.createContentScroll(objects)
-		scroll.Offset = scrollOffset // Restore scroll position
-		b.content.Objects = []fyne.CanvasObject{scroll}
-		b.content.Refresh()
+		t.display(objects, t.scrollPosition())
 	})
 }
 
 // refreshContent is an alias for repaint (called by keyboard handlers)
-func (b *Browser) refreshContent() {
-	b.repaint()
+func (t *Tab) refreshContent() {
+	t.repaint()
 }
 
 // collectFormData gathers all input name/value pairs from a form
@@ -1040,19 +1186,20 @@ func findParentForm(node *dom.Node) *dom.Node {
 	return nil
 }
 
-// submitForm handles form submission
-func (b *Browser) submitForm(formNode *dom.Node) {
-
-	invalid := b.validateForm(formNode)
-	if len(invalid) > 0 {
-		b.invalidNodes = make(map[*dom.Node]bool)
-		for _, node := range invalid {
-			b.invalidNodes[node] = true
+// submitForm handles form submission. Unless the form has novalidate or
+// the submitter formnovalidate, invalid fields block it.
+func (b *Browser) submitForm(formNode, submitter *dom.Node) {
+	_, novalidate := formNode.Attributes["novalidate"]
+	if _, ok := submitter.Attributes["formnovalidate"]; ok {
+		novalidate = true
+	}
+	if !novalidate {
+		if invalid := b.validateForm(formNode); len(invalid) > 0 {
+			b.reportInvalid(invalid)
+			return
 		}
-		b.repaint()
-		return
 	}
-	b.invalidNodes = make(map[*dom.Node]bool)
+	b.clearValidation()
 
 	// Get form attributes
 	action := formNode.Attributes["action"]
@@ -1090,14 +1237,7 @@ func (b *Browser) submitForm(formNode *dom.Node) {
 		}
 
 		// Navigate to the URL
-		if b.OnNavigate != nil {
-			go func() {
-				if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-					return
-				}
-				b.OnNavigate(NavigationRequest{URL: targetURL, Method: "GET"})
-			}()
-		}
+		b.navigate(NavigationRequest{URL: targetURL, Method: "GET"})
 	case "POST":
 		if enctype == "multipart/form-data" {
 			body, contentType, err := b.buildMultipartBody(formNode)
@@ -1105,33 +1245,19 @@ func (b *Browser) submitForm(formNode *dom.Node) {
 				fmt.Println("Error building multipart body:", err)
 				return
 			}
-			if b.OnNavigate != nil {
-				go func() {
-					if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-						return
-					}
-					b.OnNavigate(NavigationRequest{
-						URL:         targetURL,
-						Method:      "POST",
-						Body:        body,
-						ContentType: contentType,
-					})
-				}()
-			}
+			b.navigate(NavigationRequest{
+				URL:         targetURL,
+				Method:      "POST",
+				Body:        body,
+				ContentType: contentType,
+			})
 		} else {
 			data := b.collectFormData(formNode)
-			if b.OnNavigate != nil {
-				go func() {
-					if b.onBeforeNavigate != nil && !b.onBeforeNavigate() {
-						return
-					}
-					b.OnNavigate(NavigationRequest{
-						URL:    targetURL,
-						Method: "POST",
-						Data:   data,
-					})
-				}()
-			}
+			b.navigate(NavigationRequest{
+				URL:    targetURL,
+				Method: "POST",
+				Data:   data,
+			})
 		}
 	}
 }
@@ -1140,7 +1266,9 @@ func (b *Browser) submitForm(formNode *dom.Node) {
 func (b *Browser) isChecked(node *dom.Node) bool {
 	inputType := node.Attributes["type"]
 	if inputType == "checkbox" {
-		return b.checkboxValue[node]
+		b.formMu.Lock()
+		defer b.formMu.Unlock()
+		return b.checkboxChecked(node)
 	}
 	if inputType == "radio" {
 		name := node.Attributes["name"]
@@ -1150,16 +1278,16 @@ func (b *Browser) isChecked(node *dom.Node) bool {
 }
 
 // getSelectedValue gets the selected option value from a select element
-func (b *Browser) getSelectedValue(selectNode *dom.Node) string {
+func (t *Tab) getSelectedValue(selectNode *dom.Node) string {
 	// Check if we have a stored value
-	if val, ok := b.inputValues[selectNode]; ok {
+	if val, ok := t.inputValues[selectNode]; ok {
 		return val
 	}
 
 	// Otherwise check DOM for selected attribute
 	for _, child := range selectNode.Children {
 		if child.TagName == "option" {
-			if child.Attributes["selected"] != "" {
+			if _, selected := child.Attributes["selected"]; selected {
 				value := child.Attributes["value"]
 				if value == "" {
 					// Use text content if no value attribute
@@ -1293,64 +1421,14 @@ func (b *Browser) addmultipartFiles(writer *multipart.Writer, node *dom.Node) {
 	}
 }
 
-func (b *Browser) validateForm(formNode *dom.Node) []*dom.Node {
-	var invalidNodes []*dom.Node
-	b.validateInputs(formNode, &invalidNodes)
-	return invalidNodes
-}
-
-func (b *Browser) validateInputs(node *dom.Node, invalidNodes *[]*dom.Node) {
-	if node == nil {
-		return
-	}
-
-	if node.Type == dom.Element {
-		_, isRequired := node.Attributes["required"]
-
-		if isRequired {
-			isEmpty := false
-
-			switch node.TagName {
-			case "input":
-				inputType := node.Attributes["type"]
-				switch inputType {
-				case "checkbox", "radio":
-					isEmpty = !b.isChecked(node)
-				case "file":
-					isEmpty = b.fileInputValues[node] == ""
-				default:
-					value := b.inputValues[node]
-					if value == "" {
-						value = node.Attributes["value"]
-					}
-					isEmpty = value == ""
-				}
-			case "textarea":
-				isEmpty = b.inputValues[node] == ""
-			case "select":
-				isEmpty = b.getSelectedValue(node) == ""
-			}
-
-			if isEmpty {
-				*invalidNodes = append(*invalidNodes, node)
-			}
-		}
-	}
-
-	// Recurse into children
-	for _, child := range node.Children {
-		b.validateInputs(child, invalidNodes)
-	}
-}
-
-func (b *Browser) SetJSClickHandler(handler func(node *dom.Node)) {
-	b.onJSClick = handler
+func (t *Tab) SetJSClickHandler(handler func(node *dom.Node)) {
+	t.onJSClick = handler
 }
 
-func (b *Browser) triggerRepaint() {
-	b.repaint()
+func (t *Tab) triggerRepaint() {
+	t.repaint()
 }
 
-func (b *Browser) SetBeforeNavigateHandler(handler func() bool) {
-	b.onBeforeNavigate = handler
+func (t *Tab) SetBeforeNavigateHandler(handler func() bool) {
+	t.onBeforeNavigate = handler
 }
@@ -50,7 +50,7 @@ func (bl *BinLinker) LinkAllPackages() error {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == ".bin" {
+		if !isDirOrLink(entry) || entry.Name() == ".bin" {
 			continue
 		}
 
@@ -63,7 +63,7 @@ func (bl *BinLinker) LinkAllPackages() error {
 				continue
 			}
 			for _, scopedEntry := range scopedEntries {
-				if scopedEntry.IsDir() {
+				if isDirOrLink(scopedEntry) {
 					scopedPkgPath := filepath.Join(pkgPath, scopedEntry.Name())
 					if err := bl.LinkPackage(scopedPkgPath); err != nil {
 						fmt.Printf("Warning: failed to link %s: %v\n", scopedPkgPath, err)
@@ -80,6 +80,12 @@ func (bl *BinLinker) LinkAllPackages() error {
 	return nil
 }
 
+// isDirOrLink reports whether an entry of node_modules can be a package:
+// a directory, or a symlink such as a linked workspace
+func isDirOrLink(entry os.DirEntry) bool {
+	return entry.IsDir() || entry.Type()&os.ModeSymlink != 0
+}
+
 func (bl *BinLinker) LinkPackage(pkgPath string) error {
 	packageJSONPath := filepath.Join(pkgPath, "package.json")
 
@@ -11,6 +11,8 @@ type Config struct {
 	ManifestDir string
 	TarballDir  string
 	PackagesDir string
+	StoreDir    string
+	AdvisoryDB  string
 
 	// Local installation paths
 	LocalNodeModules string
@@ -22,6 +24,11 @@ type Config struct {
 	GlobalBinDir      string
 	GlobalPackageJSON string
 	GlobalLockFile    string
+
+	// .npmrc files, from lowest to highest precedence
+	GlobalNpmrc  string
+	UserNpmrc    string
+	ProjectNpmrc string
 }
 
 func New() (*Config, error) {
@@ -38,6 +45,8 @@ func New() (*Config, error) {
 		ManifestDir: filepath.Join(baseDir, "manifest"),
 		TarballDir:  filepath.Join(baseDir, "tarball"),
 		PackagesDir: filepath.Join(baseDir, "packages"),
+		StoreDir:    filepath.Join(baseDir, "store"),
+		AdvisoryDB:  filepath.Join(baseDir, "advisories.json"),
 
 		LocalNodeModules: "./node_modules",
 		LocalBinDir:      "./node_modules/.bin",
@@ -47,5 +56,9 @@ func New() (*Config, error) {
 		GlobalBinDir:      filepath.Join(globalDir, "bin"),
 		GlobalPackageJSON: filepath.Join(globalDir, "package.json"),
 		GlobalLockFile:    filepath.Join(globalDir, "go-package-lock.json"),
+
+		GlobalNpmrc:  filepath.Join(globalDir, "etc", "npmrc"),
+		UserNpmrc:    filepath.Join(homeDir, ".npmrc"),
+		ProjectNpmrc: ".npmrc",
 	}, nil
 }
@@ -0,0 +1,191 @@
+package depspec
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Type is where a dependency spec says a package comes from
+type Type int
+
+const (
+	// Registry is a semver range, an exact version or a dist-tag
+	Registry Type = iota
+	// Workspace is a workspace: range, satisfied by a workspace package
+	Workspace
+	// Git is a repository to clone, at a commit, branch or tag
+	Git
+	// Directory is a local directory, packed and installed as a copy
+	Directory
+	// Tarball is a local .tgz or .tar.gz file
+	Tarball
+	// RemoteTarball is a .tgz file to download from a URL
+	RemoteTarball
+	// Link is a local directory symlinked into node_modules as it is
+	Link
+)
+
+func (t Type) String() string {
+	switch t {
+	case Workspace:
+		return "workspace"
+	case Git:
+		return "git"
+	case Directory:
+		return "directory"
+	case Tarball:
+		return "tarball"
+	case RemoteTarball:
+		return "remote tarball"
+	case Link:
+		return "link"
+	}
+	return "registry"
+}
+
+// Spec is a dependency spec, the value a dependency has in package.json
+type Spec struct {
+	Type Type
+	Raw  string
+	// Git: the URL to clone and the commit, branch or tag to check out,
+	// "" for the default branch
+	URL        string
+	Committish string
+	// Directory, Tarball and Link: the path as written, relative to the
+	// package declaring the dependency
+	Path string
+}
+
+// gitHosts are the hosted git shortcuts, github:org/repo and the like
+var gitHosts = map[string]string{
+	"github:":    "https://github.com/",
+	"gitlab:":    "https://gitlab.com/",
+	"bitbucket:": "https://bitbucket.org/",
+}
+
+// githubShorthand is org/repo, optionally with a #committish
+var githubShorthand = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+(#.*)?$`)
+
+// Parse classifies a dependency spec. Anything it does not recognise is a
+// registry range, which resolving then checks.
+func Parse(raw string) Spec {
+	s := Spec{Type: Registry, Raw: raw}
+	spec := strings.TrimSpace(raw)
+
+	switch {
+	case strings.HasPrefix(spec, "workspace:"):
+		s.Type = Workspace
+	case strings.HasPrefix(spec, "link:"):
+		s.Type, s.Path = Link, strings.TrimPrefix(spec, "link:")
+	case strings.HasPrefix(spec, "file:"):
+		s.Path = strings.TrimPrefix(spec, "file:")
+		s.Type = pathType(s.Path)
+	case strings.HasPrefix(spec, "git+"), strings.HasPrefix(spec, "git://"):
+		s.Type = Git
+		url, committish := splitCommittish(strings.TrimPrefix(spec, "git+"))
+		s.URL, s.Committish = fromSCP(url), committish
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		url, committish := splitCommittish(spec)
+		if strings.HasSuffix(url, ".git") {
+			s.Type, s.URL, s.Committish = Git, url, committish
+		} else {
+			s.Type = RemoteTarball
+		}
+	case isPath(spec):
+		s.Path = spec
+		s.Type = pathType(spec)
+	case githubShorthand.MatchString(spec):
+		s.Type = Git
+		repo, committish := splitCommittish(spec)
+		s.URL, s.Committish = gitHosts["github:"]+strings.TrimSuffix(repo, ".git")+".git", committish
+	default:
+		for prefix, base := range gitHosts {
+			if strings.HasPrefix(spec, prefix) {
+				repo, committish := splitCommittish(strings.TrimPrefix(spec, prefix))
+				s.Type = Git
+				s.URL, s.Committish = base+strings.TrimSuffix(repo, ".git")+".git", committish
+			}
+		}
+	}
+	return s
+}
+
+// IsRegistry reports whether raw is resolved against a registry
+func IsRegistry(raw string) bool {
+	return Parse(raw).Type == Registry
+}
+
+// GitResolved is how the lock file records a git dependency: its URL and
+// the commit it was at
+func GitResolved(url, commit string) string {
+	if !strings.HasPrefix(url, "git://") {
+		url = "git+" + url
+	}
+	return url + "#" + commit
+}
+
+// ParseArg splits a package argument of the command line, such as
+// lodash@^4, @scope/pkg@1.0.0 or name@github:org/repo#v1, into the name
+// and the spec. A bare spec (github:org/repo, ../lib, a git or tarball
+// URL) has no name; it comes from the package's own package.json. Paths
+// get the file: prefix package.json records them with.
+func ParseArg(arg string) (name, spec string) {
+	if s := Parse(arg); s.Type != Registry && s.Type != Workspace {
+		return "", withFilePrefix(arg)
+	}
+
+	// The @ of a scope is not a version separator
+	start := 0
+	if strings.HasPrefix(arg, "@") {
+		start = strings.Index(arg, "/")
+		if start < 0 {
+			return arg, ""
+		}
+	}
+	if i := strings.Index(arg[start:], "@"); i >= 0 {
+		return arg[:start+i], withFilePrefix(arg[start+i+1:])
+	}
+	return arg, ""
+}
+
+func withFilePrefix(spec string) string {
+	if isPath(spec) {
+		return "file:" + spec
+	}
+	return spec
+}
+
+func isPath(spec string) bool {
+	return spec == "." || spec == ".." || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") ||
+		strings.HasPrefix(spec, "/")
+}
+
+func pathType(path string) Type {
+	for _, ext := range []string{".tgz", ".tar.gz"} {
+		if strings.HasSuffix(path, ext) {
+			return Tarball
+		}
+	}
+	return Directory
+}
+
+// fromSCP turns ssh://git@host:org/repo.git, as npm accepts scp-style
+// addresses, into ssh://git@host/org/repo.git, which git can clone. A
+// numeric port after the host is kept.
+func fromSCP(url string) string {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return url
+	}
+	authority, _, _ := strings.Cut(rest, "/")
+	host, port, ok := strings.Cut(authority, ":")
+	if !ok || port == "" || strings.Trim(port, "0123456789") == "" {
+		return url
+	}
+	return scheme + "://" + host + "/" + strings.TrimPrefix(rest, host+":")
+}
+
+func splitCommittish(spec string) (string, string) {
+	url, committish, _ := strings.Cut(spec, "#")
+	return url, committish
+}
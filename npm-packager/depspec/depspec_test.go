@@ -0,0 +1,144 @@
+package depspec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		expected Spec
+	}{
+		{
+			name:     "Range",
+			spec:     "^1.2.0",
+			expected: Spec{Type: Registry, Raw: "^1.2.0"},
+		},
+		{
+			name:     "Dist-tag",
+			spec:     "latest",
+			expected: Spec{Type: Registry, Raw: "latest"},
+		},
+		{
+			name:     "Workspace range",
+			spec:     "workspace:^",
+			expected: Spec{Type: Workspace, Raw: "workspace:^"},
+		},
+		{
+			name:     "GitHub shortcut with a tag",
+			spec:     "github:org/repo#v1.0.0",
+			expected: Spec{Type: Git, Raw: "github:org/repo#v1.0.0", URL: "https://github.com/org/repo.git", Committish: "v1.0.0"},
+		},
+		{
+			name:     "GitHub shorthand",
+			spec:     "org/repo",
+			expected: Spec{Type: Git, Raw: "org/repo", URL: "https://github.com/org/repo.git"},
+		},
+		{
+			name:     "GitLab shortcut",
+			spec:     "gitlab:group/project#main",
+			expected: Spec{Type: Git, Raw: "gitlab:group/project#main", URL: "https://gitlab.com/group/project.git", Committish: "main"},
+		},
+		{
+			name:     "git+ssh URL",
+			spec:     "git+ssh://git@github.com/org/repo.git#4f3c2a1",
+			expected: Spec{Type: Git, Raw: "git+ssh://git@github.com/org/repo.git#4f3c2a1", URL: "ssh://git@github.com/org/repo.git", Committish: "4f3c2a1"},
+		},
+		{
+			name:     "scp-style git+ssh URL",
+			spec:     "git+ssh://git@github.com:org/repo.git#v1",
+			expected: Spec{Type: Git, Raw: "git+ssh://git@github.com:org/repo.git#v1", URL: "ssh://git@github.com/org/repo.git", Committish: "v1"},
+		},
+		{
+			name:     "git+ssh URL with a port",
+			spec:     "git+ssh://git@example.com:2222/org/repo.git",
+			expected: Spec{Type: Git, Raw: "git+ssh://git@example.com:2222/org/repo.git", URL: "ssh://git@example.com:2222/org/repo.git"},
+		},
+		{
+			name:     "git protocol URL",
+			spec:     "git://example.com/repo.git",
+			expected: Spec{Type: Git, Raw: "git://example.com/repo.git", URL: "git://example.com/repo.git"},
+		},
+		{
+			name:     "https URL of a repository",
+			spec:     "https://example.com/org/repo.git#dev",
+			expected: Spec{Type: Git, Raw: "https://example.com/org/repo.git#dev", URL: "https://example.com/org/repo.git", Committish: "dev"},
+		},
+		{
+			name:     "Tarball URL",
+			spec:     "https://example.com/pkg-1.0.0.tgz",
+			expected: Spec{Type: RemoteTarball, Raw: "https://example.com/pkg-1.0.0.tgz"},
+		},
+		{
+			name:     "file: directory",
+			spec:     "file:../lib",
+			expected: Spec{Type: Directory, Raw: "file:../lib", Path: "../lib"},
+		},
+		{
+			name:     "file: tarball",
+			spec:     "file:vendor/pkg-1.0.0.tgz",
+			expected: Spec{Type: Tarball, Raw: "file:vendor/pkg-1.0.0.tgz", Path: "vendor/pkg-1.0.0.tgz"},
+		},
+		{
+			name:     "Bare relative path",
+			spec:     "./lib",
+			expected: Spec{Type: Directory, Raw: "./lib", Path: "./lib"},
+		},
+		{
+			name:     "link: directory",
+			spec:     "link:../lib",
+			expected: Spec{Type: Link, Raw: "link:../lib", Path: "../lib"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Parse(tc.spec))
+		})
+	}
+}
+
+func TestParseArg(t *testing.T) {
+	testCases := []struct {
+		arg          string
+		expectedName string
+		expectedSpec string
+	}{
+		{"lodash", "lodash", ""},
+		{"lodash@^4.17.0", "lodash", "^4.17.0"},
+		{"@scope/pkg", "@scope/pkg", ""},
+		{"@scope/pkg@1.0.0", "@scope/pkg", "1.0.0"},
+		{"pkg@github:org/repo#v1", "pkg", "github:org/repo#v1"},
+		{"pkg@git+ssh://git@github.com/org/repo.git", "pkg", "git+ssh://git@github.com/org/repo.git"},
+		{"pkg@../lib", "pkg", "file:../lib"},
+		{"git+ssh://git@github.com/org/repo.git#v1", "", "git+ssh://git@github.com/org/repo.git#v1"},
+		{"github:org/repo", "", "github:org/repo"},
+		{"../lib", "", "file:../lib"},
+		{"file:../lib", "", "file:../lib"},
+		{"https://example.com/pkg-1.0.0.tgz", "", "https://example.com/pkg-1.0.0.tgz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.arg, func(t *testing.T) {
+			name, spec := ParseArg(tc.arg)
+			assert.Equal(t, tc.expectedName, name)
+			assert.Equal(t, tc.expectedSpec, spec)
+		})
+	}
+}
+
+func TestGitResolved(t *testing.T) {
+	resolved := GitResolved("ssh://git@github.com/org/repo.git", "abc123")
+	assert.Equal(t, "git+ssh://git@github.com/org/repo.git#abc123", resolved)
+
+	// The lock's resolved parses back to the commit
+	spec := Parse(resolved)
+	assert.Equal(t, Git, spec.Type)
+	assert.Equal(t, "ssh://git@github.com/org/repo.git", spec.URL)
+	assert.Equal(t, "abc123", spec.Committish)
+
+	assert.Equal(t, "git://example.com/repo.git#abc123", GitResolved("git://example.com/repo.git", "abc123"))
+}
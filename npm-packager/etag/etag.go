@@ -7,9 +7,11 @@ import (
 	"npm-packager/utils"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 type Etag struct {
+	mu       sync.Mutex
 	packages map[string]packagejson.Dependency
 	etagPath string
 	etagData map[string]EtagEntry
@@ -45,13 +47,28 @@ func (e *Etag) setPackages(packages map[string]packagejson.Dependency) {
 }
 
 func (e *Etag) Get(packageName string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	if entry, ok := e.etagData[packageName]; ok {
 		return entry.Etag
 	}
 	return ""
 }
 
+// Set records the ETag of the manifest of packageName, for Save to write
+func (e *Etag) Set(packageName string, etag string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if etag == "" {
+		delete(e.etagData, packageName)
+		return
+	}
+	e.etagData[packageName] = EtagEntry{Etag: etag}
+}
+
 func (e *Etag) Save() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	etagFilePath := filepath.Join(e.etagPath, "etag.json")
 
 	for pkgName, dep := range e.packages {
@@ -2,14 +2,16 @@ module npm-packager
 
 go 1.25.1
 
+require (
+	github.com/stretchr/testify v1.11.1
+	github.com/tidwall/gjson v1.14.2
+	github.com/tidwall/sjson v1.2.5
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
-	github.com/tidwall/gjson v1.14.2 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
-	github.com/tidwall/sjson v1.2.5 // indirect
-	golang.org/x/mod v0.28.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
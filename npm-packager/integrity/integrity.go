@@ -0,0 +1,156 @@
+package integrity
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrMismatch is returned when content does not match its expected hash
+var ErrMismatch = errors.New("integrity mismatch")
+
+// algorithms are the supported hash algorithms, strongest first
+var algorithms = []string{"sha512", "sha384", "sha256", "sha1"}
+
+// Hash is one hash of a Subresource Integrity string, e.g. sha512-<base64>
+type Hash struct {
+	Algorithm string
+	Digest    string
+}
+
+// Parse reads the hashes of an SRI string. There may be several separated
+// by spaces; options after a "?" and unsupported algorithms are ignored.
+func Parse(sri string) []Hash {
+	var hashes []Hash
+	for _, field := range strings.Fields(sri) {
+		field, _, _ = strings.Cut(field, "?")
+		algorithm, digest, found := strings.Cut(field, "-")
+		if !found || digest == "" || !supported(algorithm) {
+			continue
+		}
+		hashes = append(hashes, Hash{Algorithm: algorithm, Digest: digest})
+	}
+	return hashes
+}
+
+func supported(algorithm string) bool {
+	for _, a := range algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// FromShasum converts the hex sha1 of a manifest's dist.shasum to SRI
+func FromShasum(shasum string) string {
+	sum, err := hex.DecodeString(shasum)
+	if err != nil || len(sum) != sha1.Size {
+		return ""
+	}
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// Compute hashes content with every supported algorithm, so the result
+// can be checked against any SRI string or legacy shasum
+func Compute(r io.Reader) (string, error) {
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[i] = newHash(algorithm)
+		writers[i] = hashes[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return "", fmt.Errorf("failed to hash content: %w", err)
+	}
+
+	sri := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		sri[i] = algorithm + "-" + base64.StdEncoding.EncodeToString(hashes[i].Sum(nil))
+	}
+	return strings.Join(sri, " "), nil
+}
+
+// ComputeFile hashes a file as Compute does
+func ComputeFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	return Compute(file)
+}
+
+// Check compares the hashes of actual against expected using the strongest
+// algorithm both have. It fails with ErrMismatch when the hashes differ,
+// and when they share no algorithm, since nothing could be verified.
+func Check(actual, expected string) error {
+	actualHashes := Parse(actual)
+	expectedHashes := Parse(expected)
+	if len(expectedHashes) == 0 {
+		return fmt.Errorf("%w: no supported hash in %q", ErrMismatch, expected)
+	}
+
+	for _, algorithm := range algorithms {
+		var have []string
+		for _, h := range actualHashes {
+			if h.Algorithm == algorithm {
+				have = append(have, h.Digest)
+			}
+		}
+		if len(have) == 0 {
+			continue
+		}
+		compared := false
+		for _, want := range expectedHashes {
+			if want.Algorithm != algorithm {
+				continue
+			}
+			compared = true
+			for _, digest := range have {
+				if digest == want.Digest {
+					return nil
+				}
+			}
+		}
+		if compared {
+			return fmt.Errorf("%w: expected %s, got %s-%s", ErrMismatch, expected, algorithm, have[0])
+		}
+	}
+	return fmt.Errorf("%w: no hash of %q can be checked against %q", ErrMismatch, expected, actual)
+}
+
+// Strongest returns the strongest hash of an SRI string as SRI, the form
+// the lock file records
+func Strongest(sri string) string {
+	hashes := Parse(sri)
+	for _, algorithm := range algorithms {
+		for _, h := range hashes {
+			if h.Algorithm == algorithm {
+				return h.Algorithm + "-" + h.Digest
+			}
+		}
+	}
+	return ""
+}
+
+// newHash returns a hash for an algorithm Parse accepts
+func newHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha512":
+		return sha512.New()
+	case "sha384":
+		return sha512.New384()
+	case "sha256":
+		return sha256.New()
+	}
+	return sha1.New()
+}
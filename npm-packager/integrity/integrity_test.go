@@ -0,0 +1,105 @@
+package integrity
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var content = []byte("package contents")
+
+func sri(algorithm string, sum []byte) string {
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sri      string
+		expected []Hash
+	}{
+		{"Single hash", "sha512-abc", []Hash{{"sha512", "abc"}}},
+		{"Several hashes", "sha1-abc  sha256-def", []Hash{{"sha1", "abc"}, {"sha256", "def"}}},
+		{"Options are dropped", "sha384-abc?foo", []Hash{{"sha384", "abc"}}},
+		{"Unsupported algorithms are skipped", "md5-abc sha512-def", []Hash{{"sha512", "def"}}},
+		{"Malformed", "sha512 sha1-", nil},
+		{"Empty", "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Parse(tc.sri))
+		})
+	}
+}
+
+func TestFromShasum(t *testing.T) {
+	sum := sha1.Sum(content)
+	assert.Equal(t, sri("sha1", sum[:]), FromShasum(hex.EncodeToString(sum[:])))
+	assert.Equal(t, "", FromShasum("not-hex"))
+	assert.Equal(t, "", FromShasum("abcd"))
+}
+
+func TestCheck(t *testing.T) {
+	sha512Sum := sha512.Sum512(content)
+	sha256Sum := sha256.Sum256(content)
+	sha1Sum := sha1.Sum(content)
+	actual, err := Compute(strings.NewReader(string(content)))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		expected    string
+		expectError bool
+	}{
+		{name: "sha512 matches", expected: sri("sha512", sha512Sum[:])},
+		{name: "sha256 matches", expected: sri("sha256", sha256Sum[:])},
+		{name: "sha1 matches", expected: sri("sha1", sha1Sum[:])},
+		{name: "Any hash of the strongest algorithm may match", expected: sri("sha512", make([]byte, 64)) + " " + sri("sha512", sha512Sum[:])},
+		{
+			name:        "Strongest algorithm is used",
+			expected:    sri("sha512", make([]byte, 64)) + " " + sri("sha1", sha1Sum[:]),
+			expectError: true,
+		},
+		{name: "Mismatch", expected: sri("sha1", make([]byte, 20)), expectError: true},
+		{name: "Nothing to check against", expected: "md5-abc", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Check(actual, tc.expected)
+			if tc.expectError {
+				assert.ErrorIs(t, err, ErrMismatch)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestComputeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "package.tgz")
+	assert.NoError(t, os.WriteFile(path, content, 0644))
+
+	actual, err := ComputeFile(path)
+	assert.NoError(t, err)
+	sum := sha512.Sum512(content)
+	assert.Equal(t, sri("sha512", sum[:]), Strongest(actual))
+
+	_, err = ComputeFile(filepath.Join(t.TempDir(), "missing.tgz"))
+	assert.Error(t, err)
+}
+
+func TestStrongest(t *testing.T) {
+	assert.Equal(t, "sha512-b", Strongest("sha1-a sha512-b sha256-c"))
+	assert.Equal(t, "sha1-a", Strongest("sha1-a"))
+	assert.Equal(t, "", Strongest("md5-a"))
+}
@@ -0,0 +1,231 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// InstallEvents are the scripts of a dependency run once it is installed,
+// in the order npm runs them
+var InstallEvents = []string{"preinstall", "install", "postinstall"}
+
+// ErrMissingScript is returned when a script asked for by name does not exist
+var ErrMissingScript = errors.New("missing script")
+
+// PackageJSON holds the fields of package.json a script needs
+type PackageJSON struct {
+	Name    string            `json:"name"`
+	Version string            `json:"version"`
+	Scripts map[string]string `json:"scripts"`
+}
+
+// ScriptError is returned when a script exits with an error
+type ScriptError struct {
+	Package  string
+	Event    string
+	Script   string
+	ExitCode int
+	Err      error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s %s script %q failed with exit code %d: %v", e.Package, e.Event, e.Script, e.ExitCode, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs package.json scripts in a shell, with the environment npm
+// gives them
+type Runner struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	// Directory the command was started from, INIT_CWD for scripts
+	initCwd string
+}
+
+func NewRunner() *Runner {
+	initCwd, err := os.Getwd()
+	if err != nil {
+		initCwd = "."
+	}
+	return &Runner{Stdout: os.Stdout, Stderr: os.Stderr, initCwd: initCwd}
+}
+
+// ReadPackage reads the package.json in pkgDir
+func ReadPackage(pkgDir string) (*PackageJSON, error) {
+	filePath := filepath.Join(pkgDir, "package.json")
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	var pkg PackageJSON
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from file %s: %w", filePath, err)
+	}
+	return &pkg, nil
+}
+
+// HasInstallScript reports whether pkg runs any of the InstallEvents
+func (pkg *PackageJSON) HasInstallScript() bool {
+	for _, event := range InstallEvents {
+		if pkg.Scripts[event] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RunScript runs a script the way npm run does: pre<name>, the script with
+// args appended, then post<name>, each only if the package has it
+func (r *Runner) RunScript(pkgDir, name string, args []string) error {
+	pkg, err := ReadPackage(pkgDir)
+	if err != nil {
+		return err
+	}
+	if _, ok := pkg.Scripts[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrMissingScript, name)
+	}
+
+	if err := r.run(pkgDir, pkg, "run-script", "pre"+name, nil); err != nil {
+		return err
+	}
+	if err := r.run(pkgDir, pkg, "run-script", name, args); err != nil {
+		return err
+	}
+	return r.run(pkgDir, pkg, "run-script", "post"+name, nil)
+}
+
+// RunInstall runs the install scripts of the package installed in pkgDir
+func (r *Runner) RunInstall(pkgDir string) error {
+	pkg, err := ReadPackage(pkgDir)
+	if err != nil {
+		return err
+	}
+	for _, event := range InstallEvents {
+		if err := r.run(pkgDir, pkg, "install", event, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScriptNames returns the names of the scripts of the package in pkgDir
+func ScriptNames(pkgDir string) ([]string, error) {
+	pkg, err := ReadPackage(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// run runs one script of pkg in pkgDir, doing nothing if it has none for event
+func (r *Runner) run(pkgDir string, pkg *PackageJSON, command, event string, args []string) error {
+	script, ok := pkg.Scripts[event]
+	if !ok || script == "" {
+		return nil
+	}
+
+	line := script
+	for _, arg := range args {
+		line += " " + shellQuote(arg)
+	}
+
+	fmt.Fprintf(r.Stdout, "\n> %s@%s %s\n> %s\n\n", pkg.Name, pkg.Version, event, line)
+
+	cmd := shellCommand(line)
+	cmd.Dir = pkgDir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = r.Stdout
+	cmd.Stderr = r.Stderr
+	cmd.Env = r.environ(pkgDir, pkg, command, event, script)
+
+	if err := cmd.Run(); err != nil {
+		exitCode := 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			exitCode = exitErr.ExitCode()
+		}
+		return &ScriptError{Package: pkg.Name, Event: event, Script: line, ExitCode: exitCode, Err: err}
+	}
+	return nil
+}
+
+// environ is the process environment with the node_modules/.bin directories
+// of pkgDir and every directory above it first on PATH, and the npm_*
+// variables scripts read
+func (r *Runner) environ(pkgDir string, pkg *PackageJSON, command, event, script string) []string {
+	absDir, err := filepath.Abs(pkgDir)
+	if err != nil {
+		absDir = pkgDir
+	}
+
+	var binDirs []string
+	for dir := absDir; ; dir = filepath.Dir(dir) {
+		binDirs = append(binDirs, filepath.Join(dir, "node_modules", ".bin"))
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	execPath, _ := os.Executable()
+	vars := map[string]string{
+		"PATH":                 strings.Join(append(binDirs, os.Getenv("PATH")), string(os.PathListSeparator)),
+		"INIT_CWD":             r.initCwd,
+		"npm_command":          command,
+		"npm_execpath":         execPath,
+		"npm_lifecycle_event":  event,
+		"npm_lifecycle_script": script,
+		"npm_package_name":     pkg.Name,
+		"npm_package_version":  pkg.Version,
+		"npm_package_json":     filepath.Join(absDir, "package.json"),
+	}
+
+	env := make([]string, 0, len(os.Environ())+len(vars))
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, overridden := vars[key]; !overridden {
+			env = append(env, kv)
+		}
+	}
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/d", "/s", "/c", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// shellQuote quotes an argument for the shell the script runs in, so it
+// reaches the script as one word
+func shellQuote(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	}
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@%+,", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
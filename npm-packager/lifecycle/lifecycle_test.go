@@ -0,0 +1,178 @@
+package lifecycle
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePackage writes a package.json with scripts to dir
+func writePackage(t *testing.T, dir string, scripts map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(PackageJSON{Name: "test-pkg", Version: "1.2.3", Scripts: scripts})
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), data, 0644))
+}
+
+func newTestRunner() (*Runner, *bytes.Buffer) {
+	var out bytes.Buffer
+	r := NewRunner()
+	r.Stdout = &out
+	r.Stderr = &out
+	return r, &out
+}
+
+func TestRunner_RunScript(t *testing.T) {
+	testCases := []struct {
+		name        string
+		scripts     map[string]string
+		script      string
+		args        []string
+		expectError error
+		validate    func(t *testing.T, dir string, out string, err error)
+	}{
+		{
+			name: "Runs pre and post hooks around the script",
+			scripts: map[string]string{
+				"prebuild":  "echo pre >> order.txt",
+				"build":     "echo build >> order.txt",
+				"postbuild": "echo post >> order.txt",
+			},
+			script: "build",
+			validate: func(t *testing.T, dir string, out string, err error) {
+				content, readErr := os.ReadFile(filepath.Join(dir, "order.txt"))
+				assert.NoError(t, readErr)
+				assert.Equal(t, "pre\nbuild\npost\n", string(content))
+				assert.Contains(t, out, "> test-pkg@1.2.3 build")
+			},
+		},
+		{
+			name:    "Appends quoted arguments to the script only",
+			scripts: map[string]string{"test": "printf '%s\\n'"},
+			script:  "test",
+			args:    []string{"--watch", "two words", "it's"},
+			validate: func(t *testing.T, dir string, out string, err error) {
+				assert.Contains(t, out, "--watch\ntwo words\nit's\n")
+				assert.Contains(t, out, `> printf '%s\n' --watch 'two words' 'it'\''s'`)
+			},
+		},
+		{
+			name:    "Sets npm environment variables",
+			scripts: map[string]string{"env": "echo \"$npm_package_name $npm_package_version $npm_lifecycle_event $npm_command\" > env.txt"},
+			script:  "env",
+			validate: func(t *testing.T, dir string, out string, err error) {
+				content, readErr := os.ReadFile(filepath.Join(dir, "env.txt"))
+				assert.NoError(t, readErr)
+				assert.Equal(t, "test-pkg 1.2.3 env run-script\n", string(content))
+			},
+		},
+		{
+			name:    "Puts node_modules/.bin on PATH",
+			scripts: map[string]string{"hello": "hello-bin"},
+			script:  "hello",
+			validate: func(t *testing.T, dir string, out string, err error) {
+				assert.Contains(t, out, "hello from bin")
+			},
+		},
+		{
+			name:        "Missing script",
+			scripts:     map[string]string{"build": "true"},
+			script:      "start",
+			expectError: ErrMissingScript,
+		},
+		{
+			name:    "Failing script stops the post hook and reports its exit code",
+			scripts: map[string]string{"lint": "exit 3", "postlint": "echo post > post.txt"},
+			script:  "lint",
+			validate: func(t *testing.T, dir string, out string, err error) {
+				var scriptErr *ScriptError
+				assert.ErrorAs(t, err, &scriptErr)
+				assert.Equal(t, 3, scriptErr.ExitCode)
+				assert.Equal(t, "lint", scriptErr.Event)
+				assert.NoFileExists(t, filepath.Join(dir, "post.txt"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePackage(t, dir, tc.scripts)
+
+			binDir := filepath.Join(dir, "node_modules", ".bin")
+			assert.NoError(t, os.MkdirAll(binDir, 0755))
+			assert.NoError(t, os.WriteFile(filepath.Join(binDir, "hello-bin"), []byte("#!/bin/sh\necho hello from bin\n"), 0755))
+
+			r, out := newTestRunner()
+			err := r.RunScript(dir, tc.script, tc.args)
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				return
+			}
+			if tc.validate != nil {
+				tc.validate(t, dir, out.String(), err)
+			}
+		})
+	}
+}
+
+func TestRunner_RunInstall(t *testing.T) {
+	projectDir := t.TempDir()
+	pkgDir := filepath.Join(projectDir, "node_modules", "native")
+	writePackage(t, pkgDir, map[string]string{
+		"preinstall":  "echo preinstall >> order.txt",
+		"install":     "echo $npm_lifecycle_event $npm_command >> order.txt",
+		"postinstall": "project-bin >> order.txt",
+		"test":        "echo test >> order.txt",
+	})
+
+	// Bins of the project are reachable from a dependency's scripts
+	binDir := filepath.Join(projectDir, "node_modules", ".bin")
+	assert.NoError(t, os.MkdirAll(binDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "project-bin"), []byte("#!/bin/sh\necho postinstall\n"), 0755))
+
+	r, _ := newTestRunner()
+	assert.NoError(t, r.RunInstall(pkgDir))
+
+	content, err := os.ReadFile(filepath.Join(pkgDir, "order.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "preinstall\ninstall install\npostinstall\n", string(content))
+}
+
+func TestScriptNames(t *testing.T) {
+	dir := t.TempDir()
+	writePackage(t, dir, map[string]string{"test": "jest", "build": "tsc"})
+
+	names, err := ScriptNames(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"build", "test"}, names)
+
+	_, err = ScriptNames(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected string
+	}{
+		{"--watch", "--watch"},
+		{"src/index.ts", "src/index.ts"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'\''s'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.arg, func(t *testing.T) {
+			assert.Equal(t, tc.expected, shellQuote(tc.arg))
+		})
+	}
+}
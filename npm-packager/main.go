@@ -1,22 +1,70 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"npm-packager/depspec"
+	"npm-packager/lifecycle"
 	"npm-packager/manager"
+	"npm-packager/pack"
+	"npm-packager/publish"
 	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
-func parsePackageArg(pkgArg string) (string, string) {
-	parts := strings.Split(pkgArg, "@")
-	pkg := parts[0]
-	version := ""
-	if len(parts) > 1 {
-		version = parts[1]
+// parseRunArgs splits the arguments of run into the script name, the
+// workspace given with -w or --workspace, and the arguments for the script:
+// everything after the name, with a leading -- optional
+func parseRunArgs(args []string) (script string, workspaceName string, scriptArgs []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			scriptArgs = append(scriptArgs, args[i+1:]...)
+			return script, workspaceName, scriptArgs
+		case (arg == "-w" || arg == "--workspace") && i+1 < len(args):
+			workspaceName = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--workspace="):
+			workspaceName = strings.TrimPrefix(arg, "--workspace=")
+		case script == "":
+			script = arg
+		default:
+			scriptArgs = append(scriptArgs, arg)
+		}
+	}
+	return script, workspaceName, scriptArgs
+}
+
+// setNetworkMode applies --offline and --prefer-offline; either only
+// turns its mode on, so one set in .npmrc stays
+func setNetworkMode(pm *manager.PackageManager, offline, preferOffline bool) {
+	if offline {
+		pm.SetOffline(true)
+	}
+	if preferOffline {
+		pm.SetPreferOffline(true)
 	}
-	return pkg, version
+}
+
+// printTarball reports what was packed, as npm pack does
+func printTarball(tgz *pack.Tarball) {
+	fmt.Printf("package: %s@%s\n", tgz.Name, tgz.Version)
+	fmt.Println("Tarball Contents")
+	for _, file := range tgz.Files {
+		fmt.Println("  " + file)
+	}
+	fmt.Println("Tarball Details")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "  filename:\t%s\n", tgz.Filename())
+	fmt.Fprintf(w, "  package size:\t%d B\n", len(tgz.Data))
+	fmt.Fprintf(w, "  shasum:\t%s\n", tgz.Shasum)
+	fmt.Fprintf(w, "  integrity:\t%s\n", tgz.Integrity)
+	fmt.Fprintf(w, "  total files:\t%d\n", len(tgz.Files))
+	w.Flush()
 }
 
 func main() {
@@ -44,9 +92,16 @@ func main() {
 		iFlags := flag.NewFlagSet("i", flag.ExitOnError)
 		globalFlag := iFlags.Bool("g", false, "Install package globally")
 		productionFlag := iFlags.Bool("production", false, "Install only production dependencies")
+		ignoreScriptsFlag := iFlags.Bool("ignore-scripts", false, "Do not run dependencies' lifecycle scripts")
+		offlineFlag := iFlags.Bool("offline", false, "Install only from the cache, without the network")
+		preferOfflineFlag := iFlags.Bool("prefer-offline", false, "Use cached manifests without revalidating them")
 
 		iFlags.Parse(os.Args[2:])
 		args := iFlags.Args()
+		if *ignoreScriptsFlag {
+			packageManager.SetIgnoreScripts(true)
+		}
+		setNetworkMode(packageManager, *offlineFlag, *preferOfflineFlag)
 
 		if *globalFlag {
 			if len(args) < 1 {
@@ -54,7 +109,11 @@ func main() {
 				os.Exit(1)
 			}
 
-			pkg, version := parsePackageArg(args[0])
+			pkg, version := depspec.ParseArg(args[0])
+			if pkg == "" {
+				fmt.Println("Usage: go-npm i -g <package-name>[@version]")
+				os.Exit(1)
+			}
 
 			err := packageManager.SetupGlobal()
 			if err != nil {
@@ -67,6 +126,7 @@ func main() {
 				fmt.Println("Error installing globally:", err)
 				return
 			}
+			deps.Downloader.Progress.Finish()
 
 			executionTime := time.Since(startTime)
 			fmt.Printf("\nExecution completed in: %v\n", executionTime)
@@ -78,12 +138,168 @@ func main() {
 			return
 		}
 
+	case "ci":
+		ciFlags := flag.NewFlagSet("ci", flag.ExitOnError)
+		productionFlag := ciFlags.Bool("production", false, "Install only production dependencies")
+		ignoreScriptsFlag := ciFlags.Bool("ignore-scripts", false, "Do not run dependencies' lifecycle scripts")
+		offlineFlag := ciFlags.Bool("offline", false, "Install only from the cache, without the network")
+		preferOfflineFlag := ciFlags.Bool("prefer-offline", false, "Use cached manifests without revalidating them")
+		ciFlags.Parse(os.Args[2:])
+		if *ignoreScriptsFlag {
+			packageManager.SetIgnoreScripts(true)
+		}
+		setNetworkMode(packageManager, *offlineFlag, *preferOfflineFlag)
+
+		if err := packageManager.CI(*productionFlag); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+	case "run", "run-script":
+		script, workspaceName, args := parseRunArgs(os.Args[2:])
+		if script == "" {
+			names, err := packageManager.ScriptNames()
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			fmt.Println("Usage: go-npm run <script> [-w <workspace>] [-- args]")
+			fmt.Println("Scripts available:", strings.Join(names, ", "))
+			return
+		}
+
+		if workspaceName != "" {
+			err = packageManager.RunWorkspaceScript(workspaceName, script, args)
+		} else {
+			err = packageManager.RunScript(script, args)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			var scriptErr *lifecycle.ScriptError
+			if errors.As(err, &scriptErr) {
+				os.Exit(scriptErr.ExitCode)
+			}
+			os.Exit(1)
+		}
+		return
+
+	case "ls", "list":
+		lsFlags := flag.NewFlagSet("ls", flag.ExitOnError)
+		depthFlag := lsFlags.Int("depth", 0, "Levels of dependencies to show below the project's own")
+		lsFlags.Parse(os.Args[2:])
+
+		problems, err := packageManager.List(os.Stdout, *depthFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(problems) > 0 {
+			fmt.Println()
+			for _, problem := range problems {
+				fmt.Println("Error:", problem)
+			}
+			os.Exit(1)
+		}
+		return
+
+	case "dedupe":
+		if err := packageManager.Dedupe(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+	case "outdated":
+		outdated, err := packageManager.Outdated()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(outdated) == 0 {
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Package\tCurrent\tWanted\tLatest\tDepended by")
+		for _, pkg := range outdated {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", pkg.Name, pkg.Current, pkg.Wanted, pkg.Latest, pkg.DependedBy)
+		}
+		w.Flush()
+		os.Exit(1)
+
+	case "update", "up":
+		updateFlags := flag.NewFlagSet("update", flag.ExitOnError)
+		offlineFlag := updateFlags.Bool("offline", false, "Update only to versions in the cache, without the network")
+		preferOfflineFlag := updateFlags.Bool("prefer-offline", false, "Use cached manifests without revalidating them")
+		updateFlags.Parse(os.Args[2:])
+		setNetworkMode(packageManager, *offlineFlag, *preferOfflineFlag)
+
+		if err := packageManager.Update(updateFlags.Args()); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+	case "audit":
+		auditFlags := flag.NewFlagSet("audit", flag.ExitOnError)
+		dbFlag := auditFlags.String("db", deps.Config.AdvisoryDB, "Advisory database file")
+		levelFlag := auditFlags.String("audit-level", "low", "Lowest severity that fails the audit: info, low, moderate, high or critical")
+		auditFlags.Parse(os.Args[2:])
+
+		failing, err := packageManager.Audit(os.Stdout, *dbFlag, *levelFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(failing) > 0 {
+			os.Exit(1)
+		}
+		return
+
+	case "store":
+		if len(os.Args) < 3 || os.Args[2] != "prune" {
+			fmt.Println("Usage: go-npm store prune")
+			os.Exit(1)
+		}
+		if err := packageManager.StorePrune(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+
+	case "pack":
+		packFlags := flag.NewFlagSet("pack", flag.ExitOnError)
+		destFlag := packFlags.String("pack-destination", ".", "Directory to write the tarball to")
+		dryRunFlag := packFlags.Bool("dry-run", false, "Report what would be packed without writing it")
+		packFlags.Parse(os.Args[2:])
+
+		tgz, err := packageManager.Pack(*destFlag, *dryRunFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		printTarball(tgz)
+		return
+
+	case "publish":
+		publishFlags := flag.NewFlagSet("publish", flag.ExitOnError)
+		tagFlag := publishFlags.String("tag", "", "Dist-tag to point at the version (default latest)")
+		accessFlag := publishFlags.String("access", "", "public or restricted, for scoped packages")
+		dryRunFlag := publishFlags.Bool("dry-run", false, "Pack and report without publishing")
+		publishFlags.Parse(os.Args[2:])
+
+		tgz, err := packageManager.Publish(publish.Options{Tag: *tagFlag, Access: *accessFlag, DryRun: *dryRunFlag})
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		printTarball(tgz)
+		fmt.Printf("+ %s@%s\n", tgz.Name, tgz.Version)
+		return
+
 	case "add":
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: go-npm add <package-name>@<version>")
+			fmt.Println("Usage: go-npm add <package-name>[@<version>] | <git-url> | <path> | <tarball-url>")
 			os.Exit(1)
 		}
-		pkg, version := parsePackageArg(os.Args[2])
+		pkg, version := depspec.ParseArg(os.Args[2])
 		fmt.Println("pkg:", pkg)
 		fmt.Println("version:", version)
 
@@ -148,14 +364,18 @@ func main() {
 		return
 
 	default:
-		fmt.Println("Usage: go-npm [i|add|rm|uninstall] [package-name]")
+		fmt.Println("Usage: go-npm [i|ci|run|ls|outdated|update|audit|dedupe|store|pack|publish|add|rm|uninstall] [package-name]")
 		os.Exit(1)
 	}
 
 	if err := packageManager.InstallFromCache(); err != nil {
 		fmt.Println(err)
+		if param == "ci" {
+			os.Exit(1)
+		}
 		return
 	}
+	deps.Downloader.Progress.Finish()
 
 	executionTime := time.Since(startTime)
 	fmt.Printf("\nExecution completed in: %v\n", executionTime)
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// severities are the advisory severities, lowest first
+var severities = []string{"info", "low", "moderate", "high", "critical"}
+
+// severityRank orders severities; unknown ones rank with info
+func severityRank(severity string) int {
+	if i := severityIndex(severity); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+func severityIndex(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// advisoryID is the id of an advisory, a number in the npm registry's
+// feed and a string such as a GHSA id in others
+type advisoryID string
+
+func (id *advisoryID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = advisoryID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("advisory id must be a string or a number: %s", data)
+	}
+	*id = advisoryID(n)
+	return nil
+}
+
+// Advisory is a known vulnerability of the versions of a package in
+// VulnerableVersions, a semver range
+type Advisory struct {
+	ID                 advisoryID `json:"id"`
+	Title              string     `json:"title"`
+	URL                string     `json:"url"`
+	Severity           string     `json:"severity"`
+	VulnerableVersions string     `json:"vulnerable_versions"`
+}
+
+// LoadAdvisories reads an advisory database: advisories by package name,
+// the format of the npm registry's bulk advisory endpoint, so a response
+// saved from it can be used offline
+func LoadAdvisories(dbPath string) (map[string][]Advisory, error) {
+	content, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory database: %w", err)
+	}
+	var db map[string][]Advisory
+	if err := json.Unmarshal(content, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse advisory database %s: %w", dbPath, err)
+	}
+	return db, nil
+}
+
+// Vulnerability is an advisory and the installed packages it applies to
+type Vulnerability struct {
+	Advisory
+	Name string
+	// Versions and lock style paths of the affected packages
+	Installed []string
+}
+
+// Audit checks every package installed in node_modules against the
+// advisory database at dbPath and prints what it finds, most severe first.
+// It returns the vulnerabilities at level or above.
+func (pm *PackageManager) Audit(w io.Writer, dbPath, level string) ([]Vulnerability, error) {
+	if level != "" && severityIndex(level) < 0 {
+		return nil, fmt.Errorf("invalid audit level %q, expected one of %s", level, strings.Join(severities, ", "))
+	}
+
+	db, err := LoadAdvisories(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := readInstalled(".", pm.extractedPath, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]*Vulnerability)
+	var walk func(pkg *installedPackage)
+	walk = func(pkg *installedPackage) {
+		for _, name := range sortedNames(pkg.children) {
+			child := pkg.children[name]
+			// Workspaces are the project's own code; what they install is not
+			if child.link == "" {
+				for _, advisory := range db[child.Name] {
+					if !satisfies(child.Version, advisory.VulnerableVersions) {
+						continue
+					}
+					key := child.Name + "\x00" + string(advisory.ID)
+					if found[key] == nil {
+						found[key] = &Vulnerability{Advisory: advisory, Name: child.Name}
+					}
+					found[key].Installed = append(found[key].Installed, child.Version+" "+child.path)
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+
+	all := make([]Vulnerability, 0, len(found))
+	for _, v := range found {
+		all = append(all, *v)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if ri, rj := severityRank(all[i].Severity), severityRank(all[j].Severity); ri != rj {
+			return ri > rj
+		}
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].ID < all[j].ID
+	})
+
+	counts := make(map[string]int)
+	var failing []Vulnerability
+	for _, v := range all {
+		fmt.Fprintf(w, "%s  %s\n", v.Name, v.VulnerableVersions)
+		fmt.Fprintf(w, "Severity: %s\n", v.Severity)
+		fmt.Fprintf(w, "%s - %s\n", v.Title, v.URL)
+		for _, installed := range v.Installed {
+			fmt.Fprintf(w, "  %s\n", installed)
+		}
+		fmt.Fprintln(w)
+
+		counts[severities[severityRank(v.Severity)]]++
+		if severityRank(v.Severity) >= severityRank(level) {
+			failing = append(failing, v)
+		}
+	}
+
+	if len(all) == 0 {
+		fmt.Fprintln(w, "found 0 vulnerabilities")
+		return nil, nil
+	}
+	var parts []string
+	for i := len(severities) - 1; i >= 0; i-- {
+		if n := counts[severities[i]]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, severities[i]))
+		}
+	}
+	fmt.Fprintf(w, "%d vulnerabilities (%s)\n", len(all), strings.Join(parts, ", "))
+
+	return failing, nil
+}
@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"bytes"
+	"npm-packager/npmrc"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	db := `{
+		"lodash": [
+			{"id": 1523, "title": "Prototype Pollution", "url": "https://example.com/1523", "severity": "high", "vulnerable_versions": "<4.17.21"},
+			{"id": "GHSA-fixed", "title": "Fixed long ago", "url": "https://example.com/old", "severity": "critical", "vulnerable_versions": "<1.0.0"}
+		],
+		"minimist": [
+			{"id": 1179, "title": "Prototype Pollution", "url": "https://example.com/1179", "severity": "moderate", "vulnerable_versions": ">=1.0.0 <1.2.6"}
+		]
+	}`
+
+	testCases := []struct {
+		name        string
+		db          string
+		level       string
+		expectError bool
+		failing     []string
+		output      []string
+	}{
+		{
+			name:    "reports vulnerable installed versions, most severe first",
+			db:      db,
+			level:   "low",
+			failing: []string{"lodash", "minimist"},
+			output: []string{
+				"lodash  <4.17.21\nSeverity: high\nPrototype Pollution - https://example.com/1523\n  4.17.20 node_modules/lodash\n",
+				"minimist  >=1.0.0 <1.2.6\nSeverity: moderate\n",
+				"  1.2.0 node_modules/lodash/node_modules/minimist\n",
+				"2 vulnerabilities (1 high, 1 moderate)",
+			},
+		},
+		{
+			name:    "fails only at the audit level or above",
+			db:      db,
+			level:   "high",
+			failing: []string{"lodash"},
+		},
+		{
+			name:    "finds nothing in a clean tree",
+			db:      `{"lodash": [{"id": 1, "severity": "low", "vulnerable_versions": "<1.0.0"}]}`,
+			level:   "low",
+			failing: nil,
+			output:  []string{"found 0 vulnerabilities"},
+		},
+		{
+			name:        "rejects an unknown level",
+			db:          db,
+			level:       "severe",
+			expectError: true,
+		},
+		{
+			name:        "rejects a database it cannot parse",
+			db:          `[]`,
+			level:       "low",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "lodash", version: "4.17.20", dependencies: map[string]string{"minimist": "^1.0.0"}},
+				fakePackage{name: "minimist", version: "1.2.0"},
+				fakePackage{name: "minimist", version: "2.0.0"},
+			)
+			pm, tmpDir, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			// minimist 2 at the top puts lodash's 1.2.0 below it
+			installProject(t, pm, `{"dependencies":{"lodash":"^4.0.0","minimist":"^2.0.0"}}`)
+
+			dbPath := filepath.Join(tmpDir, "advisories.json")
+			assert.NoError(t, os.WriteFile(dbPath, []byte(tc.db), 0644))
+
+			var out bytes.Buffer
+			failing, err := pm.Audit(&out, dbPath, tc.level)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			var names []string
+			for _, v := range failing {
+				names = append(names, v.Name)
+			}
+			assert.Equal(t, tc.failing, names)
+			for _, expected := range tc.output {
+				assert.Contains(t, out.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package manager
+
+import (
+	"fmt"
+	"npm-packager/npmrc"
+	"npm-packager/packagejson"
+	"npm-packager/workspace"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resolution is what resolving a dependency tree found: the version each
+// requested range of a package resolved to, and the lock entry of every
+// version fetched, with the packages bundled in it
+type resolution struct {
+	// What each name@range resolved to
+	versions map[string]resolvedRange
+	// Lock entry by cache key, see cacheKey
+	packages map[string]packagejson.PackageItem
+	// Bundled packages by cache key of the package shipping them
+	bundled map[string]map[string]packagejson.PackageItem
+	// Tells packages of the registries from other tarball URLs
+	registry *npmrc.Config
+}
+
+// resolvedRange is the version a range resolved to and the cache key of
+// the package fetched for it, which tells a git or file: source from a
+// published version of the same number
+type resolvedRange struct {
+	version string
+	key     string
+}
+
+// itemKey is the cache key of a lock entry
+func (r *resolution) itemKey(item packagejson.PackageItem) string {
+	return cacheKey(r.registry, item.Name, item.Version, item.Resolved)
+}
+
+func newResolution(registry *npmrc.Config) *resolution {
+	return &resolution{
+		versions: make(map[string]resolvedRange),
+		packages: make(map[string]packagejson.PackageItem),
+		bundled:  make(map[string]map[string]packagejson.PackageItem),
+		registry: registry,
+	}
+}
+
+// versionOf returns what the range of name resolved to, and whether that
+// package was fetched
+func (r *resolution) versionOf(name, spec string) (resolvedRange, bool) {
+	resolved, ok := r.versions[name+"@"+spec]
+	if !ok {
+		return resolvedRange{}, false
+	}
+	_, fetched := r.packages[resolved.key]
+	return resolved, fetched
+}
+
+// highestSatisfying returns the cache key of the highest fetched version of
+// name in the range, or "" if none is
+func (r *resolution) highestSatisfying(name, spec string) string {
+	var best string
+	var bestVersion semVersion
+	for key, item := range r.packages {
+		if item.Name != name || !satisfies(item.Version, spec) {
+			continue
+		}
+		v, err := parseVersion(item.Version)
+		if err != nil {
+			continue
+		}
+		// Equal versions from different sources are ordered by key, so
+		// the pick does not depend on map order
+		if cmp := v.compare(bestVersion); best == "" || cmp > 0 || cmp == 0 && key < best {
+			best, bestVersion = key, v
+		}
+	}
+	return best
+}
+
+// resolveTo records that the range of name resolved to the fetched package
+// with key
+func (r *resolution) resolveTo(name, spec, key string) {
+	r.versions[name+"@"+spec] = resolvedRange{version: r.packages[key].Version, key: key}
+}
+
+// dependencyEdge is a dependency a placed package asks for
+type dependencyEdge struct {
+	name string
+	spec string
+	peer bool
+	// Optional peers are only checked, never installed
+	optional bool
+}
+
+// edgesOf returns the dependencies of item to place, in name order.
+// Bundled ones are not among them: they come with the package.
+func edgesOf(item packagejson.PackageItem) []dependencyEdge {
+	bundled := make(map[string]bool, len(item.BundleDependencies))
+	for _, name := range item.BundleDependencies {
+		bundled[name] = true
+	}
+
+	var edges []dependencyEdge
+	for name, spec := range item.Dependencies {
+		if !bundled[name] {
+			edges = append(edges, dependencyEdge{name: name, spec: spec})
+		}
+	}
+	for name, spec := range item.OptionalDependencies {
+		edges = append(edges, dependencyEdge{name: name, spec: spec})
+	}
+	for name, spec := range item.PeerDependencies {
+		edges = append(edges, dependencyEdge{name: name, spec: spec, peer: true, optional: item.PeerDependenciesMeta[name].Optional})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].name != edges[j].name {
+			return edges[i].name < edges[j].name
+		}
+		return !edges[i].peer && edges[j].peer
+	})
+	return edges
+}
+
+// childPath is the lock path of name in the node_modules of the package at
+// parent, "" being the root
+func childPath(parent, name string) string {
+	if parent == "" {
+		return "node_modules/" + name
+	}
+	return parent + "/node_modules/" + name
+}
+
+// parentPath is the lock path of the package whose node_modules holds the
+// package at pkgPath, "" for the root
+func parentPath(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/node_modules/"); i >= 0 {
+		return pkgPath[:i]
+	}
+	return ""
+}
+
+// inSubtree reports whether pkgPath is the package at parent or below it
+func inSubtree(pkgPath, parent string) bool {
+	return parent == "" || pkgPath == parent || strings.HasPrefix(pkgPath, parent+"/node_modules/")
+}
+
+// placedEdge records the package a dependency of the package at from
+// resolved to
+type placedEdge struct {
+	from string
+	path string
+}
+
+// layout is a node_modules tree being built by hoist
+type layout struct {
+	res      *resolution
+	packages map[string]packagejson.PackageItem
+	// Placed dependencies by name
+	edges map[string][]placedEdge
+}
+
+// hoist lays the resolved packages out in node_modules the way npm 7 does.
+// Going breadth first from the root and through each package's
+// dependencies in name order, a dependency is shared when a package on the
+// way up to the root already satisfies its range; otherwise it goes as high
+// as it can without clashing with another version of it, or hiding the one
+// a package below already uses. Peers go beside the package needing them.
+// The same resolution always gives the same layout.
+func hoist(root packagejson.PackageItem, workspaces []packagejson.PackageItem, res *resolution) map[string]packagejson.PackageItem {
+	l := &layout{
+		res:      res,
+		packages: make(map[string]packagejson.PackageItem),
+		edges:    make(map[string][]placedEdge),
+	}
+
+	type node struct {
+		path string
+		item packagejson.PackageItem
+	}
+	queue := []node{{"", root}}
+
+	// Workspaces are linked at the top, and their dependencies placed from there
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	for _, ws := range workspaces {
+		link := ws
+		link.Dependencies = nil
+		pkgPath := childPath("", ws.Name)
+		l.packages[pkgPath] = link
+		queue = append(queue, node{pkgPath, ws})
+	}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, edge := range edgesOf(n.item) {
+			if placed, ok := l.place(n.path, edge); ok {
+				queue = append(queue, node{placed, l.packages[placed]})
+			}
+		}
+	}
+	return l.packages
+}
+
+// place finds or makes the package a dependency of the package at from
+// resolves to, returning the path of a package it added
+func (l *layout) place(from string, edge dependencyEdge) (string, bool) {
+	start := from
+	if edge.peer {
+		start = parentPath(from)
+	}
+	resolvedTo, resolved := l.res.versionOf(edge.name, edge.spec)
+	version := resolvedTo.version
+
+	// Where it could go, lowest first
+	var candidates []string
+	for dir := start; ; dir = parentPath(dir) {
+		pkgPath := childPath(dir, edge.name)
+		if existing, ok := l.packages[pkgPath]; ok {
+			// A git or file: source only serves the range it was fetched for
+			key := l.res.itemKey(existing)
+			published := key == existing.Name+"@"+existing.Version
+			if key == resolvedTo.key || published && satisfies(existing.Version, edge.spec) || existing.Link && workspace.IsProtocol(edge.spec) {
+				l.record(from, edge.name, pkgPath)
+				return "", false
+			}
+			// A peer has to be the version its dependent's parent sees
+			if edge.peer || len(candidates) == 0 {
+				fmt.Printf("Warning: %s requires %s@%s but %s is installed\n", l.label(from), edge.name, edge.spec, existing.Version)
+				l.record(from, edge.name, pkgPath)
+				return "", false
+			}
+			break
+		}
+		candidates = append(candidates, pkgPath)
+		if dir == "" {
+			break
+		}
+	}
+
+	// Failed optional dependencies, optional peers nothing provides and
+	// linked workspaces have nothing to place
+	if !resolved || edge.optional {
+		return "", false
+	}
+
+	target := candidates[0]
+	for i := len(candidates) - 1; i > 0; i-- {
+		if !l.shadows(candidates[i], edge.name, version) {
+			target = candidates[i]
+			break
+		}
+	}
+
+	l.packages[target] = l.res.packages[resolvedTo.key]
+	for name, item := range l.res.bundled[resolvedTo.key] {
+		l.packages[childPath(target, name)] = item
+	}
+	l.record(from, edge.name, target)
+	return target, true
+}
+
+func (l *layout) record(from, name, pkgPath string) {
+	l.edges[name] = append(l.edges[name], placedEdge{from: from, path: pkgPath})
+}
+
+// shadows reports whether version of name at pkgPath would hide another
+// version a package below already resolved from higher up
+func (l *layout) shadows(pkgPath, name, version string) bool {
+	parent := parentPath(pkgPath)
+	for _, e := range l.edges[name] {
+		if inSubtree(e.from, parent) && !inSubtree(parentPath(e.path), parent) && l.packages[e.path].Version != version {
+			return true
+		}
+	}
+	return false
+}
+
+// label names the package at pkgPath in messages
+func (l *layout) label(pkgPath string) string {
+	if pkgPath == "" {
+		return "the root project"
+	}
+	item := l.packages[pkgPath]
+	return item.Name + "@" + item.Version
+}
+
+// rootItem is the root package as hoist places it: its dependencies and
+// devDependencies as recorded in the lock
+func rootItem(lock *packagejson.PackageLock) packagejson.PackageItem {
+	dependencies := make(map[string]string, len(lock.Dependencies)+len(lock.DevDependencies))
+	for name, version := range lock.DevDependencies {
+		dependencies[name] = version
+	}
+	for name, version := range lock.Dependencies {
+		dependencies[name] = version
+	}
+	return packagejson.PackageItem{Dependencies: dependencies, OptionalDependencies: lock.OptionalDependencies}
+}
+
+// Dedupe lays node_modules out again from the packages the lock already
+// records, so every range uses the highest version recorded for it and is
+// shared wherever its dependents allow. It rewrites the lock and removes
+// what moved; InstallFromCache then puts the new layout in place.
+func (pm *PackageManager) Dedupe() error {
+	lockFileName := pm.packageJsonParse.LockFileName
+	lock, err := pm.packageJsonParse.ParseLockFile()
+	if err != nil {
+		return fmt.Errorf("dedupe needs a valid %s, run go-npm i to create it: %w", lockFileName, err)
+	}
+
+	res := newResolution(pm.registry)
+	var workspaces []packagejson.PackageItem
+	for pkgPath, item := range lock.Packages {
+		switch {
+		case item.Link:
+			ws := item
+			ws.Dependencies = lock.Packages[item.Resolved].Dependencies
+			workspaces = append(workspaces, ws)
+		case !strings.HasPrefix(pkgPath, "node_modules/"):
+		case item.InBundle:
+			key := res.itemKey(lock.Packages[parentPath(pkgPath)])
+			if res.bundled[key] == nil {
+				res.bundled[key] = make(map[string]packagejson.PackageItem)
+			}
+			res.bundled[key][item.Name] = item
+		default:
+			item.Dev, item.Optional, item.Peer = false, false, false
+			res.packages[res.itemKey(item)] = item
+		}
+	}
+
+	root := rootItem(lock)
+	resolveRanges := func(from string, item packagejson.PackageItem) {
+		for _, edge := range edgesOf(item) {
+			key := edge.name + "@" + edge.spec
+			if _, done := res.versions[key]; done {
+				continue
+			}
+			if best := res.highestSatisfying(edge.name, edge.spec); best != "" {
+				res.resolveTo(edge.name, edge.spec, best)
+			} else if found := lockPathOf(lock, from, edge.name); found != "" {
+				// Tags, git and file: sources keep what they had
+				item := lock.Packages[found]
+				res.versions[key] = resolvedRange{version: item.Version, key: res.itemKey(item)}
+			}
+		}
+	}
+	resolveRanges("", root)
+	for _, ws := range workspaces {
+		resolveRanges(childPath("", ws.Name), ws)
+	}
+	for pkgPath, item := range lock.Packages {
+		if strings.HasPrefix(pkgPath, "node_modules/") && !item.InBundle && !item.Link {
+			resolveRanges(pkgPath, item)
+		}
+	}
+
+	packages := hoist(root, workspaces, res)
+	for pkgPath, item := range lock.Packages {
+		if !strings.HasPrefix(pkgPath, "node_modules/") {
+			packages[pkgPath] = item
+		}
+	}
+
+	// What moved or went away is removed; the new layout is installed after
+	if err := pm.removeChanged(lock.Packages, packages); err != nil {
+		return err
+	}
+	installed := 0
+	for pkgPath, item := range lock.Packages {
+		if strings.HasPrefix(pkgPath, "node_modules/") && !item.InBundle {
+			installed++
+		}
+	}
+	for pkgPath, item := range packages {
+		if strings.HasPrefix(pkgPath, "node_modules/") && !item.InBundle {
+			installed--
+		}
+	}
+	fmt.Printf("Removed %d duplicate packages\n", installed)
+
+	lock.Packages = packages
+	markDependencyFlags(lock)
+	if err := pm.packageJsonParse.CreateLockFile(lock, false); err != nil {
+		return err
+	}
+	pm.packageLock = lock
+
+	return nil
+}
+
+// removeChanged removes from node_modules the packages of before that
+// after does not have at the same path in the same version, so
+// InstallFromCache puts the new layout in place
+func (pm *PackageManager) removeChanged(before, after map[string]packagejson.PackageItem) error {
+	for pkgPath, item := range before {
+		if !strings.HasPrefix(pkgPath, "node_modules/") || item.InBundle {
+			continue
+		}
+		if kept, ok := after[pkgPath]; ok && kept.Version == item.Version && kept.Link == item.Link {
+			continue
+		}
+		target := filepath.Join(pm.extractedPath, filepath.FromSlash(strings.TrimPrefix(pkgPath, "node_modules/")))
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+	}
+	return nil
+}
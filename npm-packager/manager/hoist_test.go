@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"npm-packager/npmrc"
+	"npm-packager/packagejson"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testResolution resolves every range the root, the workspaces and the
+// packages ask for to the highest of the packages in it
+func testResolution(root packagejson.PackageItem, workspaces []packagejson.PackageItem, packages ...packagejson.PackageItem) *resolution {
+	res := newResolution(npmrc.New(npmRegistryURL))
+	for _, item := range packages {
+		res.packages[res.itemKey(item)] = item
+	}
+	for _, item := range append(append([]packagejson.PackageItem{root}, workspaces...), packages...) {
+		for _, edge := range edgesOf(item) {
+			if best := res.highestSatisfying(edge.name, edge.spec); best != "" {
+				res.resolveTo(edge.name, edge.spec, best)
+			}
+		}
+	}
+	return res
+}
+
+func TestHoist(t *testing.T) {
+	pkg := func(name, version string, dependencies map[string]string) packagejson.PackageItem {
+		return packagejson.PackageItem{Name: name, Version: version, Dependencies: dependencies}
+	}
+
+	testCases := []struct {
+		name       string
+		root       packagejson.PackageItem
+		workspaces []packagejson.PackageItem
+		packages   []packagejson.PackageItem
+		bundled    map[string]map[string]packagejson.PackageItem
+		expected   map[string]string
+	}{
+		{
+			name: "shares what satisfies a range and nests conflicting versions",
+			root: pkg("", "", map[string]string{"a": "^1.0.0", "b": "^1.0.0", "c": "^1.0.0"}),
+			packages: []packagejson.PackageItem{
+				pkg("a", "1.0.0", map[string]string{"c": "^2.0.0"}),
+				pkg("b", "1.0.0", map[string]string{"c": "^1.0.0", "d": "^1.0.0"}),
+				pkg("c", "1.0.0", nil),
+				pkg("c", "2.0.0", nil),
+				pkg("d", "1.0.0", nil),
+			},
+			expected: map[string]string{
+				"node_modules/a":                "1.0.0",
+				"node_modules/a/node_modules/c": "2.0.0",
+				"node_modules/b":                "1.0.0",
+				"node_modules/c":                "1.0.0",
+				"node_modules/d":                "1.0.0",
+			},
+		},
+		{
+			name: "does not hide the version a package below already uses",
+			root: pkg("", "", map[string]string{"a": "^1.0.0", "b": "^2.0.0", "q": "^2.0.0", "x": "^1.0.0"}),
+			packages: []packagejson.PackageItem{
+				pkg("a", "1.0.0", map[string]string{"b": "^1.0.0", "q": "^1.0.0"}),
+				pkg("b", "1.0.0", map[string]string{"x": "^1.0.0"}),
+				pkg("b", "2.0.0", nil),
+				pkg("q", "1.0.0", map[string]string{"x": "^2.0.0"}),
+				pkg("q", "2.0.0", nil),
+				pkg("x", "1.0.0", nil),
+				pkg("x", "2.0.0", nil),
+			},
+			expected: map[string]string{
+				"node_modules/a":                               "1.0.0",
+				"node_modules/a/node_modules/b":                "1.0.0",
+				"node_modules/a/node_modules/q":                "1.0.0",
+				"node_modules/a/node_modules/q/node_modules/x": "2.0.0",
+				"node_modules/b":                               "2.0.0",
+				"node_modules/q":                               "2.0.0",
+				"node_modules/x":                               "1.0.0",
+			},
+		},
+		{
+			name: "places peers beside their dependent and keeps bundled packages",
+			root: pkg("", "", map[string]string{"a": "^1.0.0"}),
+			packages: []packagejson.PackageItem{
+				pkg("a", "1.0.0", map[string]string{"plugin": "^1.0.0", "host": "^2.0.0"}),
+				{
+					Name:               "plugin",
+					Version:            "1.0.0",
+					Dependencies:       map[string]string{"vendored": "^1.0.0"},
+					BundleDependencies: []string{"vendored"},
+					PeerDependencies:   map[string]string{"host": "^1.0.0", "extra": "^1.0.0"},
+					PeerDependenciesMeta: map[string]packagejson.PeerDependencyMeta{
+						"extra": {Optional: true},
+					},
+				},
+				pkg("host", "1.0.0", nil),
+				pkg("host", "2.0.0", nil),
+				pkg("extra", "1.0.0", nil),
+			},
+			bundled: map[string]map[string]packagejson.PackageItem{
+				"plugin@1.0.0": {"vendored": {Name: "vendored", Version: "1.3.0", InBundle: true}},
+			},
+			expected: map[string]string{
+				"node_modules/a":                            "1.0.0",
+				"node_modules/host":                         "2.0.0",
+				"node_modules/plugin":                       "1.0.0",
+				"node_modules/plugin/node_modules/vendored": "1.3.0",
+			},
+		},
+		{
+			name: "places workspace dependencies from their links",
+			root: pkg("", "", nil),
+			workspaces: []packagejson.PackageItem{
+				{Name: "app", Version: "0.1.0", Resolved: "packages/app", Link: true, Dependencies: map[string]string{"lib": "^1.0.0", "api": "workspace:*"}},
+				{Name: "api", Version: "0.1.0", Resolved: "packages/api", Link: true, Dependencies: map[string]string{"lib": "^2.0.0"}},
+			},
+			packages: []packagejson.PackageItem{
+				pkg("lib", "1.0.0", nil),
+				pkg("lib", "2.0.0", nil),
+			},
+			expected: map[string]string{
+				"node_modules/api":                  "0.1.0",
+				"node_modules/app":                  "0.1.0",
+				"node_modules/app/node_modules/lib": "1.0.0",
+				"node_modules/lib":                  "2.0.0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := testResolution(tc.root, tc.workspaces, tc.packages...)
+			if tc.bundled != nil {
+				res.bundled = tc.bundled
+			}
+
+			packages := hoist(tc.root, tc.workspaces, res)
+
+			versions := make(map[string]string, len(packages))
+			for pkgPath, item := range packages {
+				versions[pkgPath] = item.Version
+			}
+			assert.Equal(t, tc.expected, versions)
+		})
+	}
+}
+
+func TestFetchToCache_DeterministicLayout(t *testing.T) {
+	r := newFakeRegistry(t, "",
+		fakePackage{name: "a", version: "1.0.0", dependencies: map[string]string{"helper": "^1.0.0"}},
+		fakePackage{name: "b", version: "1.0.0", dependencies: map[string]string{"helper": "^2.0.0"}},
+		fakePackage{name: "c", version: "1.0.0", dependencies: map[string]string{"helper": "^2.0.0", "a": "^1.0.0"}},
+		fakePackage{name: "helper", version: "1.0.0"},
+		fakePackage{name: "helper", version: "2.0.0"},
+	)
+
+	expected := map[string]string{
+		"node_modules/a":                     "1.0.0",
+		"node_modules/b":                     "1.0.0",
+		"node_modules/b/node_modules/helper": "2.0.0",
+		"node_modules/c":                     "1.0.0",
+		"node_modules/c/node_modules/helper": "2.0.0",
+		"node_modules/helper":                "1.0.0",
+	}
+
+	for i := 0; i < 5; i++ {
+		func() {
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			err := pm.fetchToCache(packagejson.PackageJSON{
+				Dependencies: map[string]string{"a": "^1.0.0", "b": "^1.0.0", "c": "^1.0.0"},
+			}, false)
+			assert.NoError(t, err)
+
+			versions := make(map[string]string)
+			for pkgPath, item := range pm.packageLock.Packages {
+				versions[pkgPath] = item.Version
+			}
+			assert.Equal(t, expected, versions)
+		}()
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	a := fakePackage{name: "a", version: "1.0.0", dependencies: map[string]string{"helper": "^1.0.0"}}
+	b := fakePackage{name: "b", version: "1.0.0", dependencies: map[string]string{"helper": "^1.1.0"}}
+	oldHelper := fakePackage{name: "helper", version: "1.0.0"}
+	newHelper := fakePackage{name: "helper", version: "1.2.0"}
+	r := newFakeRegistry(t, "", a, b, oldHelper, newHelper)
+
+	pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+	defer os.Chdir(origDir)
+
+	entry := func(pkg fakePackage) packagejson.PackageItem {
+		return packagejson.PackageItem{
+			Name:         pkg.name,
+			Version:      pkg.version,
+			Resolved:     r.URL + fakeTarballPath(pkg),
+			Integrity:    r.integrity[fakeTarballPath(pkg)],
+			Dependencies: pkg.dependencies,
+		}
+	}
+
+	// As an older install left it: b got its own, newer helper
+	lock := &packagejson.PackageLock{
+		Dependencies: map[string]string{"a": "^1.0.0", "b": "^1.0.0"},
+		Packages: map[string]packagejson.PackageItem{
+			"node_modules/a":                     entry(a),
+			"node_modules/b":                     entry(b),
+			"node_modules/helper":                entry(oldHelper),
+			"node_modules/b/node_modules/helper": entry(newHelper),
+		},
+	}
+	assert.NoError(t, pm.packageJsonParse.CreateLockFile(lock, false))
+	pm.packageLock = lock
+	assert.NoError(t, pm.InstallFromCache())
+	assert.DirExists(t, filepath.Join("node_modules", "b", "node_modules", "helper"))
+
+	assert.NoError(t, pm.Dedupe())
+	assert.NoError(t, pm.InstallFromCache())
+
+	deduped, err := pm.packageJsonParse.ParseLockFile()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node_modules/a", "node_modules/b", "node_modules/helper"}, sortedKeys(deduped.Packages))
+	assert.Equal(t, "1.2.0", deduped.Packages["node_modules/helper"].Version)
+
+	assert.NoDirExists(t, filepath.Join("node_modules", "b", "node_modules", "helper"))
+	content, err := os.ReadFile(filepath.Join("node_modules", "helper", "package.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"version":"1.2.0"`)
+}
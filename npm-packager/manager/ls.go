@@ -0,0 +1,314 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"npm-packager/packagejson"
+	"npm-packager/workspace"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// installedPackage is a package found in node_modules, or the project itself
+type installedPackage struct {
+	Name                 string                                    `json:"name"`
+	Version              string                                    `json:"version"`
+	Dependencies         map[string]string                         `json:"dependencies"`
+	DevDependencies      map[string]string                         `json:"devDependencies"`
+	OptionalDependencies map[string]string                         `json:"optionalDependencies"`
+	PeerDependencies     map[string]string                         `json:"peerDependencies"`
+	PeerDependenciesMeta map[string]packagejson.PeerDependencyMeta `json:"peerDependenciesMeta"`
+
+	// Lock style path: node_modules/a/node_modules/b, "" for the project
+	path string
+	// Where a symlinked package, a workspace, points
+	link     string
+	parent   *installedPackage
+	children map[string]*installedPackage
+}
+
+// installedEdge is a dependency of an installed package and what it finds
+type installedEdge struct {
+	name     string
+	spec     string
+	optional bool
+	peer     bool
+	found    *installedPackage
+}
+
+// readInstalled reads the package in dir and, recursively, what is
+// installed in nodeModules below it
+func readInstalled(dir, nodeModules, pkgPath string, parent *installedPackage) (*installedPackage, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	pkg := &installedPackage{path: pkgPath, parent: parent, children: make(map[string]*installedPackage)}
+	if err := json.Unmarshal(content, pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+
+	entries, err := os.ReadDir(nodeModules)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !strings.HasPrefix(entry.Name(), "@") {
+			names = append(names, entry.Name())
+			continue
+		}
+		scoped, err := os.ReadDir(filepath.Join(nodeModules, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range scoped {
+			names = append(names, entry.Name()+"/"+s.Name())
+		}
+	}
+
+	for _, name := range names {
+		childDir := filepath.Join(nodeModules, filepath.FromSlash(name))
+		if _, err := os.Stat(filepath.Join(childDir, "package.json")); err != nil {
+			continue
+		}
+		child, err := readInstalled(childDir, filepath.Join(childDir, "node_modules"), childPath(pkgPath, name), pkg)
+		if err != nil {
+			return nil, err
+		}
+		if target, err := os.Readlink(childDir); err == nil {
+			child.link = filepath.ToSlash(target)
+		}
+		pkg.children[name] = child
+	}
+	return pkg, nil
+}
+
+// resolve finds name from pkg the way node does, in its node_modules and
+// then in those of the packages above it
+func (pkg *installedPackage) resolve(name string) *installedPackage {
+	for p := pkg; p != nil; p = p.parent {
+		if child, ok := p.children[name]; ok {
+			return child
+		}
+	}
+	return nil
+}
+
+// isRootLike is true for the project and its linked workspaces, whose
+// devDependencies are installed too
+func (pkg *installedPackage) isRootLike() bool {
+	return pkg.parent == nil || pkg.link != "" && pkg.parent.parent == nil
+}
+
+// edges are the dependencies of pkg, in name order, each with what it finds
+func (pkg *installedPackage) edges() []installedEdge {
+	var edges []installedEdge
+	add := func(deps map[string]string, optional, peer bool) {
+		for name, spec := range deps {
+			edges = append(edges, installedEdge{
+				name:     name,
+				spec:     spec,
+				optional: optional || peer && pkg.PeerDependenciesMeta[name].Optional,
+				peer:     peer,
+				found:    pkg.resolve(name),
+			})
+		}
+	}
+	add(pkg.Dependencies, false, false)
+	if pkg.isRootLike() {
+		add(pkg.DevDependencies, false, false)
+	}
+	add(pkg.OptionalDependencies, true, false)
+	add(pkg.PeerDependencies, false, true)
+
+	sort.Slice(edges, func(i, j int) bool { return edges[i].name < edges[j].name })
+	// A name listed as both keeps its first kind
+	unique := edges[:0]
+	for i, edge := range edges {
+		if i == 0 || edge.name != edges[i-1].name {
+			unique = append(unique, edge)
+		}
+	}
+	return unique
+}
+
+// invalid reports whether what edge found does not satisfy its range
+func (edge installedEdge) invalid() bool {
+	if edge.found == nil || workspace.IsProtocol(edge.spec) {
+		return false
+	}
+	if _, err := parseRange(edge.spec); err != nil {
+		// Tags, urls and other specs ls cannot check
+		return false
+	}
+	return !satisfies(edge.found.Version, edge.spec)
+}
+
+func (pkg *installedPackage) label() string {
+	if pkg.parent == nil {
+		return "the root project"
+	}
+	return pkg.path
+}
+
+// List prints the tree installed in node_modules the way npm ls does, down
+// to depth levels below the project's own dependencies, and returns the
+// problems it found anywhere in it: missing and invalid dependencies, and
+// extraneous packages nothing depends on
+func (pm *PackageManager) List(w io.Writer, depth int) ([]string, error) {
+	root, err := readInstalled(".", pm.extractedPath, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything the project or its workspaces need, directly or not
+	required := make(map[*installedPackage]bool)
+	var problems []string
+	var walk func(pkg *installedPackage)
+	walk = func(pkg *installedPackage) {
+		if required[pkg] {
+			return
+		}
+		required[pkg] = true
+		for _, edge := range pkg.edges() {
+			switch {
+			case edge.found == nil && !edge.optional:
+				problems = append(problems, fmt.Sprintf("missing: %s@%s, required by %s", edge.name, edge.spec, pkg.label()))
+			case edge.invalid():
+				problems = append(problems, fmt.Sprintf("invalid: %s@%s %s, %s wants %s", edge.name, edge.found.Version, edge.found.path, pkg.label(), edge.spec))
+			}
+			if edge.found != nil {
+				walk(edge.found)
+			}
+		}
+	}
+	walk(root)
+	for _, name := range sortedNames(root.children) {
+		if child := root.children[name]; child.link != "" {
+			walk(child)
+		}
+	}
+
+	var extraneous func(pkg *installedPackage)
+	extraneous = func(pkg *installedPackage) {
+		for _, name := range sortedNames(pkg.children) {
+			child := pkg.children[name]
+			if !required[child] {
+				problems = append(problems, fmt.Sprintf("extraneous: %s@%s %s", child.Name, child.Version, child.path))
+			}
+			extraneous(child)
+		}
+	}
+	extraneous(root)
+
+	absRoot, err := filepath.Abs(".")
+	if err != nil {
+		absRoot = "."
+	}
+	fmt.Fprintf(w, "%s@%s %s\n", root.Name, root.Version, absRoot)
+	pm.printInstalled(w, root, required, "", 0, depth)
+
+	return problems, nil
+}
+
+// printInstalled prints the dependencies of pkg, then the other packages in
+// its node_modules, as the branches of a tree
+func (pm *PackageManager) printInstalled(w io.Writer, pkg *installedPackage, required map[*installedPackage]bool, prefix string, level, depth int) {
+	type line struct {
+		name string
+		text string
+		next *installedPackage
+	}
+	var lines []line
+
+	listed := make(map[string]bool)
+	for _, edge := range pkg.edges() {
+		listed[edge.name] = true
+		switch {
+		case edge.found == nil && edge.optional:
+			continue
+		case edge.found == nil && edge.peer:
+			lines = append(lines, line{name: edge.name, text: "UNMET PEER DEPENDENCY " + edge.name + "@" + edge.spec})
+			continue
+		case edge.found == nil:
+			lines = append(lines, line{name: edge.name, text: "UNMET DEPENDENCY " + edge.name + "@" + edge.spec})
+			continue
+		}
+
+		text := edge.name + "@" + edge.found.Version
+		if edge.found.link != "" {
+			text += " -> " + edge.found.link
+		}
+		if edge.invalid() {
+			text += fmt.Sprintf(" invalid: %q from %s", edge.spec, pkg.label())
+		}
+		var next *installedPackage
+		if edge.found.parent == pkg {
+			next = edge.found
+		} else {
+			text += " deduped"
+		}
+		lines = append(lines, line{name: edge.name, text: text, next: next})
+	}
+	// Then what is installed here for a package below, or for nothing
+	for _, name := range sortedNames(pkg.children) {
+		child := pkg.children[name]
+		if listed[name] {
+			continue
+		}
+		text := name + "@" + child.Version
+		if child.link != "" {
+			text += " -> " + child.link
+		}
+		if !required[child] {
+			text += " extraneous"
+		}
+		lines = append(lines, line{name: name, text: text, next: child})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].name < lines[j].name })
+
+	for i, l := range lines {
+		last := i == len(lines)-1
+		branch, indent := "├─", "│ "
+		if last {
+			branch, indent = "└─", "  "
+		}
+		expand := l.next != nil && level < depth && len(l.next.children)+len(l.next.edges()) > 0
+		if expand {
+			fmt.Fprintf(w, "%s%s┬ %s\n", prefix, branch, l.text)
+			pm.printInstalled(w, l.next, required, prefix+indent, level+1, depth)
+		} else {
+			fmt.Fprintf(w, "%s%s─ %s\n", prefix, branch, l.text)
+		}
+	}
+}
+
+func sortedNames(children map[string]*installedPackage) []string {
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
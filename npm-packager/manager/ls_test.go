@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	files := map[string]string{
+		"package.json":                                  `{"name":"proj","version":"1.0.0","dependencies":{"a":"^1.0.0","missing":"^1.0.0"},"devDependencies":{"b":"^2.0.0"},"optionalDependencies":{"gone":"^1.0.0"}}`,
+		"node_modules/a/package.json":                   `{"name":"a","version":"1.0.0","dependencies":{"c":"^1.0.0","d":"^1.0.0"}}`,
+		"node_modules/a/node_modules/d/package.json":    `{"name":"d","version":"1.1.0"}`,
+		"node_modules/b/package.json":                   `{"name":"b","version":"1.5.0"}`,
+		"node_modules/c/package.json":                   `{"name":"c","version":"1.0.0","peerDependencies":{"host":"^1.0.0"}}`,
+		"node_modules/@scope/extra/package.json":        `{"name":"@scope/extra","version":"3.0.0"}`,
+		"node_modules/.bin/ignored/package.json":        `{"name":"ignored","version":"1.0.0"}`,
+		"node_modules/a/node_modules/left/package.json": `{"name":"left","version":"1.0.0"}`,
+	}
+
+	testCases := []struct {
+		name     string
+		depth    int
+		expected []string
+	}{
+		{
+			name:  "Direct dependencies only by default",
+			depth: 0,
+			expected: []string{
+				"├── @scope/extra@3.0.0 extraneous",
+				"├── a@1.0.0",
+				`├── b@1.5.0 invalid: "^2.0.0" from the root project`,
+				"├── c@1.0.0",
+				"└── UNMET DEPENDENCY missing@^1.0.0",
+			},
+		},
+		{
+			name:  "Deeper levels",
+			depth: 1,
+			expected: []string{
+				"├── @scope/extra@3.0.0 extraneous",
+				"├─┬ a@1.0.0",
+				"│ ├── c@1.0.0 deduped",
+				"│ ├── d@1.1.0",
+				"│ └── left@1.0.0 extraneous",
+				`├── b@1.5.0 invalid: "^2.0.0" from the root project`,
+				"├─┬ c@1.0.0",
+				"│ └── UNMET PEER DEPENDENCY host@^1.0.0",
+				"└── UNMET DEPENDENCY missing@^1.0.0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pm, tmpDir, origDir := setupTestPackageManager(t)
+			defer os.Chdir(origDir)
+			pm.extractedPath = filepath.Join(tmpDir, "node_modules")
+
+			for name, content := range files {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+				assert.NoError(t, os.WriteFile(name, []byte(content), 0644))
+			}
+
+			var out bytes.Buffer
+			problems, err := pm.List(&out, tc.depth)
+			assert.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			assert.True(t, strings.HasPrefix(lines[0], "proj@1.0.0 "), lines[0])
+			assert.Equal(t, tc.expected, lines[1:])
+
+			assert.Equal(t, []string{
+				"missing: host@^1.0.0, required by node_modules/c",
+				"invalid: b@1.5.0 node_modules/b, the root project wants ^2.0.0",
+				"missing: missing@^1.0.0, required by the root project",
+				"extraneous: @scope/extra@3.0.0 node_modules/@scope/extra",
+				"extraneous: left@1.0.0 node_modules/a/node_modules/left",
+			}, problems)
+		})
+	}
+}
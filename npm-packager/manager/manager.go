@@ -5,20 +5,29 @@ import (
 	"fmt"
 	"npm-packager/binlink"
 	"npm-packager/config"
+	"npm-packager/depspec"
 	"npm-packager/etag"
 	"npm-packager/extractor"
+	"npm-packager/integrity"
+	"npm-packager/lifecycle"
 	"npm-packager/manifest"
+	"npm-packager/npmrc"
 	"npm-packager/packagecopy"
 	"npm-packager/packagejson"
+	"npm-packager/publish"
+	"npm-packager/store"
 	"npm-packager/tarball"
 	"npm-packager/utils"
+	"npm-packager/workspace"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
 
+// npmRegistryURL is the registry used unless .npmrc names another
 const npmRegistryURL = "https://registry.npmjs.org/"
 
 type Job struct {
@@ -40,7 +49,7 @@ type PackageManager struct {
 	processedPackages map[string]packagejson.Dependency
 	configPath        string
 	packagesPath      string
-	Etag              etag.Etag
+	Etag              *etag.Etag
 	isAdd             bool
 	isGlobal          bool
 	config            *config.Config
@@ -50,12 +59,23 @@ type PackageManager struct {
 	tarball           *tarball.Tarball
 	extractor         *extractor.TGZExtractor
 	packageCopy       *packagecopy.PackageCopy
+	store             *store.Store
 	parseJsonManifest *ParseJsonManifest
 	versionInfo       *VersionInfo
 	packageJsonParse  *packagejson.PackageJSONParser
 	binLinker         *binlink.BinLinker
+	registry          *npmrc.Config
+	publisher         *publish.Publisher
+	scripts           *lifecycle.Runner
+	ignoreScripts     bool
+	offline           bool
+	preferOffline     bool
+	workspaces        map[string]workspace.Package
 	downloadMu        sync.Mutex
 	downloadLocks     map[string]*sync.Mutex
+
+	// Versions Update keeps from the lock, by name
+	lockedVersions map[string][]string
 }
 
 type Package struct {
@@ -72,18 +92,27 @@ type Dependencies struct {
 	Manifest          *manifest.Manifest
 	Etag              *etag.Etag
 	Tarball           *tarball.Tarball
+	Downloader        *utils.Downloader
 	Extractor         *extractor.TGZExtractor
 	PackageCopy       *packagecopy.PackageCopy
+	Store             *store.Store
 	ParseJsonManifest *ParseJsonManifest
 	VersionInfo       *VersionInfo
 	PackageJsonParse  *packagejson.PackageJSONParser
 	BinLinker         *binlink.BinLinker
+	Registry          *npmrc.Config
+	Publisher         *publish.Publisher
+	Scripts           *lifecycle.Runner
 }
 
 type QueueItem struct {
 	Dep        packagejson.Dependency
 	ParentName string
+	// Where file: and link: paths of the dependency are relative to; ""
+	// for dependencies of packages from a registry or git
+	BaseDir    string
 	IsDev      bool
+	IsOptional bool
 }
 
 func BuildDependencies() (*Dependencies, error) {
@@ -92,7 +121,12 @@ func BuildDependencies() (*Dependencies, error) {
 		return nil, fmt.Errorf("failed to create config: %w", err)
 	}
 
-	manifest, err := manifest.NewManifest(cfg.BaseDir, npmRegistryURL)
+	registry, err := npmrc.Load(npmRegistryURL, cfg.GlobalNpmrc, cfg.UserNpmrc, cfg.ProjectNpmrc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .npmrc: %w", err)
+	}
+
+	manifest, err := manifest.NewManifest(cfg.BaseDir, registry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create manifest: %w", err)
 	}
@@ -102,17 +136,32 @@ func BuildDependencies() (*Dependencies, error) {
 		return nil, fmt.Errorf("failed to create etag: %w", err)
 	}
 
+	packageCopy := packagecopy.NewPackageCopy()
+
+	// Manifests and tarballs share one pool of connections and one report
+	downloader := utils.NewDownloader(utils.DefaultConcurrency)
+	downloader.Progress = utils.NewProgress(os.Stdout)
+	manifest.Downloader = downloader
+	tarball := tarball.NewTarball(registry)
+	tarball.TarballPath = cfg.TarballDir
+	tarball.Downloader = downloader
+
 	return &Dependencies{
 		Config:            cfg,
 		Manifest:          manifest,
 		Etag:              etag,
-		Tarball:           tarball.NewTarball(),
+		Tarball:           tarball,
+		Downloader:        downloader,
 		Extractor:         extractor.NewTGZExtractor(),
-		PackageCopy:       packagecopy.NewPackageCopy(),
+		PackageCopy:       packageCopy,
+		Store:             store.New(cfg.StoreDir, packageCopy),
 		ParseJsonManifest: newParseJsonManifest(),
 		VersionInfo:       newVersionInfo(),
 		PackageJsonParse:  packagejson.NewPackageJSONParser(cfg),
 		BinLinker:         binlink.NewBinLinker(cfg.LocalNodeModules),
+		Registry:          registry,
+		Publisher:         publish.NewPublisher(registry),
+		Scripts:           lifecycle.NewRunner(),
 	}, nil
 }
 
@@ -126,13 +175,17 @@ func New(deps *Dependencies) (*PackageManager, error) {
 		return nil, err
 	}
 
+	if err := utils.CreateDir(deps.Tarball.TarballPath); err != nil {
+		return nil, err
+	}
+
 	return &PackageManager{
 		dependencies:      make(map[string]string),
 		extractedPath:     deps.Config.LocalNodeModules,
 		processedPackages: make(map[string]packagejson.Dependency),
 		configPath:        deps.Config.BaseDir,
 		packagesPath:      deps.Config.PackagesDir,
-		Etag:              *deps.Etag,
+		Etag:              deps.Etag,
 		isAdd:             false,
 		isGlobal:          false,
 		config:            deps.Config,
@@ -140,11 +193,18 @@ func New(deps *Dependencies) (*PackageManager, error) {
 		tarball:           deps.Tarball,
 		extractor:         deps.Extractor,
 		packageCopy:       deps.PackageCopy,
+		store:             deps.Store,
 		manifest:          deps.Manifest,
 		parseJsonManifest: deps.ParseJsonManifest,
 		versionInfo:       deps.VersionInfo,
 		packageJsonParse:  deps.PackageJsonParse,
 		binLinker:         deps.BinLinker,
+		registry:          deps.Registry,
+		publisher:         deps.Publisher,
+		scripts:           deps.Scripts,
+		ignoreScripts:     deps.Registry.IgnoreScripts,
+		offline:           deps.Registry.Offline,
+		preferOffline:     deps.Registry.PreferOffline,
 		downloadLocks:     make(map[string]*sync.Mutex),
 	}, nil
 }
@@ -206,6 +266,10 @@ func (pm *PackageManager) ParsePackageJSON(isProduction bool) error {
 		return err
 	}
 
+	if len(data.Workspaces) > 0 {
+		return pm.installWorkspaces(*data, isProduction)
+	}
+
 	if pm.packageJsonParse.PackageLock != nil {
 		packagesToAdd, packagesToRemove := pm.packageJsonParse.ResolveDependencies()
 
@@ -245,6 +309,233 @@ func (pm *PackageManager) ParsePackageJSON(isProduction bool) error {
 	return nil
 }
 
+// installWorkspaces resolves the root's dependencies together with those of
+// every workspace, so the ones they share are hoisted to the root
+// node_modules, and records links to the workspaces themselves. The whole
+// tree is resolved again each time and written to the one lock file.
+func (pm *PackageManager) installWorkspaces(root packagejson.PackageJSON, isProduction bool) error {
+	packages, err := workspace.Discover(".", root.Workspaces)
+	if err != nil {
+		return err
+	}
+
+	pm.workspaces = make(map[string]workspace.Package, len(packages))
+	for _, pkg := range packages {
+		pm.workspaces[pkg.Name] = pkg
+	}
+
+	if err := pm.checkWorkspaceRanges("package.json", root.Dependencies, root.DevDependencies); err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		if err := pm.checkWorkspaceRanges(pkg.Name, pkg.Dependencies, pkg.DevDependencies); err != nil {
+			return err
+		}
+	}
+
+	if err := pm.fetchToCache(root, isProduction); err != nil {
+		return err
+	}
+
+	for _, pkg := range packages {
+		dependencies := make(map[string]string, len(pkg.Dependencies)+len(pkg.DevDependencies))
+		for name, version := range pkg.DevDependencies {
+			dependencies[name] = version
+		}
+		for name, version := range pkg.Dependencies {
+			dependencies[name] = version
+		}
+
+		pm.packageLock.Packages[pkg.Dir] = packagejson.PackageItem{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Dependencies: dependencies,
+		}
+	}
+	// Again, now that the workspaces depend on their packages
+	markDependencyFlags(pm.packageLock)
+
+	return pm.packageJsonParse.CreateLockFile(pm.packageLock, false)
+}
+
+// checkWorkspaceRanges makes sure every workspace: dependency of parent
+// names a workspace whose version satisfies the range
+func (pm *PackageManager) checkWorkspaceRanges(parent string, dependencies ...map[string]string) error {
+	for _, deps := range dependencies {
+		for name, spec := range deps {
+			if !workspace.IsProtocol(spec) {
+				continue
+			}
+			pkg, ok := pm.workspaces[name]
+			if !ok {
+				return fmt.Errorf("%s depends on %s@%s but there is no workspace named %s", parent, name, spec, name)
+			}
+			if r := workspace.Range(spec, pkg); !satisfies(pkg.Version, r) {
+				return fmt.Errorf("%s depends on %s@%s but the workspace is at %s", parent, name, spec, pkg.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// linkedWorkspace reports whether a dependency is satisfied by a workspace,
+// so it is linked rather than fetched
+func (pm *PackageManager) linkedWorkspace(dep packagejson.Dependency) bool {
+	pkg, ok := pm.workspaces[dep.Name]
+	if !ok {
+		return false
+	}
+	return workspace.IsProtocol(dep.Version) || satisfies(pkg.Version, dep.Version)
+}
+
+// workspaceQueue lists the dependencies of every workspace, to be resolved
+// with the root's
+func (pm *PackageManager) workspaceQueue(isProduction bool) []QueueItem {
+	names := make([]string, 0, len(pm.workspaces))
+	for name := range pm.workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var queue []QueueItem
+	for _, name := range names {
+		pkg := pm.workspaces[name]
+		for dep, version := range pkg.Dependencies {
+			queue = append(queue, QueueItem{
+				Dep:        packagejson.Dependency{Name: dep, Version: version},
+				ParentName: name,
+				BaseDir:    pkg.Dir,
+			})
+		}
+		if !isProduction {
+			for dep, version := range pkg.DevDependencies {
+				queue = append(queue, QueueItem{
+					Dep:        packagejson.Dependency{Name: dep, Version: version},
+					ParentName: name,
+					BaseDir:    pkg.Dir,
+					IsDev:      true,
+				})
+			}
+		}
+	}
+	return queue
+}
+
+// workspaceItems are the links to the workspaces for hoist, each with the
+// dependencies placed from it: a version only one workspace needs is nested
+// under its link
+func (pm *PackageManager) workspaceItems(isProduction bool) []packagejson.PackageItem {
+	items := make([]packagejson.PackageItem, 0, len(pm.workspaces))
+	for _, pkg := range pm.workspaces {
+		dependencies := make(map[string]string, len(pkg.Dependencies)+len(pkg.DevDependencies))
+		if !isProduction {
+			for name, version := range pkg.DevDependencies {
+				dependencies[name] = version
+			}
+		}
+		for name, version := range pkg.Dependencies {
+			dependencies[name] = version
+		}
+		items = append(items, packagejson.PackageItem{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Resolved:     pkg.Dir,
+			Link:         true,
+			Dependencies: dependencies,
+		})
+	}
+	return items
+}
+
+// linkWorkspace symlinks a workspace, or the directory of a link:
+// dependency, into node_modules, relative so the project can be moved
+func (pm *PackageManager) linkWorkspace(pkgPath string, item packagejson.PackageItem) error {
+	linkPath := filepath.Join(pm.extractedPath, strings.TrimPrefix(pkgPath, "node_modules/"))
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(linkPath), err)
+	}
+
+	linkDir, err := filepath.Abs(filepath.Dir(linkPath))
+	if err != nil {
+		return err
+	}
+	workspaceDir, err := filepath.Abs(filepath.FromSlash(item.Resolved))
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(linkDir, workspaceDir)
+	if err != nil {
+		return fmt.Errorf("failed to link workspace %s: %w", item.Name, err)
+	}
+
+	// A stale link, or the package installed from the registry before
+	if err := os.RemoveAll(linkPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", linkPath, err)
+	}
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to link workspace %s: %w", item.Name, err)
+	}
+	return nil
+}
+
+// RunWorkspaceScript runs a script of the workspace named, or in the
+// directory, workspaceName
+func (pm *PackageManager) RunWorkspaceScript(workspaceName, name string, args []string) error {
+	root, err := pm.packageJsonParse.ParseDefault()
+	if err != nil {
+		return err
+	}
+	packages, err := workspace.Discover(".", root.Workspaces)
+	if err != nil {
+		return err
+	}
+	pkg, ok := workspace.Find(packages, workspaceName)
+	if !ok {
+		return fmt.Errorf("no workspace named %s", workspaceName)
+	}
+	return pm.scripts.RunScript(pkg.Dir, name, args)
+}
+
+// CI prepares an install of exactly what the lock file records, as npm ci
+// does. It fails when package.json and the lock disagree instead of
+// resolving again, empties node_modules and never writes the lock; the
+// tarballs are then verified against the lock by InstallFromCache.
+func (pm *PackageManager) CI(isProduction bool) error {
+	if _, err := pm.packageJsonParse.ParseDefault(); err != nil {
+		return err
+	}
+
+	lockFileName := pm.packageJsonParse.LockFileName
+	packageLock, err := pm.packageJsonParse.ParseLockFile()
+	if err != nil {
+		return fmt.Errorf("ci needs a valid %s, run go-npm i to create it: %w", lockFileName, err)
+	}
+	pm.packageJsonParse.PackageLock = packageLock
+
+	if mismatches := pm.packageJsonParse.LockMismatches(); len(mismatches) > 0 {
+		return fmt.Errorf("package.json and %s are out of sync, run go-npm i to update the lock file:\n  %s",
+			lockFileName, strings.Join(mismatches, "\n  "))
+	}
+
+	for pkgPath, item := range packageLock.Packages {
+		if strings.HasPrefix(pkgPath, "node_modules/") && !item.InBundle && item.Resolved == "" {
+			return fmt.Errorf("%s has no resolved URL in %s", pkgPath, lockFileName)
+		}
+	}
+
+	if isProduction && len(packageLock.DevDependencies) > 0 {
+		pm.removeDevOnlyPackages()
+	}
+
+	if err := os.RemoveAll(pm.extractedPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", pm.extractedPath, err)
+	}
+
+	pm.packageLock = packageLock
+
+	return nil
+}
+
 func (pm *PackageManager) removeDevOnlyPackages() {
 	pkgsToRemoveMap := make(map[string]bool)
 
@@ -258,8 +549,9 @@ func (pm *PackageManager) removeDevOnlyPackages() {
 	}
 
 	pathsToDelete := []string{}
-	for pkgPath := range pm.packageJsonParse.PackageLock.Packages {
-		shouldDelete := false
+	for pkgPath, item := range pm.packageJsonParse.PackageLock.Packages {
+		// Flagged when the lock was written
+		shouldDelete := item.Dev
 
 		pkgName := strings.TrimPrefix(pkgPath, "node_modules/")
 		if strings.Contains(pkgName, "/node_modules/") {
@@ -291,17 +583,34 @@ func (pm *PackageManager) removeDevOnlyPackages() {
 
 func (pm *PackageManager) InstallFromCache() error {
 	packagesToInstall := make(map[string]packagejson.PackageItem)
-	for pkgPath := range pm.packageLock.Packages {
-		namePkg := strings.TrimPrefix(pkgPath, "node_modules/")
-		if strings.Contains(namePkg, "/node_modules/") {
-			parts := strings.Split(namePkg, "/node_modules/")
-			namePkg = parts[len(parts)-1]
+	for pkgPath, item := range pm.packageLock.Packages {
+		// The root and workspace directories are not installed, and bundled
+		// packages come with the package they are bundled in
+		if !strings.HasPrefix(pkgPath, "node_modules/") || item.InBundle {
+			continue
+		}
+		if !platformSupported(item.OS, item.CPU) {
+			if item.Optional {
+				fmt.Printf("Skipping optional dependency %s: not supported on %s/%s\n", item.Name, nodePlatform(), nodeArch())
+				continue
+			}
+			return fmt.Errorf("%s@%s does not support %s/%s (os %v, cpu %v)", item.Name, item.Version, nodePlatform(), nodeArch(), item.OS, item.CPU)
 		}
 
-		targetPath := path.Join(pm.extractedPath, namePkg)
+		targetPath := path.Join(pm.extractedPath, strings.TrimPrefix(pkgPath, "node_modules/"))
 		exists := utils.FolderExists(targetPath)
 		if !exists {
-			packagesToInstall[pkgPath] = pm.packageLock.Packages[pkgPath]
+			packagesToInstall[pkgPath] = item
+		}
+	}
+
+	// Workspaces first: their own nested dependencies install through the links
+	for pkgPath, item := range packagesToInstall {
+		if item.Link {
+			if err := pm.linkWorkspace(pkgPath, item); err != nil {
+				return err
+			}
+			delete(packagesToInstall, pkgPath)
 		}
 	}
 
@@ -323,32 +632,35 @@ func (pm *PackageManager) InstallFromCache() error {
 				pkgName = parts[len(parts)-1]
 			}
 
-			pathPkg := path.Join(pm.packagesPath, pkgName+"@"+item.Version)
+			key := cacheKey(pm.registry, pkgName, item.Version, item.Resolved)
+			pathPkg := filepath.Join(pm.packagesPath, key)
 
-			exists := utils.FolderExists(pathPkg)
-			if !exists {
-				if item.Resolved == "" {
+			if item.Resolved == "" {
+				if !utils.FolderExists(pathPkg) {
 					fmt.Printf("Skipping package %s - empty resolved URL in lock file\n", item.Name)
 					return
 				}
-				err := pm.tarball.Download(item.Resolved)
-				if err != nil {
-					errChan <- err
-					return
-				}
-
-				err = pm.extractor.Extract(
-					filepath.Join(pm.tarball.TarballPath, path.Base(item.Resolved)),
-					pathPkg,
-				)
-				if err != nil {
-					errChan <- err
+			} else if err := pm.fetchLocked(pkgName, item); err != nil {
+				if item.Optional {
+					fmt.Printf("Skipping optional dependency %s: %v\n", item.Name, err)
 					return
 				}
+				errChan <- err
+				return
 			}
 
 			targetPath := path.Join(pm.extractedPath, namePkg)
-			err := pm.packageCopy.CopyDirectory(pathPkg, targetPath)
+			// A source fetched again may replace the cache entry meanwhile
+			defer pm.lockPackage(pathPkg)()
+			install := pm.store.Link
+			// Install scripts may build into the package's own files, which
+			// must not write through to the store
+			if !pm.ignoreScripts {
+				if pkg, err := lifecycle.ReadPackage(pathPkg); err == nil && pkg.HasInstallScript() {
+					install = pm.store.Copy
+				}
+			}
+			err := install(key, pathPkg, targetPath)
 			if err != nil {
 				errChan <- err
 				return
@@ -367,9 +679,204 @@ func (pm *PackageManager) InstallFromCache() error {
 		return fmt.Errorf("failed to link bin executables: %w", err)
 	}
 
+	if !pm.ignoreScripts {
+		if err := pm.runInstallScripts(packagesToInstall); err != nil {
+			return err
+		}
+	}
+
+	// store prune keeps what the project's lock file uses
+	if err := pm.store.Register(pm.lockFilePath()); err != nil {
+		return fmt.Errorf("failed to register the project with the store: %w", err)
+	}
+
 	return nil
 }
 
+// runInstallScripts runs the install scripts of newly installed packages
+// one at a time once every bin is linked, each after the packages it
+// depends on, as npm does
+func (pm *PackageManager) runInstallScripts(installed map[string]packagejson.PackageItem) error {
+	pkgPaths := make([]string, 0, len(installed))
+	for pkgPath := range installed {
+		if pkgPath != "" {
+			pkgPaths = append(pkgPaths, pkgPath)
+		}
+	}
+	sort.Strings(pkgPaths)
+
+	var order []string
+	visited := make(map[string]bool)
+	var visit func(pkgPath string)
+	visit = func(pkgPath string) {
+		if visited[pkgPath] {
+			return
+		}
+		visited[pkgPath] = true
+
+		deps := make([]string, 0, len(installed[pkgPath].Dependencies))
+		for dep := range installed[pkgPath].Dependencies {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if depPath := lockPathOf(pm.packageLock, pkgPath, dep); depPath != "" {
+				if _, ok := installed[depPath]; ok {
+					visit(depPath)
+				}
+			}
+		}
+		order = append(order, pkgPath)
+	}
+	for _, pkgPath := range pkgPaths {
+		visit(pkgPath)
+	}
+
+	for _, pkgPath := range order {
+		pkgDir := path.Join(pm.extractedPath, strings.TrimPrefix(pkgPath, "node_modules/"))
+		if !utils.FolderExists(pkgDir) {
+			continue
+		}
+		if err := pm.scripts.RunInstall(pkgDir); err != nil {
+			// A failed build of an optional package only leaves it out
+			if installed[pkgPath].Optional {
+				fmt.Printf("Removing optional dependency %s: %v\n", installed[pkgPath].Name, err)
+				if err := os.RemoveAll(pkgDir); err != nil {
+					return fmt.Errorf("failed to remove %s: %w", pkgDir, err)
+				}
+				continue
+			}
+			return fmt.Errorf("failed to run install scripts of %s: %w", installed[pkgPath].Name, err)
+		}
+	}
+
+	return nil
+}
+
+// lockPathOf finds the lock entry a dependency of pkgPath resolves to,
+// looking through node_modules directories upwards as node does
+func lockPathOf(lock *packagejson.PackageLock, pkgPath, dep string) string {
+	for dir := pkgPath; ; {
+		candidate := dir + "/node_modules/" + dep
+		if _, ok := lock.Packages[candidate]; ok {
+			return candidate
+		}
+		i := strings.LastIndex(dir, "/node_modules/")
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+	}
+	if _, ok := lock.Packages["node_modules/"+dep]; ok {
+		return "node_modules/" + dep
+	}
+	return ""
+}
+
+// markDependencyFlags sets Dev, Optional and Peer on the packages of the
+// lock that are only reached through dev, optional or peer dependencies,
+// the way npm flags them, so a production or platform specific install
+// knows what it can leave out
+func markDependencyFlags(lock *packagejson.PackageLock) {
+	type edgeKind int
+	const (
+		prodEdge edgeKind = iota
+		optionalEdge
+		peerEdge
+	)
+	type edge struct {
+		path string
+		kind edgeKind
+	}
+
+	edgesOf := func(pkgPath string) []edge {
+		item := lock.Packages[pkgPath]
+		var edges []edge
+		add := func(deps map[string]string, kind edgeKind) {
+			for name := range deps {
+				if depPath := lockPathOf(lock, pkgPath, name); depPath != "" {
+					edges = append(edges, edge{depPath, kind})
+				}
+			}
+		}
+		add(item.Dependencies, prodEdge)
+		add(item.OptionalDependencies, optionalEdge)
+		add(item.PeerDependencies, peerEdge)
+		return edges
+	}
+
+	// reach walks the graph from the roots, following only the edge kinds
+	// follow accepts
+	reach := func(roots []string, follow func(edgeKind) bool) map[string]bool {
+		seen := make(map[string]bool)
+		var walk func(pkgPath string)
+		walk = func(pkgPath string) {
+			if seen[pkgPath] {
+				return
+			}
+			seen[pkgPath] = true
+			for _, e := range edgesOf(pkgPath) {
+				if follow(e.kind) {
+					walk(e.path)
+				}
+			}
+		}
+		for _, root := range roots {
+			walk(root)
+		}
+		return seen
+	}
+
+	rootsOf := func(deps ...map[string]string) []string {
+		var roots []string
+		for _, m := range deps {
+			for name := range m {
+				if depPath := lockPathOf(lock, "", name); depPath != "" {
+					roots = append(roots, depPath)
+				}
+			}
+		}
+		// Workspaces depend on their packages as the root does
+		for pkgPath := range lock.Packages {
+			if !strings.HasPrefix(pkgPath, "node_modules/") {
+				roots = append(roots, pkgPath)
+			}
+		}
+		return roots
+	}
+
+	anyEdge := func(edgeKind) bool { return true }
+	prod := reach(rootsOf(lock.Dependencies, lock.OptionalDependencies), anyEdge)
+	required := reach(rootsOf(lock.Dependencies, lock.DevDependencies), func(k edgeKind) bool { return k != optionalEdge })
+	direct := reach(rootsOf(lock.Dependencies, lock.DevDependencies, lock.OptionalDependencies), func(k edgeKind) bool { return k != peerEdge })
+
+	for pkgPath, item := range lock.Packages {
+		if !strings.HasPrefix(pkgPath, "node_modules/") || item.Link {
+			continue
+		}
+		item.Dev = !prod[pkgPath]
+		item.Optional = !required[pkgPath]
+		item.Peer = !direct[pkgPath]
+		lock.Packages[pkgPath] = item
+	}
+}
+
+// SetIgnoreScripts skips dependencies' lifecycle scripts on install
+func (pm *PackageManager) SetIgnoreScripts(ignore bool) {
+	pm.ignoreScripts = ignore
+}
+
+// RunScript runs a script of the project's package.json with its pre and
+// post hooks
+func (pm *PackageManager) RunScript(name string, args []string) error {
+	return pm.scripts.RunScript(".", name, args)
+}
+
+// ScriptNames lists the scripts of the project's package.json
+func (pm *PackageManager) ScriptNames() ([]string, error) {
+	return lifecycle.ScriptNames(".")
+}
+
 func (pm *PackageManager) removePackagesFromNodeModules(pkgList []string) error {
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(pkgList))
@@ -403,6 +910,15 @@ func (pm *PackageManager) Add(pkgName string, version string, isInstall bool) er
 		return err
 	}
 
+	// A git url, path or tarball given alone is named by its package.json
+	if pkgName == "" {
+		pkg, err := pm.fetchSource("", depspec.Parse(version), ".", "")
+		if err != nil {
+			return err
+		}
+		pkgName = pkg.Name
+	}
+
 	if !isInstall {
 		if _, exists := packageJson.Dependencies[pkgName]; exists {
 			if version != "" && packageJson.Dependencies[pkgName] == version {
@@ -422,6 +938,10 @@ func (pm *PackageManager) Add(pkgName string, version string, isInstall bool) er
 		return err
 	}
 
+	// Resolving parsed the package.json of every package it fetched
+	if _, err := pm.packageJsonParse.ParseDefault(); err != nil {
+		return err
+	}
 	err = pm.packageJsonParse.AddOrUpdateDependency(pkgName, version)
 	if err != nil {
 		return err
@@ -471,35 +991,54 @@ func (pm *PackageManager) fetchToCache(packageJson packagejson.PackageJSON, isPr
 	queue := make([]QueueItem, 0)
 
 	for name, version := range packageJson.Dependencies {
+		// Optional dependencies are often listed in both
+		if _, ok := packageJson.OptionalDependencies[name]; ok {
+			continue
+		}
 		queue = append(queue, QueueItem{
 			Dep:        packagejson.Dependency{Name: name, Version: version},
 			ParentName: "package.json",
+			BaseDir:    ".",
 			IsDev:      false,
 		})
 	}
 
+	for name, version := range packageJson.OptionalDependencies {
+		queue = append(queue, QueueItem{
+			Dep:        packagejson.Dependency{Name: name, Version: version},
+			ParentName: "package.json",
+			BaseDir:    ".",
+			IsOptional: true,
+		})
+	}
+
 	if !isProduction {
 		for name, version := range packageJson.DevDependencies {
 			queue = append(queue, QueueItem{
 				Dep:        packagejson.Dependency{Name: name, Version: version},
 				ParentName: "package.json",
+				BaseDir:    ".",
 				IsDev:      true,
 			})
 		}
 	}
 
+	queue = append(queue, pm.workspaceQueue(isProduction)...)
+
 	packageLock := packagejson.PackageLock{}
-	packageLock.Packages = make(map[string]packagejson.PackageItem)
 	packageLock.Dependencies = make(map[string]string)
 	packageLock.DevDependencies = make(map[string]string)
-	packagesVersion := make(map[string]QueueItem)
+	packageLock.OptionalDependencies = make(map[string]string)
+	res := newResolution(pm.registry)
+	manifests := pm.newManifestLoads()
 
 	var (
 		wg             sync.WaitGroup
 		mapMutex       sync.Mutex
 		activeWorkers  int
 		workerMutex    sync.Mutex
-		processingPkgs = make(map[string]bool)
+		processingPkgs = make(map[string]bool) // claimed by a required dependent, by cache key
+		pendingPeers   []peerRequest
 	)
 
 	errChan := make(chan error, 1)
@@ -507,236 +1046,436 @@ func (pm *PackageManager) fetchToCache(packageJson packagejson.PackageJSON, isPr
 
 	workChan := make(chan QueueItem, len(queue))
 	for _, item := range queue {
-		if item.IsDev {
-			packageLock.DevDependencies[item.Dep.Name] = item.Dep.Version
-		} else {
-			packageLock.Dependencies[item.Dep.Name] = item.Dep.Version
+		if item.ParentName == "package.json" {
+			switch {
+			case item.IsOptional:
+				packageLock.OptionalDependencies[item.Dep.Name] = item.Dep.Version
+			case item.IsDev:
+				packageLock.DevDependencies[item.Dep.Name] = item.Dep.Version
+			default:
+				packageLock.Dependencies[item.Dep.Name] = item.Dep.Version
+			}
 		}
 		workChan <- item
 	}
 
+	// The workers only resolve and fetch, in whatever order they get to
+	// packages; hoist lays them out afterwards. Each round resolves the
+	// queued packages and everything they depend on; the peers they asked
+	// for are queued for the next one.
 	for {
-		workerMutex.Lock()
-		workers := activeWorkers
-		workerMutex.Unlock()
-
-		if len(workChan) == 0 && workers == 0 {
-			break
-		}
-
-		select {
-		case item := <-workChan:
+		for {
 			workerMutex.Lock()
-			activeWorkers++
+			workers := activeWorkers
 			workerMutex.Unlock()
 
-			wg.Add(1)
-
-			go func(item QueueItem) {
-				defer func() {
-					wg.Done()
-					workerMutex.Lock()
-					activeWorkers--
-					workerMutex.Unlock()
-				}()
+			if len(workChan) == 0 && workers == 0 {
+				break
+			}
 
-				if item.Dep.Name == "" {
-					return
-				}
+			select {
+			case item := <-workChan:
+				workerMutex.Lock()
+				activeWorkers++
+				workerMutex.Unlock()
 
-				select {
-				case <-done:
-					return
-				default:
-				}
+				wg.Add(1)
 
-				pm.downloadMu.Lock()
-				pkgLock, exists := pm.downloadLocks[item.Dep.Name]
-				if !exists {
-					pkgLock = &sync.Mutex{}
-					pm.downloadLocks[item.Dep.Name] = pkgLock
-				}
-				pm.downloadMu.Unlock()
+				go func(item QueueItem) {
+					defer func() {
+						wg.Done()
+						workerMutex.Lock()
+						activeWorkers--
+						workerMutex.Unlock()
+					}()
 
-				pkgLock.Lock()
+					if item.Dep.Name == "" || pm.linkedWorkspace(item.Dep) {
+						return
+					}
 
-				manifestPath := filepath.Join(pm.manifest.Path, item.Dep.Name+".json")
-				var currentEtag string
+					select {
+					case <-done:
+						return
+					default:
+					}
 
-				if _, err := os.Stat(manifestPath); err == nil {
-					currentEtag = pm.Etag.Get(item.Dep.Name)
-				} else {
-					etag := pm.Etag.Get(item.Dep.Name)
-					var downloadErr error
-					currentEtag, _, downloadErr = pm.manifest.Download(item.Dep.Name, etag)
-					if downloadErr != nil {
-						pkgLock.Unlock()
+					// fail stops the install, unless the package is optional:
+					// then it is only left out
+					fail := func(err error) {
+						if item.IsOptional {
+							fmt.Printf("Skipping optional dependency %s: %v\n", item.Dep.Name, err)
+							return
+						}
 						select {
-						case errChan <- downloadErr:
+						case errChan <- err:
 							close(done)
 						default:
 						}
-						return
 					}
-				}
 
-				npmPackage, err := pm.parseJsonManifest.parse(manifestPath)
-				pkgLock.Unlock()
-
-				if err != nil {
-					select {
-					case errChan <- err:
-						close(done)
-					default:
+					pm.downloadMu.Lock()
+					pkgLock, exists := pm.downloadLocks[item.Dep.Name]
+					if !exists {
+						pkgLock = &sync.Mutex{}
+						pm.downloadLocks[item.Dep.Name] = pkgLock
 					}
-					return
-				}
+					pm.downloadMu.Unlock()
 
-				version := pm.versionInfo.getVersion(item.Dep.Version, npmPackage)
-				packageKey := item.Dep.Name + "@" + version
+					pkgLock.Lock()
 
-				if version == "" {
-					fmt.Println("Version not found for package:", item.Dep.Name, "with constraint:", item.Dep.Version)
-				}
+					var (
+						version, tarballURL, expected, currentEtag string
+						manifestVersion                            Version
+						// Set for git, file:, link: and tarball URL dependencies
+						source *sourcePackage
+					)
+					if spec := depspec.Parse(item.Dep.Version); spec.Type == depspec.Registry || spec.Type == depspec.Workspace {
+						npmPackage, etag, err := manifests.load(item.Dep.Name, item.Dep.Version)
+						pkgLock.Unlock()
 
-				var packageResolved string
-				var shouldProcessDeps bool
-
-				mapMutex.Lock()
-				if existingPkg, ok := packagesVersion[item.Dep.Name]; ok {
-					if existingPkg.Dep.Version != version {
-						fmt.Println("Package Repeated:", item.Dep.Name)
-						fmt.Println("Resolved version:", version)
-						packageResolved = "node_modules/" + item.ParentName + "/node_modules/" + item.Dep.Name
-
-						if _, processed := processingPkgs[packageKey]; processed {
-							shouldProcessDeps = false
-						} else {
-							processingPkgs[packageKey] = true
-							shouldProcessDeps = true
+						if err != nil {
+							fail(err)
+							return
 						}
-					} else {
-						mapMutex.Unlock()
-						return
-					}
-				} else {
-					packageResolved = "node_modules/" + item.Dep.Name
-					packagesVersion[item.Dep.Name] = QueueItem{
-						Dep:        packagejson.Dependency{Name: item.Dep.Name, Version: version},
-						ParentName: item.ParentName,
-					}
+						currentEtag = etag
 
-					if _, processed := processingPkgs[packageKey]; processed {
-						shouldProcessDeps = false
+						version = pm.versionInfo.getVersion(item.Dep.Version, npmPackage)
+						if kept := pm.lockedVersion(item.Dep.Name, item.Dep.Version, npmPackage); kept != "" {
+							version = kept
+						}
+						if version == "" {
+							fail(fmt.Errorf("no version of %s matches %q", item.Dep.Name, item.Dep.Version))
+							return
+						}
+						manifestVersion = npmPackage.Versions[version]
+						tarballURL = manifestVersion.Dist.Tarball
+						if tarballURL == "" {
+							tarballURL = pm.registry.TarballURL(item.Dep.Name, version)
+						}
 					} else {
-						processingPkgs[packageKey] = true
-						shouldProcessDeps = true
-					}
-				}
-				mapMutex.Unlock()
+						fetched, err := pm.fetchSource(item.Dep.Name, spec, item.BaseDir, "")
+						pkgLock.Unlock()
 
-				configPackageVersion := filepath.Join(pm.packagesPath, item.Dep.Name+"@"+version)
+						if err != nil {
+							fail(err)
+							return
+						}
+						source = fetched
+						version, tarballURL, expected = source.Version, source.Resolved, source.Integrity
+						manifestVersion = Version{OS: source.OS, CPU: source.CPU}
+					}
+					// A git or file: source never stands in for a published
+					// version of the same number
+					packageKey := cacheKey(pm.registry, item.Dep.Name, version, tarballURL)
 
-				tarballName := item.Dep.Name
-				if strings.HasPrefix(item.Dep.Name, "@") && strings.Contains(item.Dep.Name, "/") {
-					parts := strings.Split(item.Dep.Name, "/")
-					tarballName = parts[1]
-				}
+					if !platformSupported(manifestVersion.OS, manifestVersion.CPU) {
+						fail(fmt.Errorf("%s@%s does not support %s/%s (os %v, cpu %v)", item.Dep.Name, version, nodePlatform(), nodeArch(), manifestVersion.OS, manifestVersion.CPU))
+						return
+					}
 
-				tarballURL := fmt.Sprintf("%s%s/-/%s-%s.tgz", npmRegistryURL, item.Dep.Name, tarballName, version)
+					mapMutex.Lock()
+					res.versions[item.Dep.Name+"@"+item.Dep.Version] = resolvedRange{version: version, key: packageKey}
+					// A package an optional dependent claimed is fetched again
+					// for a required one, so failing to fetch it for the first
+					// cannot leave it out silently
+					required, claimed := processingPkgs[packageKey]
+					processed := claimed && (required || item.IsOptional)
+					if !processed {
+						processingPkgs[packageKey] = !item.IsOptional
+					}
+					mapMutex.Unlock()
 
-				if shouldProcessDeps && !utils.FolderExists(configPackageVersion) {
-					if tarballURL == "" || version == "" {
-						fmt.Printf("Skipping download for %s - invalid URL or empty version\n", item.Dep.Name)
+					if processed {
 						return
 					}
-					err = pm.tarball.Download(tarballURL)
-					if err != nil {
-						select {
-						case errChan <- err:
-							close(done)
-						default:
+
+					switch {
+					case source == nil:
+						dist := manifestVersion.Dist
+						if tarballURL == "" {
+							fmt.Printf("Skipping download for %s - invalid URL or empty version\n", item.Dep.Name)
+							return
 						}
-						return
-					}
 
-					err = pm.extractor.Extract(
-						filepath.Join(pm.tarball.TarballPath, path.Base(tarballURL)),
-						configPackageVersion,
-					)
+						expected = dist.Integrity
+						if expected == "" {
+							expected = integrity.FromShasum(dist.Shasum)
+						}
 
-					if err != nil {
-						select {
-						case errChan <- err:
-							close(done)
-						default:
+						actual, err := pm.fetchPackage(item.Dep.Name, version, tarballURL, expected)
+						if err != nil {
+							fail(err)
+							return
 						}
+						// Registries without dist.integrity get the hash of what was downloaded
+						if expected == "" {
+							expected = integrity.Strongest(actual)
+						}
+					case source.Link:
+						// Symlinked as it is: what it depends on is its own business
+						mapMutex.Lock()
+						res.packages[packageKey] = packagejson.PackageItem{
+							Name:     item.Dep.Name,
+							Version:  version,
+							Resolved: source.Resolved,
+							Link:     true,
+						}
+						mapMutex.Unlock()
 						return
 					}
-				}
-
-				mapMutex.Lock()
-				pckItem := packagejson.PackageItem{
-					Name:     item.Dep.Name,
-					Version:  version,
-					Resolved: tarballURL,
-					Etag:     currentEtag,
-				}
-				packageLock.Packages[packageResolved] = pckItem
-				mapMutex.Unlock()
 
-				if shouldProcessDeps {
-					packageJsonPath := filepath.Join(pm.packagesPath, item.Dep.Name+"@"+version, "package.json")
-
-					data, err := pm.packageJsonParse.Parse(packageJsonPath)
+					pkgDir := filepath.Join(pm.packagesPath, packageKey)
+					// Paths in a directory's dependencies are relative to it
+					var baseDir string
+					if source != nil {
+						baseDir = source.Dir
+					}
+					data, err := pm.packageJsonParse.Parse(filepath.Join(pkgDir, "package.json"))
 					if err != nil {
-						select {
-						case errChan <- err:
-							close(done)
-						default:
-						}
+						fail(err)
 						return
 					}
 
+					pkgItem := packagejson.PackageItem{
+						Name:                 item.Dep.Name,
+						Version:              version,
+						Resolved:             tarballURL,
+						Integrity:            expected,
+						Etag:                 currentEtag,
+						OptionalDependencies: data.OptionalDependencies,
+						PeerDependencies:     data.PeerDependencies,
+						PeerDependenciesMeta: data.PeerDependenciesMeta,
+						OS:                   manifestVersion.OS,
+						CPU:                  manifestVersion.CPU,
+					}
+					bundled := data.Bundled()
+
 					mapMutex.Lock()
+					defer mapMutex.Unlock()
+
 					for name, version := range data.Dependencies {
-						pkgItem := packageLock.Packages[packageResolved]
+						if _, ok := data.OptionalDependencies[name]; ok {
+							continue
+						}
 						if pkgItem.Dependencies == nil {
 							pkgItem.Dependencies = make(map[string]string)
 						}
 						pkgItem.Dependencies[name] = version
-						packageLock.Packages[packageResolved] = pkgItem
+
+						// Bundled dependencies come in the tarball, nested in the package
+						if bundled[name] {
+							if shipped, ok := readShippedPackage(pkgDir, name); ok {
+								pkgItem.BundleDependencies = append(pkgItem.BundleDependencies, name)
+								if res.bundled[packageKey] == nil {
+									res.bundled[packageKey] = make(map[string]packagejson.PackageItem)
+								}
+								res.bundled[packageKey][name] = packagejson.PackageItem{
+									Name:     name,
+									Version:  shipped,
+									InBundle: true,
+								}
+								continue
+							}
+						}
 
 						workChan <- QueueItem{
 							Dep:        packagejson.Dependency{Name: name, Version: version},
 							ParentName: item.Dep.Name,
+							BaseDir:    baseDir,
 							IsDev:      item.IsDev,
+							IsOptional: item.IsOptional,
 						}
 					}
-					mapMutex.Unlock()
+					sort.Strings(pkgItem.BundleDependencies)
+
+					for name, version := range data.OptionalDependencies {
+						workChan <- QueueItem{
+							Dep:        packagejson.Dependency{Name: name, Version: version},
+							ParentName: item.Dep.Name,
+							BaseDir:    baseDir,
+							IsDev:      item.IsDev,
+							IsOptional: true,
+						}
+					}
+
+					for name, version := range data.PeerDependencies {
+						pendingPeers = append(pendingPeers, peerRequest{
+							Dependent: item.Dep.Name,
+							Dep:       packagejson.Dependency{Name: name, Version: version},
+							Optional:  data.PeerDependenciesMeta[name].Optional,
+						})
+					}
+
+					res.packages[packageKey] = pkgItem
+				}(item)
+			default:
+				workerMutex.Lock()
+				if activeWorkers == 0 {
+					workerMutex.Unlock()
+					break
 				}
-			}(item)
-		default:
-			workerMutex.Lock()
-			if activeWorkers == 0 {
 				workerMutex.Unlock()
-				break
 			}
-			workerMutex.Unlock()
+		}
+
+		wg.Wait()
+		if len(errChan) > 0 {
+			break
+		}
+
+		next := resolvePeers(pendingPeers, res)
+		pendingPeers = nil
+		if len(next) == 0 {
+			break
+		}
+		workChan = make(chan QueueItem, len(next))
+		for _, item := range next {
+			workChan <- item
 		}
 	}
 
-	wg.Wait()
 	close(errChan)
 
 	if err := <-errChan; err != nil {
 		return err
 	}
+
+	if err := pm.Etag.Save(); err != nil {
+		return err
+	}
+
+	packageLock.Packages = hoist(rootItem(&packageLock), pm.workspaceItems(isProduction), res)
+	markDependencyFlags(&packageLock)
 	pm.packageLock = &packageLock
 
 	return nil
 }
 
+// peerRequest is a peer dependency of a resolved package, waiting for the
+// regular dependencies to be resolved before it is
+type peerRequest struct {
+	Dependent string
+	Dep       packagejson.Dependency
+	Optional  bool
+}
+
+// resolvePeers returns the peers still to resolve. A peer a version already
+// fetched satisfies uses it; peers marked optional in peerDependenciesMeta
+// are never fetched for, placement only checks them.
+func resolvePeers(peers []peerRequest, res *resolution) []QueueItem {
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Dep.Name != peers[j].Dep.Name {
+			return peers[i].Dep.Name < peers[j].Dep.Name
+		}
+		return peers[i].Dependent < peers[j].Dependent
+	})
+
+	var next []QueueItem
+	queued := make(map[string]bool)
+	for _, peer := range peers {
+		key := peer.Dep.Name + "@" + peer.Dep.Version
+		if peer.Optional || queued[key] {
+			continue
+		}
+		if _, ok := res.versionOf(peer.Dep.Name, peer.Dep.Version); ok {
+			continue
+		}
+		if best := res.highestSatisfying(peer.Dep.Name, peer.Dep.Version); best != "" {
+			res.resolveTo(peer.Dep.Name, peer.Dep.Version, best)
+			continue
+		}
+		queued[key] = true
+		next = append(next, QueueItem{
+			Dep:        peer.Dep,
+			ParentName: peer.Dependent,
+		})
+	}
+	return next
+}
+
+// readShippedPackage returns the version of a bundled dependency shipped in
+// pkgDir's node_modules, if the tarball really had it
+func readShippedPackage(pkgDir, name string) (string, bool) {
+	content, err := os.ReadFile(filepath.Join(pkgDir, "node_modules", name, "package.json"))
+	if err != nil {
+		return "", false
+	}
+	var shipped struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(content, &shipped); err != nil || shipped.Version == "" {
+		return "", false
+	}
+	return shipped.Version, true
+}
+
+// fetchPackage makes sure the package cache holds name@version, extracted
+// from a tarball that matches expected when it is known. A cached copy is
+// only reused if the integrity recorded when it was extracted matches too;
+// otherwise the tarball is fetched again, so a mismatch is never installed.
+// It returns the integrity of the tarball the cached copy came from.
+func (pm *PackageManager) fetchPackage(name, version, tarballURL, expected string) (string, error) {
+	pkgPath := filepath.Join(pm.packagesPath, name+"@"+version)
+	recordPath := pkgPath + ".integrity"
+
+	// One version is not fetched twice at once
+	defer pm.lockPackage(pkgPath)()
+
+	if utils.FolderExists(pkgPath) {
+		recorded, err := os.ReadFile(recordPath)
+		if err == nil && (expected == "" || integrity.Check(string(recorded), expected) == nil) {
+			return string(recorded), nil
+		}
+		// Extracted before integrity was recorded, or from another tarball
+		if err := os.RemoveAll(pkgPath); err != nil {
+			return "", fmt.Errorf("failed to remove cached %s@%s: %w", name, version, err)
+		}
+	}
+
+	actual, cached := pm.tarball.Cached(tarballURL, name, version, expected)
+	if !cached {
+		if pm.offline {
+			return "", fmt.Errorf("%w: the tarball of %s@%s is not cached", ErrOffline, name, version)
+		}
+		var err error
+		actual, err = pm.tarball.Download(tarballURL, name, version, expected)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err := pm.extractor.Extract(pm.tarball.File(tarballURL, name, version), pkgPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(recordPath, []byte(actual), 0644); err != nil {
+		return "", fmt.Errorf("failed to record integrity of %s@%s: %w", name, version, err)
+	}
+
+	if _, err := pm.store.Import(name+"@"+version, pkgPath); err != nil {
+		return "", err
+	}
+
+	return actual, nil
+}
+
+// lockPackage takes the lock of the package cache entry at pkgPath and
+// returns its unlock. It is held while the entry is written and while it
+// is installed from, so nothing copies a directory being replaced.
+func (pm *PackageManager) lockPackage(pkgPath string) func() {
+	pm.downloadMu.Lock()
+	pkgLock, exists := pm.downloadLocks[pkgPath]
+	if !exists {
+		pkgLock = &sync.Mutex{}
+		pm.downloadLocks[pkgPath] = pkgLock
+	}
+	pm.downloadMu.Unlock()
+
+	pkgLock.Lock()
+	return pkgLock.Unlock
+}
+
 func (pm *PackageManager) addBinToPath() error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
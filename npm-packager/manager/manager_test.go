@@ -1,17 +1,41 @@
 package manager
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"npm-packager/binlink"
 	"npm-packager/config"
 	"npm-packager/etag"
 	"npm-packager/extractor"
+	"npm-packager/integrity"
+	"npm-packager/lifecycle"
 	"npm-packager/manifest"
+	"npm-packager/npmrc"
 	"npm-packager/packagecopy"
 	"npm-packager/packagejson"
+	"npm-packager/publish"
+	"npm-packager/store"
 	"npm-packager/tarball"
+	"npm-packager/utils"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,7 +49,9 @@ func createMockDependencies(t *testing.T, baseDir string) *Dependencies {
 		t.Fatalf("failed to create config: %v", err)
 	}
 
-	manifestInst, err := manifest.NewManifest(baseDir, npmRegistryURL)
+	registry := npmrc.New(npmRegistryURL)
+
+	manifestInst, err := manifest.NewManifest(baseDir, registry)
 	if err != nil {
 		t.Fatalf("failed to create manifest: %v", err)
 	}
@@ -35,17 +61,23 @@ func createMockDependencies(t *testing.T, baseDir string) *Dependencies {
 		t.Fatalf("failed to create etag: %v", err)
 	}
 
+	packageCopy := packagecopy.NewPackageCopy()
+
 	return &Dependencies{
 		Config:            cfg,
 		Manifest:          manifestInst,
 		Etag:              etagInst,
-		Tarball:           tarball.NewTarball(),
+		Tarball:           tarball.NewTarball(registry),
 		Extractor:         extractor.NewTGZExtractor(),
-		PackageCopy:       packagecopy.NewPackageCopy(),
+		PackageCopy:       packageCopy,
+		Store:             store.New(filepath.Join(baseDir, "store"), packageCopy),
 		ParseJsonManifest: newParseJsonManifest(),
 		VersionInfo:       newVersionInfo(),
 		PackageJsonParse:  packagejson.NewPackageJSONParser(cfg),
 		BinLinker:         binlink.NewBinLinker(cfg.LocalNodeModules),
+		Registry:          registry,
+		Publisher:         publish.NewPublisher(registry),
+		Scripts:           lifecycle.NewRunner(),
 	}
 }
 
@@ -67,6 +99,269 @@ func setupTestPackageManager(t *testing.T) (*PackageManager, string, string) {
 	return pm, tmpDir, origDir
 }
 
+// fakePackage is a version of a package served by a fakeRegistry
+type fakePackage struct {
+	name                 string
+	version              string
+	dependencies         map[string]string
+	optionalDependencies map[string]string
+	peerDependencies     map[string]string
+	peerDependenciesMeta map[string]packagejson.PeerDependencyMeta
+	bundleDependencies   []string
+	// Copies shipped in the tarball's node_modules
+	bundled []fakePackage
+	os      []string
+	scripts map[string]string
+}
+
+// fakeRegistry serves manifests and tarballs the way the npm registry
+// does. Tarballs live under /tarballs/ rather than the usual path, so
+// clients have to follow dist.tarball. With a token set, every request
+// must carry it. Manifests publish each tarball's integrity and shasum as
+// it was when the package was added, so a test can swap the tarball after.
+type fakeRegistry struct {
+	*httptest.Server
+	token    string
+	packages map[string][]fakePackage
+	tarballs map[string][]byte
+	// Integrity and shasum by tarball path
+	integrity map[string]string
+	shasums   map[string]string
+	// Publish only the legacy shasum, as old packages do
+	shasumOnly bool
+	// Fail the first request for every path with a 503 and cut the first
+	// tarball body short, as an unreliable network does
+	flaky bool
+
+	mu sync.Mutex
+	// Requests by path, and the manifest requests answered with a 304
+	requests    map[string]int
+	notModified int
+}
+
+func newFakeRegistry(t *testing.T, token string, packages ...fakePackage) *fakeRegistry {
+	t.Helper()
+
+	r := &fakeRegistry{
+		token:     token,
+		packages:  make(map[string][]fakePackage),
+		tarballs:  make(map[string][]byte),
+		integrity: make(map[string]string),
+		shasums:   make(map[string]string),
+		requests:  make(map[string]int),
+	}
+	r.publish(t, packages...)
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// publish adds versions of packages; the last one of each becomes latest
+func (r *fakeRegistry) publish(t *testing.T, packages ...fakePackage) {
+	t.Helper()
+
+	for _, pkg := range packages {
+		tgz := packTestTarball(t, pkg)
+		sha512Sum := sha512.Sum512(tgz)
+		sha1Sum := sha1.Sum(tgz)
+
+		r.packages[pkg.name] = append(r.packages[pkg.name], pkg)
+		r.tarballs[fakeTarballPath(pkg)] = tgz
+		r.integrity[fakeTarballPath(pkg)] = "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:])
+		r.shasums[fakeTarballPath(pkg)] = hex.EncodeToString(sha1Sum[:])
+	}
+}
+
+func fakeTarballPath(pkg fakePackage) string {
+	return fmt.Sprintf("/tarballs/%s-%s.tgz", pkg.name, pkg.version)
+}
+
+// requestCount returns how many requests for path the registry has had
+func (r *fakeRegistry) requestCount(path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[path]
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests[req.URL.EscapedPath()]++
+	count := r.requests[req.URL.EscapedPath()]
+	r.mu.Unlock()
+
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.flaky && count == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if req.Method == http.MethodPut {
+		r.accept(w, req)
+		return
+	}
+	if tgz, ok := r.tarballs[req.URL.Path]; ok {
+		if r.flaky && count == 2 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(tgz)))
+			w.Write(tgz[:len(tgz)/2])
+			return
+		}
+		// Answers Range requests too
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(tgz))
+		return
+	}
+
+	// Manifests are at /<name>, with the slash of a scoped name escaped
+	name, err := url.PathUnescape(strings.TrimPrefix(req.URL.EscapedPath(), "/"))
+	versions, ok := r.packages[name]
+	if err != nil || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	manifest := NPMPackage{Name: name, DistTags: make(map[string]string), Versions: make(map[string]Version)}
+	for _, pkg := range versions {
+		dist := Dist{Tarball: r.URL + fakeTarballPath(pkg), Shasum: r.shasums[fakeTarballPath(pkg)]}
+		if !r.shasumOnly {
+			dist.Integrity = r.integrity[fakeTarballPath(pkg)]
+		}
+		manifest.Versions[pkg.version] = Version{
+			Name:         pkg.name,
+			Version:      pkg.version,
+			Dependencies: pkg.dependencies,
+			OS:           pkg.os,
+			Dist:         dist,
+		}
+		manifest.DistTags["latest"] = pkg.version
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, name, len(versions))
+	if req.Header.Get("If-None-Match") == etag {
+		r.mu.Lock()
+		r.notModified++
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// accept adds the versions a publish PUTs, refusing versions the registry
+// has and attachments that do not match the integrity published with them
+func (r *fakeRegistry) accept(w http.ResponseWriter, req *http.Request) {
+	var doc struct {
+		Name     string `json:"name"`
+		Versions map[string]struct {
+			Dependencies map[string]string `json:"dependencies"`
+			Dist         Dist              `json:"dist"`
+		} `json:"versions"`
+		Attachments map[string]struct {
+			Data string `json:"data"`
+		} `json:"_attachments"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&doc); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for version, manifest := range doc.Versions {
+		for _, existing := range r.packages[doc.Name] {
+			if existing.version == version {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error":"cannot modify pre-existing version"}`))
+				return
+			}
+		}
+		tgz, err := base64.StdEncoding.DecodeString(doc.Attachments[doc.Name+"-"+version+".tgz"].Data)
+		sum := sha512.Sum512(tgz)
+		actual := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+		if err != nil || actual != manifest.Dist.Integrity {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		pkg := fakePackage{name: doc.Name, version: version, dependencies: manifest.Dependencies}
+		r.packages[pkg.name] = append(r.packages[pkg.name], pkg)
+		r.tarballs[fakeTarballPath(pkg)] = tgz
+		r.integrity[fakeTarballPath(pkg)] = actual
+		r.shasums[fakeTarballPath(pkg)] = manifest.Dist.Shasum
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// packTestTarball builds the .tgz of a package, with its files under
+// package/ as npm packs them
+func packTestTarball(t *testing.T, pkg fakePackage) []byte {
+	t.Helper()
+
+	packageJSON := func(pkg fakePackage) []byte {
+		content, err := json.Marshal(map[string]any{
+			"name":                 pkg.name,
+			"version":              pkg.version,
+			"dependencies":         pkg.dependencies,
+			"optionalDependencies": pkg.optionalDependencies,
+			"peerDependencies":     pkg.peerDependencies,
+			"peerDependenciesMeta": pkg.peerDependenciesMeta,
+			"bundleDependencies":   pkg.bundleDependencies,
+			"os":                   pkg.os,
+			"scripts":              pkg.scripts,
+		})
+		assert.NoError(t, err)
+		return content
+	}
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	files := map[string][]byte{
+		"package/package.json": packageJSON(pkg),
+		"package/index.js":     []byte("module.exports = '" + pkg.name + "'\n"),
+	}
+	for _, shipped := range pkg.bundled {
+		files["package/node_modules/"+shipped.name+"/package.json"] = packageJSON(shipped)
+	}
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		assert.NoError(t, err)
+		_, err = tw.Write(content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+// setupRegistryPackageManager creates a test PackageManager that fetches
+// from the registries in registry, with its package cache and tarballs in
+// the temp directory
+func setupRegistryPackageManager(t *testing.T, registry *npmrc.Config) (*PackageManager, string, string) {
+	t.Helper()
+
+	pm, tmpDir, origDir := setupTestPackageManager(t)
+
+	// Retries come quickly, so a flaky registry does not slow tests down
+	downloader := utils.NewDownloader(utils.DefaultConcurrency)
+	downloader.Backoff = time.Millisecond
+	downloader.Progress = utils.NewProgress(io.Discard)
+
+	manifestInst, err := manifest.NewManifest(tmpDir, registry)
+	assert.NoError(t, err)
+	manifestInst.Downloader = downloader
+	pm.manifest = manifestInst
+	pm.tarball = tarball.NewTarball(registry)
+	pm.tarball.TarballPath = filepath.Join(tmpDir, "tarballs")
+	pm.tarball.Downloader = downloader
+	pm.registry = registry
+	pm.publisher = publish.NewPublisher(registry)
+	pm.packagesPath = filepath.Join(tmpDir, "packages")
+	assert.NoError(t, os.MkdirAll(pm.packagesPath, 0755))
+
+	return pm, tmpDir, origDir
+}
+
 func TestNew(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -105,6 +400,7 @@ func TestNew(t *testing.T) {
 				assert.NotNil(t, pm.tarball)
 				assert.NotNil(t, pm.extractor)
 				assert.NotNil(t, pm.packageCopy)
+				assert.NotNil(t, pm.store)
 				assert.NotNil(t, pm.manifest)
 				assert.NotNil(t, pm.parseJsonManifest)
 				assert.NotNil(t, pm.versionInfo)
@@ -604,6 +900,561 @@ func TestFetchToCache(t *testing.T) {
 	}
 }
 
+func TestFetchToCache_Registry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		npmrc       func(public, private *fakeRegistry) string
+		expectError bool
+		validate    func(t *testing.T, pm *PackageManager, private *fakeRegistry)
+	}{
+		{
+			name: "scoped package from private registry with token, dependency from default registry",
+			npmrc: func(public, private *fakeRegistry) string {
+				return "registry=" + public.URL + "\n" +
+					"@company:registry=" + private.URL + "\n" +
+					"//" + strings.TrimPrefix(private.URL, "http://") + "/:_authToken=${TEST_COMPANY_TOKEN}\n"
+			},
+			validate: func(t *testing.T, pm *PackageManager, private *fakeRegistry) {
+				assert.FileExists(t, filepath.Join(pm.packagesPath, "@company/widget@1.2.0", "package.json"))
+				assert.FileExists(t, filepath.Join(pm.packagesPath, "helper@1.1.0", "package.json"))
+
+				widget := pm.packageLock.Packages["node_modules/@company/widget"]
+				assert.Equal(t, "1.2.0", widget.Version)
+				assert.Equal(t, private.URL+"/tarballs/@company/widget-1.2.0.tgz", widget.Resolved)
+				assert.Equal(t, "1.1.0", pm.packageLock.Packages["node_modules/helper"].Version)
+			},
+		},
+		{
+			name: "fails without the private registry's token",
+			npmrc: func(public, private *fakeRegistry) string {
+				return "registry=" + public.URL + "\n@company:registry=" + private.URL + "\n"
+			},
+			expectError: true,
+		},
+		{
+			name: "fails when the scope has no registry configured",
+			npmrc: func(public, private *fakeRegistry) string {
+				return "registry=" + public.URL + "\n"
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TEST_COMPANY_TOKEN", "company-secret")
+			public := newFakeRegistry(t, "",
+				fakePackage{name: "helper", version: "1.0.0"},
+				fakePackage{name: "helper", version: "1.1.0"},
+				fakePackage{name: "helper", version: "2.0.0"},
+			)
+			private := newFakeRegistry(t, "company-secret",
+				fakePackage{name: "@company/widget", version: "1.0.0"},
+				fakePackage{name: "@company/widget", version: "1.2.0", dependencies: map[string]string{"helper": "^1.0.0"}},
+			)
+
+			npmrcPath := filepath.Join(t.TempDir(), ".npmrc")
+			assert.NoError(t, os.WriteFile(npmrcPath, []byte(tc.npmrc(public, private)), 0644))
+			registry, err := npmrc.Load(npmRegistryURL, npmrcPath)
+			assert.NoError(t, err)
+
+			pm, _, origDir := setupRegistryPackageManager(t, registry)
+			defer os.Chdir(origDir)
+
+			err = pm.fetchToCache(packagejson.PackageJSON{
+				Dependencies: map[string]string{"@company/widget": "^1.0.0"},
+			}, false)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tc.validate(t, pm, private)
+		})
+	}
+}
+
+func TestFetchToCache_Integrity(t *testing.T) {
+	helper := fakePackage{name: "helper", version: "1.0.0"}
+
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T, r *fakeRegistry, pm *PackageManager)
+		expectError bool
+		validate    func(t *testing.T, r *fakeRegistry, pm *PackageManager)
+	}{
+		{
+			name: "records dist.integrity in the lock and the cache",
+			validate: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				expected := r.integrity[fakeTarballPath(helper)]
+				assert.Equal(t, expected, pm.packageLock.Packages["node_modules/helper"].Integrity)
+
+				recorded, err := os.ReadFile(filepath.Join(pm.packagesPath, "helper@1.0.0.integrity"))
+				assert.NoError(t, err)
+				assert.NoError(t, integrity.Check(string(recorded), expected))
+			},
+		},
+		{
+			name: "falls back to dist.shasum",
+			setupFunc: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				r.shasumOnly = true
+			},
+			validate: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				assert.Equal(t, integrity.FromShasum(r.shasums[fakeTarballPath(helper)]),
+					pm.packageLock.Packages["node_modules/helper"].Integrity)
+				assert.FileExists(t, filepath.Join(pm.packagesPath, "helper@1.0.0", "package.json"))
+			},
+		},
+		{
+			name: "refuses a tarball that does not match",
+			setupFunc: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				r.tarballs[fakeTarballPath(helper)] = packTestTarball(t, fakePackage{name: "helper", version: "6.6.6"})
+			},
+			expectError: true,
+			validate: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				assert.NoDirExists(t, filepath.Join(pm.packagesPath, "helper@1.0.0"))
+			},
+		},
+		{
+			name: "replaces a cached copy without a matching record",
+			setupFunc: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				cached := filepath.Join(pm.packagesPath, "helper@1.0.0")
+				assert.NoError(t, os.MkdirAll(cached, 0755))
+				assert.NoError(t, os.WriteFile(filepath.Join(cached, "package.json"), []byte(`{"name":"tampered"}`), 0644))
+				assert.NoError(t, os.WriteFile(cached+".integrity", []byte("sha512-bm90IHRoZSB0YXJiYWxs"), 0644))
+			},
+			validate: func(t *testing.T, r *fakeRegistry, pm *PackageManager) {
+				content, err := os.ReadFile(filepath.Join(pm.packagesPath, "helper@1.0.0", "package.json"))
+				assert.NoError(t, err)
+				assert.Contains(t, string(content), `"name":"helper"`)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "", helper)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			if tc.setupFunc != nil {
+				tc.setupFunc(t, r, pm)
+			}
+
+			err := pm.fetchToCache(packagejson.PackageJSON{
+				Dependencies: map[string]string{"helper": "^1.0.0"},
+			}, false)
+
+			if tc.expectError {
+				assert.ErrorIs(t, err, integrity.ErrMismatch)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.validate(t, r, pm)
+		})
+	}
+}
+
+func TestCI(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T, pm *PackageManager)
+		expectError error
+		validate    func(t *testing.T, pm *PackageManager, lockBefore []byte)
+	}{
+		{
+			name: "installs the locked versions into a clean node_modules",
+			setupFunc: func(t *testing.T, pm *PackageManager) {
+				assert.NoError(t, os.MkdirAll(filepath.Join(pm.extractedPath, "leftover"), 0755))
+			},
+			validate: func(t *testing.T, pm *PackageManager, lockBefore []byte) {
+				assert.FileExists(t, filepath.Join(pm.extractedPath, "widget", "package.json"))
+				assert.FileExists(t, filepath.Join(pm.extractedPath, "helper", "package.json"))
+				assert.NoDirExists(t, filepath.Join(pm.extractedPath, "leftover"))
+
+				lockAfter, err := os.ReadFile(pm.packageJsonParse.LockFileName)
+				assert.NoError(t, err)
+				assert.Equal(t, string(lockBefore), string(lockAfter), "ci should not write the lock file")
+			},
+		},
+		{
+			name: "fails when package.json and the lock disagree",
+			setupFunc: func(t *testing.T, pm *PackageManager) {
+				err := os.WriteFile("package.json", []byte(`{"dependencies":{"widget":"^2.0.0"}}`), 0644)
+				assert.NoError(t, err)
+			},
+			expectError: errors.New("out of sync"),
+		},
+		{
+			name: "fails without a lock file",
+			setupFunc: func(t *testing.T, pm *PackageManager) {
+				assert.NoError(t, os.Remove(pm.packageJsonParse.LockFileName))
+			},
+			expectError: errors.New("ci needs a valid go-package-lock.json"),
+		},
+		{
+			name: "refuses tarballs that do not match the lock",
+			setupFunc: func(t *testing.T, pm *PackageManager) {
+				lock, err := pm.packageJsonParse.ParseLockFile()
+				assert.NoError(t, err)
+				item := lock.Packages["node_modules/helper"]
+				item.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(make([]byte, sha512.Size))
+				lock.Packages["node_modules/helper"] = item
+				assert.NoError(t, pm.packageJsonParse.CreateLockFile(lock, false))
+			},
+			expectError: integrity.ErrMismatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "helper", version: "1.0.0"},
+				fakePackage{name: "widget", version: "1.0.0", dependencies: map[string]string{"helper": "^1.0.0"}},
+			)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			// Lock with go-npm i, then start over from an empty cache
+			assert.NoError(t, os.WriteFile("package.json", []byte(`{"dependencies":{"widget":"^1.0.0"}}`), 0644))
+			assert.NoError(t, pm.ParsePackageJSON(false))
+			assert.NoError(t, os.RemoveAll(pm.packagesPath))
+			lockBefore, err := os.ReadFile(pm.packageJsonParse.LockFileName)
+			assert.NoError(t, err)
+
+			if tc.setupFunc != nil {
+				tc.setupFunc(t, pm)
+			}
+
+			err = pm.CI(false)
+			if err == nil {
+				err = pm.InstallFromCache()
+			}
+
+			switch {
+			case tc.expectError == nil:
+				assert.NoError(t, err)
+				tc.validate(t, pm, lockBefore)
+			case errors.Is(tc.expectError, integrity.ErrMismatch):
+				assert.ErrorIs(t, err, integrity.ErrMismatch)
+				assert.NoDirExists(t, filepath.Join(pm.extractedPath, "helper"))
+			default:
+				assert.ErrorContains(t, err, tc.expectError.Error())
+			}
+		})
+	}
+}
+
+func TestInstallFromCache_Scripts(t *testing.T) {
+	// Scripts append to a file in the project, INIT_CWD
+	logScript := func(name string) string {
+		return "echo " + name + " $npm_lifecycle_event >> \"$INIT_CWD/scripts.log\""
+	}
+
+	testCases := []struct {
+		name          string
+		packages      []fakePackage
+		ignoreScripts bool
+		expectError   bool
+		expectedLog   string
+	}{
+		{
+			name: "runs install scripts, dependencies first",
+			packages: []fakePackage{
+				{name: "native", version: "1.0.0", scripts: map[string]string{
+					"preinstall":  logScript("native"),
+					"install":     logScript("native"),
+					"postinstall": logScript("native"),
+				}},
+				{name: "app-lib", version: "1.0.0", dependencies: map[string]string{"native": "^1.0.0"}, scripts: map[string]string{
+					"postinstall": logScript("app-lib"),
+					"test":        logScript("app-lib"),
+				}},
+			},
+			expectedLog: "native preinstall\nnative install\nnative postinstall\napp-lib postinstall\n",
+		},
+		{
+			name: "ignore-scripts skips them",
+			packages: []fakePackage{
+				{name: "app-lib", version: "1.0.0", scripts: map[string]string{"postinstall": logScript("app-lib")}},
+			},
+			ignoreScripts: true,
+			expectedLog:   "",
+		},
+		{
+			name: "a failing script fails the install",
+			packages: []fakePackage{
+				{name: "app-lib", version: "1.0.0", scripts: map[string]string{"install": "exit 1"}},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "", tc.packages...)
+			pm, tmpDir, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+			pm.SetIgnoreScripts(tc.ignoreScripts)
+
+			err := pm.fetchToCache(packagejson.PackageJSON{
+				Dependencies: map[string]string{"app-lib": "^1.0.0"},
+			}, false)
+			assert.NoError(t, err)
+
+			err = pm.InstallFromCache()
+			if tc.expectError {
+				var scriptErr *lifecycle.ScriptError
+				assert.ErrorAs(t, err, &scriptErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			log, err := os.ReadFile(filepath.Join(tmpDir, "scripts.log"))
+			if tc.expectedLog == "" {
+				assert.True(t, os.IsNotExist(err), "no script should have run")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedLog, string(log))
+		})
+	}
+}
+
+func TestFetchToCache_DependencyKinds(t *testing.T) {
+	// A platform no package can be installed on here
+	otherOS := []string{"!" + nodePlatform()}
+
+	packages := []fakePackage{
+		{name: "react", version: "17.0.0"},
+		{name: "react", version: "18.0.0"},
+		{name: "helper", version: "1.0.0"},
+		{name: "native-only", version: "1.0.0", os: otherOS},
+		{
+			name:                 "ui-lib",
+			version:              "1.0.0",
+			dependencies:         map[string]string{"vendored": "^1.0.0", "native-only": "^1.0.0"},
+			optionalDependencies: map[string]string{"native-only": "^1.0.0"},
+			peerDependencies:     map[string]string{"react": "^18.0.0", "react-dom": "^18.0.0"},
+			peerDependenciesMeta: map[string]packagejson.PeerDependencyMeta{"react-dom": {Optional: true}},
+			bundleDependencies:   []string{"vendored"},
+			bundled:              []fakePackage{{name: "vendored", version: "1.4.0"}},
+		},
+		{name: "opt-lib", version: "1.0.0", dependencies: map[string]string{"helper": "^1.0.0"}},
+		{name: "app-lib", version: "1.0.0", dependencies: map[string]string{"helper": "^1.0.0"}},
+		{name: "dev-tool", version: "1.0.0"},
+		{name: "broken-build", version: "1.0.0", scripts: map[string]string{"install": "exit 1"}},
+	}
+
+	testCases := []struct {
+		name        string
+		packageJSON packagejson.PackageJSON
+		setupFunc   func(t *testing.T, r *fakeRegistry)
+		expectError string
+		validate    func(t *testing.T, lock *packagejson.PackageLock)
+	}{
+		{
+			name:        "installs peers, leaves out unsupported optionals and keeps bundled copies",
+			packageJSON: packagejson.PackageJSON{Dependencies: map[string]string{"ui-lib": "^1.0.0"}},
+			validate: func(t *testing.T, lock *packagejson.PackageLock) {
+				assert.Equal(t, "18.0.0", lock.Packages["node_modules/react"].Version)
+				assert.True(t, lock.Packages["node_modules/react"].Peer)
+				assert.NotContains(t, lock.Packages, "node_modules/react-dom")
+				assert.NotContains(t, lock.Packages, "node_modules/native-only")
+				assert.NotContains(t, lock.Packages, "node_modules/vendored")
+
+				uiLib := lock.Packages["node_modules/ui-lib"]
+				assert.Equal(t, []string{"vendored"}, uiLib.BundleDependencies)
+				assert.Equal(t, map[string]string{"native-only": "^1.0.0"}, uiLib.OptionalDependencies)
+				assert.Equal(t, map[string]string{"vendored": "^1.0.0"}, uiLib.Dependencies)
+				assert.True(t, uiLib.PeerDependenciesMeta["react-dom"].Optional)
+				assert.Equal(t, packagejson.PackageItem{Name: "vendored", Version: "1.4.0", InBundle: true},
+					lock.Packages["node_modules/ui-lib/node_modules/vendored"])
+
+				assert.FileExists(t, filepath.Join("node_modules", "react", "package.json"))
+				assert.FileExists(t, filepath.Join("node_modules", "ui-lib", "node_modules", "vendored", "package.json"))
+				assert.NoDirExists(t, filepath.Join("node_modules", "vendored"))
+			},
+		},
+		{
+			name: "a peer conflicting with a direct dependency keeps the direct one",
+			packageJSON: packagejson.PackageJSON{Dependencies: map[string]string{
+				"ui-lib": "^1.0.0",
+				"react":  "^17.0.0",
+			}},
+			validate: func(t *testing.T, lock *packagejson.PackageLock) {
+				assert.Equal(t, "17.0.0", lock.Packages["node_modules/react"].Version)
+				assert.False(t, lock.Packages["node_modules/react"].Peer)
+			},
+		},
+		{
+			name:        "an unsupported platform fails a required package",
+			packageJSON: packagejson.PackageJSON{Dependencies: map[string]string{"native-only": "^1.0.0"}},
+			expectError: "does not support",
+		},
+		{
+			name: "optional dependencies that fail are left out",
+			packageJSON: packagejson.PackageJSON{OptionalDependencies: map[string]string{
+				"native-only":  "^1.0.0",
+				"not-on-npm":   "^1.0.0",
+				"broken-build": "^1.0.0",
+			}},
+			validate: func(t *testing.T, lock *packagejson.PackageLock) {
+				assert.Len(t, lock.OptionalDependencies, 3)
+				assert.Empty(t, lock.Dependencies)
+				assert.Equal(t, []string{"node_modules/broken-build"}, sortedKeys(lock.Packages))
+				assert.True(t, lock.Packages["node_modules/broken-build"].Optional)
+				// Its install script failed, so it is removed again
+				assert.NoDirExists(t, filepath.Join("node_modules", "broken-build"))
+			},
+		},
+		{
+			name: "a package that failed as an optional dependency still fails a required one",
+			packageJSON: packagejson.PackageJSON{
+				Dependencies:         map[string]string{"app-lib": "^1.0.0"},
+				OptionalDependencies: map[string]string{"helper": "^1.0.0"},
+			},
+			setupFunc: func(t *testing.T, r *fakeRegistry) {
+				r.tarballs[fakeTarballPath(fakePackage{name: "helper", version: "1.0.0"})] = packTestTarball(t, fakePackage{name: "helper", version: "6.6.6"})
+			},
+			expectError: "integrity mismatch",
+		},
+		{
+			name: "flags packages only reached through optional or dev dependencies",
+			packageJSON: packagejson.PackageJSON{
+				OptionalDependencies: map[string]string{"opt-lib": "^1.0.0"},
+				DevDependencies:      map[string]string{"dev-tool": "^1.0.0"},
+			},
+			validate: func(t *testing.T, lock *packagejson.PackageLock) {
+				assert.Equal(t, map[string]string{"opt-lib": "^1.0.0"}, lock.OptionalDependencies)
+				for pkgPath, flags := range map[string][2]bool{
+					"node_modules/opt-lib":  {false, true},
+					"node_modules/helper":   {false, true},
+					"node_modules/dev-tool": {true, false},
+				} {
+					assert.Equal(t, flags[0], lock.Packages[pkgPath].Dev, pkgPath)
+					assert.Equal(t, flags[1], lock.Packages[pkgPath].Optional, pkgPath)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "", packages...)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+			if tc.setupFunc != nil {
+				tc.setupFunc(t, r)
+			}
+
+			err := pm.fetchToCache(tc.packageJSON, false)
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, pm.InstallFromCache())
+			tc.validate(t, pm.packageLock)
+		})
+	}
+}
+
+func TestInstallWorkspaces(t *testing.T) {
+	// installedVersion finds the version of pkg a workspace in dir gets, looking
+	// in its own node_modules first as node does
+	installedVersion := func(t *testing.T, dir, pkg string) string {
+		for _, candidate := range []string{filepath.Join(dir, "node_modules", pkg), filepath.Join("node_modules", pkg)} {
+			content, err := os.ReadFile(filepath.Join(candidate, "package.json"))
+			if err != nil {
+				continue
+			}
+			var pj struct{ Version string }
+			assert.NoError(t, json.Unmarshal(content, &pj))
+			return pj.Version
+		}
+		return ""
+	}
+
+	// Workspaces are under libs/, as the test package cache is packages/
+	testCases := []struct {
+		name        string
+		appJSON     string
+		expectError string
+		validate    func(t *testing.T, pm *PackageManager)
+	}{
+		{
+			name:    "links workspaces, hoists shared dependencies and nests conflicting ones",
+			appJSON: `{"name":"@repo/app","version":"0.1.0","dependencies":{"@repo/utils":"workspace:^","helper":"^2.0.0"}}`,
+			validate: func(t *testing.T, pm *PackageManager) {
+				target, err := os.Readlink(filepath.Join("node_modules", "@repo", "utils"))
+				assert.NoError(t, err)
+				assert.Equal(t, filepath.Join("..", "..", "libs", "utils"), target)
+				assert.FileExists(t, filepath.Join("node_modules", "@repo", "app", "package.json"))
+
+				assert.DirExists(t, filepath.Join("node_modules", "shared"))
+				assert.NoDirExists(t, filepath.Join("libs", "utils", "node_modules", "shared"))
+				assert.Equal(t, "2.0.0", installedVersion(t, filepath.Join("libs", "app"), "helper"))
+				assert.Equal(t, "1.0.0", installedVersion(t, filepath.Join("libs", "utils"), "helper"))
+
+				lock, err := pm.packageJsonParse.ParseLockFile()
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]string{"shared": "^1.0.0"}, lock.DevDependencies)
+				assert.Equal(t, "1.2.0", lock.Packages["libs/utils"].Version)
+				assert.Equal(t, "workspace:^", lock.Packages["libs/app"].Dependencies["@repo/utils"])
+				assert.Equal(t, packagejson.PackageItem{Name: "@repo/utils", Version: "1.2.0", Resolved: "libs/utils", Link: true},
+					lock.Packages["node_modules/@repo/utils"])
+
+				assert.NoError(t, pm.RunWorkspaceScript("@repo/utils", "build", nil))
+				assert.FileExists(t, filepath.Join("libs", "utils", "built.txt"))
+				assert.Error(t, pm.RunWorkspaceScript("@repo/missing", "build", nil))
+			},
+		},
+		{
+			name:        "fails when a workspace range does not match",
+			appJSON:     `{"name":"@repo/app","dependencies":{"@repo/utils":"workspace:^2.0.0"}}`,
+			expectError: "but the workspace is at 1.2.0",
+		},
+		{
+			name:        "fails on an unknown workspace",
+			appJSON:     `{"name":"@repo/app","dependencies":{"@repo/ui":"workspace:*"}}`,
+			expectError: "no workspace named @repo/ui",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "helper", version: "1.0.0"},
+				fakePackage{name: "helper", version: "2.0.0"},
+				fakePackage{name: "shared", version: "1.0.0"},
+			)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			files := map[string]string{
+				"package.json":            `{"name":"mono","private":true,"workspaces":["libs/*"],"devDependencies":{"shared":"^1.0.0"}}`,
+				"libs/utils/package.json": `{"name":"@repo/utils","version":"1.2.0","dependencies":{"helper":"^1.0.0","shared":"^1.0.0"},"scripts":{"build":"echo built > built.txt"}}`,
+				"libs/app/package.json":   tc.appJSON,
+			}
+			for name, content := range files {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+				assert.NoError(t, os.WriteFile(name, []byte(content), 0644))
+			}
+
+			err := pm.ParsePackageJSON(false)
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, pm.InstallFromCache())
+			tc.validate(t, pm)
+		})
+	}
+}
+
 func TestInstallFromCache(t *testing.T) {
 	testCases := []struct {
 		name        string
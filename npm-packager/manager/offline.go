@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrOffline is returned in offline mode for anything not in the cache
+var ErrOffline = errors.New("offline mode")
+
+// SetOffline makes installs use only cached manifests and tarballs, never
+// the network
+func (pm *PackageManager) SetOffline(offline bool) {
+	pm.offline = offline
+}
+
+// SetPreferOffline makes installs use cached manifests without asking the
+// registry whether they changed, as long as they have a version to offer
+func (pm *PackageManager) SetPreferOffline(preferOffline bool) {
+	pm.preferOffline = preferOffline
+}
+
+// loadManifest returns the manifest of name and its ETag. Offline it comes
+// from the cache; preferring offline, from the cache when that has a
+// version matching spec (any version, for an empty spec). Otherwise the
+// cached copy is revalidated with its ETag, and used as it is if the
+// registry cannot be reached.
+func (pm *PackageManager) loadManifest(name, spec string) (*NPMPackage, string, error) {
+	manifestPath := filepath.Join(pm.manifest.Path, name+".json")
+
+	if pm.offline {
+		cached, err := pm.parseJsonManifest.parse(manifestPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: the manifest of %s is not cached", ErrOffline, name)
+		}
+		return cached, pm.Etag.Get(name), nil
+	}
+	if pm.preferOffline {
+		cached, err := pm.parseJsonManifest.parse(manifestPath)
+		if err == nil && (spec == "" || pm.versionInfo.getVersion(spec, cached) != "") {
+			return cached, pm.Etag.Get(name), nil
+		}
+	}
+
+	var currentEtag string
+	_, statErr := os.Stat(manifestPath)
+	if statErr == nil {
+		currentEtag = pm.Etag.Get(name)
+	}
+	newEtag, _, err := pm.manifest.Download(name, currentEtag)
+	if err != nil {
+		if statErr == nil {
+			if cached, cacheErr := pm.parseJsonManifest.parse(manifestPath); cacheErr == nil {
+				fmt.Printf("Warning: using the cached manifest of %s: %v\n", name, err)
+				return cached, currentEtag, nil
+			}
+		}
+		return nil, "", err
+	}
+	pm.Etag.Set(name, newEtag)
+
+	npmPackage, err := pm.parseJsonManifest.parse(manifestPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return npmPackage, newEtag, nil
+}
+
+// manifestLoads remembers the manifests loaded during one install or
+// refresh, so each is revalidated with the registry at most once
+type manifestLoads struct {
+	pm      *PackageManager
+	mu      sync.Mutex
+	entries map[string]*manifestLoad
+}
+
+type manifestLoad struct {
+	mu         sync.Mutex
+	loaded     bool
+	npmPackage *NPMPackage
+	etag       string
+	err        error
+	// Loaded again because the cached copy had no matching version
+	revalidated bool
+}
+
+func (pm *PackageManager) newManifestLoads() *manifestLoads {
+	return &manifestLoads{pm: pm, entries: make(map[string]*manifestLoad)}
+}
+
+// load returns the manifest of name as loadManifest does, the first time it
+// is asked for. Preferring offline, a cached copy without a version
+// matching spec is loaded once more, so the registry is asked for one.
+func (l *manifestLoads) load(name, spec string) (*NPMPackage, string, error) {
+	l.mu.Lock()
+	entry, ok := l.entries[name]
+	if !ok {
+		entry = &manifestLoad{}
+		l.entries[name] = entry
+	}
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.loaded {
+		if !l.pm.preferOffline || entry.revalidated || entry.err != nil ||
+			spec == "" || l.pm.versionInfo.getVersion(spec, entry.npmPackage) != "" {
+			return entry.npmPackage, entry.etag, entry.err
+		}
+		entry.revalidated = true
+	}
+	entry.npmPackage, entry.etag, entry.err = l.pm.loadManifest(name, spec)
+	entry.loaded = true
+	return entry.npmPackage, entry.etag, entry.err
+}
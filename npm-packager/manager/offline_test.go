@@ -0,0 +1,165 @@
+package manager
+
+import (
+	"npm-packager/npmrc"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const offlineTestProject = `{"name":"app","version":"1.0.0","dependencies":{"first":"^1.0.0"}}`
+
+// forgetInstall removes node_modules, the lock and the extracted package
+// cache, so the next install resolves from manifests and tarballs again
+func forgetInstall(t *testing.T, pm *PackageManager) {
+	t.Helper()
+	assert.NoError(t, os.RemoveAll("node_modules"))
+	assert.NoError(t, os.RemoveAll(pm.packageJsonParse.LockFileName))
+	assert.NoError(t, os.RemoveAll(pm.packagesPath))
+	assert.NoError(t, os.MkdirAll(pm.packagesPath, 0755))
+	pm.packageJsonParse.PackageLock = nil
+}
+
+func TestInstall_Offline(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T, pm *PackageManager, r *fakeRegistry) string
+		expectError error
+		validate    func(t *testing.T, pm *PackageManager, r *fakeRegistry)
+	}{
+		{
+			name: "offline installs from cached manifests and tarballs",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				forgetInstall(t, pm)
+				r.Close()
+				pm.SetOffline(true)
+				return offlineTestProject
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "shared")))
+			},
+		},
+		{
+			name: "offline fails for a package that is not cached",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				pm.SetOffline(true)
+				return offlineTestProject
+			},
+			expectError: ErrOffline,
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, 0, r.requestCount("/first"))
+			},
+		},
+		{
+			name: "prefer-offline uses cached manifests without asking the registry",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				forgetInstall(t, pm)
+				pm.SetPreferOffline(true)
+				return offlineTestProject
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, 1, r.requestCount("/first"))
+				assert.Equal(t, 1, r.requestCount("/shared"))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+			},
+		},
+		{
+			name: "prefer-offline fetches a manifest whose cached copy has no matching version",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				forgetInstall(t, pm)
+				r.publish(t, fakePackage{name: "first", version: "2.0.0"})
+				pm.SetPreferOffline(true)
+				return `{"name":"app","version":"1.0.0","dependencies":{"first":"^2.0.0"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, 2, r.requestCount("/first"))
+				assert.Equal(t, "2.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+			},
+		},
+		{
+			name: "online revalidates cached manifests with their ETag",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				forgetInstall(t, pm)
+				return offlineTestProject
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, 2, r.notModified)
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+			},
+		},
+		{
+			name: "online revalidates a manifest once however many packages depend on it",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				forgetInstall(t, pm)
+				return `{"name":"app","version":"1.0.0","dependencies":{"first":"^1.0.0","shared":"^1.0.0"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, 2, r.requestCount("/shared"))
+				assert.Equal(t, 2, r.notModified)
+			},
+		},
+		{
+			name: "online falls back to cached manifests when the registry is gone",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				installProject(t, pm, offlineTestProject)
+				// The extracted packages stay, so no tarball is needed
+				assert.NoError(t, os.RemoveAll("node_modules"))
+				assert.NoError(t, os.RemoveAll(pm.packageJsonParse.LockFileName))
+				pm.packageJsonParse.PackageLock = nil
+				r.Close()
+				return offlineTestProject
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+			},
+		},
+		{
+			name: "a flaky registry is retried and cut off tarballs resumed",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				r.flaky = true
+				return offlineTestProject
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				// A 503, then the manifest
+				assert.Equal(t, 2, r.requestCount("/first"))
+				// A 503, half the tarball, then the rest of it
+				assert.Equal(t, 3, r.requestCount("/tarballs/first-1.0.0.tgz"))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "shared")))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "first", version: "1.0.0", dependencies: map[string]string{"shared": "^1.0.0"}},
+				fakePackage{name: "shared", version: "1.0.0"},
+			)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			packageJSON := tc.setupFunc(t, pm, r)
+			assert.NoError(t, os.WriteFile("package.json", []byte(packageJSON), 0644))
+
+			err := pm.ParsePackageJSON(false)
+			if err == nil {
+				err = pm.InstallFromCache()
+			}
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.validate(t, pm, r)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"fmt"
+	"npm-packager/depspec"
+	"npm-packager/packagejson"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestWorkers bounds how many manifests are refreshed at once
+const manifestWorkers = 16
+
+// OutdatedPackage is a dependency with a newer version than the one
+// installed: Wanted is the highest in its range, Latest the latest tag
+type OutdatedPackage struct {
+	Name       string
+	Current    string
+	Wanted     string
+	Latest     string
+	DependedBy string
+}
+
+// refreshManifests revalidates the cached manifests of names, or downloads
+// them, so newer versions show up
+func (pm *PackageManager) refreshManifests(names []string) (map[string]*NPMPackage, error) {
+	manifests := make(map[string]*NPMPackage, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, manifestWorkers)
+	loads := pm.newManifestLoads()
+
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			npmPackage, _, err := loads.load(name, "")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to fetch the manifest of %s: %w", name, err)
+				}
+				return
+			}
+			manifests[name] = npmPackage
+		}(name)
+	}
+	wg.Wait()
+
+	return manifests, firstErr
+}
+
+// Outdated compares the installed version of every dependency of the
+// project and its workspaces with the highest version its range allows
+// and with the latest one published, and returns those behind either,
+// sorted by name
+func (pm *PackageManager) Outdated() ([]OutdatedPackage, error) {
+	root, err := readInstalled(".", pm.extractedPath, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	type dependency struct {
+		edge   installedEdge
+		parent *installedPackage
+	}
+	var dependencies []dependency
+	names := make(map[string]bool)
+	dependents := []*installedPackage{root}
+	for _, name := range sortedNames(root.children) {
+		if child := root.children[name]; child.link != "" {
+			dependents = append(dependents, child)
+		}
+	}
+	for _, pkg := range dependents {
+		for _, edge := range pkg.edges() {
+			// Peers come from the dependent, workspaces from the project;
+			// git, file: and link: dependencies have no versions to compare
+			if edge.peer || !depspec.IsRegistry(edge.spec) || edge.found != nil && edge.found.link != "" {
+				continue
+			}
+			dependencies = append(dependencies, dependency{edge: edge, parent: pkg})
+			names[edge.name] = true
+		}
+	}
+
+	manifests, err := pm.refreshManifests(sortedKeys(names))
+	if err != nil {
+		return nil, err
+	}
+
+	var outdated []OutdatedPackage
+	for _, dep := range dependencies {
+		npmPackage := manifests[dep.edge.name]
+		current := "MISSING"
+		if dep.edge.found != nil {
+			current = dep.edge.found.Version
+		}
+		wanted := pm.versionInfo.getVersion(dep.edge.spec, npmPackage)
+		latest := npmPackage.DistTags["latest"]
+		if current == wanted && current == latest {
+			continue
+		}
+		dependedBy := dep.parent.Name
+		if dependedBy == "" {
+			dependedBy = "the root project"
+		}
+		outdated = append(outdated, OutdatedPackage{
+			Name:       dep.edge.name,
+			Current:    current,
+			Wanted:     wanted,
+			Latest:     latest,
+			DependedBy: dependedBy,
+		})
+	}
+
+	sort.SliceStable(outdated, func(i, j int) bool { return outdated[i].Name < outdated[j].Name })
+	return outdated, nil
+}
+
+// Update resolves the dependencies of package.json again within their
+// ranges, with fresh manifests, and rewrites the lock file. Given names,
+// only those packages move; everything else keeps the version locked for
+// it while that still satisfies its range. What changed is removed from
+// node_modules for InstallFromCache to put the new versions in place.
+func (pm *PackageManager) Update(names []string) error {
+	data, err := pm.packageJsonParse.ParseDefault()
+	if err != nil {
+		return err
+	}
+
+	lock, err := pm.packageJsonParse.ParseLockFile()
+	if err != nil {
+		lock = &packagejson.PackageLock{}
+	}
+
+	locked := make(map[string][]string)
+	// Packages from git, directories and tarballs have no manifest to refresh
+	sources := make(map[string]bool)
+	for pkgPath, item := range lock.Packages {
+		if strings.HasPrefix(pkgPath, "node_modules/") && !item.Link && !item.InBundle {
+			name := packageName(pkgPath)
+			locked[name] = append(locked[name], item.Version)
+			if spec := depspec.Parse(item.Resolved); spec.Type != depspec.Registry && spec.Type != depspec.RemoteTarball {
+				sources[name] = true
+			}
+		}
+	}
+
+	updating := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := locked[name]; !ok {
+			return fmt.Errorf("%s is not installed", name)
+		}
+		updating[name] = true
+	}
+	if len(names) == 0 {
+		for name := range locked {
+			updating[name] = true
+		}
+	}
+
+	// Everything not being updated keeps its version
+	pm.lockedVersions = make(map[string][]string)
+	for name, versions := range locked {
+		if !updating[name] {
+			pm.lockedVersions[name] = versions
+		}
+	}
+	defer func() { pm.lockedVersions = nil }()
+
+	var refresh []string
+	for _, name := range sortedKeys(updating) {
+		if !sources[name] {
+			refresh = append(refresh, name)
+		}
+	}
+	if _, err := pm.refreshManifests(refresh); err != nil {
+		return err
+	}
+
+	if len(data.Workspaces) > 0 {
+		err = pm.installWorkspaces(*data, false)
+	} else if err = pm.fetchToCache(*data, false); err == nil {
+		err = pm.packageJsonParse.CreateLockFile(pm.packageLock, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, pkgPath := range sortedKeys(pm.packageLock.Packages) {
+		before, existed := lock.Packages[pkgPath]
+		after := pm.packageLock.Packages[pkgPath]
+		if !strings.HasPrefix(pkgPath, "node_modules/") || after.InBundle || existed && before.Version == after.Version {
+			continue
+		}
+		changed++
+		if existed {
+			fmt.Printf("Updated %s from %s to %s\n", strings.TrimPrefix(pkgPath, "node_modules/"), before.Version, after.Version)
+		}
+	}
+	fmt.Printf("Changed %d packages\n", changed)
+
+	return pm.removeChanged(lock.Packages, pm.packageLock.Packages)
+}
+
+// lockedVersion returns the highest version kept from the lock by Update
+// for name that satisfies spec and is still published, or ""
+func (pm *PackageManager) lockedVersion(name, spec string, npmPackage *NPMPackage) string {
+	var best string
+	var bestVersion semVersion
+	for _, version := range pm.lockedVersions[name] {
+		if _, published := npmPackage.Versions[version]; !published || !satisfies(version, spec) {
+			continue
+		}
+		v, err := parseVersion(version)
+		if err != nil {
+			continue
+		}
+		if best == "" || v.compare(bestVersion) > 0 {
+			best, bestVersion = version, v
+		}
+	}
+	return best
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"npm-packager/npmrc"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// installProject writes package.json and installs it, as go-npm i does
+func installProject(t *testing.T, pm *PackageManager, packageJSON string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile("package.json", []byte(packageJSON), 0644))
+	assert.NoError(t, pm.ParsePackageJSON(false))
+	assert.NoError(t, pm.InstallFromCache())
+}
+
+func installedVersionOf(t *testing.T, pkgPath string) string {
+	t.Helper()
+	pkg, err := readInstalled(pkgPath, filepath.Join(pkgPath, "node_modules"), "", nil)
+	assert.NoError(t, err)
+	return pkg.Version
+}
+
+func TestOutdated(t *testing.T) {
+	r := newFakeRegistry(t, "",
+		fakePackage{name: "behind", version: "1.0.0"},
+		fakePackage{name: "current", version: "1.0.0"},
+		fakePackage{name: "major", version: "1.0.0"},
+	)
+	pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+	defer os.Chdir(origDir)
+
+	installProject(t, pm, `{"name":"app","version":"1.0.0",
+		"dependencies":{"behind":"^1.0.0","current":"^1.0.0"},
+		"devDependencies":{"major":"^1.0.0"}}`)
+
+	r.publish(t,
+		fakePackage{name: "behind", version: "1.1.0"},
+		fakePackage{name: "behind", version: "2.0.0"},
+		fakePackage{name: "major", version: "2.0.0"},
+	)
+	// A dependency added to package.json and not installed yet
+	assert.NoError(t, os.WriteFile("package.json", []byte(`{"name":"app","version":"1.0.0",
+		"dependencies":{"behind":"^1.0.0","current":"^1.0.0","missing":"^1.0.0"},
+		"devDependencies":{"major":"^1.0.0"}}`), 0644))
+	r.publish(t, fakePackage{name: "missing", version: "1.0.0"})
+
+	outdated, err := pm.Outdated()
+	assert.NoError(t, err)
+	assert.Equal(t, []OutdatedPackage{
+		{Name: "behind", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0", DependedBy: "app"},
+		{Name: "major", Current: "1.0.0", Wanted: "1.0.0", Latest: "2.0.0", DependedBy: "app"},
+		{Name: "missing", Current: "MISSING", Wanted: "1.0.0", Latest: "1.0.0", DependedBy: "app"},
+	}, outdated)
+}
+
+func TestUpdate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		update      []string
+		expectError bool
+		expected    map[string]string
+	}{
+		{
+			name:     "updates everything within its range",
+			expected: map[string]string{"first": "1.1.0", "second": "1.1.0", "shared": "1.1.0"},
+		},
+		{
+			name:     "updates only the named package",
+			update:   []string{"first"},
+			expected: map[string]string{"first": "1.1.0", "second": "1.0.0", "shared": "1.0.0"},
+		},
+		{
+			name:        "refuses a package that is not installed",
+			update:      []string{"unknown"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "first", version: "1.0.0", dependencies: map[string]string{"shared": "^1.0.0"}},
+				fakePackage{name: "second", version: "1.0.0"},
+				fakePackage{name: "shared", version: "1.0.0"},
+			)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			installProject(t, pm, `{"dependencies":{"first":"^1.0.0","second":"^1.0.0"}}`)
+			lockBefore, err := os.ReadFile(pm.packageJsonParse.LockFileName)
+			assert.NoError(t, err)
+
+			r.publish(t,
+				fakePackage{name: "first", version: "1.1.0", dependencies: map[string]string{"shared": "^1.0.0"}},
+				fakePackage{name: "first", version: "2.0.0"},
+				fakePackage{name: "second", version: "1.1.0"},
+				fakePackage{name: "shared", version: "1.1.0"},
+			)
+
+			err = pm.Update(tc.update)
+			if tc.expectError {
+				assert.Error(t, err)
+				lockAfter, err := os.ReadFile(pm.packageJsonParse.LockFileName)
+				assert.NoError(t, err)
+				assert.Equal(t, string(lockBefore), string(lockAfter))
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, pm.InstallFromCache())
+
+			lock, err := pm.packageJsonParse.ParseLockFile()
+			assert.NoError(t, err)
+			for name, version := range tc.expected {
+				assert.Equal(t, version, lock.Packages["node_modules/"+name].Version, name)
+				assert.Equal(t, version, installedVersionOf(t, filepath.Join("node_modules", name)), name)
+			}
+		})
+	}
+}
@@ -10,7 +10,7 @@ type NPMPackage struct {
 	ID       string             `json:"_id"`
 	Rev      string             `json:"_rev"`
 	Name     string             `json:"name"`
-	DistTags DistTags           `json:"dist-tags"`
+	DistTags map[string]string  `json:"dist-tags"`
 	Versions map[string]Version `json:"versions"`
 	Time     map[string]string  `json:"time"`
 	Bugs     any                `json:"bugs"`
@@ -27,11 +27,6 @@ type NPMPackage struct {
 	Users          map[string]bool `json:"users"`
 }
 
-type DistTags struct {
-	Latest string `json:"latest"`
-	Next   string `json:"next"`
-}
-
 type Version struct {
 	Name                   string                 `json:"name"`
 	Version                string                 `json:"version"`
@@ -59,6 +54,8 @@ type Version struct {
 	Keywords               any                    `json:"keywords"`
 	Contributors           any                    `json:"contributors"`
 	Files                  []string               `json:"files"`
+	OS                     []string               `json:"os"`
+	CPU                    []string               `json:"cpu"`
 	NPMOperationalInternal NPMOperationalInternal `json:"_npmOperationalInternal"`
 	NPMSignature           string                 `json:"npm-signature"`
 }
@@ -22,7 +22,7 @@ func TestParseJsonManifestParse(t *testing.T) {
 				t.Helper()
 				dir := t.TempDir()
 				filePath := filepath.Join(dir, "package.json")
-				content := `{"_id":"example","name":"example","dist-tags":{"latest":"1.0.0"},"versions":{"1.0.0":{"name":"example","version":"1.0.0","_id":"example@1.0.0","dist":{"tarball":"https://example.com/example-1.0.0.tgz","shasum":"abc123"}}}}`
+				content := `{"_id":"example","name":"example","dist-tags":{"latest":"1.0.0","beta":"1.1.0-beta.1"},"versions":{"1.0.0":{"name":"example","version":"1.0.0","_id":"example@1.0.0","dist":{"tarball":"https://example.com/example-1.0.0.tgz","shasum":"abc123"}}}}`
 
 				if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
 					t.Fatalf("write manifest: %v", err)
@@ -32,7 +32,7 @@ func TestParseJsonManifestParse(t *testing.T) {
 			},
 			assertPkg: func(t *testing.T, pkg *NPMPackage) {
 				assert.Equal(t, "example", pkg.Name)
-				assert.Equal(t, "1.0.0", pkg.DistTags.Latest)
+				assert.Equal(t, map[string]string{"latest": "1.0.0", "beta": "1.1.0-beta.1"}, pkg.DistTags)
 
 				if assert.Contains(t, pkg.Versions, "1.0.0") {
 					assert.Equal(t, "https://example.com/example-1.0.0.tgz", pkg.Versions["1.0.0"].Dist.Tarball)
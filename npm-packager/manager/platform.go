@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"runtime"
+	"strings"
+)
+
+// nodePlatform names the running OS the way the os field of package.json does
+func nodePlatform() string {
+	if runtime.GOOS == "windows" {
+		return "win32"
+	}
+	return runtime.GOOS
+}
+
+// nodeArch names the running architecture the way the cpu field of
+// package.json does
+func nodeArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "ia32"
+	}
+	return runtime.GOARCH
+}
+
+// platformSupported reports whether a package with these os and cpu fields
+// can be installed on the running platform
+func platformSupported(osList, cpuList []string) bool {
+	return matchPlatform(osList, nodePlatform()) && matchPlatform(cpuList, nodeArch())
+}
+
+// matchPlatform checks current against a list of allowed values, where
+// entries starting with ! exclude a value instead. An empty list, or one
+// with only exclusions, allows everything it does not exclude.
+func matchPlatform(list []string, current string) bool {
+	hasAllowed, allowed := false, false
+	for _, entry := range list {
+		if name, excluded := strings.CutPrefix(entry, "!"); excluded {
+			if name == current {
+				return false
+			}
+			continue
+		}
+		hasAllowed = true
+		if entry == current || entry == "any" {
+			allowed = true
+		}
+	}
+	return allowed || !hasAllowed
+}
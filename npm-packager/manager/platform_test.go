@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPlatform(t *testing.T) {
+	testCases := []struct {
+		name     string
+		list     []string
+		current  string
+		expected bool
+	}{
+		{"No restriction", nil, "linux", true},
+		{"Listed", []string{"darwin", "linux"}, "linux", true},
+		{"Not listed", []string{"darwin", "win32"}, "linux", false},
+		{"Excluded", []string{"!win32"}, "win32", false},
+		{"Not excluded", []string{"!win32"}, "linux", true},
+		{"Excluded wins over listed", []string{"linux", "!linux"}, "linux", false},
+		{"Any", []string{"any"}, "freebsd", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchPlatform(tc.list, tc.current))
+		})
+	}
+}
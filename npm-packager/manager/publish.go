@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"fmt"
+	"npm-packager/pack"
+	"npm-packager/publish"
+	"os"
+	"path/filepath"
+)
+
+// Pack packs the project into destDir as npm pack does, named
+// name-version.tgz, and returns the tarball. With dryRun nothing is
+// written.
+func (pm *PackageManager) Pack(destDir string, dryRun bool) (*pack.Tarball, error) {
+	tgz, err := pack.Create(".")
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return tgz, nil
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", destDir, err)
+	}
+	dst := filepath.Join(destDir, tgz.Filename())
+	if err := os.WriteFile(dst, tgz.Data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return tgz, nil
+}
+
+// Publish packs the project and publishes it to its registry
+func (pm *PackageManager) Publish(opts publish.Options) (*pack.Tarball, error) {
+	return pm.publisher.Publish(".", opts)
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"npm-packager/npmrc"
+	"npm-packager/pack"
+	"npm-packager/publish"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const publishTestPackage = `{"name":"lib","version":"1.0.0","files":["index.js"],"dependencies":{"shared":"^1.0.0"}}`
+
+func TestPack(t *testing.T) {
+	testCases := []struct {
+		name     string
+		destDir  string
+		dryRun   bool
+		validate func(t *testing.T, tgz *pack.Tarball)
+	}{
+		{
+			name:    "writes name-version.tgz to the destination",
+			destDir: "dist",
+			validate: func(t *testing.T, tgz *pack.Tarball) {
+				assert.Equal(t, []string{"index.js", "package.json"}, tgz.Files)
+				content, err := os.ReadFile(filepath.Join("dist", "lib-1.0.0.tgz"))
+				assert.NoError(t, err)
+				assert.Equal(t, tgz.Data, content)
+			},
+		},
+		{
+			name:    "dry run writes nothing",
+			destDir: "dist",
+			dryRun:  true,
+			validate: func(t *testing.T, tgz *pack.Tarball) {
+				assert.Equal(t, "lib-1.0.0.tgz", tgz.Filename())
+				assert.NoDirExists(t, "dist")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pm, _, origDir := setupTestPackageManager(t)
+			defer os.Chdir(origDir)
+
+			writeSourcePackage(t, ".", publishTestPackage)
+			assert.NoError(t, os.WriteFile("notes.txt", []byte("not published\n"), 0644))
+
+			tgz, err := pm.Pack(tc.destDir, tc.dryRun)
+			assert.NoError(t, err)
+			tc.validate(t, tgz)
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	testCases := []struct {
+		name        string
+		token       string
+		setupFunc   func(t *testing.T, pm *PackageManager)
+		expectError string
+		validate    func(t *testing.T, pm *PackageManager, r *fakeRegistry, tgz *pack.Tarball)
+	}{
+		{
+			name:  "the published version installs from the registry",
+			token: "secret",
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry, tgz *pack.Tarball) {
+				assert.Equal(t, 1, r.requestCount("/lib"))
+
+				// Another project depends on it
+				assert.NoError(t, os.MkdirAll("app", 0755))
+				assert.NoError(t, os.Chdir("app"))
+				installProject(t, pm, `{"name":"app","version":"1.0.0","dependencies":{"lib":"^1.0.0"}}`)
+
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "lib")))
+				assert.FileExists(t, filepath.Join("node_modules", "lib", "index.js"))
+				assert.NoFileExists(t, filepath.Join("node_modules", "lib", "notes.txt"))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "shared")))
+				assert.Equal(t, tgz.Integrity, lockedPackage(t, pm, "node_modules/lib").Integrity)
+			},
+		},
+		{
+			name:  "a version cannot be published twice",
+			token: "secret",
+			setupFunc: func(t *testing.T, pm *PackageManager) {
+				_, err := pm.Publish(publish.Options{})
+				assert.NoError(t, err)
+			},
+			expectError: publish.ErrVersionExists.Error(),
+		},
+		{
+			name:        "the registry's token is needed",
+			token:       "wrong",
+			expectError: "401",
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry, tgz *pack.Tarball) {
+				assert.Empty(t, r.packages["lib"])
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "secret", fakePackage{name: "shared", version: "1.0.0"})
+
+			npmrcPath := filepath.Join(t.TempDir(), ".npmrc")
+			content := "registry=" + r.URL + "\n//" + strings.TrimPrefix(r.URL, "http://") + "/:_authToken=" + tc.token + "\n"
+			assert.NoError(t, os.WriteFile(npmrcPath, []byte(content), 0644))
+			registry, err := npmrc.Load(npmRegistryURL, npmrcPath)
+			assert.NoError(t, err)
+			pm, _, origDir := setupRegistryPackageManager(t, registry)
+			defer os.Chdir(origDir)
+
+			writeSourcePackage(t, ".", publishTestPackage)
+			assert.NoError(t, os.WriteFile("notes.txt", []byte("not published\n"), 0644))
+			if tc.setupFunc != nil {
+				tc.setupFunc(t, pm)
+			}
+
+			tgz, err := pm.Publish(publish.Options{})
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.validate != nil {
+				tc.validate(t, pm, r, tgz)
+			}
+		})
+	}
+}
@@ -0,0 +1,588 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSafeInteger is the largest version number npm accepts, the largest
+// integer a JavaScript number holds exactly
+const maxSafeInteger = 1<<53 - 1
+
+// semVersion is a parsed semantic version. Build metadata is kept but
+// takes no part in comparisons.
+type semVersion struct {
+	major, minor, patch int
+	prerelease          []string
+	build               []string
+}
+
+// parseVersion parses a full version like 1.2.3-beta.1+build.5, with an
+// optional leading "v"
+func parseVersion(s string) (semVersion, error) {
+	var v semVersion
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		build, err := parseIdentifiers(rest[i+1:], false)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.build = build
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		pre, err := parseIdentifiers(rest[i+1:], true)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.prerelease = pre
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := parseNumber(part)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		numbers[i] = n
+	}
+	v.major, v.minor, v.patch = numbers[0], numbers[1], numbers[2]
+	return v, nil
+}
+
+// parseNumber parses a version number, which has no leading zeros
+func parseNumber(s string) (int, error) {
+	if s == "" || !isNumeric(s) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has a leading zero", s)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n > maxSafeInteger {
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+	return n, nil
+}
+
+// parseIdentifiers parses the dot separated identifiers of a prerelease or
+// build. Numeric prerelease identifiers may not have leading zeros.
+func parseIdentifiers(s string, prerelease bool) ([]string, error) {
+	ids := strings.Split(s, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("empty identifier in %q", s)
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return nil, fmt.Errorf("invalid character %q in %q", r, s)
+			}
+		}
+		if prerelease && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return nil, fmt.Errorf("identifier %q has a leading zero", id)
+		}
+	}
+	return ids, nil
+}
+
+func isNumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compare returns -1, 0 or 1 as v is lower than, equal to or higher than o
+func (v semVersion) compare(o semVersion) int {
+	if c := compareInt(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.patch, o.patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.prerelease, o.prerelease)
+}
+
+// sameRelease reports whether v and o share major, minor and patch
+func (v semVersion) sameRelease(o semVersion) bool {
+	return v.major == o.major && v.minor == o.minor && v.patch == o.patch
+}
+
+// String formats the version without its build metadata, as npm does
+func (v semVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease orders prerelease tags: a release is higher than any
+// of its prereleases, numeric identifiers compare as numbers and sort
+// before alphanumeric ones, and a longer tag wins when one is a prefix of
+// the other
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+func compareIdentifier(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+	switch {
+	case aNum && bNum:
+		// No leading zeros, so the longer number is the larger
+		if c := compareInt(len(a), len(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// partial is a version in a range, where any of the numbers may be left
+// out or be a wildcard: 1, 1.2, 1.x, 1.2.*, *
+type partial struct {
+	major, minor, patch    int
+	xMajor, xMinor, xPatch bool
+	prerelease             []string
+}
+
+func parsePartial(s string) (partial, error) {
+	var p partial
+	rest := strings.TrimLeft(s, "v=")
+	if rest == "" {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+
+	hasBuild := false
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		if _, err := parseIdentifiers(rest[i+1:], false); err != nil {
+			return p, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		hasBuild = true
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		pre, err := parseIdentifiers(rest[i+1:], true)
+		if err != nil {
+			return p, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		p.prerelease = pre
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 || (len(parts) < 3 && (p.prerelease != nil || hasBuild)) {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := [3]*int{&p.major, &p.minor, &p.patch}
+	wildcards := [3]*bool{&p.xMajor, &p.xMinor, &p.xPatch}
+	for i := range numbers {
+		if i >= len(parts) || isWildcard(parts[i]) {
+			*wildcards[i] = true
+			continue
+		}
+		n, err := parseNumber(parts[i])
+		if err != nil {
+			return p, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		*numbers[i] = n
+	}
+	// Whatever follows a wildcard is a wildcard too
+	p.xMinor = p.xMinor || p.xMajor
+	p.xPatch = p.xPatch || p.xMinor
+	return p, nil
+}
+
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// comparator is a single condition on a version, like >=1.2.3. An empty
+// operator means equal.
+type comparator struct {
+	op      string
+	version semVersion
+}
+
+// nothing is the comparator no version satisfies
+var nothing = comparator{op: "<", version: semVersion{prerelease: []string{"0"}}}
+
+func (c comparator) test(v semVersion) bool {
+	cmp := v.compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func (c comparator) isNothing() bool {
+	return c.op == nothing.op && c.version.compare(nothing.version) == 0
+}
+
+// matchesAll reports whether c is >=0.0.0, which every release satisfies
+func (c comparator) matchesAll() bool {
+	return c.op == ">=" && c.version.compare(semVersion{}) == 0
+}
+
+func (c comparator) String() string {
+	return c.op + c.version.String()
+}
+
+// atLeast is >=M.m.p with the given prerelease
+func atLeast(major, minor, patch int, prerelease []string) comparator {
+	return comparator{op: ">=", version: semVersion{major: major, minor: minor, patch: patch, prerelease: prerelease}}
+}
+
+// below is <M.m.p-0, which keeps out the prereleases of M.m.p as well
+func below(major, minor, patch int) comparator {
+	return comparator{op: "<", version: semVersion{major: major, minor: minor, patch: patch, prerelease: []string{"0"}}}
+}
+
+// semverRange is a parsed npm version range: sets of comparators joined
+// by ||. A version is in the range when it satisfies every comparator of
+// any one set; an empty set takes any release.
+type semverRange [][]comparator
+
+// parseRange parses a range the way node-semver does: hyphen ranges,
+// x-ranges, tilde and caret ranges and plain comparators, joined by
+// spaces and ||
+func parseRange(spec string) (semverRange, error) {
+	var sets semverRange
+	for _, part := range strings.Split(spec, "||") {
+		set, err := parseComparatorSet(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", spec, err)
+		}
+		sets = append(sets, set)
+	}
+
+	// Sets nothing satisfies are dropped, unless nothing else is left
+	var kept semverRange
+	for _, set := range sets {
+		if len(set) != 1 || !set[0].isNothing() {
+			kept = append(kept, set)
+		}
+	}
+	if len(kept) == 0 {
+		return sets[:1], nil
+	}
+	// A set taking anything makes the others redundant
+	for _, set := range kept {
+		if len(set) == 0 {
+			return semverRange{set}, nil
+		}
+	}
+	return kept, nil
+}
+
+// parseComparatorSet parses one side of a ||
+func parseComparatorSet(set string) ([]comparator, error) {
+	fields := strings.Fields(set)
+	if len(fields) == 3 && fields[1] == "-" {
+		from, err := parsePartial(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parsePartial(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return simplifySet(hyphenRange(from, to)), nil
+	}
+
+	var comparators []comparator
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		// An operator may be apart from its version: ">= 1.2.3", "~ 1.2"
+		if isOperator(token) && i+1 < len(fields) {
+			token += fields[i+1]
+			i++
+		}
+		parsed, err := parseRangeToken(token)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, parsed...)
+	}
+	return simplifySet(comparators), nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "<", "<=", ">", ">=", "=", "~", "~>", "^":
+		return true
+	}
+	return false
+}
+
+// parseRangeToken turns one term of a range into the comparators it
+// stands for
+func parseRangeToken(token string) ([]comparator, error) {
+	switch {
+	case strings.HasPrefix(token, "~"):
+		p, err := parsePartial(strings.TrimPrefix(strings.TrimPrefix(token, "~"), ">"))
+		if err != nil {
+			return nil, err
+		}
+		return tildeRange(p), nil
+	case strings.HasPrefix(token, "^"):
+		p, err := parsePartial(strings.TrimPrefix(token, "^"))
+		if err != nil {
+			return nil, err
+		}
+		return caretRange(p), nil
+	}
+
+	op := ""
+	rest := token
+	if strings.HasPrefix(rest, "<") || strings.HasPrefix(rest, ">") {
+		op, rest = rest[:1], rest[1:]
+	}
+	if strings.HasPrefix(rest, "=") {
+		op, rest = op+"=", rest[1:]
+	}
+	p, err := parsePartial(rest)
+	if err != nil {
+		return nil, err
+	}
+	return xRange(op, p), nil
+}
+
+// tildeRange allows patch changes, or minor ones when no minor is given:
+// ~1.2.3 is >=1.2.3 <1.3.0-0 and ~1 is >=1.0.0 <2.0.0-0
+func tildeRange(p partial) []comparator {
+	switch {
+	case p.xMajor:
+		return nil
+	case p.xMinor:
+		return []comparator{atLeast(p.major, 0, 0, nil), below(p.major+1, 0, 0)}
+	case p.xPatch:
+		return []comparator{atLeast(p.major, p.minor, 0, nil), below(p.major, p.minor+1, 0)}
+	}
+	return []comparator{atLeast(p.major, p.minor, p.patch, p.prerelease), below(p.major, p.minor+1, 0)}
+}
+
+// caretRange allows changes that keep the first non-zero number:
+// ^1.2.3 is >=1.2.3 <2.0.0-0, ^0.2.3 is >=0.2.3 <0.3.0-0 and ^0.0.3 is
+// >=0.0.3 <0.0.4-0
+func caretRange(p partial) []comparator {
+	switch {
+	case p.xMajor:
+		return nil
+	case p.xMinor:
+		return []comparator{atLeast(p.major, 0, 0, nil), below(p.major+1, 0, 0)}
+	case p.xPatch:
+		if p.major == 0 {
+			return []comparator{atLeast(p.major, p.minor, 0, nil), below(p.major, p.minor+1, 0)}
+		}
+		return []comparator{atLeast(p.major, p.minor, 0, nil), below(p.major+1, 0, 0)}
+	}
+
+	lower := atLeast(p.major, p.minor, p.patch, p.prerelease)
+	switch {
+	case p.major == 0 && p.minor == 0:
+		return []comparator{lower, below(0, 0, p.patch+1)}
+	case p.major == 0:
+		return []comparator{lower, below(0, p.minor+1, 0)}
+	}
+	return []comparator{lower, below(p.major+1, 0, 0)}
+}
+
+// xRange handles a plain comparator whose version may be partial: 1.2 is
+// >=1.2.0 <1.3.0-0, >1.2 is >=1.3.0 and <=1 is <2.0.0-0
+func xRange(op string, p partial) []comparator {
+	if op == "=" {
+		op = ""
+	}
+	if !p.xPatch {
+		return []comparator{{op: op, version: semVersion{major: p.major, minor: p.minor, patch: p.patch, prerelease: p.prerelease}}}
+	}
+
+	if p.xMajor {
+		if op == "<" || op == ">" {
+			return []comparator{nothing}
+		}
+		return nil
+	}
+
+	major, minor := p.major, p.minor
+	if p.xMinor {
+		minor = 0
+	}
+	switch op {
+	case "":
+		if p.xMinor {
+			return []comparator{atLeast(major, 0, 0, nil), below(major+1, 0, 0)}
+		}
+		return []comparator{atLeast(major, minor, 0, nil), below(major, minor+1, 0)}
+	case ">":
+		op = ">="
+		if p.xMinor {
+			major, minor = major+1, 0
+		} else {
+			minor++
+		}
+	case "<=":
+		op = "<"
+		if p.xMinor {
+			major++
+		} else {
+			minor++
+		}
+	}
+	if op == "<" {
+		return []comparator{below(major, minor, 0)}
+	}
+	return []comparator{{op: op, version: semVersion{major: major, minor: minor}}}
+}
+
+// hyphenRange is from - to, both inclusive; a partial upper bound takes
+// every version it covers, so 1 - 2 is >=1.0.0 <3.0.0-0
+func hyphenRange(from, to partial) []comparator {
+	var comparators []comparator
+	switch {
+	case from.xMajor:
+	case from.xMinor:
+		comparators = append(comparators, atLeast(from.major, 0, 0, nil))
+	case from.xPatch:
+		comparators = append(comparators, atLeast(from.major, from.minor, 0, nil))
+	default:
+		comparators = append(comparators, atLeast(from.major, from.minor, from.patch, from.prerelease))
+	}
+
+	switch {
+	case to.xMajor:
+	case to.xMinor:
+		comparators = append(comparators, below(to.major+1, 0, 0))
+	case to.xPatch:
+		comparators = append(comparators, below(to.major, to.minor+1, 0))
+	default:
+		comparators = append(comparators, comparator{op: "<=", version: semVersion{major: to.major, minor: to.minor, patch: to.patch, prerelease: to.prerelease}})
+	}
+	return comparators
+}
+
+// simplifySet drops duplicate comparators and ones every release meets,
+// and reduces a set containing an impossible comparator to just that
+func simplifySet(comparators []comparator) []comparator {
+	var set []comparator
+	seen := make(map[string]bool)
+	for _, c := range comparators {
+		if c.isNothing() {
+			return []comparator{nothing}
+		}
+		if c.matchesAll() || seen[c.String()] {
+			continue
+		}
+		seen[c.String()] = true
+		set = append(set, c)
+	}
+	return set
+}
+
+// test reports whether v is in the range. A prerelease is only in a set
+// that names a prerelease of the same version, so ^1.2.3-beta.1 takes
+// 1.2.3-beta.2 but not 1.3.0-beta.1: prereleases are opted into one at a
+// time.
+func (r semverRange) test(v semVersion) bool {
+	for _, set := range r {
+		if testSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func testSet(set []comparator, v semVersion) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if len(v.prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.version.prerelease) > 0 && c.version.sameRelease(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the range in its normalized form, e.g. ^1.2 as
+// >=1.2.0 <2.0.0-0
+func (r semverRange) String() string {
+	sets := make([]string, len(r))
+	for i, set := range r {
+		parts := make([]string, len(set))
+		for j, c := range set {
+			parts[j] = c.String()
+		}
+		sets[i] = strings.Join(parts, " ")
+	}
+	if s := strings.Join(sets, "||"); s != "" {
+		return s
+	}
+	return "*"
+}
+
+// satisfies reports whether version is in the range spec. An invalid
+// version or range satisfies nothing.
+func satisfies(version, spec string) bool {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	r, err := parseRange(spec)
+	if err != nil {
+		return false
+	}
+	return r.test(v)
+}
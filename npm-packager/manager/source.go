@@ -0,0 +1,330 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"npm-packager/depspec"
+	"npm-packager/integrity"
+	"npm-packager/npmrc"
+	"npm-packager/pack"
+	"npm-packager/packagejson"
+	"npm-packager/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sourcePackage is a package fetched from a git repository, a local
+// directory or tarball, or a tarball URL, rather than through a manifest
+type sourcePackage struct {
+	Name    string
+	Version string
+	// Where it came from, as the lock records it
+	Resolved string
+	// Of the tarball, for tarballs; packed directories and git checkouts
+	// have none that stays the same
+	Integrity string
+	// A link: dependency, symlinked rather than installed
+	Link bool
+	OS   []string
+	CPU  []string
+	// The directory, relative to the project, paths in its own
+	// dependencies are relative to; "" when they have none
+	Dir string
+}
+
+// sourceManifest is what is read from the package.json of a source
+type sourceManifest struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	OS      []string `json:"os"`
+	CPU     []string `json:"cpu"`
+}
+
+func readSourceManifest(dir string) (*sourceManifest, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	var manifest sourceManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	return &manifest, nil
+}
+
+// cacheKey is the key of a package in the package cache and the store.
+// Versions from name's registry are name@version, checked against their
+// integrity; git, directory and tarball sources, local or at any other URL,
+// also get a hash of where they came from, so they never take the place of
+// a published version of the same number.
+func cacheKey(registry *npmrc.Config, name, version, resolved string) string {
+	switch depspec.Parse(resolved).Type {
+	case depspec.RemoteTarball:
+		if fromRegistry(registry, name, version, resolved) {
+			break
+		}
+		fallthrough
+	case depspec.Git, depspec.Directory, depspec.Tarball:
+		sum := sha256.Sum256([]byte(resolved))
+		return name + "@" + version + "-" + hex.EncodeToString(sum[:6])
+	}
+	return name + "@" + version
+}
+
+// fromRegistry reports whether url is a tarball of name's registry, or is
+// where a mirror of the npm registry keeps version of name
+func fromRegistry(registry *npmrc.Config, name, version, url string) bool {
+	base := registry.RegistryFor(name)
+	return strings.HasPrefix(url, base) ||
+		strings.HasSuffix(url, "/"+strings.TrimPrefix(registry.TarballURL(name, version), base))
+}
+
+// fetchSource puts a package that is not resolved against a registry in
+// the package cache; a link: dependency is only read. Paths are relative
+// to baseDir, the directory of the package declaring the dependency. A
+// local tarball must match expected when that is set. Without a name, the
+// package's own is used.
+func (pm *PackageManager) fetchSource(name string, spec depspec.Spec, baseDir, expected string) (*sourcePackage, error) {
+	switch spec.Type {
+	case depspec.Git:
+		return pm.fetchGit(name, spec)
+	case depspec.RemoteTarball:
+		return pm.fetchRemoteTarball(name, spec.Raw, expected)
+	case depspec.Directory, depspec.Tarball, depspec.Link:
+	default:
+		return nil, fmt.Errorf("%s@%s is resolved against a registry", name, spec.Raw)
+	}
+
+	if baseDir == "" {
+		return nil, fmt.Errorf("%s@%s: local paths are only supported in the project, its workspaces and the directories they depend on", name, spec.Raw)
+	}
+	dir := spec.Path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.ToSlash(filepath.Clean(filepath.Join(baseDir, filepath.FromSlash(dir))))
+	}
+
+	switch spec.Type {
+	case depspec.Link:
+		manifest, err := readSourceManifest(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link %s to %s: %w", name, dir, err)
+		}
+		if name == "" {
+			name = manifest.Name
+		}
+		return &sourcePackage{Name: name, Version: manifest.Version, Resolved: dir, Link: true}, nil
+
+	case depspec.Tarball:
+		actual, err := integrity.ComputeFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		if expected != "" {
+			if err := integrity.Check(actual, expected); err != nil {
+				return nil, fmt.Errorf("tarball of %s at %s: %w", name, dir, err)
+			}
+		}
+		pkg, err := pm.cacheArchive(name, dir, "file:"+dir)
+		if err != nil {
+			return nil, err
+		}
+		pkg.Integrity = integrity.Strongest(actual)
+		return pkg, nil
+	}
+
+	// A directory is packed as it would be published, then extracted
+	archive, err := packTemp(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(filepath.Dir(archive))
+	pkg, err := pm.cacheArchive(name, archive, "file:"+dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg.Dir = dir
+	return pkg, nil
+}
+
+// fetchGit clones the repository, checks out the committish and packs the
+// checkout; the lock records the commit it was at
+func (pm *PackageManager) fetchGit(name string, spec depspec.Spec) (*sourcePackage, error) {
+	if pm.offline {
+		return nil, fmt.Errorf("%w: %s is fetched from git at %s", ErrOffline, name, spec.URL)
+	}
+
+	tmp, err := os.MkdirTemp("", "go-npm-git-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a directory to clone %s: %w", spec.URL, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	checkout := filepath.Join(tmp, "repo")
+	if _, err := runGit("", "clone", "--quiet", spec.URL, checkout); err != nil {
+		return nil, err
+	}
+	if spec.Committish != "" {
+		if _, err := runGit(checkout, "checkout", "--quiet", spec.Committish); err != nil {
+			return nil, err
+		}
+	}
+	commit, err := runGit(checkout, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := packTemp(checkout)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(filepath.Dir(archive))
+	return pm.cacheArchive(name, archive, depspec.GitResolved(spec.URL, commit))
+}
+
+// fetchRemoteTarball downloads a tarball from its URL, or takes it from
+// the tarball cache
+func (pm *PackageManager) fetchRemoteTarball(name, url, expected string) (*sourcePackage, error) {
+	actual, cached := pm.tarball.Cached(url, name, "", expected)
+	if !cached {
+		if pm.offline {
+			return nil, fmt.Errorf("%w: the tarball %s is not cached", ErrOffline, url)
+		}
+		var err error
+		actual, err = pm.tarball.Download(url, name, "", expected)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pkg, err := pm.cacheArchive(name, pm.tarball.File(url, name, ""), url)
+	if err != nil {
+		return nil, err
+	}
+	pkg.Integrity = integrity.Strongest(actual)
+	return pkg, nil
+}
+
+// cacheArchive extracts archive into the package cache, under the key its
+// version and resolved give, and imports it into the store
+func (pm *PackageManager) cacheArchive(name, archive, resolved string) (*sourcePackage, error) {
+	if err := os.MkdirAll(pm.packagesPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the package cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(pm.packagesPath, ".source-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a directory to extract %s: %w", archive, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := pm.extractor.Extract(archive, tmp); err != nil {
+		return nil, err
+	}
+	manifest, err := readSourceManifest(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("%s from %s: %w", name, resolved, err)
+	}
+	if name == "" {
+		name = manifest.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the package at %s has no name", resolved)
+	}
+
+	key := cacheKey(pm.registry, name, manifest.Version, resolved)
+	pkgPath := filepath.Join(pm.packagesPath, key)
+	actual, err := integrity.ComputeFile(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &sourcePackage{
+		Name:     name,
+		Version:  manifest.Version,
+		Resolved: resolved,
+		OS:       manifest.OS,
+		CPU:      manifest.CPU,
+	}
+
+	defer pm.lockPackage(pkgPath)()
+	// The same archive was cached before: leave what dependents install from
+	if recorded, err := os.ReadFile(pkgPath + ".integrity"); err == nil && utils.FolderExists(pkgPath) &&
+		integrity.Check(actual, string(recorded)) == nil {
+		return pkg, nil
+	}
+
+	if err := os.RemoveAll(pkgPath); err != nil {
+		return nil, fmt.Errorf("failed to remove cached %s: %w", key, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(pkgPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(pkgPath), err)
+	}
+	if err := os.Rename(tmp, pkgPath); err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %w", key, err)
+	}
+	if err := os.WriteFile(pkgPath+".integrity", []byte(actual), 0644); err != nil {
+		return nil, fmt.Errorf("failed to record integrity of %s: %w", key, err)
+	}
+	if _, err := pm.store.Import(key, pkgPath); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// fetchLocked makes sure the package cache holds the package a lock entry
+// records. A git commit is fetched once; directories and local tarballs
+// are read again, as they may have changed.
+func (pm *PackageManager) fetchLocked(name string, item packagejson.PackageItem) error {
+	spec := depspec.Parse(item.Resolved)
+	key := cacheKey(pm.registry, name, item.Version, item.Resolved)
+	if key == name+"@"+item.Version {
+		_, err := pm.fetchPackage(name, item.Version, item.Resolved, item.Integrity)
+		return err
+	}
+
+	if spec.Type == depspec.Git && utils.FolderExists(filepath.Join(pm.packagesPath, key)) {
+		return nil
+	}
+	pkg, err := pm.fetchSource(name, spec, ".", item.Integrity)
+	if err != nil {
+		return err
+	}
+	if pkg.Version != item.Version {
+		return fmt.Errorf("%s from %s is at %s but the lock file has %s, run go-npm i to update it", name, item.Resolved, pkg.Version, item.Version)
+	}
+	return nil
+}
+
+// packTemp packs dir into a tarball in a directory of its own, for the
+// caller to remove
+func packTemp(dir string) (string, error) {
+	tmp, err := os.MkdirTemp("", "go-npm-pack-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a directory to pack %s: %w", dir, err)
+	}
+	archive := filepath.Join(tmp, "package.tgz")
+	if err := pack.Pack(dir, archive); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return archive, nil
+}
+
+// runGit runs git in dir and returns what it printed, trimmed
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,371 @@
+package manager
+
+import (
+	"npm-packager/depspec"
+	"npm-packager/integrity"
+	"npm-packager/npmrc"
+	"npm-packager/pack"
+	"npm-packager/packagejson"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSourcePackage writes a package.json, and an index.js, to dir
+func writeSourcePackage(t *testing.T, dir, packageJSON string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(packageJSON), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.js"), []byte("module.exports = 1\n"), 0644))
+}
+
+// commitGitRepo commits everything in dir, creating the repository first
+// when needed, and returns the commit
+func commitGitRepo(t *testing.T, dir, message string) string {
+	t.Helper()
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		_, err := runGit(dir, "init", "--quiet")
+		assert.NoError(t, err)
+	}
+	_, err := runGit(dir, "add", "-A")
+	assert.NoError(t, err)
+	_, err = runGit(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", message)
+	assert.NoError(t, err)
+	commit, err := runGit(dir, "rev-parse", "HEAD")
+	assert.NoError(t, err)
+	return commit
+}
+
+func lockedPackage(t *testing.T, pm *PackageManager, path string) packagejson.PackageItem {
+	t.Helper()
+	lock, err := pm.packageJsonParse.ParseLockFile()
+	assert.NoError(t, err)
+	item, ok := lock.Packages[path]
+	assert.True(t, ok, "%s is not in the lock", path)
+	return item
+}
+
+func TestInstall_Sources(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T, pm *PackageManager, r *fakeRegistry) string
+		expectError string
+		validate    func(t *testing.T, pm *PackageManager, r *fakeRegistry)
+	}{
+		{
+			name: "git dependency at a tag",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				repo := filepath.Join(t.TempDir(), "repo")
+				writeSourcePackage(t, repo, `{"name":"gitpkg","version":"1.0.0","dependencies":{"shared":"^1.0.0"}}`)
+				commit := commitGitRepo(t, repo, "v1")
+				_, err := runGit(repo, "tag", "v1.0.0")
+				assert.NoError(t, err)
+				writeSourcePackage(t, repo, `{"name":"gitpkg","version":"2.0.0"}`)
+				commitGitRepo(t, repo, "v2")
+				assert.NoError(t, os.WriteFile("commit", []byte(commit), 0644))
+				return `{"name":"app","version":"1.0.0","dependencies":{"gitpkg":"git+file://` + filepath.ToSlash(repo) + `#v1.0.0"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				commit, err := os.ReadFile("commit")
+				assert.NoError(t, err)
+				item := lockedPackage(t, pm, "node_modules/gitpkg")
+				assert.Equal(t, "1.0.0", item.Version)
+				spec := depspec.Parse(item.Resolved)
+				assert.Equal(t, depspec.Git, spec.Type)
+				assert.Equal(t, string(commit), spec.Committish)
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "gitpkg")))
+				// Its registry dependencies are installed too
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "shared")))
+			},
+		},
+		{
+			name: "git dependency is reinstalled at the locked commit",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				repo := filepath.Join(t.TempDir(), "repo")
+				writeSourcePackage(t, repo, `{"name":"gitpkg","version":"1.0.0"}`)
+				commitGitRepo(t, repo, "v1")
+				packageJSON := `{"name":"app","version":"1.0.0","dependencies":{"gitpkg":"git+file://` + filepath.ToSlash(repo) + `"}}`
+				installProject(t, pm, packageJSON)
+
+				// The branch moves on, and the package cache is gone
+				writeSourcePackage(t, repo, `{"name":"gitpkg","version":"2.0.0"}`)
+				commitGitRepo(t, repo, "v2")
+				assert.NoError(t, os.RemoveAll("node_modules"))
+				assert.NoError(t, os.RemoveAll(pm.packagesPath))
+				assert.NoError(t, os.MkdirAll(pm.packagesPath, 0755))
+				return packageJSON
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "gitpkg")))
+			},
+		},
+		{
+			name: "file: directory is installed as a copy with its own dependencies",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				writeSourcePackage(t, "lib", `{"name":"lib","version":"0.1.0","dependencies":{"shared":"^1.0.0","util":"file:../util"}}`)
+				writeSourcePackage(t, "util", `{"name":"util","version":"0.2.0"}`)
+				return `{"name":"app","version":"1.0.0","dependencies":{"lib":"file:lib"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				info, err := os.Lstat(filepath.Join("node_modules", "lib"))
+				assert.NoError(t, err)
+				assert.True(t, info.IsDir(), "node_modules/lib should be a directory")
+
+				item := lockedPackage(t, pm, "node_modules/lib")
+				assert.Equal(t, "file:lib", item.Resolved)
+				assert.Equal(t, "0.1.0", item.Version)
+				assert.Empty(t, item.Integrity)
+				// Paths in its dependencies are relative to it
+				assert.Equal(t, "file:util", lockedPackage(t, pm, "node_modules/util").Resolved)
+				assert.Equal(t, "0.2.0", installedVersionOf(t, filepath.Join("node_modules", "util")))
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "shared")))
+			},
+		},
+		{
+			name: "file: directory of a published version stays apart from it",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				writeSourcePackage(t, "forked", `{"name":"shared","version":"1.0.0"}`)
+				assert.NoError(t, os.WriteFile(filepath.Join("forked", "index.js"), []byte("forked\n"), 0644))
+				r.publish(t, fakePackage{name: "user", version: "1.0.0", dependencies: map[string]string{"shared": "^1.0.0"}})
+				return `{"name":"app","version":"1.0.0","dependencies":{"shared":"file:forked","user":"^1.0.0"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, "file:forked", lockedPackage(t, pm, "node_modules/shared").Resolved)
+				content, err := os.ReadFile(filepath.Join("node_modules", "shared", "index.js"))
+				assert.NoError(t, err)
+				assert.Equal(t, "forked\n", string(content))
+
+				// The published version is nested where the range asks for it
+				nested := lockedPackage(t, pm, "node_modules/user/node_modules/shared")
+				assert.Equal(t, r.URL+"/tarballs/shared-1.0.0.tgz", nested.Resolved)
+				content, err = os.ReadFile(filepath.Join("node_modules", "user", "node_modules", "shared", "index.js"))
+				assert.NoError(t, err)
+				assert.Equal(t, "module.exports = 'shared'\n", string(content))
+			},
+		},
+		{
+			name: "tarball URL of a published version stays apart from it",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				other := newFakeRegistry(t, "", fakePackage{name: "shared", version: "1.0.0", dependencies: map[string]string{"first": "^1.0.0"}})
+				r.publish(t, fakePackage{name: "user", version: "1.0.0", dependencies: map[string]string{"shared": "^1.0.0"}})
+				return `{"name":"app","version":"1.0.0","dependencies":{"shared":"` + other.URL + `/tarballs/shared-1.0.0.tgz","user":"^1.0.0"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				assert.Equal(t, map[string]string{"first": "^1.0.0"}, lockedPackage(t, pm, "node_modules/shared").Dependencies)
+
+				nested := lockedPackage(t, pm, "node_modules/user/node_modules/shared")
+				assert.Equal(t, r.URL+"/tarballs/shared-1.0.0.tgz", nested.Resolved)
+				assert.Empty(t, nested.Dependencies)
+				assert.Equal(t, r.integrity["/tarballs/shared-1.0.0.tgz"], nested.Integrity)
+			},
+		},
+		{
+			name: "link: directory is symlinked",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				writeSourcePackage(t, "lib", `{"name":"lib","version":"0.1.0"}`)
+				return `{"name":"app","version":"1.0.0","dependencies":{"lib":"link:lib"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				info, err := os.Lstat(filepath.Join("node_modules", "lib"))
+				assert.NoError(t, err)
+				assert.NotZero(t, info.Mode()&os.ModeSymlink, "node_modules/lib should be a symlink")
+				assert.FileExists(t, filepath.Join("node_modules", "lib", "index.js"))
+
+				item := lockedPackage(t, pm, "node_modules/lib")
+				assert.True(t, item.Link)
+				assert.Equal(t, "lib", item.Resolved)
+			},
+		},
+		{
+			name: "local tarball is locked with its integrity",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				src := filepath.Join(t.TempDir(), "src")
+				writeSourcePackage(t, src, `{"name":"packed","version":"3.0.0"}`)
+				assert.NoError(t, pack.Pack(src, filepath.Join("vendor", "packed-3.0.0.tgz")))
+				return `{"name":"app","version":"1.0.0","dependencies":{"packed":"file:vendor/packed-3.0.0.tgz"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				actual, err := integrity.ComputeFile(filepath.Join("vendor", "packed-3.0.0.tgz"))
+				assert.NoError(t, err)
+				item := lockedPackage(t, pm, "node_modules/packed")
+				assert.Equal(t, "file:vendor/packed-3.0.0.tgz", item.Resolved)
+				assert.Equal(t, integrity.Strongest(actual), item.Integrity)
+				assert.Equal(t, "3.0.0", installedVersionOf(t, filepath.Join("node_modules", "packed")))
+			},
+		},
+		{
+			name: "local tarball that changed since it was locked is refused",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				src := filepath.Join(t.TempDir(), "src")
+				writeSourcePackage(t, src, `{"name":"packed","version":"3.0.0"}`)
+				assert.NoError(t, pack.Pack(src, "packed.tgz"))
+				packageJSON := `{"name":"app","version":"1.0.0","dependencies":{"packed":"file:packed.tgz"}}`
+				installProject(t, pm, packageJSON)
+
+				assert.NoError(t, os.WriteFile(filepath.Join(src, "index.js"), []byte("changed\n"), 0644))
+				assert.NoError(t, pack.Pack(src, "packed.tgz"))
+				assert.NoError(t, os.RemoveAll("node_modules"))
+				return packageJSON
+			},
+			expectError: integrity.ErrMismatch.Error(),
+			validate:    func(t *testing.T, pm *PackageManager, r *fakeRegistry) {},
+		},
+		{
+			name: "tarball URL",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				return `{"name":"app","version":"1.0.0","dependencies":{"first":"` + r.URL + `/tarballs/first-1.0.0.tgz"}}`
+			},
+			validate: func(t *testing.T, pm *PackageManager, r *fakeRegistry) {
+				item := lockedPackage(t, pm, "node_modules/first")
+				assert.Equal(t, r.URL+"/tarballs/first-1.0.0.tgz", item.Resolved)
+				assert.Equal(t, r.integrity["/tarballs/first-1.0.0.tgz"], item.Integrity)
+				assert.Equal(t, "1.0.0", installedVersionOf(t, filepath.Join("node_modules", "first")))
+				// The manifest was never needed
+				assert.Equal(t, 0, r.requestCount("/first"))
+			},
+		},
+		{
+			name: "relative paths are refused in registry packages",
+			setupFunc: func(t *testing.T, pm *PackageManager, r *fakeRegistry) string {
+				r.publish(t, fakePackage{name: "escapes", version: "1.0.0", dependencies: map[string]string{"lib": "file:../lib"}})
+				return `{"name":"app","version":"1.0.0","dependencies":{"escapes":"^1.0.0"}}`
+			},
+			expectError: "local paths are only supported",
+			validate:    func(t *testing.T, pm *PackageManager, r *fakeRegistry) {},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newFakeRegistry(t, "",
+				fakePackage{name: "first", version: "1.0.0"},
+				fakePackage{name: "shared", version: "1.0.0"},
+			)
+			pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+			defer os.Chdir(origDir)
+
+			packageJSON := tc.setupFunc(t, pm, r)
+			assert.NoError(t, os.WriteFile("package.json", []byte(packageJSON), 0644))
+
+			err := pm.ParsePackageJSON(false)
+			if err == nil {
+				err = pm.InstallFromCache()
+			}
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.validate(t, pm, r)
+		})
+	}
+}
+
+func TestAdd_Source(t *testing.T) {
+	r := newFakeRegistry(t, "")
+	pm, _, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+	defer os.Chdir(origDir)
+
+	assert.NoError(t, os.WriteFile("package.json", []byte(`{"name":"app","version":"1.0.0"}`), 0644))
+	_, err := pm.packageJsonParse.ParseDefault()
+	assert.NoError(t, err)
+	lockContent := `{"name":"app","version":"1.0.0","lockfileVersion":3,"requires":true,"packages":{},"dependencies":{}}`
+	assert.NoError(t, os.WriteFile(pm.packageJsonParse.LockFileName, []byte(lockContent), 0644))
+	_, err = pm.packageJsonParse.ParseLockFile()
+	assert.NoError(t, err)
+	writeSourcePackage(t, "lib", `{"name":"local-lib","version":"0.1.0"}`)
+
+	// Named by its own package.json
+	name, spec := depspec.ParseArg("./lib")
+	assert.NoError(t, pm.Add(name, spec, false))
+
+	packageJSON, err := pm.packageJsonParse.ParseDefault()
+	assert.NoError(t, err)
+	assert.Equal(t, "file:./lib", packageJSON.Dependencies["local-lib"])
+	assert.Equal(t, "file:lib", lockedPackage(t, pm, "node_modules/local-lib").Resolved)
+}
+
+func TestCacheKey(t *testing.T) {
+	registry := npmrc.New("https://registry.npmjs.org/")
+	registry.Scopes["@company"] = "https://npm.company.io/"
+
+	testCases := []struct {
+		name     string
+		pkg      string
+		resolved string
+		hashed   bool
+	}{
+		{name: "registry tarball", pkg: "widget", resolved: "https://registry.npmjs.org/widget/-/widget-1.0.0.tgz"},
+		{name: "scoped registry tarball", pkg: "@company/widget", resolved: "https://npm.company.io/@company/widget/-/widget-1.0.0.tgz"},
+		{name: "registry mirror", pkg: "widget", resolved: "https://mirror.example.com/npm/widget/-/widget-1.0.0.tgz"},
+		{name: "no resolved URL", pkg: "widget"},
+		{name: "tarball URL elsewhere", pkg: "widget", resolved: "https://example.com/widget-1.0.0.tgz", hashed: true},
+		{name: "another version's tarball", pkg: "widget", resolved: "https://mirror.example.com/widget/-/widget-2.0.0.tgz", hashed: true},
+		{name: "git", pkg: "widget", resolved: "git+https://github.com/org/widget.git#abc123", hashed: true},
+		{name: "directory", pkg: "widget", resolved: "file:widget", hashed: true},
+		{name: "local tarball", pkg: "widget", resolved: "file:widget-1.0.0.tgz", hashed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := cacheKey(registry, tc.pkg, "1.0.0", tc.resolved)
+			if tc.hashed {
+				assert.Regexp(t, `^`+tc.pkg+`@1\.0\.0-[0-9a-f]{12}$`, key)
+			} else {
+				assert.Equal(t, tc.pkg+"@1.0.0", key)
+			}
+		})
+	}
+}
+
+func TestCacheArchive_Unchanged(t *testing.T) {
+	pm, tmpDir, origDir := setupTestPackageManager(t)
+	defer os.Chdir(origDir)
+
+	archive := filepath.Join(tmpDir, "widget-1.0.0.tgz")
+	resolved := "file:widget-1.0.0.tgz"
+	pkgPath := filepath.Join(pm.packagesPath, cacheKey(pm.registry, "widget", "1.0.0", resolved))
+	marker := filepath.Join(pkgPath, "marker")
+
+	testCases := []struct {
+		name     string
+		pkg      fakePackage
+		repack   bool
+		replaced bool
+	}{
+		{name: "first cached", pkg: fakePackage{name: "widget", version: "1.0.0"}, repack: true, replaced: true},
+		{name: "same archive keeps the entry", replaced: false},
+		{name: "changed archive replaces it", pkg: fakePackage{name: "widget", version: "1.0.0", scripts: map[string]string{"test": "true"}}, repack: true, replaced: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.repack {
+				assert.NoError(t, os.WriteFile(archive, packTestTarball(t, tc.pkg), 0644))
+			}
+
+			pkg, err := pm.cacheArchive("widget", archive, resolved)
+			assert.NoError(t, err)
+			assert.Equal(t, "1.0.0", pkg.Version)
+			if tc.replaced {
+				assert.NoFileExists(t, marker)
+			} else {
+				assert.FileExists(t, marker, "An unchanged source should not replace what dependents install from")
+			}
+
+			recorded, err := os.ReadFile(pkgPath + ".integrity")
+			assert.NoError(t, err)
+			actual, err := integrity.ComputeFile(archive)
+			assert.NoError(t, err)
+			assert.Equal(t, actual, string(recorded))
+			assert.NoError(t, os.WriteFile(marker, nil, 0644))
+		})
+	}
+}
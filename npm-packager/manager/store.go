@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"npm-packager/packagejson"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lockFilePath is the lock file of the project being installed, or of the
+// global packages
+func (pm *PackageManager) lockFilePath() string {
+	if pm.isGlobal {
+		return pm.config.GlobalLockFile
+	}
+	return pm.packageJsonParse.LockFileName
+}
+
+// packageName is the name of the package installed at pkgPath
+func packageName(pkgPath string) string {
+	name := strings.TrimPrefix(pkgPath, "node_modules/")
+	if i := strings.LastIndex(name, "/node_modules/"); i >= 0 {
+		name = name[i+len("/node_modules/"):]
+	}
+	return name
+}
+
+// packageKey is the cache key of the package installed at pkgPath
+func (pm *PackageManager) packageKey(pkgPath string, item packagejson.PackageItem) string {
+	return cacheKey(pm.registry, packageName(pkgPath), item.Version, item.Resolved)
+}
+
+// StorePrune removes from the store, and from the package cache, every
+// package no registered project's lock file uses, then the file content
+// nothing refers to any more. Projects register when they install.
+func (pm *PackageManager) StorePrune() error {
+	projects, err := pm.store.Projects()
+	if err != nil {
+		return fmt.Errorf("failed to read the projects using the store: %w", err)
+	}
+
+	keep := make(map[string]bool)
+	for _, lockPath := range projects {
+		content, err := os.ReadFile(lockPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", lockPath, err)
+		}
+		var lock packagejson.PackageLock
+		if err := json.Unmarshal(content, &lock); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", lockPath, err)
+		}
+		for pkgPath, item := range lock.Packages {
+			if strings.HasPrefix(pkgPath, "node_modules/") && !item.Link && !item.InBundle {
+				keep[pm.packageKey(pkgPath, item)] = true
+			}
+		}
+	}
+
+	// The package cache links the same content, so it goes too
+	cached, err := pm.cachedPackages()
+	if err != nil {
+		return err
+	}
+	for _, key := range cached {
+		if keep[key] {
+			continue
+		}
+		pkgDir := filepath.Join(pm.packagesPath, filepath.FromSlash(key))
+		if err := os.RemoveAll(pkgDir); err != nil {
+			return fmt.Errorf("failed to remove cached %s: %w", key, err)
+		}
+		if err := os.Remove(pkgDir + ".integrity"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cached %s: %w", key, err)
+		}
+	}
+
+	result, err := pm.store.Prune(keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune the store: %w", err)
+	}
+
+	fmt.Printf("Removed %d packages and %d files (%.1f MB) no project uses\n",
+		result.Packages, result.Files, float64(result.Bytes)/(1024*1024))
+	return nil
+}
+
+// cachedPackages lists the name@version of the packages extracted in the
+// package cache
+func (pm *PackageManager) cachedPackages() ([]string, error) {
+	var keys []string
+	var read func(dir, scope string) error
+	read = func(dir, scope string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to read the package cache: %w", err)
+		}
+		for _, entry := range entries {
+			switch {
+			case !entry.IsDir():
+			case scope == "" && strings.HasPrefix(entry.Name(), "@") && !strings.Contains(entry.Name()[1:], "@"):
+				if err := read(filepath.Join(dir, entry.Name()), entry.Name()+"/"); err != nil {
+					return err
+				}
+			default:
+				keys = append(keys, scope+entry.Name())
+			}
+		}
+		return nil
+	}
+	return keys, read(pm.packagesPath, "")
+}
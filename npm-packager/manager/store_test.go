@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"npm-packager/npmrc"
+	"npm-packager/packagejson"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallFromCache_Store(t *testing.T) {
+	r := newFakeRegistry(t, "",
+		fakePackage{name: "shared", version: "1.0.0"},
+		fakePackage{name: "only-first", version: "1.0.0"},
+		fakePackage{name: "builds", version: "1.0.0", scripts: map[string]string{"postinstall": "echo built >> index.js"}},
+	)
+
+	install := func(pm *PackageManager, dependencies map[string]string) {
+		assert.NoError(t, pm.fetchToCache(packagejson.PackageJSON{Dependencies: dependencies}, false))
+		assert.NoError(t, pm.packageJsonParse.CreateLockFile(pm.packageLock, false))
+		assert.NoError(t, pm.InstallFromCache())
+	}
+	sameFile := func(a, b string) bool {
+		infoA, err := os.Stat(a)
+		assert.NoError(t, err)
+		infoB, err := os.Stat(b)
+		assert.NoError(t, err)
+		return os.SameFile(infoA, infoB)
+	}
+
+	first, firstDir, origDir := setupRegistryPackageManager(t, npmrc.New(r.URL))
+	defer os.Chdir(origDir)
+	install(first, map[string]string{"shared": "^1.0.0", "only-first": "^1.0.0", "builds": "^1.0.0"})
+
+	// A second project on the same store and package cache
+	second, secondDir, _ := setupRegistryPackageManager(t, npmrc.New(r.URL))
+	second.store = first.store
+	second.packagesPath = first.packagesPath
+	install(second, map[string]string{"shared": "^1.0.0", "builds": "^1.0.0"})
+
+	assert.True(t, sameFile(
+		filepath.Join(firstDir, "node_modules", "shared", "index.js"),
+		filepath.Join(secondDir, "node_modules", "shared", "index.js"),
+	), "projects should share the store's copy")
+
+	// What an install script builds stays in its project
+	built := filepath.Join(secondDir, "node_modules", "builds", "index.js")
+	assert.False(t, sameFile(built, filepath.Join(first.packagesPath, "builds@1.0.0", "index.js")))
+	content, err := os.ReadFile(filepath.Join(first.packagesPath, "builds@1.0.0", "index.js"))
+	assert.NoError(t, err)
+	assert.Equal(t, "module.exports = 'builds'\n", string(content))
+
+	// Once the first project is gone, what only it used is pruned
+	assert.NoError(t, os.Remove(filepath.Join(firstDir, first.packageJsonParse.LockFileName)))
+	assert.NoError(t, second.StorePrune())
+
+	assert.NoDirExists(t, filepath.Join(first.packagesPath, "only-first@1.0.0"))
+	assert.NoFileExists(t, filepath.Join(first.packagesPath, "only-first@1.0.0.integrity"))
+	_, err = second.store.Index("only-first@1.0.0")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.DirExists(t, filepath.Join(first.packagesPath, "shared@1.0.0"))
+	_, err = second.store.Index("shared@1.0.0")
+	assert.NoError(t, err)
+
+	// The kept packages still install from the store
+	assert.NoError(t, os.RemoveAll(filepath.Join(secondDir, "node_modules")))
+	assert.NoError(t, second.InstallFromCache())
+	assert.FileExists(t, filepath.Join(secondDir, "node_modules", "shared", "index.js"))
+}
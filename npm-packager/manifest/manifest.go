@@ -1,32 +1,40 @@
 package manifest
 
 import (
+	"npm-packager/npmrc"
 	"npm-packager/utils"
 	"path/filepath"
 )
 
 type Manifest struct {
-	npmResgistryURL string
-	Path            string
+	registry   *npmrc.Config
+	Path       string
+	Downloader *utils.Downloader
 }
 
-func NewManifest(configPath string, npmRegistryURL string) (*Manifest, error) {
+func NewManifest(configPath string, registry *npmrc.Config) (*Manifest, error) {
 	pathM := filepath.Join(configPath, "manifest")
 	if err := utils.CreateDir(pathM); err != nil {
 		return nil, err
 	}
 
 	return &Manifest{
-		Path:            pathM,
-		npmResgistryURL: npmRegistryURL,
+		Path:       pathM,
+		registry:   registry,
+		Downloader: utils.NewDownloader(utils.DefaultConcurrency),
 	}, nil
 }
 
 func (m *Manifest) Download(pkg string, currentEtag string) (string, int, error) {
-	url := m.npmResgistryURL + pkg
+	url := m.registry.ManifestURL(pkg)
 	filename := filepath.Join(m.Path, pkg+".json")
 
-	eTag, statusCode, err := utils.DownloadFile(url, filename, currentEtag)
+	eTag, statusCode, err := m.Downloader.Download(utils.Request{
+		URL:       url,
+		Filename:  filename,
+		Etag:      currentEtag,
+		AuthToken: m.registry.AuthToken(url, pkg),
+	})
 
 	return eTag, statusCode, err
 }
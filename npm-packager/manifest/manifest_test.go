@@ -3,8 +3,11 @@ package manifest
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"npm-packager/npmrc"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,7 +53,7 @@ func TestDownloadManifest_Download(t *testing.T) {
 			setupFunc: func(t *testing.T) (string, string, string) {
 				configDir := setupTestDirs(t)
 
-				manifest, err := NewManifest(configDir, "https://registry.npmjs.org/")
+				manifest, err := NewManifest(configDir, npmrc.New("https://registry.npmjs.org/"))
 				assert.NoError(t, err)
 				etag, _, err := manifest.Download(packageName, "")
 				assert.NoError(t, err)
@@ -88,7 +91,7 @@ func TestDownloadManifest_Download(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			configDir, packageName, etag := tc.setupFunc(t)
-			manifest, err := NewManifest(configDir, "https://registry.npmjs.org/")
+			manifest, err := NewManifest(configDir, npmrc.New("https://registry.npmjs.org/"))
 			assert.NoError(t, err)
 			etag, statusCode, err := manifest.Download(packageName, etag)
 
@@ -103,3 +106,76 @@ func TestDownloadManifest_Download(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadManifest_Registry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageName string
+		setupFunc   func(t *testing.T, serverURL string) *npmrc.Config
+		expectError bool
+	}{
+		{
+			name:        "Scoped package from its scope's registry with token",
+			packageName: "@company/widget",
+			setupFunc: func(t *testing.T, serverURL string) *npmrc.Config {
+				path := filepath.Join(t.TempDir(), ".npmrc")
+				npmrcContent := "@company:registry=" + serverURL + "/private/\n" +
+					"//" + strings.TrimPrefix(serverURL, "http://") + "/private/:_authToken=secret\n"
+				assert.NoError(t, os.WriteFile(path, []byte(npmrcContent), 0644))
+				registry, err := npmrc.Load("https://registry.npmjs.org/", path)
+				assert.NoError(t, err)
+				return registry
+			},
+		},
+		{
+			name:        "Unscoped package from the configured default registry",
+			packageName: "widget",
+			setupFunc: func(t *testing.T, serverURL string) *npmrc.Config {
+				return npmrc.New(serverURL + "/public")
+			},
+		},
+		{
+			name:        "Error when the registry refuses the request",
+			packageName: "@company/widget",
+			setupFunc: func(t *testing.T, serverURL string) *npmrc.Config {
+				registry := npmrc.New("https://registry.npmjs.org/")
+				registry.Scopes["@company"] = serverURL + "/private/"
+				return registry
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.EscapedPath() {
+				case "/private/@company%2fwidget":
+					if r.Header.Get("Authorization") != "Bearer secret" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+				case "/public/widget":
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprintf(w, `{"name": %q}`, tc.packageName)
+			}))
+			defer server.Close()
+
+			manifest, err := NewManifest(setupTestDirs(t), tc.setupFunc(t, server.URL))
+			assert.NoError(t, err)
+			etag, _, err := manifest.Download(tc.packageName, "")
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, `"v1"`, etag)
+			assert.FileExists(t, filepath.Join(manifest.Path, tc.packageName+".json"))
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package npmrc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Config is the registry configuration read from .npmrc files: the default
+// registry, registries for scopes and the auth tokens that go with them
+type Config struct {
+	Registry string
+	Scopes   map[string]string
+	// ignore-scripts: do not run dependencies' lifecycle scripts on install
+	IgnoreScripts bool
+	// offline: install only from the cache
+	Offline bool
+	// prefer-offline: use cached manifests without revalidating them
+	PreferOffline bool
+
+	// Tokens by nerf-darted registry URL, e.g. //npm.example.com/
+	authTokens map[string]string
+	alwaysAuth map[string]bool
+	// always-auth without a registry in front of it
+	alwaysAuthAll bool
+	// _authToken without a registry in front of it, for the default registry
+	defaultToken string
+}
+
+// New returns a configuration that fetches everything from registry
+func New(registry string) *Config {
+	return &Config{
+		Registry:   withSlash(registry),
+		Scopes:     make(map[string]string),
+		authTokens: make(map[string]string),
+		alwaysAuth: make(map[string]bool),
+	}
+}
+
+// Load reads .npmrc files on top of the default registry. Files are given
+// from lowest to highest precedence, so for npm's global, user and project
+// files a setting in the project file wins. Missing files are skipped.
+func Load(registry string, paths ...string) (*Config, error) {
+	c := New(registry)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		err = c.parse(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// parse reads key=value lines, expanding ${VAR} from the environment as npm
+// does so tokens can stay out of the file
+func (c *Config) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		c.set(os.ExpandEnv(strings.TrimSpace(key)), os.ExpandEnv(unquote(strings.TrimSpace(value))))
+	}
+	return scanner.Err()
+}
+
+func (c *Config) set(key, value string) {
+	switch {
+	case key == "registry":
+		c.Registry = withSlash(value)
+	case key == "_authToken":
+		c.defaultToken = value
+	case key == "always-auth":
+		c.alwaysAuthAll = value == "true"
+	case key == "ignore-scripts":
+		c.IgnoreScripts = value == "true"
+	case key == "offline":
+		c.Offline = value == "true"
+	case key == "prefer-offline":
+		c.PreferOffline = value == "true"
+	case strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry"):
+		c.Scopes[strings.TrimSuffix(key, ":registry")] = withSlash(value)
+	case strings.HasPrefix(key, "//"):
+		// Settings for one registry: //host/path/:_authToken=...
+		i := strings.LastIndex(key, ":")
+		if i < 0 {
+			return
+		}
+		dart := withSlash(key[:i])
+		switch key[i+1:] {
+		case "_authToken":
+			c.authTokens[dart] = value
+		case "always-auth":
+			c.alwaysAuth[dart] = value == "true"
+		}
+	}
+}
+
+// RegistryFor returns the registry a package is fetched from: its scope's
+// registry, or the default one
+func (c *Config) RegistryFor(pkg string) string {
+	if scope, _, scoped := strings.Cut(pkg, "/"); scoped && strings.HasPrefix(scope, "@") {
+		if registry, ok := c.Scopes[scope]; ok {
+			return registry
+		}
+	}
+	return c.Registry
+}
+
+// ManifestURL is where a package's manifest is. The slash of a scoped name
+// is escaped, as registries expect.
+func (c *Config) ManifestURL(pkg string) string {
+	return c.RegistryFor(pkg) + strings.Replace(pkg, "/", "%2f", 1)
+}
+
+// TarballURL is where a registry keeps a version's tarball by default,
+// for manifests that do not say
+func (c *Config) TarballURL(pkg, version string) string {
+	base := pkg
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		base = pkg[i+1:]
+	}
+	return fmt.Sprintf("%s%s/-/%s-%s.tgz", c.RegistryFor(pkg), pkg, base, version)
+}
+
+// AuthToken returns the token to send when fetching rawURL for pkg, or "".
+// A token is sent to URLs under the registry it was configured for; with
+// always-auth the token of pkg's registry is sent wherever its tarballs
+// are hosted.
+func (c *Config) AuthToken(rawURL, pkg string) string {
+	if token := c.tokenFor(nerfDart(rawURL)); token != "" {
+		return token
+	}
+	registry := nerfDart(c.RegistryFor(pkg))
+	if c.alwaysAuthAll || c.alwaysAuth[registry] {
+		return c.tokenFor(registry)
+	}
+	return ""
+}
+
+// tokenFor returns the token of the most specific registry dart is under
+func (c *Config) tokenFor(dart string) string {
+	for dart != "" {
+		if token, ok := c.authTokens[dart]; ok {
+			return token
+		}
+		if dart == nerfDart(c.Registry) && c.defaultToken != "" {
+			return c.defaultToken
+		}
+		// Up one path segment: //host/a/b/ -> //host/a/
+		trimmed := strings.TrimSuffix(dart, "/")
+		i := strings.LastIndex(trimmed, "/")
+		if i < 2 {
+			break
+		}
+		dart = trimmed[:i+1]
+	}
+	return ""
+}
+
+// nerfDart reduces a URL to the form .npmrc keys registry settings by:
+// //host/path/, without scheme, credentials, query or file name
+func nerfDart(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	path := u.Path
+	if !strings.HasSuffix(path, "/") {
+		path = path[:strings.LastIndex(path, "/")+1]
+	}
+	return "//" + u.Host + path
+}
+
+func withSlash(s string) string {
+	if s == "" || strings.HasSuffix(s, "/") {
+		return s
+	}
+	return s + "/"
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
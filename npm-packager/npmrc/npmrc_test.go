@@ -0,0 +1,227 @@
+package npmrc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const defaultRegistry = "https://registry.npmjs.org/"
+
+// writeNpmrc writes an .npmrc with the given content and returns its path
+func writeNpmrc(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, ".npmrc")
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T) []string
+		expectError bool
+		validate    func(t *testing.T, c *Config)
+	}{
+		{
+			name: "No files keeps the default registry",
+			setupFunc: func(t *testing.T) []string {
+				return []string{filepath.Join(t.TempDir(), ".npmrc")}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.Equal(t, defaultRegistry, c.Registry)
+				assert.Empty(t, c.Scopes)
+			},
+		},
+		{
+			name: "Registry and scoped registries",
+			setupFunc: func(t *testing.T) []string {
+				return []string{writeNpmrc(t, t.TempDir(), `
+# company registry
+registry=https://npm.example.com/repository/npm
+@company:registry = "https://npm.company.io/"
+; comment
+@other:registry=https://other.example.com/npm/
+`)}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.Equal(t, "https://npm.example.com/repository/npm/", c.Registry)
+				assert.Equal(t, "https://npm.company.io/", c.Scopes["@company"])
+				assert.Equal(t, "https://other.example.com/npm/", c.Scopes["@other"])
+			},
+		},
+		{
+			name: "Later files take precedence",
+			setupFunc: func(t *testing.T) []string {
+				global := writeNpmrc(t, t.TempDir(), "registry=https://global.example.com/\n@company:registry=https://global.company.io/\n")
+				user := writeNpmrc(t, t.TempDir(), "registry=https://user.example.com/\n")
+				project := writeNpmrc(t, t.TempDir(), "@company:registry=https://project.company.io/\n")
+				return []string{global, user, project}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.Equal(t, "https://user.example.com/", c.Registry)
+				assert.Equal(t, "https://project.company.io/", c.Scopes["@company"])
+			},
+		},
+		{
+			name: "Environment variables are expanded",
+			setupFunc: func(t *testing.T) []string {
+				t.Setenv("TEST_NPM_TOKEN", "secret-token")
+				return []string{writeNpmrc(t, t.TempDir(), "//npm.company.io/:_authToken=${TEST_NPM_TOKEN}\n@company:registry=https://npm.company.io/\n")}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.Equal(t, "secret-token", c.AuthToken("https://npm.company.io/@company%2fwidget", "@company/widget"))
+			},
+		},
+		{
+			name: "ignore-scripts",
+			setupFunc: func(t *testing.T) []string {
+				return []string{writeNpmrc(t, t.TempDir(), "ignore-scripts=true\n")}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.True(t, c.IgnoreScripts)
+			},
+		},
+		{
+			name: "offline and prefer-offline",
+			setupFunc: func(t *testing.T) []string {
+				return []string{writeNpmrc(t, t.TempDir(), "offline=true\nprefer-offline=true\n")}
+			},
+			validate: func(t *testing.T, c *Config) {
+				assert.True(t, c.Offline)
+				assert.True(t, c.PreferOffline)
+			},
+		},
+		{
+			name: "Error when a path is a directory",
+			setupFunc: func(t *testing.T) []string {
+				return []string{t.TempDir()}
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths := tc.setupFunc(t)
+			c, err := Load(defaultRegistry, paths...)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tc.validate(t, c)
+		})
+	}
+}
+
+func TestConfig_URLs(t *testing.T) {
+	c := New("https://npm.example.com/npm")
+	c.Scopes["@company"] = "https://npm.company.io/"
+
+	testCases := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"Registry for unscoped package", c.RegistryFor("express"), "https://npm.example.com/npm/"},
+		{"Registry for configured scope", c.RegistryFor("@company/widget"), "https://npm.company.io/"},
+		{"Registry for other scope", c.RegistryFor("@types/node"), "https://npm.example.com/npm/"},
+		{"Manifest URL", c.ManifestURL("express"), "https://npm.example.com/npm/express"},
+		{"Scoped manifest URL", c.ManifestURL("@company/widget"), "https://npm.company.io/@company%2fwidget"},
+		{"Tarball URL", c.TarballURL("express", "4.18.2"), "https://npm.example.com/npm/express/-/express-4.18.2.tgz"},
+		{"Scoped tarball URL", c.TarballURL("@company/widget", "1.0.0"), "https://npm.company.io/@company/widget/-/widget-1.0.0.tgz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.got)
+		})
+	}
+}
+
+func TestConfig_AuthToken(t *testing.T) {
+	testCases := []struct {
+		name     string
+		npmrc    string
+		url      string
+		pkg      string
+		expected string
+	}{
+		{
+			name:     "Token for registry host",
+			npmrc:    "//npm.company.io/:_authToken=abc\n",
+			url:      "https://npm.company.io/widget",
+			pkg:      "widget",
+			expected: "abc",
+		},
+		{
+			name:     "Token for registry path covers its tarballs",
+			npmrc:    "//npm.example.com/repo/npm/:_authToken=abc\n",
+			url:      "https://npm.example.com/repo/npm/widget/-/widget-1.0.0.tgz",
+			pkg:      "widget",
+			expected: "abc",
+		},
+		{
+			name:     "Most specific registry wins",
+			npmrc:    "//npm.example.com/:_authToken=host\n//npm.example.com/private/:_authToken=private\n",
+			url:      "https://npm.example.com/private/widget",
+			pkg:      "widget",
+			expected: "private",
+		},
+		{
+			name:     "Token is not sent to other hosts",
+			npmrc:    "//npm.company.io/:_authToken=abc\n",
+			url:      "https://cdn.example.com/widget-1.0.0.tgz",
+			pkg:      "widget",
+			expected: "",
+		},
+		{
+			name:     "Token is not sent to a sibling path",
+			npmrc:    "//npm.example.com/private/:_authToken=abc\n",
+			url:      "https://npm.example.com/public/widget",
+			pkg:      "widget",
+			expected: "",
+		},
+		{
+			name:     "Bare token belongs to the default registry",
+			npmrc:    "registry=https://npm.company.io/\n_authToken=abc\n",
+			url:      "https://npm.company.io/widget",
+			pkg:      "widget",
+			expected: "abc",
+		},
+		{
+			name:     "Bare token is not sent to scoped registries",
+			npmrc:    "_authToken=abc\n@company:registry=https://npm.company.io/\n",
+			url:      "https://npm.company.io/@company%2fwidget",
+			pkg:      "@company/widget",
+			expected: "",
+		},
+		{
+			name:     "Always-auth sends the registry token to tarball hosts",
+			npmrc:    "@company:registry=https://npm.company.io/\n//npm.company.io/:_authToken=abc\n//npm.company.io/:always-auth=true\n",
+			url:      "https://cdn.company.io/widget-1.0.0.tgz",
+			pkg:      "@company/widget",
+			expected: "abc",
+		},
+		{
+			name:     "Global always-auth",
+			npmrc:    "always-auth=true\n//registry.npmjs.org/:_authToken=abc\n",
+			url:      "https://cdn.example.com/widget-1.0.0.tgz",
+			pkg:      "widget",
+			expected: "abc",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Load(defaultRegistry, writeNpmrc(t, t.TempDir(), tc.npmrc))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, c.AuthToken(tc.url, tc.pkg))
+		})
+	}
+}
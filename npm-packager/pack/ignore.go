@@ -0,0 +1,118 @@
+package pack
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rule is one line of a .npmignore or .gitignore file
+type rule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	// Patterns with a slash before their end match from the directory of
+	// the file; others match a name at any depth below it
+	anchored bool
+	// Directory of the ignore file, relative to the package
+	base string
+}
+
+// readRules reads the .npmignore of dir, or its .gitignore when it has no
+// .npmignore, as npm does. rel is dir relative to the package.
+func readRules(dir, rel string) ([]rule, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ".npmignore"))
+	if os.IsNotExist(err) {
+		content, err = os.ReadFile(filepath.Join(dir, ".gitignore"))
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(string(content), rel), nil
+}
+
+// parseRules parses gitignore patterns: # comments, ! negation, a trailing
+// / for directories only, * and ? within a name and ** across directories
+func parseRules(content, base string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r rule
+		r.base = base
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		r.segments = strings.Split(line, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matches reports whether the rule matches rel, a path relative to the
+// package
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		return globMatch(r.segments, []string{path.Base(rel)})
+	}
+	return globMatch(r.segments, strings.Split(rel, "/"))
+}
+
+// ignored applies rules in order, the last one that matches deciding
+func ignored(rules []rule, rel string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.matches(rel, isDir) {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// globMatch matches path segments against pattern segments, where **
+// stands for any number of segments
+func globMatch(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if globMatch(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+	return globMatch(pattern[1:], name[1:])
+}
@@ -0,0 +1,362 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"npm-packager/integrity"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// modTime is the time npm gives every file it packs, so the same files
+// always pack to the same bytes
+var modTime = time.Date(1985, time.October, 26, 8, 15, 0, 0, time.UTC)
+
+// excluded are never packed, at any depth, whatever files and .npmignore
+// say
+var excluded = []string{
+	".git", "CVS", ".svn", ".hg", ".lock-wscript", ".wafpickle-*", ".*.swp", ".DS_Store", "._*",
+	"npm-debug.log", ".npmrc", "node_modules", "config.gypi", "*.orig", ".npmignore", ".gitignore",
+}
+
+// rootExcluded are lock files, never packed from the package's root
+var rootExcluded = map[string]bool{
+	"package-lock.json":    true,
+	"yarn.lock":            true,
+	"pnpm-lock.yaml":       true,
+	"go-package-lock.json": true,
+}
+
+// manifest holds the fields of package.json that decide what is packed
+type manifest struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Files   []string `json:"files"`
+	Main    string   `json:"main"`
+	// A path, or paths by command name
+	Bin any `json:"bin"`
+}
+
+// readManifest reads the package.json of dir; a directory without one
+// packs as if it had no fields
+func readManifest(dir string) (*manifest, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return &manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	return &m, nil
+}
+
+// included returns the root relative paths packed whatever files and
+// .npmignore say: package.json, the main file and the bin files
+func (m *manifest) included() map[string]bool {
+	paths := []string{"package.json", m.Main}
+	switch bin := m.Bin.(type) {
+	case string:
+		paths = append(paths, bin)
+	case map[string]any:
+		for _, p := range bin {
+			if s, ok := p.(string); ok {
+				paths = append(paths, s)
+			}
+		}
+	}
+	included := make(map[string]bool)
+	for _, p := range paths {
+		if p != "" {
+			included[path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "./"))] = true
+		}
+	}
+	return included
+}
+
+// fileRules turns the files field into rules that match a file listed or
+// inside a directory listed
+func (m *manifest) fileRules() []rule {
+	var rules []rule
+	for _, entry := range m.Files {
+		r := rule{anchored: true}
+		if strings.HasPrefix(entry, "!") {
+			r.negate = true
+			entry = entry[1:]
+		}
+		entry = strings.Trim(path.Clean(strings.TrimPrefix(filepath.ToSlash(entry), "./")), "/")
+		if entry == "" || entry == "." {
+			continue
+		}
+		r.segments = strings.Split(entry, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// listed reports whether rel is a file the files field takes in: the last
+// entry matching it or one of its directories decides
+func listed(rules []rule, rel string) bool {
+	result := false
+	for _, r := range rules {
+		matched := r.matches(rel, false)
+		for dir := path.Dir(rel); !matched && dir != "."; dir = path.Dir(dir) {
+			matched = r.matches(dir, true)
+		}
+		if matched {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// alwaysPacked are packed from the root whatever files and .npmignore say:
+// README, LICENSE and LICENCE, with any extension
+func alwaysPacked(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range []string{"readme", "license", "licence"} {
+		if lower == prefix || strings.HasPrefix(lower, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isExcluded(name string) bool {
+	for _, pattern := range excluded {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Files lists the files of the package in dir to pack, relative to dir
+// with forward slashes, sorted. As npm does, it takes the files field of
+// package.json when there is one, leaves out what .npmignore (or
+// .gitignore where there is none) matches, and always packs package.json,
+// README and LICENSE files, the main file and the bin files.
+func Files(dir string) ([]string, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &packlist{
+		dir:      dir,
+		included: m.included(),
+		listed:   m.Files != nil,
+		files:    m.fileRules(),
+	}
+	if err := p.walk("", nil, false); err != nil {
+		return nil, fmt.Errorf("failed to list the files of %s: %w", dir, err)
+	}
+	sort.Strings(p.result)
+	return p.result, nil
+}
+
+// packlist collects the files to pack as it walks the package
+type packlist struct {
+	dir      string
+	included map[string]bool
+	// Whether package.json has a files field, and its entries
+	listed bool
+	files  []rule
+	result []string
+}
+
+// walk adds the files of the directory rel, with the ignore rules of the
+// directories above it. Only the main and bin files are taken from an
+// ignored directory.
+func (p *packlist) walk(rel string, rules []rule, ignoredDir bool) error {
+	abs := filepath.Join(p.dir, filepath.FromSlash(rel))
+	// The files field takes the place of the root's ignore file
+	if rel != "" || !p.listed {
+		own, err := readRules(abs, rel)
+		if err != nil {
+			return err
+		}
+		rules = append(rules[:len(rules):len(rules)], own...)
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		child := path.Join(rel, name)
+		if isExcluded(name) || rel == "" && rootExcluded[name] {
+			continue
+		}
+		if entry.IsDir() {
+			skip := ignoredDir || ignored(rules, child, true)
+			if !skip || p.holdsIncluded(child) {
+				if err := p.walk(child, rules, skip); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		switch {
+		case p.included[child], rel == "" && alwaysPacked(name):
+		case ignoredDir:
+			continue
+		case p.listed && !listed(p.files, child):
+			continue
+		case ignored(rules, child, false):
+			continue
+		}
+		p.result = append(p.result, child)
+	}
+	return nil
+}
+
+// holdsIncluded reports whether the directory rel has a main or bin file
+// below it
+func (p *packlist) holdsIncluded(rel string) bool {
+	for included := range p.included {
+		if strings.HasPrefix(included, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Pack writes the package in dir to dst as a gzipped tarball, with its
+// files under package/ as npm packs them
+func Pack(dir, dst string) error {
+	files, err := Files(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if err := write(dir, files, out); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
+
+// Tarball is a package packed in memory, as pack and publish make it
+type Tarball struct {
+	Name    string
+	Version string
+	Files   []string
+	Data    []byte
+	// Of Data: the sha512 SRI and the legacy sha1 hex digest
+	Integrity string
+	Shasum    string
+}
+
+// Create packs the package in dir, which must have a name and a version
+func Create(dir string) (*Tarball, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Name == "" || m.Version == "" {
+		return nil, fmt.Errorf("%s needs a name and a version to be packed", filepath.Join(dir, "package.json"))
+	}
+	files, err := Files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := write(dir, files, &buf); err != nil {
+		return nil, err
+	}
+	sri, err := integrity.Compute(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	sha1Sum := sha1.Sum(buf.Bytes())
+
+	return &Tarball{
+		Name:      m.Name,
+		Version:   m.Version,
+		Files:     files,
+		Data:      buf.Bytes(),
+		Integrity: integrity.Strongest(sri),
+		Shasum:    hex.EncodeToString(sha1Sum[:]),
+	}, nil
+}
+
+// Filename is the name npm gives the tarball: name-version.tgz, with the
+// @ of a scope dropped and its slash made a dash
+func (t *Tarball) Filename() string {
+	name := strings.Replace(strings.TrimPrefix(t.Name, "@"), "/", "-", 1)
+	return name + "-" + t.Version + ".tgz"
+}
+
+func write(dir string, files []string, w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		mode := int64(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		header := &tar.Header{
+			Name:     "package/" + name,
+			Mode:     mode,
+			Size:     info.Size(),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to pack %s: %w", name, err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		_, err = io.Copy(tw, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to pack %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	return nil
+}
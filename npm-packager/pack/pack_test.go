@@ -0,0 +1,330 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFiles writes files, by slash separated path, under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+// readTarball returns the entries of a packed tarball with their modes
+func readTarball(t *testing.T, path string) map[string]int64 {
+	t.Helper()
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	gzr, err := gzip.NewReader(file)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gzr)
+
+	entries := make(map[string]int64)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		entries[header.Name] = header.Mode
+	}
+	return entries
+}
+
+func TestPack(t *testing.T) {
+	testCases := []struct {
+		name      string
+		setupFunc func(t *testing.T, dir string)
+		expected  map[string]int64
+	}{
+		{
+			name: "Files go under package/",
+			setupFunc: func(t *testing.T, dir string) {
+				writeFiles(t, dir, map[string]string{
+					"package.json": `{"name":"lib","version":"1.0.0"}`,
+					"index.js":     "module.exports = 1\n",
+					"lib/util.js":  "",
+				})
+			},
+			expected: map[string]int64{
+				"package/package.json": 0644,
+				"package/index.js":     0644,
+				"package/lib/util.js":  0644,
+			},
+		},
+		{
+			name: "node_modules and .git are left out",
+			setupFunc: func(t *testing.T, dir string) {
+				writeFiles(t, dir, map[string]string{
+					"package.json":                  `{"name":"lib","version":"1.0.0"}`,
+					"node_modules/dep/package.json": `{}`,
+					".git/HEAD":                     "ref: refs/heads/main\n",
+				})
+			},
+			expected: map[string]int64{
+				"package/package.json": 0644,
+			},
+		},
+		{
+			name: "Executables stay executable",
+			setupFunc: func(t *testing.T, dir string) {
+				writeFiles(t, dir, map[string]string{
+					"package.json": `{"name":"lib","version":"1.0.0"}`,
+					"bin/cli.js":   "#!/usr/bin/env node\n",
+				})
+				assert.NoError(t, os.Chmod(filepath.Join(dir, "bin", "cli.js"), 0700))
+			},
+			expected: map[string]int64{
+				"package/package.json": 0644,
+				"package/bin/cli.js":   0755,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.setupFunc(t, dir)
+
+			dst := filepath.Join(t.TempDir(), "out", "lib.tgz")
+			assert.NoError(t, Pack(dir, dst))
+			assert.Equal(t, tc.expected, readTarball(t, dst))
+		})
+	}
+}
+
+func TestPack_Reproducible(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json": `{"name":"lib","version":"1.0.0"}`,
+		"index.js":     "module.exports = 1\n",
+	})
+
+	first := filepath.Join(t.TempDir(), "first.tgz")
+	assert.NoError(t, Pack(dir, first))
+
+	// Touching a file does not change the tarball
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "index.js"), modTime.AddDate(30, 0, 0), modTime.AddDate(30, 0, 0)))
+	second := filepath.Join(t.TempDir(), "second.tgz")
+	assert.NoError(t, Pack(dir, second))
+
+	firstContent, err := os.ReadFile(first)
+	assert.NoError(t, err)
+	secondContent, err := os.ReadFile(second)
+	assert.NoError(t, err)
+	assert.Equal(t, firstContent, secondContent)
+}
+
+func TestPack_MissingDirectory(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "lib.tgz")
+	assert.Error(t, Pack(filepath.Join(t.TempDir(), "missing"), dst))
+	assert.NoFileExists(t, dst)
+}
+
+func TestFiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name: "files field picks files and directories",
+			files: map[string]string{
+				"package.json":     `{"name":"lib","version":"1.0.0","files":["dist","types/*.d.ts"]}`,
+				"dist/index.js":    "",
+				"dist/deep/a.js":   "",
+				"types/index.d.ts": "",
+				"types/notes.txt":  "",
+				"src/index.ts":     "",
+			},
+			expected: []string{"dist/deep/a.js", "dist/index.js", "package.json", "types/index.d.ts"},
+		},
+		{
+			name: "files field entries can be negated",
+			files: map[string]string{
+				"package.json":      `{"name":"lib","version":"1.0.0","files":["dist","!dist/*.map"]}`,
+				"dist/index.js":     "",
+				"dist/index.js.map": "",
+			},
+			expected: []string{"dist/index.js", "package.json"},
+		},
+		{
+			name: "package.json, README, LICENSE, main and bin are always packed",
+			files: map[string]string{
+				"package.json": `{"name":"lib","version":"1.0.0","files":["dist"],"main":"./lib/main.js","bin":{"lib":"bin/cli.js"}}`,
+				"README.md":    "",
+				"LICENSE":      "",
+				"licence.txt":  "",
+				"lib/main.js":  "",
+				"lib/other.js": "",
+				"bin/cli.js":   "",
+				"docs/README":  "",
+			},
+			expected: []string{"LICENSE", "README.md", "bin/cli.js", "lib/main.js", "licence.txt", "package.json"},
+		},
+		{
+			name: ".npmignore leaves files out",
+			files: map[string]string{
+				"package.json":      `{"name":"lib","version":"1.0.0"}`,
+				".npmignore":        "# tests\ntest/\n*.log\n/coverage\n!keep.log\n",
+				"index.js":          "",
+				"debug.log":         "",
+				"keep.log":          "",
+				"test/a.js":         "",
+				"coverage/lcov":     "",
+				"src/coverage/x.js": "",
+			},
+			expected: []string{"index.js", "keep.log", "package.json", "src/coverage/x.js"},
+		},
+		{
+			name: ".gitignore is used where there is no .npmignore",
+			files: map[string]string{
+				"package.json": `{"name":"lib","version":"1.0.0"}`,
+				".gitignore":   "build\n",
+				"index.js":     "",
+				"build/out.js": "",
+			},
+			expected: []string{"index.js", "package.json"},
+		},
+		{
+			name: ".npmignore takes the place of .gitignore",
+			files: map[string]string{
+				"package.json":  `{"name":"lib","version":"1.0.0"}`,
+				".gitignore":    "dist\n",
+				".npmignore":    "src\n",
+				"dist/index.js": "",
+				"src/index.ts":  "",
+			},
+			expected: []string{"dist/index.js", "package.json"},
+		},
+		{
+			name: "nested .npmignore applies below its directory",
+			files: map[string]string{
+				"package.json":        `{"name":"lib","version":"1.0.0","files":["lib"]}`,
+				".npmignore":          "lib\n",
+				"lib/index.js":        "",
+				"lib/.npmignore":      "fixtures/**\n",
+				"lib/fixtures/a.json": "",
+			},
+			expected: []string{"lib/index.js", "package.json"},
+		},
+		{
+			name: "the main file is packed from an ignored directory",
+			files: map[string]string{
+				"package.json":  `{"name":"lib","version":"1.0.0","main":"dist/index.js"}`,
+				".gitignore":    "dist/\n",
+				"dist/index.js": "",
+				"dist/other.js": "",
+			},
+			expected: []string{"dist/index.js", "package.json"},
+		},
+		{
+			name: "lock files and editor leftovers are never packed",
+			files: map[string]string{
+				"package.json":               `{"name":"lib","version":"1.0.0","files":["*"]}`,
+				"package-lock.json":          "{}",
+				"go-package-lock.json":       "{}",
+				".npmrc":                     "",
+				"index.js.orig":              "",
+				".index.js.swp":              "",
+				".DS_Store":                  "",
+				"index.js":                   "",
+				"fixtures/package-lock.json": "{}",
+			},
+			expected: []string{"fixtures/package-lock.json", "index.js", "package.json"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			files, err := Files(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, files)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		expectError bool
+		validate    func(t *testing.T, tgz *Tarball, dir string)
+	}{
+		{
+			name:        "Packs in memory with its digests",
+			packageJSON: `{"name":"lib","version":"1.2.3"}`,
+			validate: func(t *testing.T, tgz *Tarball, dir string) {
+				assert.Equal(t, "lib", tgz.Name)
+				assert.Equal(t, "1.2.3", tgz.Version)
+				assert.Equal(t, "lib-1.2.3.tgz", tgz.Filename())
+				assert.Equal(t, []string{"index.js", "package.json"}, tgz.Files)
+
+				dst := filepath.Join(t.TempDir(), "lib.tgz")
+				assert.NoError(t, Pack(dir, dst))
+				content, err := os.ReadFile(dst)
+				assert.NoError(t, err)
+				assert.Equal(t, content, tgz.Data, "Create and Pack give the same bytes")
+
+				sha512Sum := sha512.Sum512(tgz.Data)
+				sha1Sum := sha1.Sum(tgz.Data)
+				assert.Equal(t, "sha512-"+base64.StdEncoding.EncodeToString(sha512Sum[:]), tgz.Integrity)
+				assert.Equal(t, hex.EncodeToString(sha1Sum[:]), tgz.Shasum)
+			},
+		},
+		{
+			name:        "Scoped packages are named without the @",
+			packageJSON: `{"name":"@scope/lib","version":"2.0.0"}`,
+			validate: func(t *testing.T, tgz *Tarball, dir string) {
+				assert.Equal(t, "scope-lib-2.0.0.tgz", tgz.Filename())
+			},
+		},
+		{
+			name:        "A version is needed",
+			packageJSON: `{"name":"lib"}`,
+			expectError: true,
+		},
+		{
+			name:        "package.json must parse",
+			packageJSON: `{"name":`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"package.json": tc.packageJSON,
+				"index.js":     "module.exports = 1\n",
+			})
+
+			tgz, err := Create(dir)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tc.validate(t, tgz, dir)
+		})
+	}
+}
@@ -51,6 +51,26 @@ func (pc *PackageCopy) CopyDirectory(src, dst string) error {
 	return nil
 }
 
+// LinkFile puts the content of src at dst, replacing what is there: as a
+// hard link when it can, otherwise as CloneFile does, e.g. across filesystems
+func (pc *PackageCopy) LinkFile(src, dst string) error {
+	// Writing through an old hard link would change every copy of it
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %v", dst, err)
+	}
+	return pc.copyFile(src, dst)
+}
+
+// CloneFile puts a copy of src at dst that can be written to without
+// changing src: a reflink (copy-on-write clone) where the filesystem
+// supports it, a plain copy otherwise
+func (pc *PackageCopy) CloneFile(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %v", dst, err)
+	}
+	return pc.cloneFile(src, dst)
+}
+
 func (pc *PackageCopy) copyFile(src, dst string) error {
 	// Try hardlink first (fast, no copy, works with Node.js resolution)
 	err := os.Link(src, dst)
@@ -58,7 +78,11 @@ func (pc *PackageCopy) copyFile(src, dst string) error {
 		return nil
 	}
 
-	// Fallback to regular copy if hardlink fails (e.g., cross-device)
+	// Fallback to a reflink or regular copy if hardlink fails (e.g., cross-device)
+	return pc.cloneFile(src, dst)
+}
+
+func (pc *PackageCopy) cloneFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %v", err)
@@ -76,6 +100,10 @@ func (pc *PackageCopy) copyFile(src, dst string) error {
 	}
 	defer dstFile.Close()
 
+	if err := reflink(srcFile, dstFile); err == nil {
+		return nil
+	}
+
 	if _, err := io.Copy(dstFile, srcFile); err != nil {
 		return fmt.Errorf("failed to copy file contents: %v", err)
 	}
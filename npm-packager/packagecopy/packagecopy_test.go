@@ -101,3 +101,49 @@ func TestPackageCopyCopyDirectory(t *testing.T) {
 	}
 }
 
+func TestPackageCopyLinkAndCloneFile(t *testing.T) {
+	testCases := []struct {
+		name   string
+		clone  bool
+		linked bool
+	}{
+		{name: "links the file", linked: true},
+		{name: "clones the file", clone: true, linked: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			src := filepath.Join(baseDir, "src.txt")
+			other := filepath.Join(baseDir, "other.txt")
+			dst := filepath.Join(baseDir, "dst.txt")
+			assert.NoError(t, os.WriteFile(src, []byte("new-content"), 0o644))
+			assert.NoError(t, os.WriteFile(other, []byte("old-content"), 0o644))
+			// dst starts as a hard link to another file, which must not change
+			assert.NoError(t, os.Link(other, dst))
+
+			pc := NewPackageCopy()
+			var err error
+			if tc.clone {
+				err = pc.CloneFile(src, dst)
+			} else {
+				err = pc.LinkFile(src, dst)
+			}
+			assert.NoError(t, err)
+
+			content, err := os.ReadFile(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("new-content"), content)
+
+			content, err = os.ReadFile(other)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("old-content"), content)
+
+			srcInfo, err := os.Stat(src)
+			assert.NoError(t, err)
+			dstInfo, err := os.Stat(dst)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.linked, os.SameFile(srcInfo, dstInfo))
+		})
+	}
+}
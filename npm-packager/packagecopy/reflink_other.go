@@ -0,0 +1,13 @@
+//go:build !linux
+
+package packagecopy
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is only implemented on Linux; elsewhere files are copied
+func reflink(src, dst *os.File) error {
+	return errors.ErrUnsupported
+}
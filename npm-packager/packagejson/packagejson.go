@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"npm-packager/config"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/tidwall/gjson"
@@ -31,15 +32,90 @@ type PackageJSON struct {
 	Keywords        any               `json:"keywords"`
 	Dependencies    map[string]string `json:"dependencies"`
 	DevDependencies map[string]string `json:"devDependencies"`
-	Engines         any               `json:"engines"`
-	Files           []string          `json:"files"`
-	Scripts         map[string]string `json:"scripts"`
-	Main            any               `json:"main"`
-	Bin             any               `json:"bin"`
-	Types           string            `json:"types"`
-	Exports         any               `json:"exports"`
-	Private         bool              `json:"private"`
-	Workspaces      []string          `json:"workspaces"`
+	// Installed where possible, left out when they fail or do not fit the platform
+	OptionalDependencies map[string]string             `json:"optionalDependencies"`
+	PeerDependencies     map[string]string             `json:"peerDependencies"`
+	PeerDependenciesMeta map[string]PeerDependencyMeta `json:"peerDependenciesMeta"`
+	// Shipped inside the package's own tarball; npm accepts both spellings
+	BundleDependencies  BundleDependencies `json:"bundleDependencies"`
+	BundledDependencies BundleDependencies `json:"bundledDependencies"`
+	OS                  []string           `json:"os"`
+	CPU                 []string           `json:"cpu"`
+	Engines             any                `json:"engines"`
+	Files               []string           `json:"files"`
+	Scripts             map[string]string  `json:"scripts"`
+	Main                any                `json:"main"`
+	Bin                 any                `json:"bin"`
+	Types               string             `json:"types"`
+	Exports             any                `json:"exports"`
+	Private             bool               `json:"private"`
+	Workspaces          Workspaces         `json:"workspaces"`
+}
+
+// PeerDependencyMeta is an entry of peerDependenciesMeta
+type PeerDependencyMeta struct {
+	Optional bool `json:"optional"`
+}
+
+// BundleDependencies lists the dependencies a package ships in its tarball:
+// either their names, or true for all of its dependencies
+type BundleDependencies struct {
+	All   bool
+	Names []string
+}
+
+func (b *BundleDependencies) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &b.All); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &b.Names); err != nil {
+		return fmt.Errorf("bundleDependencies must be an array or a boolean: %w", err)
+	}
+	return nil
+}
+
+func (b BundleDependencies) MarshalJSON() ([]byte, error) {
+	if b.All {
+		return json.Marshal(true)
+	}
+	return json.Marshal(b.Names)
+}
+
+// Bundled returns the set of dependencies the package ships with it
+func (p *PackageJSON) Bundled() map[string]bool {
+	bundled := make(map[string]bool)
+	for _, b := range []BundleDependencies{p.BundleDependencies, p.BundledDependencies} {
+		if b.All {
+			for name := range p.Dependencies {
+				bundled[name] = true
+			}
+		}
+		for _, name := range b.Names {
+			bundled[name] = true
+		}
+	}
+	return bundled
+}
+
+// Workspaces are the glob patterns of workspace packages. package.json has
+// them either as an array or, as yarn writes it, under "packages".
+type Workspaces []string
+
+func (w *Workspaces) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("workspaces must be an array or an object with packages: %w", err)
+	}
+	*w = object.Packages
+	return nil
 }
 
 type Funding struct {
@@ -59,23 +135,40 @@ type PackageJSONParser struct {
 }
 
 type PackageLock struct {
-	Name            string                 `json:"name"`
-	Version         string                 `json:"version"`
-	LockfileVersion int                    `json:"lockfileVersion"`
-	Requires        bool                   `json:"requires"`
-	Dependencies    map[string]string      `json:"dependencies"`
-	DevDependencies map[string]string      `json:"devDependencies,omitempty"`
-	Packages        map[string]PackageItem `json:"packages"`
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	LockfileVersion int               `json:"lockfileVersion"`
+	Requires        bool              `json:"requires"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies,omitempty"`
+	// Optional dependencies of the root package
+	OptionalDependencies map[string]string      `json:"optionalDependencies,omitempty"`
+	Packages             map[string]PackageItem `json:"packages"`
 }
 
+// PackageItem is a package of the lock file. Link entries stand for a
+// symlink to the workspace directory in Resolved; InBundle entries came
+// inside the tarball of the package they are nested in. Dev, Optional and
+// Peer mark packages only needed through those kinds of dependencies.
 type PackageItem struct {
-	Name         string            `json:"name,omitempty"`
-	Version      string            `json:"version,omitempty"`
-	Resolved     string            `json:"resolved,omitempty"`
-	Integrity    string            `json:"integrity,omitempty"`
-	License      any               `json:"license,omitempty"`
-	Etag         string            `json:"etag,omitempty"`
-	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Name                 string                        `json:"name,omitempty"`
+	Version              string                        `json:"version,omitempty"`
+	Resolved             string                        `json:"resolved,omitempty"`
+	Integrity            string                        `json:"integrity,omitempty"`
+	License              any                           `json:"license,omitempty"`
+	Etag                 string                        `json:"etag,omitempty"`
+	Link                 bool                          `json:"link,omitempty"`
+	InBundle             bool                          `json:"inBundle,omitempty"`
+	Dev                  bool                          `json:"dev,omitempty"`
+	Optional             bool                          `json:"optional,omitempty"`
+	Peer                 bool                          `json:"peer,omitempty"`
+	Dependencies         map[string]string             `json:"dependencies,omitempty"`
+	OptionalDependencies map[string]string             `json:"optionalDependencies,omitempty"`
+	PeerDependencies     map[string]string             `json:"peerDependencies,omitempty"`
+	PeerDependenciesMeta map[string]PeerDependencyMeta `json:"peerDependenciesMeta,omitempty"`
+	BundleDependencies   []string                      `json:"bundleDependencies,omitempty"`
+	OS                   []string                      `json:"os,omitempty"`
+	CPU                  []string                      `json:"cpu,omitempty"`
 }
 
 func NewPackageJSONParser(cfg *config.Config) *PackageJSONParser {
@@ -311,14 +404,63 @@ func (p *PackageJSONParser) ResolveDependencies() (toInstall []Dependency, toRem
 	return toInstall, toRemove
 }
 
+// LockMismatches lists where package.json and the lock file disagree about
+// direct dependencies, in the order package.json, then the lock, lists them
+func (p *PackageJSONParser) LockMismatches() []string {
+	var mismatches []string
+	mismatches = append(mismatches, compareSpecs("dependencies", p.PackageJSON.Dependencies, p.PackageLock.Dependencies)...)
+	mismatches = append(mismatches, compareSpecs("devDependencies", p.PackageJSON.DevDependencies, p.PackageLock.DevDependencies)...)
+	mismatches = append(mismatches, compareSpecs("optionalDependencies", p.PackageJSON.OptionalDependencies, p.PackageLock.OptionalDependencies)...)
+	return mismatches
+}
+
+func compareSpecs(field string, inJSON, inLock map[string]string) []string {
+	var mismatches []string
+	for _, name := range sortedKeys(inJSON) {
+		versionInLock, exists := inLock[name]
+		switch {
+		case !exists:
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s@%s is missing from the lock file", field, name, inJSON[name]))
+		case versionInLock != inJSON[name]:
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s is %s in package.json but %s in the lock file", field, name, inJSON[name], versionInLock))
+		}
+	}
+	for _, name := range sortedKeys(inLock) {
+		if _, exists := inJSON[name]; !exists {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s@%s is in the lock file but not in package.json", field, name, inLock[name]))
+		}
+	}
+	return mismatches
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (p *PackageJSONParser) ResolveDependenciesToRemove(pkg string) []string {
 	pkgToKeep := make(map[string]bool)
 
+	var keepRoots []string
 	for directDep := range p.PackageLock.Dependencies {
-		if directDep == pkg {
-			continue
+		if directDep != pkg {
+			keepRoots = append(keepRoots, directDep)
 		}
+	}
+	// Workspaces, recorded under their directories, keep what they depend on
+	for pkgPath, item := range p.PackageLock.Packages {
+		if pkgPath != "" && !strings.HasPrefix(pkgPath, "node_modules/") {
+			for dep := range item.Dependencies {
+				keepRoots = append(keepRoots, dep)
+			}
+		}
+	}
 
+	for _, directDep := range keepRoots {
 		visited := make(map[string]bool)
 		queue := []string{directDep}
 
@@ -125,3 +125,148 @@ func TestPackageJSONParser_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageJSONParser_LockMismatches(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     PackageJSON
+		lock     PackageLock
+		expected []string
+	}{
+		{
+			name: "In agreement",
+			json: PackageJSON{
+				Dependencies:    map[string]string{"express": "^4.18.0"},
+				DevDependencies: map[string]string{"jest": "^29.0.0"},
+			},
+			lock: PackageLock{
+				Dependencies:    map[string]string{"express": "^4.18.0"},
+				DevDependencies: map[string]string{"jest": "^29.0.0"},
+			},
+			expected: nil,
+		},
+		{
+			name: "Missing, changed and extra dependencies",
+			json: PackageJSON{
+				Dependencies:    map[string]string{"express": "^4.19.0", "lodash": "^4.17.21"},
+				DevDependencies: map[string]string{},
+			},
+			lock: PackageLock{
+				Dependencies:    map[string]string{"express": "^4.18.0", "chalk": "^5.0.0"},
+				DevDependencies: map[string]string{"jest": "^29.0.0"},
+			},
+			expected: []string{
+				"dependencies: express is ^4.19.0 in package.json but ^4.18.0 in the lock file",
+				"dependencies: lodash@^4.17.21 is missing from the lock file",
+				"dependencies: chalk@^5.0.0 is in the lock file but not in package.json",
+				"devDependencies: jest@^29.0.0 is in the lock file but not in package.json",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := &PackageJSONParser{PackageJSON: &tc.json, PackageLock: &tc.lock}
+			assert.Equal(t, tc.expected, parser.LockMismatches())
+		})
+	}
+}
+
+func TestWorkspaces_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name        string
+		json        string
+		expectError bool
+		expected    Workspaces
+	}{
+		{name: "Array", json: `{"workspaces":["packages/*","apps/web"]}`, expected: Workspaces{"packages/*", "apps/web"}},
+		{name: "Object with packages", json: `{"workspaces":{"packages":["packages/*"],"nohoist":["**/react"]}}`, expected: Workspaces{"packages/*"}},
+		{name: "Absent", json: `{}`, expected: nil},
+		{name: "Invalid", json: `{"workspaces":"packages/*"}`, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pj PackageJSON
+			err := json.Unmarshal([]byte(tc.json), &pj)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, pj.Workspaces)
+		})
+	}
+}
+
+func TestPackageJSON_Bundled(t *testing.T) {
+	testCases := []struct {
+		name        string
+		json        string
+		expectError bool
+		expected    map[string]bool
+	}{
+		{name: "Names", json: `{"dependencies":{"a":"1","b":"1"},"bundleDependencies":["a"]}`, expected: map[string]bool{"a": true}},
+		{name: "Other spelling", json: `{"dependencies":{"a":"1"},"bundledDependencies":["a"]}`, expected: map[string]bool{"a": true}},
+		{name: "All dependencies", json: `{"dependencies":{"a":"1","b":"1"},"bundleDependencies":true}`, expected: map[string]bool{"a": true, "b": true}},
+		{name: "None", json: `{"dependencies":{"a":"1"},"bundleDependencies":false}`, expected: map[string]bool{}},
+		{name: "Invalid", json: `{"bundleDependencies":"a"}`, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pj PackageJSON
+			err := json.Unmarshal([]byte(tc.json), &pj)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, pj.Bundled())
+		})
+	}
+}
+
+func TestPackageJSONParser_ResolveDependenciesToRemove(t *testing.T) {
+	packages := map[string]PackageItem{
+		"node_modules/express": {Dependencies: map[string]string{"debug": "^2.0.0"}},
+		"node_modules/debug":   {Dependencies: map[string]string{"ms": "^2.0.0"}},
+		"node_modules/ms":      {},
+		"node_modules/lodash":  {},
+	}
+
+	testCases := []struct {
+		name     string
+		packages map[string]PackageItem
+		expected []string
+	}{
+		{
+			name:     "Removes the package and what only it needs",
+			packages: packages,
+			expected: []string{"express", "debug", "ms"},
+		},
+		{
+			name: "Keeps what a workspace depends on",
+			packages: func() map[string]PackageItem {
+				withWorkspace := map[string]PackageItem{"packages/api": {Dependencies: map[string]string{"debug": "^2.0.0"}}}
+				for key, item := range packages {
+					withWorkspace[key] = item
+				}
+				return withWorkspace
+			}(),
+			expected: []string{"express"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := &PackageJSONParser{PackageLock: &PackageLock{
+				Dependencies: map[string]string{"express": "^4.18.0", "lodash": "^4.17.21"},
+				Packages:     tc.packages,
+			}}
+			assert.Equal(t, tc.expected, parser.ResolveDependenciesToRemove("express"))
+		})
+	}
+}
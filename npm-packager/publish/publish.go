@@ -0,0 +1,192 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"npm-packager/npmrc"
+	"npm-packager/pack"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrPrivate is returned for a package.json with "private": true
+var ErrPrivate = errors.New("package is private")
+
+// ErrVersionExists is returned when the registry already has the version
+var ErrVersionExists = errors.New("version already published")
+
+// Options are the flags of publish; publishConfig in package.json fills in
+// what they leave empty
+type Options struct {
+	// Dist-tag pointed at the version, latest when empty
+	Tag string
+	// public or restricted, for scoped packages
+	Access string
+	// Pack and report, without sending anything
+	DryRun bool
+}
+
+// Publisher uploads packages to the registry .npmrc configures for them,
+// as npm publish does
+type Publisher struct {
+	Client   *http.Client
+	registry *npmrc.Config
+}
+
+func NewPublisher(registry *npmrc.Config) *Publisher {
+	return &Publisher{
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+		registry: registry,
+	}
+}
+
+// publishConfig is the publishConfig field of package.json
+type publishConfig struct {
+	Registry string `json:"registry"`
+	Tag      string `json:"tag"`
+	Access   string `json:"access"`
+}
+
+// packument is the document PUT to the registry: the new version's
+// manifest, the dist-tag to point at it and the tarball as an attachment
+type packument struct {
+	ID          string                    `json:"_id"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description,omitempty"`
+	DistTags    map[string]string         `json:"dist-tags"`
+	Versions    map[string]map[string]any `json:"versions"`
+	Access      string                    `json:"access,omitempty"`
+	Attachments map[string]attachment     `json:"_attachments"`
+}
+
+type attachment struct {
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+	Length      int    `json:"length"`
+}
+
+// Publish packs the package in dir and PUTs it to its registry with the
+// auth token .npmrc has for it. It returns the tarball published.
+func (p *Publisher) Publish(dir string, opts Options) (*pack.Tarball, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	var version map[string]any
+	if err := json.Unmarshal(content, &version); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	var fields struct {
+		Name          string        `json:"name"`
+		Private       bool          `json:"private"`
+		Description   string        `json:"description"`
+		PublishConfig publishConfig `json:"publishConfig"`
+	}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+
+	if fields.Private {
+		return nil, fmt.Errorf("%w: remove \"private\": true from package.json to publish %s", ErrPrivate, fields.Name)
+	}
+	tgz, err := pack.Create(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := firstOf(opts.Tag, fields.PublishConfig.Tag, "latest")
+	access := firstOf(opts.Access, fields.PublishConfig.Access)
+	registry := p.registry.RegistryFor(tgz.Name)
+	if fields.PublishConfig.Registry != "" {
+		registry = strings.TrimSuffix(fields.PublishConfig.Registry, "/") + "/"
+	}
+
+	// The registry serves the tarball under the unscoped name
+	base := tgz.Name[strings.LastIndex(tgz.Name, "/")+1:]
+	version["_id"] = tgz.Name + "@" + tgz.Version
+	version["dist"] = map[string]string{
+		"integrity": tgz.Integrity,
+		"shasum":    tgz.Shasum,
+		"tarball":   registry + tgz.Name + "/-/" + base + "-" + tgz.Version + ".tgz",
+	}
+	doc := packument{
+		ID:          tgz.Name,
+		Name:        tgz.Name,
+		Description: fields.Description,
+		DistTags:    map[string]string{tag: tgz.Version},
+		Versions:    map[string]map[string]any{tgz.Version: version},
+		Access:      access,
+		Attachments: map[string]attachment{
+			tgz.Name + "-" + tgz.Version + ".tgz": {
+				ContentType: "application/octet-stream",
+				Data:        base64.StdEncoding.EncodeToString(tgz.Data),
+				Length:      len(tgz.Data),
+			},
+		},
+	}
+	if opts.DryRun {
+		return tgz, nil
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s@%s: %w", tgz.Name, tgz.Version, err)
+	}
+	url := registry + strings.Replace(tgz.Name, "/", "%2f", 1)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := p.registry.AuthToken(url, tgz.Name); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish %s@%s to %s: %w", tgz.Name, tgz.Version, registry, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return tgz, nil
+	}
+
+	reason := registryError(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		return nil, fmt.Errorf("%w: %s@%s is already in %s: %s", ErrVersionExists, tgz.Name, tgz.Version, registry, reason)
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("failed to publish %s@%s: %s, set an _authToken for %s in .npmrc: %s", tgz.Name, tgz.Version, resp.Status, registry, reason)
+	}
+	return nil, fmt.Errorf("failed to publish %s@%s to %s: %s: %s", tgz.Name, tgz.Version, registry, resp.Status, reason)
+}
+
+// registryError returns the message of an error response, which registries
+// send as {"error": ...} or {"reason": ...}
+func registryError(r io.Reader) string {
+	content, _ := io.ReadAll(io.LimitReader(r, 64*1024))
+	var body struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(content, &body) == nil && (body.Error != "" || body.Reason != "") {
+		return firstOf(body.Reason, body.Error)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package publish
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"npm-packager/npmrc"
+	"npm-packager/pack"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// publishRequest is a PUT the fake registry received
+type publishRequest struct {
+	path          string
+	authorization string
+	doc           packument
+}
+
+func TestPublish(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		npmrc       func(url string) string
+		opts        Options
+		status      int
+		response    string
+		expectError error
+		errorText   string
+		validate    func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest)
+	}{
+		{
+			name:        "PUTs the packument with the tarball attached",
+			packageJSON: `{"name":"lib","version":"1.0.0","description":"A library","dependencies":{"dep":"^1.0.0"}}`,
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Len(t, requests, 1)
+				req := requests[0]
+				assert.Equal(t, "/lib", req.path)
+				assert.Equal(t, "Bearer secret", req.authorization)
+
+				doc := req.doc
+				assert.Equal(t, "lib", doc.ID)
+				assert.Equal(t, "lib", doc.Name)
+				assert.Equal(t, "A library", doc.Description)
+				assert.Equal(t, map[string]string{"latest": "1.0.0"}, doc.DistTags)
+				assert.Empty(t, doc.Access)
+
+				version := doc.Versions["1.0.0"]
+				assert.Equal(t, "lib@1.0.0", version["_id"])
+				assert.Equal(t, map[string]any{"dep": "^1.0.0"}, version["dependencies"])
+				assert.Equal(t, map[string]any{
+					"integrity": tgz.Integrity,
+					"shasum":    tgz.Shasum,
+					"tarball":   url + "/lib/-/lib-1.0.0.tgz",
+				}, version["dist"])
+
+				attachment, ok := doc.Attachments["lib-1.0.0.tgz"]
+				assert.True(t, ok)
+				assert.Equal(t, "application/octet-stream", attachment.ContentType)
+				assert.Equal(t, len(tgz.Data), attachment.Length)
+				data, err := base64.StdEncoding.DecodeString(attachment.Data)
+				assert.NoError(t, err)
+				assert.Equal(t, tgz.Data, data)
+			},
+		},
+		{
+			name:        "Scoped packages go to their escaped URL with the tag and access given",
+			packageJSON: `{"name":"@scope/lib","version":"2.0.0-beta.1"}`,
+			opts:        Options{Tag: "next", Access: "public"},
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Len(t, requests, 1)
+				assert.Equal(t, "/@scope%2flib", requests[0].path)
+				assert.Equal(t, map[string]string{"next": "2.0.0-beta.1"}, requests[0].doc.DistTags)
+				assert.Equal(t, "public", requests[0].doc.Access)
+				assert.Contains(t, requests[0].doc.Attachments, "@scope/lib-2.0.0-beta.1.tgz")
+				assert.Equal(t, url+"/@scope/lib/-/lib-2.0.0-beta.1.tgz", requests[0].doc.Versions["2.0.0-beta.1"]["dist"].(map[string]any)["tarball"])
+			},
+		},
+		{
+			name: "Scoped registries and their tokens are used",
+			npmrc: func(url string) string {
+				return "registry=https://registry.invalid/\n@internal:registry=" + url + "/npm/\n" +
+					"//" + strings.TrimPrefix(url, "http://") + "/npm/:_authToken=scoped\n"
+			},
+			packageJSON: `{"name":"@internal/lib","version":"1.0.0"}`,
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Len(t, requests, 1)
+				assert.Equal(t, "/npm/@internal%2flib", requests[0].path)
+				assert.Equal(t, "Bearer scoped", requests[0].authorization)
+			},
+		},
+		{
+			name: "publishConfig sets the registry and tag",
+			npmrc: func(url string) string {
+				return "registry=https://registry.invalid/\n//" + strings.TrimPrefix(url, "http://") + "/:_authToken=secret\n"
+			},
+			packageJSON: `{"name":"lib","version":"1.0.0","publishConfig":{"registry":"REGISTRY","tag":"beta"}}`,
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Len(t, requests, 1)
+				assert.Equal(t, "/lib", requests[0].path)
+				assert.Equal(t, "Bearer secret", requests[0].authorization)
+				assert.Equal(t, map[string]string{"beta": "1.0.0"}, requests[0].doc.DistTags)
+			},
+		},
+		{
+			name:        "Dry runs send nothing",
+			packageJSON: `{"name":"lib","version":"1.0.0"}`,
+			opts:        Options{DryRun: true},
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Empty(t, requests)
+				assert.Equal(t, "lib", tgz.Name)
+			},
+		},
+		{
+			name:        "Private packages are refused",
+			packageJSON: `{"name":"lib","version":"1.0.0","private":true}`,
+			expectError: ErrPrivate,
+			validate: func(t *testing.T, url string, tgz *pack.Tarball, requests []publishRequest) {
+				assert.Empty(t, requests)
+			},
+		},
+		{
+			name:        "A version already published is a conflict",
+			packageJSON: `{"name":"lib","version":"1.0.0"}`,
+			status:      http.StatusConflict,
+			response:    `{"error":"cannot modify pre-existing version"}`,
+			expectError: ErrVersionExists,
+			errorText:   "cannot modify pre-existing version",
+		},
+		{
+			name:        "Rejected credentials point at .npmrc",
+			packageJSON: `{"name":"lib","version":"1.0.0"}`,
+			status:      http.StatusUnauthorized,
+			response:    `{"error":"unauthorized"}`,
+			errorText:   "set an _authToken",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []publishRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var doc packument
+				assert.Equal(t, http.MethodPut, req.Method)
+				assert.NoError(t, json.NewDecoder(req.Body).Decode(&doc))
+				requests = append(requests, publishRequest{
+					path:          req.URL.EscapedPath(),
+					authorization: req.Header.Get("Authorization"),
+					doc:           doc,
+				})
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+					w.Write([]byte(tc.response))
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"ok":true}`))
+			}))
+			defer server.Close()
+
+			rc := "registry=" + server.URL + "/\n//" + strings.TrimPrefix(server.URL, "http://") + "/:_authToken=secret\n"
+			if tc.npmrc != nil {
+				rc = tc.npmrc(server.URL)
+			}
+			rcPath := filepath.Join(t.TempDir(), ".npmrc")
+			assert.NoError(t, os.WriteFile(rcPath, []byte(rc), 0644))
+			registry, err := npmrc.Load("https://registry.npmjs.org/", rcPath)
+			assert.NoError(t, err)
+
+			dir := t.TempDir()
+			packageJSON := strings.Replace(tc.packageJSON, "REGISTRY", server.URL, 1)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(packageJSON), 0644))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.js"), []byte("module.exports = 1\n"), 0644))
+
+			tgz, err := NewPublisher(registry).Publish(dir, tc.opts)
+			switch {
+			case tc.expectError != nil:
+				assert.ErrorIs(t, err, tc.expectError)
+			case tc.errorText != "":
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+			if tc.errorText != "" {
+				assert.ErrorContains(t, err, tc.errorText)
+			}
+			if tc.validate != nil {
+				tc.validate(t, server.URL, tgz, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package store
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"npm-packager/packagecopy"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps the files of extracted packages once, by the hash of their
+// content, so every project installs them as hard links to the same data.
+//
+//	files/<2 hex>/<sha512 hex>[-exec]   the content, executables apart
+//	index/<name@version>.json           the files of each package
+//	projects/<sha256 of lock path>      the lock files of projects installed from it
+type Store struct {
+	dir    string
+	copier *packagecopy.PackageCopy
+}
+
+// File is a file of a package: its content hash and whether it is executable
+type File struct {
+	Hash       string `json:"hash"`
+	Executable bool   `json:"executable,omitempty"`
+	Size       int64  `json:"size"`
+}
+
+// Index lists the files of a package by path relative to its directory,
+// with forward slashes
+type Index struct {
+	Files map[string]File `json:"files"`
+}
+
+// PruneResult is what Prune removed
+type PruneResult struct {
+	Packages int
+	Files    int
+	Bytes    int64
+}
+
+func New(dir string, copier *packagecopy.PackageCopy) *Store {
+	return &Store{dir: dir, copier: copier}
+}
+
+// contentPath is where the content of file is kept
+func (s *Store) contentPath(file File) string {
+	name := file.Hash
+	if file.Executable {
+		name += "-exec"
+	}
+	return filepath.Join(s.dir, "files", file.Hash[:2], name)
+}
+
+// indexPath is the index of key, name@version; the / of a scope is kept
+// out of the file name
+func (s *Store) indexPath(key string) string {
+	return filepath.Join(s.dir, "index", strings.ReplaceAll(key, "/", "+")+".json")
+}
+
+// Import adds the files of the package extracted in pkgDir to the store
+// and records them as the index of key, name@version. Files in pkgDir are
+// replaced by links to what the store already holds, so the package cache
+// does not keep a second copy either.
+func (s *Store) Import(key, pkgDir string) (*Index, error) {
+	index := &Index{Files: make(map[string]File)}
+	err := filepath.WalkDir(pkgDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(filePath)
+		if err != nil {
+			return err
+		}
+		file := File{Hash: hash, Executable: info.Mode()&0111 != 0, Size: info.Size()}
+		if err := s.add(filePath, file); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pkgDir, filePath)
+		if err != nil {
+			return err
+		}
+		index.Files[filepath.ToSlash(rel)] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s into the store: %w", key, err)
+	}
+
+	if err := writeAtomic(s.indexPath(key), index); err != nil {
+		return nil, fmt.Errorf("failed to write the store index of %s: %w", key, err)
+	}
+	return index, nil
+}
+
+// add puts the content of filePath in the store unless it is there already,
+// in which case filePath becomes a link to it
+func (s *Store) add(filePath string, file File) error {
+	target := s.contentPath(file)
+	stored, err := os.Stat(target)
+	if err == nil {
+		current, err := os.Stat(filePath)
+		if err != nil || os.SameFile(stored, current) {
+			return err
+		}
+		return s.replaceWithLink(target, filePath)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Through a temporary name, so no one links a half written file
+	return s.replaceWithLink(filePath, target)
+}
+
+// replaceWithLink makes dst the content of src, atomically
+func (s *Store) replaceWithLink(src, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := s.copier.LinkFile(src, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Index returns the files recorded for key; the error satisfies
+// os.IsNotExist when the package was never imported
+func (s *Store) Index(key string) (*Index, error) {
+	content, err := os.ReadFile(s.indexPath(key))
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the store index of %s: %w", key, err)
+	}
+	return &index, nil
+}
+
+// Link installs the package key at dst from the store: every file a hard
+// link to its content, or a reflink or copy where linking is not possible.
+// A package not imported yet is imported from pkgDir first.
+func (s *Store) Link(key, pkgDir, dst string) error {
+	return s.install(key, pkgDir, dst, s.copier.LinkFile)
+}
+
+// Copy installs the package key at dst like Link, but with files of its
+// own, for packages that may change them, such as by building in place
+func (s *Store) Copy(key, pkgDir, dst string) error {
+	return s.install(key, pkgDir, dst, s.copier.CloneFile)
+}
+
+func (s *Store) install(key, pkgDir, dst string, place func(src, dst string) error) error {
+	index, err := s.Index(key)
+	if err == nil && !s.complete(index) {
+		// Content pruned from under its index
+		err = os.ErrNotExist
+	}
+	if os.IsNotExist(err) {
+		index, err = s.Import(key, pkgDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(index.Files))
+	for rel := range index.Files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	for _, rel := range paths {
+		file := index.Files[rel]
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", target, err)
+		}
+		if err := place(s.contentPath(file), target); err != nil {
+			return fmt.Errorf("failed to install %s from the store: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// complete reports whether the store holds the content of every file of index
+func (s *Store) complete(index *Index) bool {
+	for _, file := range index.Files {
+		if _, err := os.Stat(s.contentPath(file)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// projectPath is where the registration of the project with lockPath is kept
+func (s *Store) projectPath(lockPath string) string {
+	sum := sha256.Sum256([]byte(lockPath))
+	return filepath.Join(s.dir, "projects", hex.EncodeToString(sum[:]))
+}
+
+// Register records that the project whose lock file is lockPath installs
+// from the store, so Prune keeps what it uses
+func (s *Store) Register(lockPath string) error {
+	lockPath, err := filepath.Abs(lockPath)
+	if err != nil {
+		return err
+	}
+	projectPath := s.projectPath(lockPath)
+	if err := os.MkdirAll(filepath.Dir(projectPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(projectPath, []byte(lockPath), 0644)
+}
+
+// Projects returns the lock files of the registered projects that still
+// exist, sorted; projects whose lock file is gone are forgotten
+func (s *Store) Projects() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "projects"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var projects []string
+	for _, entry := range entries {
+		projectPath := filepath.Join(s.dir, "projects", entry.Name())
+		lockPath, err := os.ReadFile(projectPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(string(lockPath)); os.IsNotExist(err) {
+			if err := os.Remove(projectPath); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		projects = append(projects, string(lockPath))
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// Prune removes the index of every package not in keep, by name@version,
+// then the content no remaining index refers to
+func (s *Store) Prune(keep map[string]bool) (PruneResult, error) {
+	var result PruneResult
+
+	indexDir := filepath.Join(s.dir, "index")
+	entries, err := os.ReadDir(indexDir)
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+	used := make(map[string]bool)
+	for _, entry := range entries {
+		key := strings.ReplaceAll(strings.TrimSuffix(entry.Name(), ".json"), "+", "/")
+		if !keep[key] {
+			if err := os.Remove(filepath.Join(indexDir, entry.Name())); err != nil {
+				return result, err
+			}
+			result.Packages++
+			continue
+		}
+		index, err := s.Index(key)
+		if err != nil {
+			return result, err
+		}
+		for _, file := range index.Files {
+			used[s.contentPath(file)] = true
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(s.dir, "files"), func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || used[filePath] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		result.Files++
+		result.Bytes += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeAtomic writes v as JSON to filePath through a temporary file
+func writeAtomic(filePath string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
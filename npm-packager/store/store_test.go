@@ -0,0 +1,195 @@
+package store
+
+import (
+	"npm-packager/packagecopy"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePackage extracts a package with files, by relative path, in a new
+// directory and returns it
+func writePackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(rel))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		mode := os.FileMode(0644)
+		if filepath.Ext(rel) == ".sh" {
+			mode = 0755
+		}
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), mode))
+	}
+	return dir
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	infoA, err := os.Stat(a)
+	assert.NoError(t, err)
+	infoB, err := os.Stat(b)
+	assert.NoError(t, err)
+	return os.SameFile(infoA, infoB)
+}
+
+func TestStore_Import(t *testing.T) {
+	s := New(t.TempDir(), packagecopy.NewPackageCopy())
+
+	first := writePackage(t, map[string]string{"index.js": "shared", "lib/a.js": "a", "bin/run.sh": "shared"})
+	second := writePackage(t, map[string]string{"index.js": "shared"})
+
+	index, err := s.Import("first@1.0.0", first)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bin/run.sh", "index.js", "lib/a.js"}, sortedPaths(index))
+	assert.Equal(t, index.Files["index.js"].Hash, index.Files["bin/run.sh"].Hash)
+	assert.True(t, index.Files["bin/run.sh"].Executable)
+	assert.False(t, index.Files["index.js"].Executable)
+	assert.NotEqual(t, s.contentPath(index.Files["index.js"]), s.contentPath(index.Files["bin/run.sh"]))
+
+	_, err = s.Import("second@1.0.0", second)
+	assert.NoError(t, err)
+
+	// Both extracted copies became links to the one in the store
+	assert.True(t, sameFile(t, filepath.Join(first, "index.js"), filepath.Join(second, "index.js")))
+	assert.True(t, sameFile(t, filepath.Join(first, "index.js"), s.contentPath(index.Files["index.js"])))
+
+	recorded, err := s.Index("first@1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, index, recorded)
+
+	_, err = s.Index("@scope/missing@1.0.0")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStore_Install(t *testing.T) {
+	testCases := []struct {
+		name      string
+		setupFunc func(t *testing.T, s *Store, pkgDir, dst string)
+		copy      bool
+		linked    bool
+	}{
+		{
+			name:   "links imported files",
+			linked: true,
+		},
+		{
+			name: "imports a package the store does not have yet",
+			setupFunc: func(t *testing.T, s *Store, pkgDir, dst string) {
+				assert.NoError(t, os.Remove(s.indexPath("pkg@1.0.0")))
+			},
+			linked: true,
+		},
+		{
+			name: "imports again content pruned from under its index",
+			setupFunc: func(t *testing.T, s *Store, pkgDir, dst string) {
+				assert.NoError(t, os.RemoveAll(filepath.Join(s.dir, "files")))
+			},
+			linked: true,
+		},
+		{
+			name: "replaces installed files without writing through them",
+			setupFunc: func(t *testing.T, s *Store, pkgDir, dst string) {
+				assert.NoError(t, os.MkdirAll(filepath.Join(dst, "lib"), 0755))
+				assert.NoError(t, os.Link(filepath.Join(pkgDir, "lib", "a.js"), filepath.Join(dst, "index.js")))
+			},
+			linked: true,
+		},
+		{
+			name:   "copies for packages that change their files",
+			copy:   true,
+			linked: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(t.TempDir(), packagecopy.NewPackageCopy())
+			pkgDir := writePackage(t, map[string]string{"index.js": "index", "lib/a.js": "a"})
+			dst := filepath.Join(t.TempDir(), "node_modules", "pkg")
+
+			_, err := s.Import("pkg@1.0.0", pkgDir)
+			assert.NoError(t, err)
+			if tc.setupFunc != nil {
+				tc.setupFunc(t, s, pkgDir, dst)
+			}
+
+			if tc.copy {
+				err = s.Copy("pkg@1.0.0", pkgDir, dst)
+			} else {
+				err = s.Link("pkg@1.0.0", pkgDir, dst)
+			}
+			assert.NoError(t, err)
+
+			for rel, expected := range map[string]string{"index.js": "index", "lib/a.js": "a"} {
+				installed := filepath.Join(dst, filepath.FromSlash(rel))
+				content, err := os.ReadFile(installed)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(content))
+
+				original, err := os.ReadFile(filepath.Join(pkgDir, filepath.FromSlash(rel)))
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(original))
+
+				assert.Equal(t, tc.linked, sameFile(t, installed, filepath.Join(pkgDir, filepath.FromSlash(rel))))
+			}
+		})
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, packagecopy.NewPackageCopy())
+
+	kept, err := s.Import("kept@1.0.0", writePackage(t, map[string]string{"index.js": "shared", "kept.js": "kept"}))
+	assert.NoError(t, err)
+	gone, err := s.Import("@scope/gone@1.0.0", writePackage(t, map[string]string{"index.js": "shared", "gone.js": "gone"}))
+	assert.NoError(t, err)
+
+	result, err := s.Prune(map[string]bool{"kept@1.0.0": true})
+	assert.NoError(t, err)
+	assert.Equal(t, PruneResult{Packages: 1, Files: 1, Bytes: 4}, result)
+
+	for _, file := range kept.Files {
+		assert.FileExists(t, s.contentPath(file))
+	}
+	assert.NoFileExists(t, s.contentPath(gone.Files["gone.js"]))
+	_, err = s.Index("@scope/gone@1.0.0")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStore_Projects(t *testing.T) {
+	s := New(t.TempDir(), packagecopy.NewPackageCopy())
+
+	projectDir := t.TempDir()
+	kept := filepath.Join(projectDir, "kept", "go-package-lock.json")
+	gone := filepath.Join(projectDir, "gone", "go-package-lock.json")
+	for _, lockPath := range []string{kept, gone} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(lockPath), 0755))
+		assert.NoError(t, os.WriteFile(lockPath, []byte("{}"), 0644))
+		assert.NoError(t, s.Register(lockPath))
+	}
+	// Registering twice records the project once
+	assert.NoError(t, s.Register(kept))
+	assert.NoError(t, os.RemoveAll(filepath.Dir(gone)))
+
+	projects, err := s.Projects()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{kept}, projects)
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "projects"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "a project whose lock file is gone is forgotten")
+}
+
+func sortedPaths(index *Index) []string {
+	paths := make([]string, 0, len(index.Files))
+	for rel := range index.Files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
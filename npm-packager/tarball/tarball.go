@@ -1,25 +1,90 @@
 package tarball
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"npm-packager/integrity"
+	"npm-packager/npmrc"
 	"npm-packager/utils"
 	"os"
-	"path"
 	"path/filepath"
+	"strings"
 )
 
 type Tarball struct {
 	TarballPath string
+	Downloader  *utils.Downloader
+	registry    *npmrc.Config
 }
 
-func NewTarball() *Tarball {
+func NewTarball(registry *npmrc.Config) *Tarball {
 	tarballPath := os.TempDir()
-	return &Tarball{TarballPath: tarballPath}
+	return &Tarball{
+		TarballPath: tarballPath,
+		Downloader:  utils.NewDownloader(utils.DefaultConcurrency),
+		registry:    registry,
+	}
 }
 
-func (d *Tarball) Download(url string) error {
-	filename := path.Base(url)
-	filePath := filepath.Join(d.TarballPath, filename)
+// File is where the tarball of version of pkg is kept. It is named after
+// the full package name, with the slash of a scope escaped, so packages of
+// different scopes never share a file or a partial download. Without a
+// version, as for a tarball URL, a hash of url stands in for it.
+func (d *Tarball) File(url, pkg, version string) string {
+	if version == "" {
+		sum := sha256.Sum256([]byte(url))
+		version = hex.EncodeToString(sum[:6])
+	}
+	return filepath.Join(d.TarballPath, strings.ReplaceAll(pkg, "/", "%2f")+"-"+version+".tgz")
+}
+
+// Cached returns the integrity of the tarball of version of pkg downloaded
+// earlier, if there is one and it matches expected. Without an expected
+// integrity nothing tells a cached tarball apart from another with the same
+// name, so none is used.
+func (d *Tarball) Cached(url, pkg, version, expected string) (string, bool) {
+	if expected == "" {
+		return "", false
+	}
+	filePath := d.File(url, pkg, version)
+	actual, err := integrity.ComputeFile(filePath)
+	if err != nil {
+		return "", false
+	}
+	if integrity.Check(actual, expected) != nil {
+		return "", false
+	}
+	return actual, true
+}
+
+// Download fetches the tarball of version of pkg into File, with the auth
+// token .npmrc has for its registry, and returns the integrity of what
+// arrived. When expected is set, a tarball that does not match it is
+// deleted and an error returned.
+func (d *Tarball) Download(url, pkg, version, expected string) (string, error) {
+	filePath := d.File(url, pkg, version)
+
+	// Tarballs never change once published, so a cut off download is resumed
+	_, _, err := d.Downloader.Download(utils.Request{
+		URL:       url,
+		Filename:  filePath,
+		AuthToken: d.registry.AuthToken(url, pkg),
+		Resume:    true,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	_, _, err := utils.DownloadFile(url, filePath, "")
-	return err
+	actual, err := integrity.ComputeFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	if expected != "" {
+		if err := integrity.Check(actual, expected); err != nil {
+			os.Remove(filePath)
+			return "", fmt.Errorf("tarball of %s from %s: %w", pkg, url, err)
+		}
+	}
+	return actual, nil
 }
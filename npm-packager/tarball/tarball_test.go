@@ -1,8 +1,17 @@
 package tarball
 
 import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"npm-packager/integrity"
+	"npm-packager/npmrc"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,12 +20,16 @@ import (
 func TestDownloadTarball_Download(t *testing.T) {
 	testCases := []struct {
 		name        string
+		pkg         string
+		version     string
 		setupFunc   func(t *testing.T) string
 		expectError bool
 		validate    func(t *testing.T, tb *Tarball, url string, err error)
 	}{
 		{
-			name: "Download express tarball successfully",
+			name:    "Download express tarball successfully",
+			pkg:     "express",
+			version: "4.18.2",
 			setupFunc: func(t *testing.T) string {
 				url := "https://registry.npmjs.org/express/-/express-4.18.2.tgz"
 				return url
@@ -32,7 +45,9 @@ func TestDownloadTarball_Download(t *testing.T) {
 			},
 		},
 		{
-			name: "Error with invalid tarball URL",
+			name:    "Error with invalid tarball URL",
+			pkg:     "invalid-package-12345678",
+			version: "1.0.0",
 			setupFunc: func(t *testing.T) string {
 				url := "https://registry.npmjs.org/invalid-package-12345678/-/invalid-package-12345678-1.0.0.tgz"
 				return url
@@ -54,8 +69,8 @@ func TestDownloadTarball_Download(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			url := tc.setupFunc(t)
-			tarball := NewTarball()
-			err := tarball.Download(url)
+			tarball := NewTarball(npmrc.New("https://registry.npmjs.org/"))
+			_, err := tarball.Download(url, tc.pkg, tc.version, "")
 
 			if tc.expectError {
 				assert.Error(t, err, "Expected an error")
@@ -67,3 +82,143 @@ func TestDownloadTarball_Download(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadTarball_RegistryAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("tarball"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		npmrc       string
+		expectError bool
+	}{
+		{
+			name:  "Sends the token of the package's registry",
+			npmrc: "@company:registry=" + server.URL + "/\n//" + strings.TrimPrefix(server.URL, "http://") + "/:_authToken=secret\n",
+		},
+		{
+			name:        "Fails without a token",
+			npmrc:       "@company:registry=" + server.URL + "/\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".npmrc")
+			assert.NoError(t, os.WriteFile(path, []byte(tc.npmrc), 0644))
+			registry, err := npmrc.Load("https://registry.npmjs.org/", path)
+			assert.NoError(t, err)
+
+			tarball := NewTarball(registry)
+			tarball.TarballPath = t.TempDir()
+			_, err = tarball.Download(server.URL+"/@company/widget/-/widget-1.0.0.tgz", "@company/widget", "1.0.0", "")
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.FileExists(t, filepath.Join(tarball.TarballPath, "@company%2fwidget-1.0.0.tgz"))
+		})
+	}
+}
+
+func TestDownloadTarball_Integrity(t *testing.T) {
+	content := []byte("tarball")
+	sha512Sum := sha512.Sum512(content)
+	sha1Sum := sha1.Sum(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "No expected integrity",
+			expected: "",
+		},
+		{
+			name:     "Matching sha512",
+			expected: "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:]),
+		},
+		{
+			name:     "Matching sha1 from a shasum",
+			expected: integrity.FromShasum(hex.EncodeToString(sha1Sum[:])),
+		},
+		{
+			name:        "Mismatch",
+			expected:    "sha512-" + base64.StdEncoding.EncodeToString(make([]byte, sha512.Size)),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tarball := NewTarball(npmrc.New(server.URL))
+			tarball.TarballPath = t.TempDir()
+			filePath := filepath.Join(tarball.TarballPath, "widget-1.0.0.tgz")
+
+			actual, err := tarball.Download(server.URL+"/widget/-/widget-1.0.0.tgz", "widget", "1.0.0", tc.expected)
+
+			if tc.expectError {
+				assert.ErrorIs(t, err, integrity.ErrMismatch)
+				assert.NoFileExists(t, filePath, "A tarball that fails verification should be removed")
+				return
+			}
+			assert.NoError(t, err)
+			assert.FileExists(t, filePath)
+			assert.NoError(t, integrity.Check(actual, "sha512-"+base64.StdEncoding.EncodeToString(sha512Sum[:])))
+		})
+	}
+}
+
+func TestTarballFile(t *testing.T) {
+	tarball := NewTarball(npmrc.New("https://registry.npmjs.org/"))
+	tarball.TarballPath = "cache"
+
+	testCases := []struct {
+		name     string
+		url      string
+		pkg      string
+		version  string
+		expected string
+	}{
+		{
+			name:     "Unscoped package",
+			url:      "https://registry.npmjs.org/widget/-/widget-1.0.0.tgz",
+			pkg:      "widget",
+			version:  "1.0.0",
+			expected: "widget-1.0.0.tgz",
+		},
+		{
+			name:     "Scoped package of the same name and version",
+			url:      "https://registry.npmjs.org/@company/widget/-/widget-1.0.0.tgz",
+			pkg:      "@company/widget",
+			version:  "1.0.0",
+			expected: "@company%2fwidget-1.0.0.tgz",
+		},
+		{
+			name:     "Tarball URL without a known version",
+			url:      "https://example.com/widget.tgz",
+			pkg:      "widget",
+			expected: "widget-790bdac5f9aa.tgz",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, filepath.Join("cache", tc.expected), tarball.File(tc.url, tc.pkg, tc.version))
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is how many downloads a Downloader runs at once
+// unless told otherwise
+const DefaultConcurrency = 16
+
+// Downloader fetches files over HTTP, a bounded number at a time. Failed
+// attempts (network errors, 429 and 5xx responses) are retried with
+// exponential backoff, and a download can resume from what an earlier
+// attempt wrote with an HTTP range request.
+type Downloader struct {
+	Client *http.Client
+	// Retries after the first attempt
+	Retries int
+	// Wait before the first retry, doubling for each one after, up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Progress, if set, counts what is downloaded
+	Progress *Progress
+
+	slots chan struct{}
+}
+
+// Request is a file to download. Etag makes the request conditional: a
+// 304 leaves the file as it is. With Resume, bytes a failed attempt wrote
+// are kept and the rest is asked for with a Range header.
+type Request struct {
+	URL       string
+	Filename  string
+	Etag      string
+	AuthToken string
+	Resume    bool
+}
+
+// errRetry marks a failed attempt worth trying again
+type errRetry struct {
+	err error
+}
+
+func (e *errRetry) Error() string { return e.err.Error() }
+func (e *errRetry) Unwrap() error { return e.err }
+
+// NewDownloader returns a Downloader running up to concurrency downloads
+// at once, retrying each up to 4 times
+func NewDownloader(concurrency int) *Downloader {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &Downloader{
+		Client:     &http.Client{Transport: transport, Timeout: 10 * time.Minute},
+		Retries:    4,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+		slots:      make(chan struct{}, concurrency),
+	}
+}
+
+// Download fetches req.URL into req.Filename and returns the ETag and
+// status code of the last response. The file only appears once it is
+// complete.
+func (d *Downloader) Download(req Request) (string, int, error) {
+	partial := req.Filename + ".partial"
+	if err := os.MkdirAll(filepath.Dir(req.Filename), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create directory structure: %w", err)
+	}
+	if !req.Resume {
+		os.Remove(partial)
+	}
+
+	wait := d.Backoff
+	for attempt := 0; ; attempt++ {
+		d.slots <- struct{}{}
+		etag, statusCode, err := d.attempt(req, partial)
+		<-d.slots
+
+		var retry *errRetry
+		if err == nil || !errors.As(err, &retry) || attempt >= d.Retries {
+			if err != nil && !req.Resume {
+				os.Remove(partial)
+			}
+			if err == nil && d.Progress != nil && statusCode != http.StatusNotModified {
+				d.Progress.fileDone()
+			}
+			return etag, statusCode, err
+		}
+
+		time.Sleep(wait)
+		wait *= 2
+		if d.MaxBackoff > 0 && wait > d.MaxBackoff {
+			wait = d.MaxBackoff
+		}
+	}
+}
+
+// attempt makes one request, continuing partial when req.Resume allows
+func (d *Downloader) attempt(req Request, partial string) (string, int, error) {
+	httpReq, err := http.NewRequest("GET", req.URL, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if req.Etag != "" {
+		httpReq.Header.Set("If-None-Match", req.Etag)
+	}
+	if req.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.AuthToken)
+	}
+
+	var offset int64
+	if req.Resume {
+		if info, err := os.Stat(partial); err == nil && info.Size() > 0 {
+			offset = info.Size()
+			httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+
+	resp, err := d.Client.Do(httpReq)
+	if err != nil {
+		return "", 0, &errRetry{fmt.Errorf("failed to fetch URL: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return req.Etag, resp.StatusCode, nil
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && rangeStart(resp) == offset:
+		flags = os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// A server ignoring the range sends the whole file again
+	case resp.StatusCode == http.StatusPartialContent:
+		// Not the range asked for: start over
+		os.Remove(partial)
+		return "", resp.StatusCode, &errRetry{fmt.Errorf("unexpected range from %s", req.URL)}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		os.Remove(partial)
+		return "", resp.StatusCode, &errRetry{fmt.Errorf("HTTP error: %s, %d %s", req.URL, resp.StatusCode, resp.Status)}
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return "", resp.StatusCode, &errRetry{fmt.Errorf("HTTP error: %s, %d %s", req.URL, resp.StatusCode, resp.Status)}
+	default:
+		return "", resp.StatusCode, fmt.Errorf("HTTP error: %s, %d %s", req.URL, resp.StatusCode, resp.Status)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return "", resp.StatusCode, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	var dst io.Writer = file
+	if d.Progress != nil {
+		dst = io.MultiWriter(file, progressWriter{d.Progress})
+	}
+	_, copyErr := io.Copy(dst, resp.Body)
+	closeErr := file.Close()
+	if copyErr != nil {
+		return "", resp.StatusCode, &errRetry{fmt.Errorf("failed to write file: %w", copyErr)}
+	}
+	if closeErr != nil {
+		return "", resp.StatusCode, fmt.Errorf("failed to write file: %w", closeErr)
+	}
+
+	if err := os.Rename(partial, req.Filename); err != nil {
+		return "", resp.StatusCode, fmt.Errorf("failed to write file: %w", err)
+	}
+	return resp.Header.Get("ETag"), resp.StatusCode, nil
+}
+
+// rangeStart is where the Content-Range of a 206 response starts, or -1
+func rangeStart(resp *http.Response) int64 {
+	// bytes 100-199/200
+	spec, found := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !found {
+		return -1
+	}
+	start, _, _ := strings.Cut(spec, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Progress counts the files and bytes downloaded and reports them on W,
+// at most once per Interval
+type Progress struct {
+	W        io.Writer
+	Interval time.Duration
+
+	mu       sync.Mutex
+	files    int
+	bytes    int64
+	reported time.Time
+}
+
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{W: w, Interval: time.Second, reported: time.Now()}
+}
+
+// Totals returns the files and bytes downloaded so far
+func (p *Progress) Totals() (int, int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.files, p.bytes
+}
+
+func (p *Progress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes += n
+	if time.Since(p.reported) >= p.Interval {
+		p.reported = time.Now()
+		fmt.Fprintf(p.W, "Downloading... %d files, %s\n", p.files, formatBytes(p.bytes))
+	}
+}
+
+func (p *Progress) fileDone() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files++
+}
+
+// Finish reports the totals, if anything was downloaded
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.files > 0 {
+		fmt.Fprintf(p.W, "Downloaded %d files, %s\n", p.files, formatBytes(p.bytes))
+	}
+}
+
+type progressWriter struct {
+	p *Progress
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	w.p.add(int64(len(b)))
+	return len(b), nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
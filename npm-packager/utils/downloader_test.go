@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var downloaderTestContent = bytes.Repeat([]byte("0123456789"), 100)
+
+func TestDownloader_Download(t *testing.T) {
+	testCases := []struct {
+		name        string
+		handler     func(attempt int, w http.ResponseWriter, r *http.Request)
+		resume      bool
+		expectError bool
+		validate    func(t *testing.T, filename string, attempts int)
+	}{
+		{
+			name: "retries a server error until it succeeds",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write(downloaderTestContent)
+			},
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 3, attempts)
+				content, err := os.ReadFile(filename)
+				assert.NoError(t, err)
+				assert.Equal(t, downloaderTestContent, content)
+			},
+		},
+		{
+			name: "gives up after its retries",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			expectError: true,
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 3, attempts)
+				assert.NoFileExists(t, filename)
+				assert.NoFileExists(t, filename+".partial")
+			},
+		},
+		{
+			name: "does not retry a client error",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectError: true,
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 1, attempts)
+				assert.NoFileExists(t, filename)
+			},
+		},
+		{
+			name:   "resumes a cut off body with a range request",
+			resume: true,
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				if attempt == 1 {
+					w.Header().Set("Content-Length", strconv.Itoa(len(downloaderTestContent)))
+					w.Write(downloaderTestContent[:400])
+					return
+				}
+				if r.Header.Get("Range") != "bytes=400-" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 400-%d/%d", len(downloaderTestContent)-1, len(downloaderTestContent)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(downloaderTestContent[400:])
+			},
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 2, attempts)
+				content, err := os.ReadFile(filename)
+				assert.NoError(t, err)
+				assert.Equal(t, downloaderTestContent, content)
+				assert.NoFileExists(t, filename+".partial")
+			},
+		},
+		{
+			name:   "starts over when the server ignores the range",
+			resume: true,
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(downloaderTestContent)))
+				if attempt == 1 {
+					w.Write(downloaderTestContent[:400])
+					return
+				}
+				w.Write(downloaderTestContent)
+			},
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 2, attempts)
+				content, err := os.ReadFile(filename)
+				assert.NoError(t, err)
+				assert.Equal(t, downloaderTestContent, content)
+			},
+		},
+		{
+			name: "without resume a cut off body is fetched again whole",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Range"))
+				w.Header().Set("Content-Length", strconv.Itoa(len(downloaderTestContent)))
+				if attempt == 1 {
+					w.Write(downloaderTestContent[:400])
+					return
+				}
+				w.Write(downloaderTestContent)
+			},
+			validate: func(t *testing.T, filename string, attempts int) {
+				assert.Equal(t, 2, attempts)
+				content, err := os.ReadFile(filename)
+				assert.NoError(t, err)
+				assert.Equal(t, downloaderTestContent, content)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handler(int(attempts.Add(1)), w, r)
+			}))
+			defer server.Close()
+
+			d := NewDownloader(1)
+			d.Retries = 2
+			d.Backoff = time.Millisecond
+			filename := filepath.Join(t.TempDir(), "file.tgz")
+
+			_, _, err := d.Download(Request{URL: server.URL, Filename: filename, Resume: tc.resume})
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.validate(t, filename, int(attempts.Load()))
+		})
+	}
+}
+
+func TestDownloader_Concurrency(t *testing.T) {
+	var running, most atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write(downloaderTestContent)
+	}))
+	defer server.Close()
+
+	d := NewDownloader(3)
+	var out bytes.Buffer
+	d.Progress = NewProgress(&out)
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := d.Download(Request{URL: server.URL, Filename: filepath.Join(dir, strconv.Itoa(i))})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), most.Load())
+
+	files, size := d.Progress.Totals()
+	assert.Equal(t, 10, files)
+	assert.Equal(t, int64(10*len(downloaderTestContent)), size)
+	d.Progress.Finish()
+	assert.Equal(t, "Downloaded 10 files, 9.8 kB\n", out.String())
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 kB", formatBytes(1536))
+	assert.Equal(t, "2.0 MB", formatBytes(2<<20))
+}
@@ -11,6 +11,14 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// downloadOnce fetches url into filename once, without retries; etag
+// makes the request conditional
+func downloadOnce(url, filename, etag, authToken string) (string, int, error) {
+	d := NewDownloader(1)
+	d.Retries = 0
+	return d.Download(Request{URL: url, Filename: filename, Etag: etag, AuthToken: authToken})
+}
+
 func TestDownloadFile(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -206,7 +214,7 @@ func TestDownloadFile(t *testing.T) {
 				return
 			}
 
-			returnedEtag, statusCode, err := DownloadFile(url, filename, etag)
+			returnedEtag, statusCode, err := downloadOnce(url, filename, etag, "")
 
 			if tc.expectError {
 				assert.Error(t, err)
@@ -457,3 +465,37 @@ func TestFolderExists(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadFile_AuthToken(t *testing.T) {
+	testCases := []struct {
+		name      string
+		authToken string
+		expected  string
+	}{
+		{
+			name:      "Sends bearer token",
+			authToken: "secret",
+			expected:  "Bearer secret",
+		},
+		{
+			name:      "No header without token",
+			authToken: "",
+			expected:  "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tc.expected, r.Header.Get("Authorization"))
+				w.Write([]byte("content"))
+			}))
+			defer server.Close()
+
+			filename := filepath.Join(t.TempDir(), "test.json")
+			_, statusCode, err := downloadOnce(server.URL, filename, "", tc.authToken)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, statusCode)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Protocol prefixes a range that must be satisfied by a workspace,
+// e.g. workspace:*, workspace:^ or workspace:^1.2.0
+const Protocol = "workspace:"
+
+// Package is a workspace package found under the project root. Dir is
+// relative to the root, with forward slashes: packages/utils.
+type Package struct {
+	Name            string
+	Version         string
+	Dir             string
+	Dependencies    map[string]string
+	DevDependencies map[string]string
+	Scripts         map[string]string
+}
+
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Scripts         map[string]string `json:"scripts"`
+}
+
+// Discover finds the workspace packages the patterns of the root's
+// workspaces field match: directories holding a package.json. Patterns
+// are globs relative to rootDir; ** matches any number of directories and
+// a leading ! excludes what it matches. Packages come back sorted by Dir.
+func Discover(rootDir string, patterns []string) ([]Package, error) {
+	dirs := make(map[string]bool)
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		matches, err := match(rootDir, strings.TrimPrefix(pattern, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+		for _, dir := range matches {
+			if exclude {
+				delete(dirs, dir)
+			} else {
+				dirs[dir] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+
+	packages := make([]Package, 0, len(sorted))
+	byName := make(map[string]string)
+	for _, dir := range sorted {
+		pkg, err := read(rootDir, dir)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := byName[pkg.Name]; ok {
+			return nil, fmt.Errorf("workspaces %s and %s are both named %s", other, dir, pkg.Name)
+		}
+		byName[pkg.Name] = dir
+		packages = append(packages, *pkg)
+	}
+	return packages, nil
+}
+
+// match returns the directories under rootDir matching pattern that hold
+// a package.json, relative to rootDir
+func match(rootDir, pattern string) ([]string, error) {
+	pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(pattern), "./"))
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	// Only the part before the first wildcard needs walking
+	base := pattern
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		base = path.Dir(pattern[:i+1])
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.Join(rootDir, filepath.FromSlash(base)), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".") && p != filepath.Join(rootDir, filepath.FromSlash(base)) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(rootDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchGlob(pattern, rel) {
+			if _, err := os.Stat(filepath.Join(p, "package.json")); err == nil {
+				matches = append(matches, rel)
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchGlob matches a slash separated path against a pattern where ** spans
+// any number of path segments, including none
+func matchGlob(pattern, name string) bool {
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+
+	var matchParts func(p, n []string) bool
+	matchParts = func(p, n []string) bool {
+		if len(p) == 0 {
+			return len(n) == 0
+		}
+		if p[0] == "**" {
+			for i := 0; i <= len(n); i++ {
+				if matchParts(p[1:], n[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(n) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(p[0], n[0]); !ok {
+			return false
+		}
+		return matchParts(p[1:], n[1:])
+	}
+	return matchParts(patternParts, nameParts)
+}
+
+func read(rootDir, dir string) (*Package, error) {
+	filePath := filepath.Join(rootDir, filepath.FromSlash(dir), "package.json")
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	var pj packageJSON
+	if err := json.Unmarshal(content, &pj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from file %s: %w", filePath, err)
+	}
+	if pj.Name == "" {
+		return nil, fmt.Errorf("workspace %s has no name in its package.json", dir)
+	}
+
+	return &Package{
+		Name:            pj.Name,
+		Version:         pj.Version,
+		Dir:             dir,
+		Dependencies:    pj.Dependencies,
+		DevDependencies: pj.DevDependencies,
+		Scripts:         pj.Scripts,
+	}, nil
+}
+
+// Find returns the workspace named name, or whose directory is name
+func Find(packages []Package, name string) (Package, bool) {
+	dir := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	for _, pkg := range packages {
+		if pkg.Name == name || pkg.Dir == dir {
+			return pkg, true
+		}
+	}
+	return Package{}, false
+}
+
+// Range turns a workspace: spec into the range the workspace's version has
+// to satisfy: * and an empty range accept any version, ^ and ~ alone stand
+// for that operator on the workspace's own version
+func Range(spec string, pkg Package) string {
+	r := strings.TrimPrefix(spec, Protocol)
+	switch r {
+	case "", "*":
+		return "*"
+	case "^", "~":
+		return r + pkg.Version
+	}
+	return r
+}
+
+// IsProtocol reports whether spec uses the workspace: protocol
+func IsProtocol(spec string) bool {
+	return strings.HasPrefix(spec, Protocol)
+}
@@ -0,0 +1,201 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePackage writes a package.json to dir under root
+func writePackage(t *testing.T, root, dir, content string) {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(dir))
+	assert.NoError(t, os.MkdirAll(full, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(full, "package.json"), []byte(content), 0644))
+}
+
+func TestDiscover(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setupFunc   func(t *testing.T, root string)
+		patterns    []string
+		expectError bool
+		expected    []string
+	}{
+		{
+			name: "Directories matching a glob",
+			setupFunc: func(t *testing.T, root string) {
+				writePackage(t, root, "packages/app", `{"name":"app","version":"1.0.0","dependencies":{"utils":"workspace:*"}}`)
+				writePackage(t, root, "packages/utils", `{"name":"utils","version":"1.0.0"}`)
+				assert.NoError(t, os.MkdirAll(filepath.Join(root, "packages", "no-package-json"), 0755))
+			},
+			patterns: []string{"packages/*"},
+			expected: []string{"packages/app", "packages/utils"},
+		},
+		{
+			name: "Double star matches nested directories but not node_modules",
+			setupFunc: func(t *testing.T, root string) {
+				writePackage(t, root, "libs/a", `{"name":"a"}`)
+				writePackage(t, root, "libs/group/b", `{"name":"b"}`)
+				writePackage(t, root, "libs/a/node_modules/dep", `{"name":"dep"}`)
+			},
+			patterns: []string{"libs/**"},
+			expected: []string{"libs/a", "libs/group/b"},
+		},
+		{
+			name: "Plain directories and exclusions",
+			setupFunc: func(t *testing.T, root string) {
+				writePackage(t, root, "tools", `{"name":"tools"}`)
+				writePackage(t, root, "packages/app", `{"name":"app"}`)
+				writePackage(t, root, "packages/legacy", `{"name":"legacy"}`)
+			},
+			patterns: []string{"./tools", "packages/*", "!packages/legacy"},
+			expected: []string{"packages/app", "tools"},
+		},
+		{
+			name: "Missing directories match nothing",
+			setupFunc: func(t *testing.T, root string) {
+			},
+			patterns: []string{"packages/*"},
+			expected: []string{},
+		},
+		{
+			name: "Error on duplicate names",
+			setupFunc: func(t *testing.T, root string) {
+				writePackage(t, root, "packages/a", `{"name":"same"}`)
+				writePackage(t, root, "packages/b", `{"name":"same"}`)
+			},
+			patterns:    []string{"packages/*"},
+			expectError: true,
+		},
+		{
+			name: "Error on a workspace without a name",
+			setupFunc: func(t *testing.T, root string) {
+				writePackage(t, root, "packages/a", `{"version":"1.0.0"}`)
+			},
+			patterns:    []string{"packages/*"},
+			expectError: true,
+		},
+		{
+			name: "Error on an invalid pattern",
+			setupFunc: func(t *testing.T, root string) {
+			},
+			patterns:    []string{"packages/["},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			tc.setupFunc(t, root)
+
+			packages, err := Discover(root, tc.patterns)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			dirs := make([]string, 0, len(packages))
+			for _, pkg := range packages {
+				dirs = append(dirs, pkg.Dir)
+			}
+			assert.Equal(t, tc.expected, dirs)
+		})
+	}
+}
+
+func TestDiscover_ReadsPackages(t *testing.T) {
+	root := t.TempDir()
+	writePackage(t, root, "packages/app", `{
+		"name": "@repo/app",
+		"version": "2.1.0",
+		"dependencies": {"@repo/utils": "workspace:^"},
+		"devDependencies": {"jest": "^29.0.0"},
+		"scripts": {"build": "tsc"}
+	}`)
+
+	packages, err := Discover(root, []string{"packages/*"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Package{{
+		Name:            "@repo/app",
+		Version:         "2.1.0",
+		Dir:             "packages/app",
+		Dependencies:    map[string]string{"@repo/utils": "workspace:^"},
+		DevDependencies: map[string]string{"jest": "^29.0.0"},
+		Scripts:         map[string]string{"build": "tsc"},
+	}}, packages)
+}
+
+func TestMatchGlob(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"packages/*", "packages/app", true},
+		{"packages/*", "packages/app/nested", false},
+		{"packages/**", "packages/app/nested", true},
+		{"packages/**/ui", "packages/ui", true},
+		{"packages/**/ui", "packages/web/ui", true},
+		{"packages/**/ui", "packages/web/api", false},
+		{"apps/web-*", "apps/web-admin", true},
+		{"apps/web-*", "apps/api", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchGlob(tc.pattern, tc.name))
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	packages := []Package{{Name: "@repo/app", Dir: "packages/app"}, {Name: "utils", Dir: "packages/utils"}}
+
+	testCases := []struct {
+		name     string
+		expected string
+		found    bool
+	}{
+		{"@repo/app", "packages/app", true},
+		{"packages/utils", "packages/utils", true},
+		{"./packages/utils/", "packages/utils", true},
+		{"missing", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pkg, found := Find(packages, tc.name)
+			assert.Equal(t, tc.found, found)
+			assert.Equal(t, tc.expected, pkg.Dir)
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	pkg := Package{Name: "utils", Version: "1.4.2"}
+
+	testCases := []struct {
+		spec     string
+		expected string
+	}{
+		{"workspace:*", "*"},
+		{"workspace:", "*"},
+		{"workspace:^", "^1.4.2"},
+		{"workspace:~", "~1.4.2"},
+		{"workspace:^1.0.0", "^1.0.0"},
+		{"workspace:1.4.2", "1.4.2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Range(tc.spec, pkg))
+			assert.True(t, IsProtocol(tc.spec))
+		})
+	}
+	assert.False(t, IsProtocol("^1.0.0"))
+}